@@ -0,0 +1,102 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// AnnotationVersion is the envelope version written by MarshalAnnotation.
+const AnnotationVersion = 1
+
+// MaxAgentNameLength is the longest agent name MAAS will store, so the
+// longest annotation that fits in AllocateMachineArgs.AgentName.
+const MaxAgentNameLength = 255
+
+// annotation is the envelope that wraps the data. The version field also
+// marks the string as an annotation rather than free text that happens to
+// be JSON.
+type annotation struct {
+	Version int             `json:"annotation"`
+	Kind    string          `json:"kind,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// MarshalAnnotation wraps data, which must marshal to JSON, in a small
+// versioned envelope. The result is suitable for the Comment or AgentName
+// of an allocation, so structured context can be recovered later with
+// UnmarshalAnnotation. The kind is free form, and lets readers tell apart
+// the annotations written by different tools.
+func MarshalAnnotation(kind string, data interface{}) (string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", errors.Annotate(err, "marshalling annotation data")
+	}
+	out, err := json.Marshal(annotation{
+		Version: AnnotationVersion,
+		Kind:    kind,
+		Data:    raw,
+	})
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return string(out), nil
+}
+
+// MarshalAgentNameAnnotation is MarshalAnnotation for use as an agent
+// name. It fails with an error satisfying errors.IsNotValid if the result
+// is longer than MaxAgentNameLength.
+func MarshalAgentNameAnnotation(kind string, data interface{}) (string, error) {
+	result, err := MarshalAnnotation(kind, data)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if len(result) > MaxAgentNameLength {
+		msg := fmt.Sprintf("annotation is %d bytes, exceeding the agent name limit of %d bytes", len(result), MaxAgentNameLength)
+		return "", errors.NewNotValid(nil, msg)
+	}
+	return result, nil
+}
+
+// IsAnnotation returns true if the value was written by MarshalAnnotation.
+func IsAnnotation(value string) bool {
+	_, err := parseAnnotation(value)
+	return err == nil
+}
+
+// UnmarshalAnnotation reads an annotation written by MarshalAnnotation,
+// storing its data in the value pointed to by data, and returns its kind.
+// If the value is not an annotation, such as a comment written by hand,
+// the error satisfies errors.IsNotValid. Annotations from a later version
+// of this package give an error satisfying errors.IsNotSupported.
+func UnmarshalAnnotation(value string, data interface{}) (string, error) {
+	envelope, err := parseAnnotation(value)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if envelope.Version > AnnotationVersion {
+		return "", errors.NotSupportedf("annotation version %d", envelope.Version)
+	}
+	if len(envelope.Data) > 0 && data != nil {
+		if err := json.Unmarshal(envelope.Data, data); err != nil {
+			return "", errors.Annotatef(err, "unmarshalling %q annotation data", envelope.Kind)
+		}
+	}
+	return envelope.Kind, nil
+}
+
+func parseAnnotation(value string) (*annotation, error) {
+	if !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return nil, errors.NotValidf("annotation %q", value)
+	}
+	var envelope annotation
+	if err := json.Unmarshal([]byte(value), &envelope); err != nil || envelope.Version < 1 {
+		return nil, errors.NotValidf("annotation %q", value)
+	}
+	return &envelope, nil
+}
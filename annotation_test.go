@@ -0,0 +1,63 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type annotationSuite struct{}
+
+var _ = gc.Suite(&annotationSuite{})
+
+type buildInfo struct {
+	Job   string `json:"job"`
+	Build int    `json:"build"`
+}
+
+func (*annotationSuite) TestRoundTrip(c *gc.C) {
+	value, err := MarshalAnnotation("ci", buildInfo{Job: "nightly", Build: 42})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(value, gc.Equals, `{"annotation":1,"kind":"ci","data":{"job":"nightly","build":42}}`)
+	c.Check(IsAnnotation(value), jc.IsTrue)
+
+	var info buildInfo
+	kind, err := UnmarshalAnnotation(value, &info)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(kind, gc.Equals, "ci")
+	c.Check(info, jc.DeepEquals, buildInfo{Job: "nightly", Build: 42})
+}
+
+func (*annotationSuite) TestUnmarshalNotAnnotation(c *gc.C) {
+	for _, value := range []string{"", "allocated by bob", `{"job": "nightly"}`, "{broken"} {
+		c.Logf("value %q", value)
+		c.Check(IsAnnotation(value), jc.IsFalse)
+		_, err := UnmarshalAnnotation(value, nil)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+}
+
+func (*annotationSuite) TestUnmarshalLaterVersion(c *gc.C) {
+	_, err := UnmarshalAnnotation(`{"annotation":2,"kind":"ci"}`, nil)
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (*annotationSuite) TestUnmarshalBadData(c *gc.C) {
+	var info buildInfo
+	_, err := UnmarshalAnnotation(`{"annotation":1,"kind":"ci","data":{"build":"x"}}`, &info)
+	c.Check(err, gc.ErrorMatches, `unmarshalling "ci" annotation data: .*`)
+}
+
+func (*annotationSuite) TestMarshalAgentNameTooLong(c *gc.C) {
+	_, err := MarshalAgentNameAnnotation("ci", strings.Repeat("x", MaxAgentNameLength))
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+
+	value, err := MarshalAgentNameAnnotation("ci", "short")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(value, gc.Equals, `{"annotation":1,"kind":"ci","data":"short"}`)
+}
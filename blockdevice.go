@@ -4,12 +4,17 @@
 package gomaasapi
 
 import (
+	"fmt"
+	"net/http"
+
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
 )
 
 type blockdevice struct {
+	controller *controller
+
 	resourceURI string
 
 	id      int
@@ -103,6 +108,66 @@ func (b *blockdevice) Partitions() []Partition {
 	return result
 }
 
+// setController sets the controller of the block device and its
+// partitions.
+func (b *blockdevice) setController(c *controller) {
+	b.controller = c
+	for _, p := range b.partitions {
+		p.controller = c
+	}
+}
+
+// CreatePartitionArgs is an argument struct for BlockDevice.CreatePartition
+// and Machine.CreateBlockDevicePartition.
+type CreatePartitionArgs struct {
+	// Size of the partition in bytes. If it is zero, the partition takes
+	// the rest of the free space of the block device.
+	Size uint64
+	// UUID of the partition. The server generates one if it is empty.
+	UUID string
+	// Bootable marks the partition as bootable.
+	Bootable bool
+}
+
+// CreatePartition implements BlockDevice.
+func (b *blockdevice) CreatePartition(args CreatePartitionArgs) (Partition, error) {
+	result, err := b.createPartition(args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
+
+func (b *blockdevice) createPartition(args CreatePartitionArgs) (*partition, error) {
+	params := NewURLParams()
+	if args.Size > 0 {
+		params.Values.Add("size", fmt.Sprint(args.Size))
+	}
+	params.MaybeAdd("uuid", args.UUID)
+	params.MaybeAddBool("bootable", args.Bootable)
+	source, err := b.controller.post(b.resourceURI+"partitions/", "", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusBadRequest, http.StatusConflict:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	result, err := readPartition(b.controller.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result.controller = b.controller
+	b.partitions = append(b.partitions, result)
+	return result, nil
+}
+
 func readBlockDevices(controllerVersion version.Number, source interface{}) ([]*blockdevice, error) {
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
@@ -130,11 +195,11 @@ func readBlockDeviceList(sourceList []interface{}, readFunc blockdeviceDeseriali
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, NewDeserializationError("unexpected value for blockdevice %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for blockdevice %d, %T", i, value), indexPath(i))
 		}
 		blockdevice, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "blockdevice %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "blockdevice %d", i)
 		}
 		result = append(result, blockdevice)
 	}
@@ -179,12 +244,12 @@ func blockdevice_2_0(source map[string]interface{}) (*blockdevice, error) {
 	var filesystem *filesystem
 	if fsSource, ok := valid["filesystem"].(map[string]interface{}); ok {
 		if filesystem, err = filesystem2_0(fsSource); err != nil {
-			return nil, errors.Trace(err)
+			return nil, errors.Trace(atPath(err, "filesystem"))
 		}
 	}
 	partitions, err := readPartitionList(valid["partitions"].([]interface{}), partition_2_0)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return nil, errors.Trace(atPath(err, "partitions"))
 	}
 
 	uuid, _ := valid["uuid"].(string)
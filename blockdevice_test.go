@@ -4,6 +4,9 @@
 package gomaasapi
 
 import (
+	"net/http"
+
+	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
@@ -60,6 +63,50 @@ func (*blockdeviceSuite) TestReadBlockDevicesWithNulls(c *gc.C) {
 	c.Check(blockdevice.FileSystem(), gc.IsNil)
 }
 
+func (s *machineSuite) TestBlockDeviceCreatePartition(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/blockdevices/34/partitions/?op=", http.StatusOK, partitionResponse(2, "null"))
+	device := machine.BlockDevice(34)
+
+	partition, err := device.CreatePartition(CreatePartitionArgs{Size: 1073741824, Bootable: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(partition.ID(), gc.Equals, 2)
+	c.Check(device.Partitions(), gc.HasLen, 2)
+	c.Check(machine.Partition(2), gc.NotNil)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("size"), gc.Equals, "1073741824")
+	c.Check(form.Get("bootable"), gc.Equals, "true")
+	c.Check(form.Get("uuid"), gc.Equals, "")
+}
+
+func (s *machineSuite) TestBlockDeviceCreatePartitionNoSpace(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/blockdevices/34/partitions/?op=", http.StatusBadRequest, "not enough space")
+	_, err := machine.BlockDevice(34).CreatePartition(CreatePartitionArgs{})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(machine.BlockDevice(34).Partitions(), gc.HasLen, 1)
+}
+
+func (s *machineSuite) TestCreateBlockDevicePartition(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/blockdevices/34/partitions/?op=", http.StatusOK, partitionResponse(2, "null"))
+
+	partition, err := machine.CreateBlockDevicePartition(machine.PhysicalBlockDevice(34), CreatePartitionArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(partition.ID(), gc.Equals, 2)
+	c.Check(machine.BlockDevice(34).Partitions(), gc.HasLen, 2)
+	c.Check(machine.PhysicalBlockDevice(34).Partitions(), gc.HasLen, 2)
+}
+
+func (s *machineSuite) TestCreateBlockDevicePartitionOtherMachine(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	_, err := machine.CreateBlockDevicePartition(&blockdevice{id: 99, name: "sdb"}, CreatePartitionArgs{})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, `block device "sdb" not of machine 4y3ha3 not valid`)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
 func (*blockdeviceSuite) TestLowVersion(c *gc.C) {
 	_, err := readBlockDevices(version.MustParse("1.9.0"), parseJSON(c, blockdevicesResponse))
 	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
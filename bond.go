@@ -0,0 +1,228 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+)
+
+// The bonding modes of the Linux bonding driver, for CreateBondArgs.
+const (
+	BondModeBalanceRR    = "balance-rr"
+	BondModeActiveBackup = "active-backup"
+	BondModeBalanceXOR   = "balance-xor"
+	BondModeBroadcast    = "broadcast"
+	BondMode8023AD       = "802.3ad"
+	BondModeBalanceTLB   = "balance-tlb"
+	BondModeBalanceALB   = "balance-alb"
+)
+
+var knownBondModes = set.NewStrings(
+	BondModeBalanceRR, BondModeActiveBackup, BondModeBalanceXOR, BondModeBroadcast,
+	BondMode8023AD, BondModeBalanceTLB, BondModeBalanceALB,
+)
+
+// CreateBondArgs is an argument struct for Machine.CreateBond and
+// Machine.BondInterfaces. Name and Parents are required; the server picks
+// defaults for the others.
+type CreateBondArgs struct {
+	// Name of the bond, such as "bond0".
+	Name string
+	// Parents are the interfaces of the machine that are bonded.
+	Parents []Interface
+	// MACAddress of the bond. It is that of the first parent by default.
+	MACAddress string
+	// VLAN is the untagged VLAN of the bond. It is that of the first
+	// parent by default.
+	VLAN VLAN
+	// Tags to attach to the bond.
+	Tags []string
+	// MTU - Maximum transmission unit.
+	MTU int
+
+	// Mode is one of the BondMode values. The server uses
+	// BondModeBalanceRR if it isn't set.
+	Mode string
+	// MIIMon is the link monitoring frequency in milliseconds.
+	MIIMon int
+	// DownDelay and UpDelay are the milliseconds to wait before disabling
+	// and enabling a parent when its link goes down and up.
+	DownDelay int
+	UpDelay   int
+	// LACPRate is "fast" or "slow", for BondMode8023AD.
+	LACPRate string
+	// XmitHashPolicy is the transmit hash policy, such as "layer2" or
+	// "layer3+4", for BondModeBalanceXOR, BondMode8023AD and
+	// BondModeBalanceTLB.
+	XmitHashPolicy string
+}
+
+// Validate ensures that the name and parents are set, and that the mode
+// and LACP rate are known values.
+func (a *CreateBondArgs) Validate() error {
+	if a.Name == "" {
+		return errors.NotValidf("missing Name")
+	}
+	if len(a.Parents) == 0 {
+		return errors.NotValidf("missing Parents")
+	}
+	for _, parent := range a.Parents {
+		if parent == nil {
+			return errors.NotValidf("nil Parent")
+		}
+	}
+	if a.Mode != "" && !knownBondModes.Contains(a.Mode) {
+		return errors.NotValidf("unknown Mode value (%q)", a.Mode)
+	}
+	switch a.LACPRate {
+	case "", "fast", "slow":
+	default:
+		return errors.NotValidf("unknown LACPRate value (%q)", a.LACPRate)
+	}
+	return nil
+}
+
+// CreateBond implements Machine.
+func (m *machine) CreateBond(args CreateBondArgs) (Interface, error) {
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := m.checkParents(args.Parents...); err != nil {
+		return nil, errors.Trace(err)
+	}
+	bond, err := m.createBond(args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	m.interfaceSet = append(m.interfaceSet, bond)
+	return bond, nil
+}
+
+func (m *machine) createBond(args CreateBondArgs) (*interface_, error) {
+	params := NewURLParams()
+	params.Values.Add("name", args.Name)
+	for _, parent := range args.Parents {
+		params.Values.Add("parents", fmt.Sprint(parent.ID()))
+	}
+	params.MaybeAdd("mac_address", args.MACAddress)
+	if args.VLAN != nil {
+		params.Values.Add("vlan", fmt.Sprint(args.VLAN.ID()))
+	}
+	params.MaybeAdd("tags", strings.Join(args.Tags, ","))
+	params.MaybeAddInt("mtu", args.MTU)
+	params.MaybeAdd("bond_mode", args.Mode)
+	params.MaybeAddInt("bond_miimon", args.MIIMon)
+	params.MaybeAddInt("bond_downdelay", args.DownDelay)
+	params.MaybeAddInt("bond_updelay", args.UpDelay)
+	params.MaybeAdd("bond_lacp_rate", args.LACPRate)
+	params.MaybeAdd("bond_xmit_hash_policy", args.XmitHashPolicy)
+	return m.postInterface("create_bond", params)
+}
+
+// BondInterfaces implements Machine.
+func (m *machine) BondInterfaces(args CreateBondArgs) (Interface, error) {
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := m.checkParents(args.Parents...); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// The links of the parents, as they were, and those for the bond
+	// with the duplicates removed.
+	original := make([][]linkState, len(args.Parents))
+	var moved []linkState
+	for i, parent := range args.Parents {
+		for _, link := range parent.Links() {
+			state := linkState{
+				mode:      InterfaceLinkMode(strings.ToUpper(link.Mode())),
+				subnet:    link.Subnet(),
+				ipAddress: link.IPAddress(),
+			}
+			if state.subnet == nil {
+				continue
+			}
+			original[i] = append(original[i], state)
+			moved = addBondLink(moved, state)
+		}
+	}
+
+	unlinked := make([][]linkState, len(args.Parents))
+	var bond *interface_
+	rollback := func(cause error) error {
+		var failures []string
+		if bond != nil {
+			// Deleting the bond removes its links.
+			if err := bond.Delete(); err != nil {
+				failures = append(failures, err.Error())
+			}
+		}
+		for i, states := range unlinked {
+			for _, state := range states {
+				if err := args.Parents[i].LinkSubnet(state.args()); err != nil {
+					failures = append(failures, err.Error())
+				}
+			}
+		}
+		if len(failures) > 0 {
+			return errors.Annotatef(cause, "rollback failed (%s)", strings.Join(failures, "; "))
+		}
+		return errors.Trace(cause)
+	}
+
+	for i, parent := range args.Parents {
+		for _, state := range original[i] {
+			if err := parent.UnlinkSubnet(state.subnet); err != nil {
+				return nil, rollback(errors.Annotatef(err, "unlinking %s from subnet %d", parent.Name(), state.subnet.ID()))
+			}
+			unlinked[i] = append(unlinked[i], state)
+		}
+	}
+	created, err := m.createBond(args)
+	if err != nil {
+		return nil, rollback(errors.Annotatef(err, "creating bond %s", args.Name))
+	}
+	bond = created
+	for _, state := range moved {
+		if err := bond.LinkSubnet(state.args()); err != nil {
+			return nil, rollback(errors.Annotatef(err, "linking %s to subnet %d", args.Name, state.subnet.ID()))
+		}
+	}
+	m.interfaceSet = append(m.interfaceSet, bond)
+	return bond, nil
+}
+
+// addBondLink adds the link state to those for the bond unless it repeats
+// one of them. A static address is kept for each parent that had one, but
+// the other modes are only kept once for each subnet, and LINK_UP only for
+// a subnet without another link.
+func addBondLink(states []linkState, state linkState) []linkState {
+	for i, existing := range states {
+		if existing.subnet.ID() != state.subnet.ID() {
+			continue
+		}
+		switch {
+		case existing.mode == state.mode && existing.ipAddress == state.ipAddress:
+			return states
+		case state.mode == LinkModeLinkUp:
+			return states
+		case existing.mode == LinkModeLinkUp:
+			states[i] = state
+			return states
+		case state.mode != LinkModeStatic && existing.mode == state.mode:
+			return states
+		}
+	}
+	return append(states, state)
+}
@@ -0,0 +1,186 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+// unlinkedInterfaceResponse returns an interface of machine 4y3ha3 with no
+// links.
+func unlinkedInterfaceResponse(id int, name, type_ string) string {
+	return fmt.Sprintf(`{
+    "id": %d, "name": %q, "type": %q, "enabled": true, "tags": [],
+    "resource_uri": "/MAAS/api/2.0/nodes/4y3ha3/interfaces/%d/",
+    "vlan": null, "links": [], "effective_mtu": 1500,
+    "parents": [], "children": []
+}`, id, name, type_, id)
+}
+
+func requestLines(server *SimpleTestServer, n int) []string {
+	var result []string
+	for _, request := range server.LastNRequests(n) {
+		result = append(result, request.Method+" "+request.URL.String())
+	}
+	return result
+}
+
+func (s *machineSuite) TestCreateBondArgsValidate(c *gc.C) {
+	parent := &interface_{id: 35}
+	for i, test := range []struct {
+		args    CreateBondArgs
+		errText string
+	}{{
+		args:    CreateBondArgs{Parents: []Interface{parent}},
+		errText: "missing Name not valid",
+	}, {
+		args:    CreateBondArgs{Name: "bond0"},
+		errText: "missing Parents not valid",
+	}, {
+		args:    CreateBondArgs{Name: "bond0", Parents: []Interface{parent}, Mode: "round-robin"},
+		errText: `unknown Mode value ("round-robin") not valid`,
+	}, {
+		args:    CreateBondArgs{Name: "bond0", Parents: []Interface{parent}, LACPRate: "medium"},
+		errText: `unknown LACPRate value ("medium") not valid`,
+	}, {
+		args: CreateBondArgs{Name: "bond0", Parents: []Interface{parent}, Mode: BondMode8023AD, LACPRate: "fast"},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *machineSuite) TestCreateBond(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/api/2.0/nodes/4y3ha3/interfaces/?op=create_bond", http.StatusOK, unlinkedInterfaceResponse(100, "bond0", "bond"))
+	parents := machine.InterfaceSet()
+
+	bond, err := machine.CreateBond(CreateBondArgs{
+		Name:     "bond0",
+		Parents:  parents,
+		Mode:     BondMode8023AD,
+		LACPRate: "fast",
+		MIIMon:   100,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(bond.Name(), gc.Equals, "bond0")
+	c.Check(machine.Interface(100), gc.NotNil)
+
+	form := server.LastRequest().PostForm
+	c.Check(form["parents"], jc.DeepEquals, []string{"35", "99"})
+	c.Check(form.Get("name"), gc.Equals, "bond0")
+	c.Check(form.Get("bond_mode"), gc.Equals, "802.3ad")
+	c.Check(form.Get("bond_lacp_rate"), gc.Equals, "fast")
+	c.Check(form.Get("bond_miimon"), gc.Equals, "100")
+	c.Check(form.Get("vlan"), gc.Equals, "")
+}
+
+func (s *machineSuite) TestCreateBondParentOfOtherMachine(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	_, err := machine.CreateBond(CreateBondArgs{
+		Name:    "bond0",
+		Parents: []Interface{&interface_{id: 40, name: "eth0"}},
+	})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestCreateBondServerError(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/api/2.0/nodes/4y3ha3/interfaces/?op=create_bond", http.StatusBadRequest, "parents in use")
+	_, err := machine.CreateBond(CreateBondArgs{Name: "bond0", Parents: machine.InterfaceSet()})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(machine.InterfaceSet(), gc.HasLen, 2)
+}
+
+func (s *machineSuite) TestBondInterfaces(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/35/?op=unlink_subnet", http.StatusOK, unlinkedInterfaceResponse(35, "eth0", "physical"))
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/99/?op=unlink_subnet", http.StatusOK, unlinkedInterfaceResponse(99, "eth1", "physical"))
+	server.AddPostResponse("/api/2.0/nodes/4y3ha3/interfaces/?op=create_bond", http.StatusOK, unlinkedInterfaceResponse(100, "bond0", "bond"))
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/100/?op=link_subnet", http.StatusOK, interfaceResponse)
+
+	bond, err := machine.BondInterfaces(CreateBondArgs{Name: "bond0", Parents: machine.InterfaceSet()})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(bond.Links(), gc.HasLen, 1)
+	c.Check(requestLines(server, 4), jc.DeepEquals, []string{
+		"POST /MAAS/api/2.0/nodes/4y3ha3/interfaces/35/?op=unlink_subnet",
+		"POST /MAAS/api/2.0/nodes/4y3ha3/interfaces/99/?op=unlink_subnet",
+		"POST /api/2.0/nodes/4y3ha3/interfaces/?op=create_bond",
+		"POST /MAAS/api/2.0/nodes/4y3ha3/interfaces/100/?op=link_subnet",
+	})
+	// Both parents were linked to subnet 1 in auto mode, which the bond
+	// is linked to once.
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("mode"), gc.Equals, "AUTO")
+	c.Check(form.Get("subnet"), gc.Equals, "1")
+	c.Check(machine.InterfaceSet(), gc.HasLen, 3)
+}
+
+func (s *machineSuite) TestBondInterfacesRollback(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/35/?op=unlink_subnet", http.StatusOK, unlinkedInterfaceResponse(35, "eth0", "physical"))
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/99/?op=unlink_subnet", http.StatusOK, unlinkedInterfaceResponse(99, "eth1", "physical"))
+	server.AddPostResponse("/api/2.0/nodes/4y3ha3/interfaces/?op=create_bond", http.StatusOK, unlinkedInterfaceResponse(100, "bond0", "bond"))
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/100/?op=link_subnet", http.StatusServiceUnavailable, "no addresses")
+	server.AddDeleteResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/100/", http.StatusNoContent, "")
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/35/?op=link_subnet", http.StatusOK, interfaceResponse)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/99/?op=link_subnet", http.StatusOK, interfaceResponse)
+
+	_, err := machine.BondInterfaces(CreateBondArgs{Name: "bond0", Parents: machine.InterfaceSet()})
+	c.Assert(err, gc.ErrorMatches, "linking bond0 to subnet 1: .*no addresses.*")
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(requestLines(server, 4), jc.DeepEquals, []string{
+		"POST /MAAS/api/2.0/nodes/4y3ha3/interfaces/100/?op=link_subnet",
+		"DELETE /MAAS/api/2.0/nodes/4y3ha3/interfaces/100/",
+		"POST /MAAS/api/2.0/nodes/4y3ha3/interfaces/35/?op=link_subnet",
+		"POST /MAAS/api/2.0/nodes/4y3ha3/interfaces/99/?op=link_subnet",
+	})
+	c.Check(machine.InterfaceSet(), gc.HasLen, 2)
+}
+
+func (s *machineSuite) TestBondInterfacesUnlinkFails(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/35/?op=unlink_subnet", http.StatusOK, unlinkedInterfaceResponse(35, "eth0", "physical"))
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/99/?op=unlink_subnet", http.StatusForbidden, "no")
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/35/?op=link_subnet", http.StatusOK, interfaceResponse)
+
+	_, err := machine.BondInterfaces(CreateBondArgs{Name: "bond0", Parents: machine.InterfaceSet()})
+	c.Assert(err, gc.ErrorMatches, "unlinking eth0 from subnet 1: .*")
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(server.LastRequest().URL.String(), gc.Equals, "/MAAS/api/2.0/nodes/4y3ha3/interfaces/35/?op=link_subnet")
+}
+
+func (*machineSuite) TestAddBondLink(c *gc.C) {
+	one := &fakeSubnet{id: 1}
+	two := &fakeSubnet{id: 2}
+	var states []linkState
+	for _, state := range []linkState{
+		{mode: LinkModeLinkUp, subnet: one},
+		{mode: LinkModeStatic, subnet: one, ipAddress: "10.0.0.1"},
+		{mode: LinkModeStatic, subnet: one, ipAddress: "10.0.0.1"},
+		{mode: LinkModeStatic, subnet: one, ipAddress: "10.0.0.2"},
+		{mode: LinkModeLinkUp, subnet: one},
+		{mode: LinkModeDHCP, subnet: two},
+		{mode: LinkModeDHCP, subnet: two},
+	} {
+		states = addBondLink(states, state)
+	}
+	c.Check(states, jc.DeepEquals, []linkState{
+		{mode: LinkModeStatic, subnet: one, ipAddress: "10.0.0.1"},
+		{mode: LinkModeStatic, subnet: one, ipAddress: "10.0.0.2"},
+		{mode: LinkModeDHCP, subnet: two},
+	})
+}
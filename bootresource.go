@@ -4,6 +4,13 @@
 package gomaasapi
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/juju/collections/set"
@@ -134,3 +141,265 @@ func bootResource_2_0(source map[string]interface{}) (*bootResource, error) {
 	}
 	return result, nil
 }
+
+const (
+	// DefaultUploadChunkSize is the size of each request made by
+	// UploadBootResource when UploadBootResourceArgs.ChunkSize is not set.
+	DefaultUploadChunkSize = 4 << 20
+
+	// DefaultUploadRetries is how many times UploadBootResource resumes an
+	// interrupted upload when UploadBootResourceArgs.MaxRetries is not set.
+	DefaultUploadRetries = 3
+)
+
+// UploadBootResourceArgs is an argument struct for passing information into
+// UploadBootResource.
+type UploadBootResourceArgs struct {
+	// Name is the name of the image, such as "custom/centos7-gpu".
+	Name string
+	// Architecture is the architecture with subarchitecture, such as
+	// "amd64/generic".
+	Architecture string
+	// Title is optional and shown in the UI.
+	Title string
+	// FileType is the kind of image, such as "tgz" or "ddtgz". The server
+	// default of "tgz" is used if it is empty.
+	FileType string
+
+	// Content is read from the start, and read again from part way when
+	// an upload is resumed.
+	Content io.ReadSeeker
+	// Size and SHA256 describe Content. They are worked out by reading
+	// Content if they are not set, which takes a while for a large image.
+	Size   int64
+	SHA256 string
+
+	// ChunkSize is the number of bytes sent in each request. Zero means
+	// that DefaultUploadChunkSize is used.
+	ChunkSize int
+	// MaxRetries is the number of times an interrupted upload is resumed
+	// before giving up. Zero means that DefaultUploadRetries is used, and a
+	// negative value disables resuming.
+	MaxRetries int
+
+	// Progress, if set, is called after each chunk with the number of
+	// bytes the server has and the total size.
+	Progress func(uploaded, total int64)
+}
+
+// Validate ensures that the required fields are set.
+func (a *UploadBootResourceArgs) Validate() error {
+	if a.Name == "" {
+		return errors.NotValidf("missing Name")
+	}
+	if a.Architecture == "" {
+		return errors.NotValidf("missing Architecture")
+	}
+	if a.Content == nil {
+		return errors.NotValidf("missing Content")
+	}
+	if a.Size < 0 {
+		return errors.NotValidf("negative Size")
+	}
+	if a.ChunkSize < 0 {
+		return errors.NotValidf("negative ChunkSize")
+	}
+	return nil
+}
+
+// describeContent fills in the size and hash of the content if they were
+// not given.
+func (a *UploadBootResourceArgs) describeContent() error {
+	if a.SHA256 != "" && a.Size > 0 {
+		return nil
+	}
+	if _, err := a.Content.Seek(0, io.SeekStart); err != nil {
+		return errors.Trace(err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, a.Content)
+	if err != nil {
+		return errors.Annotate(err, "reading content")
+	}
+	if a.SHA256 == "" {
+		a.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	}
+	a.Size = size
+	return nil
+}
+
+// bootResourceUpload is the state of the uploaded file of a boot resource.
+type bootResourceUpload struct {
+	complete  bool
+	uploadURI string
+	// progress is the fraction of the file that the server has.
+	progress float64
+}
+
+// offset returns the number of bytes the server has of a file of the size.
+func (u bootResourceUpload) offset(size int64) int64 {
+	return int64(math.Round(u.progress * float64(size)))
+}
+
+// UploadBootResource implements Controller.
+func (c *controller) UploadBootResource(args UploadBootResourceArgs) (BootResource, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := args.describeContent(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	chunkSize := args.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = DefaultUploadChunkSize
+	}
+	maxRetries := args.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultUploadRetries
+	} else if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	// Creating the resource without content gives an upload URI for the
+	// content. The server keeps partial content by hash, so this also
+	// finds an earlier upload of the same content that did not finish.
+	params := NewURLParams()
+	params.Values.Add("name", args.Name)
+	params.Values.Add("architecture", args.Architecture)
+	params.MaybeAdd("title", args.Title)
+	params.MaybeAdd("filetype", args.FileType)
+	params.Values.Add("sha256", args.SHA256)
+	params.Values.Add("size", fmt.Sprint(args.Size))
+	source, err := c.post(BootResourcesPath, "", params.Values)
+	if err != nil {
+		return nil, mapUploadError(err)
+	}
+
+	var uploadErr error
+	for attempt := 0; ; attempt++ {
+		resource, upload, err := readBootResourceUpload(c.schemaVersion(), source, args.SHA256)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if upload.complete {
+			return resource, nil
+		}
+		if attempt > maxRetries {
+			if uploadErr == nil {
+				uploadErr = errors.Errorf("server has %d of %d bytes", upload.offset(args.Size), args.Size)
+			}
+			return nil, errors.Annotatef(uploadErr, "uploading boot resource %q", args.Name)
+		}
+		if attempt > 0 {
+			controllerLogger.Warningf("resuming upload of boot resource %q after: %v", args.Name, uploadErr)
+		}
+		uploadErr = c.uploadBootResourceContent(upload, args, chunkSize)
+		if uploadErr != nil && !isRetryableUploadError(uploadErr) {
+			return nil, mapUploadError(uploadErr)
+		}
+		if source, err = c.get(resource.resourceURI); err != nil {
+			return nil, mapUploadError(err)
+		}
+	}
+}
+
+func (c *controller) uploadBootResourceContent(upload bootResourceUpload, args UploadBootResourceArgs, chunkSize int) error {
+	offset := upload.offset(args.Size)
+	if _, err := args.Content.Seek(offset, io.SeekStart); err != nil {
+		return errors.Trace(err)
+	}
+	buffer := make([]byte, chunkSize)
+	for offset < args.Size {
+		n, err := io.ReadFull(args.Content, buffer)
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			if offset+int64(n) != args.Size {
+				return errors.Errorf("content is shorter than %d bytes", args.Size)
+			}
+		} else if err != nil {
+			return errors.Trace(err)
+		}
+		if _, err := c.putContent(upload.uploadURI, buffer[:n]); err != nil {
+			return errors.Trace(err)
+		}
+		offset += int64(n)
+		if args.Progress != nil {
+			args.Progress(offset, args.Size)
+		}
+	}
+	return nil
+}
+
+// isRetryableUploadError returns true if the error is not the server
+// refusing the content, such as the connection dropping or the server
+// failing, in which case the upload may be resumed.
+func isRetryableUploadError(err error) bool {
+	svrErr, ok := errors.Cause(err).(ServerError)
+	if !ok {
+		_, isURLErr := errors.Cause(err).(*url.Error)
+		return isURLErr
+	}
+	return svrErr.StatusCode >= http.StatusInternalServerError
+}
+
+func mapUploadError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+// readBootResourceUpload reads a boot resource with its sets, as returned
+// when creating or getting a single resource, and the state of the file
+// with the hash.
+func readBootResourceUpload(controllerVersion version.Number, source interface{}, sha string) (*bootResource, bootResourceUpload, error) {
+	var upload bootResourceUpload
+	resources, err := readBootResources(controllerVersion, []interface{}{source})
+	if err != nil {
+		return nil, upload, errors.Trace(err)
+	}
+	fileFields := schema.Fields{
+		"sha256":     schema.String(),
+		"complete":   schema.Bool(),
+		"progress":   schema.Float(),
+		"upload_uri": schema.String(),
+	}
+	fileDefaults := schema.Defaults{
+		"progress":   0.0,
+		"upload_uri": "",
+	}
+	setFields := schema.Fields{
+		"files": schema.StringMap(schema.FieldMap(fileFields, fileDefaults)),
+	}
+	checker := schema.FieldMap(schema.Fields{
+		"sets": schema.StringMap(schema.FieldMap(setFields, nil)),
+	}, schema.Defaults{"sets": schema.Omit})
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, upload, WrapWithDeserializationError(err, "boot resource sets schema check failed")
+	}
+	sets, _ := coerced.(map[string]interface{})["sets"].(map[string]interface{})
+	for _, resourceSet := range sets {
+		files := resourceSet.(map[string]interface{})["files"].(map[string]interface{})
+		for _, file := range files {
+			valid := file.(map[string]interface{})
+			if valid["sha256"].(string) != sha {
+				continue
+			}
+			upload.complete = valid["complete"].(bool)
+			upload.progress = valid["progress"].(float64)
+			upload.uploadURI = valid["upload_uri"].(string)
+			if !upload.complete && upload.uploadURI == "" {
+				return nil, upload, NewDeserializationError("incomplete boot resource file has no upload_uri")
+			}
+			return resources[0], upload, nil
+		}
+	}
+	return nil, upload, NewDeserializationError("boot resource has no file with sha256 %s", sha)
+}
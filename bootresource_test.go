@@ -4,13 +4,27 @@
 package gomaasapi
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
 	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
 )
 
-type bootResourceSuite struct{}
+type bootResourceSuite struct {
+	testing.LoggingCleanupSuite
+}
 
 var _ = gc.Suite(&bootResourceSuite{})
 
@@ -46,6 +60,193 @@ func (*bootResourceSuite) TestHighVersion(c *gc.C) {
 	c.Assert(bootResources, gc.HasLen, 5)
 }
 
+// uploadServer acts as the parts of the boot resources API used by
+// UploadBootResource, keeping the uploaded content.
+type uploadServer struct {
+	*SimpleTestServer
+
+	mu       sync.Mutex
+	size     int
+	sha      string
+	content  bytes.Buffer
+	puts     int
+	dropPuts map[int]bool
+	reject   string
+	created  url.Values
+}
+
+const uploadURI = "/MAAS/api/2.0/boot-resources/7/upload/3/"
+
+func (s *uploadServer) resource() string {
+	complete := s.content.Len() == s.size
+	file := map[string]interface{}{
+		"filename": "root-tgz",
+		"filetype": "root-tgz",
+		"sha256":   s.sha,
+		"size":     s.size,
+		"complete": complete,
+	}
+	if !complete {
+		file["progress"] = float64(s.content.Len()) / float64(s.size)
+		file["upload_uri"] = uploadURI
+	}
+	return fmt.Sprintf(`{
+        "id": 7,
+        "type": "Uploaded",
+        "name": "custom/centos7-gpu",
+        "architecture": "amd64/generic",
+        "resource_uri": "/MAAS/api/2.0/boot-resources/7/",
+        "sets": {"20190601": {"version": "20190601", "complete": %t, "files": {"root-tgz": %s}}}
+    }`, complete, mustJSON(file))
+}
+
+func mustJSON(value interface{}) string {
+	out, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	return string(out)
+}
+
+func (s *uploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/api/2.0/boot-resources/":
+		r.ParseForm()
+		s.created = r.PostForm
+		fmt.Fprint(w, s.resource())
+	case r.Method == "GET" && r.URL.Path == "/MAAS/api/2.0/boot-resources/7/":
+		fmt.Fprint(w, s.resource())
+	case r.Method == "PUT" && r.URL.Path == uploadURI:
+		s.puts++
+		if s.dropPuts[s.puts] {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		if s.reject != "" {
+			http.Error(w, s.reject, http.StatusBadRequest)
+			return
+		}
+		body, _ := readAndClose(r.Body)
+		s.content.Write(body)
+		fmt.Fprint(w, "{}")
+	default:
+		s.SimpleTestServer.handler(w, r)
+	}
+}
+
+func (s *bootResourceSuite) getUploadServerAndController(c *gc.C, content string) (*uploadServer, Controller) {
+	hash := sha256.Sum256([]byte(content))
+	server := &uploadServer{
+		SimpleTestServer: NewSimpleServer(),
+		size:             len(content),
+		sha:              hex.EncodeToString(hash[:]),
+	}
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Config.Handler = server
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, controller
+}
+
+const uploadContent = "0123456789"
+
+func (s *bootResourceSuite) TestUploadBootResource(c *gc.C) {
+	server, controller := s.getUploadServerAndController(c, uploadContent)
+	var progress []int64
+	resource, err := controller.UploadBootResource(UploadBootResourceArgs{
+		Name:         "custom/centos7-gpu",
+		Architecture: "amd64/generic",
+		FileType:     "tgz",
+		Content:      strings.NewReader(uploadContent),
+		ChunkSize:    3,
+		Progress:     func(uploaded, total int64) { progress = append(progress, uploaded) },
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(resource.ID(), gc.Equals, 7)
+	c.Check(server.content.String(), gc.Equals, uploadContent)
+	c.Check(server.puts, gc.Equals, 4)
+	c.Check(progress, jc.DeepEquals, []int64{3, 6, 9, 10})
+
+	form := server.created
+	c.Check(form.Get("name"), gc.Equals, "custom/centos7-gpu")
+	c.Check(form.Get("filetype"), gc.Equals, "tgz")
+	c.Check(form.Get("size"), gc.Equals, "10")
+	c.Check(form.Get("sha256"), gc.Equals, server.sha)
+}
+
+func (s *bootResourceSuite) TestUploadBootResourceResumes(c *gc.C) {
+	server, controller := s.getUploadServerAndController(c, uploadContent)
+	server.dropPuts = map[int]bool{3: true}
+	_, err := controller.UploadBootResource(UploadBootResourceArgs{
+		Name:         "custom/centos7-gpu",
+		Architecture: "amd64/generic",
+		Content:      strings.NewReader(uploadContent),
+		ChunkSize:    3,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.content.String(), gc.Equals, uploadContent)
+	// The dropped third chunk is sent again, but not the first two.
+	c.Check(server.puts, gc.Equals, 5)
+}
+
+func (s *bootResourceSuite) TestUploadBootResourceAlreadyPartial(c *gc.C) {
+	server, controller := s.getUploadServerAndController(c, uploadContent)
+	server.content.WriteString("01234")
+	_, err := controller.UploadBootResource(UploadBootResourceArgs{
+		Name:         "custom/centos7-gpu",
+		Architecture: "amd64/generic",
+		Content:      strings.NewReader(uploadContent),
+		ChunkSize:    3,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.content.String(), gc.Equals, uploadContent)
+	c.Check(server.puts, gc.Equals, 2)
+}
+
+func (s *bootResourceSuite) TestUploadBootResourceGivesUp(c *gc.C) {
+	server, controller := s.getUploadServerAndController(c, uploadContent)
+	server.dropPuts = map[int]bool{1: true, 2: true}
+	_, err := controller.UploadBootResource(UploadBootResourceArgs{
+		Name:         "custom/centos7-gpu",
+		Architecture: "amd64/generic",
+		Content:      strings.NewReader(uploadContent),
+		MaxRetries:   1,
+	})
+	c.Check(err, gc.ErrorMatches, `uploading boot resource "custom/centos7-gpu": .*EOF`)
+	c.Check(server.puts, gc.Equals, 2)
+}
+
+func (s *bootResourceSuite) TestUploadBootResourceRejected(c *gc.C) {
+	server, controller := s.getUploadServerAndController(c, uploadContent)
+	server.reject = "Saved content does not match given SHA256 value."
+	_, err := controller.UploadBootResource(UploadBootResourceArgs{
+		Name:         "custom/centos7-gpu",
+		Architecture: "amd64/generic",
+		Content:      strings.NewReader(uploadContent),
+	})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(server.puts, gc.Equals, 1)
+}
+
+func (*bootResourceSuite) TestUploadBootResourceArgsValidate(c *gc.C) {
+	args := UploadBootResourceArgs{Name: "custom/centos7-gpu", Architecture: "amd64/generic"}
+	c.Check(args.Validate(), jc.Satisfies, errors.IsNotValid)
+	args.Content = strings.NewReader(uploadContent)
+	c.Check(args.Validate(), jc.ErrorIsNil)
+	args.Name = ""
+	c.Check(args.Validate(), gc.ErrorMatches, "missing Name not valid")
+}
+
 var bootResourcesResponse = `
 [
     {
@@ -0,0 +1,451 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+// The image streams published by Canonical. Boot sources for the other
+// streams, or for a mirror, are added with CreateBootSource.
+const (
+	ImageStreamStable    = "http://images.maas.io/ephemeral-v3/stable/"
+	ImageStreamCandidate = "http://images.maas.io/ephemeral-v3/candidate/"
+)
+
+type bootSource struct {
+	controller *controller
+
+	resourceURI string
+
+	id              int
+	url             string
+	keyringFilename string
+}
+
+// ID implements BootSource.
+func (s *bootSource) ID() int {
+	return s.id
+}
+
+// URL implements BootSource.
+func (s *bootSource) URL() string {
+	return s.url
+}
+
+// KeyringFilename implements BootSource.
+func (s *bootSource) KeyringFilename() string {
+	return s.keyringFilename
+}
+
+// Delete implements BootSource.
+func (s *bootSource) Delete() error {
+	if err := s.controller.delete(s.resourceURI); err != nil {
+		return errors.Trace(bootSourceError(err))
+	}
+	return nil
+}
+
+// Selections implements BootSource.
+func (s *bootSource) Selections() ([]BootSourceSelection, error) {
+	source, err := s.controller.get(s.resourceURI + "selections")
+	if err != nil {
+		return nil, errors.Trace(bootSourceError(err))
+	}
+	selections, err := readBootSourceSelections(s.controller.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []BootSourceSelection
+	for _, selection := range selections {
+		selection.controller = s.controller
+		result = append(result, selection)
+	}
+	return result, nil
+}
+
+// CreateSelection implements BootSource.
+func (s *bootSource) CreateSelection(args BootSourceSelectionArgs) (BootSourceSelection, error) {
+	if args.OS == "" {
+		return nil, errors.NotValidf("missing OS")
+	}
+	if args.Release == "" {
+		return nil, errors.NotValidf("missing Release")
+	}
+	if err := args.requireVersion(s.controller); err != nil {
+		return nil, errors.Trace(err)
+	}
+	source, err := s.controller.post(s.resourceURI+"selections", "", args.params().Values)
+	if err != nil {
+		return nil, errors.Trace(bootSourceError(err))
+	}
+	selection, err := readBootSourceSelection(s.controller.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	selection.controller = s.controller
+	return selection, nil
+}
+
+type bootSourceSelection struct {
+	controller *controller
+
+	resourceURI string
+
+	id        int
+	os        string
+	release   string
+	arches    []string
+	subarches []string
+	labels    []string
+}
+
+// ID implements BootSourceSelection.
+func (s *bootSourceSelection) ID() int {
+	return s.id
+}
+
+// OS implements BootSourceSelection.
+func (s *bootSourceSelection) OS() string {
+	return s.os
+}
+
+// Release implements BootSourceSelection.
+func (s *bootSourceSelection) Release() string {
+	return s.release
+}
+
+// Arches implements BootSourceSelection.
+func (s *bootSourceSelection) Arches() []string {
+	return s.arches
+}
+
+// Subarches implements BootSourceSelection.
+func (s *bootSourceSelection) Subarches() []string {
+	return s.subarches
+}
+
+// Labels implements BootSourceSelection.
+func (s *bootSourceSelection) Labels() []string {
+	return s.labels
+}
+
+// Update implements BootSourceSelection.
+func (s *bootSourceSelection) Update(args BootSourceSelectionArgs) error {
+	params := args.params()
+	if len(params.Values) == 0 {
+		return nil
+	}
+	if err := args.requireVersion(s.controller); err != nil {
+		return errors.Trace(err)
+	}
+	source, err := s.controller.put(s.resourceURI, params.Values)
+	if err != nil {
+		return errors.Trace(bootSourceError(err))
+	}
+	selection, err := readBootSourceSelection(s.controller.schemaVersion(), source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	selection.controller = s.controller
+	*s = *selection
+	return nil
+}
+
+// Delete implements BootSourceSelection.
+func (s *bootSourceSelection) Delete() error {
+	if err := s.controller.delete(s.resourceURI); err != nil {
+		return errors.Trace(bootSourceError(err))
+	}
+	return nil
+}
+
+// BootSourceSelectionArgs is an argument struct for creating or updating
+// the selection of images from a boot source. OS and Release are required
+// to create a selection; when updating, only the fields that are set are
+// changed.
+type BootSourceSelectionArgs struct {
+	// OS and Release select the images, such as "ubuntu" and "jammy".
+	OS      string
+	Release string
+	// Arches are the architectures to import, such as "amd64" and
+	// "arm64". Subarches and Labels narrow the images further, and
+	// "*" selects all of them.
+	Arches    []string
+	Subarches []string
+	Labels    []string
+}
+
+func (a BootSourceSelectionArgs) params() *URLParams {
+	params := NewURLParams()
+	params.MaybeAdd("os", a.OS)
+	params.MaybeAdd("release", a.Release)
+	params.MaybeAddMany("arches", a.Arches)
+	params.MaybeAddMany("subarches", a.Subarches)
+	params.MaybeAddMany("labels", a.Labels)
+	return params
+}
+
+// requireVersion gives an error satisfying errors.IsNotSupported if the
+// args select images per architecture and the server is older than 3.5.
+func (a BootSourceSelectionArgs) requireVersion(c *controller) error {
+	if len(a.Arches) == 0 && len(a.Subarches) == 0 && len(a.Labels) == 0 {
+		return nil
+	}
+	return c.requireVersion("per-architecture boot source selections", 3, 5)
+}
+
+// CreateBootSourceArgs is an argument struct for Controller.CreateBootSource.
+type CreateBootSourceArgs struct {
+	// URL is the address of a simplestreams image stream, such as
+	// ImageStreamCandidate or a local mirror.
+	URL string
+	// KeyringFilename is the path, on the region controller, of the
+	// keyring for the signatures of the stream.
+	KeyringFilename string
+}
+
+// Validate ensures that there is a URL.
+func (a *CreateBootSourceArgs) Validate() error {
+	if a.URL == "" {
+		return errors.NotValidf("missing URL")
+	}
+	return nil
+}
+
+// BootSources implements Controller.
+func (c *controller) BootSources() ([]BootSource, error) {
+	source, err := c.get(BootSourcesPath)
+	if err != nil {
+		return nil, errors.Trace(bootSourceError(err))
+	}
+	sources, err := readBootSources(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []BootSource
+	for _, s := range sources {
+		s.controller = c
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// CreateBootSource implements Controller.
+func (c *controller) CreateBootSource(args CreateBootSourceArgs) (BootSource, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := c.requireVersion("custom image streams", 3, 5); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("url", args.URL)
+	params.MaybeAdd("keyring_filename", args.KeyringFilename)
+	source, err := c.post(BootSourcesPath, "", params.Values)
+	if err != nil {
+		return nil, errors.Trace(bootSourceError(err))
+	}
+	s, err := readBootSource(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	s.controller = c
+	return s, nil
+}
+
+// bootSourceError translates the errors of the boot sources API, which
+// only admins can use.
+func bootSourceError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			if strings.HasPrefix(svrErr.BodyMessage, "Unknown API endpoint") {
+				return errors.NewNotSupported(err, "boot sources")
+			}
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+func readBootSource(controllerVersion version.Number, source interface{}) (*bootSource, error) {
+	readFunc, err := getBootSourceDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot source base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
+func readBootSources(controllerVersion version.Number, source interface{}) ([]*bootSource, error) {
+	readFunc, err := getBootSourceDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot source base schema check failed")
+	}
+	sourceList := coerced.([]interface{})
+	result := make([]*bootSource, 0, len(sourceList))
+	for i, value := range sourceList {
+		s, err := readFunc(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "boot source %d", i)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+func getBootSourceDeserializationFunc(controllerVersion version.Number) (bootSourceDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range bootSourceDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no boot source read func for version %s", controllerVersion)
+	}
+	return bootSourceDeserializationFuncs[deserialisationVersion], nil
+}
+
+type bootSourceDeserializationFunc func(map[string]interface{}) (*bootSource, error)
+
+var bootSourceDeserializationFuncs = map[version.Number]bootSourceDeserializationFunc{
+	twoDotOh: bootSource_2_0,
+}
+
+func bootSource_2_0(source map[string]interface{}) (*bootSource, error) {
+	fields := schema.Fields{
+		"resource_uri":     schema.String(),
+		"id":               schema.ForceInt(),
+		"url":              schema.String(),
+		"keyring_filename": schema.OneOf(schema.Nil(""), schema.String()),
+	}
+	defaults := schema.Defaults{
+		"keyring_filename": "",
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot source 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	keyringFilename, _ := valid["keyring_filename"].(string)
+	return &bootSource{
+		resourceURI:     valid["resource_uri"].(string),
+		id:              valid["id"].(int),
+		url:             valid["url"].(string),
+		keyringFilename: keyringFilename,
+	}, nil
+}
+
+func readBootSourceSelection(controllerVersion version.Number, source interface{}) (*bootSourceSelection, error) {
+	readFunc, err := getBootSourceSelectionDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot source selection base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
+func readBootSourceSelections(controllerVersion version.Number, source interface{}) ([]*bootSourceSelection, error) {
+	readFunc, err := getBootSourceSelectionDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot source selection base schema check failed")
+	}
+	sourceList := coerced.([]interface{})
+	result := make([]*bootSourceSelection, 0, len(sourceList))
+	for i, value := range sourceList {
+		s, err := readFunc(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "boot source selection %d", i)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+func getBootSourceSelectionDeserializationFunc(controllerVersion version.Number) (bootSourceSelectionDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range bootSourceSelectionDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no boot source selection read func for version %s", controllerVersion)
+	}
+	return bootSourceSelectionDeserializationFuncs[deserialisationVersion], nil
+}
+
+type bootSourceSelectionDeserializationFunc func(map[string]interface{}) (*bootSourceSelection, error)
+
+var bootSourceSelectionDeserializationFuncs = map[version.Number]bootSourceSelectionDeserializationFunc{
+	twoDotOh: bootSourceSelection_2_0,
+}
+
+func bootSourceSelection_2_0(source map[string]interface{}) (*bootSourceSelection, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+		"id":           schema.ForceInt(),
+		"os":           schema.String(),
+		"release":      schema.String(),
+		"arches":       schema.List(schema.String()),
+		"subarches":    schema.List(schema.String()),
+		"labels":       schema.List(schema.String()),
+	}
+	defaults := schema.Defaults{
+		"arches":    []interface{}{},
+		"subarches": []interface{}{},
+		"labels":    []interface{}{},
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot source selection 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	return &bootSourceSelection{
+		resourceURI: valid["resource_uri"].(string),
+		id:          valid["id"].(int),
+		os:          valid["os"].(string),
+		release:     valid["release"].(string),
+		arches:      convertToStringSlice(valid["arches"]),
+		subarches:   convertToStringSlice(valid["subarches"]),
+		labels:      convertToStringSlice(valid["labels"]),
+	}, nil
+}
@@ -0,0 +1,234 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type bootSourceSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&bootSourceSuite{})
+
+func (*bootSourceSuite) TestReadBootSourcesBadSchema(c *gc.C) {
+	_, err := readBootSources(twoDotOh, "wat?")
+	c.Assert(err.Error(), gc.Equals, `boot source base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*bootSourceSuite) TestReadBootSources(c *gc.C) {
+	sources, err := readBootSources(twoDotOh, parseJSON(c, bootSourcesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(sources, gc.HasLen, 2)
+	c.Check(sources[0].ID(), gc.Equals, 1)
+	c.Check(sources[0].URL(), gc.Equals, ImageStreamStable)
+	c.Check(sources[0].KeyringFilename(), gc.Equals, "/usr/share/keyrings/ubuntu-cloudimage-keyring.gpg")
+	c.Check(sources[1].KeyringFilename(), gc.Equals, "")
+}
+
+func (*bootSourceSuite) TestReadBootSourceSelections(c *gc.C) {
+	selections, err := readBootSourceSelections(twoDotOh, parseJSON(c, bootSourceSelectionsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(selections, gc.HasLen, 1)
+	selection := selections[0]
+	c.Check(selection.ID(), gc.Equals, 4)
+	c.Check(selection.OS(), gc.Equals, "ubuntu")
+	c.Check(selection.Release(), gc.Equals, "jammy")
+	c.Check(selection.Arches(), jc.DeepEquals, []string{"amd64", "arm64"})
+	c.Check(selection.Subarches(), jc.DeepEquals, []string{"*"})
+	c.Check(selection.Labels(), jc.DeepEquals, []string{"*"})
+}
+
+func (*bootSourceSuite) TestLowVersion(c *gc.C) {
+	_, err := readBootSources(version.MustParse("1.9.0"), parseJSON(c, bootSourcesResponse))
+	c.Assert(err.Error(), gc.Equals, `no boot source read func for version 1.9.0`)
+	_, err = readBootSourceSelections(version.MustParse("1.9.0"), parseJSON(c, bootSourceSelectionsResponse))
+	c.Assert(err.Error(), gc.Equals, `no boot source selection read func for version 1.9.0`)
+}
+
+func (s *bootSourceSuite) TestBootSources(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/boot-sources/", http.StatusOK, bootSourcesResponse)
+	sources, err := controller.BootSources()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(sources, gc.HasLen, 2)
+
+	server.AddGetResponse("/MAAS/api/2.0/boot-sources/1/selections/", http.StatusOK, bootSourceSelectionsResponse)
+	selections, err := sources[0].Selections()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(selections, gc.HasLen, 1)
+
+	server.AddDeleteResponse("/MAAS/api/2.0/boot-sources/2/", http.StatusNoContent, "")
+	err = sources[1].Delete()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *bootSourceSuite) TestBootSourcesNotSupported(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/boot-sources/", http.StatusNotFound, "Unknown API endpoint: /MAAS/api/2.0/boot-sources/.")
+	_, err := controller.BootSources()
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *bootSourceSuite) TestBootSourcesPermission(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/boot-sources/", http.StatusForbidden, "admin only")
+	_, err := controller.BootSources()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *bootSourceSuite) TestCreateBootSource(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/boot-sources/?op=", http.StatusOK, bootSourceCandidateResponse)
+	source, err := controller.CreateBootSource(CreateBootSourceArgs{URL: ImageStreamCandidate})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(source.ID(), gc.Equals, 2)
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 1)
+	c.Check(form.Get("url"), gc.Equals, ImageStreamCandidate)
+}
+
+func (s *bootSourceSuite) TestCreateBootSourceOldServer(c *gc.C) {
+	server, maas := createTestServerController(c, s)
+	maas.(*controller).serverVersion = version.MustParse("3.4.2")
+	_, err := maas.CreateBootSource(CreateBootSourceArgs{URL: ImageStreamCandidate})
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+	c.Check(err, gc.ErrorMatches, "custom image streams needs MAAS 3.5 or later, the server is 3.4.2")
+	c.Check(server.LastRequest().Method, gc.Equals, "GET")
+}
+
+func (s *bootSourceSuite) TestCreateBootSourceValidates(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	_, err := controller.CreateBootSource(CreateBootSourceArgs{})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, "missing URL not valid")
+}
+
+func (s *bootSourceSuite) TestCreateSelection(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/boot-sources/?op=", http.StatusOK, bootSourceCandidateResponse)
+	source, err := controller.CreateBootSource(CreateBootSourceArgs{URL: ImageStreamCandidate})
+	c.Assert(err, jc.ErrorIsNil)
+
+	server.AddPostResponse("/MAAS/api/2.0/boot-sources/2/selections/?op=", http.StatusOK, bootSourceSelectionResponse)
+	selection, err := source.CreateSelection(BootSourceSelectionArgs{
+		OS:      "ubuntu",
+		Release: "jammy",
+		Arches:  []string{"amd64", "arm64"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(selection.ID(), gc.Equals, 4)
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 3)
+	c.Check(form.Get("os"), gc.Equals, "ubuntu")
+	c.Check(form.Get("release"), gc.Equals, "jammy")
+	c.Check(form["arches"], jc.DeepEquals, []string{"amd64", "arm64"})
+
+	_, err = source.CreateSelection(BootSourceSelectionArgs{OS: "ubuntu"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, "missing Release not valid")
+}
+
+func (s *bootSourceSuite) TestSelectionsOldServer(c *gc.C) {
+	server, maas := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/boot-sources/", http.StatusOK, bootSourcesResponse)
+	sources, err := maas.BootSources()
+	c.Assert(err, jc.ErrorIsNil)
+	server.AddGetResponse("/MAAS/api/2.0/boot-sources/1/selections/", http.StatusOK, bootSourceSelectionsResponse)
+	selections, err := sources[0].Selections()
+	c.Assert(err, jc.ErrorIsNil)
+	maas.(*controller).serverVersion = version.MustParse("3.4.2")
+
+	_, err = sources[0].CreateSelection(BootSourceSelectionArgs{
+		OS:      "ubuntu",
+		Release: "jammy",
+		Labels:  []string{"candidate"},
+	})
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+	err = selections[0].Update(BootSourceSelectionArgs{Arches: []string{"arm64"}})
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+	c.Check(server.LastRequest().Method, gc.Equals, "GET")
+
+	server.AddPostResponse("/MAAS/api/2.0/boot-sources/1/selections/?op=", http.StatusOK, bootSourceSelectionResponse)
+	_, err = sources[0].CreateSelection(BootSourceSelectionArgs{OS: "ubuntu", Release: "jammy"})
+	c.Check(err, jc.ErrorIsNil)
+}
+
+func (s *bootSourceSuite) TestUpdateSelection(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/boot-sources/", http.StatusOK, bootSourcesResponse)
+	sources, err := controller.BootSources()
+	c.Assert(err, jc.ErrorIsNil)
+	server.AddGetResponse("/MAAS/api/2.0/boot-sources/1/selections/", http.StatusOK, bootSourceSelectionsResponse)
+	selections, err := sources[0].Selections()
+	c.Assert(err, jc.ErrorIsNil)
+	selection := selections[0]
+
+	server.AddPutResponse("/MAAS/api/2.0/boot-sources/1/selections/4/", http.StatusOK, bootSourceSelectionUpdatedResponse)
+	err = selection.Update(BootSourceSelectionArgs{Arches: []string{"amd64", "arm64", "s390x"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(selection.Arches(), jc.DeepEquals, []string{"amd64", "arm64", "s390x"})
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 1)
+	c.Check(form["arches"], jc.DeepEquals, []string{"amd64", "arm64", "s390x"})
+
+	server.AddDeleteResponse("/MAAS/api/2.0/boot-sources/1/selections/4/", http.StatusNotFound, "gone")
+	err = selection.Delete()
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+const (
+	bootSourceCandidateResponse = `
+{
+    "resource_uri": "/MAAS/api/2.0/boot-sources/2/",
+    "id": 2,
+    "url": "http://images.maas.io/ephemeral-v3/candidate/",
+    "keyring_filename": "",
+    "keyring_data": ""
+}
+`
+	bootSourcesResponse = `[
+{
+    "resource_uri": "/MAAS/api/2.0/boot-sources/1/",
+    "id": 1,
+    "url": "http://images.maas.io/ephemeral-v3/stable/",
+    "keyring_filename": "/usr/share/keyrings/ubuntu-cloudimage-keyring.gpg",
+    "keyring_data": ""
+},` + bootSourceCandidateResponse + `]`
+	bootSourceSelectionResponse = `
+{
+    "resource_uri": "/MAAS/api/2.0/boot-sources/1/selections/4/",
+    "id": 4,
+    "boot_source_id": 1,
+    "os": "ubuntu",
+    "release": "jammy",
+    "arches": ["amd64", "arm64"],
+    "subarches": ["*"],
+    "labels": ["*"]
+}
+`
+	bootSourceSelectionsResponse       = `[` + bootSourceSelectionResponse + `]`
+	bootSourceSelectionUpdatedResponse = `
+{
+    "resource_uri": "/MAAS/api/2.0/boot-sources/1/selections/4/",
+    "id": 4,
+    "boot_source_id": 1,
+    "os": "ubuntu",
+    "release": "jammy",
+    "arches": ["amd64", "arm64", "s390x"],
+    "subarches": ["*"],
+    "labels": ["*"]
+}
+`
+)
@@ -5,6 +5,8 @@ package gomaasapi
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,11 +14,12 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/juju/errors"
+	"github.com/juju/utils/clock"
 )
 
 const (
@@ -33,6 +36,102 @@ const (
 type Client struct {
 	APIURL *url.URL
 	Signer OAuthSigner
+	// Clock is used to wait before retrying a request. If it is nil, the
+	// wall clock is used.
+	Clock clock.Clock
+	// AdjustClockSkew, if true, makes the client retry a request once when
+	// the server rejects its OAuth timestamp, after shifting the signer's
+	// timestamps by the skew measured from the Date header of the
+	// rejection. Only signers made by NewPlainTestOAuthSigner are adjusted.
+	AdjustClockSkew bool
+	// RetryUnauthorized, if true, makes the client retry a request once
+	// when the server rejects it with a 401 response for any other reason
+	// than its timestamp. The request is signed again with a fresh nonce
+	// and timestamp, which gets past nonce collisions and proxies that
+	// replay a cached rejection.
+	RetryUnauthorized bool
+	// RateLimitObserver, if set, is told the rate limit of every
+	// response, successful or not, that carries rate limit headers. See
+	// ParseRateLimit.
+	RateLimitObserver RateLimitObserver
+	// TLSServerName, if set, is the name that the certificate of the
+	// server is verified against instead of the host of APIURL. It is for
+	// servers reached through an address that their certificate does not
+	// name, such as the virtual IP of an HA region or a name that only
+	// resolves in a split DNS view.
+	TLSServerName string
+	// TLSConfig, if set, is the TLS configuration of the connections to
+	// the server, such as the CAs to trust, in place of that of the
+	// transport. The ServerName in it is overridden by TLSServerName. It
+	// is copied into the transport made for it, so set a new one rather
+	// than changing it once requests have been made.
+	TLSConfig *tls.Config
+	// HTTPClient, if set, sends the requests, so that its transport can
+	// set a proxy, the CAs to trust, timeouts and the reuse of
+	// connections. Redirects that change the method are still refused
+	// unless it has its own CheckRedirect. If it is nil, a client with the
+	// default transport is used, and connections are not reused.
+	HTTPClient *http.Client
+	// DisableRedaction, if true, keeps the values of sensitive fields, such
+	// as the BMC passwords in power parameters, in the messages of
+	// ServerErrors. Otherwise they are replaced, see RedactText.
+	DisableRedaction bool
+	// MaxResponseSize, if positive, is the most bytes of a response body
+	// that are read. A larger response fails with a TooLargeError without
+	// being read further, and is not retried. It does not apply to the
+	// readers returned by GetReader, which are not held in memory.
+	MaxResponseSize int64
+	// Journal, if set, records the POST, PUT and DELETE requests that fail
+	// because the server cannot be reached, which then return an error
+	// satisfying IsJournaledError. See Journal.Replay.
+	Journal *Journal
+	// RetryPolicy, if set, controls which failed requests are sent again
+	// and how long to wait before doing so. Otherwise only 503 responses
+	// with a Retry-After header are retried, up to NumberOfRetries times.
+	RetryPolicy *RetryPolicy
+	// Context, if set, is used for every request, so that requests are
+	// abandoned when it is done. Use WithContext to set it on a copy of a
+	// client that is in use.
+	Context context.Context
+
+	// httpClients is shared by the copies of the client.
+	httpClients *httpClientCache
+}
+
+// WithContext returns a copy of the client that makes its requests with
+// the context.
+func (client *Client) WithContext(ctx context.Context) *Client {
+	result := *client
+	result.Context = ctx
+	return &result
+}
+
+func (client Client) context() context.Context {
+	if client.Context == nil {
+		return context.Background()
+	}
+	return client.Context
+}
+
+// IsContextError returns true if err comes from a request abandoned
+// because the context of the client was cancelled or its deadline passed.
+func IsContextError(err error) bool {
+	for err != nil {
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return true
+		}
+		switch wrapper := err.(type) {
+		case *url.Error:
+			err = wrapper.Err
+		case interface{ Underlying() error }:
+			err = wrapper.Underlying()
+		case interface{ Cause() error }:
+			err = wrapper.Cause()
+		default:
+			return false
+		}
+	}
+	return false
 }
 
 // ServerError is an http error (or at least, a non-2xx result) received from
@@ -68,62 +167,335 @@ func readAndClose(stream io.ReadCloser) ([]byte, error) {
 // Client-side errors will return an empty response and a non-nil error.  For
 // server-side errors however (i.e. responses with a non 2XX status code), the
 // returned error will be ServerError and the returned body will reflect the
-// server's response.  If the server returns a 503 response with a 'Retry-after'
-// header, the request will be transparenty retried.
+// server's response.  Failed requests are retried as the RetryPolicy of the
+// client says, which by default retries 503 responses with a 'Retry-after'
+// header.
 func (client Client) dispatchRequest(request *http.Request) ([]byte, error) {
-	// First, store the request's body into a byte[] to be able to restore it
-	// after each request.
-	bodyContent, err := readAndClose(request.Body)
-	if err != nil {
-		return nil, err
+	var body []byte
+	err := client.retryRequest(request, func(request *http.Request) error {
+		var err error
+		body, err = client.dispatchSingleRequest(request)
+		return err
+	})
+	return body, err
+}
+
+// retryRequest calls send with the request until it succeeds or the
+// RetryPolicy of the client gives up, resending it once with adjusted
+// timestamps or a new signature as AdjustClockSkew and RetryUnauthorized
+// say.
+func (client Client) retryRequest(request *http.Request, send func(*http.Request) error) error {
+	if request.GetBody == nil {
+		// Store the request's body into a byte[] to be able to restore it
+		// after each request.
+		bodyContent, err := readAndClose(request.Body)
+		if err != nil {
+			return err
+		}
+		request.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(bodyContent)), nil
+		}
+	}
+	policy := client.RetryPolicy
+	if policy == nil {
+		policy = defaultRetryPolicy
 	}
-	for retry := 0; retry < NumberOfRetries; retry++ {
+	adjusted, resigned := false, false
+	for attempt := 1; ; attempt++ {
 		// Restore body before issuing request.
-		newBody := ioutil.NopCloser(bytes.NewReader(bodyContent))
-		request.Body = newBody
-		body, err := client.dispatchSingleRequest(request)
-		// If this is a 503 response with a non-void "Retry-After" header: wait
-		// as instructed and retry the request.
+		newBody, err := request.GetBody()
 		if err != nil {
-			serverError, ok := errors.Cause(err).(ServerError)
-			if ok && serverError.StatusCode == http.StatusServiceUnavailable {
-				retry_time_int, errConv := strconv.Atoi(serverError.Header.Get(RetryAfterHeaderName))
-				if errConv == nil {
-					select {
-					case <-time.After(time.Duration(retry_time_int) * time.Second):
-					}
+			return err
+		}
+		request.Body = newBody
+		err = send(request)
+		if err == nil || attempt >= policy.maxAttempts() || client.context().Err() != nil {
+			return err
+		}
+		if client.AdjustClockSkew && !adjusted {
+			if serverError, ok := errors.Cause(err).(ServerError); ok && isTimestampRejection(serverError) {
+				adjusted = client.adjustClockSkew(serverError.Header)
+				if adjusted {
 					continue
 				}
 			}
 		}
-		return body, err
+		if client.RetryUnauthorized && !resigned {
+			if serverError, ok := errors.Cause(err).(ServerError); ok &&
+				serverError.StatusCode == http.StatusUnauthorized && !isTimestampRejection(serverError) {
+				httpLogger.Debugf("request rejected as unauthorized, retrying with a new signature")
+				resigned = true
+				continue
+			}
+		}
+		// Wait as the retry policy says and retry the request.
+		delay, ok := policy.retryDelay(request, err, attempt, client.clock().Now())
+		if !ok {
+			return err
+		}
+		httpLogger.Debugf("request failed on attempt %d, retrying in %v: %v", attempt, delay, err)
+		select {
+		case <-client.clock().After(delay):
+		case <-client.context().Done():
+			return errors.Trace(client.context().Err())
+		}
 	}
-	// Restore body before issuing request.
-	newBody := ioutil.NopCloser(bytes.NewReader(bodyContent))
-	request.Body = newBody
-	return client.dispatchSingleRequest(request)
+}
+
+// isTimestampRejection returns true if the server refused the request
+// because the OAuth timestamp was too far from its own time.
+func isTimestampRejection(err ServerError) bool {
+	return err.StatusCode == http.StatusUnauthorized && strings.Contains(err.BodyMessage, "timestamp")
+}
+
+// adjustClockSkew shifts the signer's timestamps to match the server time
+// in the header, returning false if that isn't possible.
+func (client Client) adjustClockSkew(header http.Header) bool {
+	signer, ok := client.Signer.(timeOffsetter)
+	if !ok {
+		return false
+	}
+	serverTime, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return false
+	}
+	clk := client.clock()
+	skew := serverTime.Sub(clk.Now())
+	httpLogger.Warningf("server clock is %v ahead of the local clock, adjusting OAuth timestamps", skew)
+	signer.setTimeOffset(clk, skew)
+	return true
+}
+
+// ServerTime returns the time in the Date header of the server's response
+// to an anonymous request for the API version. The header has a resolution
+// of one second.
+func (client Client) ServerTime() (time.Time, error) {
+	request, err := http.NewRequest("GET", client.GetURL(&url.URL{Path: EnsureTrailingSlash(VersionPath)}).String(), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	request.Close = client.HTTPClient == nil
+	response, err := client.httpClient().Do(request.WithContext(client.context()))
+	if err != nil {
+		return time.Time{}, err
+	}
+	readAndClose(response.Body)
+	serverTime, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, errors.Annotate(err, "reading Date header")
+	}
+	return serverTime, nil
+}
+
+func (client Client) clock() clock.Clock {
+	if client.Clock == nil {
+		return clock.WallClock
+	}
+	return client.Clock
+}
+
+// httpClientCache holds the HTTP client that a Client, and its copies, make
+// their requests with, so that a transport made for the TLS settings is
+// made once and its connections are reused.
+type httpClientCache struct {
+	mu     sync.Mutex
+	key    httpClientKey
+	client *http.Client
+	// owned is true when the transport of the client was made for it,
+	// and so its idle connections are closed when it is replaced.
+	owned bool
+}
+
+// httpClientKey is the settings of a Client that the HTTP client is made
+// from.
+type httpClientKey struct {
+	httpClient    *http.Client
+	tlsConfig     *tls.Config
+	tlsServerName string
+}
+
+func (c *httpClientCache) get(client Client) *http.Client {
+	key := httpClientKey{
+		httpClient:    client.HTTPClient,
+		tlsConfig:     client.TLSConfig,
+		tlsServerName: client.TLSServerName,
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != nil && c.key == key {
+		return c.client
+	}
+	if c.owned {
+		c.client.Transport.(*http.Transport).CloseIdleConnections()
+	}
+	c.client, c.owned = client.newHTTPClient()
+	c.key = key
+	return c.client
+}
+
+// httpClient returns the HTTP client that makes the requests. Clients made
+// by NewAnonymousClient and NewAuthenticatedClient make it once for their
+// settings; others make it for each request.
+func (client Client) httpClient() *http.Client {
+	if client.httpClients != nil {
+		return client.httpClients.get(client)
+	}
+	result, _ := client.newHTTPClient()
+	return result
+}
+
+// newHTTPClient makes the HTTP client for the settings of the client. It
+// returns true if the transport was made for it, rather than being that of
+// HTTPClient or the default transport.
+func (client Client) newHTTPClient() (*http.Client, bool) {
+	result := &http.Client{CheckRedirect: checkRedirect}
+	if client.HTTPClient != nil {
+		copied := *client.HTTPClient
+		if copied.CheckRedirect == nil {
+			copied.CheckRedirect = checkRedirect
+		}
+		result = &copied
+	}
+	if client.TLSServerName != "" || client.TLSConfig != nil {
+		transport, ok := result.Transport.(*http.Transport)
+		if result.Transport == nil {
+			transport, ok = http.DefaultTransport.(*http.Transport)
+		}
+		if !ok {
+			httpLogger.Warningf("cannot set the TLS configuration of a %T", result.Transport)
+			return result, false
+		}
+		transport = transport.Clone()
+		if client.TLSConfig != nil {
+			transport.TLSClientConfig = client.TLSConfig.Clone()
+		} else if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		if client.TLSServerName != "" {
+			transport.TLSClientConfig.ServerName = client.TLSServerName
+		}
+		result.Transport = transport
+		return result, true
+	}
+	return result, false
+}
+
+// checkRedirect refuses a redirect that changes the method of the request,
+// as a 301, 302 or 303 response to a POST does, since the parameters would
+// be lost. Redirects with 307 and 308 keep the method and body.
+func checkRedirect(request *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if original := via[0]; request.Method != original.Method {
+		return errors.Errorf("%s %s redirected to %s %s", original.Method, original.URL, request.Method, request.URL)
+	}
+	return nil
 }
 
 func (client Client) dispatchSingleRequest(request *http.Request) ([]byte, error) {
+	response, err := client.sendRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if client.MaxResponseSize > 0 && response.ContentLength > client.MaxResponseSize {
+		response.Body.Close()
+		return nil, errors.Trace(NewTooLargeError("response", response.ContentLength, client.MaxResponseSize))
+	}
+	body, err := readLimited(response.Body, client.MaxResponseSize)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return body, errors.Trace(client.serverError(response, body))
+	}
+	return body, nil
+}
+
+// sendRequest signs and sends the request, and passes the rate limit of
+// the response to the RateLimitObserver.
+func (client Client) sendRequest(request *http.Request) (*http.Response, error) {
+	if client.Context != nil {
+		request = request.WithContext(client.Context)
+	}
 	client.Signer.OAuthSign(request)
-	httpClient := http.Client{}
+	httpClient := client.httpClient()
 	// See https://code.google.com/p/go/issues/detail?id=4677
 	// We need to force the connection to close each time so that we don't
-	// hit the above Go bug.
-	request.Close = true
+	// hit the above Go bug. A client given its own HTTPClient keeps its
+	// connections alive as the transport of that allows.
+	request.Close = client.HTTPClient == nil
 	response, err := httpClient.Do(request)
 	if err != nil {
 		return nil, err
 	}
-	body, err := readAndClose(response.Body)
+	if client.RateLimitObserver != nil {
+		if limit, ok := ParseRateLimit(response.Header, client.clock().Now()); ok {
+			client.RateLimitObserver.ObserveRateLimit(limit)
+		}
+	}
+	return response, nil
+}
+
+// serverError returns the ServerError for a response with an error status
+// and the body read from it, which is redacted unless DisableRedaction is
+// set.
+func (client Client) serverError(response *http.Response, body []byte) error {
+	message := string(body)
+	if !client.DisableRedaction {
+		message = RedactText(message)
+	}
+	err := errors.Errorf("ServerError: %v (%s)", response.Status, message)
+	return ServerError{error: err, StatusCode: response.StatusCode, Header: response.Header, BodyMessage: message}
+}
+
+// GetReader performs an HTTP "GET" of a resource like Get, but returns the
+// body of the response to be read as it arrives rather than all at once.
+// If offset is positive, only the content from that offset on is asked for
+// with a range request. The content before the offset is skipped if the
+// server sends it anyway, and an offset at or past the end gives an empty
+// reader. Failed requests are retried like those of Get. The caller must
+// close the reader.
+func (client Client) GetReader(uri *url.URL, offset int64) (io.ReadCloser, error) {
+	request, err := http.NewRequest("GET", client.GetURL(uri).String(), nil)
 	if err != nil {
 		return nil, err
 	}
-	if response.StatusCode < 200 || response.StatusCode > 299 {
-		err := errors.Errorf("ServerError: %v (%s)", response.Status, body)
-		return body, errors.Trace(ServerError{error: err, StatusCode: response.StatusCode, Header: response.Header, BodyMessage: string(body)})
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
-	return body, nil
+	var response *http.Response
+	err = client.retryRequest(request, func(request *http.Request) error {
+		var err error
+		response, err = client.sendRequest(request)
+		if err != nil {
+			return err
+		}
+		if response.StatusCode == http.StatusRequestedRangeNotSatisfiable ||
+			response.StatusCode >= 200 && response.StatusCode <= 299 {
+			return nil
+		}
+		body, err := readLimited(response.Body, client.MaxResponseSize)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		return errors.Trace(client.serverError(response, body))
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case response.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		readAndClose(response.Body)
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	case offset > 0 && response.StatusCode != http.StatusPartialContent:
+		// The server ignored the range.
+		if _, err := io.CopyN(ioutil.Discard, response.Body, offset); err != nil && err != io.EOF {
+			response.Body.Close()
+			return nil, err
+		}
+	}
+	return response.Body, nil
 }
 
 // GetURL returns the URL to a given resource on the API, based on its URI.
@@ -222,7 +594,11 @@ func (client Client) nonIdempotentRequest(method string, uri *url.URL, parameter
 		return nil, err
 	}
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	return client.dispatchRequest(request)
+	result, err := client.dispatchRequest(request)
+	if err != nil {
+		return nil, client.journal(method, uri, parameters, err)
+	}
+	return result, nil
 }
 
 // Post performs an HTTP "POST" to the API.  This may be either an API method
@@ -237,11 +613,92 @@ func (client Client) Post(uri *url.URL, operation string, parameters url.Values,
 	return client.nonIdempotentRequest("POST", uri, parameters)
 }
 
+// PostFile performs a multipart HTTP "POST" to the API like Post, with a
+// single file whose content is streamed from the reader rather than held in
+// memory. Exactly length bytes of content are sent. A request that has to be
+// sent again, such as after a 503 response, reads the content again from
+// where the reader started, which fails unless the reader is an io.Seeker.
+func (client Client) PostFile(uri *url.URL, operation string, parameters url.Values, fileName string, content io.Reader, length int64) ([]byte, error) {
+	queryParams := url.Values{"op": {operation}}
+	uri.RawQuery = queryParams.Encode()
+	buf := new(bytes.Buffer)
+	writer := multipart.NewWriter(buf)
+	if err := writeMultiPartParams(writer, parameters); err != nil {
+		return nil, err
+	}
+	if _, err := writer.CreateFormFile(fileName, fileName); err != nil {
+		return nil, err
+	}
+	// The content goes between the headers of the file part and the
+	// closing boundary.
+	head := append([]byte(nil), buf.Bytes()...)
+	buf.Reset()
+	writer.Close()
+	tail := buf.Bytes()
+
+	getBody, err := streamingBody(head, content, length, tail)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest("POST", client.GetURL(uri).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.GetBody = getBody
+	request.ContentLength = int64(len(head)) + length + int64(len(tail))
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return client.dispatchRequest(request)
+}
+
+// streamingBody returns a function for http.Request.GetBody that gives the
+// content between head and tail. The content is only read again, from where
+// the reader started, if the reader is an io.Seeker.
+func streamingBody(head []byte, content io.Reader, length int64, tail []byte) (func() (io.ReadCloser, error), error) {
+	seeker, seekable := content.(io.Seeker)
+	var start int64
+	if seekable {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		start = offset
+	}
+	used := false
+	return func() (io.ReadCloser, error) {
+		if used {
+			if !seekable {
+				return nil, errors.New("cannot send content again, the reader is not seekable")
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		used = true
+		return ioutil.NopCloser(io.MultiReader(
+			bytes.NewReader(head),
+			io.LimitReader(content, length),
+			bytes.NewReader(tail),
+		)), nil
+	}, nil
+}
+
 // Put updates an object on the API, using an HTTP "PUT" request.
 func (client Client) Put(uri *url.URL, parameters url.Values) ([]byte, error) {
 	return client.nonIdempotentRequest("PUT", uri, parameters)
 }
 
+// PutContent sends content as the raw body of an HTTP "PUT" request, for
+// the API calls that take data rather than parameters.
+func (client Client) PutContent(uri *url.URL, content []byte) ([]byte, error) {
+	url := client.GetURL(uri)
+	request, err := http.NewRequest("PUT", url.String(), bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/octet-stream")
+	return client.dispatchRequest(request)
+}
+
 // Delete deletes an object on the API, using an HTTP "DELETE" request.
 func (client Client) Delete(uri *url.URL) error {
 	url := client.GetURL(uri)
@@ -251,7 +708,7 @@ func (client Client) Delete(uri *url.URL) error {
 	}
 	_, err = client.dispatchRequest(request)
 	if err != nil {
-		return err
+		return client.journal("DELETE", uri, nil, err)
 	}
 	return nil
 }
@@ -270,8 +727,7 @@ var _ OAuthSigner = anonSigner{}
 // given URL, handling trailing slashes. It shouldn't be called with a
 // URL that already includes a version.
 func AddAPIVersionToURL(BaseURL, apiVersion string) string {
-	baseurl := EnsureTrailingSlash(BaseURL)
-	return fmt.Sprintf("%sapi/%s/", baseurl, apiVersion)
+	return EnsureTrailingSlash(BaseURL) + VersionedAPIPath(apiVersion, "")
 }
 
 var apiVersionPattern = regexp.MustCompile(`^(?P<base>.*/)api/(?P<version>\d+\.\d+)/?$`)
@@ -300,27 +756,35 @@ func NewAnonymousClient(BaseURL string, apiVersion string) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Client{Signer: &anonSigner{}, APIURL: parsedURL}, nil
+	return &Client{Signer: &anonSigner{}, APIURL: parsedURL, httpClients: &httpClientCache{}}, nil
 }
 
-// NewAuthenticatedClient parses the given MAAS API key into the
-// individual OAuth tokens and creates an Client that will use these
-// tokens to sign the requests it issues.
-// versionedURL should be the location of the versioned API root of
-// the MAAS server, e.g.:
-// http://my.maas.server.example.com/MAAS/api/2.0/
-func NewAuthenticatedClient(versionedURL, apiKey string) (*Client, error) {
+// parseAPIKey splits the MAAS API key into its OAuth tokens.
+func parseAPIKey(apiKey string) (*OAuthToken, error) {
 	elements := strings.Split(apiKey, ":")
 	if len(elements) != 3 {
 		errString := fmt.Sprintf("invalid API key %q; expected \"<consumer secret>:<token key>:<token secret>\"", apiKey)
 		return nil, errors.NewNotValid(nil, errString)
 	}
-	token := &OAuthToken{
+	return &OAuthToken{
 		ConsumerKey: elements[0],
 		// The consumer secret is the empty string in MAAS' authentication.
 		ConsumerSecret: "",
 		TokenKey:       elements[1],
 		TokenSecret:    elements[2],
+	}, nil
+}
+
+// NewAuthenticatedClient parses the given MAAS API key into the
+// individual OAuth tokens and creates an Client that will use these
+// tokens to sign the requests it issues.
+// versionedURL should be the location of the versioned API root of
+// the MAAS server, e.g.:
+// http://my.maas.server.example.com/MAAS/api/2.0/
+func NewAuthenticatedClient(versionedURL, apiKey string) (*Client, error) {
+	token, err := parseAPIKey(apiKey)
+	if err != nil {
+		return nil, err
 	}
 	signer, err := NewPlainTestOAuthSigner(token, "MAAS API")
 	if err != nil {
@@ -330,5 +794,5 @@ func NewAuthenticatedClient(versionedURL, apiKey string) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Client{Signer: signer, APIURL: parsedURL}, nil
+	return &Client{Signer: signer, APIURL: parsedURL, httpClients: &httpClientCache{}}, nil
 }
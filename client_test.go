@@ -5,12 +5,21 @@ package gomaasapi
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 )
@@ -35,6 +44,85 @@ func (*ClientSuite) TestReadAndCloseReturnsContents(c *gc.C) {
 	c.Check(string(data), gc.Equals, content)
 }
 
+// trustServer makes the default transport, which clients copy, trust the
+// certificate of the TLS test server until the returned func is called.
+func trustServer(server *httptest.Server) func() {
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	original := http.DefaultTransport
+	transport := original.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	http.DefaultTransport = transport
+	return func() { http.DefaultTransport = original }
+}
+
+func (suite *ClientSuite) TestClientTLSServerName(c *gc.C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+	defer trustServer(server)()
+	// The certificate of the test server names example.com but not
+	// localhost, as a certificate for a region would not name its VIP.
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	c.Assert(err, jc.ErrorIsNil)
+	client, err := NewAnonymousClient("https://localhost:"+port+"/", "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.Get(&url.URL{Path: "version/"}, "", nil)
+	c.Assert(err, gc.ErrorMatches, `.*certificate is valid for .*, not localhost`)
+
+	client.TLSServerName = "example.com"
+	result, err := client.Get(&url.URL{Path: "version/"}, "", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, "ok")
+}
+
+func (suite *ClientSuite) TestClientTLSTransportReused(c *gc.C) {
+	client, err := NewAnonymousClient("https://example.com/", "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.TLSServerName = "maas.example.com"
+	first := client.httpClient()
+	c.Check(first.Transport, gc.NotNil)
+	c.Check(client.httpClient(), gc.Equals, first)
+	// Copies of the client share it.
+	c.Check(client.WithContext(context.Background()).httpClient(), gc.Equals, first)
+
+	client.TLSServerName = "other.example.com"
+	second := client.httpClient()
+	c.Check(second, gc.Not(gc.Equals), first)
+	c.Check(second.Transport.(*http.Transport).TLSClientConfig.ServerName, gc.Equals, "other.example.com")
+}
+
+func (suite *ClientSuite) TestClientHTTPClient(c *gc.C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL+"/", "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.Get(&url.URL{Path: "version/"}, "", nil)
+	c.Assert(err, gc.ErrorMatches, `.*certificate signed by unknown authority`)
+
+	// The client of the test server trusts its certificate.
+	client.HTTPClient = server.Client()
+	result, err := client.Get(&url.URL{Path: "version/"}, "", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, "ok")
+
+	// The server name is set on a copy of the transport of the client.
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	c.Assert(err, jc.ErrorIsNil)
+	client.APIURL, err = url.Parse("https://localhost:" + port + "/api/2.0/")
+	c.Assert(err, jc.ErrorIsNil)
+	client.TLSServerName = "example.com"
+	result, err = client.Get(&url.URL{Path: "version/"}, "", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, "ok")
+	c.Check(server.Client().Transport.(*http.Transport).TLSClientConfig.ServerName, gc.Equals, "")
+}
+
 func (suite *ClientSuite) TestClientdispatchRequestReturnsServerError(c *gc.C) {
 	URI := "/some/url/?param1=test"
 	expectedResult := "expected:result"
@@ -55,6 +143,85 @@ func (suite *ClientSuite) TestClientdispatchRequestReturnsServerError(c *gc.C) {
 	c.Check(string(result), gc.Equals, expectedResult)
 }
 
+func (suite *ClientSuite) TestClientdispatchRequestRedactsServerError(c *gc.C) {
+	URI := "/some/url/?param1=test"
+	body := `{"power_pass": "hunter2"}`
+	server := newSingleServingServer(URI, body, http.StatusBadRequest)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := client.dispatchRequest(request)
+
+	c.Check(err, gc.ErrorMatches, `ServerError: 400 Bad Request \({"power_pass": "REDACTED"}\)`)
+	svrError, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrError.BodyMessage, gc.Equals, `{"power_pass": "REDACTED"}`)
+	c.Check(string(result), gc.Equals, body)
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestDisableRedaction(c *gc.C) {
+	URI := "/some/url/?param1=test"
+	body := `{"power_pass": "hunter2"}`
+	server := newSingleServingServer(URI, body, http.StatusBadRequest)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.DisableRedaction = true
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+
+	svrError, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrError.BodyMessage, gc.Equals, body)
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestMaxResponseSize(c *gc.C) {
+	URI := "/some/url/"
+	server := newSingleServingServer(URI, `["a", "b", "c"]`, http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.MaxResponseSize = 10
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+
+	c.Assert(err, jc.Satisfies, IsTooLargeError)
+	c.Check(err, gc.ErrorMatches, "response of at least 15 bytes exceeds the limit of 10 bytes")
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestMaxResponseSizeChunked(c *gc.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flushing before the end sends the body without a length.
+		for i := 0; i < 100; i++ {
+			fmt.Fprint(w, "0123456789")
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.MaxResponseSize = 25
+	request, err := http.NewRequest("GET", server.URL+"/some/url/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+
+	c.Assert(err, jc.Satisfies, IsTooLargeError)
+	c.Check(err, gc.ErrorMatches, "response of at least 26 bytes exceeds the limit of 25 bytes")
+
+	client.MaxResponseSize = 1000
+	result, err := client.dispatchRequest(request)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result, gc.HasLen, 1000)
+}
+
 func (suite *ClientSuite) TestClientdispatchRequestRetries503(c *gc.C) {
 	URI := "/some/url/?param1=test"
 	server := newFlakyServer(URI, 503, NumberOfRetries)
@@ -75,6 +242,302 @@ func (suite *ClientSuite) TestClientdispatchRequestRetries503(c *gc.C) {
 	c.Check(*server.requests, jc.DeepEquals, expectedRequestsContent)
 }
 
+func (suite *ClientSuite) TestClientdispatchRequestRetryPolicy(c *gc.C) {
+	URI := "/some/url/?param1=test"
+	server := newFlakyServer(URI, 502, 2)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, StatusCodes: []int{502}}
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := client.dispatchRequest(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, "ok")
+	c.Check(*server.nbRequests, gc.Equals, 3)
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestRetryPolicyMaxAttempts(c *gc.C) {
+	URI := "/some/url/?param1=test"
+	server := newFlakyServer(URI, 503, 5)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 2}
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+
+	svrError, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrError.StatusCode, gc.Equals, 503)
+	c.Check(*server.nbRequests, gc.Equals, 2)
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestRetriesNetworkErrors(c *gc.C) {
+	var nbRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if atomic.AddInt32(&nbRequests, 1) == 1 {
+			// Drop the connection without a response.
+			conn, _, err := writer.(http.Hijacker).Hijack()
+			c.Check(err, jc.ErrorIsNil)
+			conn.Close()
+			return
+		}
+		fmt.Fprint(writer, "ok")
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	request, err := http.NewRequest("GET", server.URL+"/some/url/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.dispatchRequest(request)
+	c.Assert(err, gc.NotNil)
+	c.Check(atomic.LoadInt32(&nbRequests), gc.Equals, int32(1))
+
+	atomic.StoreInt32(&nbRequests, 0)
+	client.RetryPolicy = &RetryPolicy{Backoff: time.Millisecond, RetryNetworkErrors: true}
+	request, err = http.NewRequest("GET", server.URL+"/some/url/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	result, err := client.dispatchRequest(request)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, "ok")
+	c.Check(atomic.LoadInt32(&nbRequests), gc.Equals, int32(2))
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestWaitsOnClock(c *gc.C) {
+	nbRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		nbRequests++
+		if nbRequests == 1 {
+			writer.Header().Set("Retry-After", "30")
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(writer, "ok")
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	clock := testing.NewClock(time.Time{})
+	client.Clock = clock
+	request, err := http.NewRequest("GET", server.URL+"/some/url/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.dispatchRequest(request)
+		done <- err
+	}()
+	err = clock.WaitAdvance(30*time.Second, 5*time.Second, 1)
+	c.Assert(err, jc.ErrorIsNil)
+
+	select {
+	case err := <-done:
+		c.Assert(err, jc.ErrorIsNil)
+	case <-time.After(5 * time.Second):
+		c.Fatalf("request did not complete after advancing the clock")
+	}
+	c.Check(nbRequests, gc.Equals, 2)
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestRetryWaitCancelled(c *gc.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Retry-After", "30")
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	clock := testing.NewClock(time.Time{})
+	client.Clock = clock
+	ctx, cancel := context.WithCancel(context.Background())
+	client = client.WithContext(ctx)
+	request, err := http.NewRequest("GET", server.URL+"/some/url/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.dispatchRequest(request)
+		done <- err
+	}()
+	err = clock.WaitAdvance(0, 5*time.Second, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	cancel()
+
+	select {
+	case err := <-done:
+		c.Assert(err, jc.Satisfies, IsContextError)
+	case <-time.After(5 * time.Second):
+		c.Fatalf("request did not complete after cancelling the context")
+	}
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestCancelled(c *gc.C) {
+	server := newSingleServingServer("/some/url/", "ok", http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client = client.WithContext(ctx)
+	request, err := http.NewRequest("GET", server.URL+"/some/url/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+	c.Assert(err, jc.Satisfies, IsContextError)
+}
+
+const expiredTimestampMessage = "Authorization Error: 'Expired timestamp: given 1500000000 and now 1500003600 has a greater difference than threshold 300'"
+
+func newSkewedServer(skew time.Duration, timestamps *[]int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		now := time.Now().Add(skew)
+		writer.Header().Set("Date", now.UTC().Format(http.TimeFormat))
+		auth := request.Header.Get("Authorization")
+		start := strings.Index(auth, `oauth_timestamp="`)
+		if start < 0 {
+			// Anonymous requests have no timestamp to check.
+			fmt.Fprint(writer, "ok")
+			return
+		}
+		var timestamp int64
+		fmt.Sscanf(auth[start+len(`oauth_timestamp="`):], "%d", &timestamp)
+		*timestamps = append(*timestamps, timestamp)
+		if diff := now.Unix() - timestamp; diff > 300 || diff < -300 {
+			http.Error(writer, expiredTimestampMessage, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(writer, "ok")
+	}))
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestAdjustsClockSkew(c *gc.C) {
+	var timestamps []int64
+	server := newSkewedServer(time.Hour, &timestamps)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL+"/api/2.0/", "a:b:c")
+	c.Assert(err, jc.ErrorIsNil)
+	client.AdjustClockSkew = true
+
+	request, err := http.NewRequest("GET", server.URL+"/api/2.0/version/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	result, err := client.dispatchRequest(request)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, "ok")
+	c.Assert(timestamps, gc.HasLen, 2)
+	c.Check(timestamps[1]-timestamps[0], jc.GreaterThan, 3590)
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestAdjustsClockSkewWithClock(c *gc.C) {
+	var timestamps []int64
+	server := newSkewedServer(time.Hour, &timestamps)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL+"/api/2.0/", "a:b:c")
+	c.Assert(err, jc.ErrorIsNil)
+	client.AdjustClockSkew = true
+	client.Clock = testing.NewClock(time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	request, err := http.NewRequest("GET", server.URL+"/api/2.0/version/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.dispatchRequest(request)
+	c.Assert(err, jc.ErrorIsNil)
+	// The skew is measured against the clock of the client, which the
+	// timestamps then follow.
+	c.Assert(timestamps, gc.HasLen, 2)
+	serverNow := time.Now().Add(time.Hour).Unix()
+	c.Check(serverNow-timestamps[1] < 5 && timestamps[1]-serverNow < 5, jc.IsTrue, gc.Commentf("timestamp %d", timestamps[1]))
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestClockSkewNotAdjusted(c *gc.C) {
+	var timestamps []int64
+	server := newSkewedServer(-time.Hour, &timestamps)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL+"/api/2.0/", "a:b:c")
+	c.Assert(err, jc.ErrorIsNil)
+
+	request, err := http.NewRequest("GET", server.URL+"/api/2.0/version/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.dispatchRequest(request)
+	c.Check(err, jc.Satisfies, IsClockSkewError)
+	c.Check(timestamps, gc.HasLen, 1)
+}
+
+func newUnauthorizedServer(rejections int, headers *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		*headers = append(*headers, request.Header.Get("Authorization"))
+		if len(*headers) <= rejections {
+			http.Error(writer, "Authorization Error: Nonce already used", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(writer, "ok")
+	}))
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestRetriesUnauthorized(c *gc.C) {
+	var headers []string
+	server := newUnauthorizedServer(1, &headers)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL+"/api/2.0/", "a:b:c")
+	c.Assert(err, jc.ErrorIsNil)
+	client.RetryUnauthorized = true
+
+	request, err := http.NewRequest("GET", server.URL+"/api/2.0/version/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	result, err := client.dispatchRequest(request)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, "ok")
+	c.Assert(headers, gc.HasLen, 2)
+	c.Check(headers[1], gc.Not(gc.Equals), headers[0])
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestRetriesUnauthorizedOnce(c *gc.C) {
+	var headers []string
+	server := newUnauthorizedServer(2, &headers)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL+"/api/2.0/", "a:b:c")
+	c.Assert(err, jc.ErrorIsNil)
+	client.RetryUnauthorized = true
+
+	request, err := http.NewRequest("GET", server.URL+"/api/2.0/version/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.dispatchRequest(request)
+	svrErr, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrErr.StatusCode, gc.Equals, http.StatusUnauthorized)
+	c.Check(headers, gc.HasLen, 2)
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestUnauthorizedNotRetried(c *gc.C) {
+	var headers []string
+	server := newUnauthorizedServer(1, &headers)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL+"/api/2.0/", "a:b:c")
+	c.Assert(err, jc.ErrorIsNil)
+
+	request, err := http.NewRequest("GET", server.URL+"/api/2.0/version/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.dispatchRequest(request)
+	c.Check(err, gc.NotNil)
+	c.Check(headers, gc.HasLen, 1)
+}
+
+func (suite *ClientSuite) TestServerTime(c *gc.C) {
+	var timestamps []int64
+	server := newSkewedServer(time.Hour, &timestamps)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	serverTime, err := client.ServerTime()
+	c.Assert(err, jc.ErrorIsNil)
+	skew := serverTime.Sub(time.Now())
+	c.Check(skew > 58*time.Minute && skew < 62*time.Minute, jc.IsTrue, gc.Commentf("skew %v", skew))
+}
+
 func (suite *ClientSuite) TestClientdispatchRequestDoesntRetry200(c *gc.C) {
 	URI := "/some/url/?param1=test"
 	server := newFlakyServer(URI, 200, 10)
@@ -195,6 +658,44 @@ func (suite *ClientSuite) TestClientPostSendsRequestWithParams(c *gc.C) {
 	c.Check(postedValues, jc.DeepEquals, expectedPostedValues)
 }
 
+func (suite *ClientSuite) TestClientPostRefusesRedirectToGet(c *gc.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/some/url" {
+			http.Redirect(writer, request, "/some/url/", http.StatusMovedPermanently)
+			return
+		}
+		fmt.Fprint(writer, "ok")
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.Post(&url.URL{Path: "/some/url"}, "list", url.Values{"test": {"123"}}, nil)
+	c.Assert(err, gc.ErrorMatches, `.*POST .*/some/url\?op=list redirected to GET .*/some/url/`)
+}
+
+func (suite *ClientSuite) TestClientPostFollowsPermanentRedirect(c *gc.C) {
+	var method, content string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/some/url" {
+			http.Redirect(writer, request, "/some/url/?"+request.URL.RawQuery, http.StatusPermanentRedirect)
+			return
+		}
+		method = request.Method
+		content = request.PostFormValue("test")
+		fmt.Fprint(writer, "ok")
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := client.Post(&url.URL{Path: "/some/url"}, "list", url.Values{"test": {"123"}}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, "ok")
+	c.Check(method, gc.Equals, "POST")
+	c.Check(content, gc.Equals, "123")
+}
+
 // extractFileContent extracts from the request built using 'requestContent',
 // 'requestHeader' and 'requestURL', the file named 'filename'.
 func extractFileContent(requestContent string, requestHeader *http.Header, requestURL string, filename string) ([]byte, error) {
@@ -237,6 +738,114 @@ func (suite *ClientSuite) TestClientPostSendsMultipartRequest(c *gc.C) {
 	c.Check(receivedFileContent, jc.DeepEquals, fileContent)
 }
 
+func (suite *ClientSuite) TestClientPostFileStreamsContent(c *gc.C) {
+	URI, err := url.Parse("/some/url")
+	c.Assert(err, jc.ErrorIsNil)
+	expectedResult := "expected:result"
+	fullURI := URI.String() + "?op=add"
+	server := newSingleServingServer(fullURI, expectedResult, http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	// Only the length given is sent.
+	content := strings.NewReader("content and more")
+
+	result, err := client.PostFile(URI, "add", url.Values{"test": {"123"}}, "testfile", content, 7)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, expectedResult)
+	receivedFileContent, err := extractFileContent(*server.requestContent, server.requestHeader, fullURI, "testfile")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(receivedFileContent), gc.Equals, "content")
+	c.Check(*server.requestContent, jc.Contains, `name="test"`)
+}
+
+func (suite *ClientSuite) TestClientPostFileRetriesSeekable(c *gc.C) {
+	URI := "/some/url/?op=add"
+	server := newFlakyServer(URI, 503, 1)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	content := strings.NewReader("skipped content")
+	_, err = content.Seek(8, io.SeekStart)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.PostFile(&url.URL{Path: "/some/url/"}, "add", nil, "testfile", content, 7)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(*server.nbRequests, gc.Equals, 2)
+	requests := *server.requests
+	c.Check(string(requests[0]), jc.Contains, "content")
+	c.Check(requests[1], jc.DeepEquals, requests[0])
+}
+
+func (suite *ClientSuite) TestClientPostFileNotSeekableNotRetried(c *gc.C) {
+	URI := "/some/url/?op=add"
+	server := newFlakyServer(URI, 503, 1)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	content := struct{ io.Reader }{strings.NewReader("content")}
+
+	_, err = client.PostFile(&url.URL{Path: "/some/url/"}, "add", nil, "testfile", content, 7)
+
+	c.Assert(err, gc.ErrorMatches, "cannot send content again, the reader is not seekable")
+	c.Check(*server.nbRequests, gc.Equals, 1)
+}
+
+func (suite *ClientSuite) TestClientGetReaderRange(c *gc.C) {
+	content := "0123456789"
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.ServeContent(writer, request, "file", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	for _, test := range []struct {
+		offset   int64
+		expected string
+	}{
+		{0, content},
+		{4, "456789"},
+		{10, ""},
+		{20, ""},
+	} {
+		reader, err := client.GetReader(&url.URL{Path: "/file"}, test.offset)
+		c.Assert(err, jc.ErrorIsNil)
+		result, err := ioutil.ReadAll(reader)
+		reader.Close()
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(string(result), gc.Equals, test.expected, gc.Commentf("offset %d", test.offset))
+	}
+}
+
+func (suite *ClientSuite) TestClientGetReaderError(c *gc.C) {
+	server := newSingleServingServer("/file", "no such file", http.StatusNotFound)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.GetReader(&url.URL{Path: "/file"}, 0)
+	svrErr, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrErr.StatusCode, gc.Equals, http.StatusNotFound)
+	c.Check(svrErr.BodyMessage, gc.Equals, "no such file")
+}
+
+func (suite *ClientSuite) TestClientGetReaderErrorRedacted(c *gc.C) {
+	server := newSingleServingServer("/file", `{"power_pass": "hunter2"}`, http.StatusBadRequest)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.GetReader(&url.URL{Path: "/file"}, 0)
+	svrErr, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrErr.BodyMessage, gc.Equals, `{"power_pass": "REDACTED"}`)
+	c.Check(err, gc.Not(gc.ErrorMatches), ".*hunter2.*")
+}
+
 func (suite *ClientSuite) TestClientPutSendsRequest(c *gc.C) {
 	URI, err := url.Parse("/some/url")
 	c.Assert(err, jc.ErrorIsNil)
@@ -0,0 +1,169 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/juju/errors"
+	"github.com/juju/version"
+)
+
+// CompatibilityReport is the result of Controller.CheckCompatibility.
+type CompatibilityReport struct {
+	// ServerVersion is the version of the server, and SchemaVersion the
+	// version that its responses were read as.
+	ServerVersion version.Number
+	SchemaVersion version.Number
+
+	// Endpoints has an entry for each endpoint that was read.
+	Endpoints []EndpointCompatibility
+}
+
+// Compatible returns true if the responses of every endpoint that could be
+// read were understood.
+func (r CompatibilityReport) Compatible() bool {
+	for _, endpoint := range r.Endpoints {
+		if !endpoint.Compatible() {
+			return false
+		}
+	}
+	return true
+}
+
+// EndpointCompatibility describes how the response of an endpoint fits the
+// objects of this package.
+type EndpointCompatibility struct {
+	// Path is the path of the endpoint, such as "machines".
+	Path string
+
+	// Objects is the number of objects in the response.
+	Objects int
+
+	// RequestError is set if the endpoint could not be read, such as when
+	// the user is not an admin or the server does not have the endpoint.
+	// The other fields are then empty.
+	RequestError error
+
+	// ReadError is set if the response could not be read, such as when a
+	// field that this package requires is missing or has another type.
+	// It satisfies IsDeserializationError.
+	ReadError error
+
+	// Unknown are the fields of the first object in the response that
+	// this package does not read, sorted by name. They are usually new
+	// fields of the server, and are harmless.
+	Unknown []string
+}
+
+// Compatible returns true if the response was read, or could not be
+// requested at all.
+func (e EndpointCompatibility) Compatible() bool {
+	return e.ReadError == nil
+}
+
+// compatibilityReadFunc reads a list of objects from a response.
+type compatibilityReadFunc func(version.Number, interface{}) (interface{}, error)
+
+// compatibilityEndpoints are the endpoints read by CheckCompatibility. Each
+// lists objects without a filter, and its read func is the one that the
+// Controller uses.
+var compatibilityEndpoints = []struct {
+	path string
+	read compatibilityReadFunc
+}{
+	{MachinesPath, func(v version.Number, source interface{}) (interface{}, error) { return readMachines(v, source) }},
+	{DevicesPath, func(v version.Number, source interface{}) (interface{}, error) { return readDevices(v, source) }},
+	{RackControllersPath, func(v version.Number, source interface{}) (interface{}, error) { return readControllerNodes(v, source) }},
+	{PodsPath, func(v version.Number, source interface{}) (interface{}, error) { return readPods(v, source) }},
+	{FabricsPath, func(v version.Number, source interface{}) (interface{}, error) { return readFabrics(v, source) }},
+	{SpacesPath, func(v version.Number, source interface{}) (interface{}, error) { return readSpaces(v, source) }},
+	{SubnetsPath, func(v version.Number, source interface{}) (interface{}, error) { return readSubnets(v, source) }},
+	{StaticRoutesPath, func(v version.Number, source interface{}) (interface{}, error) { return readStaticRoutes(v, source) }},
+	{IPAddressesPath, func(v version.Number, source interface{}) (interface{}, error) { return readIPAddresses(v, source) }},
+	{ZonesPath, func(v version.Number, source interface{}) (interface{}, error) { return readZones(v, source) }},
+	{PoolsPath, func(v version.Number, source interface{}) (interface{}, error) { return readPools(v, source) }},
+	{TagsPath, func(v version.Number, source interface{}) (interface{}, error) { return readTags(v, source) }},
+	{DomainsPath, func(v version.Number, source interface{}) (interface{}, error) { return readDomains(v, source) }},
+	{DNSResourcesPath, func(v version.Number, source interface{}) (interface{}, error) { return readDNSResources(v, source) }},
+	{BootResourcesPath, func(v version.Number, source interface{}) (interface{}, error) { return readBootResources(v, source) }},
+	{BootSourcesPath, func(v version.Number, source interface{}) (interface{}, error) { return readBootSources(v, source) }},
+	{FilesPath, func(v version.Number, source interface{}) (interface{}, error) { return readFiles(v, source) }},
+}
+
+// CheckCompatibility implements Controller.
+func (c *controller) CheckCompatibility() (CompatibilityReport, error) {
+	report := CompatibilityReport{
+		ServerVersion: c.ServerVersion(),
+		SchemaVersion: c.schemaVersion(),
+	}
+	for _, endpoint := range compatibilityEndpoints {
+		result := EndpointCompatibility{Path: endpoint.path}
+		source, err := c.get(endpoint.path)
+		if err != nil {
+			if IsContextError(err) {
+				return CompatibilityReport{}, errors.Trace(err)
+			}
+			result.RequestError = compatibilityRequestError(err)
+			report.Endpoints = append(report.Endpoints, result)
+			continue
+		}
+		list, _ := source.([]interface{})
+		result.Objects = len(list)
+		if _, err := endpoint.read(c.schemaVersion(), source); err != nil {
+			result.ReadError = errors.Trace(err)
+		} else if len(list) > 0 {
+			result.Unknown = unknownFields(c.schemaVersion(), endpoint.read, list[0])
+		}
+		report.Endpoints = append(report.Endpoints, result)
+	}
+	return report, nil
+}
+
+func compatibilityRequestError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusNotFound:
+			return errors.NewNotSupported(err, "")
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+// unknownFieldProbe replaces a field to find whether it is read. No schema
+// accepts it except one that takes any value, and then it shows up in the
+// object read.
+type unknownFieldProbe struct{}
+
+// unknownFields returns the fields of the object that are not read. A field
+// is read if replacing it with a probe changes the result, either because
+// the object can no longer be read or because the probe is kept.
+func unknownFields(v version.Number, read compatibilityReadFunc, object interface{}) []string {
+	fields, ok := object.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	expected, err := read(v, []interface{}{fields})
+	if err != nil {
+		return nil
+	}
+	var unknown []string
+	for name := range fields {
+		probed := make(map[string]interface{}, len(fields))
+		for key, value := range fields {
+			probed[key] = value
+		}
+		probed[name] = unknownFieldProbe{}
+		got, err := read(v, []interface{}{probed})
+		if err == nil && reflect.DeepEqual(got, expected) {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
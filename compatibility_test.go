@@ -0,0 +1,94 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type compatibilitySuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&compatibilitySuite{})
+
+func (s *compatibilitySuite) endpoint(c *gc.C, report CompatibilityReport, path string) EndpointCompatibility {
+	for _, endpoint := range report.Endpoints {
+		if endpoint.Path == path {
+			return endpoint
+		}
+	}
+	c.Fatalf("no endpoint %q in report", path)
+	return EndpointCompatibility{}
+}
+
+func (s *compatibilitySuite) TestCheckCompatibility(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, `[{
+		"id": 1,
+		"name": "default",
+		"description": "",
+		"resource_uri": "/MAAS/api/2.0/zones/default/",
+		"new_field": {"added": "later"},
+		"another": 7
+	}]`)
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, `[{
+		"id": 1,
+		"name": "192.168.100.0/24",
+		"space": "space-0",
+		"vlan": {}
+	}]`)
+	server.AddGetResponse("/api/2.0/pools/", http.StatusOK, `[]`)
+	server.AddGetResponse("/api/2.0/boot-sources/", http.StatusForbidden, "admins only")
+
+	report, err := controller.CheckCompatibility()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(report.SchemaVersion, gc.Equals, twoDotOh)
+	c.Check(report.Endpoints, gc.HasLen, len(compatibilityEndpoints))
+	c.Check(report.Compatible(), jc.IsFalse)
+
+	zones := s.endpoint(c, report, "zones")
+	c.Check(zones.Compatible(), jc.IsTrue)
+	c.Check(zones.Objects, gc.Equals, 1)
+	c.Check(zones.Unknown, jc.DeepEquals, []string{"another", "new_field"})
+
+	subnets := s.endpoint(c, report, "subnets")
+	c.Check(subnets.Compatible(), jc.IsFalse)
+	c.Check(subnets.ReadError, jc.Satisfies, IsDeserializationError)
+
+	pools := s.endpoint(c, report, "pools")
+	c.Check(pools.Compatible(), jc.IsTrue)
+	c.Check(pools.Objects, gc.Equals, 0)
+	c.Check(pools.Unknown, gc.HasLen, 0)
+
+	bootSources := s.endpoint(c, report, "boot-sources")
+	c.Check(bootSources.Compatible(), jc.IsTrue)
+	c.Check(bootSources.RequestError, jc.Satisfies, IsPermissionError)
+
+	machines := s.endpoint(c, report, "machines")
+	c.Check(machines.RequestError, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *compatibilitySuite) TestCheckCompatibilityMachines(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"new_field": true,
+	})
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+response+"]")
+
+	report, err := controller.CheckCompatibility()
+	c.Assert(err, jc.ErrorIsNil)
+	machines := s.endpoint(c, report, "machines")
+	c.Check(machines.Compatible(), jc.IsTrue)
+	unknown := set.NewStrings(machines.Unknown...)
+	c.Check(unknown.Contains("new_field"), jc.IsTrue)
+	c.Check(unknown.Contains("system_id"), jc.IsFalse)
+	c.Check(unknown.Contains("status_name"), jc.IsFalse)
+}
@@ -4,29 +4,57 @@
 package gomaasapi
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/juju/collections/set"
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
 	"github.com/juju/schema"
+	"github.com/juju/utils/clock"
 	"github.com/juju/version"
 )
 
+// The names of the loggers used by the package. The child loggers can be
+// configured separately, so that, for example, the wire traces of
+// HTTPLoggerName can be enabled without the rest.
+const (
+	LoggerName            = "maas"
+	HTTPLoggerName        = LoggerName + ".http"
+	DeserializeLoggerName = LoggerName + ".deserialize"
+	ControllerLoggerName  = LoggerName + ".controller"
+)
+
 var (
-	logger = loggo.GetLogger("maas")
+	logger = loggo.GetLogger(LoggerName)
+
+	// httpLogger traces every request and response.
+	httpLogger = loggo.GetLogger(HTTPLoggerName)
+	// deserializeLogger reports objects skipped when reading responses.
+	deserializeLogger = loggo.GetLogger(DeserializeLoggerName)
+	// controllerLogger reports what the controller does on the caller's
+	// behalf, such as retries and cleanups.
+	controllerLogger = loggo.GetLogger(ControllerLoggerName)
 
 	// The supported versions should be ordered from most desirable version to
-	// least as they will be tried in order.
+	// least as they will be tried in order. Every MAAS release so far serves
+	// its API as 2.0, and later releases are told apart by the server
+	// version that they report.
 	supportedAPIVersions = []string{"2.0"}
 
 	// Each of the api versions that change the request or response structure
@@ -36,6 +64,11 @@ var (
 
 	// Current request number. Informational only for logging.
 	requestNumber int64
+
+	// The maximum number of system IDs sent in a single machines query.
+	// Long lists of IDs are split over multiple requests, as some servers
+	// and proxies reject very long URLs.
+	maxSystemIDsPerRequest = 100
 )
 
 // ControllerArgs is an argument struct for passing the required parameters
@@ -43,8 +76,125 @@ var (
 type ControllerArgs struct {
 	BaseURL string
 	APIKey  string
+
+	// MaxQueryLength is the maximum length in bytes of the encoded query
+	// string sent with a GET request. Machines and Devices split the system
+	// IDs and hostnames they filter on across as many requests as needed.
+	// Other queries that are longer are rejected with an error satisfying
+	// errors.IsNotValid before they are sent, rather than risking silent
+	// truncation by a proxy. Zero means that DefaultMaxQueryLength is used,
+	// and a negative value disables the check.
+	MaxQueryLength int
+
+	// Clock is used for every wait, whether it is polling for a change
+	// or backing off before a retry, so tests can control the passage of
+	// time. If it is nil, the wall clock is used.
+	Clock clock.Clock
+
+	// ServerPathPrefix is the path that the MAAS server believes it is
+	// served under, usually "/MAAS/". It only needs to be set when the
+	// server is reached through a reverse proxy that rewrites the path, as
+	// when BaseURL is "http://proxy.example.com/infra/maas/". The resource
+	// URIs returned by the server start with ServerPathPrefix, and are
+	// rewritten to start with the path of BaseURL instead.
+	ServerPathPrefix string
+
+	// NoTrailingSlash, if true, sends request paths as they are given
+	// instead of adding the trailing slash that the MAAS API expects.
+	// It is for reverse proxies that route on the exact path and
+	// redirect the slashed path, which would turn a POST into a GET.
+	NoTrailingSlash bool
+
+	// AdjustClockSkew, if true, corrects the timestamps of requests for
+	// the difference between the local clock and the server's when the
+	// server rejects them. Otherwise such requests fail with an error
+	// satisfying IsClockSkewError.
+	AdjustClockSkew bool
+
+	// RetryUnauthorized, if true, retries a request once with a fresh
+	// signature when the server rejects it as unauthorized, before the
+	// rejection is returned as a PermissionError. See
+	// Client.RetryUnauthorized.
+	RetryUnauthorized bool
+
+	// CapabilitiesChanged, if set, is called by RefreshCapabilities when
+	// the capabilities of the server have changed, as they may after an
+	// upgrade.
+	CapabilitiesChanged func(added, removed set.Strings)
+
+	// UnknownValue, if set, is called for each value read from the server
+	// that is not one of the values known to this package, such as a new
+	// machine status or interface type. Nothing fails on such values, but
+	// reporting them shows up new MAAS behaviour before it matters.
+	UnknownValue func(UnknownValue)
+
+	// SchemaVersion, if set, pins the version of the responses that the
+	// controller expects, so that an upgrade of the server does not
+	// change how they are read. Otherwise they are read as the newest
+	// version that the server is at least. See
+	// Controller.CheckCompatibility.
+	SchemaVersion version.Number
+
+	// RateLimitChanged, if set, is called with the rate limit reported by
+	// each response that carries rate limit headers, from the goroutine
+	// making the request. Controller.RateLimit returns the latest one.
+	RateLimitChanged func(RateLimit)
+
+	// TLSServerName, if set, is the name that the certificate of the
+	// server must have, when it differs from the host of BaseURL. See
+	// Client.TLSServerName.
+	TLSServerName string
+
+	// TLSConfig, if set, is the TLS configuration for an HTTPS BaseURL.
+	// CACertPEM and SkipTLSVerify are applied to a copy of it.
+	TLSConfig *tls.Config
+
+	// CACertPEM, if set, holds the PEM encoded certificates of the CAs
+	// that the certificate of the server must be signed by, such as the
+	// self-signed certificate of the region, in place of the CAs of the
+	// system.
+	CACertPEM string
+
+	// SkipTLSVerify, if true, accepts any certificate from the server.
+	// It leaves the connection open to interception, so prefer CACertPEM.
+	SkipTLSVerify bool
+
+	// HTTPClient, if set, is used for all the requests to the server, for
+	// a proxy, custom CAs or timeouts. See Client.HTTPClient.
+	HTTPClient *http.Client
+
+	// DisableRedaction, if true, logs the values of sensitive fields, such
+	// as power passwords, and keeps them in the errors from the server.
+	// By default they are replaced by RedactedValue.
+	DisableRedaction bool
+
+	// Journal, if set, records the requests that change the region when
+	// the server cannot be reached, to be sent later by
+	// Controller.ReplayJournal. See Client.Journal.
+	Journal *Journal
+
+	// RetryPolicy, if set, controls the retries of every request of the
+	// controller, such as retrying transient network failures with a
+	// backoff. See Client.RetryPolicy.
+	RetryPolicy *RetryPolicy
+
+	// MaxResponseSize, if positive, is the largest response body in bytes
+	// that is read, see Client.MaxResponseSize. MaxDecodeMemory, if
+	// positive, is the estimated memory in bytes that decoding a response
+	// may take, which can be several times its size for lists of small
+	// objects. Responses over either limit fail with a TooLargeError
+	// before they are held in memory or decoded. They protect agents with
+	// little memory from unexpectedly large lists; filter the lists, such
+	// as with MachinesArgs, to stay within them.
+	MaxResponseSize int64
+	MaxDecodeMemory int64
 }
 
+// DefaultMaxQueryLength is the query string length limit used when
+// ControllerArgs.MaxQueryLength is not set. It leaves room for the rest of
+// the request line within the common 8KiB limit of web servers and proxies.
+const DefaultMaxQueryLength = 7168
+
 // NewController creates an authenticated client to the MAAS API, and
 // checks the capabilities of the server. If the BaseURL specified
 // includes the API version, that version of the API will be used,
@@ -59,7 +209,7 @@ func NewController(args ControllerArgs) (Controller, error) {
 		if !supportedVersion(apiVersion) {
 			return nil, NewUnsupportedVersionError("version %s", apiVersion)
 		}
-		return newControllerWithVersion(base, apiVersion, args.APIKey)
+		return newControllerWithVersion(base, apiVersion, args)
 	}
 	return newControllerUnknownVersion(args)
 }
@@ -73,13 +223,8 @@ func supportedVersion(value string) bool {
 	return false
 }
 
-func newControllerWithVersion(baseURL, apiVersion, apiKey string) (Controller, error) {
-	major, minor, err := version.ParseMajorMinor(apiVersion)
-	// We should not get an error here. See the test.
-	if err != nil {
-		return nil, errors.Errorf("bad version defined in supported versions: %q", apiVersion)
-	}
-	client, err := NewAuthenticatedClient(AddAPIVersionToURL(baseURL, apiVersion), apiKey)
+func newControllerWithVersion(baseURL, apiVersion string, args ControllerArgs) (Controller, error) {
+	client, err := NewAuthenticatedClient(AddAPIVersionToURL(baseURL, apiVersion), args.APIKey)
 	if err != nil {
 		// If the credentials aren't valid, return now.
 		if errors.IsNotValid(err) {
@@ -89,16 +234,86 @@ func newControllerWithVersion(baseURL, apiVersion, apiKey string) (Controller, e
 		// is an unexpected error and return now.
 		return nil, NewUnexpectedError(err)
 	}
+	controller, err := newControllerWithClient(client, baseURL, apiVersion, args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return controller, nil
+}
+
+// newControllerWithClient creates a controller that makes its requests
+// with the client, which must be for the apiVersion of the API at the
+// baseURL. The client is changed to use the clock of the args.
+func newControllerWithClient(client *Client, baseURL, apiVersion string, args ControllerArgs) (*controller, error) {
+	major, minor, err := version.ParseMajorMinor(apiVersion)
+	// We should not get an error here. See the test.
+	if err != nil {
+		return nil, errors.Errorf("bad version defined in supported versions: %q", apiVersion)
+	}
 	controllerVersion := version.Number{
 		Major: major,
 		Minor: minor,
 	}
-	controller := &controller{client: client, apiVersion: controllerVersion}
-	controller.capabilities, err = controller.readAPIVersionInfo()
+	maxQueryLength := args.MaxQueryLength
+	if maxQueryLength == 0 {
+		maxQueryLength = DefaultMaxQueryLength
+	}
+	clk := args.Clock
+	if clk == nil {
+		clk = clock.WallClock
+	}
+	client.Clock = clk
+	client.AdjustClockSkew = args.AdjustClockSkew
+	client.RetryUnauthorized = args.RetryUnauthorized
+	client.TLSServerName = args.TLSServerName
+	client.RetryPolicy = args.RetryPolicy
+	client.DisableRedaction = args.DisableRedaction
+	client.HTTPClient = args.HTTPClient
+	client.Journal = args.Journal
+	client.MaxResponseSize = args.MaxResponseSize
+	client.TLSConfig, err = args.tlsConfig()
 	if err != nil {
-		logger.Debugf("read version failed: %#v", err)
 		return nil, errors.Trace(err)
 	}
+	// The transport made for the TLS settings is kept for all the
+	// requests of the controller, so that its connections are reused.
+	if client.httpClients == nil {
+		client.httpClients = &httpClientCache{}
+	}
+	controller := &controller{
+		client:          client,
+		baseAPIVersion:  controllerVersion,
+		pinnedVersion:   args.SchemaVersion,
+		maxQueryLength:  maxQueryLength,
+		clock:           clk,
+		noTrailingSlash: args.NoTrailingSlash,
+		maxDecodeMemory: args.MaxDecodeMemory,
+
+		controllerState:     &controllerState{},
+		capabilitiesChanged: args.CapabilitiesChanged,
+		unknownValue:        args.UnknownValue,
+		rateLimits:          &rateLimitTracker{changed: args.RateLimitChanged},
+	}
+	client.RateLimitObserver = controller.rateLimits
+	if args.ServerPathPrefix != "" {
+		parsed, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, NewUnexpectedError(err)
+		}
+		serverPrefix := EnsureTrailingSlash("/" + strings.TrimPrefix(args.ServerPathPrefix, "/"))
+		basePath := EnsureTrailingSlash(parsed.Path)
+		if serverPrefix != basePath {
+			controller.serverPathPrefix = serverPrefix
+			controller.basePath = basePath
+		}
+	}
+	capabilities, serverVersion, err := controller.readAPIVersionInfo()
+	if err != nil {
+		controllerLogger.Debugf("read version failed: %#v", err)
+		return nil, errors.Trace(err)
+	}
+	controller.capabilities = capabilities
+	controller.setServerVersion(serverVersion)
 
 	if err := controller.checkCreds(); err != nil {
 		return nil, errors.Trace(err)
@@ -106,12 +321,35 @@ func newControllerWithVersion(baseURL, apiVersion, apiKey string) (Controller, e
 	return controller, nil
 }
 
+// tlsConfig returns the TLS configuration of the args, or nil if they have
+// none.
+func (args ControllerArgs) tlsConfig() (*tls.Config, error) {
+	if args.TLSConfig == nil && args.CACertPEM == "" && !args.SkipTLSVerify {
+		return nil, nil
+	}
+	config := &tls.Config{}
+	if args.TLSConfig != nil {
+		config = args.TLSConfig.Clone()
+	}
+	if args.CACertPEM != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(args.CACertPEM)) {
+			return nil, errors.NotValidf("CACertPEM without certificates")
+		}
+		config.RootCAs = pool
+	}
+	if args.SkipTLSVerify {
+		config.InsecureSkipVerify = true
+	}
+	return config, nil
+}
+
 func newControllerUnknownVersion(args ControllerArgs) (Controller, error) {
 	// For now we don't need to test multiple versions. It is expected that at
 	// some time in the future, we will try the most up to date version and then
 	// work our way backwards.
 	for _, apiVersion := range supportedAPIVersions {
-		controller, err := newControllerWithVersion(args.BaseURL, apiVersion, args.APIKey)
+		controller, err := newControllerWithVersion(args.BaseURL, apiVersion, args)
 		switch {
 		case err == nil:
 			return controller, nil
@@ -127,23 +365,165 @@ func newControllerUnknownVersion(args ControllerArgs) (Controller, error) {
 }
 
 type controller struct {
-	client       *Client
-	apiVersion   version.Number
-	capabilities set.Strings
+	client *Client
+	// baseAPIVersion is the version of the API, and pinnedVersion is
+	// ControllerArgs.SchemaVersion. See setServerVersion.
+	baseAPIVersion version.Number
+	pinnedVersion  version.Number
+	maxQueryLength int
+	clock          clock.Clock
+
+	// noTrailingSlash is set from ControllerArgs.NoTrailingSlash.
+	noTrailingSlash bool
+	// maxDecodeMemory is set from ControllerArgs.MaxDecodeMemory.
+	maxDecodeMemory int64
+
+	// controllerState is shared with the controllers returned by
+	// WithContext.
+	*controllerState
+	capabilitiesChanged func(added, removed set.Strings)
+	unknownValue        func(UnknownValue)
+
+	// rateLimits is shared with the controllers returned by WithContext.
+	rateLimits *rateLimitTracker
+
+	// serverPathPrefix and basePath are only set when the resource URIs
+	// returned by the server need rewriting, see ControllerArgs.
+	serverPathPrefix string
+	basePath         string
+}
+
+// controllerState is the mutable state of a controller.
+type controllerState struct {
+	// mu guards capabilities, serverVersion and apiVersion, which
+	// RefreshCapabilities replaces. apiVersion selects the
+	// deserialization funcs; use schemaVersion to read it.
+	mu            sync.Mutex
+	capabilities  set.Strings
+	serverVersion version.Number
+	apiVersion    version.Number
+
+	// staleMu guards generation and stale, see stale.go.
+	staleMu    sync.Mutex
+	generation uint64
+	stale      map[string]staleRecord
+}
+
+// WithContext implements Controller.
+func (c *controller) WithContext(ctx context.Context) Controller {
+	bound := *c
+	bound.client = c.client.WithContext(ctx)
+	return &bound
+}
+
+// UpdateAPIKey implements Controller.
+func (c *controller) UpdateAPIKey(apiKey string, checkCreds bool) error {
+	token, err := parseAPIKey(apiKey)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	setter, ok := c.client.Signer.(tokenSetter)
+	if !ok {
+		return errors.NotSupportedf("updating the API key of %T", c.client.Signer)
+	}
+	if checkCreds {
+		// The new key is checked with a signer of its own, so that other
+		// requests keep using the old key until the new one is known to
+		// work.
+		client := *c.client
+		client.Signer = setter.withToken(token)
+		checking := *c
+		checking.client = &client
+		if err := checking.checkCreds(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	// The signer is shared by the client of every Controller bound with
+	// WithContext, so they all switch to the new key.
+	setter.setToken(token)
+	return nil
+}
+
+// ServerVersion implements Controller.
+func (c *controller) ServerVersion() version.Number {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.serverVersion
+}
+
+// schemaVersion returns the version that selects the deserialization funcs.
+func (c *controller) schemaVersion() version.Number {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.apiVersion
+}
+
+// setServerVersion records the version that the server reported. The
+// deserialization funcs are chosen by the newest version that the server
+// is at least, so that funcs added for responses changed by a MAAS release
+// are used with it and those after it, unless the args pin the version.
+func (c *controller) setServerVersion(serverVersion version.Number) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serverVersion = serverVersion
+	switch {
+	case c.pinnedVersion != version.Zero:
+		c.apiVersion = c.pinnedVersion
+	case serverVersion.Compare(c.baseAPIVersion) > 0:
+		c.apiVersion = serverVersion
+	default:
+		c.apiVersion = c.baseAPIVersion
+	}
+}
+
+// requireVersion gives an error satisfying errors.IsNotSupported if the
+// server reported a version older than major.minor. Servers that did not
+// report a version are assumed to support the feature.
+func (c *controller) requireVersion(feature string, major, minor int) error {
+	required := version.Number{Major: major, Minor: minor}
+	serverVersion := c.ServerVersion()
+	if serverVersion == version.Zero || serverVersion.Compare(required) >= 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("%s needs MAAS %d.%d or later, the server is %s", feature, major, minor, serverVersion)
+	return errors.NewNotSupported(nil, msg)
 }
 
 // Capabilities implements Controller.
 func (c *controller) Capabilities() set.Strings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.capabilities
 }
 
+// RefreshCapabilities implements Controller.
+func (c *controller) RefreshCapabilities() (set.Strings, error) {
+	capabilities, serverVersion, err := c.readAPIVersionInfo()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	c.setServerVersion(serverVersion)
+	c.mu.Lock()
+	previous := c.capabilities
+	c.capabilities = capabilities
+	c.mu.Unlock()
+
+	added := capabilities.Difference(previous)
+	removed := previous.Difference(capabilities)
+	if c.capabilitiesChanged != nil && (!added.IsEmpty() || !removed.IsEmpty()) {
+		controllerLogger.Debugf("capabilities changed, added %v, removed %v", added.SortedValues(), removed.SortedValues())
+		c.capabilitiesChanged(added, removed)
+	}
+	return capabilities, nil
+}
+
 // BootResources implements Controller.
 func (c *controller) BootResources() ([]BootResource, error) {
-	source, err := c.get("boot-resources")
+	source, err := c.get(BootResourcesPath)
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	resources, err := readBootResources(c.apiVersion, source)
+	resources, err := readBootResources(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -156,11 +536,11 @@ func (c *controller) BootResources() ([]BootResource, error) {
 
 // Fabrics implements Controller.
 func (c *controller) Fabrics() ([]Fabric, error) {
-	source, err := c.get("fabrics")
+	source, err := c.get(FabricsPath)
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	fabrics, err := readFabrics(c.apiVersion, source)
+	fabrics, err := readFabrics(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -173,11 +553,11 @@ func (c *controller) Fabrics() ([]Fabric, error) {
 
 // Spaces implements Controller.
 func (c *controller) Spaces() ([]Space, error) {
-	source, err := c.get("spaces")
+	source, err := c.get(SpacesPath)
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	spaces, err := readSpaces(c.apiVersion, source)
+	spaces, err := readSpaces(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -190,11 +570,11 @@ func (c *controller) Spaces() ([]Space, error) {
 
 // StaticRoutes implements Controller.
 func (c *controller) StaticRoutes() ([]StaticRoute, error) {
-	source, err := c.get("static-routes")
+	source, err := c.get(StaticRoutesPath)
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	staticRoutes, err := readStaticRoutes(c.apiVersion, source)
+	staticRoutes, err := readStaticRoutes(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -207,11 +587,11 @@ func (c *controller) StaticRoutes() ([]StaticRoute, error) {
 
 // Zones implements Controller.
 func (c *controller) Zones() ([]Zone, error) {
-	source, err := c.get("zones")
+	source, err := c.get(ZonesPath)
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	zones, err := readZones(c.apiVersion, source)
+	zones, err := readZones(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -226,17 +606,18 @@ func (c *controller) Zones() ([]Zone, error) {
 func (c *controller) Pools() ([]Pool, error) {
 	var result []Pool
 
-	source, err := c.get("pools")
+	source, err := c.get(PoolsPath)
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
 
-	pools, err := readPools(c.apiVersion, source)
+	pools, err := readPools(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
 	for _, p := range pools {
+		p.controller = c
 		result = append(result, p)
 	}
 	return result, nil
@@ -244,25 +625,26 @@ func (c *controller) Pools() ([]Pool, error) {
 
 // Domains implements Controller
 func (c *controller) Domains() ([]Domain, error) {
-	source, err := c.get("domains")
+	source, err := c.get(DomainsPath)
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	domains, err := readDomains(c.apiVersion, source)
+	domains, err := readDomains(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 	var result []Domain
 	for _, domain := range domains {
+		domain.controller = c
 		result = append(result, domain)
 	}
 	return result, nil
 }
 
-// DevicesArgs is a argument struct for selecting Devices.
-// Only devices that match the specified criteria are returned.
-type DevicesArgs struct {
-	Hostname     []string
+// NodesArgs is a argument struct for selecting Nodes.
+// Only nodes that match the specified criteria are returned.
+type NodesArgs struct {
+	Hostnames    []string
 	MACAddresses []string
 	SystemIDs    []string
 	Domain       string
@@ -271,32 +653,184 @@ type DevicesArgs struct {
 	AgentName    string
 }
 
-// Devices implements Controller.
-func (c *controller) Devices(args DevicesArgs) ([]Device, error) {
+func (a *NodesArgs) params() *URLParams {
 	params := NewURLParams()
-	params.MaybeAddMany("hostname", args.Hostname)
-	params.MaybeAddMany("mac_address", args.MACAddresses)
-	params.MaybeAddMany("id", args.SystemIDs)
-	params.MaybeAdd("domain", args.Domain)
-	params.MaybeAdd("zone", args.Zone)
-	params.MaybeAdd("pool", args.Pool)
-	params.MaybeAdd("agent_name", args.AgentName)
-	source, err := c.getQuery("devices", params.Values)
-	if err != nil {
+	params.MaybeAddMany("hostname", a.Hostnames)
+	params.MaybeAddMany("mac_address", a.MACAddresses)
+	params.MaybeAddMany("id", a.SystemIDs)
+	params.MaybeAdd("domain", a.Domain)
+	params.MaybeAdd("zone", a.Zone)
+	params.MaybeAdd("pool", a.Pool)
+	params.MaybeAdd("agent_name", a.AgentName)
+	return params
+}
+
+// Nodes implements Controller.
+func (c *controller) Nodes(args NodesArgs, options ...ReadOption) ([]GenericNode, error) {
+	source, err := c.getQuery(NodesPath, args.params().Values)
+	if errors.IsNotValid(err) {
+		return nil, errors.Trace(err)
+	} else if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	devices, err := readDevices(c.apiVersion, source)
+	nodes, err := readNodes(c.schemaVersion(), source, options...)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *machine:
+			c.adoptMachine(n)
+		case *device:
+			c.adoptDevice(n)
+		}
+	}
+	return nodes, nil
+}
+
+// DevicesArgs is a argument struct for selecting Devices.
+// Only devices that match the specified criteria are returned.
+type DevicesArgs struct {
+	Hostname     []string
+	MACAddresses []string
+	SystemIDs    []string
+	Domain       string
+	Zone         string
+	Pool         string
+	AgentName    string
+	// Parent, if specified, limits the results to the devices whose parent
+	// is the machine with that system ID. Servers that can't filter on the
+	// parent return all the devices, so the filter is also applied here.
+	Parent string
+}
+
+func (a *DevicesArgs) params() *URLParams {
+	params := NewURLParams()
+	params.MaybeAddMany("hostname", a.Hostname)
+	params.MaybeAddMany("mac_address", a.MACAddresses)
+	params.MaybeAddMany("id", a.SystemIDs)
+	params.MaybeAdd("domain", a.Domain)
+	params.MaybeAdd("zone", a.Zone)
+	params.MaybeAdd("pool", a.Pool)
+	params.MaybeAdd("agent_name", a.AgentName)
+	params.MaybeAdd("parent", a.Parent)
+	return params
+}
+
+func (a *DevicesArgs) matches(d *device) bool {
+	return a.Parent == "" || d.parent == a.Parent
+}
+
+// Devices implements Controller.
+//
+// If the query is too long, the hostnames and system IDs are split across
+// multiple requests, and the results are merged.
+func (c *controller) Devices(args DevicesArgs, options ...ReadOption) ([]Device, error) {
+	var devices []*device
+	for _, params := range c.splitQuery(args.params().Values, "id", "hostname") {
+		source, err := c.getQuery(DevicesPath, params)
+		if errors.IsNotValid(err) {
+			return nil, errors.Trace(err)
+		} else if err != nil {
+			return nil, NewUnexpectedError(err)
+		}
+		batch, err := readDevices(c.schemaVersion(), source, options...)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		devices = append(devices, batch...)
+	}
 	var result []Device
 	for _, d := range devices {
-		d.controller = c
+		if !args.matches(d) {
+			continue
+		}
+		c.adoptDevice(d)
 		result = append(result, d)
 	}
 	return result, nil
 }
 
+// VisitDevices implements Controller.
+//
+// Rather than reading the whole response and building the list of devices,
+// each element is decoded from the response as it arrives and passed to
+// visit in turn. ControllerArgs.MaxResponseSize limits the whole response,
+// and MaxDecodeMemory each element. Long queries are split as they are by
+// Devices.
+func (c *controller) VisitDevices(args DevicesArgs, visit func(Device) error, options ...ReadOption) error {
+	readFunc, err := getDeviceDeserializationFunc(c.schemaVersion())
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, params := range c.splitQuery(args.params().Values, "id", "hostname") {
+		if err := c.visitDevices(args, params, readFunc, visit, options); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func (c *controller) visitDevices(args DevicesArgs, params url.Values, readFunc deviceDeserializationFunc, visit func(Device) error, options []ReadOption) error {
+	if err := c.checkQueryLength(DevicesPath, params); err != nil {
+		return errors.Trace(err)
+	}
+	path := c.requestPath(DevicesPath)
+	requestID := nextRequestID()
+	httpLogger.Tracef("request %x: GET %s%s?%s", requestID, c.client.APIURL, path, c.logParams(params))
+	stream, err := c.client.GetReader(&url.URL{Path: path, RawQuery: params.Encode()}, 0)
+	if err != nil {
+		httpLogger.Tracef("response %x: error: %q", requestID, err.Error())
+		return NewUnexpectedError(err)
+	}
+	httpLogger.Tracef("response %x: streamed", requestID)
+	defer stream.Close()
+	decoder := json.NewDecoder(limitStream(stream, c.client.MaxResponseSize))
+	token, err := decoder.Token()
+	if IsTooLargeError(err) {
+		return NewUnexpectedError(err)
+	} else if err != nil {
+		return WrapWithDeserializationError(err, "device base schema check failed")
+	}
+	if token != json.Delim('[') {
+		return NewDeserializationError("device base schema check failed: expected list, got %v", token)
+	}
+	readOptions := collectReadOptions(options)
+	for i := 0; decoder.More(); i++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); IsTooLargeError(err) {
+			return NewUnexpectedError(err)
+		} else if err != nil {
+			return atPath(WrapWithDeserializationError(err, "device %d", i), joinPath("devices", indexPath(i)))
+		}
+		if err := checkDecodeMemory(raw, c.maxDecodeMemory); err != nil {
+			return NewUnexpectedError(err)
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return atPath(WrapWithDeserializationError(err, "device %d", i), joinPath("devices", indexPath(i)))
+		}
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return atPath(NewDeserializationError("unexpected value for device %d, %T", i, value), joinPath("devices", indexPath(i)))
+		}
+		device, err := readFunc(source, readOptions)
+		if skip, err := readOptions.item(err, "device", i, joinPath("devices", indexPath(i))); err != nil {
+			return err
+		} else if skip {
+			continue
+		}
+		if !args.matches(device) {
+			continue
+		}
+		c.adoptDevice(device)
+		if err := visit(device); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
 // CreateDeviceArgs is a argument struct for passing information into CreateDevice.
 type CreateDeviceArgs struct {
 	Hostname     string
@@ -316,7 +850,7 @@ func (c *controller) CreateDevice(args CreateDeviceArgs) (Device, error) {
 	params.MaybeAdd("domain", args.Domain)
 	params.MaybeAddMany("mac_addresses", args.MACAddresses)
 	params.MaybeAdd("parent", args.Parent)
-	result, err := c.post("devices", "", params.Values)
+	result, err := c.post(DevicesPath, "", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
 			if svrErr.StatusCode == http.StatusBadRequest {
@@ -327,11 +861,11 @@ func (c *controller) CreateDevice(args CreateDeviceArgs) (Device, error) {
 		return nil, NewUnexpectedError(err)
 	}
 
-	device, err := readDevice(c.apiVersion, result)
+	device, err := readDevice(c.schemaVersion(), result)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	device.controller = c
+	c.adoptDevice(device)
 	return device, nil
 }
 
@@ -346,36 +880,111 @@ type MachinesArgs struct {
 	Pool         string
 	AgentName    string
 	OwnerData    map[string]string
+	// NodeTypes, if specified, limits the results to machines of those
+	// node types. Controllers that are also machines are returned by the
+	// machines endpoint, so NodeTypes: []NodeType{NodeTypeMachine} excludes
+	// them.
+	NodeTypes []NodeType
+	// InterfaceTags, if specified, limits the results to machines with an
+	// interface that has all of the tags. The server does not filter on
+	// interface tags, so the machines are filtered after they are read.
+	InterfaceTags []string
 }
 
 // Machines implements Controller.
-func (c *controller) Machines(args MachinesArgs) ([]Machine, error) {
+//
+// If more than maxSystemIDsPerRequest system IDs are specified, or the query
+// is too long, the system IDs and hostnames are split across multiple
+// requests to keep the URL length reasonable, and the results are merged.
+func (c *controller) Machines(args MachinesArgs, options ...ReadOption) ([]Machine, error) {
+	var machines []*machine
+	for _, systemIDs := range splitSystemIDs(args.SystemIDs) {
+		batch, err := c.readMachinesBatch(args, systemIDs, options)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		machines = append(machines, batch...)
+	}
+	var result []Machine
+	for _, m := range machines {
+		c.adoptMachine(m)
+		if ownerDataMatches(m.ownerData, args.OwnerData) && nodeTypeMatches(m.nodeType, args.NodeTypes) && interfaceTagsMatch(m.interfaceSet, args.InterfaceTags) {
+			result = append(result, m)
+		}
+	}
+	return result, nil
+}
+
+func (c *controller) readMachinesBatch(args MachinesArgs, systemIDs []string, options []ReadOption) ([]*machine, error) {
 	params := NewURLParams()
 	params.MaybeAddMany("hostname", args.Hostnames)
 	params.MaybeAddMany("mac_address", args.MACAddresses)
-	params.MaybeAddMany("id", args.SystemIDs)
+	params.MaybeAddMany("id", systemIDs)
 	params.MaybeAdd("domain", args.Domain)
 	params.MaybeAdd("zone", args.Zone)
 	params.MaybeAdd("pool", args.Pool)
 	params.MaybeAdd("agent_name", args.AgentName)
 	// At the moment the MAAS API doesn't support filtering by owner
-	// data so we do that ourselves below.
-	source, err := c.getQuery("machines", params.Values)
-	if err != nil {
-		return nil, NewUnexpectedError(err)
+	// data so we do that ourselves in Machines.
+	var machines []*machine
+	for _, query := range c.splitQuery(params.Values, "id", "hostname") {
+		source, err := c.getQuery(MachinesPath, query)
+		if errors.IsNotValid(err) {
+			return nil, errors.Trace(err)
+		} else if err != nil {
+			return nil, NewUnexpectedError(err)
+		}
+		batch, err := readMachines(c.schemaVersion(), source, options...)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		machines = append(machines, batch...)
 	}
-	machines, err := readMachines(c.apiVersion, source)
-	if err != nil {
-		return nil, errors.Trace(err)
+	return machines, nil
+}
+
+// splitSystemIDs breaks the system IDs into batches of at most
+// maxSystemIDsPerRequest values. There is always at least one batch, so that
+// a query with no system IDs is still made.
+func splitSystemIDs(systemIDs []string) [][]string {
+	if len(systemIDs) <= maxSystemIDsPerRequest {
+		return [][]string{systemIDs}
+	}
+	var result [][]string
+	for len(systemIDs) > 0 {
+		size := maxSystemIDsPerRequest
+		if len(systemIDs) < size {
+			size = len(systemIDs)
+		}
+		result = append(result, systemIDs[:size])
+		systemIDs = systemIDs[size:]
 	}
-	var result []Machine
-	for _, m := range machines {
-		m.controller = c
-		if ownerDataMatches(m.ownerData, args.OwnerData) {
-			result = append(result, m)
+	return result
+}
+
+func nodeTypeMatches(nodeType NodeType, filter []NodeType) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, value := range filter {
+		if value == nodeType {
+			return true
 		}
 	}
-	return result, nil
+	return false
+}
+
+func interfaceTagsMatch(interfaces []*interface_, tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	required := set.NewStrings(tags...)
+	for _, iface := range interfaces {
+		if required.Difference(set.NewStrings(iface.tags...)).IsEmpty() {
+			return true
+		}
+	}
+	return false
 }
 
 func ownerDataMatches(ownerData, filter map[string]string) bool {
@@ -400,15 +1009,22 @@ type StorageSpec struct {
 }
 
 // Validate ensures that there is a positive size and that there are no Empty
-// tag values.
+// tag values. The label and tags cannot contain the characters that
+// separate them in the storage constraint, such as "root:32(ssd),data:100".
 func (s *StorageSpec) Validate() error {
 	if s.Size <= 0 {
 		return errors.NotValidf("Size value %d", s.Size)
 	}
+	if strings.ContainsAny(s.Label, ":,()") {
+		return errors.NotValidf("label %q", s.Label)
+	}
 	for _, v := range s.Tags {
 		if v == "" {
 			return errors.NotValidf("empty tag")
 		}
+		if strings.ContainsAny(v, ",()") {
+			return errors.NotValidf("tag %q", v)
+		}
 	}
 	return nil
 }
@@ -433,35 +1049,111 @@ type InterfaceSpec struct {
 	// The label is returned in the ConstraintMatches response from
 	// AllocateMachine.
 	Label string
-	Space string
+
+	// Space, Fabric, FabricClass and Subnet are what the interface must
+	// be connected to, and the Not fields what it must not be. Subnet
+	// takes a subnet specifier, such as a name or "cidr:10.0.0.0/24".
+	Space          string
+	NotSpace       []string
+	Fabric         string
+	NotFabric      []string
+	FabricClass    string
+	NotFabricClass []string
+	Subnet         string
+	NotSubnet      []string
 
 	// NOTE: there are other interface spec values that we are not exposing at
 	// this stage that can be added on an as needed basis. Other possible values are:
-	//     'fabric_class', 'not_fabric_class',
-	//     'subnet_cidr', 'not_subnet_cidr',
 	//     'vid', 'not_vid',
-	//     'fabric', 'not_fabric',
-	//     'subnet', 'not_subnet',
 	//     'mode'
 }
 
+// values returns the constraints of the spec as key=value pairs, in the
+// order of the fields.
+func (a *InterfaceSpec) values() []string {
+	var values []string
+	add := func(key string, value ...string) {
+		for _, v := range value {
+			if v != "" {
+				values = append(values, key+"="+v)
+			}
+		}
+	}
+	add("space", a.Space)
+	add("not_space", a.NotSpace...)
+	add("fabric", a.Fabric)
+	add("not_fabric", a.NotFabric...)
+	add("fabric_class", a.FabricClass)
+	add("not_fabric_class", a.NotFabricClass...)
+	add("subnet", a.Subnet)
+	add("not_subnet", a.NotSubnet...)
+	return values
+}
+
 // Validate ensures that a Label is specified and that there is at least one
-// Space or NotSpace value set.
+// constraint. The label and values cannot contain the characters that
+// separate them in the interfaces constraint.
 func (a *InterfaceSpec) Validate() error {
 	if a.Label == "" {
 		return errors.NotValidf("missing Label")
 	}
-	// Perhaps at some stage in the future there will be other possible specs
-	// supported (like vid, subnet, etc), but until then, just space to check.
-	if a.Space == "" {
-		return errors.NotValidf("empty Space constraint")
+	if strings.ContainsAny(a.Label, ":,;=") {
+		return errors.NotValidf("label %q", a.Label)
+	}
+	values := a.values()
+	if len(values) == 0 {
+		return errors.NotValidf("empty interface constraint")
+	}
+	for _, v := range values {
+		// Only the first = separates the key from the value.
+		if strings.ContainsAny(v, ",;") || strings.Count(v, "=") > 1 {
+			return errors.NotValidf("constraint %q", v)
+		}
 	}
 	return nil
 }
 
 // String returns the interface spec as MaaS requires it.
 func (a *InterfaceSpec) String() string {
-	return fmt.Sprintf("%s:space=%s", a.Label, a.Space)
+	return a.Label + ":" + strings.Join(a.values(), ",")
+}
+
+// NodeDeviceSpec represents one element of the devices constraint, which
+// matches PCI or USB devices attached to the machine, such as GPUs.
+type NodeDeviceSpec struct {
+	// Label is required and an arbitrary string. Labels need to be unique
+	// across the NodeDeviceSpec elements specified in the
+	// AllocateMachineArgs. The label is returned in the ConstraintMatches
+	// response from AllocateMachine.
+	Label string
+	// VendorID and ProductID are the hexadecimal IDs of the device, such
+	// as "10de" for NVIDIA. At least one of them is required.
+	VendorID  string
+	ProductID string
+}
+
+// Validate ensures that a Label is specified and that there is a vendor
+// or product ID to match.
+func (a *NodeDeviceSpec) Validate() error {
+	if a.Label == "" {
+		return errors.NotValidf("missing Label")
+	}
+	if a.VendorID == "" && a.ProductID == "" {
+		return errors.NotValidf("empty device constraint")
+	}
+	return nil
+}
+
+// String returns the device spec as MaaS requires it.
+func (a *NodeDeviceSpec) String() string {
+	var values []string
+	if a.VendorID != "" {
+		values = append(values, "vendor_id="+a.VendorID)
+	}
+	if a.ProductID != "" {
+		values = append(values, "product_id="+a.ProductID)
+	}
+	return a.Label + ":" + strings.Join(values, ",")
 }
 
 // AllocateMachineArgs is an argument struct for passing args into Machine.Allocate.
@@ -484,6 +1176,11 @@ type AllocateMachineArgs struct {
 	// Interfaces represents a number of required interfaces on the machine.
 	// Each InterfaceSpec relates to an individual network interface.
 	Interfaces []InterfaceSpec
+	// NodeDevices represents PCI or USB devices required on the machine.
+	// Servers that can't match devices ignore the constraint, so when it
+	// isn't reported as matched the machine is released again and the
+	// error satisfies errors.IsNotSupported.
+	NodeDevices []NodeDeviceSpec
 	// NotSpace is a machine level constraint, and applies to the entire machine
 	// rather than specific interfaces.
 	NotSpace  []string
@@ -518,6 +1215,16 @@ func (a *AllocateMachineArgs) Validate() error {
 		}
 		interfaceLabels.Add(spec.Label)
 	}
+	deviceLabels := set.NewStrings()
+	for _, spec := range a.NodeDevices {
+		if err := spec.Validate(); err != nil {
+			return errors.Annotate(err, "NodeDevices")
+		}
+		if deviceLabels.Contains(spec.Label) {
+			return errors.NotValidf("reusing device label %q", spec.Label)
+		}
+		deviceLabels.Add(spec.Label)
+	}
 	for _, v := range a.NotSpace {
 		if v == "" {
 			return errors.NotValidf("empty NotSpace constraint")
@@ -542,6 +1249,14 @@ func (a *AllocateMachineArgs) interfaces() string {
 	return strings.Join(values, ";")
 }
 
+func (a *AllocateMachineArgs) nodeDevices() string {
+	var values []string
+	for _, spec := range a.NodeDevices {
+		values = append(values, spec.String())
+	}
+	return strings.Join(values, ";")
+}
+
 func (a *AllocateMachineArgs) notSubnets() []string {
 	var values []string
 	for _, v := range a.NotSpace {
@@ -561,6 +1276,10 @@ type ConstraintMatches struct {
 	// Storage is a mapping of the constraint label specified to the StorageDevice
 	// that match that constraint.
 	Storage map[string][]StorageDevice
+
+	// NodeDevices is a mapping of the constraint label specified to the IDs
+	// of the node devices that match that constraint.
+	NodeDevices map[string][]int
 }
 
 // AllocateMachine implements Controller.
@@ -579,6 +1298,7 @@ func (c *controller) AllocateMachine(args AllocateMachineArgs) (Machine, Constra
 	params.MaybeAddMany("not_tags", args.NotTags)
 	params.MaybeAdd("storage", args.storage())
 	params.MaybeAdd("interfaces", args.interfaces())
+	params.MaybeAdd("devices", args.nodeDevices())
 	params.MaybeAddMany("not_subnets", args.notSubnets())
 	params.MaybeAdd("zone", args.Zone)
 	params.MaybeAdd("pool", args.Pool)
@@ -587,7 +1307,7 @@ func (c *controller) AllocateMachine(args AllocateMachineArgs) (Machine, Constra
 	params.MaybeAdd("agent_name", args.AgentName)
 	params.MaybeAdd("comment", args.Comment)
 	params.MaybeAddBool("dry_run", args.DryRun)
-	result, err := c.post("machines", "allocate", params.Values)
+	result, err := c.post(MachinesPath, "allocate", params.Values)
 	if err != nil {
 		// A 409 Status code is "No Matching Machines"
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
@@ -599,65 +1319,196 @@ func (c *controller) AllocateMachine(args AllocateMachineArgs) (Machine, Constra
 		return nil, matches, NewUnexpectedError(err)
 	}
 
-	machine, err := readMachine(c.apiVersion, result)
+	machine, err := readMachine(c.schemaVersion(), result)
 	if err != nil {
 		return nil, matches, errors.Trace(err)
 	}
-	machine.controller = c
+	c.adoptMachine(machine)
 
 	// Parse the constraint matches.
 	matches, err = parseAllocateConstraintsResponse(result, machine)
 	if err != nil {
 		return nil, matches, errors.Trace(err)
 	}
+	if len(args.NodeDevices) > 0 && len(matches.NodeDevices) == 0 {
+		if !args.DryRun {
+			err := c.ReleaseMachines(ReleaseMachinesArgs{
+				SystemIDs: []string{machine.SystemID()},
+				Comment:   "devices constraint not supported",
+			})
+			if err != nil {
+				controllerLogger.Warningf("releasing %s: %v", machine.SystemID(), err)
+			}
+		}
+		return nil, ConstraintMatches{}, errors.NotSupportedf("devices constraint")
+	}
 
 	return machine, matches, nil
 }
 
+// AcquireMachine implements Controller.
+func (c *controller) AcquireMachine(systemID, comment string) (Machine, error) {
+	if systemID == "" {
+		return nil, errors.NotValidf("missing system ID")
+	}
+	machine, _, err := c.AllocateMachine(AllocateMachineArgs{
+		SystemId: systemID,
+		Comment:  comment,
+	})
+	if err == nil {
+		return machine, nil
+	}
+	if !IsNoMatchError(err) {
+		return nil, errors.Trace(err)
+	}
+	// The server refuses the allocation in the same way whether the
+	// machine is taken, busy or missing, so look at the machine to say
+	// which.
+	machines, readErr := c.Machines(MachinesArgs{SystemIDs: []string{systemID}})
+	if readErr != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(machines) == 0 {
+		return nil, errors.Wrap(err, NewNoMatchError(fmt.Sprintf("no machine with system ID %q", systemID)))
+	}
+	if owner := machines[0].Owner(); owner != "" {
+		return nil, errors.Wrap(err, NewAlreadyOwnedError(systemID, owner))
+	}
+	msg := fmt.Sprintf("machine %s is %s and cannot be allocated", systemID, machines[0].StatusName())
+	return nil, errors.Wrap(err, NewCannotCompleteError(msg))
+}
+
 // ReleaseMachinesArgs is an argument struct for passing the machine system IDs
 // and an optional comment into the ReleaseMachines method.
 type ReleaseMachinesArgs struct {
 	SystemIDs []string
 	Comment   string
+
+	// Erase erases the disks of the machines before they are released.
+	// SecureErase and QuickErase choose how, and need Erase; if both are
+	// set the server tries a secure erase first.
+	Erase       bool
+	SecureErase bool
+	QuickErase  bool
+
+	// Scripts names the release scripts to run, on servers that support
+	// them. Their results are read with Machine.ScriptResults, using
+	// ScriptResultRelease, once the machines are released.
+	Scripts []string
+}
+
+// Validate ensures that the erase options are consistent.
+func (a ReleaseMachinesArgs) Validate() error {
+	if (a.SecureErase || a.QuickErase) && !a.Erase {
+		return errors.NotValidf("secure or quick erase without erase")
+	}
+	return nil
 }
 
 // ReleaseMachines implements Controller.
 //
-// Release multiple machines at once. Returns
+// Release multiple machines at once. On failure the error is a *BulkError
+// with a result for each of the machines, and its cause is
 //  - BadRequestError if any of the machines cannot be found
 //  - PermissionError if the user does not have permission to release any of the machines
 //  - CannotCompleteError if any of the machines could not be released due to their current state
+//
+// The server releases all of the machines or none of them. When its
+// error names the machines at fault, only those have the error and the
+// others have a NotAttemptedError, so that BulkError.NotAttempted gives
+// the machines to release again.
 func (c *controller) ReleaseMachines(args ReleaseMachinesArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
 	params := NewURLParams()
 	params.MaybeAddMany("machines", args.SystemIDs)
 	params.MaybeAdd("comment", args.Comment)
-	_, err := c.post("machines", "release", params.Values)
+	params.MaybeAddBool("erase", args.Erase)
+	params.MaybeAddBool("secure_erase", args.SecureErase)
+	params.MaybeAddBool("quick_erase", args.QuickErase)
+	params.MaybeAdd("scripts", strings.Join(args.Scripts, ","))
+	_, err := c.post(MachinesPath, "release", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
 			switch svrErr.StatusCode {
 			case http.StatusBadRequest:
-				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+				err = errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
 			case http.StatusForbidden:
-				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+				err = errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
 			case http.StatusConflict:
-				return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+				err = errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+			default:
+				err = NewUnexpectedError(err)
 			}
+		} else {
+			err = NewUnexpectedError(err)
 		}
-		return NewUnexpectedError(err)
+		return releaseError(args.SystemIDs, err)
+	}
+	for _, systemID := range args.SystemIDs {
+		c.markStale(systemID, "released")
 	}
-
 	return nil
 }
 
+// releaseError returns the BulkError for a release of the machines that
+// failed with err. The server names the machines at fault at the end of
+// its message, as in "Unknown machine(s): 4y3ha3, 4y3ha6.", for unknown
+// machines, ones the user may not release, and ones in the wrong state.
+func releaseError(systemIDs []string, err error) error {
+	svrErr, ok := findServerError(err)
+	if !ok {
+		return bulkErrorForAll(systemIDs, err)
+	}
+	named := machinesInMessage(svrErr.BodyMessage, systemIDs)
+	if len(named) == 0 || len(named) == len(systemIDs) {
+		return bulkErrorForAll(systemIDs, err)
+	}
+	notAttempted := NewNotAttemptedError(fmt.Sprintf("not released: %s", svrErr.BodyMessage))
+	result := NewBulkError()
+	for _, systemID := range systemIDs {
+		if named.Contains(systemID) {
+			result.Add(systemID, err)
+		} else {
+			result.Add(systemID, notAttempted)
+		}
+	}
+	return result
+}
+
+// machinesInMessage returns the system IDs listed after the last colon of
+// the message. If anything listed is not one of the systemIDs the message
+// is not a list of machines, and the result is empty.
+func machinesInMessage(message string, systemIDs []string) set.Strings {
+	result := set.NewStrings()
+	pos := strings.LastIndex(message, ":")
+	if pos < 0 {
+		return result
+	}
+	requested := set.NewStrings(systemIDs...)
+	list := strings.TrimSuffix(strings.TrimSpace(message[pos+1:]), ".")
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if !requested.Contains(name) {
+			return set.NewStrings()
+		}
+		result.Add(name)
+	}
+	return result
+}
+
 // Files implements Controller.
 func (c *controller) Files(prefix string) ([]File, error) {
 	params := NewURLParams()
 	params.MaybeAdd("prefix", prefix)
-	source, err := c.getQuery("files", params.Values)
-	if err != nil {
+	source, err := c.getQuery(FilesPath, params.Values)
+	if errors.IsNotValid(err) {
+		return nil, errors.Trace(err)
+	} else if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	files, err := readFiles(c.apiVersion, source)
+	files, err := readFiles(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -674,7 +1525,7 @@ func (c *controller) GetFile(filename string) (File, error) {
 	if filename == "" {
 		return nil, errors.NotValidf("missing filename")
 	}
-	source, err := c.get("files/" + filename)
+	source, err := c.get(APIPath(FilesPath, filename))
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
 			if svrErr.StatusCode == http.StatusNotFound {
@@ -683,7 +1534,7 @@ func (c *controller) GetFile(filename string) (File, error) {
 		}
 		return nil, NewUnexpectedError(err)
 	}
-	file, err := readFile(c.apiVersion, source)
+	file, err := readFile(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -691,6 +1542,10 @@ func (c *controller) GetFile(filename string) (File, error) {
 	return file, nil
 }
 
+// defaultUploadAttempts is the number of times AddFile uploads a file whose
+// stored content does not match the SHA256 given.
+const defaultUploadAttempts = 3
+
 // AddFileArgs is a argument struct for passing information into AddFile.
 // One of Content or (Reader, Length) must be specified.
 type AddFileArgs struct {
@@ -698,6 +1553,15 @@ type AddFileArgs struct {
 	Content  []byte
 	Reader   io.Reader
 	Length   int64
+
+	// SHA256, if specified, is the hex encoded SHA-256 digest of the
+	// content. The stored file is read back after the upload and the
+	// upload is retried if the digests differ.
+	SHA256 string
+	// Attempts is the number of uploads to try when SHA256 is specified.
+	// Zero means three. A Reader that is not an io.Seeker is only
+	// uploaded once, as its content is not kept.
+	Attempts int
 }
 
 // Validate checks to make sure the filename has no slashes, and that one of
@@ -725,6 +1589,14 @@ func (a *AddFileArgs) Validate() error {
 			return errors.NotValidf("specifying Length and Content")
 		}
 	}
+	if a.SHA256 != "" {
+		if digest, err := hex.DecodeString(a.SHA256); err != nil || len(digest) != sha256.Size {
+			return errors.NotValidf("SHA256 %q", a.SHA256)
+		}
+	}
+	if a.Attempts < 0 {
+		return errors.NotValidf("negative Attempts")
+	}
 	return nil
 }
 
@@ -733,16 +1605,60 @@ func (c *controller) AddFile(args AddFileArgs) error {
 	if err := args.Validate(); err != nil {
 		return errors.Trace(err)
 	}
-	fileContent := args.Content
-	if fileContent == nil {
-		content, err := ioutil.ReadAll(io.LimitReader(args.Reader, args.Length))
+	content, length := args.Reader, args.Length
+	if args.Content != nil {
+		content, length = bytes.NewReader(args.Content), int64(len(args.Content))
+	}
+	if args.SHA256 == "" {
+		return c.uploadFile(args.Filename, content, length)
+	}
+
+	attempts := args.Attempts
+	if attempts == 0 {
+		attempts = defaultUploadAttempts
+	}
+	expected := strings.ToLower(args.SHA256)
+	// The content is streamed, so it can only be uploaded again if it
+	// can be read again.
+	seeker, seekable := content.(io.Seeker)
+	if !seekable {
+		attempts = 1
+	}
+	var start int64
+	if seekable {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
 		if err != nil {
 			return errors.Annotatef(err, "cannot read file content")
 		}
-		fileContent = content
+		start = offset
 	}
-	params := url.Values{"filename": {args.Filename}}
-	_, err := c.postFile("files", "", params, fileContent)
+	var actual string
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return errors.Annotatef(err, "cannot read file content")
+			}
+		}
+		if err := c.uploadFile(args.Filename, content, length); err != nil {
+			return errors.Trace(err)
+		}
+		digest, err := c.storedFileSHA256(args.Filename)
+		if err != nil {
+			return errors.Annotatef(err, "cannot verify %q", args.Filename)
+		}
+		if digest == expected {
+			return nil
+		}
+		actual = digest
+		controllerLogger.Warningf("upload %d of %q stored content with SHA256 %s, expected %s", i+1, args.Filename, actual, expected)
+	}
+	return NewCannotCompleteError(fmt.Sprintf(
+		"%q stored with SHA256 %s after %d attempts, expected %s", args.Filename, actual, attempts, expected))
+}
+
+func (c *controller) uploadFile(filename string, content io.Reader, length int64) error {
+	params := url.Values{"filename": {filename}}
+	_, err := c.postFile(FilesPath, "", params, content, length)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
 			if svrErr.StatusCode == http.StatusBadRequest {
@@ -754,8 +1670,42 @@ func (c *controller) AddFile(args AddFileArgs) error {
 	return nil
 }
 
+func (c *controller) storedFileSHA256(filename string) (string, error) {
+	file, err := c.GetFile(filename)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	digest, err := file.SHA256()
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return digest, nil
+}
+
+// ReplayJournal implements Controller.
+func (c *controller) ReplayJournal(confirm func(JournalEntry) ReplayDecision) (int, error) {
+	if c.client.Journal == nil {
+		return 0, errors.NotValidf("controller without a Journal")
+	}
+	sent, err := c.client.Journal.Replay(*c.client, confirm)
+	return sent, errors.Trace(err)
+}
+
+// ClockSkew implements Controller.
+func (c *controller) ClockSkew() (time.Duration, error) {
+	clk := c.client.clock()
+	before := clk.Now()
+	serverTime, err := c.client.ServerTime()
+	if err != nil {
+		return 0, NewUnexpectedError(err)
+	}
+	after := clk.Now()
+	local := before.Add(after.Sub(before) / 2)
+	return serverTime.Sub(local).Truncate(time.Second), nil
+}
+
 func (c *controller) checkCreds() error {
-	if _, err := c.getOp("users", "whoami"); err != nil {
+	if _, err := c.getOp(UsersPath, "whoami"); err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
 			if svrErr.StatusCode == http.StatusUnauthorized {
 				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
@@ -767,19 +1717,18 @@ func (c *controller) checkCreds() error {
 }
 
 func (c *controller) put(path string, params url.Values) (interface{}, error) {
-	path = EnsureTrailingSlash(path)
+	path = c.requestPath(path)
 	requestID := nextRequestID()
-	logger.Tracef("request %x: PUT %s%s, params: %s", requestID, c.client.APIURL, path, params.Encode())
+	httpLogger.Tracef("request %x: PUT %s%s, params: %s", requestID, c.client.APIURL, path, c.logParams(params))
 	bytes, err := c.client.Put(&url.URL{Path: path}, params)
 	if err != nil {
-		logger.Tracef("response %x: error: %q", requestID, err.Error())
-		logger.Tracef("error detail: %#v", err)
+		httpLogger.Tracef("response %x: error: %q", requestID, err.Error())
+		httpLogger.Tracef("error detail: %#v", err)
 		return nil, errors.Trace(err)
 	}
-	logger.Tracef("response %x: %s", requestID, string(bytes))
+	httpLogger.Tracef("response %x: %s", requestID, c.logBody(bytes))
 
-	var parsed interface{}
-	err = json.Unmarshal(bytes, &parsed)
+	parsed, err := c.parseResponse(bytes)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -787,63 +1736,134 @@ func (c *controller) put(path string, params url.Values) (interface{}, error) {
 }
 
 func (c *controller) post(path, op string, params url.Values) (interface{}, error) {
-	bytes, err := c._postRaw(path, op, params, nil)
+	bytes, err := c._postRaw(path, op, params)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
-	var parsed interface{}
-	err = json.Unmarshal(bytes, &parsed)
+	parsed, err := c.parseResponse(bytes)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 	return parsed, nil
 }
 
-func (c *controller) postFile(path, op string, params url.Values, fileContent []byte) (interface{}, error) {
-	// Only one file is ever sent at a time.
-	files := map[string][]byte{"file": fileContent}
-	return c._postRaw(path, op, params, files)
+// postFile sends the content as the file of the request without holding it
+// in memory, see Client.PostFile.
+func (c *controller) postFile(path, op string, params url.Values, content io.Reader, length int64) ([]byte, error) {
+	path = c.requestPath(path)
+	requestID := nextRequestID()
+	httpLogger.Tracef("request %x: POST %s%s?op=%s, params=%s, %d bytes of file", requestID, c.client.APIURL, path, op, c.logParams(params), length)
+	bytes, err := c.client.PostFile(&url.URL{Path: path}, op, params, "file", content, length)
+	if err != nil {
+		httpLogger.Tracef("response %x: error: %q", requestID, err.Error())
+		httpLogger.Tracef("error detail: %#v", err)
+		return nil, errors.Trace(err)
+	}
+	httpLogger.Tracef("response %x: %s", requestID, c.logBody(bytes))
+	return bytes, nil
 }
 
-func (c *controller) _postRaw(path, op string, params url.Values, files map[string][]byte) ([]byte, error) {
-	path = EnsureTrailingSlash(path)
+func (c *controller) _postRaw(path, op string, params url.Values) ([]byte, error) {
+	path = c.requestPath(path)
 	requestID := nextRequestID()
-	if logger.IsTraceEnabled() {
+	if httpLogger.IsTraceEnabled() {
 		opArg := ""
 		if op != "" {
 			opArg = "?op=" + op
 		}
-		logger.Tracef("request %x: POST %s%s%s, params=%s", requestID, c.client.APIURL, path, opArg, params.Encode())
+		httpLogger.Tracef("request %x: POST %s%s%s, params=%s", requestID, c.client.APIURL, path, opArg, c.logParams(params))
 	}
-	bytes, err := c.client.Post(&url.URL{Path: path}, op, params, files)
+	bytes, err := c.client.Post(&url.URL{Path: path}, op, params, nil)
+	if err != nil {
+		httpLogger.Tracef("response %x: error: %q", requestID, err.Error())
+		httpLogger.Tracef("error detail: %#v", err)
+		return nil, errors.Trace(err)
+	}
+	httpLogger.Tracef("response %x: %s", requestID, c.logBody(bytes))
+	return bytes, nil
+}
+
+func (c *controller) putContent(path string, content []byte) ([]byte, error) {
+	path = c.requestPath(path)
+	requestID := nextRequestID()
+	httpLogger.Tracef("request %x: PUT %s%s, %d bytes", requestID, c.client.APIURL, path, len(content))
+	bytes, err := c.client.PutContent(&url.URL{Path: path}, content)
 	if err != nil {
-		logger.Tracef("response %x: error: %q", requestID, err.Error())
-		logger.Tracef("error detail: %#v", err)
+		httpLogger.Tracef("response %x: error: %q", requestID, err.Error())
+		httpLogger.Tracef("error detail: %#v", err)
 		return nil, errors.Trace(err)
 	}
-	logger.Tracef("response %x: %s", requestID, string(bytes))
+	httpLogger.Tracef("response %x: %s", requestID, c.logBody(bytes))
 	return bytes, nil
 }
 
 func (c *controller) delete(path string) error {
-	path = EnsureTrailingSlash(path)
+	path = c.requestPath(path)
 	requestID := nextRequestID()
-	logger.Tracef("request %x: DELETE %s%s", requestID, c.client.APIURL, path)
+	httpLogger.Tracef("request %x: DELETE %s%s", requestID, c.client.APIURL, path)
 	err := c.client.Delete(&url.URL{Path: path})
 	if err != nil {
-		logger.Tracef("response %x: error: %q", requestID, err.Error())
-		logger.Tracef("error detail: %#v", err)
+		httpLogger.Tracef("response %x: error: %q", requestID, err.Error())
+		httpLogger.Tracef("error detail: %#v", err)
 		return errors.Trace(err)
 	}
-	logger.Tracef("response %x: complete", requestID)
+	httpLogger.Tracef("response %x: complete", requestID)
 	return nil
 }
 
+// getQuery performs a GET with the specified query parameters. If the encoded
+// query is longer than the controller's limit, an error satisfying
+// errors.IsNotValid is returned without contacting the server. None of the
+// MAAS read operations accept the equivalent query as a POST, so callers that
+// may build long queries need to split them up, as Machines and Devices do
+// with splitQuery.
 func (c *controller) getQuery(path string, params url.Values) (interface{}, error) {
+	if err := c.checkQueryLength(path, params); err != nil {
+		return nil, errors.Trace(err)
+	}
 	return c._get(path, "", params)
 }
 
+// splitQuery splits the values of the keys, which the server matches any
+// one of, across as many queries as it takes to keep each within the query
+// length limit. The server matches all of the different keys, so the
+// results of the queries add up to those of the whole query without
+// repeating any node, as a node has one hostname and one system ID. A
+// query that is too long with a single value of each key is left for
+// getQuery to reject.
+func (c *controller) splitQuery(params url.Values, keys ...string) []url.Values {
+	if c.maxQueryLength <= 0 || len(params.Encode()) <= c.maxQueryLength {
+		return []url.Values{params}
+	}
+	for _, key := range keys {
+		values := params[key]
+		if len(values) < 2 {
+			continue
+		}
+		half := len(values) / 2
+		var result []url.Values
+		for _, part := range [][]string{values[:half], values[half:]} {
+			query := make(url.Values, len(params))
+			for k, v := range params {
+				query[k] = v
+			}
+			query[key] = part
+			result = append(result, c.splitQuery(query, keys...)...)
+		}
+		return result
+	}
+	return []url.Values{params}
+}
+
+func (c *controller) checkQueryLength(path string, params url.Values) error {
+	if length := len(params.Encode()); c.maxQueryLength > 0 && length > c.maxQueryLength {
+		msg := fmt.Sprintf("query for %q is %d bytes, exceeding the limit of %d bytes", path, length, c.maxQueryLength)
+		return errors.NewNotValid(nil, msg)
+	}
+	return nil
+}
+
 func (c *controller) get(path string) (interface{}, error) {
 	return c._get(path, "", nil)
 }
@@ -857,8 +1877,7 @@ func (c *controller) _get(path, op string, params url.Values) (interface{}, erro
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	var parsed interface{}
-	err = json.Unmarshal(bytes, &parsed)
+	parsed, err := c.parseResponse(bytes)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -866,25 +1885,71 @@ func (c *controller) _get(path, op string, params url.Values) (interface{}, erro
 }
 
 func (c *controller) _getRaw(path, op string, params url.Values) ([]byte, error) {
-	path = EnsureTrailingSlash(path)
+	path = c.requestPath(path)
 	requestID := nextRequestID()
-	if logger.IsTraceEnabled() {
+	if httpLogger.IsTraceEnabled() {
 		var query string
 		if params != nil {
-			query = "?" + params.Encode()
+			query = "?" + c.logParams(params)
 		}
-		logger.Tracef("request %x: GET %s%s%s", requestID, c.client.APIURL, path, query)
+		httpLogger.Tracef("request %x: GET %s%s%s", requestID, c.client.APIURL, path, query)
 	}
 	bytes, err := c.client.Get(&url.URL{Path: path}, op, params)
 	if err != nil {
-		logger.Tracef("response %x: error: %q", requestID, err.Error())
-		logger.Tracef("error detail: %#v", err)
+		httpLogger.Tracef("response %x: error: %q", requestID, err.Error())
+		httpLogger.Tracef("error detail: %#v", err)
 		return nil, errors.Trace(err)
 	}
-	logger.Tracef("response %x: %s", requestID, string(bytes))
+	httpLogger.Tracef("response %x: %s", requestID, c.logBody(bytes))
 	return bytes, nil
 }
 
+// parseResponse decodes the response within the decode memory budget of
+// the controller.
+func (c *controller) parseResponse(data []byte) (interface{}, error) {
+	if err := checkDecodeMemory(data, c.maxDecodeMemory); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return parseResponse(data)
+}
+
+// logParams encodes the params of a request for the trace log, with the
+// values of sensitive fields redacted unless redaction is disabled.
+func (c *controller) logParams(params url.Values) string {
+	if c.client.DisableRedaction {
+		return params.Encode()
+	}
+	return RedactParams(params).Encode()
+}
+
+// logBody returns the body of a response for the trace log, with the
+// values of sensitive fields redacted unless redaction is disabled.
+func (c *controller) logBody(body []byte) string {
+	if c.client.DisableRedaction {
+		return string(body)
+	}
+	return RedactText(string(body))
+}
+
+// requestPath returns the path to send a request for, which has a
+// trailing slash unless the controller was made with NoTrailingSlash.
+func (c *controller) requestPath(path string) string {
+	if !c.noTrailingSlash {
+		path = EnsureTrailingSlash(path)
+	}
+	return c.serverPath(path)
+}
+
+// serverPath rewrites an absolute path returned by the server, such as a
+// resource URI, to go through the path of the BaseURL when the two differ.
+// Relative paths are resolved against the API URL and are left alone.
+func (c *controller) serverPath(path string) string {
+	if c.serverPathPrefix == "" || !strings.HasPrefix(path, c.serverPathPrefix) {
+		return path
+	}
+	return c.basePath + strings.TrimPrefix(path, c.serverPathPrefix)
+}
+
 func nextRequestID() int64 {
 	return atomic.AddInt64(&requestNumber, 1)
 }
@@ -907,25 +1972,29 @@ func indicatesUnsupportedVersion(err error) bool {
 	return false
 }
 
-func (c *controller) readAPIVersionInfo() (set.Strings, error) {
-	parsed, err := c.get("version")
+func (c *controller) readAPIVersionInfo() (set.Strings, version.Number, error) {
+	parsed, err := c.get(VersionPath)
 	if indicatesUnsupportedVersion(err) {
-		return nil, WrapWithUnsupportedVersionError(err)
+		return nil, version.Zero, WrapWithUnsupportedVersionError(err)
 	} else if err != nil {
-		return nil, errors.Trace(err)
+		return nil, version.Zero, errors.Trace(err)
 	}
 
 	// As we care about other fields, add them.
 	fields := schema.Fields{
 		"capabilities": schema.List(schema.String()),
+		"version":      schema.OneOf(schema.Nil(""), schema.String()),
+		"subversion":   schema.OneOf(schema.Nil(""), schema.String()),
 	}
-	checker := schema.FieldMap(fields, nil) // no defaults
+	defaults := schema.Defaults{
+		"version":    "",
+		"subversion": "",
+	}
+	checker := schema.FieldMap(fields, defaults)
 	coerced, err := checker.Coerce(parsed, nil)
 	if err != nil {
-		return nil, WrapWithDeserializationError(err, "version response")
+		return nil, version.Zero, WrapWithDeserializationError(err, "version response")
 	}
-	// For now, we don't append any subversion, but as it becomes used, we
-	// should parse and check.
 
 	valid := coerced.(map[string]interface{})
 	// From here we know that the map returned from the schema coercion
@@ -935,8 +2004,42 @@ func (c *controller) readAPIVersionInfo() (set.Strings, error) {
 	for _, value := range capabilityValues {
 		capabilities.Add(value.(string))
 	}
+	versionValue, _ := valid["version"].(string)
+	subversion, _ := valid["subversion"].(string)
+	return capabilities, parseServerVersion(versionValue, subversion), nil
+}
 
-	return capabilities, nil
+// parseServerVersion reads the version that the server reports, such as
+// "2.9.2" or "3.3.0~beta1". Some releases leave the version as "unknown"
+// and report it in the subversion, as in "2.4.2-7034-g2f5deb8b8-0ubuntu1".
+// Versions that cannot be read give version.Zero.
+func parseServerVersion(value, subversion string) version.Number {
+	for _, candidate := range []string{value, subversion} {
+		end := strings.IndexFunc(candidate, func(r rune) bool {
+			return r != '.' && (r < '0' || r > '9')
+		})
+		if end >= 0 {
+			candidate = candidate[:end]
+		}
+		parts := strings.Split(candidate, ".")
+		if len(parts) < 2 || len(parts) > 3 {
+			continue
+		}
+		var numbers [3]int
+		valid := true
+		for i, part := range parts {
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				valid = false
+				break
+			}
+			numbers[i] = n
+		}
+		if valid {
+			return version.Number{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}
+		}
+	}
+	return version.Zero
 }
 
 func parseAllocateConstraintsResponse(source interface{}, machine *machine) (ConstraintMatches, error) {
@@ -944,10 +2047,12 @@ func parseAllocateConstraintsResponse(source interface{}, machine *machine) (Con
 	matchFields := schema.Fields{
 		"storage":    schema.StringMap(schema.List(schema.Any())),
 		"interfaces": schema.StringMap(schema.List(schema.ForceInt())),
+		"devices":    schema.StringMap(schema.List(schema.ForceInt())),
 	}
 	matchDefaults := schema.Defaults{
 		"storage":    schema.Omit,
 		"interfaces": schema.Omit,
+		"devices":    schema.Omit,
 	}
 	fields := schema.Fields{
 		"constraints_by_type": schema.FieldMap(matchFields, matchDefaults),
@@ -960,8 +2065,13 @@ func parseAllocateConstraintsResponse(source interface{}, machine *machine) (Con
 	valid := coerced.(map[string]interface{})
 	constraintsMap := valid["constraints_by_type"].(map[string]interface{})
 	result := ConstraintMatches{
-		Interfaces: make(map[string][]Interface),
-		Storage:    make(map[string][]StorageDevice),
+		Interfaces:  make(map[string][]Interface),
+		Storage:     make(map[string][]StorageDevice),
+		NodeDevices: make(map[string][]int),
+	}
+
+	if deviceMatches, found := constraintsMap["devices"]; found {
+		result.NodeDevices = convertConstraintMatchesInt(deviceMatches)
 	}
 
 	if interfaceMatches, found := constraintsMap["interfaces"]; found {
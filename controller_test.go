@@ -5,15 +5,27 @@ package gomaasapi
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/juju/collections/set"
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/clock"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
 )
@@ -30,6 +42,25 @@ func (*versionSuite) TestSupportedVersions(c *gc.C) {
 	}
 }
 
+func (*versionSuite) TestParseServerVersion(c *gc.C) {
+	for i, test := range []struct {
+		version    string
+		subversion string
+		expected   version.Number
+	}{
+		{"2.9.2", "", version.MustParse("2.9.2")},
+		{"3.3.0~beta1", "", version.MustParse("3.3.0")},
+		{"2.5", "", version.MustParse("2.5.0")},
+		{"unknown", "2.4.2-7034-g2f5deb8b8-0ubuntu1", version.MustParse("2.4.2")},
+		{"unknown", "", version.Zero},
+		{"", "", version.Zero},
+		{"1.2.3.4", "", version.Zero},
+	} {
+		c.Logf("test %d: %q %q", i, test.version, test.subversion)
+		c.Check(parseServerVersion(test.version, test.subversion), gc.Equals, test.expected)
+	}
+}
+
 type controllerSuite struct {
 	testing.LoggingCleanupSuite
 	server *SimpleTestServer
@@ -68,6 +99,156 @@ func (s *controllerSuite) getController(c *gc.C) Controller {
 	return controller
 }
 
+type countingTransport struct {
+	count int
+}
+
+func (t *countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.count++
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+func (s *controllerSuite) TestNewControllerHTTPClient(c *gc.C) {
+	transport := &countingTransport{}
+	controller, err := NewController(ControllerArgs{
+		BaseURL:    s.server.URL,
+		APIKey:     "fake:as:key",
+		HTTPClient: &http.Client{Transport: transport},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	// The version and whoami requests.
+	c.Check(transport.count, gc.Equals, 2)
+
+	_, err = controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(transport.count, gc.Equals, 3)
+}
+
+// tlsServer returns a server for NewController over HTTPS with a
+// certificate that is not trusted by the system.
+func (s *controllerSuite) tlsServer(c *gc.C) *SimpleTestServer {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.StartTLS()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	return server
+}
+
+func (s *controllerSuite) TestNewControllerUntrustedCertificate(c *gc.C) {
+	server := s.tlsServer(c)
+	_, err := NewController(ControllerArgs{BaseURL: server.URL, APIKey: "fake:as:key"})
+	c.Assert(err, gc.ErrorMatches, `.*certificate signed by unknown authority`)
+}
+
+func (s *controllerSuite) TestNewControllerCACertPEM(c *gc.C) {
+	server := s.tlsServer(c)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	_, err := NewController(ControllerArgs{
+		BaseURL:   server.URL,
+		APIKey:    "fake:as:key",
+		CACertPEM: string(certPEM),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *controllerSuite) TestNewControllerBadCACertPEM(c *gc.C) {
+	_, err := NewController(ControllerArgs{
+		BaseURL:   s.server.URL,
+		APIKey:    "fake:as:key",
+		CACertPEM: "not a certificate",
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *controllerSuite) TestNewControllerSkipTLSVerify(c *gc.C) {
+	server := s.tlsServer(c)
+	_, err := NewController(ControllerArgs{
+		BaseURL:       server.URL,
+		APIKey:        "fake:as:key",
+		SkipTLSVerify: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *controllerSuite) TestNewControllerTLSConfig(c *gc.C) {
+	server := s.tlsServer(c)
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	config := &tls.Config{RootCAs: pool}
+	_, err := NewController(ControllerArgs{
+		BaseURL:   server.URL,
+		APIKey:    "fake:as:key",
+		TLSConfig: config,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The config of the args is not changed.
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	_, err = NewController(ControllerArgs{
+		BaseURL:       server.URL,
+		APIKey:        "fake:as:key",
+		TLSConfig:     config,
+		SkipTLSVerify: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(config.InsecureSkipVerify, jc.IsFalse)
+}
+
+func (s *controllerSuite) TestNewControllerTLSReusesConnections(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+	var mu sync.Mutex
+	connections := 0
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			connections++
+			mu.Unlock()
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	controller, err := NewController(ControllerArgs{
+		BaseURL:    server.URL,
+		APIKey:     "fake:as:key",
+		CACertPEM:  string(certPEM),
+		HTTPClient: &http.Client{Transport: &http.Transport{}},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+
+	mu.Lock()
+	defer mu.Unlock()
+	c.Check(connections, gc.Equals, 1)
+}
+
+func (s *controllerSuite) TestReplayJournalWithoutJournal(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.ReplayJournal(nil)
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *controllerSuite) TestChildLoggers(c *gc.C) {
+	err := loggo.ConfigureLoggers("<root>=WARNING;maas.http=TRACE")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(httpLogger.Name(), gc.Equals, HTTPLoggerName)
+	c.Check(httpLogger.IsTraceEnabled(), jc.IsTrue)
+	c.Check(deserializeLogger.IsDebugEnabled(), jc.IsFalse)
+	c.Check(controllerLogger.IsDebugEnabled(), jc.IsFalse)
+
+	err = loggo.ConfigureLoggers("maas=DEBUG")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(deserializeLogger.IsDebugEnabled(), jc.IsTrue)
+	c.Check(controllerLogger.IsDebugEnabled(), jc.IsTrue)
+}
+
 func (s *controllerSuite) TestNewController(c *gc.C) {
 	controller := s.getController(c)
 
@@ -85,6 +266,358 @@ func (s *controllerSuite) TestNewController(c *gc.C) {
 	c.Assert(expectedCapabilities.Difference(capabilities), gc.HasLen, 0)
 }
 
+func (s *controllerSuite) TestNewControllerDefaultClock(c *gc.C) {
+	controller := s.getController(c).(*controller)
+	c.Check(controller.clock, gc.Equals, clock.WallClock)
+	c.Check(controller.client.Clock, gc.Equals, clock.WallClock)
+}
+
+func (s *controllerSuite) TestNewControllerClock(c *gc.C) {
+	testClock := testing.NewClock(time.Time{})
+	result, err := NewController(ControllerArgs{
+		BaseURL: s.server.URL,
+		APIKey:  "fake:as:key",
+		Clock:   testClock,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	controller := result.(*controller)
+	c.Check(controller.clock, gc.Equals, testClock)
+	c.Check(controller.client.Clock, gc.Equals, testClock)
+}
+
+func (s *controllerSuite) TestNewControllerRetryUnauthorized(c *gc.C) {
+	result, err := NewController(ControllerArgs{
+		BaseURL:           s.server.URL,
+		APIKey:            "fake:as:key",
+		RetryUnauthorized: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.(*controller).client.RetryUnauthorized, jc.IsTrue)
+}
+
+func (s *controllerSuite) TestServerPathPrefix(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/infra/maas/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/infra/maas/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/infra/maas/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	server.AddPostResponse("/infra/maas/api/2.0/machines/4y3ha3/?op=deploy", http.StatusOK, machineResponse)
+	server.AddGetResponse("/infra/maas/api/2.0/files/testing/", http.StatusOK, fileResponse)
+	server.Start()
+	defer server.Close()
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:          server.URL + "/infra/maas",
+		APIKey:           "fake:as:key",
+		ServerPathPrefix: "MAAS",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	err = machines[0].Start(StartArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.LastRequest().URL.Path, gc.Equals, "/infra/maas/api/2.0/machines/4y3ha3/")
+
+	file, err := controller.GetFile("testing")
+	c.Assert(err, jc.ErrorIsNil)
+	uri, err := url.Parse(file.AnonymousURL())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(uri.RequestURI(), gc.Equals, "/infra/maas/api/2.0/files/?op=get_by_key&key=88e64b76-fb82-11e5-932f-52540051bf22")
+}
+
+func (s *controllerSuite) TestServerPathPrefixSameAsBase(c *gc.C) {
+	result, err := NewController(ControllerArgs{
+		BaseURL:          s.server.URL,
+		APIKey:           "fake:as:key",
+		ServerPathPrefix: "/",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	controller := result.(*controller)
+	c.Check(controller.serverPathPrefix, gc.Equals, "")
+	c.Check(controller.serverPath("/MAAS/api/2.0/machines/"), gc.Equals, "/MAAS/api/2.0/machines/")
+}
+
+func (s *controllerSuite) TestNoTrailingSlash(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddPostResponse("/api/2.0/tags?op=", http.StatusOK, tagResponse)
+	server.Start()
+	defer server.Close()
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:         server.URL,
+		APIKey:          "fake:as:key",
+		NoTrailingSlash: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = controller.CreateTag("virtual", "", "")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.LastRequest().URL.Path, gc.Equals, "/api/2.0/tags")
+}
+
+func (s *controllerSuite) TestCheckAPIKeyPermissionsAdmin(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `{"username": "admin", "is_superuser": true}`)
+	s.server.AddGetResponse("/api/2.0/machines/?id=permission-probe", http.StatusOK, "[]")
+	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "No machine available.")
+	s.server.AddGetResponse("/api/2.0/boot-sources/", http.StatusOK, "[]")
+	controller := s.getController(c)
+
+	perms, err := controller.CheckAPIKeyPermissions()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(perms.Username, gc.Equals, "admin")
+	c.Check(perms.Allowed(ScopeRead), jc.IsTrue)
+	c.Check(perms.Allowed(ScopeAllocate), jc.IsTrue)
+	c.Check(perms.Allowed(ScopeAdmin), jc.IsTrue)
+	c.Check(perms.ReadOnly(), jc.IsFalse)
+	c.Check(perms.Require(ScopeRead, ScopeAdmin), jc.ErrorIsNil)
+
+	form := s.server.LastNRequests(2)[0].PostForm
+	c.Check(form.Get("dry_run"), gc.Equals, "true")
+	c.Check(form.Get("system_id"), gc.Equals, "permission-probe")
+}
+
+func (s *controllerSuite) TestCheckAPIKeyPermissionsReadOnly(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"auditor"`)
+	s.server.AddGetResponse("/api/2.0/machines/?id=permission-probe", http.StatusOK, "[]")
+	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusForbidden, "forbidden")
+	s.server.AddGetResponse("/api/2.0/boot-sources/", http.StatusForbidden, "forbidden")
+	controller := s.getController(c)
+
+	perms, err := controller.CheckAPIKeyPermissions()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(perms.Username, gc.Equals, "auditor")
+	c.Check(perms.ReadOnly(), jc.IsTrue)
+	err = perms.Require(ScopeAllocate, ScopeAdmin)
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(err, gc.ErrorMatches, `API key for user "auditor" lacks the allocate and admin permission needed; .*`)
+}
+
+func (s *controllerSuite) TestCheckAPIKeyPermissionsServerError(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"auditor"`)
+	s.server.AddGetResponse("/api/2.0/machines/?id=permission-probe", http.StatusInternalServerError, "boom")
+	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "No machine available.")
+	controller := s.getController(c)
+
+	perms, err := controller.CheckAPIKeyPermissions()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(perms.Probes, gc.HasLen, 3)
+	c.Check(perms.Probes[0].Allowed, jc.IsFalse)
+	c.Check(perms.Probes[0].Err, jc.Satisfies, IsUnexpectedError)
+	// The unknown boot-sources endpoint is not taken as a refusal.
+	c.Check(perms.Probes[2].Err, jc.Satisfies, IsUnexpectedError)
+}
+
+func (s *controllerSuite) TestClockSkew(c *gc.C) {
+	controller := s.getController(c)
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	skew, err := controller.ClockSkew()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(skew >= -time.Second && skew <= time.Second, jc.IsTrue, gc.Commentf("skew %v", skew))
+}
+
+func (s *controllerSuite) TestClockSkewUsesClock(c *gc.C) {
+	local := time.Now().Add(-time.Hour)
+	controller, err := NewController(ControllerArgs{
+		BaseURL: s.server.URL,
+		APIKey:  "fake:as:key",
+		Clock:   testing.NewClock(local),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	skew, err := controller.ClockSkew()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(skew >= 59*time.Minute && skew <= 61*time.Minute, jc.IsTrue, gc.Commentf("skew %v", skew))
+}
+
+func (s *controllerSuite) TestUnknownValues(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	defer server.Close()
+	var unknown []UnknownValue
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+		UnknownValue: func(value UnknownValue) {
+			unknown = append(unknown, value)
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	var parsed map[string]interface{}
+	c.Assert(json.Unmarshal([]byte(machineResponse), &parsed), jc.ErrorIsNil)
+	parsed["status_name"] = "Pondering"
+	parsed["power_state"] = "warm"
+	parsed["interface_set"].([]interface{})[0].(map[string]interface{})["type"] = "tunnel"
+	response, err := json.Marshal(parsed)
+	c.Assert(err, jc.ErrorIsNil)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+","+string(response)+"]")
+
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines, gc.HasLen, 2)
+	c.Check(unknown, jc.DeepEquals, []UnknownValue{
+		{Kind: UnknownStatusName, Value: "Pondering", SystemID: "4y3ha3"},
+		{Kind: UnknownPowerState, Value: "warm", SystemID: "4y3ha3"},
+		{Kind: UnknownInterfaceType, Value: "tunnel", SystemID: "4y3ha3"},
+	})
+	c.Check(unknown[0].String(), gc.Equals, `unknown status_name "Pondering" on 4y3ha3`)
+}
+
+func (s *controllerSuite) TestRefreshCapabilities(c *gc.C) {
+	var added, removed []set.Strings
+	controller, err := NewController(ControllerArgs{
+		BaseURL: s.server.URL,
+		APIKey:  "fake:as:key",
+		CapabilitiesChanged: func(a, r set.Strings) {
+			added = append(added, a)
+			removed = append(removed, r)
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK,
+		`{"version": "2.5.0", "capabilities": ["networks-management", "static-ipaddresses", "bridging"]}`)
+
+	// Nothing has changed yet.
+	capabilities, err := controller.RefreshCapabilities()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(capabilities.Contains(DevicesManagement), jc.IsTrue)
+	c.Check(added, gc.HasLen, 0)
+
+	capabilities, err = controller.RefreshCapabilities()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(capabilities.SortedValues(), jc.DeepEquals, []string{"bridging", NetworksManagement, StaticIPAddresses})
+	c.Check(controller.Capabilities().SortedValues(), jc.DeepEquals, capabilities.SortedValues())
+	c.Assert(added, gc.HasLen, 1)
+	c.Check(added[0].SortedValues(), jc.DeepEquals, []string{"bridging"})
+	c.Check(removed[0].SortedValues(), jc.DeepEquals, []string{
+		DevicesManagement, IPv6DeploymentUbuntu, NetworkDeploymentUbuntu, StorageDeploymentUbuntu,
+	})
+}
+
+// getVersionedController returns a controller for a server that reports
+// the version, and serves the machines.
+func (s *controllerSuite) getVersionedController(c *gc.C, serverVersion string) Controller {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK,
+		`{"version": "`+serverVersion+`", "subversion": "", "capabilities": []}`)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return controller
+}
+
+func (s *controllerSuite) TestServerVersion(c *gc.C) {
+	controller := s.getVersionedController(c, "2.9.2")
+	c.Check(controller.ServerVersion(), gc.Equals, version.MustParse("2.9.2"))
+
+	// The funcs for 2.0 are used until there are newer ones.
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines, gc.HasLen, 3)
+}
+
+func (s *controllerSuite) TestServerVersionSelectsDeserialization(c *gc.C) {
+	var read []string
+	twoDotNine := version.Number{Major: 2, Minor: 9}
+	machineDeserializationFuncs[twoDotNine] = func(source map[string]interface{}, options readOptions) (*machine, error) {
+		read = append(read, source["system_id"].(string))
+		return machine_2_0(source, options)
+	}
+	defer delete(machineDeserializationFuncs, twoDotNine)
+
+	_, err := s.getVersionedController(c, "3.0.0").Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(read, gc.HasLen, 3)
+
+	// Older servers keep to the funcs for 2.0.
+	read = nil
+	_, err = s.getVersionedController(c, "2.4.2").Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(read, gc.HasLen, 0)
+}
+
+func (s *controllerSuite) TestSchemaVersionPinsDeserialization(c *gc.C) {
+	var read []string
+	twoDotNine := version.Number{Major: 2, Minor: 9}
+	machineDeserializationFuncs[twoDotNine] = func(source map[string]interface{}, options readOptions) (*machine, error) {
+		read = append(read, source["system_id"].(string))
+		return machine_2_0(source, options)
+	}
+	defer delete(machineDeserializationFuncs, twoDotNine)
+
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK,
+		`{"version": "3.0.0", "subversion": "", "capabilities": []}`)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	server.Start()
+	defer server.Close()
+	controller, err := NewController(ControllerArgs{
+		BaseURL:       server.URL,
+		APIKey:        "fake:as:key",
+		SchemaVersion: twoDotOh,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(read, gc.HasLen, 0)
+}
+
+func (s *controllerSuite) TestServerVersionUnknown(c *gc.C) {
+	controller := s.getController(c)
+	c.Check(controller.ServerVersion(), gc.Equals, version.Zero)
+}
+
+func (s *controllerSuite) TestRefreshCapabilitiesUpdatesVersion(c *gc.C) {
+	controller := s.getController(c).(*controller)
+	c.Check(controller.schemaVersion(), gc.Equals, twoDotOh)
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK,
+		`{"version": "3.0.0", "subversion": "", "capabilities": []}`)
+	_, err := controller.RefreshCapabilities()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(controller.ServerVersion(), gc.Equals, version.MustParse("3.0.0"))
+	c.Check(controller.schemaVersion(), gc.Equals, version.MustParse("3.0.0"))
+
+	// A controller bound to a context sees the new version too.
+	bound := controller.WithContext(context.Background())
+	c.Check(bound.ServerVersion(), gc.Equals, version.MustParse("3.0.0"))
+}
+
+func (s *controllerSuite) TestRefreshCapabilitiesKeepsPinnedVersion(c *gc.C) {
+	pinned, err := NewController(ControllerArgs{
+		BaseURL:       s.server.URL,
+		APIKey:        "fake:as:key",
+		SchemaVersion: twoDotOh,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK,
+		`{"version": "3.0.0", "subversion": "", "capabilities": []}`)
+	_, err = pinned.RefreshCapabilities()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(pinned.ServerVersion(), gc.Equals, version.MustParse("3.0.0"))
+	c.Check(pinned.(*controller).schemaVersion(), gc.Equals, twoDotOh)
+}
+
+func (s *controllerSuite) TestRefreshCapabilitiesVersionGone(c *gc.C) {
+	controller := s.getController(c)
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusGone, "gone")
+	_, err := controller.RefreshCapabilities()
+	c.Check(err, jc.Satisfies, IsUnsupportedVersionError)
+	c.Check(controller.Capabilities().Contains(DevicesManagement), jc.IsTrue)
+}
+
 func (s *controllerSuite) TestNewControllerBadAPIKeyFormat(c *gc.C) {
 	server := NewSimpleServer()
 	server.Start()
@@ -265,10 +798,161 @@ func (s *controllerSuite) TestDevicesArgs(c *gc.C) {
 		Domain:       "magic",
 		Zone:         "foo",
 		AgentName:    "agent 42",
+		Parent:       "4y3ha3",
 	})
 	request := s.server.LastRequest()
 	// There should be one entry in the form values for each of the args.
-	c.Assert(request.URL.Query(), gc.HasLen, 6)
+	c.Assert(request.URL.Query(), gc.HasLen, 7)
+}
+
+func (s *controllerSuite) TestDevicesFilterByParent(c *gc.C) {
+	other := updateJSONMap(c, deviceResponse, map[string]interface{}{
+		"system_id": "4y3hag",
+		"parent":    "4y3ha4",
+	})
+	response := "[" + deviceResponse + "," + other + "]"
+	s.server.AddGetResponse("/api/2.0/devices/?parent=4y3ha4", http.StatusOK, response)
+	s.server.AddGetResponse("/api/2.0/devices/?parent=4y3ha4", http.StatusOK, response)
+	controller := s.getController(c)
+
+	devices, err := controller.Devices(DevicesArgs{Parent: "4y3ha4"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 1)
+	c.Check(devices[0].SystemID(), gc.Equals, "4y3hag")
+
+	var systemIDs []string
+	err = controller.VisitDevices(DevicesArgs{Parent: "4y3ha4"}, func(device Device) error {
+		systemIDs = append(systemIDs, device.SystemID())
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(systemIDs, jc.DeepEquals, []string{"4y3hag"})
+}
+
+func (s *controllerSuite) TestVisitDevices(c *gc.C) {
+	controller := s.getController(c)
+	var systemIDs []string
+	err := controller.VisitDevices(DevicesArgs{}, func(device Device) error {
+		systemIDs = append(systemIDs, device.SystemID())
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(systemIDs, jc.DeepEquals, []string{"4y3haf"})
+}
+
+func (s *controllerSuite) TestVisitDevicesStopsOnError(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/devices/?hostname=furnacelike-brittney", http.StatusOK, "["+deviceResponse+","+deviceResponse+"]")
+	controller := s.getController(c)
+	count := 0
+	err := controller.VisitDevices(DevicesArgs{Hostname: []string{"furnacelike-brittney"}}, func(device Device) error {
+		count++
+		return errors.New("stop")
+	})
+	c.Assert(err, gc.ErrorMatches, "stop")
+	c.Assert(count, gc.Equals, 1)
+}
+
+func (s *controllerSuite) TestVisitDevicesStreams(c *gc.C) {
+	visited := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/2.0/version/":
+			fmt.Fprint(w, versionResponse)
+		case "/api/2.0/users/":
+			fmt.Fprint(w, `"captain awesome"`)
+		case "/api/2.0/devices/":
+			// The rest of the list is only sent once the first device
+			// has been visited.
+			fmt.Fprint(w, "["+deviceResponse+",")
+			w.(http.Flusher).Flush()
+			select {
+			case <-visited:
+			case <-time.After(5 * time.Second):
+			}
+			fmt.Fprint(w, deviceResponse+"]")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	controller, err := NewController(ControllerArgs{BaseURL: server.URL, APIKey: "fake:as:key"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	count := 0
+	err = controller.VisitDevices(DevicesArgs{}, func(Device) error {
+		if count == 0 {
+			close(visited)
+		}
+		count++
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(count, gc.Equals, 2)
+}
+
+func (s *controllerSuite) TestVisitDevicesRetries(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/devices/", http.StatusServiceUnavailable, "busy")
+	server.AddGetResponse("/api/2.0/devices/", http.StatusOK, devicesResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	defer server.Close()
+	controller, err := NewController(ControllerArgs{
+		BaseURL:     server.URL,
+		APIKey:      "fake:as:key",
+		RetryPolicy: &RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond, StatusCodes: []int{http.StatusServiceUnavailable}},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	count := 0
+	err = controller.VisitDevices(DevicesArgs{}, func(Device) error {
+		count++
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(count, gc.Equals, 1)
+}
+
+func (s *controllerSuite) TestVisitDevicesLimits(c *gc.C) {
+	newController := func(args ControllerArgs) Controller {
+		server := NewSimpleServer()
+		server.AddGetResponse("/api/2.0/devices/", http.StatusOK, "["+deviceResponse+","+deviceResponse+"]")
+		server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+		server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+		server.Start()
+		s.AddCleanup(func(*gc.C) { server.Close() })
+		args.BaseURL = server.URL
+		args.APIKey = "fake:as:key"
+		controller, err := NewController(args)
+		c.Assert(err, jc.ErrorIsNil)
+		return controller
+	}
+
+	controller := newController(ControllerArgs{MaxResponseSize: int64(len(deviceResponse)) + 10})
+	count := 0
+	err := controller.VisitDevices(DevicesArgs{}, func(Device) error {
+		count++
+		return nil
+	})
+	c.Check(err, jc.Satisfies, IsTooLargeError)
+	c.Check(count, gc.Equals, 1)
+
+	controller = newController(ControllerArgs{MaxDecodeMemory: 1024})
+	err = controller.VisitDevices(DevicesArgs{}, func(Device) error {
+		return nil
+	})
+	c.Check(err, jc.Satisfies, IsTooLargeError)
+	c.Check(err, gc.ErrorMatches, "unexpected: decoded response of at least .* bytes exceeds the limit of 1024 bytes")
+}
+
+func (s *controllerSuite) TestVisitDevicesBadSchema(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/devices/?hostname=wat", http.StatusOK, `{"wat": "?"}`)
+	controller := s.getController(c)
+	err := controller.VisitDevices(DevicesArgs{Hostname: []string{"wat"}}, func(Device) error {
+		return nil
+	})
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
 }
 
 func (s *controllerSuite) TestCreateDevice(c *gc.C) {
@@ -341,21 +1025,93 @@ func (s *controllerSuite) TestZones(c *gc.C) {
 	controller := s.getController(c)
 	zones, err := controller.Zones()
 	c.Assert(err, jc.ErrorIsNil)
-	c.Assert(zones, gc.HasLen, 2)
+	c.Assert(zones, gc.HasLen, 2)
+}
+
+func (s *controllerSuite) TestPools(c *gc.C) {
+	controller := s.getController(c)
+	pools, err := controller.Pools()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(pools, gc.HasLen, 2)
+}
+
+func (s *controllerSuite) TestMachines(c *gc.C) {
+	controller := s.getController(c)
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+}
+
+func (s *controllerSuite) TestWithContext(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	controller := s.getController(c).WithContext(ctx)
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+
+	cancel()
+	_, err = controller.Machines(MachinesArgs{})
+	c.Check(err, jc.Satisfies, IsContextError)
+	err = machines[0].Start(StartArgs{})
+	c.Check(err, jc.Satisfies, IsContextError)
+}
+
+func (s *controllerSuite) TestWithContextSharesState(c *gc.C) {
+	controller := s.getController(c)
+	bound := controller.WithContext(context.Background())
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK,
+		`{"version": "2.5.0", "capabilities": ["bridging"]}`)
+	_, err := bound.RefreshCapabilities()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(controller.Capabilities().SortedValues(), jc.DeepEquals, []string{"bridging"})
+}
+
+func (s *controllerSuite) TestWithContextDeadline(c *gc.C) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err := s.getController(c).WithContext(ctx).Machines(MachinesArgs{})
+	c.Check(err, jc.Satisfies, IsContextError)
+	c.Check(IsContextError(errors.New("boom")), jc.IsFalse)
+}
+
+func (s *controllerSuite) TestUpdateAPIKey(c *gc.C) {
+	controller := s.getController(c)
+	bound := controller.WithContext(context.Background())
+	err := controller.UpdateAPIKey("new:api:key", false)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = bound.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	auth := s.server.LastRequest().Header.Get("Authorization")
+	c.Check(auth, jc.Contains, `oauth_consumer_key="new"`)
+	c.Check(auth, jc.Contains, `oauth_token="api"`)
+}
+
+func (s *controllerSuite) TestUpdateAPIKeyChecksCreds(c *gc.C) {
+	controller := s.getController(c)
+	s.server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	err := controller.UpdateAPIKey("new:api:key", true)
+	c.Assert(err, jc.ErrorIsNil)
+	auth := s.server.LastRequest().Header.Get("Authorization")
+	c.Check(auth, jc.Contains, `oauth_token="api"`)
 }
 
-func (s *controllerSuite) TestPools(c *gc.C) {
+func (s *controllerSuite) TestUpdateAPIKeyKeepsOldKeyOnFailedCheck(c *gc.C) {
 	controller := s.getController(c)
-	pools, err := controller.Pools()
+	s.server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusUnauthorized, "naughty")
+	err := controller.UpdateAPIKey("new:api:key", true)
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+
+	_, err = controller.Machines(MachinesArgs{})
 	c.Assert(err, jc.ErrorIsNil)
-	c.Assert(pools, gc.HasLen, 2)
+	auth := s.server.LastRequest().Header.Get("Authorization")
+	c.Check(auth, jc.Contains, `oauth_token="as"`)
 }
 
-func (s *controllerSuite) TestMachines(c *gc.C) {
+func (s *controllerSuite) TestUpdateAPIKeyInvalid(c *gc.C) {
 	controller := s.getController(c)
-	machines, err := controller.Machines(MachinesArgs{})
-	c.Assert(err, jc.ErrorIsNil)
-	c.Assert(machines, gc.HasLen, 3)
+	err := controller.UpdateAPIKey("not-a-key", false)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
 }
 
 func (s *controllerSuite) TestMachinesFilter(c *gc.C) {
@@ -406,6 +1162,207 @@ func (s *controllerSuite) TestMachinesFilterWithOwnerData_RequiresAllMatch(c *gc
 	c.Assert(machines[0].Hostname(), gc.Equals, "lowlier-glady")
 }
 
+func (s *controllerSuite) TestMachinesFilterWithNodeTypes(c *gc.C) {
+	regionRack := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"system_id":      "4y3hab",
+		"hostname":       "region-rack",
+		"node_type":      4,
+		"node_type_name": "Region and rack controller",
+	})
+	response := "[" + machineResponse + "," + regionRack + "]"
+	s.server.AddGetResponse("/api/2.0/machines/?id=4y3ha3&id=4y3hab", http.StatusOK, response)
+	s.server.AddGetResponse("/api/2.0/machines/?id=4y3ha3&id=4y3hab", http.StatusOK, response)
+	controller := s.getController(c)
+
+	machines, err := controller.Machines(MachinesArgs{SystemIDs: []string{"4y3ha3", "4y3hab"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 2)
+	c.Check(machines[1].NodeType(), gc.Equals, NodeTypeRegionAndRackController)
+	c.Check(machines[1].NodeType().IsController(), jc.IsTrue)
+
+	machines, err = controller.Machines(MachinesArgs{
+		SystemIDs: []string{"4y3ha3", "4y3hab"},
+		NodeTypes: []NodeType{NodeTypeMachine},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	c.Check(machines[0].SystemID(), gc.Equals, "4y3ha3")
+}
+
+func (s *controllerSuite) TestMachinesFilterWithInterfaceTags(c *gc.C) {
+	tagged := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"system_id":     "4y3hab",
+		"hostname":      "tagged",
+		"interface_set": []interface{}{parseJSON(c, interfaceResponse)},
+	})
+	response := "[" + machineResponse + "," + tagged + "]"
+	s.server.AddGetResponse("/api/2.0/machines/?id=4y3ha3&id=4y3hab", http.StatusOK, response)
+	s.server.AddGetResponse("/api/2.0/machines/?id=4y3ha3&id=4y3hab", http.StatusOK, response)
+	controller := s.getController(c)
+
+	machines, err := controller.Machines(MachinesArgs{
+		SystemIDs:     []string{"4y3ha3", "4y3hab"},
+		InterfaceTags: []string{"foo", "bar"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	c.Check(machines[0].Hostname(), gc.Equals, "tagged")
+
+	machines, err = controller.Machines(MachinesArgs{
+		SystemIDs:     []string{"4y3ha3", "4y3hab"},
+		InterfaceTags: []string{"foo", "sriov"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 0)
+}
+
+func (*controllerSuite) TestNodeTypeString(c *gc.C) {
+	c.Check(NodeTypeMachine.String(), gc.Equals, "Machine")
+	c.Check(NodeTypeRackController.String(), gc.Equals, "Rack controller")
+	c.Check(NodeType(42).String(), gc.Equals, "NodeType(42)")
+	c.Check(NodeTypeMachine.IsController(), jc.IsFalse)
+	c.Check(NodeTypeDevice.IsController(), jc.IsFalse)
+}
+
+func (s *controllerSuite) TestMachinesSplitsSystemIDs(c *gc.C) {
+	s.PatchValue(&maxSystemIDsPerRequest, 2)
+	s.server.AddGetResponse("/api/2.0/machines/?id=4y3ha3&id=4y3ha4", http.StatusOK, machinesResponse)
+	s.server.AddGetResponse("/api/2.0/machines/?id=4y3ha6", http.StatusOK, "[]")
+	controller := s.getController(c)
+	s.server.ResetRequests()
+	machines, err := controller.Machines(MachinesArgs{
+		SystemIDs: []string{"4y3ha3", "4y3ha4", "4y3ha6"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+	c.Assert(s.server.RequestCount(), gc.Equals, 2)
+}
+
+func (s *controllerSuite) TestSplitSystemIDs(c *gc.C) {
+	s.PatchValue(&maxSystemIDsPerRequest, 2)
+	c.Check(splitSystemIDs(nil), jc.DeepEquals, [][]string{nil})
+	c.Check(splitSystemIDs([]string{"a", "b"}), jc.DeepEquals, [][]string{{"a", "b"}})
+	c.Check(splitSystemIDs([]string{"a", "b", "c", "d", "e"}), jc.DeepEquals, [][]string{{"a", "b"}, {"c", "d"}, {"e"}})
+}
+
+func (s *controllerSuite) TestMachinesQueryTooLong(c *gc.C) {
+	controller, err := NewController(ControllerArgs{
+		BaseURL:        s.server.URL,
+		APIKey:         "fake:as:key",
+		MaxQueryLength: 20,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	s.server.ResetRequests()
+	_, err = controller.Machines(MachinesArgs{
+		Hostnames: []string{"untasted-markita", "lowlier-glady"},
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, `query for "machines" is 25 bytes, exceeding the limit of 20 bytes`)
+	c.Assert(s.server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *controllerSuite) TestMachinesSplitsLongQuery(c *gc.C) {
+	controller, err := NewController(ControllerArgs{
+		BaseURL:        s.server.URL,
+		APIKey:         "fake:as:key",
+		MaxQueryLength: 30,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	// The server already answers for untasted-markita.
+	s.server.AddGetResponse("/api/2.0/machines/?hostname=lowlier-glady", http.StatusOK, "["+machineResponse+"]")
+	s.server.ResetRequests()
+	machines, err := controller.Machines(MachinesArgs{
+		Hostnames: []string{"untasted-markita", "lowlier-glady"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines, gc.HasLen, 2)
+	c.Check(s.server.RequestCount(), gc.Equals, 2)
+}
+
+func (s *controllerSuite) TestDevicesSplitsLongQuery(c *gc.C) {
+	controller, err := NewController(ControllerArgs{
+		BaseURL:        s.server.URL,
+		APIKey:         "fake:as:key",
+		MaxQueryLength: 36,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	s.server.AddGetResponse("/api/2.0/devices/?hostname=furnacelike-brittney&id=a", http.StatusOK, devicesResponse)
+	s.server.AddGetResponse("/api/2.0/devices/?hostname=furnacelike-brittney&id=b", http.StatusOK, "[]")
+	s.server.AddGetResponse("/api/2.0/devices/?hostname=furnacelike-brittney&id=c", http.StatusOK, devicesResponse)
+	args := DevicesArgs{
+		Hostname:  []string{"furnacelike-brittney"},
+		SystemIDs: []string{"a", "b", "c"},
+	}
+	s.server.ResetRequests()
+	devices, err := controller.Devices(args)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(devices, gc.HasLen, 2)
+	c.Check(s.server.RequestCount(), gc.Equals, 3)
+
+	s.server.AddGetResponse("/api/2.0/devices/?hostname=furnacelike-brittney&id=a", http.StatusOK, devicesResponse)
+	s.server.AddGetResponse("/api/2.0/devices/?hostname=furnacelike-brittney&id=b", http.StatusOK, "[]")
+	s.server.AddGetResponse("/api/2.0/devices/?hostname=furnacelike-brittney&id=c", http.StatusOK, devicesResponse)
+	visited := 0
+	err = controller.VisitDevices(args, func(Device) error {
+		visited++
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(visited, gc.Equals, 2)
+}
+
+func (s *controllerSuite) TestSplitQuery(c *gc.C) {
+	controller := &controller{maxQueryLength: 10}
+	params := url.Values{"id": {"a", "b", "c"}, "zone": {"z"}}
+	c.Check(controller.splitQuery(params, "id"), jc.DeepEquals, []url.Values{
+		{"id": {"a"}, "zone": {"z"}},
+		{"id": {"b"}, "zone": {"z"}},
+		{"id": {"c"}, "zone": {"z"}},
+	})
+	controller.maxQueryLength = -1
+	c.Check(controller.splitQuery(params, "id"), jc.DeepEquals, []url.Values{params})
+}
+
+func (s *controllerSuite) TestMachinesMaxResponseSize(c *gc.C) {
+	controller, err := NewController(ControllerArgs{
+		BaseURL:         s.server.URL,
+		APIKey:          "fake:as:key",
+		MaxResponseSize: 1024,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.Satisfies, IsTooLargeError)
+	c.Check(err, gc.ErrorMatches, "unexpected: response of at least .* bytes exceeds the limit of 1024 bytes")
+}
+
+func (s *controllerSuite) TestMachinesMaxDecodeMemory(c *gc.C) {
+	controller, err := NewController(ControllerArgs{
+		BaseURL:         s.server.URL,
+		APIKey:          "fake:as:key",
+		MaxDecodeMemory: 4096,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	s.server.ResetRequests()
+	_, err = controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.Satisfies, IsTooLargeError)
+	c.Check(err, gc.ErrorMatches, "unexpected: decoded response of at least .* bytes exceeds the limit of 4096 bytes")
+	c.Check(s.server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *controllerSuite) TestMachinesQueryLengthUnlimited(c *gc.C) {
+	controller, err := NewController(ControllerArgs{
+		BaseURL:        s.server.URL,
+		APIKey:         "fake:as:key",
+		MaxQueryLength: -1,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	machines, err := controller.Machines(MachinesArgs{
+		Hostnames: []string{"untasted-markita"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+}
+
 func (s *controllerSuite) TestMachinesArgs(c *gc.C) {
 	controller := s.getController(c)
 	// This will fail with a 404 due to the test server not having something  at
@@ -445,6 +1402,12 @@ func (s *controllerSuite) TestStorageSpec(c *gc.C) {
 	}, {
 		spec: StorageSpec{Size: 200, Tags: []string{"foo", ""}},
 		err:  "empty tag not valid",
+	}, {
+		spec: StorageSpec{Label: "root:1", Size: 200},
+		err:  `label "root:1" not valid`,
+	}, {
+		spec: StorageSpec{Size: 200, Tags: []string{"ssd,nvme"}},
+		err:  `tag "ssd,nvme" not valid`,
 	}, {
 		spec: StorageSpec{Size: 200, Tags: []string{"foo"}},
 		repr: "200(foo)",
@@ -474,10 +1437,29 @@ func (s *controllerSuite) TestInterfaceSpec(c *gc.C) {
 		err:  "missing Label not valid",
 	}, {
 		spec: InterfaceSpec{Label: "foo"},
-		err:  "empty Space constraint not valid",
+		err:  "empty interface constraint not valid",
 	}, {
 		spec: InterfaceSpec{Label: "foo", Space: "magic"},
 		repr: "foo:space=magic",
+	}, {
+		spec: InterfaceSpec{
+			Label:          "foo",
+			Space:          "magic",
+			NotSpace:       []string{"dmz", "storage"},
+			Fabric:         "fabric-1",
+			NotFabricClass: []string{"10g"},
+			Subnet:         "cidr:10.0.0.0/24",
+		},
+		repr: "foo:space=magic,not_space=dmz,not_space=storage,fabric=fabric-1,not_fabric_class=10g,subnet=cidr:10.0.0.0/24",
+	}, {
+		spec: InterfaceSpec{Label: "foo", NotFabric: []string{"fabric-0"}},
+		repr: "foo:not_fabric=fabric-0",
+	}, {
+		spec: InterfaceSpec{Label: "foo:bar", Space: "magic"},
+		err:  `label "foo:bar" not valid`,
+	}, {
+		spec: InterfaceSpec{Label: "foo", Space: "magic;bar:space=other"},
+		err:  `constraint "space=magic;bar:space=other" not valid`,
 	}} {
 		c.Logf("test %d", i)
 		err := test.spec.Validate()
@@ -689,6 +1671,63 @@ func (s *controllerSuite) TestAllocateMachineStorageMatchMissing(c *gc.C) {
 	c.Assert(err, jc.Satisfies, IsDeserializationError)
 }
 
+func (s *controllerSuite) TestAllocateMachineNodeDevicesMatch(c *gc.C) {
+	allocateJSON := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"constraints_by_type": map[string]interface{}{
+			"devices": constraintMatchInfo{"gpu": []int{7, 8}},
+		},
+	})
+	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocateJSON)
+	controller := s.getController(c)
+	_, match, err := controller.AllocateMachine(AllocateMachineArgs{
+		NodeDevices: []NodeDeviceSpec{{Label: "gpu", VendorID: "10de", ProductID: "1eb8"}},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(match.NodeDevices, jc.DeepEquals, map[string][]int{"gpu": {7, 8}})
+	c.Check(s.server.LastRequest().PostForm.Get("devices"), gc.Equals, "gpu:vendor_id=10de,product_id=1eb8")
+}
+
+func (s *controllerSuite) TestAllocateMachineNodeDevicesNotSupported(c *gc.C) {
+	s.addAllocateResponse(c, http.StatusOK, nil, nil)
+	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+	controller := s.getController(c)
+	machine, _, err := controller.AllocateMachine(AllocateMachineArgs{
+		NodeDevices: []NodeDeviceSpec{{Label: "gpu", VendorID: "10de"}},
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Check(machine, gc.IsNil)
+	form := s.server.LastRequest().PostForm
+	c.Check(form["machines"], jc.DeepEquals, []string{"4y3ha3"})
+}
+
+func (*controllerSuite) TestNodeDeviceSpecValidate(c *gc.C) {
+	for i, test := range []struct {
+		spec NodeDeviceSpec
+		err  string
+	}{{
+		spec: NodeDeviceSpec{VendorID: "10de"},
+		err:  "missing Label not valid",
+	}, {
+		spec: NodeDeviceSpec{Label: "gpu"},
+		err:  "empty device constraint not valid",
+	}, {
+		spec: NodeDeviceSpec{Label: "gpu", ProductID: "1eb8"},
+	}} {
+		c.Logf("test %d", i)
+		err := test.spec.Validate()
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, gc.ErrorMatches, test.err)
+		}
+	}
+	args := AllocateMachineArgs{NodeDevices: []NodeDeviceSpec{
+		{Label: "gpu", VendorID: "10de"},
+		{Label: "gpu", VendorID: "1002"},
+	}}
+	c.Check(args.Validate(), gc.ErrorMatches, `reusing device label "gpu" not valid`)
+}
+
 func (s *controllerSuite) TestAllocateMachineArgsForm(c *gc.C) {
 	s.addAllocateResponse(c, http.StatusOK, nil, nil)
 	controller := s.getController(c)
@@ -738,6 +1777,59 @@ func (s *controllerSuite) TestAllocateMachineUnexpected(c *gc.C) {
 	c.Assert(err, jc.Satisfies, IsUnexpectedError)
 }
 
+func (s *controllerSuite) TestAcquireMachine(c *gc.C) {
+	s.addAllocateResponse(c, http.StatusOK, nil, nil)
+	controller := s.getController(c)
+	machine, err := controller.AcquireMachine("4y3ha3", "mine now")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(machine.Owner(), gc.Equals, "thumper")
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("system_id"), gc.Equals, "4y3ha3")
+	c.Check(form.Get("comment"), gc.Equals, "mine now")
+}
+
+func (s *controllerSuite) TestAcquireMachineAlreadyOwned(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "No machine available.")
+	s.server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineResponse+"]")
+	controller := s.getController(c)
+	_, err := controller.AcquireMachine("4y3ha3", "")
+	c.Assert(err, jc.Satisfies, IsAlreadyOwnedError)
+	ownedErr := errors.Cause(err).(*AlreadyOwnedError)
+	c.Check(ownedErr.SystemID, gc.Equals, "4y3ha3")
+	c.Check(ownedErr.Owner, gc.Equals, "thumper")
+	c.Check(err, gc.ErrorMatches, `machine 4y3ha3 is already owned by "thumper"`)
+}
+
+func (s *controllerSuite) TestAcquireMachineNotReady(c *gc.C) {
+	broken := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"owner":       nil,
+		"status_name": "Broken",
+	})
+	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "No machine available.")
+	s.server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+broken+"]")
+	controller := s.getController(c)
+	_, err := controller.AcquireMachine("4y3ha3", "")
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err, gc.ErrorMatches, "machine 4y3ha3 is Broken and cannot be allocated")
+}
+
+func (s *controllerSuite) TestAcquireMachineMissing(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "No machine available.")
+	s.server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "[]")
+	controller := s.getController(c)
+	_, err := controller.AcquireMachine("4y3ha3", "")
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err, gc.ErrorMatches, `no machine with system ID "4y3ha3"`)
+}
+
+func (s *controllerSuite) TestAcquireMachineValidates(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.AcquireMachine("", "")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
 func (s *controllerSuite) TestReleaseMachines(c *gc.C) {
 	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
 	controller := s.getController(c)
@@ -753,6 +1845,34 @@ func (s *controllerSuite) TestReleaseMachines(c *gc.C) {
 	c.Assert(request.PostForm.Get("comment"), gc.Equals, "all good")
 }
 
+func (s *controllerSuite) TestReleaseMachinesErase(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+	controller := s.getController(c)
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs:   []string{"this"},
+		Erase:       true,
+		SecureErase: true,
+		Scripts:     []string{"wipe-disks", "collect-evidence"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("erase"), gc.Equals, "true")
+	c.Check(form.Get("secure_erase"), gc.Equals, "true")
+	c.Check(form.Get("scripts"), gc.Equals, "wipe-disks,collect-evidence")
+	_, found := form["quick_erase"]
+	c.Check(found, jc.IsFalse)
+}
+
+func (s *controllerSuite) TestReleaseMachinesValidates(c *gc.C) {
+	controller := s.getController(c)
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs:  []string{"this"},
+		QuickErase: true,
+	})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
 func (s *controllerSuite) TestReleaseMachinesBadRequest(c *gc.C) {
 	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusBadRequest, "unknown machines")
 	controller := s.getController(c)
@@ -761,6 +1881,50 @@ func (s *controllerSuite) TestReleaseMachinesBadRequest(c *gc.C) {
 	})
 	c.Assert(err, jc.Satisfies, IsBadRequestError)
 	c.Assert(err.Error(), gc.Equals, "unknown machines")
+	c.Assert(err, jc.Satisfies, IsBulkError)
+	bulkErr := err.(*BulkError)
+	c.Check(bulkErr.Failed(), jc.DeepEquals, []string{"this", "that"})
+	c.Check(bulkErr.Succeeded(), gc.HasLen, 0)
+}
+
+func (s *controllerSuite) TestReleaseMachinesUnknownMachines(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusBadRequest, "Unknown machine(s): that, other.")
+	controller := s.getController(c)
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs: []string{"this", "that", "other"},
+	})
+	c.Assert(err, jc.Satisfies, IsBulkError)
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "Unknown machine(s): that, other.")
+	bulkErr := err.(*BulkError)
+	c.Check(bulkErr.Err("that"), jc.Satisfies, IsBadRequestError)
+	c.Check(bulkErr.Err("other"), jc.Satisfies, IsBadRequestError)
+	c.Check(bulkErr.Err("this"), jc.Satisfies, IsNotAttemptedError)
+	c.Check(bulkErr.NotAttempted(), jc.DeepEquals, []string{"this"})
+}
+
+func (s *controllerSuite) TestReleaseMachinesNamedInWrongState(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusConflict, "Machine(s) cannot be released in their current state: this.")
+	controller := s.getController(c)
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs: []string{"this", "that"},
+	})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	bulkErr := err.(*BulkError)
+	c.Check(bulkErr.Failed(), jc.DeepEquals, []string{"this", "that"})
+	c.Check(bulkErr.NotAttempted(), jc.DeepEquals, []string{"that"})
+}
+
+func (s *controllerSuite) TestReleaseMachinesMessageNotAList(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusBadRequest, "Unknown machine(s): this, elsewhere.")
+	controller := s.getController(c)
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs: []string{"this", "that"},
+	})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	bulkErr := err.(*BulkError)
+	c.Check(bulkErr.NotAttempted(), gc.HasLen, 0)
+	c.Check(bulkErr.Failed(), jc.DeepEquals, []string{"this", "that"})
 }
 
 func (s *controllerSuite) TestReleaseMachinesForbidden(c *gc.C) {
@@ -793,6 +1957,80 @@ func (s *controllerSuite) TestReleaseMachinesUnexpected(c *gc.C) {
 	c.Assert(err.Error(), gc.Equals, "unexpected: ServerError: 502 Bad Gateway (wat)")
 }
 
+func (s *controllerSuite) TestMoveMachinesToPool(c *gc.C) {
+	s.server.AddPutResponse("/api/2.0/machines/abc/", http.StatusOK, machineResponse)
+	s.server.AddPutResponse("/api/2.0/machines/def/", http.StatusOK, machineResponse)
+	controller := s.getController(c)
+	err := controller.MoveMachinesToPool(MoveMachinesToPoolArgs{
+		SystemIDs: []string{"abc", "def"},
+		Pool:      "swimming_is_fun",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	requests := s.server.LastNRequests(2)
+	c.Check(requests[0].URL.Path, gc.Equals, "/api/2.0/machines/abc/")
+	c.Check(requests[1].URL.Path, gc.Equals, "/api/2.0/machines/def/")
+	c.Check(requests[1].PostForm.Get("pool"), gc.Equals, "swimming_is_fun")
+}
+
+func (s *controllerSuite) TestMoveMachinesToPoolPartialFailure(c *gc.C) {
+	s.server.AddPutResponse("/api/2.0/machines/abc/", http.StatusOK, machineResponse)
+	s.server.AddPutResponse("/api/2.0/machines/def/", http.StatusNotFound, "no such machine")
+	s.server.AddPutResponse("/api/2.0/machines/ghi/", http.StatusOK, machineResponse)
+	controller := s.getController(c)
+	err := controller.MoveMachinesToPool(MoveMachinesToPoolArgs{
+		SystemIDs: []string{"abc", "def", "ghi"},
+		Pool:      "default",
+		BatchSize: 2,
+	})
+	c.Assert(err, jc.Satisfies, IsBulkError)
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	bulkErr := err.(*BulkError)
+	c.Check(bulkErr.Succeeded(), jc.DeepEquals, []string{"abc", "ghi"})
+	c.Check(bulkErr.Failed(), jc.DeepEquals, []string{"def"})
+}
+
+func (s *controllerSuite) TestMoveMachinesToPoolForbidden(c *gc.C) {
+	s.server.AddPutResponse("/api/2.0/machines/abc/", http.StatusForbidden, "bzzt denied")
+	controller := s.getController(c)
+	err := controller.MoveMachinesToPool(MoveMachinesToPoolArgs{
+		SystemIDs: []string{"abc", "def"},
+		Pool:      "default",
+	})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Check(err.(*BulkError).Failed(), jc.DeepEquals, []string{"abc", "def"})
+	// The second machine is not tried.
+	c.Check(s.server.LastRequest().URL.Path, gc.Equals, "/api/2.0/machines/abc/")
+}
+
+func (s *controllerSuite) TestMoveMachinesToPoolUnknownPool(c *gc.C) {
+	controller := s.getController(c)
+	err := controller.MoveMachinesToPool(MoveMachinesToPoolArgs{
+		SystemIDs: []string{"abc"},
+		Pool:      "deep-end",
+	})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err, gc.ErrorMatches, `no pool named "deep-end"`)
+}
+
+func (s *controllerSuite) TestMoveMachinesToPoolNotSupported(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	err := controller.MoveMachinesToPool(MoveMachinesToPoolArgs{
+		SystemIDs: []string{"abc"},
+		Pool:      "default",
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *controllerSuite) TestMoveMachinesToPoolValidates(c *gc.C) {
+	controller := s.getController(c)
+	err := controller.MoveMachinesToPool(MoveMachinesToPoolArgs{Pool: "default"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, "missing SystemIDs not valid")
+	err = controller.MoveMachinesToPool(MoveMachinesToPoolArgs{SystemIDs: []string{"abc"}})
+	c.Check(err, gc.ErrorMatches, "missing Pool not valid")
+}
+
 func (s *controllerSuite) TestFiles(c *gc.C) {
 	controller := s.getController(c)
 	files, err := controller.Files("")
@@ -869,6 +2107,21 @@ func (s *controllerSuite) TestAddFileArgsValidate(c *gc.C) {
 			Length:   20,
 		},
 		errText: `specifying Length and Content not valid`,
+	}, {
+		args: AddFileArgs{
+			Filename: "foo.txt",
+			Content:  []byte("foo"),
+			SHA256:   "abc",
+		},
+		errText: `SHA256 "abc" not valid`,
+	}, {
+		args: AddFileArgs{
+			Filename: "foo.txt",
+			Content:  []byte("foo"),
+			SHA256:   testingFileSHA256,
+			Attempts: -1,
+		},
+		errText: `negative Attempts not valid`,
 	}, {
 		args: AddFileArgs{
 			Filename: "foo.txt",
@@ -931,6 +2184,79 @@ func (s *controllerSuite) TestAddFileReader(c *gc.C) {
 	s.assertFile(c, request, "foo.txt", "test\n")
 }
 
+// testingFileSHA256 is the digest of the content in fileResponse.
+const testingFileSHA256 = "91751cee0a1ab8414400238a761411daa29643ab4b8243e9a91649e25be53ada"
+
+func (s *controllerSuite) TestAddFileVerifiesSHA256(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/files/?op=", http.StatusOK, "")
+	s.server.AddGetResponse("/api/2.0/files/testing/", http.StatusOK, fileResponse)
+	controller := s.getController(c)
+	s.server.ResetRequests()
+	err := controller.AddFile(AddFileArgs{
+		Filename: "testing",
+		Content:  []byte("this is a test\n"),
+		SHA256:   strings.ToUpper(testingFileSHA256),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.server.RequestCount(), gc.Equals, 2)
+}
+
+func (s *controllerSuite) TestAddFileRetriesOnMismatch(c *gc.C) {
+	truncated := updateJSONMap(c, fileResponse, map[string]interface{}{
+		"content": "dGhpcyBpcw==",
+	})
+	s.server.AddPostResponse("/api/2.0/files/?op=", http.StatusOK, "")
+	s.server.AddPostResponse("/api/2.0/files/?op=", http.StatusOK, "")
+	s.server.AddGetResponse("/api/2.0/files/testing/", http.StatusOK, truncated)
+	s.server.AddGetResponse("/api/2.0/files/testing/", http.StatusOK, fileResponse)
+	controller := s.getController(c)
+	s.server.ResetRequests()
+	err := controller.AddFile(AddFileArgs{
+		Filename: "testing",
+		Content:  []byte("this is a test\n"),
+		SHA256:   testingFileSHA256,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.server.RequestCount(), gc.Equals, 4)
+}
+
+func (s *controllerSuite) TestAddFileGivesUpOnMismatch(c *gc.C) {
+	truncated := updateJSONMap(c, fileResponse, map[string]interface{}{
+		"content": "dGhpcyBpcw==",
+	})
+	for i := 0; i < 2; i++ {
+		s.server.AddPostResponse("/api/2.0/files/?op=", http.StatusOK, "")
+		s.server.AddGetResponse("/api/2.0/files/testing/", http.StatusOK, truncated)
+	}
+	controller := s.getController(c)
+	err := controller.AddFile(AddFileArgs{
+		Filename: "testing",
+		Content:  []byte("this is a test\n"),
+		SHA256:   testingFileSHA256,
+		Attempts: 2,
+	})
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+	c.Assert(err.Error(), gc.Matches, `"testing" stored with SHA256 [0-9a-f]{64} after 2 attempts, expected `+testingFileSHA256)
+}
+
+func (s *controllerSuite) TestAddFileReaderNotSeekableUploadedOnce(c *gc.C) {
+	truncated := updateJSONMap(c, fileResponse, map[string]interface{}{
+		"content": "dGhpcyBpcw==",
+	})
+	s.server.AddPostResponse("/api/2.0/files/?op=", http.StatusOK, "")
+	s.server.AddGetResponse("/api/2.0/files/testing/", http.StatusOK, truncated)
+	controller := s.getController(c)
+	s.server.ResetRequests()
+	err := controller.AddFile(AddFileArgs{
+		Filename: "testing",
+		Reader:   bytes.NewBufferString("this is a test\n"),
+		Length:   15,
+		SHA256:   testingFileSHA256,
+	})
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+	c.Assert(s.server.RequestCount(), gc.Equals, 2)
+}
+
 var versionResponse = `{"version": "unknown", "subversion": "", "capabilities": ["networks-management", "static-ipaddresses", "ipv6-deployment-ubuntu", "devices-management", "storage-deployment-ubuntu", "network-deployment-ubuntu"]}`
 
 type cleanup interface {
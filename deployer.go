@@ -0,0 +1,297 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// DefaultMaxInFlight is the number of deployments that a Deployer runs at
+// once when the args do not give one.
+const DefaultMaxInFlight = 10
+
+// DeployJob is a machine for a Deployer to allocate and deploy.
+type DeployJob struct {
+	// ID names the job in the checkpoint and in the errors of Run. It
+	// must be unique within the jobs of a Deployer.
+	ID string
+
+	// Constraints selects the machine to allocate.
+	Constraints AllocateMachineArgs
+	// Start is how the machine is deployed.
+	Start StartArgs
+}
+
+// DeployJobState is the progress of a DeployJob.
+type DeployJobState string
+
+// The states of a DeployJob. A job moves from pending to allocated,
+// deploying and deployed, or to failed from any of them.
+const (
+	DeployJobPending   DeployJobState = "pending"
+	DeployJobAllocated DeployJobState = "allocated"
+	DeployJobDeploying DeployJobState = "deploying"
+	DeployJobDeployed  DeployJobState = "deployed"
+	DeployJobFailed    DeployJobState = "failed"
+)
+
+// DeployJobStatus is the state of a job, as it is saved in a checkpoint.
+type DeployJobStatus struct {
+	ID    string         `json:"id"`
+	State DeployJobState `json:"state"`
+	// SystemID is the machine allocated for the job, once there is one.
+	SystemID string `json:"system_id,omitempty"`
+	// Error is the reason that the job failed.
+	Error string `json:"error,omitempty"`
+}
+
+// DeployCheckpoint is the state of the jobs of a Deployer. It is meant to
+// be saved, as JSON, whenever a job changes, so that a rollout that is
+// interrupted can be resumed by a new Deployer.
+type DeployCheckpoint struct {
+	Jobs []DeployJobStatus `json:"jobs"`
+}
+
+// DeployerArgs is an argument struct for NewDeployer.
+type DeployerArgs struct {
+	Controller Controller
+	Jobs       []DeployJob
+
+	// MaxInFlight is the most jobs that are allocating or deploying at
+	// once, so that the region is not overloaded. If it is zero,
+	// DefaultMaxInFlight is used.
+	MaxInFlight int
+
+	// Checkpoint, if set, resumes the jobs from a checkpoint of an
+	// earlier Deployer. Jobs that were deployed or failed are not run
+	// again, and jobs that had a machine carry on with that machine.
+	// Jobs that are not in the checkpoint start from the beginning.
+	Checkpoint *DeployCheckpoint
+
+	// Wait sets how the deployments are polled. Its Statuses are
+	// ignored.
+	Wait WaitForStatusArgs
+
+	// Changed, if set, is called with the checkpoint after every change
+	// of the state of a job. The calls are not concurrent.
+	Changed func(DeployCheckpoint)
+}
+
+// Validate ensures that there is a controller, and that the jobs have
+// unique IDs.
+func (a *DeployerArgs) Validate() error {
+	if a.Controller == nil {
+		return errors.NotValidf("missing Controller")
+	}
+	if a.MaxInFlight < 0 {
+		return errors.NotValidf("negative MaxInFlight")
+	}
+	seen := make(map[string]bool)
+	for _, job := range a.Jobs {
+		if job.ID == "" {
+			return errors.NotValidf("job with empty ID")
+		}
+		if seen[job.ID] {
+			return errors.NotValidf("duplicate job ID %q", job.ID)
+		}
+		seen[job.ID] = true
+	}
+	return nil
+}
+
+// Deployer allocates and deploys machines for a list of jobs, running at
+// most MaxInFlight of them at once.
+type Deployer struct {
+	controller  Controller
+	jobs        []DeployJob
+	maxInFlight int
+	wait        WaitForStatusArgs
+
+	// changedMu serializes the calls to changed.
+	changedMu sync.Mutex
+	changed   func(DeployCheckpoint)
+
+	// mu guards status.
+	mu     sync.Mutex
+	status map[string]*DeployJobStatus
+}
+
+// NewDeployer returns a Deployer for the jobs of the args.
+func NewDeployer(args DeployerArgs) (*Deployer, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	maxInFlight := args.MaxInFlight
+	if maxInFlight == 0 {
+		maxInFlight = DefaultMaxInFlight
+	}
+	d := &Deployer{
+		controller:  args.Controller,
+		jobs:        args.Jobs,
+		maxInFlight: maxInFlight,
+		wait:        args.Wait,
+		changed:     args.Changed,
+		status:      make(map[string]*DeployJobStatus),
+	}
+	for _, job := range args.Jobs {
+		d.status[job.ID] = &DeployJobStatus{ID: job.ID, State: DeployJobPending}
+	}
+	if args.Checkpoint != nil {
+		for _, saved := range args.Checkpoint.Jobs {
+			if status, ok := d.status[saved.ID]; ok {
+				*status = saved
+			}
+		}
+	}
+	return d, nil
+}
+
+// Checkpoint returns the state of the jobs, in the order they were given.
+func (d *Deployer) Checkpoint() DeployCheckpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.checkpoint()
+}
+
+func (d *Deployer) checkpoint() DeployCheckpoint {
+	result := DeployCheckpoint{Jobs: make([]DeployJobStatus, 0, len(d.jobs))}
+	for _, job := range d.jobs {
+		result.Jobs = append(result.Jobs, *d.status[job.ID])
+	}
+	return result
+}
+
+// Run deploys the jobs that are not yet deployed or failed, and returns
+// when they all are. If any job fails the error is a BulkError, with the
+// results keyed by job ID. If the context is done Run stops starting jobs
+// and returns the error of the context once the running jobs have been
+// abandoned; they keep their state, and the checkpoint resumes them.
+func (d *Deployer) Run(ctx context.Context) error {
+	controller := d.controller.WithContext(ctx)
+	slots := make(chan struct{}, d.maxInFlight)
+	var wg sync.WaitGroup
+	results := NewBulkError()
+	var resultsMu sync.Mutex
+
+	for _, job := range d.jobs {
+		status := d.jobStatus(job.ID)
+		if status.State == DeployJobDeployed || status.State == DeployJobFailed {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case slots <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(job DeployJob) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			err := d.runJob(ctx, controller, job)
+			if err != nil && ctx.Err() != nil {
+				// The job was abandoned rather than failed.
+				return
+			}
+			if err != nil {
+				d.setStatus(job.ID, func(s *DeployJobStatus) {
+					s.State = DeployJobFailed
+					s.Error = err.Error()
+				})
+			}
+			resultsMu.Lock()
+			results.Add(job.ID, err)
+			resultsMu.Unlock()
+		}(job)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return errors.Trace(err)
+	}
+	for _, job := range d.jobs {
+		status := d.jobStatus(job.ID)
+		if status.State == DeployJobFailed && results.Err(job.ID) == nil {
+			// Failed before the checkpoint was taken.
+			results.Add(job.ID, errors.New(status.Error))
+		}
+	}
+	if results.HasFailures() {
+		return results
+	}
+	return nil
+}
+
+// runJob takes the job from its current state to deployed.
+func (d *Deployer) runJob(ctx context.Context, controller Controller, job DeployJob) error {
+	var machine Machine
+	status := d.jobStatus(job.ID)
+	if status.SystemID == "" {
+		allocated, _, err := controller.AllocateMachine(job.Constraints)
+		if err != nil {
+			return errors.Annotate(err, "allocating machine")
+		}
+		machine = allocated
+		d.setStatus(job.ID, func(s *DeployJobStatus) {
+			s.State = DeployJobAllocated
+			s.SystemID = machine.SystemID()
+		})
+	} else {
+		machines, err := controller.Machines(MachinesArgs{SystemIDs: []string{status.SystemID}})
+		if err != nil {
+			return errors.Annotatef(err, "reading machine %s", status.SystemID)
+		}
+		if len(machines) != 1 {
+			return errors.NotFoundf("machine %s", status.SystemID)
+		}
+		machine = machines[0]
+	}
+
+	switch statusName := machine.StatusName(); {
+	case isFailedStatus(statusName):
+		return NewMachineFailedError(machine.SystemID(), statusName, machine.StatusMessage())
+	case statusName == "Deployed":
+	case statusName == "Deploying":
+		d.setStatus(job.ID, func(s *DeployJobStatus) { s.State = DeployJobDeploying })
+	default:
+		if err := machine.Start(job.Start); err != nil {
+			return errors.Annotatef(err, "deploying machine %s", machine.SystemID())
+		}
+		d.setStatus(job.ID, func(s *DeployJobStatus) { s.State = DeployJobDeploying })
+	}
+
+	wait := d.wait
+	wait.Statuses = []string{"Deployed"}
+	if err := machine.WaitForStatus(ctx, wait); err != nil {
+		return errors.Annotatef(err, "waiting for machine %s", machine.SystemID())
+	}
+	d.setStatus(job.ID, func(s *DeployJobStatus) { s.State = DeployJobDeployed })
+	return nil
+}
+
+func (d *Deployer) jobStatus(id string) DeployJobStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return *d.status[id]
+}
+
+// setStatus changes the status of the job, and reports the checkpoint to
+// the changed func.
+func (d *Deployer) setStatus(id string, update func(*DeployJobStatus)) {
+	// changedMu is held across the update so that the checkpoints are
+	// reported in the order of the changes.
+	d.changedMu.Lock()
+	defer d.changedMu.Unlock()
+	d.mu.Lock()
+	update(d.status[id])
+	checkpoint := d.checkpoint()
+	d.mu.Unlock()
+	if d.changed != nil {
+		d.changed(checkpoint)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type deployerSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&deployerSuite{})
+
+func deployerMachineResponse(c *gc.C, systemID, statusName string) string {
+	return updateJSONMap(c, machineResponse, map[string]interface{}{
+		"system_id":    systemID,
+		"resource_uri": "/MAAS/api/2.0/machines/" + systemID + "/",
+		"status_name":  statusName,
+	})
+}
+
+func (s *deployerSuite) TestDeployerValidates(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	_, err := NewDeployer(DeployerArgs{Jobs: []DeployJob{{ID: "a"}}})
+	c.Check(err, gc.ErrorMatches, "missing Controller not valid")
+	_, err = NewDeployer(DeployerArgs{Controller: controller, Jobs: []DeployJob{{ID: "a"}, {ID: "a"}}})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, `duplicate job ID "a" not valid`)
+	_, err = NewDeployer(DeployerArgs{Controller: controller, Jobs: []DeployJob{{}}})
+	c.Check(err, gc.ErrorMatches, "job with empty ID not valid")
+}
+
+func (s *deployerSuite) TestRun(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, deployerMachineResponse(c, "m1", "Allocated"))
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, deployerMachineResponse(c, "m2", "Allocated"))
+	server.AddPostResponse("/MAAS/api/2.0/machines/m1/?op=deploy", http.StatusOK, deployerMachineResponse(c, "m1", "Deployed"))
+	server.AddPostResponse("/MAAS/api/2.0/machines/m2/?op=deploy", http.StatusOK, deployerMachineResponse(c, "m2", "Deployed"))
+
+	var states []DeployJobState
+	deployer, err := NewDeployer(DeployerArgs{
+		Controller: controller,
+		Jobs: []DeployJob{
+			{ID: "web-1", Constraints: AllocateMachineArgs{Tags: []string{"web"}}, Start: StartArgs{DistroSeries: "jammy"}},
+			{ID: "web-2", Constraints: AllocateMachineArgs{Tags: []string{"web"}}, Start: StartArgs{DistroSeries: "jammy"}},
+		},
+		MaxInFlight: 1,
+		Changed: func(checkpoint DeployCheckpoint) {
+			states = append(states, checkpoint.Jobs[0].State)
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(deployer.Checkpoint(), jc.DeepEquals, DeployCheckpoint{Jobs: []DeployJobStatus{
+		{ID: "web-1", State: DeployJobPending},
+		{ID: "web-2", State: DeployJobPending},
+	}})
+
+	err = deployer.Run(context.Background())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(deployer.Checkpoint(), jc.DeepEquals, DeployCheckpoint{Jobs: []DeployJobStatus{
+		{ID: "web-1", State: DeployJobDeployed, SystemID: "m1"},
+		{ID: "web-2", State: DeployJobDeployed, SystemID: "m2"},
+	}})
+	c.Check(states[:3], jc.DeepEquals, []DeployJobState{DeployJobAllocated, DeployJobDeploying, DeployJobDeployed})
+	c.Check(states, gc.HasLen, 6)
+	c.Check(server.LastRequest().PostForm.Get("distro_series"), gc.Equals, "jammy")
+}
+
+func (s *deployerSuite) TestRunFailures(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, deployerMachineResponse(c, "m1", "Allocated"))
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "No machine available.")
+	server.AddPostResponse("/MAAS/api/2.0/machines/m1/?op=deploy", http.StatusOK, deployerMachineResponse(c, "m1", "Failed deployment"))
+
+	deployer, err := NewDeployer(DeployerArgs{
+		Controller:  controller,
+		Jobs:        []DeployJob{{ID: "a"}, {ID: "b"}},
+		MaxInFlight: 1,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = deployer.Run(context.Background())
+	c.Assert(err, jc.Satisfies, IsBulkError)
+	bulk := err.(*BulkError)
+	c.Check(bulk.Failed(), jc.DeepEquals, []string{"a", "b"})
+	c.Check(bulk.Err("a"), jc.Satisfies, IsMachineFailedError)
+	c.Check(bulk.Err("b"), jc.Satisfies, IsNoMatchError)
+
+	checkpoint := deployer.Checkpoint()
+	c.Check(checkpoint.Jobs[0].State, gc.Equals, DeployJobFailed)
+	c.Check(checkpoint.Jobs[0].SystemID, gc.Equals, "m1")
+	c.Check(checkpoint.Jobs[1].State, gc.Equals, DeployJobFailed)
+	c.Check(checkpoint.Jobs[1].Error, gc.Equals, "allocating machine: No machine available.")
+}
+
+func (s *deployerSuite) TestResume(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?id=m2", http.StatusOK, "["+deployerMachineResponse(c, "m2", "Allocated")+"]")
+	server.AddPostResponse("/MAAS/api/2.0/machines/m2/?op=deploy", http.StatusOK, deployerMachineResponse(c, "m2", "Deployed"))
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, deployerMachineResponse(c, "m3", "Allocated"))
+	server.AddPostResponse("/MAAS/api/2.0/machines/m3/?op=deploy", http.StatusOK, deployerMachineResponse(c, "m3", "Deployed"))
+
+	// The checkpoint survives a round trip through JSON.
+	saved, err := json.Marshal(DeployCheckpoint{Jobs: []DeployJobStatus{
+		{ID: "a", State: DeployJobDeployed, SystemID: "m1"},
+		{ID: "b", State: DeployJobAllocated, SystemID: "m2"},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	var checkpoint DeployCheckpoint
+	c.Assert(json.Unmarshal(saved, &checkpoint), jc.ErrorIsNil)
+
+	deployer, err := NewDeployer(DeployerArgs{
+		Controller:  controller,
+		Jobs:        []DeployJob{{ID: "a"}, {ID: "b"}, {ID: "c"}},
+		MaxInFlight: 1,
+		Checkpoint:  &checkpoint,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = deployer.Run(context.Background())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(deployer.Checkpoint(), jc.DeepEquals, DeployCheckpoint{Jobs: []DeployJobStatus{
+		{ID: "a", State: DeployJobDeployed, SystemID: "m1"},
+		{ID: "b", State: DeployJobDeployed, SystemID: "m2"},
+		{ID: "c", State: DeployJobDeployed, SystemID: "m3"},
+	}})
+	// Nothing is asked about the machine that was already deployed.
+	for _, request := range server.LastNRequests(server.RequestCount()) {
+		c.Check(request.URL.Path, gc.Not(gc.Equals), "/MAAS/api/2.0/machines/m1/")
+	}
+}
+
+func (s *deployerSuite) TestRunCancelled(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	deployer, err := NewDeployer(DeployerArgs{
+		Controller: controller,
+		Jobs:       []DeployJob{{ID: "a"}},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	count := server.RequestCount()
+	err = deployer.Run(ctx)
+	c.Check(errors.Cause(err), gc.Equals, context.Canceled)
+	c.Check(server.RequestCount(), gc.Equals, count)
+	c.Check(deployer.Checkpoint().Jobs[0].State, gc.Equals, DeployJobPending)
+}
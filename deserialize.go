@@ -0,0 +1,100 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// MaxResponseDepth is the deepest nesting of JSON objects and arrays
+// accepted in a response. The deepest response MAAS sends, a machine with
+// its interfaces, links, subnets and VLANs, is well inside it.
+const MaxResponseDepth = 32
+
+// parseResponse decodes a JSON response, failing with a DeserializationError
+// if it is nested deeper than MaxResponseDepth.
+func parseResponse(bytes []byte) (interface{}, error) {
+	var parsed interface{}
+	if err := json.Unmarshal(bytes, &parsed); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := checkDepth(parsed, 0, ""); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return parsed, nil
+}
+
+func checkDepth(value interface{}, depth int, path string) error {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if depth >= MaxResponseDepth {
+			return deserializationErrorAt(path, "response nested deeper than %d levels", MaxResponseDepth)
+		}
+		for key, elem := range value {
+			if err := checkDepth(elem, depth+1, joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if depth >= MaxResponseDepth {
+			return deserializationErrorAt(path, "response nested deeper than %d levels", MaxResponseDepth)
+		}
+		for i, elem := range value {
+			if err := checkDepth(elem, depth+1, joinPath(path, indexPath(i))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func deserializationErrorAt(path, format string, args ...interface{}) error {
+	err := &DeserializationError{Err: errors.NewErr(format, args...), path: path}
+	err.SetLocation(1)
+	return err
+}
+
+// atPath records that the value that caused err, if err was caused by a
+// DeserializationError, is found under element, a field name or an
+// indexPath. The readers call it as the error passes back up through them,
+// so the path is built from the innermost value out.
+func atPath(err error, element string) error {
+	if derr, ok := errors.Cause(err).(*DeserializationError); ok {
+		derr.path = joinPath(element, derr.path)
+	}
+	return err
+}
+
+func indexPath(i int) string {
+	return fmt.Sprintf("[%d]", i)
+}
+
+func joinPath(parent, child string) string {
+	switch {
+	case parent == "":
+		return child
+	case child == "", strings.HasPrefix(child, "["):
+		return parent + child
+	}
+	return parent + "." + child
+}
+
+// errorPath returns the path of a DeserializationError, or of a schema
+// error, whose messages start with the path of the bad value, such as
+// `links[0].subnet: expected map, got string("x")`.
+func errorPath(err error) string {
+	if derr, ok := errors.Cause(err).(*DeserializationError); ok {
+		return derr.path
+	}
+	message := err.Error()
+	end := strings.Index(message, ": ")
+	if end <= 0 || strings.ContainsAny(message[:end], " \t\n") {
+		return ""
+	}
+	return message[:end]
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type deserializeSuite struct{}
+
+var _ = gc.Suite(&deserializeSuite{})
+
+func (*deserializeSuite) TestMachineErrorPath(c *gc.C) {
+	source := parseJSON(c, machinesResponse)
+	machines := source.([]interface{})
+	iface := machines[1].(map[string]interface{})["interface_set"].([]interface{})[0]
+	link := iface.(map[string]interface{})["links"].([]interface{})[0]
+	link.(map[string]interface{})["subnet"] = "not a map"
+
+	_, err := readMachines(twoDotOh, source)
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Check(DeserializationErrorPath(err), gc.Equals, "machines[1].interface_set[0].links[0].subnet")
+	c.Check(err, gc.ErrorMatches, `machine 1: interface 0: link 0: link 2.0 schema check failed: subnet: expected map, got string\("not a map"\)`)
+}
+
+func (*deserializeSuite) TestNestedErrorPath(c *gc.C) {
+	source := parseJSON(c, machinesResponse)
+	machines := source.([]interface{})
+	iface := machines[0].(map[string]interface{})["interface_set"].([]interface{})[0]
+	link := iface.(map[string]interface{})["links"].([]interface{})[0]
+	subnet := link.(map[string]interface{})["subnet"].(map[string]interface{})
+	subnet["vlan"].(map[string]interface{})["vid"] = "one"
+
+	_, err := readMachines(twoDotOh, source)
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Check(DeserializationErrorPath(err), gc.Equals, "machines[0].interface_set[0].links[0].subnet.vlan.vid")
+}
+
+func (*deserializeSuite) TestListErrorPath(c *gc.C) {
+	_, err := readMachines(twoDotOh, []interface{}{"not a map"})
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Check(DeserializationErrorPath(err), gc.Equals, "machines[0]")
+
+	_, err = readDevices(twoDotOh, parseJSON(c, "["+deviceResponse+", 42]"))
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Check(DeserializationErrorPath(err), gc.Equals, "devices[1]")
+}
+
+func (*deserializeSuite) TestErrorPathNotDeserialization(c *gc.C) {
+	c.Check(DeserializationErrorPath(fmt.Errorf("links[0]: boom")), gc.Equals, "")
+}
+
+func (*deserializeSuite) TestParseResponseDepth(c *gc.C) {
+	deep := strings.Repeat(`{"a":[`, MaxResponseDepth/2) + "1" + strings.Repeat("]}", MaxResponseDepth/2)
+	_, err := parseResponse([]byte(deep))
+	c.Assert(err, jc.ErrorIsNil)
+
+	deeper := `{"b":` + deep + "}"
+	_, err = parseResponse([]byte(deeper))
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Check(err, gc.ErrorMatches, "response nested deeper than 32 levels")
+	c.Check(DeserializationErrorPath(err), gc.Equals, "b"+strings.Repeat(".a[0]", MaxResponseDepth/2-1)+".a")
+}
+
+func (*deserializeSuite) TestFuzzMachines(c *gc.C) {
+	fuzzReader(c, machinesResponse, func(source interface{}) error {
+		_, err := readMachines(twoDotOh, source)
+		return err
+	})
+}
+
+func (*deserializeSuite) TestFuzzDevices(c *gc.C) {
+	fuzzReader(c, devicesResponse, func(source interface{}) error {
+		_, err := readDevices(twoDotOh, source)
+		return err
+	})
+}
+
+func (*deserializeSuite) TestFuzzInterfaces(c *gc.C) {
+	fuzzReader(c, interfacesResponse, func(source interface{}) error {
+		_, err := readInterfaces(twoDotOh, source)
+		return err
+	})
+}
+
+// fuzzReader checks that the reader neither panics nor fails with anything
+// but a DeserializationError when given many random corruptions of the
+// response. The seed is fixed so that failures can be reproduced.
+func fuzzReader(c *gc.C, response string, read func(interface{}) error) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		source := parseJSON(c, response)
+		var mutations []string
+		for n := rng.Intn(3) + 1; n > 0; n-- {
+			mutations = append(mutations, mutate(rng, source, ""))
+		}
+		err := func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return read(source)
+		}()
+		if err != nil && !IsDeserializationError(err) {
+			c.Fatalf("iteration %d, mutations %v: %v", i, mutations, err)
+		}
+	}
+}
+
+var fuzzValues = []interface{}{
+	nil, "", "junk", float64(-1), 1.5, true,
+	[]interface{}{}, []interface{}{"junk"}, map[string]interface{}{},
+}
+
+// mutate replaces a random value in the container with a fuzzValue, and
+// returns its path.
+func mutate(rng *rand.Rand, value interface{}, path string) string {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if len(value) == 0 {
+			return path
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		// Map iteration order is random, so sort for a repeatable choice.
+		sort.Strings(keys)
+		key := keys[rng.Intn(len(keys))]
+		if isContainer(value[key]) && rng.Intn(3) > 0 {
+			return mutate(rng, value[key], joinPath(path, key))
+		}
+		value[key] = fuzzValues[rng.Intn(len(fuzzValues))]
+		return joinPath(path, key)
+	case []interface{}:
+		if len(value) == 0 {
+			return path
+		}
+		i := rng.Intn(len(value))
+		if isContainer(value[i]) && rng.Intn(3) > 0 {
+			return mutate(rng, value[i], joinPath(path, indexPath(i)))
+		}
+		value[i] = fuzzValues[rng.Intn(len(fuzzValues))]
+		return joinPath(path, indexPath(i))
+	}
+	return path
+}
+
+func isContainer(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
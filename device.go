@@ -15,12 +15,15 @@ import (
 
 type device struct {
 	controller *controller
+	// generation is when the device was read, see stale.go.
+	generation uint64
 
 	resourceURI string
 
 	systemID string
 	hostname string
 	fqdn     string
+	nodeType NodeType
 
 	parent string
 	owner  string
@@ -29,6 +32,7 @@ type device struct {
 	interfaceSet []*interface_
 	zone         *zone
 	pool         *pool
+	domain       *domain
 }
 
 // SystemID implements Device.
@@ -46,6 +50,11 @@ func (d *device) FQDN() string {
 	return d.fqdn
 }
 
+// NodeType implements Device.
+func (d *device) NodeType() NodeType {
+	return d.nodeType
+}
+
 // Parent implements Device.
 func (d *device) Parent() string {
 	return d.parent
@@ -77,6 +86,17 @@ func (d *device) Pool() Pool {
 	return d.pool
 }
 
+// Domain implements Device.
+func (d *device) Domain() Domain {
+	if d.domain == nil {
+		return nil
+	}
+	// The domain is read with the device, so it picks up the controller
+	// when it is needed for Update.
+	d.domain.controller = d.controller
+	return d.domain
+}
+
 // InterfaceSet implements Device.
 func (d *device) InterfaceSet() []Interface {
 	result := make([]Interface, len(d.interfaceSet))
@@ -123,11 +143,14 @@ func (a *CreateInterfaceArgs) Validate() error {
 // interfacesURI used to add interfaces for this device. The operations
 // are on the nodes endpoint, not devices.
 func (d *device) interfacesURI() string {
-	return strings.Replace(d.resourceURI, "devices", "nodes", 1) + "interfaces/"
+	return strings.Replace(d.resourceURI, DevicesPath, NodesPath, 1) + "interfaces/"
 }
 
 // CreateInterface implements Device.
 func (d *device) CreateInterface(args CreateInterfaceArgs) (Interface, error) {
+	if err := d.controller.checkStale(d.systemID, d.generation); err != nil {
+		return nil, errors.Trace(err)
+	}
 	if err := args.Validate(); err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -154,7 +177,7 @@ func (d *device) CreateInterface(args CreateInterfaceArgs) (Interface, error) {
 		return nil, NewUnexpectedError(err)
 	}
 
-	iface, err := readInterface(d.controller.apiVersion, result)
+	iface, err := readInterface(d.controller.schemaVersion(), result)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -165,8 +188,97 @@ func (d *device) CreateInterface(args CreateInterfaceArgs) (Interface, error) {
 	return iface, nil
 }
 
+// AllocateStickyIPArgs is an argument struct for Device.AllocateStickyIP.
+// Subnet and MACAddress are required.
+type AllocateStickyIPArgs struct {
+	// Subnet is the subnet to allocate the address in.
+	Subnet Subnet
+	// MACAddress selects the interface of the device. If the device has
+	// no interface with the MAC address, one is created on the VLAN of
+	// the subnet.
+	MACAddress string
+	// InterfaceName names the interface if one is created. It is "eth"
+	// followed by the number of interfaces of the device if empty.
+	InterfaceName string
+	// IPAddress is the address to allocate. The server picks a free one
+	// in the subnet if it is empty.
+	IPAddress string
+}
+
+// Validate ensures that the subnet and MAC address are set.
+func (a *AllocateStickyIPArgs) Validate() error {
+	if a.Subnet == nil {
+		return errors.NotValidf("missing Subnet")
+	}
+	if a.MACAddress == "" {
+		return errors.NotValidf("missing MACAddress")
+	}
+	return nil
+}
+
+// AllocateStickyIP implements Device.
+func (d *device) AllocateStickyIP(args AllocateStickyIPArgs) (_ Interface, err error) {
+	if err := d.controller.checkStale(d.systemID, d.generation); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var iface *interface_
+	created := false
+	for _, candidate := range d.interfaceSet {
+		if strings.EqualFold(candidate.macAddress, args.MACAddress) {
+			candidate.controller = d.controller
+			iface = candidate
+			break
+		}
+	}
+	if iface == nil {
+		name := args.InterfaceName
+		if name == "" {
+			name = fmt.Sprintf("eth%d", len(d.interfaceSet))
+		}
+		var newIface Interface
+		newIface, err = d.CreateInterface(CreateInterfaceArgs{
+			Name:       name,
+			MACAddress: args.MACAddress,
+			VLAN:       args.Subnet.VLAN(),
+		})
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		iface = newIface.(*interface_)
+		created = true
+		defer func() {
+			// Remove the interface that was created for the address if
+			// the address could not be allocated.
+			if err != nil {
+				if innerErr := iface.Delete(); innerErr != nil {
+					controllerLogger.Warningf("could not delete interface %q of device %q", iface.Name(), d.systemID)
+				}
+			}
+		}()
+	}
+
+	err = iface.LinkSubnet(LinkSubnetArgs{
+		Mode:      LinkModeStatic,
+		Subnet:    args.Subnet,
+		IPAddress: args.IPAddress,
+	})
+	if err != nil {
+		return nil, errors.Annotatef(err, "allocating address in %q for device %q", args.Subnet.CIDR(), d.systemID)
+	}
+	if created {
+		d.interfaceSet = append(d.interfaceSet, iface)
+	}
+	return iface, nil
+}
+
 // Delete implements Device.
 func (d *device) Delete() error {
+	if err := d.controller.checkStale(d.systemID, d.generation); err != nil {
+		return errors.Trace(err)
+	}
 	err := d.controller.delete(d.resourceURI)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
@@ -179,6 +291,7 @@ func (d *device) Delete() error {
 		}
 		return NewUnexpectedError(err)
 	}
+	d.controller.markStale(d.systemID, "deleted")
 	return nil
 }
 
@@ -194,10 +307,10 @@ func readDevice(controllerVersion version.Number, source interface{}) (*device,
 		return nil, WrapWithDeserializationError(err, "device base schema check failed")
 	}
 	valid := coerced.(map[string]interface{})
-	return readFunc(valid)
+	return readFunc(valid, strictReading)
 }
 
-func readDevices(controllerVersion version.Number, source interface{}) ([]*device, error) {
+func readDevices(controllerVersion version.Number, source interface{}, options ...ReadOption) ([]*device, error) {
 	readFunc, err := getDeviceDeserializationFunc(controllerVersion)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -206,10 +319,14 @@ func readDevices(controllerVersion version.Number, source interface{}) ([]*devic
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, WrapWithDeserializationError(err, "device base schema check failed")
+		return nil, atPath(WrapWithDeserializationError(err, "device base schema check failed"), "devices")
 	}
 	valid := coerced.([]interface{})
-	return readDeviceList(valid, readFunc)
+	result, err := readDeviceList(valid, readFunc, collectReadOptions(options))
+	if err != nil {
+		return nil, atPath(err, "devices")
+	}
+	return result, nil
 }
 
 func getDeviceDeserializationFunc(controllerVersion version.Number) (deviceDeserializationFunc, error) {
@@ -226,35 +343,38 @@ func getDeviceDeserializationFunc(controllerVersion version.Number) (deviceDeser
 }
 
 // readDeviceList expects the values of the sourceList to be string maps.
-func readDeviceList(sourceList []interface{}, readFunc deviceDeserializationFunc) ([]*device, error) {
+func readDeviceList(sourceList []interface{}, readFunc deviceDeserializationFunc, options readOptions) ([]*device, error) {
 	result := make([]*device, 0, len(sourceList))
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, NewDeserializationError("unexpected value for device %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for device %d, %T", i, value), indexPath(i))
 		}
-		device, err := readFunc(source)
-		if err != nil {
-			return nil, errors.Annotatef(err, "device %d", i)
+		device, err := readFunc(source, options)
+		if skip, err := options.item(err, "device", i, indexPath(i)); err != nil {
+			return nil, err
+		} else if skip {
+			continue
 		}
 		result = append(result, device)
 	}
 	return result, nil
 }
 
-type deviceDeserializationFunc func(map[string]interface{}) (*device, error)
+type deviceDeserializationFunc func(map[string]interface{}, readOptions) (*device, error)
 
 var deviceDeserializationFuncs = map[version.Number]deviceDeserializationFunc{
 	twoDotOh: device_2_0,
 }
 
-func device_2_0(source map[string]interface{}) (*device, error) {
+func device_2_0(source map[string]interface{}, options readOptions) (*device, error) {
 	fields := schema.Fields{
 		"resource_uri": schema.String(),
 
 		"system_id": schema.String(),
 		"hostname":  schema.String(),
 		"fqdn":      schema.String(),
+		"node_type": schema.ForceInt(),
 		"parent":    schema.OneOf(schema.Nil(""), schema.String()),
 		"owner":     schema.OneOf(schema.Nil(""), schema.String()),
 
@@ -262,34 +382,44 @@ func device_2_0(source map[string]interface{}) (*device, error) {
 		"interface_set": schema.List(schema.StringMap(schema.Any())),
 		"zone":          schema.StringMap(schema.Any()),
 		"pool":          schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+		"domain":        schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
 	}
 	defaults := schema.Defaults{
-		"owner":  "",
-		"parent": "",
+		"node_type": int(NodeTypeDevice),
+		"owner":     "",
+		"parent":    "",
+		"domain":    nil,
 	}
-	checker := schema.FieldMap(fields, defaults)
-	coerced, err := checker.Coerce(source, nil)
+	valid, err := options.coerce("device", fields, defaults, source)
 	if err != nil {
 		return nil, WrapWithDeserializationError(err, "device 2.0 schema check failed")
 	}
-	valid := coerced.(map[string]interface{})
 	// From here we know that the map returned from the schema coercion
 	// contains fields of the right type.
 
 	interfaceSet, err := readInterfaceList(valid["interface_set"].([]interface{}), interface_2_0)
-	if err != nil {
-		return nil, errors.Trace(err)
+	if err = options.omit(err, "device", "interface_set"); err != nil {
+		return nil, errors.Trace(atPath(err, "interface_set"))
 	}
 
 	zone, err := zone_2_0(valid["zone"].(map[string]interface{}))
-	if err != nil {
-		return nil, errors.Trace(err)
+	if err = options.omit(err, "device", "zone"); err != nil {
+		return nil, errors.Trace(atPath(err, "zone"))
 	}
 
 	var pool *pool
 	if valid["pool"] != nil {
-		if pool, err = pool_2_0(valid["pool"].(map[string]interface{})); err != nil {
-			return nil, errors.Trace(err)
+		pool, err = pool_2_0(valid["pool"].(map[string]interface{}))
+		if err = options.omit(err, "device", "pool"); err != nil {
+			return nil, errors.Trace(atPath(err, "pool"))
+		}
+	}
+
+	var domain *domain
+	if valid["domain"] != nil {
+		domain, err = domain_2_0(valid["domain"].(map[string]interface{}))
+		if err = options.omit(err, "device", "domain"); err != nil {
+			return nil, errors.Trace(atPath(err, "domain"))
 		}
 	}
 
@@ -301,6 +431,7 @@ func device_2_0(source map[string]interface{}) (*device, error) {
 		systemID: valid["system_id"].(string),
 		hostname: valid["hostname"].(string),
 		fqdn:     valid["fqdn"].(string),
+		nodeType: NodeType(valid["node_type"].(int)),
 		parent:   parent,
 		owner:    owner,
 
@@ -308,6 +439,7 @@ func device_2_0(source map[string]interface{}) (*device, error) {
 		interfaceSet: interfaceSet,
 		zone:         zone,
 		pool:         pool,
+		domain:       domain,
 	}
 	return result, nil
 }
@@ -39,10 +39,14 @@ func (*deviceSuite) TestReadDevices(c *gc.C) {
 	c.Check(device.SystemID(), gc.Equals, "4y3haf")
 	c.Check(device.Hostname(), gc.Equals, "furnacelike-brittney")
 	c.Check(device.FQDN(), gc.Equals, "furnacelike-brittney.maas")
+	c.Check(device.NodeType(), gc.Equals, NodeTypeDevice)
 	c.Check(device.IPAddresses(), jc.DeepEquals, []string{"192.168.100.11"})
 	zone := device.Zone()
 	c.Check(zone, gc.NotNil)
 	c.Check(zone.Name(), gc.Equals, "default")
+	domain := device.Domain()
+	c.Check(domain, gc.NotNil)
+	c.Check(domain.Name(), gc.Equals, "maas")
 	pool := device.Pool()
 	c.Check(pool, gc.NotNil)
 	c.Check(pool.Name(), gc.Equals, "default")
@@ -64,6 +68,21 @@ func (*deviceSuite) TestReadDevicesNils(c *gc.C) {
 	c.Check(device.Pool(), gc.IsNil)
 }
 
+func (*deviceSuite) TestReadDevicesNotStrict(c *gc.C) {
+	json := parseJSON(c, devicesResponse)
+	deviceMap := json.([]interface{})[0].(map[string]interface{})
+	deviceMap["zone"] = "not a map"
+
+	_, err := readDevices(twoDotOh, json, WithStrictParsing(true))
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+
+	devices, err := readDevices(twoDotOh, json, WithStrictParsing(false))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 1)
+	c.Check(devices[0].SystemID(), gc.Equals, "4y3haf")
+	c.Check(devices[0].Zone(), gc.IsNil)
+}
+
 func (*deviceSuite) TestLowVersion(c *gc.C) {
 	_, err := readDevices(version.MustParse("1.9.0"), parseJSON(c, devicesResponse))
 	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
@@ -192,6 +211,101 @@ func (s *deviceSuite) TestCreateInterfaceUnknown(c *gc.C) {
 	c.Assert(err.Error(), gc.Equals, "unexpected: ServerError: 405 Method Not Allowed (wat?)")
 }
 
+func (s *deviceSuite) TestAllocateStickyIPValidates(c *gc.C) {
+	_, device := s.getServerAndDevice(c)
+	_, err := device.AllocateStickyIP(AllocateStickyIPArgs{MACAddress: "78:f0:f1:16:a7:46"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, "missing Subnet not valid")
+	_, err = device.AllocateStickyIP(AllocateStickyIPArgs{Subnet: &fakeSubnet{id: 42}})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, "missing MACAddress not valid")
+}
+
+func (s *deviceSuite) TestAllocateStickyIPExistingInterface(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3haf/interfaces/48/?op=link_subnet", http.StatusOK, interfaceResponse)
+
+	iface, err := device.AllocateStickyIP(AllocateStickyIPArgs{
+		Subnet:     &fakeSubnet{id: 42, vlan: &fakeVLAN{id: 1}},
+		MACAddress: "78:F0:F1:16:A7:46",
+		IPAddress:  "10.10.10.10",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.ID(), gc.Equals, 40)
+	c.Check(device.InterfaceSet(), gc.HasLen, 2)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("mode"), gc.Equals, "STATIC")
+	c.Check(form.Get("subnet"), gc.Equals, "42")
+	c.Check(form.Get("ip_address"), gc.Equals, "10.10.10.10")
+}
+
+func (s *deviceSuite) TestAllocateStickyIPCreatesInterface(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddPostResponse(device.interfacesURI()+"?op=create_physical", http.StatusOK, interfaceResponse)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha6/interfaces/40/?op=link_subnet", http.StatusOK, interfaceResponse)
+
+	iface, err := device.AllocateStickyIP(AllocateStickyIPArgs{
+		Subnet:     &fakeSubnet{id: 42, vlan: &fakeVLAN{id: 33}},
+		MACAddress: "52:54:00:c9:6a:45",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.ID(), gc.Equals, 40)
+	c.Check(device.InterfaceSet(), gc.HasLen, 3)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("mode"), gc.Equals, "STATIC")
+	c.Check(form.Get("subnet"), gc.Equals, "42")
+	c.Check(form.Get("ip_address"), gc.Equals, "")
+}
+
+func (s *deviceSuite) TestAllocateStickyIPCreatedInterfaceName(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddPostResponse(device.interfacesURI()+"?op=create_physical", http.StatusOK, interfaceResponse)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha6/interfaces/40/?op=link_subnet", http.StatusOK, interfaceResponse)
+
+	_, err := device.AllocateStickyIP(AllocateStickyIPArgs{
+		Subnet:     &fakeSubnet{id: 42, vlan: &fakeVLAN{id: 33}},
+		MACAddress: "52:54:00:c9:6a:45",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	form := server.LastNRequests(2)[0].PostForm
+	c.Check(form.Get("name"), gc.Equals, "eth2")
+	c.Check(form.Get("mac_address"), gc.Equals, "52:54:00:c9:6a:45")
+	c.Check(form.Get("vlan"), gc.Equals, "33")
+}
+
+func (s *deviceSuite) TestAllocateStickyIPFailureDeletesCreatedInterface(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddPostResponse(device.interfacesURI()+"?op=create_physical", http.StatusOK, interfaceResponse)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha6/interfaces/40/?op=link_subnet", http.StatusServiceUnavailable, "no free addresses")
+	server.AddDeleteResponse("/MAAS/api/2.0/nodes/4y3ha6/interfaces/40/", http.StatusNoContent, "")
+
+	_, err := device.AllocateStickyIP(AllocateStickyIPArgs{
+		Subnet:     &fakeSubnet{id: 42, cidr: "10.0.0.0/24", vlan: &fakeVLAN{id: 33}},
+		MACAddress: "52:54:00:c9:6a:45",
+	})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err, gc.ErrorMatches, `allocating address in "10.0.0.0/24" for device "4y3haf": no free addresses`)
+	c.Check(device.InterfaceSet(), gc.HasLen, 2)
+
+	request := server.LastRequest()
+	c.Check(request.Method, gc.Equals, "DELETE")
+	c.Check(request.URL.Path, gc.Equals, "/MAAS/api/2.0/nodes/4y3ha6/interfaces/40/")
+}
+
+func (s *deviceSuite) TestAllocateStickyIPFailureKeepsExistingInterface(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3haf/interfaces/48/?op=link_subnet", http.StatusServiceUnavailable, "no free addresses")
+
+	_, err := device.AllocateStickyIP(AllocateStickyIPArgs{
+		Subnet:     &fakeSubnet{id: 42, vlan: &fakeVLAN{id: 1}},
+		MACAddress: "78:f0:f1:16:a7:46",
+	})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(server.LastRequest().Method, gc.Equals, "POST")
+}
+
 func (s *deviceSuite) getServerAndDevice(c *gc.C) (*SimpleTestServer, *device) {
 	server, controller := createTestServerController(c, s)
 	server.AddGetResponse("/api/2.0/devices/", http.StatusOK, devicesResponse)
@@ -210,6 +324,21 @@ func (s *deviceSuite) TestDelete(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 }
 
+func (s *deviceSuite) TestDeleteMakesStale(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddDeleteResponse(device.resourceURI, http.StatusNoContent, "")
+	err := device.Delete()
+	c.Assert(err, jc.ErrorIsNil)
+	server.ResetRequests()
+
+	err = device.Delete()
+	c.Check(err, jc.Satisfies, IsStaleObjectError)
+	c.Check(err, gc.ErrorMatches, "4y3haf was deleted and is stale")
+	_, err = device.CreateInterface(minimalCreateInterfaceArgs())
+	c.Check(err, jc.Satisfies, IsStaleObjectError)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
 func (s *deviceSuite) TestDelete404(c *gc.C) {
 	_, device := s.getServerAndDevice(c)
 	// No path, so 404
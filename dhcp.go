@@ -0,0 +1,215 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// ipRange is the part of an IP range that EnableDHCP needs to look at.
+type ipRange struct {
+	rangeType string
+	startIP   string
+	endIP     string
+	subnetID  int
+}
+
+func readIPRanges(source interface{}) ([]ipRange, error) {
+	fields := schema.Fields{
+		"type":     schema.String(),
+		"start_ip": schema.String(),
+		"end_ip":   schema.String(),
+		"subnet":   schema.StringMap(schema.Any()),
+	}
+	checker := schema.List(schema.FieldMap(fields, nil))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ip range schema check failed")
+	}
+	var result []ipRange
+	for i, value := range coerced.([]interface{}) {
+		valid := value.(map[string]interface{})
+		id, err := schema.ForceInt().Coerce(valid["subnet"].(map[string]interface{})["id"], nil)
+		if err != nil {
+			return nil, WrapWithDeserializationError(err, "ip range %d subnet id", i)
+		}
+		result = append(result, ipRange{
+			rangeType: valid["type"].(string),
+			startIP:   valid["start_ip"].(string),
+			endIP:     valid["end_ip"].(string),
+			subnetID:  id.(int),
+		})
+	}
+	return result, nil
+}
+
+// EnableDHCPArgs is an argument struct for passing information into
+// EnableDHCP.
+type EnableDHCPArgs struct {
+	// Subnet is the subnet to serve DHCP on. DHCP is enabled on its VLAN.
+	Subnet Subnet
+
+	// PrimaryRack is the system ID of the rack controller that serves
+	// DHCP. It may be left empty if the VLAN already has a primary rack.
+	PrimaryRack string
+
+	// SecondaryRack is optional, and gives a highly available DHCP
+	// service when set.
+	SecondaryRack string
+
+	// DynamicStartIP and DynamicEndIP give the dynamic range to create
+	// if the subnet does not already have one. They are ignored if it
+	// does.
+	DynamicStartIP string
+	DynamicEndIP   string
+}
+
+// Validate ensures that the arguments are consistent with each other and
+// the subnet. It does not check the server.
+func (a *EnableDHCPArgs) Validate() error {
+	if a.Subnet == nil {
+		return errors.NotValidf("missing Subnet")
+	}
+	if a.Subnet.VLAN() == nil {
+		return errors.NotValidf("Subnet without a VLAN")
+	}
+	if a.PrimaryRack == "" && a.Subnet.VLAN().PrimaryRack() == "" {
+		return errors.NotValidf("missing PrimaryRack")
+	}
+	primary := a.PrimaryRack
+	if primary == "" {
+		primary = a.Subnet.VLAN().PrimaryRack()
+	}
+	if a.SecondaryRack != "" && a.SecondaryRack == primary {
+		return errors.NotValidf("SecondaryRack the same as PrimaryRack")
+	}
+	if (a.DynamicStartIP == "") != (a.DynamicEndIP == "") {
+		return errors.NotValidf("only one of DynamicStartIP and DynamicEndIP")
+	}
+	if a.DynamicStartIP == "" {
+		return nil
+	}
+	_, network, err := net.ParseCIDR(a.Subnet.CIDR())
+	if err != nil {
+		return errors.NewNotValid(err, fmt.Sprintf("subnet CIDR %q", a.Subnet.CIDR()))
+	}
+	start, err := subnetIP(network, "DynamicStartIP", a.DynamicStartIP)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	end, err := subnetIP(network, "DynamicEndIP", a.DynamicEndIP)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if bytes.Compare(start.To16(), end.To16()) > 0 {
+		return errors.NotValidf("DynamicStartIP after DynamicEndIP")
+	}
+	return nil
+}
+
+func subnetIP(network *net.IPNet, name, value string) (net.IP, error) {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, errors.NotValidf("%s %q", name, value)
+	}
+	if !network.Contains(ip) {
+		return nil, errors.NotValidf("%s %q outside subnet %s", name, value, network)
+	}
+	return ip, nil
+}
+
+// EnableDHCP implements Controller.
+func (c *controller) EnableDHCP(args EnableDHCPArgs) (VLAN, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	vlan, ok := args.Subnet.VLAN().(*vlan)
+	if !ok || vlan.resourceURI == "" {
+		return nil, errors.NotValidf("Subnet VLAN not read from the server")
+	}
+
+	// MAAS refuses to turn on DHCP without a dynamic range, so make sure
+	// there is one first.
+	source, err := c.get(IPRangesPath)
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	ranges, err := readIPRanges(source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	hasDynamic := false
+	for _, r := range ranges {
+		if r.subnetID == args.Subnet.ID() && r.rangeType == "dynamic" {
+			hasDynamic = true
+			break
+		}
+	}
+	if !hasDynamic {
+		if args.DynamicStartIP == "" {
+			return nil, errors.NotValidf("subnet %s has no dynamic range and no DynamicStartIP", args.Subnet.CIDR())
+		}
+		params := NewURLParams()
+		params.Values.Add("type", "dynamic")
+		params.Values.Add("start_ip", args.DynamicStartIP)
+		params.Values.Add("end_ip", args.DynamicEndIP)
+		params.Values.Add("subnet", fmt.Sprint(args.Subnet.ID()))
+		if _, err := c.post(IPRangesPath, "", params.Values); err != nil {
+			return nil, errors.Annotate(mapDHCPError(err), "creating dynamic range")
+		}
+	}
+
+	params := NewURLParams()
+	params.Values.Add("dhcp_on", "true")
+	params.MaybeAdd("primary_rack", args.PrimaryRack)
+	params.MaybeAdd("secondary_rack", args.SecondaryRack)
+	result, err := c.put(vlan.resourceURI, params.Values)
+	if err != nil {
+		return nil, errors.Annotate(mapDHCPError(err), "enabling DHCP")
+	}
+	updated, err := readVLAN(c.schemaVersion(), result)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return updated, nil
+}
+
+// DisableDHCP implements Controller.
+func (c *controller) DisableDHCP(v VLAN) (VLAN, error) {
+	vlan, ok := v.(*vlan)
+	if !ok || vlan.resourceURI == "" {
+		return nil, errors.NotValidf("VLAN not read from the server")
+	}
+	params := NewURLParams()
+	params.Values.Add("dhcp_on", "false")
+	result, err := c.put(vlan.resourceURI, params.Values)
+	if err != nil {
+		return nil, errors.Annotate(mapDHCPError(err), "disabling DHCP")
+	}
+	updated, err := readVLAN(c.schemaVersion(), result)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return updated, nil
+}
+
+func mapDHCPError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type dhcpSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&dhcpSuite{})
+
+func (s *dhcpSuite) getServerControllerAndSubnet(c *gc.C) (*SimpleTestServer, Controller, Subnet) {
+	server, controller := createTestServerController(c, s)
+	subnets, err := readSubnets(twoDotOh, parseJSON(c, subnetResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	// The second subnet has DHCP off and no racks.
+	return server, controller, subnets[1]
+}
+
+func (s *dhcpSuite) TestEnableDHCPCreatesRange(c *gc.C) {
+	server, controller, subnet := s.getServerControllerAndSubnet(c)
+	server.AddGetResponse("/api/2.0/ipranges/", http.StatusOK, `[]`)
+	server.AddPostResponse("/api/2.0/ipranges/?op=", http.StatusOK, `{}`)
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusOK, enabledVLANResponse)
+
+	vlan, err := controller.EnableDHCP(EnableDHCPArgs{
+		Subnet:         subnet,
+		PrimaryRack:    "4y3h7n",
+		DynamicStartIP: "192.168.122.100",
+		DynamicEndIP:   "192.168.122.200",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(vlan.DHCP(), jc.IsTrue)
+	c.Check(vlan.PrimaryRack(), gc.Equals, "4y3h7n")
+
+	requests := server.LastNRequests(2)
+	c.Assert(requests, gc.HasLen, 2)
+	form := requests[0].PostForm
+	c.Check(form.Get("type"), gc.Equals, "dynamic")
+	c.Check(form.Get("start_ip"), gc.Equals, "192.168.122.100")
+	c.Check(form.Get("end_ip"), gc.Equals, "192.168.122.200")
+	c.Check(form.Get("subnet"), gc.Equals, "34")
+	form = requests[1].PostForm
+	c.Check(form.Get("dhcp_on"), gc.Equals, "true")
+	c.Check(form.Get("primary_rack"), gc.Equals, "4y3h7n")
+	_, ok := form["secondary_rack"]
+	c.Check(ok, jc.IsFalse)
+}
+
+func (s *dhcpSuite) TestEnableDHCPExistingRange(c *gc.C) {
+	server, controller, subnet := s.getServerControllerAndSubnet(c)
+	server.AddGetResponse("/api/2.0/ipranges/", http.StatusOK, ipRangesResponse)
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusOK, enabledVLANResponse)
+
+	_, err := controller.EnableDHCP(EnableDHCPArgs{
+		Subnet:        subnet,
+		PrimaryRack:   "4y3h7n",
+		SecondaryRack: "xr3mpt",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	request := server.LastRequest()
+	c.Check(request.Method, gc.Equals, "PUT")
+	c.Check(request.PostForm.Get("secondary_rack"), gc.Equals, "xr3mpt")
+}
+
+func (s *dhcpSuite) TestEnableDHCPNoRange(c *gc.C) {
+	server, controller, subnet := s.getServerControllerAndSubnet(c)
+	// The only dynamic range is on another subnet.
+	server.AddGetResponse("/api/2.0/ipranges/", http.StatusOK, `[
+        {"type": "reserved", "start_ip": "192.168.122.1", "end_ip": "192.168.122.9", "subnet": {"id": 34}},
+        {"type": "dynamic", "start_ip": "192.168.100.100", "end_ip": "192.168.100.200", "subnet": {"id": 1}}
+    ]`)
+	_, err := controller.EnableDHCP(EnableDHCPArgs{Subnet: subnet, PrimaryRack: "4y3h7n"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, "subnet 192.168.122.0/24 has no dynamic range and no DynamicStartIP not valid")
+}
+
+func (s *dhcpSuite) TestEnableDHCPServerError(c *gc.C) {
+	server, controller, subnet := s.getServerControllerAndSubnet(c)
+	server.AddGetResponse("/api/2.0/ipranges/", http.StatusOK, ipRangesResponse)
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusBadRequest, "Unknown rack controller")
+	_, err := controller.EnableDHCP(EnableDHCPArgs{Subnet: subnet, PrimaryRack: "missing"})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err, gc.ErrorMatches, "enabling DHCP: Unknown rack controller")
+}
+
+func (s *dhcpSuite) TestEnableDHCPArgsValidate(c *gc.C) {
+	subnets, err := readSubnets(twoDotOh, parseJSON(c, subnetResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	withRack, withoutRack := subnets[0], subnets[1]
+	for i, test := range []struct {
+		args    EnableDHCPArgs
+		errText string
+	}{{
+		errText: "missing Subnet not valid",
+	}, {
+		args:    EnableDHCPArgs{Subnet: withoutRack},
+		errText: "missing PrimaryRack not valid",
+	}, {
+		args: EnableDHCPArgs{Subnet: withRack},
+	}, {
+		args:    EnableDHCPArgs{Subnet: withRack, SecondaryRack: "4y3h7n"},
+		errText: "SecondaryRack the same as PrimaryRack not valid",
+	}, {
+		args:    EnableDHCPArgs{Subnet: withRack, DynamicStartIP: "192.168.100.10"},
+		errText: "only one of DynamicStartIP and DynamicEndIP not valid",
+	}, {
+		args:    EnableDHCPArgs{Subnet: withRack, DynamicStartIP: "192.168.100.10", DynamicEndIP: "10.0.0.1"},
+		errText: `DynamicEndIP "10.0.0.1" outside subnet 192.168.100.0/24 not valid`,
+	}, {
+		args:    EnableDHCPArgs{Subnet: withRack, DynamicStartIP: "bad", DynamicEndIP: "192.168.100.20"},
+		errText: `DynamicStartIP "bad" not valid`,
+	}, {
+		args:    EnableDHCPArgs{Subnet: withRack, DynamicStartIP: "192.168.100.30", DynamicEndIP: "192.168.100.20"},
+		errText: "DynamicStartIP after DynamicEndIP not valid",
+	}, {
+		args: EnableDHCPArgs{Subnet: withRack, DynamicStartIP: "192.168.100.10", DynamicEndIP: "192.168.100.20"},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *dhcpSuite) TestDisableDHCP(c *gc.C) {
+	server, controller, subnet := s.getServerControllerAndSubnet(c)
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusOK, updateJSONMap(c, enabledVLANResponse, map[string]interface{}{
+		"dhcp_on": false,
+	}))
+	vlan, err := controller.DisableDHCP(subnet.VLAN())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(vlan.DHCP(), jc.IsFalse)
+	c.Check(server.LastRequest().PostForm.Get("dhcp_on"), gc.Equals, "false")
+}
+
+const (
+	enabledVLANResponse = `
+{
+    "fabric": "fabric-1",
+    "resource_uri": "/MAAS/api/2.0/vlans/5001/",
+    "name": "untagged",
+    "secondary_rack": null,
+    "primary_rack": "4y3h7n",
+    "vid": 0,
+    "dhcp_on": true,
+    "id": 5001,
+    "mtu": 1500
+}
+`
+	ipRangesResponse = `
+[
+    {
+        "id": 1,
+        "type": "dynamic",
+        "start_ip": "192.168.122.100",
+        "end_ip": "192.168.122.200",
+        "comment": "",
+        "subnet": {"id": 34, "cidr": "192.168.122.0/24"},
+        "resource_uri": "/MAAS/api/2.0/ipranges/1/"
+    }
+]
+`
+)
@@ -0,0 +1,129 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bufio"
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+)
+
+// failedScriptStatuses are the statuses of scripts that found a problem.
+var failedScriptStatuses = set.NewStrings(
+	"Failed",
+	"Timed out",
+	"Failed installing",
+	"Degraded",
+)
+
+// passedScriptStatuses are the statuses of scripts that finished without
+// finding a problem.
+var passedScriptStatuses = set.NewStrings(
+	"Passed",
+	"Skipped",
+)
+
+// DiskHealth summarizes the results of the testing scripts run against one
+// storage device of a machine.
+type DiskHealth struct {
+	BlockDeviceID   int
+	BlockDeviceName string
+
+	// Failed is the names of the scripts that failed, timed out or found
+	// the device degraded.
+	Failed []string
+	// Incomplete is true if any script has not finished, or was aborted.
+	Incomplete bool
+	// ReallocatedSectors is the raw count of reallocated sectors from the
+	// SMART attributes in the output of the scripts, or -1 if no script
+	// output reported it.
+	ReallocatedSectors int
+}
+
+// Passed is true if every script run against the device finished without
+// finding a problem.
+func (h DiskHealth) Passed() bool {
+	return len(h.Failed) == 0 && !h.Incomplete
+}
+
+// SummarizeDiskHealth returns the health of each storage device that the
+// scripts of the set were run against, ordered by block device ID. The
+// scripts that are not run against a storage device are ignored. The
+// reallocated sectors are only found if the set was read with
+// ScriptResultsArgs.IncludeOutput.
+func SummarizeDiskHealth(scriptSet ScriptSet) []DiskHealth {
+	byID := make(map[int]*DiskHealth)
+	for _, result := range scriptSet.Results() {
+		id := result.BlockDeviceID()
+		if id == 0 {
+			continue
+		}
+		health, ok := byID[id]
+		if !ok {
+			health = &DiskHealth{
+				BlockDeviceID:      id,
+				BlockDeviceName:    result.BlockDeviceName(),
+				ReallocatedSectors: -1,
+			}
+			byID[id] = health
+		}
+		switch status := result.Status(); {
+		case failedScriptStatuses.Contains(status):
+			health.Failed = append(health.Failed, result.Name())
+		case !passedScriptStatuses.Contains(status):
+			health.Incomplete = true
+		}
+		if count, ok := reallocatedSectors(result.Output()); ok {
+			health.ReallocatedSectors = count
+		}
+	}
+	result := make([]DiskHealth, 0, len(byID))
+	for _, health := range byID {
+		result = append(result, *health)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BlockDeviceID < result[j].BlockDeviceID
+	})
+	return result
+}
+
+// reallocatedSectors finds the raw value of the Reallocated_Sector_Ct
+// attribute in the attribute table printed by smartctl, which is the
+// last column of the row.
+func reallocatedSectors(output []byte) (int, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[1] != "Reallocated_Sector_Ct" {
+			continue
+		}
+		count, err := strconv.Atoi(fields[9])
+		if err != nil {
+			return 0, false
+		}
+		return count, true
+	}
+	return 0, false
+}
+
+// DiskHealth implements Machine.
+func (m *machine) DiskHealth() ([]DiskHealth, error) {
+	sets, err := m.ScriptResults(ScriptResultsArgs{
+		Type:          ScriptResultTesting,
+		IncludeOutput: true,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(sets) == 0 {
+		return nil, nil
+	}
+	// The newest run of the testing scripts is first.
+	return SummarizeDiskHealth(sets[0]), nil
+}
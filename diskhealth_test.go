@@ -0,0 +1,125 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (*scriptResultSuite) TestReadStorageParameter(c *gc.C) {
+	sets, err := readScriptSets(twoDotOh, parseJSON(c, testingResultsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	results := sets[0].Results()
+	c.Check(results[0].BlockDeviceID(), gc.Equals, 0)
+	c.Check(results[1].BlockDeviceID(), gc.Equals, 32)
+	c.Check(results[1].BlockDeviceName(), gc.Equals, "sda")
+	// A parameter that names all the devices is not a device.
+	c.Check(results[5].BlockDeviceID(), gc.Equals, 0)
+}
+
+func (*scriptResultSuite) TestSummarizeDiskHealth(c *gc.C) {
+	sets, err := readScriptSets(twoDotOh, parseJSON(c, testingResultsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	health := SummarizeDiskHealth(sets[0])
+	c.Check(health, jc.DeepEquals, []DiskHealth{{
+		BlockDeviceID:      32,
+		BlockDeviceName:    "sda",
+		ReallocatedSectors: 0,
+	}, {
+		BlockDeviceID:      33,
+		BlockDeviceName:    "sdb",
+		Failed:             []string{"smartctl-validate"},
+		Incomplete:         true,
+		ReallocatedSectors: 312,
+	}, {
+		BlockDeviceID:      34,
+		BlockDeviceName:    "sdc",
+		ReallocatedSectors: -1,
+	}})
+	c.Check(health[0].Passed(), jc.IsTrue)
+	c.Check(health[1].Passed(), jc.IsFalse)
+	c.Check(health[2].Passed(), jc.IsTrue)
+}
+
+func (s *machineSuite) TestDiskHealth(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/nodes/4y3ha3/results/?include_output=true&type=testing", http.StatusOK, testingResultsResponse)
+	health, err := machine.DiskHealth()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(health, gc.HasLen, 3)
+	c.Check(health[1].Failed, jc.DeepEquals, []string{"smartctl-validate"})
+}
+
+func (s *machineSuite) TestDiskHealthNeverTested(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/nodes/4y3ha3/results/?include_output=true&type=testing", http.StatusOK, "[]")
+	health, err := machine.DiskHealth()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(health, gc.HasLen, 0)
+}
+
+const testingResultsResponse = `
+[
+    {
+        "id": 14,
+        "system_id": "4y3ha3",
+        "type": 2,
+        "type_name": "Testing",
+        "status": 3,
+        "status_name": "Failed",
+        "started": "Tue, 19 Nov. 2019 15:24:25",
+        "ended": null,
+        "resource_uri": "/MAAS/api/2.0/nodes/4y3ha3/results/14/",
+        "results": [
+            {
+                "id": 50,
+                "name": "memtester",
+                "status_name": "Passed",
+                "exit_status": 0,
+                "parameters": {}
+            },
+            {
+                "id": 51,
+                "name": "smartctl-validate",
+                "status_name": "Passed",
+                "exit_status": 0,
+                "parameters": {"storage": {"type": "storage", "value": {"id": 32, "name": "sda", "model": "QEMU HARDDISK", "serial": "QM00001"}}},
+                "output": "SUQjIEFUVFJJQlVURV9OQU1FICAgICAgICAgIEZMQUcgICAgIFZBTFVFIFdPUlNUIFRIUkVTSCBUWVBFICAgICAgVVBEQVRFRCAgV0hFTl9GQUlMRUQgUkFXX1ZBTFVFCiAgNSBSZWFsbG9jYXRlZF9TZWN0b3JfQ3QgICAweDAwMzMgICAxMDAgICAxMDAgICAwMTAgICAgUHJlLWZhaWwgIEFsd2F5cyAgICAgICAtICAgICAgIDAKICA5IFBvd2VyX09uX0hvdXJzICAgICAgICAgIDB4MDAzMiAgIDA5OSAgIDA5OSAgIDAwMCAgICBPbGRfYWdlICAgQWx3YXlzICAgICAgIC0gICAgICAgMTIwMwo="
+            },
+            {
+                "id": 52,
+                "name": "smartctl-validate",
+                "status_name": "Failed",
+                "exit_status": 1,
+                "parameters": {"storage": {"type": "storage", "value": {"id": 33, "name": "sdb"}}},
+                "output": "SUQjIEFUVFJJQlVURV9OQU1FICAgICAgICAgIEZMQUcgICAgIFZBTFVFIFdPUlNUIFRIUkVTSCBUWVBFICAgICAgVVBEQVRFRCAgV0hFTl9GQUlMRUQgUkFXX1ZBTFVFCiAgNSBSZWFsbG9jYXRlZF9TZWN0b3JfQ3QgICAweDAwMzMgICAwODAgICAwODAgICAwMTAgICAgUHJlLWZhaWwgIEFsd2F5cyAgICAgICAtICAgICAgIDMxMgo="
+            },
+            {
+                "id": 53,
+                "name": "badblocks",
+                "status_name": "Running",
+                "exit_status": null,
+                "parameters": {"storage": {"type": "storage", "value": {"id": 33, "name": "sdb"}}}
+            },
+            {
+                "id": 54,
+                "name": "smartctl-validate",
+                "status_name": "Skipped",
+                "exit_status": null,
+                "parameters": {"storage": {"type": "storage", "value": {"id": 34, "name": "sdc"}}}
+            },
+            {
+                "id": 55,
+                "name": "fio",
+                "status_name": "Pending",
+                "exit_status": null,
+                "parameters": {"storage": {"type": "storage", "value": "all"}}
+            }
+        ]
+    }
+]
+`
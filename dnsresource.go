@@ -0,0 +1,528 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type dnsResource struct {
+	controller *controller
+
+	resourceURI string
+
+	id          int
+	fqdn        string
+	addressTTL  *int
+	ipAddresses []string
+	records     []*dnsResourceRecord
+}
+
+// ID implements DNSResource.
+func (r *dnsResource) ID() int {
+	return r.id
+}
+
+// FQDN implements DNSResource.
+func (r *dnsResource) FQDN() string {
+	return r.fqdn
+}
+
+// AddressTTL implements DNSResource.
+func (r *dnsResource) AddressTTL() (int, bool) {
+	if r.addressTTL == nil {
+		return 0, false
+	}
+	return *r.addressTTL, true
+}
+
+// IPAddresses implements DNSResource.
+func (r *dnsResource) IPAddresses() []string {
+	return r.ipAddresses
+}
+
+// ResourceRecords implements DNSResource.
+func (r *dnsResource) ResourceRecords() []DNSResourceRecord {
+	result := make([]DNSResourceRecord, len(r.records))
+	for i, record := range r.records {
+		result[i] = record
+	}
+	return result
+}
+
+// Delete implements DNSResource.
+func (r *dnsResource) Delete() error {
+	if err := r.controller.delete(r.resourceURI); err != nil {
+		return errors.Trace(dnsResourceError(err))
+	}
+	return nil
+}
+
+type dnsResourceRecord struct {
+	controller *controller
+
+	resourceURI string
+
+	id     int
+	fqdn   string
+	rrType string
+	rrData string
+	ttl    *int
+}
+
+// ID implements DNSResourceRecord.
+func (r *dnsResourceRecord) ID() int {
+	return r.id
+}
+
+// FQDN implements DNSResourceRecord.
+func (r *dnsResourceRecord) FQDN() string {
+	return r.fqdn
+}
+
+// RRType implements DNSResourceRecord.
+func (r *dnsResourceRecord) RRType() string {
+	return r.rrType
+}
+
+// RRData implements DNSResourceRecord.
+func (r *dnsResourceRecord) RRData() string {
+	return r.rrData
+}
+
+// TTL implements DNSResourceRecord.
+func (r *dnsResourceRecord) TTL() (int, bool) {
+	if r.ttl == nil {
+		return 0, false
+	}
+	return *r.ttl, true
+}
+
+// Delete implements DNSResourceRecord.
+func (r *dnsResourceRecord) Delete() error {
+	if err := r.controller.delete(r.resourceURI); err != nil {
+		return errors.Trace(dnsResourceError(err))
+	}
+	return nil
+}
+
+// DNSResourcesArgs is an argument struct for selecting DNS resources and
+// records. Only those that match all the fields that are set are returned.
+type DNSResourcesArgs struct {
+	Domain string
+	// Name is the host name within the domain.
+	Name string
+	// RRType is a record type, such as "CNAME" or "TXT".
+	RRType string
+}
+
+func (a DNSResourcesArgs) params() *URLParams {
+	params := NewURLParams()
+	params.MaybeAdd("domain", a.Domain)
+	params.MaybeAdd("name", a.Name)
+	params.MaybeAdd("rrtype", a.RRType)
+	return params
+}
+
+// CreateDNSResourceArgs is an argument struct for
+// Controller.CreateDNSResource. Either FQDN, or Name and Domain, must be
+// set, as must IPAddresses.
+type CreateDNSResourceArgs struct {
+	FQDN   string
+	Name   string
+	Domain string
+	// AddressTTL is the TTL, in seconds, of the address records. The
+	// default TTL of the domain is used if it is nil.
+	AddressTTL  *int
+	IPAddresses []string
+}
+
+// Validate ensures that the args name the resource and give it addresses.
+func (a *CreateDNSResourceArgs) Validate() error {
+	if err := validateDNSName(a.FQDN, a.Name, a.Domain); err != nil {
+		return errors.Trace(err)
+	}
+	if len(a.IPAddresses) == 0 {
+		return errors.NotValidf("missing IPAddresses")
+	}
+	if a.AddressTTL != nil && *a.AddressTTL < 0 {
+		return errors.NotValidf("negative AddressTTL")
+	}
+	return nil
+}
+
+// CreateDNSResourceRecordArgs is an argument struct for
+// Controller.CreateDNSResourceRecord. Either FQDN, or Name and Domain, must
+// be set, as must RRType and RRData.
+type CreateDNSResourceRecordArgs struct {
+	FQDN   string
+	Name   string
+	Domain string
+	// RRType is the record type, such as "CNAME", "TXT" or "MX", and
+	// RRData its data in the format of a zone file.
+	RRType string
+	RRData string
+	// TTL is the TTL of the record in seconds. The default TTL of the
+	// domain is used if it is nil.
+	TTL *int
+}
+
+// Validate ensures that the args name the record and give its type and
+// data.
+func (a *CreateDNSResourceRecordArgs) Validate() error {
+	if err := validateDNSName(a.FQDN, a.Name, a.Domain); err != nil {
+		return errors.Trace(err)
+	}
+	if a.RRType == "" {
+		return errors.NotValidf("missing RRType")
+	}
+	if a.RRData == "" {
+		return errors.NotValidf("missing RRData")
+	}
+	if a.TTL != nil && *a.TTL < 0 {
+		return errors.NotValidf("negative TTL")
+	}
+	return nil
+}
+
+func validateDNSName(fqdn, name, domain string) error {
+	if fqdn == "" {
+		if name == "" || domain == "" {
+			return errors.NotValidf("missing FQDN or Name and Domain")
+		}
+	} else if name != "" || domain != "" {
+		return errors.NotValidf("specifying FQDN and Name or Domain")
+	}
+	return nil
+}
+
+func addDNSName(params *URLParams, fqdn, name, domain string) {
+	params.MaybeAdd("fqdn", fqdn)
+	params.MaybeAdd("name", name)
+	params.MaybeAdd("domain", domain)
+}
+
+// DNSResources implements Controller.
+func (c *controller) DNSResources(args DNSResourcesArgs) ([]DNSResource, error) {
+	source, err := c.getQuery(DNSResourcesPath, args.params().Values)
+	if err != nil {
+		return nil, errors.Trace(dnsResourceError(err))
+	}
+	resources, err := readDNSResources(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []DNSResource
+	for _, r := range resources {
+		c.adoptDNSResource(r)
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// CreateDNSResource implements Controller.
+func (c *controller) CreateDNSResource(args CreateDNSResourceArgs) (DNSResource, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	addDNSName(params, args.FQDN, args.Name, args.Domain)
+	if args.AddressTTL != nil {
+		params.Values.Add("address_ttl", fmt.Sprint(*args.AddressTTL))
+	}
+	params.Values.Add("ip_addresses", strings.Join(args.IPAddresses, " "))
+	source, err := c.post(DNSResourcesPath, "", params.Values)
+	if err != nil {
+		return nil, errors.Trace(dnsResourceError(err))
+	}
+	r, err := readDNSResource(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	c.adoptDNSResource(r)
+	return r, nil
+}
+
+// DNSResourceRecords implements Controller.
+func (c *controller) DNSResourceRecords(args DNSResourcesArgs) ([]DNSResourceRecord, error) {
+	source, err := c.getQuery(DNSResourceRecordsPath, args.params().Values)
+	if err != nil {
+		return nil, errors.Trace(dnsResourceError(err))
+	}
+	records, err := readDNSResourceRecords(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []DNSResourceRecord
+	for _, r := range records {
+		r.controller = c
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// CreateDNSResourceRecord implements Controller.
+func (c *controller) CreateDNSResourceRecord(args CreateDNSResourceRecordArgs) (DNSResourceRecord, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	addDNSName(params, args.FQDN, args.Name, args.Domain)
+	params.Values.Add("rrtype", args.RRType)
+	params.Values.Add("rrdata", args.RRData)
+	if args.TTL != nil {
+		params.Values.Add("ttl", fmt.Sprint(*args.TTL))
+	}
+	source, err := c.post(DNSResourceRecordsPath, "", params.Values)
+	if err != nil {
+		return nil, errors.Trace(dnsResourceError(err))
+	}
+	record, err := readDNSResourceRecord(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	record.controller = c
+	return record, nil
+}
+
+// adoptDNSResource sets the controller of the resource and its records.
+func (c *controller) adoptDNSResource(r *dnsResource) {
+	r.controller = c
+	for _, record := range r.records {
+		record.controller = c
+	}
+}
+
+// dnsResourceError translates the errors of the DNS resource APIs. The
+// server gives a 400 for a domain that does not exist, or a record that
+// conflicts with another.
+func dnsResourceError(err error) error {
+	if errors.IsNotValid(err) {
+		return err
+	}
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+func readDNSResource(controllerVersion version.Number, source interface{}) (*dnsResource, error) {
+	readFunc, err := getDNSResourceDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "dns resource base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
+func readDNSResources(controllerVersion version.Number, source interface{}) ([]*dnsResource, error) {
+	readFunc, err := getDNSResourceDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "dns resource base schema check failed")
+	}
+	sourceList := coerced.([]interface{})
+	result := make([]*dnsResource, 0, len(sourceList))
+	for i, value := range sourceList {
+		r, err := readFunc(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "dns resource %d", i)
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func getDNSResourceDeserializationFunc(controllerVersion version.Number) (dnsResourceDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range dnsResourceDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no dns resource read func for version %s", controllerVersion)
+	}
+	return dnsResourceDeserializationFuncs[deserialisationVersion], nil
+}
+
+type dnsResourceDeserializationFunc func(map[string]interface{}) (*dnsResource, error)
+
+var dnsResourceDeserializationFuncs = map[version.Number]dnsResourceDeserializationFunc{
+	twoDotOh: dnsResource_2_0,
+}
+
+func dnsResource_2_0(source map[string]interface{}) (*dnsResource, error) {
+	fields := schema.Fields{
+		"resource_uri":     schema.String(),
+		"id":               schema.ForceInt(),
+		"fqdn":             schema.String(),
+		"address_ttl":      schema.OneOf(schema.Nil(""), schema.ForceInt()),
+		"ip_addresses":     schema.List(schema.StringMap(schema.Any())),
+		"resource_records": schema.List(schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"address_ttl":      nil,
+		"ip_addresses":     []interface{}{},
+		"resource_records": []interface{}{},
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "dns resource 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	var addressTTL *int
+	if ttl, ok := valid["address_ttl"].(int); ok {
+		addressTTL = &ttl
+	}
+	var addresses []string
+	for _, value := range valid["ip_addresses"].([]interface{}) {
+		if ip, ok := value.(map[string]interface{})["ip"].(string); ok && ip != "" {
+			addresses = append(addresses, ip)
+		}
+	}
+	fqdn := valid["fqdn"].(string)
+	var records []*dnsResourceRecord
+	for i, value := range valid["resource_records"].([]interface{}) {
+		record, err := dnsResourceRecord_2_0(value.(map[string]interface{}))
+		if err != nil {
+			return nil, atPath(errors.Annotatef(err, "resource record %d", i), joinPath("resource_records", indexPath(i)))
+		}
+		// The records within a resource do not repeat its name.
+		if record.fqdn == "" {
+			record.fqdn = fqdn
+		}
+		records = append(records, record)
+	}
+	return &dnsResource{
+		resourceURI: valid["resource_uri"].(string),
+		id:          valid["id"].(int),
+		fqdn:        fqdn,
+		addressTTL:  addressTTL,
+		ipAddresses: addresses,
+		records:     records,
+	}, nil
+}
+
+func readDNSResourceRecord(controllerVersion version.Number, source interface{}) (*dnsResourceRecord, error) {
+	readFunc, err := getDNSResourceRecordDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "dns resource record base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
+func readDNSResourceRecords(controllerVersion version.Number, source interface{}) ([]*dnsResourceRecord, error) {
+	readFunc, err := getDNSResourceRecordDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "dns resource record base schema check failed")
+	}
+	sourceList := coerced.([]interface{})
+	result := make([]*dnsResourceRecord, 0, len(sourceList))
+	for i, value := range sourceList {
+		record, err := readFunc(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "dns resource record %d", i)
+		}
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+func getDNSResourceRecordDeserializationFunc(controllerVersion version.Number) (dnsResourceRecordDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range dnsResourceRecordDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no dns resource record read func for version %s", controllerVersion)
+	}
+	return dnsResourceRecordDeserializationFuncs[deserialisationVersion], nil
+}
+
+type dnsResourceRecordDeserializationFunc func(map[string]interface{}) (*dnsResourceRecord, error)
+
+var dnsResourceRecordDeserializationFuncs = map[version.Number]dnsResourceRecordDeserializationFunc{
+	twoDotOh: dnsResourceRecord_2_0,
+}
+
+func dnsResourceRecord_2_0(source map[string]interface{}) (*dnsResourceRecord, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+		"id":           schema.ForceInt(),
+		"fqdn":         schema.String(),
+		"rrtype":       schema.String(),
+		"rrdata":       schema.String(),
+		"ttl":          schema.OneOf(schema.Nil(""), schema.ForceInt()),
+	}
+	defaults := schema.Defaults{
+		"resource_uri": "",
+		"fqdn":         "",
+		"ttl":          nil,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "dns resource record 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	var ttl *int
+	if value, ok := valid["ttl"].(int); ok {
+		ttl = &value
+	}
+	id := valid["id"].(int)
+	resourceURI := valid["resource_uri"].(string)
+	if resourceURI == "" {
+		// The records listed within a resource have no URI of their own.
+		resourceURI = APIPath(DNSResourceRecordsPath, id)
+	}
+	return &dnsResourceRecord{
+		resourceURI: resourceURI,
+		id:          id,
+		fqdn:        valid["fqdn"].(string),
+		rrType:      valid["rrtype"].(string),
+		rrData:      valid["rrdata"].(string),
+		ttl:         ttl,
+	}, nil
+}
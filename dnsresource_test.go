@@ -0,0 +1,217 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type dnsResourceSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&dnsResourceSuite{})
+
+func (*dnsResourceSuite) TestReadDNSResourcesBadSchema(c *gc.C) {
+	_, err := readDNSResources(twoDotOh, "wat?")
+	c.Assert(err.Error(), gc.Equals, `dns resource base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*dnsResourceSuite) TestReadDNSResources(c *gc.C) {
+	resources, err := readDNSResources(twoDotOh, parseJSON(c, dnsResourcesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(resources, gc.HasLen, 2)
+
+	r := resources[0]
+	c.Check(r.ID(), gc.Equals, 1)
+	c.Check(r.FQDN(), gc.Equals, "www.example.com")
+	ttl, ok := r.AddressTTL()
+	c.Check(ok, jc.IsTrue)
+	c.Check(ttl, gc.Equals, 300)
+	c.Check(r.IPAddresses(), jc.DeepEquals, []string{"10.0.0.5", "2001:db8::5"})
+	records := r.ResourceRecords()
+	c.Assert(records, gc.HasLen, 1)
+	c.Check(records[0].FQDN(), gc.Equals, "www.example.com")
+	c.Check(records[0].RRType(), gc.Equals, "TXT")
+	c.Check(records[0].RRData(), gc.Equals, "v=spf1 -all")
+
+	_, ok = resources[1].AddressTTL()
+	c.Check(ok, jc.IsFalse)
+	c.Check(resources[1].IPAddresses(), gc.HasLen, 0)
+}
+
+func (*dnsResourceSuite) TestReadDNSResourceRecords(c *gc.C) {
+	records, err := readDNSResourceRecords(twoDotOh, parseJSON(c, dnsResourceRecordsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(records, gc.HasLen, 1)
+	record := records[0]
+	c.Check(record.ID(), gc.Equals, 7)
+	c.Check(record.FQDN(), gc.Equals, "mail.example.com")
+	c.Check(record.RRType(), gc.Equals, "CNAME")
+	c.Check(record.RRData(), gc.Equals, "www")
+	ttl, ok := record.TTL()
+	c.Check(ok, jc.IsTrue)
+	c.Check(ttl, gc.Equals, 60)
+}
+
+func (*dnsResourceSuite) TestLowVersion(c *gc.C) {
+	_, err := readDNSResources(version.MustParse("1.9.0"), parseJSON(c, dnsResourcesResponse))
+	c.Assert(err.Error(), gc.Equals, `no dns resource read func for version 1.9.0`)
+	_, err = readDNSResourceRecords(version.MustParse("1.9.0"), parseJSON(c, dnsResourceRecordsResponse))
+	c.Assert(err.Error(), gc.Equals, `no dns resource record read func for version 1.9.0`)
+}
+
+func (s *dnsResourceSuite) TestDNSResources(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/dnsresources/?domain=example.com", http.StatusOK, dnsResourcesResponse)
+	resources, err := controller.DNSResources(DNSResourcesArgs{Domain: "example.com"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(resources, gc.HasLen, 2)
+
+	server.AddDeleteResponse("/MAAS/api/2.0/dnsresources/1/", http.StatusNoContent, "")
+	err = resources[0].Delete()
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The records within a resource are deleted through their own API.
+	server.AddDeleteResponse("/api/2.0/dnsresourcerecords/3/", http.StatusNoContent, "")
+	err = resources[0].ResourceRecords()[0].Delete()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *dnsResourceSuite) TestCreateDNSResource(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/dnsresources/?op=", http.StatusOK, dnsResourceResponse)
+	ttl := 300
+	r, err := controller.CreateDNSResource(CreateDNSResourceArgs{
+		Name:        "www",
+		Domain:      "example.com",
+		AddressTTL:  &ttl,
+		IPAddresses: []string{"10.0.0.5", "2001:db8::5"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(r.ID(), gc.Equals, 1)
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 4)
+	c.Check(form.Get("name"), gc.Equals, "www")
+	c.Check(form.Get("domain"), gc.Equals, "example.com")
+	c.Check(form.Get("address_ttl"), gc.Equals, "300")
+	c.Check(form.Get("ip_addresses"), gc.Equals, "10.0.0.5 2001:db8::5")
+}
+
+func (s *dnsResourceSuite) TestCreateDNSResourceValidates(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	for _, test := range []struct {
+		args    CreateDNSResourceArgs
+		message string
+	}{{
+		args:    CreateDNSResourceArgs{Name: "www", IPAddresses: []string{"10.0.0.5"}},
+		message: "missing FQDN or Name and Domain not valid",
+	}, {
+		args:    CreateDNSResourceArgs{FQDN: "www.example.com", Domain: "example.com", IPAddresses: []string{"10.0.0.5"}},
+		message: "specifying FQDN and Name or Domain not valid",
+	}, {
+		args:    CreateDNSResourceArgs{FQDN: "www.example.com"},
+		message: "missing IPAddresses not valid",
+	}} {
+		_, err := controller.CreateDNSResource(test.args)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (s *dnsResourceSuite) TestCreateDNSResourceUnknownDomain(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/dnsresources/?op=", http.StatusBadRequest, `{"domain": ["Unable to find domain."]}`)
+	_, err := controller.CreateDNSResource(CreateDNSResourceArgs{
+		FQDN:        "www.example.org",
+		IPAddresses: []string{"10.0.0.5"},
+	})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *dnsResourceSuite) TestDNSResourceRecords(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/dnsresourcerecords/?rrtype=CNAME", http.StatusOK, dnsResourceRecordsResponse)
+	records, err := controller.DNSResourceRecords(DNSResourcesArgs{RRType: "CNAME"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(records, gc.HasLen, 1)
+
+	server.AddDeleteResponse("/MAAS/api/2.0/dnsresourcerecords/7/", http.StatusNotFound, "gone")
+	err = records[0].Delete()
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *dnsResourceSuite) TestCreateDNSResourceRecord(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/dnsresourcerecords/?op=", http.StatusOK, dnsResourceRecordResponse)
+	ttl := 60
+	record, err := controller.CreateDNSResourceRecord(CreateDNSResourceRecordArgs{
+		FQDN:   "mail.example.com",
+		RRType: "CNAME",
+		RRData: "www",
+		TTL:    &ttl,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(record.ID(), gc.Equals, 7)
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 4)
+	c.Check(form.Get("fqdn"), gc.Equals, "mail.example.com")
+	c.Check(form.Get("rrtype"), gc.Equals, "CNAME")
+	c.Check(form.Get("rrdata"), gc.Equals, "www")
+	c.Check(form.Get("ttl"), gc.Equals, "60")
+}
+
+func (s *dnsResourceSuite) TestCreateDNSResourceRecordValidates(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	_, err := controller.CreateDNSResourceRecord(CreateDNSResourceRecordArgs{FQDN: "mail.example.com", RRData: "www"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, "missing RRType not valid")
+}
+
+const (
+	dnsResourceResponse = `
+{
+    "resource_uri": "/MAAS/api/2.0/dnsresources/1/",
+    "id": 1,
+    "fqdn": "www.example.com",
+    "address_ttl": 300,
+    "ip_addresses": [
+        {"ip": "10.0.0.5", "alloc_type": 4},
+        {"ip": "2001:db8::5", "alloc_type": 4}
+    ],
+    "resource_records": [
+        {"id": 3, "rrtype": "TXT", "rrdata": "v=spf1 -all", "ttl": null}
+    ]
+}
+`
+	dnsResourcesResponse = `[` + dnsResourceResponse + `,
+{
+    "resource_uri": "/MAAS/api/2.0/dnsresources/2/",
+    "id": 2,
+    "fqdn": "ftp.example.com",
+    "address_ttl": null,
+    "ip_addresses": [],
+    "resource_records": []
+}
+]`
+	dnsResourceRecordResponse = `
+{
+    "resource_uri": "/MAAS/api/2.0/dnsresourcerecords/7/",
+    "id": 7,
+    "fqdn": "mail.example.com",
+    "rrtype": "CNAME",
+    "rrdata": "www",
+    "ttl": 60
+}
+`
+	dnsResourceRecordsResponse = `[` + dnsResourceRecordResponse + `]`
+)
@@ -4,18 +4,26 @@
 package gomaasapi
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
+
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
 )
 
 type domain struct {
+	controller *controller
+
 	authoritative       bool
 	resourceRecordCount int
 	ttl                 *int
 	resourceURI         string
 	id                  int
 	name                string
+	forwardDNSServers   []string
+	isDefault           bool
 }
 
 // Name implements Domain interface
@@ -23,17 +31,247 @@ func (domain *domain) Name() string {
 	return domain.name
 }
 
+// ID implements Domain interface
+func (domain *domain) ID() int {
+	return domain.id
+}
+
+// Authoritative implements Domain interface
+func (domain *domain) Authoritative() bool {
+	return domain.authoritative
+}
+
+// TTL implements Domain interface
+func (domain *domain) TTL() (int, bool) {
+	if domain.ttl == nil {
+		return 0, false
+	}
+	return *domain.ttl, true
+}
+
+// ResourceRecordCount implements Domain interface
+func (domain *domain) ResourceRecordCount() int {
+	return domain.resourceRecordCount
+}
+
+// ForwardDNSServers implements Domain interface
+func (domain *domain) ForwardDNSServers() []string {
+	return domain.forwardDNSServers
+}
+
+// IsDefault implements Domain interface
+func (domain *domain) IsDefault() bool {
+	return domain.isDefault
+}
+
+// SetDefault implements Domain interface
+func (domain *domain) SetDefault() error {
+	source, err := domain.controller.post(domain.resourceURI, "set_default", nil)
+	if err != nil {
+		return errors.Trace(domainError(err))
+	}
+	return errors.Trace(domain.updateFrom(source))
+}
+
+// Delete implements Domain interface
+func (domain *domain) Delete() error {
+	if err := domain.controller.delete(domain.resourceURI); err != nil {
+		return errors.Trace(domainError(err))
+	}
+	return nil
+}
+
+// updateFrom replaces the values of the domain with those read from the
+// source.
+func (domain *domain) updateFrom(source interface{}) error {
+	response, err := readDomain(domain.controller.schemaVersion(), source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	response.controller = domain.controller
+	*domain = *response
+	return nil
+}
+
+// UpdateDomainArgs is an argument struct for calling Domain.Update. Only
+// the fields that are set are changed.
+type UpdateDomainArgs struct {
+	Name          string
+	Authoritative *bool
+	// TTL is the default TTL, in seconds, for the records in the domain.
+	TTL *int
+	// ClearTTL removes the default TTL of the domain, so that the global
+	// default applies. It cannot be used with TTL.
+	ClearTTL bool
+	// ForwardDNSServers are the servers that queries for the domain are
+	// forwarded to when it is not authoritative. Setting them on servers
+	// before 3.5 gives an error satisfying errors.IsNotSupported. An empty
+	// slice that is not nil removes all of them.
+	ForwardDNSServers []string
+}
+
+// Validate ensures that the TTL, if set, is not negative, and that it is
+// not also cleared.
+func (a *UpdateDomainArgs) Validate() error {
+	if a.TTL != nil && *a.TTL < 0 {
+		return errors.NotValidf("negative TTL")
+	}
+	if a.TTL != nil && a.ClearTTL {
+		return errors.NotValidf("both TTL and ClearTTL")
+	}
+	return nil
+}
+
+// Update implements Domain interface
+func (domain *domain) Update(args UpdateDomainArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.MaybeAdd("name", args.Name)
+	if args.Authoritative != nil {
+		params.Values.Add("authoritative", fmt.Sprint(*args.Authoritative))
+	}
+	if args.TTL != nil {
+		params.Values.Add("ttl", fmt.Sprint(*args.TTL))
+	} else if args.ClearTTL {
+		params.Values.Add("ttl", "")
+	}
+	if args.ForwardDNSServers != nil {
+		if err := domain.controller.requireVersion("forward DNS servers", 3, 5); err != nil {
+			return errors.Trace(err)
+		}
+		params.Values.Add("forward_dns_servers", strings.Join(args.ForwardDNSServers, " "))
+	}
+	if len(params.Values) == 0 {
+		return nil
+	}
+	source, err := domain.controller.put(domain.resourceURI, params.Values)
+	if err != nil {
+		return errors.Trace(domainError(err))
+	}
+	return errors.Trace(domain.updateFrom(source))
+}
+
+// CreateDomainArgs is an argument struct for Controller.CreateDomain. Only
+// Name is required.
+type CreateDomainArgs struct {
+	Name          string
+	Authoritative *bool
+	// TTL is the default TTL, in seconds, for the records in the domain.
+	TTL *int
+	// ForwardDNSServers are the servers that queries for the domain are
+	// forwarded to when it is not authoritative. Setting them on servers
+	// before 3.5 gives an error satisfying errors.IsNotSupported.
+	ForwardDNSServers []string
+}
+
+// Validate ensures that there is a name and that the TTL, if set, is not
+// negative.
+func (a *CreateDomainArgs) Validate() error {
+	if a.Name == "" {
+		return errors.NotValidf("missing Name")
+	}
+	if a.TTL != nil && *a.TTL < 0 {
+		return errors.NotValidf("negative TTL")
+	}
+	return nil
+}
+
+// CreateDomain implements Controller.
+func (c *controller) CreateDomain(args CreateDomainArgs) (Domain, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("name", args.Name)
+	if args.Authoritative != nil {
+		params.Values.Add("authoritative", fmt.Sprint(*args.Authoritative))
+	}
+	if args.TTL != nil {
+		params.Values.Add("ttl", fmt.Sprint(*args.TTL))
+	}
+	if len(args.ForwardDNSServers) > 0 {
+		if err := c.requireVersion("forward DNS servers", 3, 5); err != nil {
+			return nil, errors.Trace(err)
+		}
+		params.Values.Add("forward_dns_servers", strings.Join(args.ForwardDNSServers, " "))
+	}
+	source, err := c.post(DomainsPath, "", params.Values)
+	if err != nil {
+		return nil, errors.Trace(domainError(err))
+	}
+	domain, err := readDomain(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	domain.controller = c
+	return domain, nil
+}
+
+// domainError translates the errors of the domains API. The server refuses
+// to delete the default domain, or one that is in use, with a 400.
+func domainError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+func readDomain(controllerVersion version.Number, source interface{}) (*domain, error) {
+	readFunc, err := getDomainDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "domain base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
 func readDomains(controllerVersion version.Number, source interface{}) ([]*domain, error) {
+	readFunc, err := getDomainDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "domain base schema check failed")
+		return nil, WrapWithDeserializationError(err, "domain base schema check failed")
 	}
 	valid := coerced.([]interface{})
-	return readDomainList(valid)
+	return readDomainList(valid, readFunc)
+}
+
+func getDomainDeserializationFunc(controllerVersion version.Number) (domainDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range domainDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no domain read func for version %s", controllerVersion)
+	}
+	return domainDeserializationFuncs[deserialisationVersion], nil
 }
 
-func domain_(source map[string]interface{}) (*domain, error) {
+type domainDeserializationFunc func(map[string]interface{}) (*domain, error)
+
+var domainDeserializationFuncs = map[version.Number]domainDeserializationFunc{
+	twoDotOh: domain_2_0,
+}
+
+func domain_2_0(source map[string]interface{}) (*domain, error) {
 	fields := schema.Fields{
 		"authoritative":         schema.Bool(),
 		"resource_record_count": schema.ForceInt(),
@@ -41,11 +279,17 @@ func domain_(source map[string]interface{}) (*domain, error) {
 		"resource_uri":          schema.String(),
 		"id":                    schema.ForceInt(),
 		"name":                  schema.String(),
+		"forward_dns_servers":   schema.OneOf(schema.Nil(""), schema.List(schema.String())),
+		"is_default":            schema.Bool(),
+	}
+	defaults := schema.Defaults{
+		"forward_dns_servers": schema.Omit,
+		"is_default":          false,
 	}
-	checker := schema.FieldMap(fields, nil) // no defaults
+	checker := schema.FieldMap(fields, defaults)
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "domain schema check failed")
+		return nil, WrapWithDeserializationError(err, "domain schema check failed")
 	}
 	valid := coerced.(map[string]interface{})
 
@@ -62,22 +306,24 @@ func domain_(source map[string]interface{}) (*domain, error) {
 		resourceRecordCount: valid["resource_record_count"].(int),
 		resourceURI:         valid["resource_uri"].(string),
 		ttl:                 ttl,
+		forwardDNSServers:   convertToStringSlice(valid["forward_dns_servers"]),
+		isDefault:           valid["is_default"].(bool),
 	}
 
 	return result, nil
 }
 
 // readDomainList expects the values of the sourceList to be string maps.
-func readDomainList(sourceList []interface{}) ([]*domain, error) {
+func readDomainList(sourceList []interface{}, readFunc domainDeserializationFunc) ([]*domain, error) {
 	result := make([]*domain, 0, len(sourceList))
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, errors.Errorf("unexpected value for domain %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for domain %d, %T", i, value), indexPath(i))
 		}
-		domain, err := domain_(source)
+		domain, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "domain %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "domain %d", i)
 		}
 		result = append(result, domain)
 	}
@@ -4,11 +4,18 @@
 package gomaasapi
 
 import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
 )
 
-type domainSuite struct{}
+type domainSuite struct {
+	testing.CleanupSuite
+}
 
 var _ = gc.Suite(&domainSuite{})
 
@@ -23,8 +30,188 @@ func (*domainSuite) TestReadDomains(c *gc.C) {
 	c.Assert(domains, gc.HasLen, 2)
 	c.Assert(domains[0].Name(), gc.Equals, "maas")
 	c.Assert(domains[1].Name(), gc.Equals, "anotherDomain.com")
+
+	c.Check(domains[0].ID(), gc.Equals, 0)
+	c.Check(domains[0].Authoritative(), jc.IsTrue)
+	_, ok := domains[0].TTL()
+	c.Check(ok, jc.IsFalse)
+	ttl, ok := domains[1].TTL()
+	c.Check(ok, jc.IsTrue)
+	c.Check(ttl, gc.Equals, 10)
+	c.Check(domains[1].ResourceRecordCount(), gc.Equals, 3)
+	c.Check(domains[0].ForwardDNSServers(), gc.HasLen, 0)
+	c.Check(domains[1].ForwardDNSServers(), jc.DeepEquals, []string{"10.0.0.53"})
+	c.Check(domains[0].IsDefault(), jc.IsTrue)
+	c.Check(domains[1].IsDefault(), jc.IsFalse)
+}
+
+func (*domainSuite) TestLowVersion(c *gc.C) {
+	_, err := readDomains(version.MustParse("1.9.0"), parseJSON(c, domainResponse))
+	c.Assert(err.Error(), gc.Equals, `no domain read func for version 1.9.0`)
+}
+
+func (s *domainSuite) getServerAndDomain(c *gc.C) (*SimpleTestServer, Domain) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/domains/", http.StatusOK, domainResponse)
+	domains, err := controller.Domains()
+	c.Assert(err, jc.ErrorIsNil)
+	server.ResetRequests()
+	return server, domains[1]
+}
+
+func (s *domainSuite) TestUpdate(c *gc.C) {
+	server, domain := s.getServerAndDomain(c)
+	response := updateJSONMap(c, domainUpdateResponse, map[string]interface{}{
+		"authoritative": false,
+		"ttl":           300,
+	})
+	server.AddPutResponse("/MAAS/api/2.0/domains/1/", http.StatusOK, response)
+
+	authoritative, ttl := false, 300
+	err := domain.Update(UpdateDomainArgs{
+		Authoritative:     &authoritative,
+		TTL:               &ttl,
+		ForwardDNSServers: []string{"10.0.0.53", "10.0.0.54"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(domain.Authoritative(), jc.IsFalse)
+	newTTL, ok := domain.TTL()
+	c.Check(ok, jc.IsTrue)
+	c.Check(newTTL, gc.Equals, 300)
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 3)
+	c.Check(form.Get("authoritative"), gc.Equals, "false")
+	c.Check(form.Get("ttl"), gc.Equals, "300")
+	c.Check(form.Get("forward_dns_servers"), gc.Equals, "10.0.0.53 10.0.0.54")
+}
+
+func (s *domainSuite) TestUpdateClears(c *gc.C) {
+	server, domain := s.getServerAndDomain(c)
+	server.AddPutResponse("/MAAS/api/2.0/domains/1/", http.StatusOK, domainUpdateResponse)
+
+	err := domain.Update(UpdateDomainArgs{
+		ClearTTL:          true,
+		ForwardDNSServers: []string{},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 2)
+	c.Check(form["ttl"], jc.DeepEquals, []string{""})
+	c.Check(form["forward_dns_servers"], jc.DeepEquals, []string{""})
+}
+
+func (s *domainSuite) TestForwardDNSServersOldServer(c *gc.C) {
+	server, updated := s.getServerAndDomain(c)
+	maas := updated.(*domain).controller
+	maas.serverVersion = version.MustParse("3.4.2")
+
+	err := updated.Update(UpdateDomainArgs{ForwardDNSServers: []string{"10.0.0.53"}})
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+	c.Check(err, gc.ErrorMatches, "forward DNS servers needs MAAS 3.5 or later, the server is 3.4.2")
+	_, err = maas.CreateDomain(CreateDomainArgs{Name: "example.com", ForwardDNSServers: []string{"10.0.0.53"}})
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *domainSuite) TestUpdateNoChangeNoRequest(c *gc.C) {
+	server, domain := s.getServerAndDomain(c)
+	err := domain.Update(UpdateDomainArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.RequestCount(), gc.Equals, 0)
 }
 
+func (s *domainSuite) TestUpdateValidates(c *gc.C) {
+	_, domain := s.getServerAndDomain(c)
+	ttl := -1
+	err := domain.Update(UpdateDomainArgs{TTL: &ttl})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, "negative TTL not valid")
+
+	ttl = 300
+	err = domain.Update(UpdateDomainArgs{TTL: &ttl, ClearTTL: true})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, "both TTL and ClearTTL not valid")
+}
+
+func (s *domainSuite) TestUpdateForbidden(c *gc.C) {
+	server, domain := s.getServerAndDomain(c)
+	server.AddPutResponse("/MAAS/api/2.0/domains/1/", http.StatusForbidden, "admins only")
+	err := domain.Update(UpdateDomainArgs{Name: "new.example.com"})
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(err.Error(), gc.Equals, "admins only")
+}
+
+func (s *domainSuite) TestCreateDomain(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/domains/?op=", http.StatusOK, domainUpdateResponse)
+	ttl := 10
+	domain, err := controller.CreateDomain(CreateDomainArgs{
+		Name: "anotherDomain.com",
+		TTL:  &ttl,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(domain.ID(), gc.Equals, 1)
+	c.Check(domain.Name(), gc.Equals, "anotherDomain.com")
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 2)
+	c.Check(form.Get("name"), gc.Equals, "anotherDomain.com")
+	c.Check(form.Get("ttl"), gc.Equals, "10")
+}
+
+func (s *domainSuite) TestCreateDomainValidates(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	_, err := controller.CreateDomain(CreateDomainArgs{})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, "missing Name not valid")
+}
+
+func (s *domainSuite) TestCreateDomainExists(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/domains/?op=", http.StatusBadRequest, "Domain with this Name already exists.")
+	_, err := controller.CreateDomain(CreateDomainArgs{Name: "maas"})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *domainSuite) TestSetDefault(c *gc.C) {
+	server, domain := s.getServerAndDomain(c)
+	response := updateJSONMap(c, domainUpdateResponse, map[string]interface{}{
+		"is_default": true,
+	})
+	server.AddPostResponse("/MAAS/api/2.0/domains/1/?op=set_default", http.StatusOK, response)
+	c.Assert(domain.IsDefault(), jc.IsFalse)
+	err := domain.SetDefault()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(domain.IsDefault(), jc.IsTrue)
+}
+
+func (s *domainSuite) TestDelete(c *gc.C) {
+	server, domain := s.getServerAndDomain(c)
+	server.AddDeleteResponse("/MAAS/api/2.0/domains/1/", http.StatusNoContent, "")
+	err := domain.Delete()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.LastRequest().Method, gc.Equals, "DELETE")
+}
+
+func (s *domainSuite) TestDeleteDefault(c *gc.C) {
+	server, domain := s.getServerAndDomain(c)
+	server.AddDeleteResponse("/MAAS/api/2.0/domains/1/", http.StatusBadRequest, "This domain is the default domain, it cannot be deleted.")
+	err := domain.Delete()
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+var domainUpdateResponse = `
+{
+    "authoritative": true,
+    "resource_uri": "/MAAS/api/2.0/domains/1/",
+    "name": "anotherDomain.com",
+    "id": 1,
+    "ttl": 10,
+    "resource_record_count": 3
+}
+`
+
 var domainResponse = `
 [
     {
@@ -33,14 +220,16 @@ var domainResponse = `
         "name": "maas",
         "id": 0,
         "ttl": null,
-        "resource_record_count": 3
+        "resource_record_count": 3,
+        "is_default": true
     }, {
         "authoritative": "true",
         "resource_uri": "/MAAS/api/2.0/domains/1/",
         "name": "anotherDomain.com",
         "id": 1,
         "ttl": 10,
-        "resource_record_count": 3
+        "resource_record_count": 3,
+        "forward_dns_servers": ["10.0.0.53"]
     }
 ]
 `
@@ -3,6 +3,8 @@
 
 package gomaasapi
 
+import "fmt"
+
 const (
 	// NodeStatus* values represent the vocabulary of a Node‘s possible statuses.
 
@@ -55,3 +57,104 @@ const (
 	// The node failed to erase its disks.
 	NodeStatusFailedDiskErasing = "15"
 )
+
+// NodeType is the kind of a node, as reported in the node_type field of the
+// API objects.
+type NodeType int
+
+const (
+	// NodeTypeMachine is a machine that can be allocated and deployed.
+	NodeTypeMachine NodeType = 0
+
+	// NodeTypeDevice is a non-deployable device, such as a container.
+	NodeTypeDevice NodeType = 1
+
+	// NodeTypeRackController is a rack controller.
+	NodeTypeRackController NodeType = 2
+
+	// NodeTypeRegionController is a region controller.
+	NodeTypeRegionController NodeType = 3
+
+	// NodeTypeRegionAndRackController is a node running both a region and
+	// a rack controller.
+	NodeTypeRegionAndRackController NodeType = 4
+)
+
+// String returns the name MAAS uses for the node type.
+func (t NodeType) String() string {
+	switch t {
+	case NodeTypeMachine:
+		return "Machine"
+	case NodeTypeDevice:
+		return "Device"
+	case NodeTypeRackController:
+		return "Rack controller"
+	case NodeTypeRegionController:
+		return "Region controller"
+	case NodeTypeRegionAndRackController:
+		return "Region and rack controller"
+	}
+	return fmt.Sprintf("NodeType(%d)", int(t))
+}
+
+// IsController returns true for the node types that run a region or rack
+// controller.
+func (t NodeType) IsController() bool {
+	switch t {
+	case NodeTypeRackController, NodeTypeRegionController, NodeTypeRegionAndRackController:
+		return true
+	}
+	return false
+}
+
+// HardwareType is the class of a node device, as reported in the
+// hardware_type field of the API objects.
+type HardwareType int
+
+const (
+	// HardwareTypeNode is a device not in any of the other classes.
+	HardwareTypeNode    HardwareType = 0
+	HardwareTypeCPU     HardwareType = 1
+	HardwareTypeMemory  HardwareType = 2
+	HardwareTypeStorage HardwareType = 3
+	HardwareTypeNetwork HardwareType = 4
+	HardwareTypeGPU     HardwareType = 5
+)
+
+// String returns the name MAAS uses for the hardware type.
+func (t HardwareType) String() string {
+	switch t {
+	case HardwareTypeNode:
+		return "Node"
+	case HardwareTypeCPU:
+		return "CPU"
+	case HardwareTypeMemory:
+		return "Memory"
+	case HardwareTypeStorage:
+		return "Storage"
+	case HardwareTypeNetwork:
+		return "Network"
+	case HardwareTypeGPU:
+		return "GPU"
+	}
+	return fmt.Sprintf("HardwareType(%d)", int(t))
+}
+
+// DeviceBus is the bus a node device is attached to.
+type DeviceBus int
+
+const (
+	DeviceBusPCIE DeviceBus = 1
+	DeviceBusUSB  DeviceBus = 2
+)
+
+// String returns the name MAAS uses for the bus.
+func (b DeviceBus) String() string {
+	switch b {
+	case DeviceBusPCIE:
+		return "PCIE"
+	case DeviceBusUSB:
+		return "USB"
+	}
+	return fmt.Sprintf("DeviceBus(%d)", int(b))
+}
@@ -4,7 +4,9 @@
 package gomaasapi
 
 import (
+	stderrors "errors"
 	"fmt"
+	"strings"
 
 	"github.com/juju/errors"
 )
@@ -77,6 +79,15 @@ func WrapWithUnsupportedVersionError(err error) error {
 // the controller doesn't match the code's expectations.
 type DeserializationError struct {
 	errors.Err
+
+	path string
+}
+
+// Path returns the location of the malformed value in the response, such
+// as "machines[3].interface_set[1].links[0].subnet", or the empty string
+// if it is not known.
+func (e *DeserializationError) Path() string {
+	return e.path
 }
 
 // NewDeserializationError constructs a new DeserializationError and sets the location.
@@ -93,7 +104,10 @@ func WrapWithDeserializationError(err error, format string, args ...interface{})
 	message := fmt.Sprintf(format, args...)
 	// We want the deserialization error message to include the error text of the
 	// previous error, but wrap it in the new type.
-	derr := &DeserializationError{Err: errors.NewErr(message + ": " + err.Error())}
+	derr := &DeserializationError{
+		Err:  errors.NewErr(message + ": " + err.Error()),
+		path: errorPath(err),
+	}
 	derr.SetLocation(1)
 	wrapped := errors.Wrap(err, derr)
 	// We want the location of the wrapped error to be the caller of this function,
@@ -111,6 +125,15 @@ func IsDeserializationError(err error) bool {
 	return ok
 }
 
+// DeserializationErrorPath returns the Path of the DeserializationError
+// that caused err, or the empty string if err is not one.
+func DeserializationErrorPath(err error) string {
+	if derr, ok := errors.Cause(err).(*DeserializationError); ok {
+		return derr.path
+	}
+	return ""
+}
+
 // BadRequestError is returned when the requested action cannot be performed
 // due to bad or incorrect parameters passed to the server.
 type BadRequestError struct {
@@ -167,3 +190,341 @@ func IsCannotCompleteError(err error) bool {
 	_, ok := errors.Cause(err).(*CannotCompleteError)
 	return ok
 }
+
+// StaleObjectError is returned when a mutating method is called on a
+// Machine or Device that was released or deleted through the same
+// Controller after the object was read. Read the object again to act on
+// it.
+type StaleObjectError struct {
+	errors.Err
+	SystemID string
+}
+
+// NewStaleObjectError constructs a new StaleObjectError and sets the location.
+func NewStaleObjectError(systemID, reason string) error {
+	err := &StaleObjectError{
+		Err:      errors.NewErr("%s was %s and is stale", systemID, reason),
+		SystemID: systemID,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// IsStaleObjectError returns true if err is a StaleObjectError.
+func IsStaleObjectError(err error) bool {
+	_, ok := errors.Cause(err).(*StaleObjectError)
+	return ok
+}
+
+// AlreadyOwnedError is returned by AcquireMachine when the machine is
+// allocated to a user, who may be the caller.
+type AlreadyOwnedError struct {
+	errors.Err
+	SystemID string
+	Owner    string
+}
+
+// NewAlreadyOwnedError constructs a new AlreadyOwnedError and sets the location.
+func NewAlreadyOwnedError(systemID, owner string) error {
+	err := &AlreadyOwnedError{
+		Err:      errors.NewErr("machine %s is already owned by %q", systemID, owner),
+		SystemID: systemID,
+		Owner:    owner,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// IsAlreadyOwnedError returns true if err is an AlreadyOwnedError.
+func IsAlreadyOwnedError(err error) bool {
+	_, ok := errors.Cause(err).(*AlreadyOwnedError)
+	return ok
+}
+
+// NotAttemptedError is recorded in a BulkError for the items that the
+// server did not act on because it rejected the request for other items.
+// Repeating the operation with just these items may succeed.
+type NotAttemptedError struct {
+	errors.Err
+}
+
+// NewNotAttemptedError constructs a new NotAttemptedError and sets the location.
+func NewNotAttemptedError(message string) error {
+	err := &NotAttemptedError{Err: errors.NewErr(message)}
+	err.SetLocation(1)
+	return err
+}
+
+// IsNotAttemptedError returns true if err is a NotAttemptedError.
+func IsNotAttemptedError(err error) bool {
+	_, ok := errors.Cause(err).(*NotAttemptedError)
+	return ok
+}
+
+// JournaledError is returned for a request that could not reach the
+// server and was recorded in the Journal of the client to be replayed.
+type JournaledError struct {
+	errors.Err
+	Method string
+	Path   string
+}
+
+// NewJournaledError constructs a new JournaledError and sets the location.
+func NewJournaledError(method, path string) error {
+	err := &JournaledError{
+		Err:    errors.NewErr("server unreachable, %s %s recorded in the journal", method, path),
+		Method: method,
+		Path:   path,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// IsJournaledError returns true if err is, or wraps, a JournaledError. The
+// errors of the controller that are not more specific are
+// UnexpectedErrors, which wrap it.
+func IsJournaledError(err error) bool {
+	for err != nil {
+		if _, ok := errors.Cause(err).(*JournaledError); ok {
+			return true
+		}
+		wrapper, ok := err.(interface {
+			Underlying() error
+		})
+		if !ok {
+			break
+		}
+		err = wrapper.Underlying()
+	}
+	return false
+}
+
+// TooLargeError is returned for a response that is larger than the limit
+// of the client, or that would take more memory to decode than the budget
+// of the controller. The size is the part that was read or counted before
+// the limit was reached.
+type TooLargeError struct {
+	errors.Err
+	Size  int64
+	Limit int64
+}
+
+// NewTooLargeError constructs a new TooLargeError and sets the location.
+func NewTooLargeError(what string, size, limit int64) error {
+	err := &TooLargeError{
+		Err:   errors.NewErr("%s of at least %d bytes exceeds the limit of %d bytes", what, size, limit),
+		Size:  size,
+		Limit: limit,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// IsTooLargeError returns true if err is, or wraps, a TooLargeError.
+func IsTooLargeError(err error) bool {
+	for err != nil {
+		if _, ok := errors.Cause(err).(*TooLargeError); ok {
+			return true
+		}
+		wrapper, ok := err.(interface {
+			Underlying() error
+		})
+		if !ok {
+			break
+		}
+		err = wrapper.Underlying()
+	}
+	return false
+}
+
+// IsClockSkewError returns true if err comes from the server rejecting the
+// OAuth timestamp of a request, which happens when the local clock is too
+// far from the server's. The error is usually also a PermissionError.
+// Controller.ClockSkew measures the difference, and
+// ControllerArgs.AdjustClockSkew corrects for it.
+func IsClockSkewError(err error) bool {
+	svrErr, ok := findServerError(err)
+	return ok && isTimestampRejection(svrErr)
+}
+
+// findServerError looks for a ServerError anywhere in the chain of errors,
+// rather than only as the cause like GetServerError.
+func findServerError(err error) (ServerError, bool) {
+	for err != nil {
+		if svrErr, ok := err.(ServerError); ok {
+			return svrErr, true
+		}
+		wrapper, ok := err.(interface {
+			Underlying() error
+		})
+		if !ok {
+			break
+		}
+		err = wrapper.Underlying()
+	}
+	return GetServerError(err)
+}
+
+// BulkError is returned by operations that act on several items, normally
+// system IDs, in one call. It records the result of each item, with a nil
+// error for the items that succeeded.
+//
+// When every failed item failed for the same reason, Cause returns that
+// reason so that the Is*Error functions above work on a BulkError as they
+// would on the single error.
+type BulkError struct {
+	ids     []string
+	results map[string]error
+}
+
+// NewBulkError returns a BulkError with no results.
+func NewBulkError() *BulkError {
+	return &BulkError{results: make(map[string]error)}
+}
+
+// bulkErrorForAll returns a BulkError where every id failed with err, as
+// happens when the server rejects the whole request. With no ids, err is
+// returned as is.
+func bulkErrorForAll(ids []string, err error) error {
+	if len(ids) == 0 {
+		return err
+	}
+	result := NewBulkError()
+	for _, id := range ids {
+		result.Add(id, err)
+	}
+	return result
+}
+
+// Add records the result for id. A nil err marks the item as succeeded.
+func (e *BulkError) Add(id string, err error) {
+	if _, found := e.results[id]; !found {
+		e.ids = append(e.ids, id)
+	}
+	e.results[id] = err
+}
+
+// Err returns the error recorded for id, or nil if it succeeded or was
+// not part of the operation.
+func (e *BulkError) Err(id string) error {
+	return e.results[id]
+}
+
+// Failed returns the ids that failed, in the order they were added.
+func (e *BulkError) Failed() []string {
+	var result []string
+	for _, id := range e.ids {
+		if e.results[id] != nil {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// Succeeded returns the ids that succeeded, in the order they were added.
+func (e *BulkError) Succeeded() []string {
+	var result []string
+	for _, id := range e.ids {
+		if e.results[id] == nil {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// NotAttempted returns the ids that failed with a NotAttemptedError, in
+// the order they were added. They are the ones to retry once the items
+// that the server rejected are dealt with.
+func (e *BulkError) NotAttempted() []string {
+	var result []string
+	for _, id := range e.ids {
+		if err := e.results[id]; err != nil && IsNotAttemptedError(err) {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// HasFailures returns true if any of the items failed.
+func (e *BulkError) HasFailures() bool {
+	return len(e.Failed()) > 0
+}
+
+// Error implements error. If all the failed items share one error, that
+// error's message is used. Items that were not attempted are left out
+// when looking for the shared error, as they failed because of it.
+func (e *BulkError) Error() string {
+	failed := e.Failed()
+	if shared := e.sharedError(); shared != nil {
+		return shared.Error()
+	}
+	messages := make([]string, len(failed))
+	for i, id := range failed {
+		messages[i] = fmt.Sprintf("%s: %v", id, e.results[id])
+	}
+	return fmt.Sprintf("%d of %d failed: %s", len(failed), len(e.ids), strings.Join(messages, "; "))
+}
+
+// Cause returns the cause of the shared error when every failed item,
+// apart from those not attempted, failed with the same error, and nil
+// otherwise.
+func (e *BulkError) Cause() error {
+	if shared := e.sharedError(); shared != nil {
+		return errors.Cause(shared)
+	}
+	return nil
+}
+
+// Is returns true if the error of any failed item matches target, which
+// makes the standard library errors.Is work with a BulkError.
+func (e *BulkError) Is(target error) bool {
+	for _, id := range e.ids {
+		if err := e.results[id]; err != nil && (err == target || stderrors.Is(errors.Cause(err), target)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *BulkError) sharedError() error {
+	var shared error
+	for _, id := range e.ids {
+		err := e.results[id]
+		if err == nil || IsNotAttemptedError(err) {
+			continue
+		}
+		if shared != nil && err != shared {
+			return nil
+		}
+		shared = err
+	}
+	return shared
+}
+
+// IsBulkError returns true if err is a BulkError, or was traced or
+// annotated from one.
+func IsBulkError(err error) bool {
+	_, ok := AsBulkError(err)
+	return ok
+}
+
+// AsBulkError returns the BulkError that err is, or was traced or annotated
+// from. The cause of a BulkError is the error its items share, so
+// errors.Cause does not find it.
+func AsBulkError(err error) (*BulkError, bool) {
+	for err != nil {
+		if bulk, ok := err.(*BulkError); ok {
+			return bulk, true
+		}
+		var bulk *BulkError
+		if stderrors.As(err, &bulk) {
+			return bulk, true
+		}
+		wrapper, ok := err.(interface{ Underlying() error })
+		if !ok {
+			break
+		}
+		err = wrapper.Underlying()
+	}
+	return nil, false
+}
@@ -4,6 +4,8 @@
 package gomaasapi
 
 import (
+	stderrors "errors"
+	"net/http"
 	"strings"
 
 	"github.com/juju/errors"
@@ -15,6 +17,27 @@ type errorTypesSuite struct{}
 
 var _ = gc.Suite(&errorTypesSuite{})
 
+func (*errorTypesSuite) TestClockSkewError(c *gc.C) {
+	svrErr := errors.Trace(ServerError{
+		error:       errors.New("ServerError: 401 Unauthorized"),
+		StatusCode:  http.StatusUnauthorized,
+		BodyMessage: "Authorization Error: 'Expired timestamp: given 1 and now 1000 has a greater difference than threshold 300'",
+	})
+	c.Check(IsClockSkewError(svrErr), jc.IsTrue)
+	wrapped := errors.Wrap(svrErr, NewPermissionError("denied"))
+	c.Check(IsClockSkewError(wrapped), jc.IsTrue)
+	c.Check(IsClockSkewError(NewUnexpectedError(svrErr)), jc.IsTrue)
+
+	other := errors.Trace(ServerError{
+		error:       errors.New("ServerError: 401 Unauthorized"),
+		StatusCode:  http.StatusUnauthorized,
+		BodyMessage: "Authorization Error: Invalid API key.",
+	})
+	c.Check(IsClockSkewError(other), jc.IsFalse)
+	c.Check(IsClockSkewError(NewPermissionError("denied")), jc.IsFalse)
+	c.Check(IsClockSkewError(nil), jc.IsFalse)
+}
+
 func (*errorTypesSuite) TestNoMatchError(c *gc.C) {
 	err := NewNoMatchError("foo")
 	c.Assert(err, gc.NotNil)
@@ -82,3 +105,66 @@ func (*errorTypesSuite) TestCannotCompleteError(c *gc.C) {
 	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
 	c.Assert(err.Error(), gc.Equals, "server says no")
 }
+
+func (*errorTypesSuite) TestBulkError(c *gc.C) {
+	err := NewBulkError()
+	err.Add("a", nil)
+	err.Add("b", NewBadRequestError("b is bad"))
+	err.Add("c", NewPermissionError("c is forbidden"))
+	c.Check(err.HasFailures(), jc.IsTrue)
+	c.Check(err.Failed(), jc.DeepEquals, []string{"b", "c"})
+	c.Check(err.Succeeded(), jc.DeepEquals, []string{"a"})
+	c.Check(err.Err("a"), jc.ErrorIsNil)
+	c.Check(err.Err("b"), jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "2 of 3 failed: b: b is bad; c: c is forbidden")
+	c.Check(errors.Cause(err), gc.Equals, err)
+	c.Check(err, gc.Not(jc.Satisfies), IsBadRequestError)
+}
+
+func (*errorTypesSuite) TestBulkErrorSharedCause(c *gc.C) {
+	cause := NewCannotCompleteError("busy")
+	err := bulkErrorForAll([]string{"a", "b"}, cause)
+	c.Check(err, jc.Satisfies, IsBulkError)
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err.Error(), gc.Equals, "busy")
+}
+
+func (*errorTypesSuite) TestBulkErrorTraced(c *gc.C) {
+	cause := NewCannotCompleteError("busy")
+	err := errors.Annotate(bulkErrorForAll([]string{"a", "b"}, cause), "releasing")
+	c.Check(err, jc.Satisfies, IsBulkError)
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	bulk, ok := AsBulkError(errors.Trace(err))
+	c.Assert(ok, jc.IsTrue)
+	c.Check(bulk.Failed(), jc.DeepEquals, []string{"a", "b"})
+
+	_, ok = AsBulkError(cause)
+	c.Check(ok, jc.IsFalse)
+	c.Check(IsBulkError(nil), jc.IsFalse)
+}
+
+func (*errorTypesSuite) TestBulkErrorNotAttempted(c *gc.C) {
+	cause := NewBadRequestError("Unknown machine(s): b.")
+	err := NewBulkError()
+	err.Add("a", NewNotAttemptedError("not released"))
+	err.Add("b", cause)
+	err.Add("c", NewNotAttemptedError("not released"))
+	c.Check(err.Failed(), jc.DeepEquals, []string{"a", "b", "c"})
+	c.Check(err.NotAttempted(), jc.DeepEquals, []string{"a", "c"})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "Unknown machine(s): b.")
+}
+
+func (*errorTypesSuite) TestBulkErrorNoIDs(c *gc.C) {
+	cause := NewCannotCompleteError("busy")
+	c.Check(bulkErrorForAll(nil, cause), gc.Equals, cause)
+}
+
+func (*errorTypesSuite) TestBulkErrorIs(c *gc.C) {
+	sentinel := stderrors.New("sentinel")
+	err := NewBulkError()
+	err.Add("a", nil)
+	c.Check(stderrors.Is(err, sentinel), jc.IsFalse)
+	err.Add("b", errors.Trace(sentinel))
+	c.Check(stderrors.Is(err, sentinel), jc.IsTrue)
+}
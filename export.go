@@ -0,0 +1,235 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// MachineColumn is a column of the table written by WriteMachinesCSV. Its
+// value is the heading of the column.
+type MachineColumn string
+
+// The columns of WriteMachinesCSV.
+const (
+	MachineColumnSystemID       MachineColumn = "system_id"
+	MachineColumnHostname       MachineColumn = "hostname"
+	MachineColumnFQDN           MachineColumn = "fqdn"
+	MachineColumnStatus         MachineColumn = "status"
+	MachineColumnStatusMessage  MachineColumn = "status_message"
+	MachineColumnPowerState     MachineColumn = "power_state"
+	MachineColumnPowerType      MachineColumn = "power_type"
+	MachineColumnOwner          MachineColumn = "owner"
+	MachineColumnZone           MachineColumn = "zone"
+	MachineColumnPool           MachineColumn = "pool"
+	MachineColumnArchitecture   MachineColumn = "architecture"
+	MachineColumnCPUCount       MachineColumn = "cpu_count"
+	MachineColumnMemory         MachineColumn = "memory"
+	MachineColumnOS             MachineColumn = "os"
+	MachineColumnDistroSeries   MachineColumn = "distro_series"
+	MachineColumnTags           MachineColumn = "tags"
+	MachineColumnIPAddresses    MachineColumn = "ip_addresses"
+	MachineColumnBootIPAddress  MachineColumn = "boot_ip_address"
+	MachineColumnBootMACAddress MachineColumn = "boot_mac_address"
+)
+
+// DefaultMachineColumns are the columns written when
+// WriteMachinesCSVArgs.Columns is empty.
+var DefaultMachineColumns = []MachineColumn{
+	MachineColumnSystemID,
+	MachineColumnHostname,
+	MachineColumnStatus,
+	MachineColumnPowerState,
+	MachineColumnOwner,
+	MachineColumnZone,
+	MachineColumnPool,
+	MachineColumnIPAddresses,
+	MachineColumnTags,
+}
+
+var machineColumns = map[MachineColumn]func(m Machine, sep string) string{
+	MachineColumnSystemID:      func(m Machine, _ string) string { return m.SystemID() },
+	MachineColumnHostname:      func(m Machine, _ string) string { return m.Hostname() },
+	MachineColumnFQDN:          func(m Machine, _ string) string { return m.FQDN() },
+	MachineColumnStatus:        func(m Machine, _ string) string { return m.StatusName() },
+	MachineColumnStatusMessage: func(m Machine, _ string) string { return m.StatusMessage() },
+	MachineColumnPowerState:    func(m Machine, _ string) string { return m.PowerState() },
+	MachineColumnPowerType:     func(m Machine, _ string) string { return m.PowerType() },
+	MachineColumnOwner:         func(m Machine, _ string) string { return m.Owner() },
+	MachineColumnZone: func(m Machine, _ string) string {
+		if zone := m.Zone(); zone != nil {
+			return zone.Name()
+		}
+		return ""
+	},
+	MachineColumnPool: func(m Machine, _ string) string {
+		if pool := m.Pool(); pool != nil {
+			return pool.Name()
+		}
+		return ""
+	},
+	MachineColumnArchitecture: func(m Machine, _ string) string { return m.Architecture() },
+	MachineColumnCPUCount:     func(m Machine, _ string) string { return strconv.Itoa(m.CPUCount()) },
+	MachineColumnMemory:       func(m Machine, _ string) string { return strconv.Itoa(m.Memory()) },
+	MachineColumnOS:           func(m Machine, _ string) string { return m.OperatingSystem() },
+	MachineColumnDistroSeries: func(m Machine, _ string) string { return m.DistroSeries() },
+	MachineColumnTags: func(m Machine, sep string) string {
+		tags := append([]string(nil), m.Tags()...)
+		sort.Strings(tags)
+		return strings.Join(tags, sep)
+	},
+	MachineColumnIPAddresses: func(m Machine, sep string) string {
+		return strings.Join(sortedAddresses(m.IPAddresses()), sep)
+	},
+	MachineColumnBootIPAddress: func(m Machine, _ string) string { return bootIPAddress(m) },
+	MachineColumnBootMACAddress: func(m Machine, _ string) string {
+		if iface := m.BootInterface(); iface != nil {
+			return iface.MACAddress()
+		}
+		return ""
+	},
+}
+
+// WriteMachinesCSVArgs is an argument struct for WriteMachinesCSV.
+type WriteMachinesCSVArgs struct {
+	// Columns are the columns to write, in order. DefaultMachineColumns
+	// are written if it is empty.
+	Columns []MachineColumn
+
+	// NoHeader leaves out the row of column headings.
+	NoHeader bool
+
+	// Separator joins the values of the list columns, the tags and the IP
+	// addresses, within their cells. It is a space if empty.
+	Separator string
+
+	// EscapeFormulas prefixes cells that a spreadsheet would read as a
+	// formula, those starting with "=", "+", "-", "@", a tab or a carriage
+	// return, with a quote. Set it when the file is for Excel or similar.
+	EscapeFormulas bool
+}
+
+// Validate ensures that the columns are known.
+func (a *WriteMachinesCSVArgs) Validate() error {
+	for _, column := range a.Columns {
+		if _, ok := machineColumns[column]; !ok {
+			return errors.NotValidf("unknown column %q", column)
+		}
+	}
+	return nil
+}
+
+// WriteMachinesCSV writes a row for each of the machines to the writer as
+// CSV, ordered by hostname and then system ID so that exports of the same
+// machines can be compared.
+//
+// The status column is the status name, such as "Deployed", which is also
+// what the web UI shows; the status message only describes the last event.
+// The IP addresses column is every address the region knows for the
+// machine, deduplicated and sorted with IPv4 before IPv6. The boot IP
+// address is the first address linked to the boot interface, which is
+// usually the one to reach the machine on.
+func WriteMachinesCSV(w io.Writer, machines []Machine, args WriteMachinesCSVArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	columns := args.Columns
+	if len(columns) == 0 {
+		columns = DefaultMachineColumns
+	}
+	sep := args.Separator
+	if sep == "" {
+		sep = " "
+	}
+
+	sorted := append([]Machine(nil), machines...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Hostname() != sorted[j].Hostname() {
+			return sorted[i].Hostname() < sorted[j].Hostname()
+		}
+		return sorted[i].SystemID() < sorted[j].SystemID()
+	})
+
+	writer := csv.NewWriter(w)
+	if !args.NoHeader {
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = string(column)
+		}
+		if err := writer.Write(header); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	for _, machine := range sorted {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			value := machineColumns[column](machine, sep)
+			if args.EscapeFormulas && value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+				value = "'" + value
+			}
+			row[i] = value
+		}
+		if err := writer.Write(row); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	writer.Flush()
+	return errors.Trace(writer.Error())
+}
+
+// sortedAddresses returns the addresses without duplicates, with IPv4
+// addresses before IPv6 ones and each in numeric order. Values that are
+// not addresses go last.
+func sortedAddresses(addresses []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, address := range addresses {
+		if address == "" || seen[address] {
+			continue
+		}
+		seen[address] = true
+		result = append(result, address)
+	}
+	key := func(address string) (int, []byte) {
+		ip := net.ParseIP(address)
+		switch {
+		case ip == nil:
+			return 2, []byte(address)
+		case ip.To4() != nil:
+			return 0, ip.To4()
+		default:
+			return 1, ip
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		iFamily, iKey := key(result[i])
+		jFamily, jKey := key(result[j])
+		if iFamily != jFamily {
+			return iFamily < jFamily
+		}
+		return bytes.Compare(iKey, jKey) < 0
+	})
+	return result
+}
+
+func bootIPAddress(m Machine) string {
+	iface := m.BootInterface()
+	if iface == nil {
+		return ""
+	}
+	for _, link := range iface.Links() {
+		if link.IPAddress() != "" {
+			return link.IPAddress()
+		}
+	}
+	return ""
+}
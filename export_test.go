@@ -0,0 +1,89 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type exportSuite struct{}
+
+var _ = gc.Suite(&exportSuite{})
+
+func (*exportSuite) machines(c *gc.C) []Machine {
+	machines, err := readMachines(twoDotOh, parseJSON(c, machinesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	result := make([]Machine, len(machines))
+	for i, m := range machines {
+		result[i] = m
+	}
+	return result
+}
+
+func (s *exportSuite) TestWriteMachinesCSV(c *gc.C) {
+	var buf bytes.Buffer
+	err := WriteMachinesCSV(&buf, s.machines(c), WriteMachinesCSVArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(buf.String(), gc.Equals, ""+
+		"system_id,hostname,status,power_state,owner,zone,pool,ip_addresses,tags\n"+
+		"4y3ha6,icier-nina,Ready,off,,default,default,,virtual\n"+
+		"4y3ha4,lowlier-glady,Ready,off,,default,default,,virtual\n"+
+		"4y3ha3,untasted-markita,Deployed,on,thumper,default,default,192.168.100.4,magic virtual\n")
+}
+
+func (s *exportSuite) TestWriteMachinesCSVColumns(c *gc.C) {
+	var buf bytes.Buffer
+	err := WriteMachinesCSV(&buf, s.machines(c)[:1], WriteMachinesCSVArgs{
+		Columns:   []MachineColumn{MachineColumnHostname, MachineColumnTags, MachineColumnBootIPAddress, MachineColumnMemory},
+		NoHeader:  true,
+		Separator: ",",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(buf.String(), gc.Equals, "untasted-markita,\"magic,virtual\",192.168.100.4,1024\n")
+}
+
+func (s *exportSuite) TestWriteMachinesCSVEscapeFormulas(c *gc.C) {
+	for _, test := range []struct {
+		hostname string
+		expected string
+	}{
+		{"=cmd|' /C calc'!A0", "'=cmd|' /C calc'!A0,4y3ha3\n"},
+		{"\t=1+1", "'\t=1+1,4y3ha3\n"},
+		{"\r=1+1", "\"'\r=1+1\",4y3ha3\n"},
+		{"host", "host,4y3ha3\n"},
+	} {
+		machines := s.machines(c)[:1]
+		machines[0].(*machine).hostname = test.hostname
+		var buf bytes.Buffer
+		err := WriteMachinesCSV(&buf, machines, WriteMachinesCSVArgs{
+			Columns:        []MachineColumn{MachineColumnHostname, MachineColumnSystemID},
+			NoHeader:       true,
+			EscapeFormulas: true,
+		})
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(buf.String(), gc.Equals, test.expected, gc.Commentf("%q", test.hostname))
+	}
+}
+
+func (s *exportSuite) TestWriteMachinesCSVUnknownColumn(c *gc.C) {
+	var buf bytes.Buffer
+	err := WriteMachinesCSV(&buf, s.machines(c), WriteMachinesCSVArgs{
+		Columns: []MachineColumn{"rack"},
+	})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, `unknown column "rack" not valid`)
+	c.Check(buf.Len(), gc.Equals, 0)
+}
+
+func (*exportSuite) TestSortedAddresses(c *gc.C) {
+	c.Check(sortedAddresses([]string{
+		"fd00::1", "10.0.0.10", "10.0.0.9", "", "10.0.0.10", "not-an-ip", "192.168.1.1", "::1",
+	}), jc.DeepEquals, []string{
+		"10.0.0.9", "10.0.0.10", "192.168.1.1", "::1", "fd00::1", "not-an-ip",
+	})
+}
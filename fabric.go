@@ -50,7 +50,7 @@ func readFabrics(controllerVersion version.Number, source interface{}) ([]*fabri
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "fabric base schema check failed")
+		return nil, WrapWithDeserializationError(err, "fabric base schema check failed")
 	}
 	valid := coerced.([]interface{})
 
@@ -73,11 +73,11 @@ func readFabricList(sourceList []interface{}, readFunc fabricDeserializationFunc
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, errors.Errorf("unexpected value for fabric %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for fabric %d, %T", i, value), indexPath(i))
 		}
 		fabric, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "fabric %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "fabric %d", i)
 		}
 		result = append(result, fabric)
 	}
@@ -101,7 +101,7 @@ func fabric_2_0(source map[string]interface{}) (*fabric, error) {
 	checker := schema.FieldMap(fields, nil) // no defaults
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "fabric 2.0 schema check failed")
+		return nil, WrapWithDeserializationError(err, "fabric 2.0 schema check failed")
 	}
 	valid := coerced.(map[string]interface{})
 	// From here we know that the map returned from the schema coercion
@@ -109,7 +109,7 @@ func fabric_2_0(source map[string]interface{}) (*fabric, error) {
 
 	vlans, err := readVLANList(valid["vlans"].([]interface{}), vlan_2_0)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return nil, errors.Trace(atPath(err, "vlans"))
 	}
 
 	// Since the class_type is optional, we use the two part cast assignment. If
@@ -4,9 +4,15 @@
 package gomaasapi
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/juju/schema"
@@ -20,6 +26,13 @@ type file struct {
 	filename     string
 	anonymousURI *url.URL
 	content      string
+
+	// size is -1 and sha256 empty when the server did not report them,
+	// until describe works them out from the content.
+	size    int64
+	sha256  string
+	created time.Time
+	updated time.Time
 }
 
 // Filename implements File.
@@ -29,10 +42,54 @@ func (f *file) Filename() string {
 
 // AnonymousURL implements File.
 func (f *file) AnonymousURL() string {
-	url := f.controller.client.GetURL(f.anonymousURI)
+	uri := *f.anonymousURI
+	uri.Path = f.controller.serverPath(uri.Path)
+	url := f.controller.client.GetURL(&uri)
 	return url.String()
 }
 
+// Size implements File.
+func (f *file) Size() (int64, error) {
+	if err := f.describe(); err != nil {
+		return 0, errors.Trace(err)
+	}
+	return f.size, nil
+}
+
+// SHA256 implements File.
+func (f *file) SHA256() (string, error) {
+	if err := f.describe(); err != nil {
+		return "", errors.Trace(err)
+	}
+	return f.sha256, nil
+}
+
+// Created implements File.
+func (f *file) Created() time.Time {
+	return f.created
+}
+
+// Updated implements File.
+func (f *file) Updated() time.Time {
+	return f.updated
+}
+
+// describe fills in the size and hash from the content if the server did
+// not report them.
+func (f *file) describe() error {
+	if f.size >= 0 && f.sha256 != "" {
+		return nil
+	}
+	content, err := f.ReadAll()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	digest := sha256.Sum256(content)
+	f.size = int64(len(content))
+	f.sha256 = hex.EncodeToString(digest[:])
+	return nil
+}
+
 // Delete implements File.
 func (f *file) Delete() error {
 	err := f.controller.delete(f.resourceURI)
@@ -62,11 +119,46 @@ func (f *file) ReadAll() ([]byte, error) {
 	return bytes, nil
 }
 
+// OpenReader implements File.
+func (f *file) OpenReader(offset int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, errors.NotValidf("negative offset %d", offset)
+	}
+	if f.content != "" {
+		content, err := f.ReadAll()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if offset > int64(len(content)) {
+			offset = int64(len(content))
+		}
+		return ioutil.NopCloser(bytes.NewReader(content[offset:])), nil
+	}
+	uri := *f.anonymousURI
+	uri.Path = f.controller.serverPath(uri.Path)
+	reader, err := f.controller.client.GetReader(&uri, offset)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		if IsContextError(err) {
+			return nil, errors.Trace(err)
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	return reader, nil
+}
+
 func (f *file) readFromServer() ([]byte, error) {
 	// If the content is available, it is base64 encoded, so
 	args := make(url.Values)
 	args.Add("filename", f.filename)
-	bytes, err := f.controller._getRaw("files", "get", args)
+	bytes, err := f.controller._getRaw(FilesPath, "get", args)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
 			switch svrErr.StatusCode {
@@ -134,7 +226,7 @@ func readFileList(sourceList []interface{}, readFunc fileDeserializationFunc) ([
 		}
 		file, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "file %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "file %d", i)
 		}
 		result = append(result, file)
 	}
@@ -153,9 +245,17 @@ func file_2_0(source map[string]interface{}) (*file, error) {
 		"filename":          schema.String(),
 		"anon_resource_uri": schema.String(),
 		"content":           schema.String(),
+		"size":              schema.ForceInt(),
+		"sha256":            schema.String(),
+		"created":           schema.String(),
+		"updated":           schema.String(),
 	}
 	defaults := schema.Defaults{
 		"content": "",
+		"size":    -1,
+		"sha256":  "",
+		"created": "",
+		"updated": "",
 	}
 	checker := schema.FieldMap(fields, defaults)
 	coerced, err := checker.Coerce(source, nil)
@@ -171,11 +271,51 @@ func file_2_0(source map[string]interface{}) (*file, error) {
 		return nil, NewUnexpectedError(err)
 	}
 
+	created, err := parseTimestamp(valid["created"].(string))
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "file 2.0 created")
+	}
+	updated, err := parseTimestamp(valid["updated"].(string))
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "file 2.0 updated")
+	}
+
 	result := &file{
 		resourceURI:  valid["resource_uri"].(string),
 		filename:     valid["filename"].(string),
 		anonymousURI: anonURI,
 		content:      valid["content"].(string),
+		size:         int64(valid["size"].(int)),
+		sha256:       valid["sha256"].(string),
+		created:      created,
+		updated:      updated,
 	}
 	return result, nil
 }
+
+// timestampLayouts are the formats MAAS uses for times. It leaves out the
+// time zone, which is always UTC.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	// Script results.
+	"Mon, 02 Jan. 2006 15:04:05",
+}
+
+// parseTimestamp parses a time from the server, returning the zero time
+// for an empty string.
+func parseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	var lastErr error
+	for _, layout := range timestampLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t.UTC(), nil
+		}
+		lastErr = err
+	}
+	return time.Time{}, errors.Trace(lastErr)
+}
@@ -4,8 +4,13 @@
 package gomaasapi
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
 	"net/http"
+	"time"
 
+	"github.com/juju/errors"
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
@@ -69,6 +74,96 @@ func (s *fileSuite) TestReadAllFromFiles(c *gc.C) {
 	c.Assert(string(content), gc.Equals, "some content\n")
 }
 
+func (s *fileSuite) TestOpenReaderFromGetFile(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/files/testing/", http.StatusOK, fileResponse)
+	file, err := controller.GetFile("testing")
+	c.Assert(err, jc.ErrorIsNil)
+	server.ResetRequests()
+	reader, err := file.OpenReader(5)
+	c.Assert(err, jc.ErrorIsNil)
+	defer reader.Close()
+	content, err := ioutil.ReadAll(reader)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, "is a test\n")
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *fileSuite) TestOpenReaderFromFiles(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/files/", http.StatusOK, filesResponse)
+	server.AddGetResponse("/MAAS/api/2.0/files/?op=get_by_key&key=3afba564-fb7d-11e5-932f-52540051bf22", http.StatusOK, "some content\n")
+	files, err := controller.Files("")
+	c.Assert(err, jc.ErrorIsNil)
+	reader, err := files[0].OpenReader(5)
+	c.Assert(err, jc.ErrorIsNil)
+	defer reader.Close()
+	content, err := ioutil.ReadAll(reader)
+	c.Assert(err, jc.ErrorIsNil)
+	// The test server ignores the range, so the start is skipped.
+	c.Check(string(content), gc.Equals, "content\n")
+	c.Check(server.LastRequest().Header.Get("Range"), gc.Equals, "bytes=5-")
+}
+
+func (s *fileSuite) TestOpenReaderMissing(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/files/", http.StatusOK, filesResponse)
+	files, err := controller.Files("")
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = files[0].OpenReader(0)
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	_, err = files[0].OpenReader(-1)
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (*fileSuite) TestReadFileMetadata(c *gc.C) {
+	file, err := readFile(twoDotOh, parseJSON(c, `{
+        "resource_uri": "/MAAS/api/2.0/files/testing/",
+        "anon_resource_uri": "/MAAS/api/2.0/files/?op=get_by_key&key=88e64b76",
+        "filename": "testing",
+        "size": 15,
+        "sha256": "abc123",
+        "created": "2019-06-01T12:30:00.123456",
+        "updated": "2019-06-02T08:00:00Z"
+    }`))
+	c.Assert(err, jc.ErrorIsNil)
+	size, err := file.Size()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(size, gc.Equals, int64(15))
+	digest, err := file.SHA256()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(digest, gc.Equals, "abc123")
+	c.Check(file.Created(), gc.Equals, time.Date(2019, 6, 1, 12, 30, 0, 123456000, time.UTC))
+	c.Check(file.Updated(), gc.Equals, time.Date(2019, 6, 2, 8, 0, 0, 0, time.UTC))
+}
+
+func (*fileSuite) TestReadFileBadTimestamp(c *gc.C) {
+	_, err := readFile(twoDotOh, parseJSON(c, `{
+        "resource_uri": "/MAAS/api/2.0/files/testing/",
+        "anon_resource_uri": "/MAAS/api/2.0/files/?op=get_by_key&key=88e64b76",
+        "filename": "testing",
+        "created": "yesterday"
+    }`))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (s *fileSuite) TestMetadataFromContent(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/files/", http.StatusOK, filesResponse)
+	server.AddGetResponse("/api/2.0/files/?filename=test&op=get", http.StatusOK, "some content\n")
+	files, err := controller.Files("")
+	c.Assert(err, jc.ErrorIsNil)
+	file := files[0]
+	c.Check(file.Created().IsZero(), jc.IsTrue)
+	size, err := file.Size()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(size, gc.Equals, int64(13))
+	// The content is only fetched once.
+	digest, err := file.SHA256()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(digest, gc.Equals, sha256Hex("some content\n"))
+}
+
 func (s *fileSuite) TestDeleteMissing(c *gc.C) {
 	// If we get a file, but someone else deletes it first, we get a ...
 	server, controller := createTestServerController(c, s)
@@ -114,3 +209,8 @@ var (
 ]
 `
 )
+
+func sha256Hex(content string) string {
+	digest := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(digest[:])
+}
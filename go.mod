@@ -1,6 +1,6 @@
 module github.com/seanhoughton/gomaasapi
 
-go 1.13
+go 1.18
 
 require (
 	github.com/juju/collections v0.0.0-20180515203731-520e0549d51a
@@ -0,0 +1,142 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/juju/collections/set"
+)
+
+// Hardware components reported in a HardwareChange.
+const (
+	HardwareMemory    = "memory"
+	HardwareCPU       = "cpu"
+	HardwareDisk      = "disk"
+	HardwareInterface = "interface"
+)
+
+// HardwareChange is a single difference found by CompareHardware.
+type HardwareChange struct {
+	// Component is one of the Hardware* values.
+	Component string
+
+	// Name is the name of the disk or interface that differs. It is empty
+	// for memory, CPU and count changes.
+	Name string
+
+	// Property is what differs, such as "count", "model", "size" or
+	// "link_speed". A disk or interface missing on either side has the
+	// property "present".
+	Property string
+
+	// Baseline and Current hold the values on each machine.
+	Baseline string
+	Current  string
+}
+
+// String returns a readable description of the change.
+func (c HardwareChange) String() string {
+	what := c.Component
+	if c.Name != "" {
+		what += " " + c.Name
+	}
+	return fmt.Sprintf("%s %s: %q -> %q", what, c.Property, c.Baseline, c.Current)
+}
+
+// HardwareDiff is the result of CompareHardware.
+type HardwareDiff struct {
+	Changes []HardwareChange
+}
+
+// Empty returns true if no differences were found.
+func (d HardwareDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// String returns one line per change.
+func (d HardwareDiff) String() string {
+	lines := make([]string, len(d.Changes))
+	for i, change := range d.Changes {
+		lines[i] = change.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CompareHardware reports how the hardware of current differs from that of
+// baseline: memory, CPU count and architecture, the physical disks with
+// their models and sizes, and the physical interfaces with their speeds.
+// Disks and interfaces are matched by name.
+//
+// To compare a machine with a golden spec rather than another machine, pass
+// NewTestMachine(spec) as the baseline.
+func CompareHardware(baseline, current Machine) HardwareDiff {
+	var diff HardwareDiff
+	add := func(component, name, property, before, after string) {
+		if before != after {
+			diff.Changes = append(diff.Changes, HardwareChange{
+				Component: component,
+				Name:      name,
+				Property:  property,
+				Baseline:  before,
+				Current:   after,
+			})
+		}
+	}
+
+	add(HardwareMemory, "", "size", strconv.Itoa(baseline.Memory()), strconv.Itoa(current.Memory()))
+	add(HardwareCPU, "", "count", strconv.Itoa(baseline.CPUCount()), strconv.Itoa(current.CPUCount()))
+	add(HardwareCPU, "", "architecture", baseline.Architecture(), current.Architecture())
+
+	diskNames := set.NewStrings()
+	baseDisks := blockDevicesByName(baseline.PhysicalBlockDevices(), diskNames)
+	currentDisks := blockDevicesByName(current.PhysicalBlockDevices(), diskNames)
+	add(HardwareDisk, "", "count", strconv.Itoa(len(baseDisks)), strconv.Itoa(len(currentDisks)))
+	for _, name := range diskNames.SortedValues() {
+		before, after := baseDisks[name], currentDisks[name]
+		if before == nil || after == nil {
+			add(HardwareDisk, name, "present", strconv.FormatBool(before != nil), strconv.FormatBool(after != nil))
+			continue
+		}
+		add(HardwareDisk, name, "model", before.Model(), after.Model())
+		add(HardwareDisk, name, "size", strconv.FormatUint(before.Size(), 10), strconv.FormatUint(after.Size(), 10))
+	}
+
+	nicNames := set.NewStrings()
+	baseNICs := physicalInterfacesByName(baseline.InterfaceSet(), nicNames)
+	currentNICs := physicalInterfacesByName(current.InterfaceSet(), nicNames)
+	add(HardwareInterface, "", "count", strconv.Itoa(len(baseNICs)), strconv.Itoa(len(currentNICs)))
+	for _, name := range nicNames.SortedValues() {
+		before, after := baseNICs[name], currentNICs[name]
+		if before == nil || after == nil {
+			add(HardwareInterface, name, "present", strconv.FormatBool(before != nil), strconv.FormatBool(after != nil))
+			continue
+		}
+		add(HardwareInterface, name, "link_speed", strconv.Itoa(before.LinkSpeed()), strconv.Itoa(after.LinkSpeed()))
+		add(HardwareInterface, name, "interface_speed", strconv.Itoa(before.InterfaceSpeed()), strconv.Itoa(after.InterfaceSpeed()))
+	}
+	return diff
+}
+
+func blockDevicesByName(devices []BlockDevice, names set.Strings) map[string]BlockDevice {
+	result := make(map[string]BlockDevice, len(devices))
+	for _, device := range devices {
+		result[device.Name()] = device
+		names.Add(device.Name())
+	}
+	return result
+}
+
+func physicalInterfacesByName(interfaces []Interface, names set.Strings) map[string]Interface {
+	result := make(map[string]Interface)
+	for _, iface := range interfaces {
+		if iface.Type() == "physical" {
+			result[iface.Name()] = iface
+			names.Add(iface.Name())
+		}
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type hardwareSuite struct{}
+
+var _ = gc.Suite(&hardwareSuite{})
+
+func baselineSpec() MachineSpec {
+	return MachineSpec{
+		Architecture: "amd64/generic",
+		Memory:       16384,
+		CPUCount:     8,
+		Interfaces: []InterfaceTestSpec{
+			{Name: "eth0", Type: "physical", LinkSpeed: 10000, InterfaceSpeed: 10000},
+			{Name: "eth1", Type: "physical", LinkSpeed: 1000, InterfaceSpeed: 1000},
+			{Name: "bond0", Type: "bond"},
+		},
+		BlockDevices: []BlockDeviceSpec{
+			{Name: "sda", Model: "QEMU HARDDISK", Size: 1000},
+			{Name: "sdb", Model: "QEMU HARDDISK", Size: 2000},
+		},
+	}
+}
+
+func (*hardwareSuite) TestCompareHardwareSame(c *gc.C) {
+	diff := CompareHardware(NewTestMachine(baselineSpec()), NewTestMachine(baselineSpec()))
+	c.Check(diff.Empty(), jc.IsTrue)
+	c.Check(diff.String(), gc.Equals, "")
+}
+
+func (*hardwareSuite) TestCompareHardware(c *gc.C) {
+	spec := baselineSpec()
+	spec.Memory = 8192
+	spec.Interfaces[0].LinkSpeed = 1000
+	spec.Interfaces = spec.Interfaces[:1]
+	spec.BlockDevices[0].Model = "Other"
+	spec.BlockDevices = append(spec.BlockDevices, BlockDeviceSpec{Name: "sdc", Size: 10})
+
+	diff := CompareHardware(NewTestMachine(baselineSpec()), NewTestMachine(spec))
+	c.Check(diff.Empty(), jc.IsFalse)
+	c.Check(diff.Changes, jc.DeepEquals, []HardwareChange{
+		{Component: HardwareMemory, Property: "size", Baseline: "16384", Current: "8192"},
+		{Component: HardwareDisk, Property: "count", Baseline: "2", Current: "3"},
+		{Component: HardwareDisk, Name: "sda", Property: "model", Baseline: "QEMU HARDDISK", Current: "Other"},
+		{Component: HardwareDisk, Name: "sdc", Property: "present", Baseline: "false", Current: "true"},
+		{Component: HardwareInterface, Property: "count", Baseline: "2", Current: "1"},
+		{Component: HardwareInterface, Name: "eth0", Property: "link_speed", Baseline: "10000", Current: "1000"},
+		{Component: HardwareInterface, Name: "eth1", Property: "present", Baseline: "true", Current: "false"},
+	})
+	c.Check(diff.Changes[5].String(), gc.Equals, `interface eth0 link_speed: "10000" -> "1000"`)
+}
@@ -0,0 +1,264 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+// BootImage identifies a boot image by its name, such as "ubuntu/bionic",
+// and its base architecture, such as "amd64".
+type BootImage struct {
+	Name         string
+	Architecture string
+}
+
+// String returns the image as "name/architecture".
+func (i BootImage) String() string {
+	return fmt.Sprintf("%s/%s", i.Name, i.Architecture)
+}
+
+// RackImageSync describes the boot images of a single rack controller
+// compared with the images selected on the region.
+type RackImageSync struct {
+	SystemID string
+	Hostname string
+
+	// Connected is false if the region could not reach the rack to ask
+	// for its images, in which case Missing is empty.
+	Connected bool
+
+	// Status is the sync status reported by the rack, such as "synced"
+	// or "syncing".
+	Status string
+
+	// Missing holds the images selected on the region that the rack does
+	// not have.
+	Missing []BootImage
+
+	// Error is set if the images of the rack could not be listed, in
+	// which case the other fields but the IDs are empty.
+	Error error
+}
+
+// InSync returns true if the rack is connected and has all the images.
+func (r RackImageSync) InSync() bool {
+	return r.Error == nil && r.Connected && len(r.Missing) == 0
+}
+
+// ImageSyncReport is the result of Controller.CheckImageSync.
+type ImageSyncReport struct {
+	// Region holds the images selected for import by the boot source
+	// selections of the region, sorted by name and architecture. A
+	// selection of all architectures stands for those that the region
+	// has imported.
+	Region []BootImage
+
+	// Racks has an entry for every rack controller.
+	Racks []RackImageSync
+}
+
+// OutOfSync returns the racks that are not in sync with the region.
+func (r ImageSyncReport) OutOfSync() []RackImageSync {
+	var result []RackImageSync
+	for _, rack := range r.Racks {
+		if !rack.InSync() {
+			result = append(result, rack)
+		}
+	}
+	return result
+}
+
+// CheckImageSync implements Controller.
+func (c *controller) CheckImageSync() (ImageSyncReport, error) {
+	expected, err := c.selectedBootImages()
+	if err != nil {
+		return ImageSyncReport{}, errors.Trace(err)
+	}
+	var report ImageSyncReport
+	for image := range expected {
+		report.Region = append(report.Region, image)
+	}
+	sortBootImages(report.Region)
+
+	source, err := c.get(RackControllersPath)
+	if err != nil {
+		return ImageSyncReport{}, NewUnexpectedError(err)
+	}
+	racks, err := readControllerNodes(c.schemaVersion(), source)
+	if err != nil {
+		return ImageSyncReport{}, errors.Trace(err)
+	}
+	for _, rack := range racks {
+		status := RackImageSync{
+			SystemID: rack.systemID,
+			Hostname: rack.hostname,
+		}
+		images, err := c.rackBootImages(rack)
+		if err != nil {
+			status.Error = errors.Annotatef(err, "rack %s", rack.systemID)
+			report.Racks = append(report.Racks, status)
+			continue
+		}
+		status.Connected = images.connected
+		status.Status = images.status
+		if images.connected {
+			have := make(map[BootImage]bool)
+			for _, image := range images.images {
+				have[image] = true
+			}
+			for _, image := range report.Region {
+				if !have[image] {
+					status.Missing = append(status.Missing, image)
+				}
+			}
+		}
+		report.Racks = append(report.Racks, status)
+	}
+	return report, nil
+}
+
+// selectedBootImages returns the images that the boot source selections
+// import. The architectures of a selection of all of them are those of the
+// boot resources of the release.
+func (c *controller) selectedBootImages() (map[BootImage]bool, error) {
+	sources, err := c.BootSources()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var resources []BootResource
+	expected := make(map[BootImage]bool)
+	for _, source := range sources {
+		selections, err := source.Selections()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, selection := range selections {
+			name := selection.OS() + "/" + selection.Release()
+			for _, arch := range selection.Arches() {
+				if arch != "*" {
+					expected[bootImageFor(name, arch)] = true
+					continue
+				}
+				if resources == nil {
+					if resources, err = c.BootResources(); err != nil {
+						return nil, errors.Trace(err)
+					}
+				}
+				for _, resource := range resources {
+					if resource.Name() == name {
+						expected[bootImageFor(name, resource.Architecture())] = true
+					}
+				}
+			}
+		}
+	}
+	return expected, nil
+}
+
+func (c *controller) rackBootImages(rack *controllerNode) (*rackBootImages, error) {
+	source, err := c.getOp(rack.resourceURI, "list_boot_images")
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	return readRackBootImages(c.schemaVersion(), source)
+}
+
+// bootImageFor strips any sub-architecture, as in "amd64/generic", from
+// the architecture.
+func bootImageFor(name, architecture string) BootImage {
+	if i := strings.Index(architecture, "/"); i >= 0 {
+		architecture = architecture[:i]
+	}
+	return BootImage{Name: name, Architecture: architecture}
+}
+
+func sortBootImages(images []BootImage) {
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].Name != images[j].Name {
+			return images[i].Name < images[j].Name
+		}
+		return images[i].Architecture < images[j].Architecture
+	})
+}
+
+type rackBootImages struct {
+	connected bool
+	status    string
+	images    []BootImage
+}
+
+func readRackBootImages(controllerVersion version.Number, source interface{}) (*rackBootImages, error) {
+	var deserialisationVersion version.Number
+	for v := range rackBootImagesDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no rack boot images read func for version %s", controllerVersion)
+	}
+	readFunc := rackBootImagesDeserializationFuncs[deserialisationVersion]
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "rack boot images base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
+type rackBootImagesDeserializationFunc func(map[string]interface{}) (*rackBootImages, error)
+
+var rackBootImagesDeserializationFuncs = map[version.Number]rackBootImagesDeserializationFunc{
+	twoDotOh: rackBootImages_2_0,
+}
+
+func rackBootImages_2_0(source map[string]interface{}) (*rackBootImages, error) {
+	imageFields := schema.FieldMap(schema.Fields{
+		"name":         schema.String(),
+		"architecture": schema.String(),
+	}, nil)
+	fields := schema.Fields{
+		"connected": schema.Bool(),
+		"status":    schema.String(),
+		"images":    schema.List(imageFields),
+	}
+	defaults := schema.Defaults{
+		"status": "",
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "rack boot images 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	result := &rackBootImages{
+		connected: valid["connected"].(bool),
+		status:    valid["status"].(string),
+	}
+	for _, value := range valid["images"].([]interface{}) {
+		image := value.(map[string]interface{})
+		result.images = append(result.images, bootImageFor(image["name"].(string), image["architecture"].(string)))
+	}
+	return result, nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type imageSyncSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&imageSyncSuite{})
+
+func (*imageSyncSuite) TestReadRackBootImages(c *gc.C) {
+	images, err := readRackBootImages(twoDotOh, parseJSON(c, rackBootImagesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(images.connected, jc.IsTrue)
+	c.Check(images.status, gc.Equals, "synced")
+	c.Check(images.images, jc.DeepEquals, []BootImage{
+		{Name: "ubuntu/trusty", Architecture: "amd64"},
+		{Name: "ubuntu/xenial", Architecture: "amd64"},
+	})
+}
+
+func (*imageSyncSuite) TestReadRackBootImagesBadSchema(c *gc.C) {
+	_, err := readRackBootImages(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `rack boot images base schema check failed: expected map, got string("wat?")`)
+}
+
+// addSelectionResponses serves boot source 1 selecting trusty on amd64 and
+// xenial on all architectures, and boot source 2 selecting nothing.
+func addSelectionResponses(server *SimpleTestServer) {
+	server.AddGetResponse("/api/2.0/boot-sources/", http.StatusOK, bootSourcesResponse)
+	server.AddGetResponse("/MAAS/api/2.0/boot-sources/1/selections/", http.StatusOK, `[
+        {"resource_uri": "/MAAS/api/2.0/boot-sources/1/selections/1/", "id": 1, "os": "ubuntu",
+         "release": "trusty", "arches": ["amd64"], "subarches": ["*"], "labels": ["*"]},
+        {"resource_uri": "/MAAS/api/2.0/boot-sources/1/selections/2/", "id": 2, "os": "ubuntu",
+         "release": "xenial", "arches": ["*"], "subarches": ["*"], "labels": ["*"]}
+    ]`)
+	server.AddGetResponse("/MAAS/api/2.0/boot-sources/2/selections/", http.StatusOK, "[]")
+}
+
+func (s *imageSyncSuite) TestCheckImageSync(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	addSelectionResponses(server)
+	server.AddGetResponse("/api/2.0/boot-resources/", http.StatusOK, bootResourcesResponse)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, rackControllersResponse)
+	server.AddGetResponse("/MAAS/api/2.0/rackcontrollers/r1/?op=list_boot_images", http.StatusOK, rackBootImagesResponse)
+	server.AddGetResponse("/MAAS/api/2.0/rackcontrollers/r2/?op=list_boot_images", http.StatusOK, `{
+        "connected": true,
+        "status": "syncing",
+        "images": [{"name": "ubuntu/trusty", "architecture": "amd64", "subarches": ["generic"]}]
+    }`)
+	server.AddGetResponse("/MAAS/api/2.0/rackcontrollers/r3/?op=list_boot_images", http.StatusOK, `{
+        "connected": false,
+        "status": "unknown",
+        "images": []
+    }`)
+
+	report, err := controller.CheckImageSync()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(report.Region, jc.DeepEquals, []BootImage{
+		{Name: "ubuntu/trusty", Architecture: "amd64"},
+		{Name: "ubuntu/xenial", Architecture: "amd64"},
+	})
+	c.Assert(report.Racks, gc.HasLen, 3)
+	c.Check(report.Racks[0].InSync(), jc.IsTrue)
+	c.Check(report.Racks[1].Missing, jc.DeepEquals, []BootImage{{Name: "ubuntu/xenial", Architecture: "amd64"}})
+	c.Check(report.Racks[1].Status, gc.Equals, "syncing")
+	c.Check(report.Racks[2].Connected, jc.IsFalse)
+
+	outOfSync := report.OutOfSync()
+	c.Assert(outOfSync, gc.HasLen, 2)
+	c.Check(outOfSync[0].Hostname, gc.Equals, "rack-2")
+	c.Check(outOfSync[1].Hostname, gc.Equals, "rack-3")
+}
+
+func (s *imageSyncSuite) TestCheckImageSyncComparesSelections(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/boot-sources/", http.StatusOK, bootSourcesResponse)
+	server.AddGetResponse("/MAAS/api/2.0/boot-sources/1/selections/", http.StatusOK, bootSourceSelectionsResponse)
+	server.AddGetResponse("/MAAS/api/2.0/boot-sources/2/selections/", http.StatusOK, "[]")
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, "[]")
+	server.ResetRequests()
+
+	report, err := controller.CheckImageSync()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(report.Region, jc.DeepEquals, []BootImage{
+		{Name: "ubuntu/jammy", Architecture: "amd64"},
+		{Name: "ubuntu/jammy", Architecture: "arm64"},
+	})
+	// The boot resources are not needed without a selection of all
+	// architectures.
+	c.Check(server.RequestCount(), gc.Equals, 4)
+}
+
+func (s *imageSyncSuite) TestCheckImageSyncRackErrors(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	addSelectionResponses(server)
+	server.AddGetResponse("/api/2.0/boot-resources/", http.StatusOK, bootResourcesResponse)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, rackControllersResponse)
+	server.AddGetResponse("/MAAS/api/2.0/rackcontrollers/r1/?op=list_boot_images", http.StatusForbidden, "admins only")
+	server.AddGetResponse("/MAAS/api/2.0/rackcontrollers/r2/?op=list_boot_images", http.StatusOK, rackBootImagesResponse)
+	server.AddGetResponse("/MAAS/api/2.0/rackcontrollers/r3/?op=list_boot_images", http.StatusNotFound, "gone")
+
+	report, err := controller.CheckImageSync()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(report.Racks, gc.HasLen, 3)
+	c.Check(report.Racks[0].Error, jc.Satisfies, IsPermissionError)
+	c.Check(report.Racks[0].Error, gc.ErrorMatches, "rack r1: admins only")
+	c.Check(report.Racks[0].InSync(), jc.IsFalse)
+	c.Check(report.Racks[1].InSync(), jc.IsTrue)
+	c.Check(report.Racks[2].Error, jc.Satisfies, IsNoMatchError)
+	c.Check(report.OutOfSync(), gc.HasLen, 2)
+}
+
+func (s *imageSyncSuite) TestCheckImageSyncSelectionsForbidden(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/boot-sources/", http.StatusForbidden, "admins only")
+
+	_, err := controller.CheckImageSync()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
+func (*imageSyncSuite) TestBootImageString(c *gc.C) {
+	c.Check(BootImage{Name: "ubuntu/bionic", Architecture: "arm64"}.String(), gc.Equals, "ubuntu/bionic/arm64")
+}
+
+const (
+	rackBootImagesResponse = `
+{
+    "connected": true,
+    "status": "synced",
+    "images": [
+        {"name": "ubuntu/trusty", "architecture": "amd64", "subarches": ["generic", "hwe-t"]},
+        {"name": "ubuntu/xenial", "architecture": "amd64", "subarches": ["generic"]}
+    ]
+}
+`
+	rackControllersResponse = `
+[
+    {
+        "system_id": "r1",
+        "hostname": "rack-1",
+        "fqdn": "rack-1.maas",
+        "node_type": 2,
+        "version": "2.5.0",
+        "ip_addresses": ["192.168.100.2"],
+        "resource_uri": "/MAAS/api/2.0/rackcontrollers/r1/"
+    },
+    {
+        "system_id": "r2",
+        "hostname": "rack-2",
+        "fqdn": "rack-2.maas",
+        "node_type": 2,
+        "version": "2.5.0",
+        "ip_addresses": ["192.168.100.3"],
+        "resource_uri": "/MAAS/api/2.0/rackcontrollers/r2/"
+    },
+    {
+        "system_id": "r3",
+        "hostname": "rack-3",
+        "fqdn": "rack-3.maas",
+        "node_type": 4,
+        "version": null,
+        "ip_addresses": [],
+        "resource_uri": "/MAAS/api/2.0/rackcontrollers/r3/"
+    }
+]
+`
+)
@@ -5,7 +5,9 @@ package gomaasapi
 
 import (
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/juju/errors"
 	"github.com/juju/schema"
@@ -30,6 +32,10 @@ type interface_ struct {
 	macAddress   string
 	effectiveMTU int
 
+	// Both speeds are in Mbit/s.
+	linkSpeed      int
+	interfaceSpeed int
+
 	parents  []string
 	children []string
 }
@@ -45,6 +51,8 @@ func (i *interface_) updateFrom(other *interface_) {
 	i.links = other.links
 	i.macAddress = other.macAddress
 	i.effectiveMTU = other.effectiveMTU
+	i.linkSpeed = other.linkSpeed
+	i.interfaceSpeed = other.interfaceSpeed
 	i.parents = other.parents
 	i.children = other.children
 }
@@ -111,11 +119,56 @@ func (i *interface_) EffectiveMTU() int {
 	return i.effectiveMTU
 }
 
+// LinkSpeed implements Interface.
+func (i *interface_) LinkSpeed() int {
+	return i.linkSpeed
+}
+
+// InterfaceSpeed implements Interface.
+func (i *interface_) InterfaceSpeed() int {
+	return i.interfaceSpeed
+}
+
 // UpdateInterfaceArgs is an argument struct for calling Interface.Update.
 type UpdateInterfaceArgs struct {
 	Name       string
 	MACAddress string
 	VLAN       VLAN
+	// Tags, if not nil, replaces all the tags of the interface. An empty
+	// slice removes them.
+	Tags []string
+	// MTU - Maximum transmission unit.
+	MTU int
+
+	// The bond parameters, as for CreateBondArgs, are only for bonds.
+	BondMode           string
+	BondMIIMon         int
+	BondDownDelay      int
+	BondUpDelay        int
+	BondLACPRate       string
+	BondXmitHashPolicy string
+}
+
+// Validate ensures that the bond mode and LACP rate are known values.
+func (a *UpdateInterfaceArgs) Validate() error {
+	if a.MTU < 0 {
+		return errors.NotValidf("negative MTU")
+	}
+	if a.BondMode != "" && !knownBondModes.Contains(a.BondMode) {
+		return errors.NotValidf("unknown BondMode value (%q)", a.BondMode)
+	}
+	switch a.BondLACPRate {
+	case "", "fast", "slow":
+	default:
+		return errors.NotValidf("unknown BondLACPRate value (%q)", a.BondLACPRate)
+	}
+	return nil
+}
+
+func (a *UpdateInterfaceArgs) empty() bool {
+	return a.Name == "" && a.MACAddress == "" && a.VLAN == nil && a.Tags == nil &&
+		a.MTU == 0 && a.BondMode == "" && a.BondMIIMon == 0 && a.BondDownDelay == 0 &&
+		a.BondUpDelay == 0 && a.BondLACPRate == "" && a.BondXmitHashPolicy == ""
 }
 
 func (a *UpdateInterfaceArgs) vlanID() int {
@@ -127,14 +180,26 @@ func (a *UpdateInterfaceArgs) vlanID() int {
 
 // Update implements Interface.
 func (i *interface_) Update(args UpdateInterfaceArgs) error {
-	var empty UpdateInterfaceArgs
-	if args == empty {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if args.empty() {
 		return nil
 	}
 	params := NewURLParams()
 	params.MaybeAdd("name", args.Name)
 	params.MaybeAdd("mac_address", args.MACAddress)
 	params.MaybeAddInt("vlan", args.vlanID())
+	if args.Tags != nil {
+		params.Values.Add("tags", strings.Join(args.Tags, ","))
+	}
+	params.MaybeAddInt("mtu", args.MTU)
+	params.MaybeAdd("bond_mode", args.BondMode)
+	params.MaybeAddInt("bond_miimon", args.BondMIIMon)
+	params.MaybeAddInt("bond_downdelay", args.BondDownDelay)
+	params.MaybeAddInt("bond_updelay", args.BondUpDelay)
+	params.MaybeAdd("bond_lacp_rate", args.BondLACPRate)
+	params.MaybeAdd("bond_xmit_hash_policy", args.BondXmitHashPolicy)
 	source, err := i.controller.put(i.resourceURI, params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
@@ -148,7 +213,44 @@ func (i *interface_) Update(args UpdateInterfaceArgs) error {
 		return NewUnexpectedError(err)
 	}
 
-	response, err := readInterface(i.controller.apiVersion, source)
+	response, err := readInterface(i.controller.schemaVersion(), source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	i.updateFrom(response)
+	return nil
+}
+
+// AddTag implements Interface.
+func (i *interface_) AddTag(tag string) error {
+	return errors.Trace(i.changeTag("add_tag", tag))
+}
+
+// RemoveTag implements Interface.
+func (i *interface_) RemoveTag(tag string) error {
+	return errors.Trace(i.changeTag("remove_tag", tag))
+}
+
+func (i *interface_) changeTag(op, tag string) error {
+	if tag == "" {
+		return errors.NotValidf("empty tag")
+	}
+	params := NewURLParams()
+	params.Values.Add("tag", tag)
+	source, err := i.controller.post(i.resourceURI, op, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+
+	response, err := readInterface(i.controller.schemaVersion(), source)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -191,6 +293,10 @@ const (
 	// address will be assigned to this interface. The interface cannot have any
 	// current DHCP or STATIC links.
 	LinkModeLinkUp InterfaceLinkMode = "LINK_UP"
+
+	// LinkModeAuto - Assign a static IP address from the subnet when the
+	// machine is deployed.
+	LinkModeAuto InterfaceLinkMode = "AUTO"
 )
 
 // LinkSubnetArgs is an argument struct for passing parameters to
@@ -203,7 +309,8 @@ type LinkSubnetArgs struct {
 	Subnet Subnet
 	// IPAddress is only valid when the Mode is set to LinkModeStatic. If
 	// not specified with a Mode of LinkModeStatic, an IP address from the
-	// subnet will be auto selected.
+	// subnet will be auto selected. It must be inside the CIDR of the
+	// Subnet.
 	IPAddress string
 	// DefaultGateway will set the gateway IP address for the Subnet as the
 	// default gateway for the machine or device the interface belongs to.
@@ -215,7 +322,7 @@ type LinkSubnetArgs struct {
 // are consistent with the Mode.
 func (a *LinkSubnetArgs) Validate() error {
 	switch a.Mode {
-	case LinkModeDHCP, LinkModeLinkUp, LinkModeStatic:
+	case LinkModeDHCP, LinkModeLinkUp, LinkModeStatic, LinkModeAuto:
 	case "":
 		return errors.NotValidf("missing Mode")
 	default:
@@ -230,6 +337,19 @@ func (a *LinkSubnetArgs) Validate() error {
 	if a.DefaultGateway && a.Mode != LinkModeStatic {
 		return errors.NotValidf("specifying DefaultGateway for Mode %q", a.Mode)
 	}
+	if a.IPAddress != "" {
+		ip := net.ParseIP(a.IPAddress)
+		if ip == nil {
+			return errors.NotValidf("IPAddress %q", a.IPAddress)
+		}
+		// The CIDR is only unknown for subnets not read from the server.
+		if cidr := a.Subnet.CIDR(); cidr != "" {
+			_, network, err := net.ParseCIDR(cidr)
+			if err == nil && !network.Contains(ip) {
+				return errors.NotValidf("IPAddress %q outside subnet %s", a.IPAddress, cidr)
+			}
+		}
+	}
 	return nil
 }
 
@@ -258,7 +378,7 @@ func (i *interface_) LinkSubnet(args LinkSubnetArgs) error {
 		return NewUnexpectedError(err)
 	}
 
-	response, err := readInterface(i.controller.apiVersion, source)
+	response, err := readInterface(i.controller.schemaVersion(), source)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -275,7 +395,7 @@ func (i *interface_) linkForSubnet(subnet Subnet) *link {
 	return nil
 }
 
-// LinkSubnet implements Interface.
+// UnlinkSubnet implements Interface.
 func (i *interface_) UnlinkSubnet(subnet Subnet) error {
 	if subnet == nil {
 		return errors.NotValidf("missing Subnet")
@@ -284,8 +404,27 @@ func (i *interface_) UnlinkSubnet(subnet Subnet) error {
 	if link == nil {
 		return errors.NotValidf("unlinked Subnet")
 	}
+	return errors.Trace(i.unlink(link.ID()))
+}
+
+// Unlink implements Interface.
+func (i *interface_) Unlink(linkID int) error {
+	found := false
+	for _, link := range i.links {
+		if link.ID() == linkID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return errors.NotFoundf("link %d of interface %s", linkID, i.name)
+	}
+	return errors.Trace(i.unlink(linkID))
+}
+
+func (i *interface_) unlink(linkID int) error {
 	params := NewURLParams()
-	params.Values.Add("id", fmt.Sprint(link.ID()))
+	params.Values.Add("id", fmt.Sprint(linkID))
 	source, err := i.controller.post(i.resourceURI, "unlink_subnet", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
@@ -299,7 +438,7 @@ func (i *interface_) UnlinkSubnet(subnet Subnet) error {
 		return NewUnexpectedError(err)
 	}
 
-	response, err := readInterface(i.controller.apiVersion, source)
+	response, err := readInterface(i.controller.schemaVersion(), source)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -356,11 +495,11 @@ func readInterfaceList(sourceList []interface{}, readFunc interfaceDeserializati
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, NewDeserializationError("unexpected value for interface %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for interface %d, %T", i, value), indexPath(i))
 		}
 		read, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "interface %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "interface %d", i)
 		}
 		result = append(result, read)
 	}
@@ -389,11 +528,17 @@ func interface_2_0(source map[string]interface{}) (*interface_, error) {
 		"mac_address":   schema.OneOf(schema.Nil(""), schema.String()),
 		"effective_mtu": schema.ForceInt(),
 
+		"link_speed":      schema.ForceInt(),
+		"interface_speed": schema.ForceInt(),
+
 		"parents":  schema.List(schema.String()),
 		"children": schema.List(schema.String()),
 	}
 	defaults := schema.Defaults{
 		"mac_address": "",
+		// Servers before 2.5 do not report speeds.
+		"link_speed":      0,
+		"interface_speed": 0,
 	}
 	checker := schema.FieldMap(fields, defaults)
 	coerced, err := checker.Coerce(source, nil)
@@ -409,13 +554,13 @@ func interface_2_0(source map[string]interface{}) (*interface_, error) {
 	if vlanMap, ok := valid["vlan"].(map[string]interface{}); ok {
 		vlan, err = vlan_2_0(vlanMap)
 		if err != nil {
-			return nil, errors.Trace(err)
+			return nil, errors.Trace(atPath(err, "vlan"))
 		}
 	}
 
 	links, err := readLinkList(valid["links"].([]interface{}), link_2_0)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return nil, errors.Trace(atPath(err, "links"))
 	}
 	macAddress, _ := valid["mac_address"].(string)
 	result := &interface_{
@@ -433,8 +578,154 @@ func interface_2_0(source map[string]interface{}) (*interface_, error) {
 		macAddress:   macAddress,
 		effectiveMTU: valid["effective_mtu"].(int),
 
+		linkSpeed:      valid["link_speed"].(int),
+		interfaceSpeed: valid["interface_speed"].(int),
+
 		parents:  convertToStringSlice(valid["parents"]),
 		children: convertToStringSlice(valid["children"]),
 	}
 	return result, nil
 }
+
+// MoveToVLANArgs is an argument struct for calling Interface.MoveToVLAN.
+type MoveToVLANArgs struct {
+	// VLAN is the VLAN to move the interface to. Required field.
+	VLAN VLAN
+	// Subnet is the subnet on the new VLAN that the links of the interface
+	// are recreated on. Required if the interface is linked to any subnet.
+	Subnet Subnet
+}
+
+// Validate ensures that the VLAN is set.
+func (a *MoveToVLANArgs) Validate() error {
+	if a.VLAN == nil {
+		return errors.NotValidf("missing VLAN")
+	}
+	return nil
+}
+
+// linkState records enough of a link to recreate it.
+type linkState struct {
+	mode      InterfaceLinkMode
+	subnet    Subnet
+	ipAddress string
+}
+
+func (l linkState) args() LinkSubnetArgs {
+	args := LinkSubnetArgs{Mode: l.mode, Subnet: l.subnet}
+	if l.mode == LinkModeStatic {
+		args.IPAddress = l.ipAddress
+	}
+	return args
+}
+
+// MoveToVLAN implements Interface.
+func (i *interface_) MoveToVLAN(args MoveToVLANArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	type originalLink struct {
+		id    int
+		state linkState
+	}
+	var original []originalLink
+	for _, link := range i.links {
+		if link.Subnet() == nil {
+			// The interface is up without a subnet, which it stays as.
+			continue
+		}
+		original = append(original, originalLink{
+			id: link.ID(),
+			state: linkState{
+				mode:      InterfaceLinkMode(strings.ToUpper(link.Mode())),
+				subnet:    link.Subnet(),
+				ipAddress: link.IPAddress(),
+			},
+		})
+	}
+	var states []linkState
+	for _, link := range original {
+		states = append(states, link.state)
+	}
+	modes := moveLinkModes(states)
+	if len(modes) > 0 && args.Subnet == nil {
+		return errors.NotValidf("missing Subnet")
+	}
+	var originalVLAN VLAN
+	if i.vlan != nil {
+		originalVLAN = i.vlan
+	}
+
+	var unlinked []linkState
+	linked := false
+	moved := false
+	rollback := func(cause error) error {
+		var failures []string
+		if linked {
+			var ids []int
+			for _, link := range i.links {
+				if link.Subnet() != nil && link.Subnet().ID() == args.Subnet.ID() {
+					ids = append(ids, link.ID())
+				}
+			}
+			for _, id := range ids {
+				if err := i.unlink(id); err != nil {
+					failures = append(failures, err.Error())
+				}
+			}
+		}
+		if moved && originalVLAN != nil {
+			if err := i.Update(UpdateInterfaceArgs{VLAN: originalVLAN}); err != nil {
+				failures = append(failures, err.Error())
+			}
+		}
+		for _, state := range unlinked {
+			if err := i.LinkSubnet(state.args()); err != nil {
+				failures = append(failures, err.Error())
+			}
+		}
+		if len(failures) > 0 {
+			return errors.Annotatef(cause, "rollback failed (%s)", strings.Join(failures, "; "))
+		}
+		return errors.Trace(cause)
+	}
+
+	for _, link := range original {
+		if err := i.unlink(link.id); err != nil {
+			return rollback(errors.Annotatef(err, "unlinking subnet %d", link.state.subnet.ID()))
+		}
+		unlinked = append(unlinked, link.state)
+	}
+	if err := i.Update(UpdateInterfaceArgs{VLAN: args.VLAN}); err != nil {
+		return rollback(errors.Annotatef(err, "setting VLAN %d", args.VLAN.ID()))
+	}
+	moved = true
+	for _, mode := range modes {
+		relink := LinkSubnetArgs{Mode: mode, Subnet: args.Subnet}
+		if err := i.LinkSubnet(relink); err != nil {
+			return rollback(errors.Annotatef(err, "linking subnet %d", args.Subnet.ID()))
+		}
+		linked = true
+	}
+	return nil
+}
+
+// moveLinkModes returns the modes to link the new subnet with, each once
+// and in the order of the links. Static addresses are on the old subnet so
+// a static link gets a new one. LINK_UP is only kept when it is the only
+// mode, as the other modes bring the interface up anyway.
+func moveLinkModes(states []linkState) []InterfaceLinkMode {
+	var modes []InterfaceLinkMode
+	seen := make(map[InterfaceLinkMode]bool)
+	for _, state := range states {
+		if seen[state.mode] || state.mode == LinkModeLinkUp {
+			continue
+		}
+		seen[state.mode] = true
+		modes = append(modes, state.mode)
+	}
+	if len(modes) == 0 && len(states) > 0 {
+		modes = append(modes, LinkModeLinkUp)
+	}
+	return modes
+}
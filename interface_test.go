@@ -90,6 +90,16 @@ func (s *interfaceSuite) TestReadInterfaceNilMAC(c *gc.C) {
 	c.Assert(result.MACAddress(), gc.Equals, "")
 }
 
+func (s *interfaceSuite) TestReadInterfaceSpeeds(c *gc.C) {
+	json := parseJSON(c, interfaceResponse)
+	json.(map[string]interface{})["link_speed"] = 1000
+	json.(map[string]interface{})["interface_speed"] = 10000
+	result, err := readInterface(twoDotOh, json)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.LinkSpeed(), gc.Equals, 1000)
+	c.Check(result.InterfaceSpeed(), gc.Equals, 10000)
+}
+
 func (*interfaceSuite) TestLowVersion(c *gc.C) {
 	_, err := readInterfaces(version.MustParse("1.9.0"), parseJSON(c, interfacesResponse))
 	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
@@ -203,6 +213,17 @@ func (s *interfaceSuite) TestLinkSubnetArgs(c *gc.C) {
 	}, {
 		args:    LinkSubnetArgs{Mode: LinkModeLinkUp, Subnet: &fakeSubnet{}, DefaultGateway: true},
 		errText: `specifying DefaultGateway for Mode "LINK_UP" not valid`,
+	}, {
+		args:    LinkSubnetArgs{Mode: LinkModeStatic, Subnet: &fakeSubnet{}, IPAddress: "10.10.10"},
+		errText: `IPAddress "10.10.10" not valid`,
+	}, {
+		args: LinkSubnetArgs{Mode: LinkModeStatic, Subnet: &fakeSubnet{cidr: "10.10.0.0/16"}, IPAddress: "10.10.10.10"},
+	}, {
+		args:    LinkSubnetArgs{Mode: LinkModeStatic, Subnet: &fakeSubnet{cidr: "10.20.0.0/16"}, IPAddress: "10.10.10.10"},
+		errText: `IPAddress "10.10.10.10" outside subnet 10.20.0.0/16 not valid`,
+	}, {
+		args:    LinkSubnetArgs{Mode: LinkModeStatic, Subnet: &fakeSubnet{cidr: "2001:db8::/64"}, IPAddress: "10.10.10.10"},
+		errText: `IPAddress "10.10.10.10" outside subnet 2001:db8::/64 not valid`,
 	}} {
 		c.Logf("test %d", i)
 		err := test.args.Validate()
@@ -326,6 +347,30 @@ func (s *interfaceSuite) TestUnlinkSubnetGood(c *gc.C) {
 	c.Assert(form.Get("id"), gc.Equals, "69")
 }
 
+func (s *interfaceSuite) TestUnlink(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, interfaceResponse)
+	err := iface.Unlink(69)
+	c.Check(err, jc.ErrorIsNil)
+	c.Check(server.LastRequest().PostForm.Get("id"), gc.Equals, "69")
+}
+
+func (s *interfaceSuite) TestUnlinkUnknownLink(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	count := server.RequestCount()
+	err := iface.Unlink(70)
+	c.Check(err, jc.Satisfies, errors.IsNotFound)
+	c.Check(err.Error(), gc.Equals, "link 70 of interface eth0 not found")
+	c.Check(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *interfaceSuite) TestUnlinkForbidden(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusForbidden, "bad user")
+	err := iface.Unlink(69)
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
 func (s *interfaceSuite) TestUnlinkSubnetMissing(c *gc.C) {
 	_, iface := s.getServerAndNewInterface(c)
 	err := iface.UnlinkSubnet(&fakeSubnet{id: 1})
@@ -402,6 +447,302 @@ func (s *interfaceSuite) TestUpdateGood(c *gc.C) {
 	c.Assert(form.Get("vlan"), gc.Equals, "13")
 }
 
+func (s *interfaceSuite) TestUpdateBondParameters(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, interfaceResponse)
+	err := iface.Update(UpdateInterfaceArgs{
+		MTU:                9000,
+		BondMode:           BondModeBalanceXOR,
+		BondXmitHashPolicy: "layer3+4",
+		BondMIIMon:         100,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("mtu"), gc.Equals, "9000")
+	c.Check(form.Get("bond_mode"), gc.Equals, "balance-xor")
+	c.Check(form.Get("bond_xmit_hash_policy"), gc.Equals, "layer3+4")
+	c.Check(form.Get("bond_miimon"), gc.Equals, "100")
+	c.Check(form.Get("name"), gc.Equals, "")
+}
+
+func (s *interfaceSuite) TestUpdateInvalidBondMode(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	count := server.RequestCount()
+	err := iface.Update(UpdateInterfaceArgs{BondMode: "round-robin"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, `unknown BondMode value ("round-robin") not valid`)
+	c.Check(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *interfaceSuite) TestUpdateTags(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	response := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"tags": []string{"sriov"},
+	})
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, response)
+	err := iface.Update(UpdateInterfaceArgs{Tags: []string{"sriov"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Tags(), jc.DeepEquals, []string{"sriov"})
+	c.Check(server.LastRequest().PostForm.Get("tags"), gc.Equals, "sriov")
+}
+
+func (s *interfaceSuite) TestUpdateClearTags(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	response := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"tags": []string{},
+	})
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, response)
+	err := iface.Update(UpdateInterfaceArgs{Tags: []string{}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Tags(), gc.HasLen, 0)
+	form := server.LastRequest().PostForm
+	c.Check(form["tags"], jc.DeepEquals, []string{""})
+}
+
+func (s *interfaceSuite) TestAddTag(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	response := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"tags": []string{"foo", "bar", "bond-member"},
+	})
+	server.AddPostResponse(iface.resourceURI+"?op=add_tag", http.StatusOK, response)
+	err := iface.AddTag("bond-member")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Tags(), jc.DeepEquals, []string{"foo", "bar", "bond-member"})
+	c.Check(server.LastRequest().PostForm.Get("tag"), gc.Equals, "bond-member")
+}
+
+func (s *interfaceSuite) TestRemoveTag(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	response := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"tags": []string{"bar"},
+	})
+	server.AddPostResponse(iface.resourceURI+"?op=remove_tag", http.StatusOK, response)
+	err := iface.RemoveTag("foo")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Tags(), jc.DeepEquals, []string{"bar"})
+}
+
+func (s *interfaceSuite) TestAddTagErrors(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	err := iface.AddTag("")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+
+	err = iface.AddTag("sriov")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+
+	server.AddPostResponse(iface.resourceURI+"?op=add_tag", http.StatusForbidden, "bad user")
+	err = iface.AddTag("sriov")
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(err.Error(), gc.Equals, "bad user")
+}
+
+func (s *interfaceSuite) TestMoveToVLANValidates(c *gc.C) {
+	_, iface := s.getServerAndNewInterface(c)
+	err := iface.MoveToVLAN(MoveToVLANArgs{})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, "missing VLAN not valid")
+
+	err = iface.MoveToVLAN(MoveToVLANArgs{VLAN: &fakeVLAN{id: 5}})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, "missing Subnet not valid")
+}
+
+func (s *interfaceSuite) TestMoveToVLANGood(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	unlinked := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"links": []interface{}{},
+	})
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, unlinked)
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, unlinked)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, interfaceResponse)
+	server.ResetRequests()
+
+	err := iface.MoveToVLAN(MoveToVLANArgs{
+		VLAN:   &fakeVLAN{id: 5},
+		Subnet: &fakeSubnet{id: 42},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(server.RequestCount(), gc.Equals, 3)
+	requests := server.LastNRequests(3)
+	c.Check(requests[0].PostForm.Get("id"), gc.Equals, "69")
+	c.Check(requests[1].Method, gc.Equals, "PUT")
+	c.Check(requests[1].PostForm.Get("vlan"), gc.Equals, "5")
+	c.Check(requests[2].PostForm.Get("mode"), gc.Equals, "AUTO")
+	c.Check(requests[2].PostForm.Get("subnet"), gc.Equals, "42")
+}
+
+func (s *interfaceSuite) TestMoveToVLANRelinksEachModeOnce(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	oldSubnet := &subnet{id: 1}
+	iface.links = []*link{
+		{id: 70, mode: "static", subnet: oldSubnet, ipAddress: "192.168.100.10"},
+		{id: 71, mode: "static", subnet: oldSubnet, ipAddress: "192.168.100.11"},
+		{id: 72, mode: "link_up", subnet: oldSubnet},
+		{id: 73, mode: "dhcp", subnet: oldSubnet},
+		{id: 74, mode: "link_up"},
+	}
+	unlinked := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"links": []interface{}{},
+	})
+	for i := 0; i < 4; i++ {
+		server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, unlinked)
+	}
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, unlinked)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, unlinked)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, unlinked)
+	server.ResetRequests()
+
+	err := iface.MoveToVLAN(MoveToVLANArgs{
+		VLAN:   &fakeVLAN{id: 5},
+		Subnet: &fakeSubnet{id: 42},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(server.RequestCount(), gc.Equals, 7)
+	requests := server.LastNRequests(7)
+	for i, id := range []string{"70", "71", "72", "73"} {
+		c.Check(requests[i].PostForm.Get("id"), gc.Equals, id)
+	}
+	c.Check(requests[4].PostForm.Get("vlan"), gc.Equals, "5")
+	c.Check(requests[5].PostForm.Get("mode"), gc.Equals, "STATIC")
+	c.Check(requests[5].PostForm.Get("ip_address"), gc.Equals, "")
+	c.Check(requests[6].PostForm.Get("mode"), gc.Equals, "DHCP")
+}
+
+func (s *interfaceSuite) TestMoveToVLANLinkUp(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	iface.links = []*link{
+		{id: 70, mode: "link_up", subnet: &subnet{id: 1}},
+		{id: 71, mode: "link_up", subnet: &subnet{id: 2}},
+	}
+	unlinked := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"links": []interface{}{},
+	})
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, unlinked)
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, unlinked)
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, unlinked)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, unlinked)
+	server.ResetRequests()
+
+	err := iface.MoveToVLAN(MoveToVLANArgs{
+		VLAN:   &fakeVLAN{id: 5},
+		Subnet: &fakeSubnet{id: 42},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(server.RequestCount(), gc.Equals, 4)
+	request := server.LastRequest()
+	c.Check(request.PostForm.Get("mode"), gc.Equals, "LINK_UP")
+	c.Check(request.PostForm.Get("subnet"), gc.Equals, "42")
+}
+
+func (s *interfaceSuite) TestMoveToVLANUpWithoutSubnet(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	iface.links = []*link{{id: 70, mode: "link_up"}}
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, interfaceResponse)
+	server.ResetRequests()
+
+	err := iface.MoveToVLAN(MoveToVLANArgs{VLAN: &fakeVLAN{id: 5}})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(server.RequestCount(), gc.Equals, 1)
+	c.Check(server.LastRequest().Method, gc.Equals, "PUT")
+}
+
+func (s *interfaceSuite) TestMoveToVLANRollbackUnlinksNewLinks(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	iface.links = []*link{
+		{id: 70, mode: "auto", subnet: &subnet{id: 1}},
+		{id: 71, mode: "dhcp", subnet: &subnet{id: 1}},
+	}
+	unlinked := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"links": []interface{}{},
+	})
+	relinked := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"links": []interface{}{map[string]interface{}{
+			"id": 80, "mode": "auto",
+			"subnet": map[string]interface{}{
+				"id": 42, "name": "new", "space": "space-0",
+				"vlan": map[string]interface{}{
+					"id": 5, "name": "new", "fabric": "fabric-0", "vid": 5, "mtu": 1500, "dhcp_on": false,
+					"primary_rack": nil, "secondary_rack": nil, "resource_uri": "/MAAS/api/2.0/vlans/5/",
+				},
+				"gateway_ip": "10.0.0.1", "cidr": "10.0.0.0/24", "dns_servers": []interface{}{},
+				"resource_uri": "/MAAS/api/2.0/subnets/42/",
+			},
+		}},
+	})
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, unlinked)
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, unlinked)
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, unlinked)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, relinked)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusServiceUnavailable, "no addresses")
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, unlinked)
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, unlinked)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, unlinked)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, unlinked)
+	server.ResetRequests()
+
+	err := iface.MoveToVLAN(MoveToVLANArgs{
+		VLAN:   &fakeVLAN{id: 5},
+		Subnet: &fakeSubnet{id: 42},
+	})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+
+	c.Assert(server.RequestCount(), gc.Equals, 9)
+	requests := server.LastNRequests(4)
+	c.Check(requests[0].PostForm.Get("id"), gc.Equals, "80")
+	c.Check(requests[1].PostForm.Get("vlan"), gc.Equals, "1")
+	c.Check(requests[2].PostForm.Get("mode"), gc.Equals, "AUTO")
+	c.Check(requests[3].PostForm.Get("mode"), gc.Equals, "DHCP")
+}
+
+func (s *interfaceSuite) TestMoveToVLANRollsBack(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	unlinked := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"links": []interface{}{},
+	})
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, unlinked)
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, unlinked)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusServiceUnavailable, "no addresses")
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, unlinked)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, interfaceResponse)
+	server.ResetRequests()
+
+	err := iface.MoveToVLAN(MoveToVLANArgs{
+		VLAN:   &fakeVLAN{id: 5},
+		Subnet: &fakeSubnet{id: 42},
+	})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err.Error(), gc.Equals, "linking subnet 42: no addresses")
+
+	c.Assert(server.RequestCount(), gc.Equals, 5)
+	requests := server.LastNRequests(2)
+	c.Check(requests[0].PostForm.Get("vlan"), gc.Equals, "1")
+	c.Check(requests[1].PostForm.Get("mode"), gc.Equals, "AUTO")
+	c.Check(requests[1].PostForm.Get("subnet"), gc.Equals, "1")
+	c.Check(iface.Links(), gc.HasLen, 1)
+}
+
+func (s *interfaceSuite) TestMoveToVLANRollbackFails(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	unlinked := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"links": []interface{}{},
+	})
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, unlinked)
+	server.AddPutResponse(iface.resourceURI, http.StatusForbidden, "bad user")
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusForbidden, "still bad")
+
+	err := iface.MoveToVLAN(MoveToVLANArgs{
+		VLAN:   &fakeVLAN{id: 5},
+		Subnet: &fakeSubnet{id: 42},
+	})
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(err.Error(), gc.Equals, "rollback failed (still bad): setting VLAN 5: bad user")
+}
+
 const (
 	interfacesResponse = "[" + interfaceResponse + "]"
 	interfaceResponse  = `
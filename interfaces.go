@@ -3,7 +3,14 @@
 
 package gomaasapi
 
-import "github.com/juju/collections/set"
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/juju/collections/set"
+	"github.com/juju/version"
+)
 
 const (
 	// Capability constants.
@@ -20,44 +27,262 @@ const (
 // HTTP calls are made and JSON response structures parsed.
 type Controller interface {
 
+	// WithContext returns a Controller that shares the state of this one,
+	// but whose requests are made with the context, so that they are
+	// abandoned when it is cancelled or its deadline passes. The error
+	// then satisfies IsContextError. The machines and other objects read
+	// through the returned Controller also use the context, so read them
+	// again to act on them after it is done.
+	WithContext(ctx context.Context) Controller
+
+	// UpdateAPIKey replaces the API key used to sign the requests of the
+	// Controller, and of those bound from it with WithContext. Requests
+	// already made are not affected. If checkCreds is true the new key is
+	// first checked with the server, and the old key is kept if the check
+	// fails; a rejected key gives an error satisfying IsPermissionError.
+	// A key that cannot be parsed gives an error satisfying
+	// errors.IsNotValid.
+	UpdateAPIKey(apiKey string, checkCreds bool) error
+
+	// ServerVersion returns the version of MAAS that the server reported
+	// when the Controller was created, or version.Zero if it reported one
+	// that could not be read.
+	ServerVersion() version.Number
+
 	// Capabilities returns a set of capabilities as defined by the string
 	// constants.
 	Capabilities() set.Strings
 
+	// RefreshCapabilities reads the capabilities and version from the
+	// server again, such as after the server has been upgraded, and
+	// returns the capabilities. If they have changed,
+	// ControllerArgs.CapabilitiesChanged is called. Responses are read as
+	// the new version from then on unless ControllerArgs.SchemaVersion
+	// pins it. The API version is not renegotiated, so if the server has
+	// dropped it the error satisfies IsUnsupportedVersionError.
+	RefreshCapabilities() (set.Strings, error)
+
+	// RateLimit returns the request budget from the latest response that
+	// carried rate limit headers, and false if none has. The budget can
+	// change at any time as other clients use the server.
+	RateLimit() (RateLimit, bool)
+
+	// CheckAPIKeyPermissions makes a few cheap requests, none of which
+	// change anything, to find out what the API key is allowed to do. Use
+	// APIKeyPermissions.Require to fail fast before starting work that
+	// needs more.
+	CheckAPIKeyPermissions() (APIKeyPermissions, error)
+
+	// ReplayJournal sends the requests recorded in the Journal of the
+	// ControllerArgs while the server was unreachable. See Journal.Replay.
+	ReplayJournal(confirm func(JournalEntry) ReplayDecision) (int, error)
+
+	// ClockSkew returns how far the server's clock is ahead of the local
+	// clock, to the second, using the Date header of a response. The
+	// server rejects requests when the skew is more than a few minutes.
+	ClockSkew() (time.Duration, error)
+
 	BootResources() ([]BootResource, error)
 
+	// UploadBootResource uploads a custom boot image in chunks. If the
+	// connection drops part way, the upload resumes from where the server
+	// got to rather than starting again, and an upload of the same content
+	// that was abandoned earlier is resumed too.
+	UploadBootResource(UploadBootResourceArgs) (BootResource, error)
+
+	// CheckImageSync compares the boot images of every rack controller with
+	// the images selected by the boot source selections of the region,
+	// reporting the racks that are missing images or could not be reached.
+	// An error listing the images of a rack is recorded for that rack
+	// rather than returned.
+	CheckImageSync() (ImageSyncReport, error)
+
 	// Fabrics returns the list of Fabrics defined in the MAAS controller.
 	Fabrics() ([]Fabric, error)
 
 	// Spaces returns the list of Spaces defined in the MAAS controller.
 	Spaces() ([]Space, error)
 
+	// Subnets returns all the subnets known to the MAAS controller.
+	Subnets() ([]Subnet, error)
+
+	// CreateSubnet adds a subnet. A CIDR that overlaps an existing subnet
+	// gives an error satisfying IsBadRequestError.
+	CreateSubnet(CreateSubnetArgs) (Subnet, error)
+
+	// UpdateSubnet changes the subnet with the ID, and returns it as it
+	// now is. Subnets that do not exist give an error satisfying
+	// IsNoMatchError.
+	UpdateSubnet(id int, args UpdateSubnetArgs) (Subnet, error)
+
+	// DeleteSubnet removes the subnet with the ID.
+	DeleteSubnet(id int) error
+
+	// IPAddresses returns the addresses reserved with ReserveIPAddress,
+	// optionally only those in one subnet.
+	IPAddresses(IPAddressesArgs) ([]IPAddress, error)
+
+	// ReserveIPAddress reserves a static address, so that MAAS does not
+	// allocate it to a machine or device. An address that is in use gives
+	// an error satisfying IsBadRequestError, and a subnet with no free
+	// addresses one satisfying IsCannotCompleteError.
+	ReserveIPAddress(ReserveIPAddressArgs) (IPAddress, error)
+
+	// ReleaseIPAddress releases an address reserved with ReserveIPAddress.
+	// An address that is not reserved by the user gives an error
+	// satisfying IsNoMatchError.
+	ReleaseIPAddress(ip string) error
+
+	// CheckCompatibility reads the objects of the server, as this package
+	// does, and reports the fields of the responses that are missing or
+	// that are not read. The objects are read as the schema version of
+	// the controller, which ControllerArgs.SchemaVersion can pin, so a
+	// version can be checked before it is used.
+	CheckCompatibility() (CompatibilityReport, error)
+
+	// PowerDrivers describes the power drivers of the server and their
+	// parameters.
+	PowerDrivers() ([]PowerDriver, error)
+
+	// Stats summarizes the machines and the address usage of the subnets
+	// of the controller. It lists the machines and subnets, and then reads
+	// the statistics of each subnet with a request of its own, so it makes
+	// two more requests than there are subnets.
+	Stats() (ControllerStats, error)
+
 	// StaticRoutes returns the list of StaticRoutes defined in the MAAS controller.
 	StaticRoutes() ([]StaticRoute, error)
 
+	// CreateStaticRoute adds a route. A subnet that does not exist, a
+	// gateway outside the source subnet, or a route that is already
+	// defined gives an error satisfying IsBadRequestError.
+	CreateStaticRoute(CreateStaticRouteArgs) (StaticRoute, error)
+
+	// DeleteStaticRoute removes the route with the ID.
+	DeleteStaticRoute(id int) error
+
 	// Zones lists all the zones known to the MAAS controller.
 	Zones() ([]Zone, error)
 
+	// SSHKeys returns the SSH public keys of the user, which are
+	// installed on the machines that the user deploys.
+	SSHKeys() ([]SSHKey, error)
+
+	// AddSSHKey adds an SSH public key, in the format of an
+	// authorized_keys line, for the user. A key that cannot be parsed,
+	// or that the user already has, gives an error satisfying
+	// IsBadRequestError.
+	AddSSHKey(key string) (SSHKey, error)
+
+	// DeleteSSHKey removes the SSH key with the ID.
+	DeleteSSHKey(id int) error
+
+	// SSLKeys returns the SSL certificates of the user.
+	SSLKeys() ([]SSLKey, error)
+
+	// AddSSLKey adds a PEM encoded SSL certificate for the user. One
+	// that cannot be parsed gives an error satisfying IsBadRequestError.
+	AddSSLKey(key string) (SSLKey, error)
+
+	// DeleteSSLKey removes the SSL key with the ID.
+	DeleteSSLKey(id int) error
+
+	// Users lists the users of MAAS.
+	Users() ([]User, error)
+
+	// CreateUser creates a user. Only administrators can create users;
+	// for others the error satisfies IsPermissionError. A username that
+	// is taken gives an error satisfying IsBadRequestError.
+	CreateUser(CreateUserArgs) (User, error)
+
+	// WhoAmI returns the user of the API key.
+	WhoAmI() (User, error)
+
+	// Tags lists all the tags known to the MAAS controller.
+	Tags() ([]Tag, error)
+
+	// CreateTag adds a tag. Tags with a definition, an XPath expression
+	// over the hardware details of machines, are applied by the server to
+	// the machines that match it; the others are applied with
+	// Machine.AddTag. Names that are in use give an error satisfying
+	// IsBadRequestError.
+	CreateTag(name, comment, definition string) (Tag, error)
+
 	// Pools lists all the pools known to the MAAS controller.
 	Pools() ([]Pool, error)
 
-	// Machines returns a list of machines that match the params.
-	Machines(MachinesArgs) ([]Machine, error)
+	// CreatePool adds a resource pool. It fails with an error satisfying
+	// errors.IsNotSupported if the server has no resource pools, and
+	// with one satisfying IsBadRequestError if the name is taken.
+	CreatePool(name, description string) (Pool, error)
+
+	// MoveMachinesToPool moves the machines into the resource pool, a
+	// batch at a time. It fails with an error satisfying
+	// errors.IsNotSupported if the server has no resource pools, and with
+	// a NoMatchError if the pool does not exist. If any machine could not
+	// be moved, the error is a *BulkError with a result for each machine.
+	MoveMachinesToPool(MoveMachinesToPoolArgs) error
+
+	// Pods returns the pods, the KVM and LXD VM hosts that machines can be
+	// composed on. Servers without pods give an error satisfying
+	// errors.IsNotSupported.
+	Pods() ([]Pod, error)
+
+	// Pod returns the pod with the ID. If there is no such pod the error
+	// satisfies IsNoMatchError.
+	Pod(id int) (Pod, error)
+
+	// CreatePod adds a VM host to MAAS, which discovers its resources
+	// before returning. Hosts that MAAS cannot reach give an error
+	// satisfying IsBadRequestError.
+	CreatePod(CreatePodArgs) (Pod, error)
+
+	// Machines returns a list of machines that match the params. The
+	// ReadOptions control how the response is deserialized.
+	Machines(MachinesArgs, ...ReadOption) ([]Machine, error)
 
 	// AllocateMachine will attempt to allocate a machine to the user.
 	// If successful, the allocated machine is returned.
 	AllocateMachine(AllocateMachineArgs) (Machine, ConstraintMatches, error)
 
 	// ReleaseMachines will stop the specified machines, and release them
-	// from the user making them available to be allocated again.
+	// from the user making them available to be allocated again. Machine
+	// objects read before the release become stale, and their mutating
+	// methods return an error satisfying IsStaleObjectError. If the
+	// server rejects some of the machines, the others are given by
+	// NotAttempted of the *BulkError.
 	ReleaseMachines(ReleaseMachinesArgs) error
 
-	// Devices returns a list of devices that match the params.
-	Devices(DevicesArgs) ([]Device, error)
+	// AcquireMachine allocates the machine with the system ID, whatever
+	// its other attributes. If the machine is allocated to a user the
+	// error satisfies IsAlreadyOwnedError, if it is not ready to be
+	// allocated IsCannotCompleteError, and if there is no such machine
+	// IsNoMatchError.
+	AcquireMachine(systemID, comment string) (Machine, error)
+
+	// Devices returns a list of devices that match the params. The
+	// ReadOptions control how the response is deserialized.
+	Devices(DevicesArgs, ...ReadOption) ([]Device, error)
+
+	// VisitDevices calls visit for each device that matches the params as
+	// it is decoded, rather than returning them all at once. Iteration
+	// stops at the first error returned by visit, and that error is
+	// returned.
+	VisitDevices(DevicesArgs, func(Device) error, ...ReadOption) error
 
 	// CreateDevice creates and returns a new Device.
 	CreateDevice(CreateDeviceArgs) (Device, error)
 
+	// EnableDHCP turns on DHCP for the VLAN of a subnet, first creating
+	// the dynamic range in the args if the subnet has none, and returns the
+	// updated VLAN. Inconsistent args give an error satisfying
+	// errors.IsNotValid before anything is changed.
+	EnableDHCP(EnableDHCPArgs) (VLAN, error)
+
+	// DisableDHCP turns off DHCP for the VLAN and returns it updated. The
+	// dynamic ranges and racks are left alone.
+	DisableDHCP(VLAN) (VLAN, error)
+
 	// Files returns all the files that match the specified prefix.
 	Files(prefix string) ([]File, error)
 
@@ -72,6 +297,51 @@ type Controller interface {
 
 	// Returns the DNS Domain Managed By MAAS
 	Domains() ([]Domain, error)
+
+	// CreateDomain adds a DNS domain. A name that is already in use gives
+	// an error satisfying IsBadRequestError.
+	CreateDomain(CreateDomainArgs) (Domain, error)
+
+	// DNSResources returns the DNS resources, the names with address
+	// records, that match the args.
+	DNSResources(DNSResourcesArgs) ([]DNSResource, error)
+
+	// CreateDNSResource adds a name with A or AAAA records for the
+	// addresses of the args. A domain that does not exist gives an error
+	// satisfying IsBadRequestError.
+	CreateDNSResource(CreateDNSResourceArgs) (DNSResource, error)
+
+	// DNSResourceRecords returns the other records, such as CNAME and
+	// TXT records, that match the args.
+	DNSResourceRecords(DNSResourcesArgs) ([]DNSResourceRecord, error)
+
+	// CreateDNSResourceRecord adds a record, such as a CNAME or TXT
+	// record. A domain that does not exist, or data that is not valid
+	// for the type, gives an error satisfying IsBadRequestError.
+	CreateDNSResourceRecord(CreateDNSResourceRecordArgs) (DNSResourceRecord, error)
+
+	// BootSources returns the image streams that the controller imports
+	// boot images from. Only admins can read them, and servers without
+	// the API give an error satisfying errors.IsNotSupported.
+	BootSources() ([]BootSource, error)
+
+	// CreateBootSource adds an image stream, such as ImageStreamCandidate
+	// or a mirror, to import boot images from. Servers before 3.5 give an
+	// error satisfying errors.IsNotSupported.
+	CreateBootSource(CreateBootSourceArgs) (BootSource, error)
+
+	// RackControllers returns the rack controllers, including the nodes
+	// that are both rack and region controllers.
+	RackControllers() ([]ControllerNode, error)
+
+	// RegionControllers returns the region controllers, including the
+	// nodes that are both rack and region controllers.
+	RegionControllers() ([]ControllerNode, error)
+
+	// Nodes returns every kind of node known to the controller in a single
+	// request. Each element is a Machine, a Device or a ControllerNode
+	// depending on its NodeType.
+	Nodes(NodesArgs, ...ReadOption) ([]GenericNode, error)
 }
 
 // File represents a file stored in the MAAS controller.
@@ -88,6 +358,24 @@ type File interface {
 
 	// ReadAll returns the content of the file.
 	ReadAll() ([]byte, error)
+
+	// OpenReader returns a reader of the content of the file from the
+	// offset on, which streams it from the AnonymousURL so that large
+	// files need not be held in memory. A download that fails part way
+	// can be carried on with a reader opened at the offset reached. The
+	// caller must close the reader.
+	OpenReader(offset int64) (io.ReadCloser, error)
+
+	// Size returns the size of the content in bytes, and SHA256 its hex
+	// encoded hash. If the server did not report them, the content is
+	// fetched to work them out.
+	Size() (int64, error)
+	SHA256() (string, error)
+
+	// Created and Updated are when the file was stored and last replaced,
+	// in UTC. They are the zero time if the server does not report them.
+	Created() time.Time
+	Updated() time.Time
 }
 
 // Fabric represents a set of interconnected VLANs that are capable of mutual
@@ -139,25 +427,228 @@ type VLAN interface {
 	SecondaryRack() string
 }
 
+// Tag is a label for machines, which can be used to select them when
+// allocating.
+type Tag interface {
+	Name() string
+	Comment() string
+	// Definition is empty for tags applied by hand.
+	Definition() string
+	// KernelOpts are added to the kernel command line of machines with the
+	// tag.
+	KernelOpts() string
+
+	// Machines returns the machines with the tag.
+	Machines() ([]Machine, error)
+	// UpdateMachines adds the tag to some machines and removes it from
+	// others in one request. The server changes all of them or none, so
+	// on failure the error is a *BulkError in which every machine has the
+	// error. Tags with a definition cannot be changed by hand.
+	UpdateMachines(UpdateTagMachinesArgs) error
+	// Delete removes the tag from MAAS, and from its machines.
+	Delete() error
+}
+
 // Zone represents a physical zone that a Machine is in. The meaning of a
 // physical zone is up to you: it could identify e.g. a server rack, a network,
 // or a data centre. Users can then allocate nodes from specific physical zones,
 // to suit their redundancy or performance requirements.
 type Zone interface {
+	// ID is zero if the server does not report zone IDs.
+	ID() int
 	Name() string
 	Description() string
 }
 
+// VirtualMachine identifies a composed machine on its pod.
+type VirtualMachine interface {
+	// ID is the ID of the virtual machine on the pod, or 0 if the server
+	// does not report it.
+	ID() int
+	PodID() int
+	PodName() string
+	// Pod reads the pod. It gives an error satisfying IsNoMatchError if
+	// the pod has been deleted.
+	Pod() (Pod, error)
+}
+
+// Pod is a VM host that machines can be composed on.
+type Pod interface {
+	ID() int
+	Name() string
+	// Type is the power driver of the pod, such as "virsh" or "lxd".
+	Type() string
+	Architectures() []string
+	Capabilities() []string
+	Tags() []string
+	Zone() Zone
+	Pool() Pool
+
+	// HostSystemID is the system ID of the machine that the pod runs on,
+	// or empty if MAAS did not deploy it or the server does not report
+	// it.
+	HostSystemID() string
+
+	// NUMANodes reads the NUMA nodes of the host machine, to choose the
+	// PinnedCores of a machine to compose. Pods without a host machine
+	// give an error satisfying errors.IsNotFound.
+	NUMANodes() ([]NUMANode, error)
+
+	// Total is the resources of the host. Used is what the composed
+	// machines take, and Available what is left, allowing for the
+	// over-commit ratios.
+	Total() PodResources
+	Used() PodResources
+	Available() PodResources
+
+	// The over-commit ratios scale the cores and memory that can be
+	// composed on the host.
+	CPUOverCommitRatio() float64
+	MemoryOverCommitRatio() float64
+
+	// DefaultStoragePool is the storage pool used when composing a
+	// machine names none, or nil if the server does not report one.
+	DefaultStoragePool() PodStoragePool
+	StoragePools() []PodStoragePool
+
+	// Update changes the settings of the pod, such as its over-commit
+	// ratios or default storage pool.
+	Update(UpdatePodArgs) error
+
+	// Compose creates a virtual machine on the pod, and returns it as a
+	// machine that is Ready to be allocated. Pods without the resources
+	// for the machine give an error satisfying IsBadRequestError.
+	Compose(ComposeMachineArgs) (Machine, error)
+}
+
+// PodStoragePool is a storage pool of a pod, where the disks of composed
+// machines are created. The sizes are in bytes.
+type PodStoragePool interface {
+	ID() string
+	Name() string
+	// Type is the kind of pool, such as "dir" or "lvm".
+	Type() string
+	Path() string
+	Total() uint64
+	Used() uint64
+	Available() uint64
+	Default() bool
+}
+
 // Pool is just a logical separation of resources.
 type Pool interface {
+	// ID is zero if the server does not report pool IDs.
+	ID() int
 	// The name of the resource pool
 	Name() string
 	Description() string
+
+	// Delete removes the pool. The default pool cannot be deleted; the
+	// error satisfies IsBadRequestError.
+	Delete() error
 }
 
 type Domain interface {
+	ID() int
 	// The name of the Domain
 	Name() string
+	Authoritative() bool
+	// TTL returns the default TTL for records in the domain. If the domain
+	// uses the global default, ok is false.
+	TTL() (ttl int, ok bool)
+	ResourceRecordCount() int
+	// ForwardDNSServers is empty unless the server supports forwarding
+	// and servers have been set for the domain.
+	ForwardDNSServers() []string
+
+	// Update the name, authoritative flag, default TTL or forward DNS
+	// servers of the domain.
+	Update(UpdateDomainArgs) error
+
+	// IsDefault is true for the domain that machines and devices are put
+	// in when no other domain is given. It is false if the server does
+	// not report it.
+	IsDefault() bool
+
+	// SetDefault makes this the default domain. Other Domain values that
+	// were read earlier still report the old default.
+	SetDefault() error
+
+	// Delete removes the domain. The server refuses to delete the default
+	// domain, or one with records, with an error satisfying
+	// IsBadRequestError.
+	Delete() error
+}
+
+// DNSResource is a name in a domain managed by MAAS, with the address
+// records and other records for the name.
+type DNSResource interface {
+	ID() int
+	FQDN() string
+	// AddressTTL returns the TTL of the address records. If they use the
+	// default of the domain, ok is false.
+	AddressTTL() (ttl int, ok bool)
+	IPAddresses() []string
+	// ResourceRecords are the records of the name other than its
+	// addresses.
+	ResourceRecords() []DNSResourceRecord
+
+	// Delete removes the name and all its records.
+	Delete() error
+}
+
+// DNSResourceRecord is a record, such as a CNAME or TXT record, in a domain
+// managed by MAAS.
+type DNSResourceRecord interface {
+	ID() int
+	FQDN() string
+	RRType() string
+	RRData() string
+	// TTL returns the TTL of the record. If it uses the default of the
+	// domain, ok is false.
+	TTL() (ttl int, ok bool)
+
+	// Delete removes the record.
+	Delete() error
+}
+
+// BootSource is an image stream that the controller imports boot images
+// from. The selections of the source say which images are imported.
+type BootSource interface {
+	ID() int
+	URL() string
+	KeyringFilename() string
+
+	// Selections returns the images imported from the source.
+	Selections() ([]BootSourceSelection, error)
+
+	// CreateSelection adds images of an OS release to import, for the
+	// architectures of the args. Selecting architectures, subarchitectures
+	// or labels on servers before 3.5 gives an error satisfying
+	// errors.IsNotSupported.
+	CreateSelection(BootSourceSelectionArgs) (BootSourceSelection, error)
+
+	// Delete removes the source. The images imported from it are removed
+	// at the next import.
+	Delete() error
+}
+
+// BootSourceSelection selects images of one OS release from a boot source.
+type BootSourceSelection interface {
+	ID() int
+	OS() string
+	Release() string
+	Arches() []string
+	Subarches() []string
+	Labels() []string
+
+	// Update changes the fields of the selection that are set in the
+	// args. Arches, Subarches and Labels replace the current lists, and
+	// need a 3.5 server like they do for BootSource.CreateSelection.
+	Update(BootSourceSelectionArgs) error
+
+	// Delete removes the selection.
+	Delete() error
 }
 
 // BootResource is the bomb... find something to say here.
@@ -170,15 +661,107 @@ type BootResource interface {
 	KernelFlavor() string
 }
 
+// GenericNode holds the values common to all the kinds of node returned by
+// Controller.Nodes. A type switch on the Machine, Device and ControllerNode
+// interfaces gives access to the rest.
+type GenericNode interface {
+	SystemID() string
+	Hostname() string
+	FQDN() string
+	NodeType() NodeType
+	IPAddresses() []string
+}
+
+// ControllerNode represents a rack controller, a region controller or a
+// node running both. NodeType tells them apart.
+type ControllerNode interface {
+	GenericNode
+
+	// Version is the MAAS version running on the controller, or empty if
+	// it has not reported one.
+	Version() string
+
+	// InterfaceSet returns the network interfaces of the controller. It
+	// is empty for the nodes returned by Controller.Nodes.
+	InterfaceSet() []Interface
+
+	// Services returns the status of the MAAS services on the controller,
+	// such as rackd, regiond and dhcpd. It is empty for the nodes returned
+	// by Controller.Nodes.
+	Services() []ControllerService
+
+	// Service returns the status of the service with the name, and false
+	// if the controller does not report it.
+	Service(name string) (ControllerService, bool)
+}
+
+// NodeDevice represents a PCI or USB device found on a machine during
+// commissioning.
+type NodeDevice interface {
+	ID() int
+	Bus() DeviceBus
+	HardwareType() HardwareType
+
+	// VendorID and ProductID are the lower case hex identifiers, such as
+	// "10de" for NVIDIA.
+	VendorID() string
+	ProductID() string
+	VendorName() string
+	ProductName() string
+
+	// CommissioningDriver is the kernel driver in use during commissioning.
+	CommissioningDriver() string
+	// PCIAddress is empty for USB devices.
+	PCIAddress() string
+	BusNumber() int
+	DeviceNumber() int
+}
+
+// ScriptSet is the results of one run of a machine's scripts of a type,
+// such as its commissioning or release.
+type ScriptSet interface {
+	ID() int
+	// Type is the ScriptResultType of the scripts.
+	Type() string
+	// Status is a status name, such as "Passed" or "Failed".
+	Status() string
+	// Started and Ended are zero until the scripts start and end.
+	Started() time.Time
+	Ended() time.Time
+	Results() []ScriptResult
+}
+
+// ScriptResult is the result of running one script.
+type ScriptResult interface {
+	ID() int
+	Name() string
+	// Status is a status name, such as "Running", "Passed" or "Failed".
+	Status() string
+	// ExitStatus is -1 until the script ends.
+	ExitStatus() int
+	Started() time.Time
+	Ended() time.Time
+	// Output is the combined output of the script, only read when
+	// ScriptResultsArgs.IncludeOutput is set.
+	Output() []byte
+	// BlockDeviceID and BlockDeviceName identify the storage device
+	// that the script was run against, for scripts that take one such
+	// as smartctl-validate. The ID is zero for other scripts.
+	BlockDeviceID() int
+	BlockDeviceName() string
+}
+
 // Device represents some form of device in MAAS.
 type Device interface {
-	// TODO: add domain
 	SystemID() string
 	Hostname() string
 	FQDN() string
+	NodeType() NodeType
 	IPAddresses() []string
 	Zone() Zone
 	Pool() Pool
+	// Domain returns nil if the server did not include the domain.
+	Domain() Domain
 
 	// Parent returns the SystemID of the Parent. Most often this will be a
 	// Machine.
@@ -193,7 +776,16 @@ type Device interface {
 	// CreateInterface will create a physical interface for this machine.
 	CreateInterface(CreateInterfaceArgs) (Interface, error)
 
-	// Delete will remove this Device.
+	// AllocateStickyIP gives the interface of the device with the MAC
+	// address of the args a static address in the subnet, creating the
+	// interface on the VLAN of the subnet if the device has none with the
+	// MAC address, as for a container on the parent machine. An
+	// interface created for the address is deleted again if the address
+	// cannot be allocated. It returns the interface with the new link.
+	AllocateStickyIP(AllocateStickyIPArgs) (Interface, error)
+
+	// Delete will remove this Device. Afterwards the mutating methods of
+	// the Device return an error satisfying IsStaleObjectError.
 	Delete() error
 }
 
@@ -204,21 +796,162 @@ type Machine interface {
 	SystemID() string
 	Hostname() string
 	FQDN() string
+	// NodeType is normally NodeTypeMachine, but controllers that are also
+	// machines are included in machine listings.
+	NodeType() NodeType
 	Tags() []string
 
+	// AddTag and RemoveTag change a single tag of the machine. Tags with a
+	// definition cannot be changed this way, and give an error satisfying
+	// IsBadRequestError.
+	AddTag(name string) error
+	RemoveTag(name string) error
+
 	OperatingSystem() string
 	DistroSeries() string
+	// HWEKernel is the kernel that the machine was deployed with. It is
+	// empty if the machine is not deployed or the default kernel was used.
+	HWEKernel() string
+	// DeployedOS groups the operating system, series and kernel that
+	// were actually deployed on the machine.
+	DeployedOS() DeployedOS
 	Architecture() string
 	Memory() int
 	CPUCount() int
 
 	IPAddresses() []string
 	PowerState() string
+	// PowerType is the name of the power driver, e.g. "ipmi".
+	PowerType() string
+
+	// PowerParameters returns the parameters of the power driver. Only
+	// admins are permitted to read them.
+	PowerParameters() (map[string]string, error)
+
+	// BMCAddress returns the host, and the port if one is configured, of
+	// the machine's baseboard management controller, extracted from the
+	// power parameters. Power types that don't use a BMC return an error
+	// satisfying errors.IsNotSupported.
+	BMCAddress() (string, error)
+
+	// PowerOn turns on a machine that the user has allocated or deployed.
+	// A machine in a state that cannot be powered on gives an error
+	// satisfying IsCannotCompleteError.
+	PowerOn(PowerOnArgs) error
+
+	// PowerOff turns off a machine, as PowerOn turns it on.
+	PowerOff(PowerOffArgs) error
+
+	// QueryPowerState asks the BMC of the machine for its power state,
+	// such as "on" or "off", rather than reporting the last state known
+	// to the server as PowerState does. PowerState is updated with it.
+	// A BMC that cannot be reached gives an error satisfying
+	// IsCannotCompleteError.
+	QueryPowerState() (string, error)
+
+	// EnterRescueMode boots the machine into an ephemeral environment
+	// that can be reached over SSH to repair it, without changing its
+	// disks. Machines that are New, Ready, Allocated, Deployed, or in a
+	// failed status can be rescued; others give an error satisfying
+	// IsCannotCompleteError without asking the server.
+	EnterRescueMode() error
+
+	// ExitRescueMode returns a machine in rescue mode to the status it
+	// had before. A machine that is not in rescue mode, or entering or
+	// exiting it, gives an error satisfying IsCannotCompleteError.
+	ExitRescueMode() error
+
+	// ConvertPowerType changes the power driver of the machine, keeping
+	// the address and credentials of the current driver. The parameters
+	// are checked against the server's description of the new driver, as
+	// PowerDriver.Convert does, before they are saved.
+	ConvertPowerType(ConvertPowerTypeArgs) error
+
+	// Netboot is true if the machine will PXE boot from MAAS on its next
+	// power on.
+	Netboot() bool
+
+	// SetNetboot turns netboot on or off. Servers without support for it
+	// return an error satisfying errors.IsNotSupported.
+	SetNetboot(enabled bool) error
+
+	// EphemeralDeploy is true if the machine was deployed to run in
+	// memory, without installing to disk, as requested with
+	// StartArgs.EphemeralDeploy.
+	EphemeralDeploy() bool
+
+	// Locked is true if the machine is locked against changes, such as
+	// being released or redeployed, until it is unlocked.
+	Locked() bool
+
+	// Lock protects a deployed machine from changes. The comment is
+	// recorded in the machine's event log. A machine that is not
+	// deployed, or is already locked, gives an error satisfying
+	// IsCannotCompleteError. Servers before MAAS 2.5 give an error
+	// satisfying errors.IsNotSupported.
+	Lock(comment string) error
+
+	// Unlock lets a locked machine be changed again.
+	Unlock(comment string) error
+
+	// MetadataClient returns a client for the metadata service that uses
+	// the machine's own credentials, which only an admin can fetch.
+	MetadataClient() (*MetadataClient, error)
+
+	// MetadataClientArgs returns the server and the machine's own
+	// credentials in the form that NewMetadataClient takes, so that an
+	// agent running on the deployed machine can be given the identity
+	// of the machine, as cloud-init has, rather than a user's API key.
+	// Only an admin can fetch them. The 2.0 API has no enrollment
+	// tokens for agents, so these credentials are the only identity of
+	// a machine that MAAS issues through it.
+	MetadataClientArgs() (MetadataClientArgs, error)
+
+	// UserData returns the user data supplied when the machine was
+	// deployed, as the metadata service serves it to the machine. The
+	// error satisfies IsNoMatchError if there is no user data.
+	UserData() ([]byte, error)
+
+	// Preseed returns the preseed that MAAS renders for the machine in its
+	// current state, as the metadata service serves it to the machine.
+	Preseed() ([]byte, error)
+
+	// CurtinConfig returns the YAML curtin configuration rendered from the
+	// curtin_userdata templates for the machine. It is only available to
+	// admins, and only while the machine is deploying or deployed; other
+	// states give an error satisfying IsBadRequestError.
+	CurtinConfig() ([]byte, error)
 
 	// Devices returns a list of devices that match the params and have
 	// this Machine as the parent.
 	Devices(DevicesArgs) ([]Device, error)
 
+	// NodeDevices returns the PCI and USB devices found on the machine when
+	// it was commissioned that match the args, such as its GPUs. Servers
+	// without the node devices API give an error satisfying
+	// errors.IsNotSupported.
+	NodeDevices(NodeDevicesArgs) ([]NodeDevice, error)
+
+	// ScriptResults returns the results of the scripts run on the machine,
+	// such as the release scripts that erase its disks, newest first.
+	// Servers without the results API give an error satisfying
+	// errors.IsNotSupported, and servers that do not know the Type give
+	// one satisfying IsBadRequestError.
+	ScriptResults(ScriptResultsArgs) ([]ScriptSet, error)
+
+	// DiskHealth summarizes, per storage device, the newest run of the
+	// machine's testing scripts, as SummarizeDiskHealth does, so that
+	// failing disks can be found. It is empty if the machine was never
+	// tested.
+	DiskHealth() ([]DiskHealth, error)
+
+	// CommissioningResources returns the hardware of the machine as
+	// found by its newest commissioning, with more detail than the
+	// machine itself. It fails with an error satisfying
+	// errors.IsNotFound if the machine was not commissioned by a server
+	// that runs CommissioningResourcesScript.
+	CommissioningResources() (*MachineResources, error)
+
 	// Consider bundling the status values into a single struct.
 	// but need to check for consistent representation if exposed on other
 	// entities.
@@ -226,6 +959,14 @@ type Machine interface {
 	StatusName() string
 	StatusMessage() string
 
+	// Owner is the user that allocated the machine, or empty if it is not
+	// allocated.
+	Owner() string
+
+	// VirtualMachine returns the machine's identity on the pod it was
+	// composed on, or nil if it was not composed.
+	VirtualMachine() VirtualMachine
+
 	// BootInterface returns the interface that was used to boot the Machine.
 	BootInterface() Interface
 	// InterfaceSet returns all the interfaces for the Machine.
@@ -250,12 +991,53 @@ type Machine interface {
 	// id specified. If there is no match, nil is returned.
 	Partition(id int) Partition
 
+	// NUMANodes returns the NUMA nodes of the machine, which are empty
+	// until it is commissioned or if the server does not report them.
+	NUMANodes() []NUMANode
+
 	Zone() Zone
 	Pool() Pool
+	// Domain returns nil if the server did not include the domain.
+	Domain() Domain
 
-	// Start the machine and install the operating system specified in the args.
+	// Start the machine and install the operating system specified in the
+	// args. Options that the server is too old for, such as
+	// EphemeralDeploy before MAAS 3.0, give an error satisfying
+	// errors.IsNotSupported without the machine being deployed.
 	Start(StartArgs) error
 
+	// WaitForStatus reads the machine again, waiting longer each time,
+	// until its status is one of those of the args. A failed status, such
+	// as "Failed deployment", gives an error satisfying
+	// IsMachineFailedError. The wait is abandoned when the context is
+	// done, with an error satisfying IsContextError.
+	WaitForStatus(ctx context.Context, args WaitForStatusArgs) error
+
+	// CreateBond creates a bond of interfaces of the machine. The machine
+	// must be Ready, Allocated or Broken.
+	CreateBond(CreateBondArgs) (Interface, error)
+
+	// BondInterfaces replaces interfaces of the machine with a bond of
+	// them: the links of the parents, with their static addresses, are
+	// moved to the bond. If any step fails, the bond is deleted and the
+	// parents are linked again as they were.
+	BondInterfaces(CreateBondArgs) (Interface, error)
+
+	// CreateInterface creates a physical interface of the machine.
+	CreateInterface(CreateInterfaceArgs) (Interface, error)
+
+	// CreateBridge creates a bridge of an interface of the machine.
+	CreateBridge(CreateBridgeArgs) (Interface, error)
+
+	// CreateVLANInterface creates an interface for a tagged VLAN on an
+	// interface of the machine.
+	CreateVLANInterface(CreateVLANInterfaceArgs) (Interface, error)
+
+	// CreateBlockDevicePartition creates a partition on a block device of
+	// the machine, as BlockDevice.CreatePartition does, after checking
+	// that the machine is not stale and that the block device is its own.
+	CreateBlockDevicePartition(BlockDevice, CreatePartitionArgs) (Partition, error)
+
 	// CreateDevice creates a new Device with this Machine as the parent.
 	// The device will have one interface that is linked to the specified subnet.
 	CreateDevice(CreateMachineDeviceArgs) (Device, error)
@@ -268,6 +1050,38 @@ type Space interface {
 	Subnets() []Subnet
 }
 
+// SSHKey is an SSH public key of the user.
+type SSHKey interface {
+	ID() int
+	// Key is the key as an authorized_keys line.
+	Key() string
+	// KeySource is where the key was imported from, such as
+	// "lp:username" or "gh:username", or empty if it was added directly.
+	KeySource() string
+}
+
+// SSLKey is an SSL certificate of the user.
+type SSLKey interface {
+	ID() int
+	// Key is the PEM encoded certificate.
+	Key() string
+}
+
+// User is a user of MAAS.
+type User interface {
+	Username() string
+	Email() string
+	// Admin is whether the user is a MAAS administrator.
+	Admin() bool
+	// Local is whether the user is managed by MAAS rather than by an
+	// external identity provider.
+	Local() bool
+
+	// Delete removes the user. A user that still owns machines or other
+	// resources cannot be deleted; the error satisfies IsBadRequestError.
+	Delete() error
+}
+
 // Subnet refers to an IP range on a VLAN.
 type Subnet interface {
 	ID() int
@@ -282,11 +1096,30 @@ type Subnet interface {
 	// DNSServers is a list of ip addresses of the DNS servers for the subnet.
 	// This list may be empty.
 	DNSServers() []string
+
+	// Managed is false if MAAS does not allocate addresses on the subnet
+	// outside of its reserved ranges.
+	Managed() bool
+}
+
+// IPAddress is a static address reserved in a subnet.
+type IPAddress interface {
+	IP() string
+	// AllocType is the kind of allocation of the address, and
+	// AllocTypeName its name, such as "User reserved".
+	AllocType() int
+	AllocTypeName() string
+	Created() string
+	// Owner is the name of the user that reserved the address.
+	Owner() string
+	// Subnet returns nil if the server does not report the subnet.
+	Subnet() Subnet
 }
 
 // StaticRoute defines an explicit route that users have requested to be added
 // for a given subnet.
 type StaticRoute interface {
+	ID() int
 	// Source is the subnet that should have the route configured. (Machines
 	// inside Source should use GatewayIP to reach Destination addresses.)
 	Source() Subnet
@@ -323,12 +1156,24 @@ type Interface interface {
 	MACAddress() string
 	EffectiveMTU() int
 
+	// LinkSpeed is the speed in Mbit/s that the link negotiated, and
+	// InterfaceSpeed is the most the interface supports. Both are zero if
+	// the server does not report them.
+	LinkSpeed() int
+	InterfaceSpeed() int
+
 	// Params is a JSON field, and defaults to an empty string, but is almost
 	// always a JSON object in practice. Gleefully ignoring it until we need it.
 
-	// Update the name, mac address or VLAN.
+	// Update the name, mac address, VLAN, tags, MTU or bond parameters.
 	Update(UpdateInterfaceArgs) error
 
+	// AddTag and RemoveTag change a single tag of the interface, leaving
+	// the others alone. Tags mark interfaces for bonding and bridging
+	// policies, or as SR-IOV capable.
+	AddTag(tag string) error
+	RemoveTag(tag string) error
+
 	// Delete this interface.
 	Delete() error
 
@@ -339,6 +1184,18 @@ type Interface interface {
 	// UnlinkSubnet will remove the Link to the subnet, and release the IP
 	// address associated if there is one.
 	UnlinkSubnet(Subnet) error
+
+	// Unlink removes the Link with the ID, and releases its IP address if
+	// it has one. Unlike UnlinkSubnet, it can remove any one of several
+	// links to the same subnet, such as a second static address.
+	Unlink(linkID int) error
+
+	// MoveToVLAN unlinks the interface from its subnets, sets the VLAN and
+	// links it to the new subnet once with each mode it had before, so
+	// several static addresses become one new static address. LINK_UP is
+	// only kept if the interface had no other mode. If any step fails the
+	// previous steps are undone so the interface is left linked as it was.
+	MoveToVLAN(MoveToVLANArgs) error
 }
 
 // Link represents a network link between an Interface and a Subnet.
@@ -384,6 +1241,17 @@ type StorageDevice interface {
 // as a filesystem.
 type Partition interface {
 	StorageDevice
+
+	// Format creates a filesystem on the partition. The machine must be
+	// Ready or Allocated.
+	Format(FormatPartitionArgs) error
+
+	// Mount sets where the filesystem of the partition is mounted when the
+	// machine is deployed.
+	Mount(MountPartitionArgs) error
+
+	// Unmount clears the mount point of the filesystem of the partition.
+	Unmount() error
 }
 
 // BlockDevice represents an entire block device on the machine.
@@ -399,6 +1267,10 @@ type BlockDevice interface {
 
 	Partitions() []Partition
 
+	// CreatePartition creates a partition on the block device. The machine
+	// must be Ready or Allocated.
+	CreatePartition(CreatePartitionArgs) (Partition, error)
+
 	// There are some other attributes for block devices, but we can
 	// expose them on an as needed basis.
 }
@@ -0,0 +1,272 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type ipAddress struct {
+	ip            string
+	allocType     int
+	allocTypeName string
+	created       string
+	owner         string
+	subnet        *subnet
+}
+
+// IP implements IPAddress.
+func (a *ipAddress) IP() string {
+	return a.ip
+}
+
+// AllocType implements IPAddress.
+func (a *ipAddress) AllocType() int {
+	return a.allocType
+}
+
+// AllocTypeName implements IPAddress.
+func (a *ipAddress) AllocTypeName() string {
+	return a.allocTypeName
+}
+
+// Created implements IPAddress.
+func (a *ipAddress) Created() string {
+	return a.created
+}
+
+// Owner implements IPAddress.
+func (a *ipAddress) Owner() string {
+	return a.owner
+}
+
+// Subnet implements IPAddress.
+func (a *ipAddress) Subnet() Subnet {
+	if a.subnet == nil {
+		return nil
+	}
+	return a.subnet
+}
+
+// IPAddressesArgs is an argument struct for Controller.IPAddresses.
+type IPAddressesArgs struct {
+	// Subnet is the ID of the subnet to list the addresses of. Zero
+	// lists the addresses of all subnets.
+	Subnet int
+	// All lists the addresses reserved by every user, rather than only
+	// those of the user of the API key. Only admins may set it.
+	All bool
+}
+
+// IPAddresses implements Controller.
+func (c *controller) IPAddresses(args IPAddressesArgs) ([]IPAddress, error) {
+	params := NewURLParams()
+	params.MaybeAddBool("all", args.All)
+	source, err := c.getQuery(IPAddressesPath, params.Values)
+	if err != nil {
+		return nil, errors.Trace(ipAddressError(err))
+	}
+	addresses, err := readIPAddresses(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []IPAddress
+	for _, a := range addresses {
+		// The server does not filter by subnet.
+		if args.Subnet != 0 && (a.subnet == nil || a.subnet.ID() != args.Subnet) {
+			continue
+		}
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+// ReserveIPAddressArgs is an argument struct for Controller.ReserveIPAddress.
+type ReserveIPAddressArgs struct {
+	// Subnet is the CIDR or ID of the subnet to reserve an address in. If
+	// IP is given the subnet may be left out.
+	Subnet string
+	// IP is the address to reserve. If it is empty, the server picks a
+	// free address in the subnet.
+	IP string
+
+	// Hostname and Domain, if given, add a DNS record for the address.
+	Hostname string
+	Domain   string
+	// MACAddress, if given, ties the address to the MAC address, so that
+	// DHCP gives it out to that MAC address.
+	MACAddress string
+}
+
+// Validate ensures that there is a subnet or an address, and that the
+// address is an IP address.
+func (a *ReserveIPAddressArgs) Validate() error {
+	if a.Subnet == "" && a.IP == "" {
+		return errors.NotValidf("missing Subnet and IP")
+	}
+	if a.IP != "" && net.ParseIP(a.IP) == nil {
+		return errors.NotValidf("IP %q", a.IP)
+	}
+	return nil
+}
+
+// ReserveIPAddress implements Controller.
+func (c *controller) ReserveIPAddress(args ReserveIPAddressArgs) (IPAddress, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.MaybeAdd("subnet", args.Subnet)
+	params.MaybeAdd("ip", args.IP)
+	params.MaybeAdd("hostname", args.Hostname)
+	params.MaybeAdd("domain", args.Domain)
+	params.MaybeAdd("mac", args.MACAddress)
+	source, err := c.post(IPAddressesPath, "reserve", params.Values)
+	if err != nil {
+		return nil, errors.Trace(ipAddressError(err))
+	}
+	result, err := readIPAddress(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
+
+// ReleaseIPAddress implements Controller.
+func (c *controller) ReleaseIPAddress(ip string) error {
+	if net.ParseIP(ip) == nil {
+		return errors.NotValidf("IP %q", ip)
+	}
+	params := NewURLParams()
+	params.Values.Add("ip", ip)
+	// The server answers with an empty body.
+	if _, err := c._postRaw(IPAddressesPath, "release", params.Values); err != nil {
+		return errors.Trace(ipAddressError(err))
+	}
+	return nil
+}
+
+// ipAddressError translates the errors of the IP addresses API. The server
+// answers 404 for an address that is not reserved by the user, and 400 for
+// an address that is in use or outside the subnet.
+func ipAddressError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		case http.StatusServiceUnavailable:
+			// There are no free addresses left in the subnet.
+			return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+func readIPAddress(controllerVersion version.Number, source interface{}) (*ipAddress, error) {
+	readFunc, err := getIPAddressDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ip address base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
+func readIPAddresses(controllerVersion version.Number, source interface{}) ([]*ipAddress, error) {
+	readFunc, err := getIPAddressDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ip address base schema check failed")
+	}
+	sourceList := coerced.([]interface{})
+	result := make([]*ipAddress, 0, len(sourceList))
+	for i, value := range sourceList {
+		a, err := readFunc(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "ip address %d", i)
+		}
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+func getIPAddressDeserializationFunc(controllerVersion version.Number) (ipAddressDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range ipAddressDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no ip address read func for version %s", controllerVersion)
+	}
+	return ipAddressDeserializationFuncs[deserialisationVersion], nil
+}
+
+type ipAddressDeserializationFunc func(map[string]interface{}) (*ipAddress, error)
+
+var ipAddressDeserializationFuncs = map[version.Number]ipAddressDeserializationFunc{
+	twoDotOh: ipAddress_2_0,
+}
+
+func ipAddress_2_0(source map[string]interface{}) (*ipAddress, error) {
+	fields := schema.Fields{
+		"ip":              schema.String(),
+		"alloc_type":      schema.ForceInt(),
+		"alloc_type_name": schema.String(),
+		"created":         schema.String(),
+		"owner":           schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+		"subnet":          schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"alloc_type_name": "",
+		"created":         "",
+		"owner":           nil,
+		"subnet":          nil,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ip address 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	var owner string
+	if ownerMap, ok := valid["owner"].(map[string]interface{}); ok {
+		owner, _ = ownerMap["username"].(string)
+	}
+	var ipSubnet *subnet
+	if subnetMap, ok := valid["subnet"].(map[string]interface{}); ok {
+		ipSubnet, err = subnet_2_0(subnetMap)
+		if err != nil {
+			return nil, errors.Trace(atPath(err, "subnet"))
+		}
+	}
+	return &ipAddress{
+		ip:            valid["ip"].(string),
+		allocType:     valid["alloc_type"].(int),
+		allocTypeName: valid["alloc_type_name"].(string),
+		created:       valid["created"].(string),
+		owner:         owner,
+		subnet:        ipSubnet,
+	}, nil
+}
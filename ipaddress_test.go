@@ -0,0 +1,154 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type ipAddressSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&ipAddressSuite{})
+
+func (*ipAddressSuite) TestReadIPAddressesBadSchema(c *gc.C) {
+	_, err := readIPAddresses(twoDotOh, "wat?")
+	c.Assert(err.Error(), gc.Equals, `ip address base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*ipAddressSuite) TestReadIPAddresses(c *gc.C) {
+	addresses, err := readIPAddresses(twoDotOh, parseJSON(c, ipAddressesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(addresses, gc.HasLen, 2)
+
+	a := addresses[0]
+	c.Check(a.IP(), gc.Equals, "192.168.100.20")
+	c.Check(a.AllocType(), gc.Equals, 4)
+	c.Check(a.AllocTypeName(), gc.Equals, "User reserved")
+	c.Check(a.Created(), gc.Equals, "2019-05-14T10:31:12.845")
+	c.Check(a.Owner(), gc.Equals, "admin")
+	c.Assert(a.Subnet(), gc.NotNil)
+	c.Check(a.Subnet().CIDR(), gc.Equals, "192.168.100.0/24")
+
+	c.Check(addresses[1].Owner(), gc.Equals, "")
+	c.Check(addresses[1].Subnet(), gc.IsNil)
+}
+
+func (*ipAddressSuite) TestLowVersion(c *gc.C) {
+	_, err := readIPAddresses(version.MustParse("1.9.0"), parseJSON(c, ipAddressesResponse))
+	c.Assert(err.Error(), gc.Equals, `no ip address read func for version 1.9.0`)
+}
+
+func (s *ipAddressSuite) TestIPAddresses(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/ipaddresses/", http.StatusOK, ipAddressesResponse)
+	addresses, err := controller.IPAddresses(IPAddressesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(addresses, gc.HasLen, 2)
+}
+
+func (s *ipAddressSuite) TestIPAddressesInSubnet(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/ipaddresses/?all=true", http.StatusOK, ipAddressesResponse)
+	addresses, err := controller.IPAddresses(IPAddressesArgs{Subnet: 1, All: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(addresses, gc.HasLen, 1)
+	c.Check(addresses[0].IP(), gc.Equals, "192.168.100.20")
+}
+
+func (s *ipAddressSuite) TestReserveIPAddress(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/ipaddresses/?op=reserve", http.StatusOK, ipAddressResponse)
+	address, err := controller.ReserveIPAddress(ReserveIPAddressArgs{
+		Subnet:   "192.168.100.0/24",
+		Hostname: "gateway",
+		Domain:   "maas",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(address.IP(), gc.Equals, "192.168.100.20")
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 3)
+	c.Check(form.Get("subnet"), gc.Equals, "192.168.100.0/24")
+	c.Check(form.Get("hostname"), gc.Equals, "gateway")
+	c.Check(form.Get("domain"), gc.Equals, "maas")
+}
+
+func (s *ipAddressSuite) TestReserveIPAddressValidates(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	for _, test := range []struct {
+		args    ReserveIPAddressArgs
+		message string
+	}{{
+		args:    ReserveIPAddressArgs{Hostname: "gateway"},
+		message: "missing Subnet and IP not valid",
+	}, {
+		args:    ReserveIPAddressArgs{IP: "192.168.100"},
+		message: `IP "192.168.100" not valid`,
+	}} {
+		_, err := controller.ReserveIPAddress(test.args)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (s *ipAddressSuite) TestReserveIPAddressErrors(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/ipaddresses/?op=reserve", http.StatusBadRequest, "The IP address 192.168.100.20 is already in use.")
+	server.AddPostResponse("/api/2.0/ipaddresses/?op=reserve", http.StatusServiceUnavailable, "No more IPs available in subnet: 192.168.100.0/24.")
+	_, err := controller.ReserveIPAddress(ReserveIPAddressArgs{IP: "192.168.100.20"})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	_, err = controller.ReserveIPAddress(ReserveIPAddressArgs{Subnet: "1"})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+}
+
+func (s *ipAddressSuite) TestReleaseIPAddress(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/ipaddresses/?op=release", http.StatusOK, "")
+	server.AddPostResponse("/api/2.0/ipaddresses/?op=release", http.StatusNotFound, "IP address 192.168.100.21 does not exist, or is not owned by you.")
+	err := controller.ReleaseIPAddress("192.168.100.20")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.LastRequest().PostForm.Get("ip"), gc.Equals, "192.168.100.20")
+
+	err = controller.ReleaseIPAddress("192.168.100.21")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+
+	err = controller.ReleaseIPAddress("gateway")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+var ipAddressResponse = `
+{
+    "alloc_type": 4,
+    "alloc_type_name": "User reserved",
+    "created": "2019-05-14T10:31:12.845",
+    "ip": "192.168.100.20",
+    "owner": {
+        "username": "admin",
+        "email": "admin@example.com",
+        "is_superuser": true,
+        "resource_uri": "/MAAS/api/2.0/users/admin/"
+    },
+    "subnet": ` + subnetItemResponse + `,
+    "resource_uri": "/MAAS/api/2.0/ipaddresses/"
+}
+`
+
+var ipAddressesResponse = `[` + ipAddressResponse + `,
+{
+    "alloc_type": 4,
+    "alloc_type_name": "User reserved",
+    "created": "2019-05-14T10:32:40.112",
+    "ip": "10.0.0.9",
+    "owner": null,
+    "resource_uri": "/MAAS/api/2.0/ipaddresses/"
+}
+]`
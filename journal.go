@@ -0,0 +1,226 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// JournalEntry is a request recorded in a Journal because the server could
+// not be reached.
+type JournalEntry struct {
+	Time time.Time `json:"time"`
+	// Method is "POST", "PUT" or "DELETE".
+	Method string `json:"method"`
+	// Path is the path of the request relative to the API URL, with the
+	// op of a POST as its query, such as "machines/4y3ha3/?op=release".
+	Path   string     `json:"path"`
+	Params url.Values `json:"params,omitempty"`
+}
+
+// Journal is a file of the requests that change the region and were not
+// sent because the server could not be reached, for sites with unreliable
+// links to MAAS. A Client with a Journal records such requests instead of
+// failing, and they are sent later by Replay.
+//
+// Only requests that were never received by the server are recorded: a
+// request that failed after the connection was made may have been acted
+// on. Requests that upload files are not recorded. The parameters are
+// written as they are, credentials included, so the file is only readable
+// by its owner.
+type Journal struct {
+	path string
+
+	mu sync.Mutex
+}
+
+// OpenJournal opens the journal in the file at the path, creating the file
+// if it doesn't exist.
+func OpenJournal(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	file.Close()
+	journal := &Journal{path: path}
+	if _, err := journal.Entries(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return journal, nil
+}
+
+// Entries returns the requests in the journal, oldest first.
+func (j *Journal) Entries() ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.read()
+}
+
+func (j *Journal) read() ([]JournalEntry, error) {
+	data, err := ioutil.ReadFile(j.path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []JournalEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, NewDeserializationError("journal %s line %d: %v", j.path, line, err)
+		}
+		result = append(result, entry)
+	}
+	return result, errors.Trace(scanner.Err())
+}
+
+func (j *Journal) append(entry JournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return errors.Trace(err)
+	}
+	return errors.Trace(file.Close())
+}
+
+// write replaces the entries of the journal.
+func (j *Journal) write(entries []JournalEntry) error {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		buf.Write(append(data, '\n'))
+	}
+	temp := j.path + ".tmp"
+	if err := ioutil.WriteFile(temp, buf.Bytes(), 0600); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(temp, j.path))
+}
+
+// ReplayDecision is what Replay does with a journal entry.
+type ReplayDecision int
+
+const (
+	// ReplayEntry sends the request and removes it from the journal.
+	ReplayEntry ReplayDecision = iota
+	// DiscardEntry removes the request from the journal without sending it.
+	DiscardEntry
+	// StopReplay leaves the request, and those after it, in the journal.
+	StopReplay
+)
+
+// Replay sends the requests in the journal with the client, oldest first,
+// and removes those that were sent. If confirm is not nil, it is asked
+// what to do with each request before it is sent. Replay stops at the
+// first request that fails, leaving it and the requests after it in the
+// journal, and returns the error. The number of requests sent is returned.
+func (j *Journal) Replay(client Client, confirm func(JournalEntry) ReplayDecision) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries, err := j.read()
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	// Requests that fail again are not recorded twice.
+	client.Journal = nil
+	sent := 0
+	done := 0
+	for _, entry := range entries {
+		decision := ReplayEntry
+		if confirm != nil {
+			decision = confirm(entry)
+		}
+		if decision == StopReplay {
+			break
+		}
+		if decision == ReplayEntry {
+			if err = j.send(client, entry); err != nil {
+				err = errors.Annotatef(err, "replaying %s %s", entry.Method, entry.Path)
+				break
+			}
+			sent++
+		}
+		done++
+	}
+	if done > 0 {
+		if writeErr := j.write(entries[done:]); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	return sent, errors.Trace(err)
+}
+
+func (j *Journal) send(client Client, entry JournalEntry) error {
+	uri, err := url.Parse(entry.Path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	switch entry.Method {
+	case "POST", "PUT":
+		_, err = client.nonIdempotentRequest(entry.Method, uri, entry.Params)
+	case "DELETE":
+		err = client.Delete(uri)
+	default:
+		err = errors.NotValidf("journal entry method %q", entry.Method)
+	}
+	return err
+}
+
+// journal records the request in the journal of the client when the error
+// shows that the server could not be reached. The error to return is the
+// JournaledError, or the original error if the request wasn't recorded.
+func (client Client) journal(method string, uri *url.URL, parameters url.Values, err error) error {
+	if client.Journal == nil || client.context().Err() != nil || !unreachable(err) {
+		return err
+	}
+	entry := JournalEntry{
+		Time:   client.clock().Now().UTC(),
+		Method: method,
+		Path:   uri.String(),
+		Params: parameters,
+	}
+	if journalErr := client.Journal.append(entry); journalErr != nil {
+		httpLogger.Errorf("cannot record %s %s in the journal: %v", method, uri, journalErr)
+		return err
+	}
+	httpLogger.Warningf("server unreachable, recorded %s %s in the journal", method, uri)
+	return errors.Wrap(err, NewJournaledError(method, uri.String()))
+}
+
+// unreachable returns true if the request failed before it reached the
+// server, because the address could not be resolved or connected to.
+func unreachable(err error) bool {
+	var dnsError *net.DNSError
+	if stderrors.As(err, &dnsError) {
+		return true
+	}
+	var opError *net.OpError
+	return stderrors.As(err, &opError) && opError.Op == "dial"
+}
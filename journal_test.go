@@ -0,0 +1,205 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type journalSuite struct{}
+
+var _ = gc.Suite(&journalSuite{})
+
+func (*journalSuite) openJournal(c *gc.C) *Journal {
+	journal, err := OpenJournal(filepath.Join(c.MkDir(), "journal"))
+	c.Assert(err, jc.ErrorIsNil)
+	return journal
+}
+
+// unreachableClient returns a client for a server that has gone away.
+func (*journalSuite) unreachableClient(c *gc.C, journal *Journal) *Client {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.Journal = journal
+	return client
+}
+
+func (s *journalSuite) TestOpenJournalCreatesFile(c *gc.C) {
+	path := filepath.Join(c.MkDir(), "journal")
+	journal, err := OpenJournal(path)
+	c.Assert(err, jc.ErrorIsNil)
+	info, err := os.Stat(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(info.Mode().Perm(), gc.Equals, os.FileMode(0600))
+	entries, err := journal.Entries()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(entries, gc.HasLen, 0)
+}
+
+func (s *journalSuite) TestOpenJournalBadEntry(c *gc.C) {
+	path := filepath.Join(c.MkDir(), "journal")
+	err := ioutil.WriteFile(path, []byte("{not json\n"), 0600)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = OpenJournal(path)
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Check(err, gc.ErrorMatches, `journal .* line 1: .*`)
+}
+
+func (s *journalSuite) TestUnreachableRequestsJournaled(c *gc.C) {
+	journal := s.openJournal(c)
+	client := s.unreachableClient(c, journal)
+
+	_, err := client.Post(&url.URL{Path: "machines/4y3ha3/"}, "release", url.Values{"comment": {"done"}}, nil)
+	c.Check(err, jc.Satisfies, IsJournaledError)
+	err = client.Delete(&url.URL{Path: "tags/virtual/"})
+	c.Check(err, jc.Satisfies, IsJournaledError)
+	// Reads are not journaled.
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+	c.Check(err, gc.NotNil)
+	c.Check(err, gc.Not(jc.Satisfies), IsJournaledError)
+
+	entries, err := journal.Entries()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(entries, gc.HasLen, 2)
+	c.Check(entries[0].Method, gc.Equals, "POST")
+	c.Check(entries[0].Path, gc.Equals, "machines/4y3ha3/?op=release")
+	c.Check(entries[0].Params, jc.DeepEquals, url.Values{"comment": {"done"}})
+	c.Check(entries[0].Time.IsZero(), jc.IsFalse)
+	c.Check(entries[1].Method, gc.Equals, "DELETE")
+	c.Check(entries[1].Path, gc.Equals, "tags/virtual/")
+}
+
+func (s *journalSuite) TestServerErrorNotJournaled(c *gc.C) {
+	journal := s.openJournal(c)
+	server := newSingleServingServer("/api/2.0/machines/?op=allocate", "no", http.StatusConflict)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.Journal = journal
+
+	_, err = client.Post(&url.URL{Path: "machines/"}, "allocate", nil, nil)
+	c.Check(err, gc.Not(jc.Satisfies), IsJournaledError)
+	entries, err := journal.Entries()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(entries, gc.HasLen, 0)
+}
+
+func (s *journalSuite) TestIsJournaledErrorWrapped(c *gc.C) {
+	err := errors.Wrap(errors.New("dial"), NewJournaledError("POST", "machines/"))
+	c.Check(IsJournaledError(err), jc.IsTrue)
+	c.Check(IsJournaledError(NewUnexpectedError(err)), jc.IsTrue)
+	c.Check(IsJournaledError(errors.New("other")), jc.IsFalse)
+}
+
+// recordingServer records the requests it receives, and fails those for
+// the failPath.
+func recordingServer(failPath string) (*httptest.Server, *[]string) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL, r.PostForm.Encode()))
+		if r.URL.Path == failPath {
+			http.Error(w, "no", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, "{}")
+	}))
+	return server, &requests
+}
+
+func (s *journalSuite) journalRequests(c *gc.C) *Journal {
+	journal := s.openJournal(c)
+	client := s.unreachableClient(c, journal)
+	client.Post(&url.URL{Path: "machines/a/"}, "release", url.Values{"comment": {"one"}}, nil)
+	client.Put(&url.URL{Path: "zones/b/"}, url.Values{"description": {"two"}})
+	client.Delete(&url.URL{Path: "tags/c/"})
+	entries, err := journal.Entries()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(entries, gc.HasLen, 3)
+	return journal
+}
+
+func (s *journalSuite) TestReplay(c *gc.C) {
+	journal := s.journalRequests(c)
+	server, requests := recordingServer("")
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	sent, err := journal.Replay(*client, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(sent, gc.Equals, 3)
+	c.Check(*requests, jc.DeepEquals, []string{
+		"POST /api/2.0/machines/a/?op=release comment=one",
+		"PUT /api/2.0/zones/b/ description=two",
+		"DELETE /api/2.0/tags/c/ ",
+	})
+	entries, err := journal.Entries()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(entries, gc.HasLen, 0)
+}
+
+func (s *journalSuite) TestReplayConfirm(c *gc.C) {
+	journal := s.journalRequests(c)
+	server, requests := recordingServer("")
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	decisions := []ReplayDecision{DiscardEntry, ReplayEntry, StopReplay}
+	var asked []string
+	sent, err := journal.Replay(*client, func(entry JournalEntry) ReplayDecision {
+		asked = append(asked, entry.Path)
+		return decisions[len(asked)-1]
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(sent, gc.Equals, 1)
+	c.Check(asked, jc.DeepEquals, []string{"machines/a/?op=release", "zones/b/", "tags/c/"})
+	c.Check(*requests, jc.DeepEquals, []string{"PUT /api/2.0/zones/b/ description=two"})
+	entries, err := journal.Entries()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(entries, gc.HasLen, 1)
+	c.Check(entries[0].Path, gc.Equals, "tags/c/")
+}
+
+func (s *journalSuite) TestReplayStopsOnError(c *gc.C) {
+	journal := s.journalRequests(c)
+	server, requests := recordingServer("/api/2.0/zones/b/")
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	sent, err := journal.Replay(*client, nil)
+	c.Assert(err, gc.ErrorMatches, `replaying PUT zones/b/: ServerError: 400 Bad Request \(no\n\)`)
+	c.Check(sent, gc.Equals, 1)
+	c.Check(*requests, gc.HasLen, 2)
+	entries, err := journal.Entries()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(entries, gc.HasLen, 2)
+	c.Check(entries[0].Path, gc.Equals, "zones/b/")
+}
+
+func (s *journalSuite) TestReplayUnreachableNotJournaledAgain(c *gc.C) {
+	journal := s.journalRequests(c)
+	client := s.unreachableClient(c, journal)
+
+	sent, err := journal.Replay(*client, nil)
+	c.Check(err, gc.NotNil)
+	c.Check(sent, gc.Equals, 0)
+	entries, err := journal.Entries()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(entries, gc.HasLen, 3)
+}
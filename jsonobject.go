@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 )
 
 // JSONObject is a wrapper around a JSON structure which provides
@@ -50,6 +51,10 @@ type JSONObject struct {
 // as a jsonMap and never notice the difference.)
 const resourceURI = "resource_uri"
 
+// maxExactInt is the largest magnitude of the whole numbers that a float64
+// holds exactly, 2^53.
+const maxExactInt = 1 << 53
+
 // maasify turns a completely untyped json.Unmarshal result into a JSONObject
 // (with the appropriate implementation of course).  This function is
 // recursive.  Maps and arrays are deep-copied, with each individual value
@@ -213,3 +218,74 @@ func (obj JSONObject) GetBytes() ([]byte, error) {
 	}
 	return obj.bytes, nil
 }
+
+// JSONValue is the set of types that GetAs, GetArray and GetField read
+// values as. JSON numbers are float64, but can also be read as int if they
+// are whole.
+type JSONValue interface {
+	string | float64 | int | bool | map[string]JSONObject | []JSONObject | MAASObject
+}
+
+// GetAs retrieves the object's value as a T. If the value isn't of that
+// type, that's an error, as it is for the Get*() methods.
+func GetAs[T JSONValue](obj JSONObject) (T, error) {
+	var result T
+	var err error
+	switch target := interface{}(&result).(type) {
+	case *string:
+		*target, err = obj.GetString()
+	case *float64:
+		*target, err = obj.GetFloat64()
+	case *int:
+		var value float64
+		value, err = obj.GetFloat64()
+		if err == nil {
+			if value != math.Trunc(value) || math.Abs(value) > maxExactInt || value > math.MaxInt || value < math.MinInt {
+				err = fmt.Errorf("requested int, got %v", value)
+			} else {
+				*target = int(value)
+			}
+		}
+	case *bool:
+		*target, err = obj.GetBool()
+	case *map[string]JSONObject:
+		*target, err = obj.GetMap()
+	case *[]JSONObject:
+		*target, err = obj.GetArray()
+	case *MAASObject:
+		*target, err = obj.GetMAASObject()
+	}
+	return result, err
+}
+
+// GetArray retrieves the object's value as an array of T. If the value
+// isn't an array, or one of its elements isn't a T, that's an error, and
+// the error for an element gives its index.
+func GetArray[T JSONValue](obj JSONObject) ([]T, error) {
+	array, err := obj.GetArray()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]T, len(array))
+	for i, elem := range array {
+		if result[i], err = GetAs[T](elem); err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return result, nil
+}
+
+// GetField retrieves the named field of the MAAS object as a T. If there
+// is no such field, or it isn't a T, that's an error that names the field.
+func GetField[T JSONValue](obj MAASObject, name string) (T, error) {
+	value, ok := obj.values[name]
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("field %q: not found", name)
+	}
+	result, err := GetAs[T](value)
+	if err != nil {
+		return result, fmt.Errorf("field %q: %w", name, err)
+	}
+	return result, nil
+}
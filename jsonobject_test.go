@@ -6,6 +6,7 @@ package gomaasapi
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 
 	. "gopkg.in/check.v1"
 )
@@ -461,3 +462,77 @@ func (suite *JSONObjectSuite) TestBoolSerializesToJSON(c *C) {
 	c.Check(f, DeepEquals, []byte("false"))
 	c.Check(t, DeepEquals, []byte("true"))
 }
+
+func (suite *JSONObjectSuite) TestGetAs(c *C) {
+	obj, err := Parse(Client{}, []byte(`{"name": "x", "count": 3, "ratio": 1.5, "ok": true, "list": [1]}`))
+	c.Assert(err, IsNil)
+	values, err := GetAs[map[string]JSONObject](obj)
+	c.Assert(err, IsNil)
+
+	name, err := GetAs[string](values["name"])
+	c.Assert(err, IsNil)
+	c.Check(name, Equals, "x")
+	count, err := GetAs[int](values["count"])
+	c.Assert(err, IsNil)
+	c.Check(count, Equals, 3)
+	ratio, err := GetAs[float64](values["ratio"])
+	c.Assert(err, IsNil)
+	c.Check(ratio, Equals, 1.5)
+	ok, err := GetAs[bool](values["ok"])
+	c.Assert(err, IsNil)
+	c.Check(ok, Equals, true)
+
+	_, err = GetAs[int](values["ratio"])
+	c.Check(err, ErrorMatches, `requested int, got 1.5`)
+	_, err = GetAs[string](values["list"])
+	c.Check(err, ErrorMatches, `Requested string, got \[\]gomaasapi.JSONObject.`)
+}
+
+func (suite *JSONObjectSuite) TestGetAsLargeInt(c *C) {
+	obj, err := Parse(Client{}, []byte(`[4294967296, 9007199254740992, 18014398509481984]`))
+	c.Assert(err, IsNil)
+	values, err := GetAs[[]JSONObject](obj)
+	c.Assert(err, IsNil)
+
+	if math.MaxInt > math.MaxInt32 {
+		value, err := GetAs[int](values[0])
+		c.Assert(err, IsNil)
+		c.Check(int64(value), Equals, int64(1)<<32)
+		value, err = GetAs[int](values[1])
+		c.Assert(err, IsNil)
+		c.Check(int64(value), Equals, int64(1)<<53)
+	}
+	_, err = GetAs[int](values[2])
+	c.Check(err, ErrorMatches, `requested int, got 1.8014398509481984e\+16`)
+}
+
+func (suite *JSONObjectSuite) TestGetArray(c *C) {
+	obj, err := Parse(Client{}, []byte(`["a", "b", 3]`))
+	c.Assert(err, IsNil)
+	_, err = GetArray[string](obj)
+	c.Check(err, ErrorMatches, `element 2: Requested string, got float64.`)
+
+	obj, err = Parse(Client{}, []byte(`[1, 2, 3]`))
+	c.Assert(err, IsNil)
+	numbers, err := GetArray[int](obj)
+	c.Assert(err, IsNil)
+	c.Check(numbers, DeepEquals, []int{1, 2, 3})
+
+	_, err = GetArray[int](maasify(Client{}, "x"))
+	c.Check(err, ErrorMatches, `Requested array, got string.`)
+}
+
+func (suite *JSONObjectSuite) TestGetField(c *C) {
+	obj, err := Parse(Client{}, []byte(`{"resource_uri": "/a/", "tag_names": ["x", "y"], "memory": "lots"}`))
+	c.Assert(err, IsNil)
+	maasObj, err := obj.GetMAASObject()
+	c.Assert(err, IsNil)
+
+	tags, err := GetField[[]JSONObject](maasObj, "tag_names")
+	c.Assert(err, IsNil)
+	c.Check(tags, HasLen, 2)
+	_, err = GetField[int](maasObj, "memory")
+	c.Check(err, ErrorMatches, `field "memory": Requested float64, got string.`)
+	_, err = GetField[string](maasObj, "hostname")
+	c.Check(err, ErrorMatches, `field "hostname": not found`)
+}
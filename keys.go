@@ -0,0 +1,316 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type sshKey struct {
+	resourceURI string
+
+	id        int
+	key       string
+	keySource string
+}
+
+// ID implements SSHKey.
+func (k *sshKey) ID() int {
+	return k.id
+}
+
+// Key implements SSHKey.
+func (k *sshKey) Key() string {
+	return k.key
+}
+
+// KeySource implements SSHKey.
+func (k *sshKey) KeySource() string {
+	return k.keySource
+}
+
+type sslKey struct {
+	resourceURI string
+
+	id  int
+	key string
+}
+
+// ID implements SSLKey.
+func (k *sslKey) ID() int {
+	return k.id
+}
+
+// Key implements SSLKey.
+func (k *sslKey) Key() string {
+	return k.key
+}
+
+// SSHKeys implements Controller.
+func (c *controller) SSHKeys() ([]SSHKey, error) {
+	source, err := c.get(SSHKeysPath)
+	if err != nil {
+		return nil, errors.Trace(keyError(err))
+	}
+	keys, err := readSSHKeys(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []SSHKey
+	for _, k := range keys {
+		result = append(result, k)
+	}
+	return result, nil
+}
+
+// AddSSHKey implements Controller.
+func (c *controller) AddSSHKey(key string) (SSHKey, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, errors.NotValidf("empty key")
+	}
+	params := NewURLParams()
+	params.Values.Add("key", key)
+	source, err := c.post(SSHKeysPath, "", params.Values)
+	if err != nil {
+		return nil, errors.Trace(keyError(err))
+	}
+	result, err := readSSHKey(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
+
+// DeleteSSHKey implements Controller.
+func (c *controller) DeleteSSHKey(id int) error {
+	if err := c.delete(APIPath(SSHKeysPath, id)); err != nil {
+		return errors.Trace(keyError(err))
+	}
+	return nil
+}
+
+// SSLKeys implements Controller.
+func (c *controller) SSLKeys() ([]SSLKey, error) {
+	source, err := c.get(SSLKeysPath)
+	if err != nil {
+		return nil, errors.Trace(keyError(err))
+	}
+	keys, err := readSSLKeys(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []SSLKey
+	for _, k := range keys {
+		result = append(result, k)
+	}
+	return result, nil
+}
+
+// AddSSLKey implements Controller.
+func (c *controller) AddSSLKey(key string) (SSLKey, error) {
+	if strings.TrimSpace(key) == "" {
+		return nil, errors.NotValidf("empty key")
+	}
+	params := NewURLParams()
+	params.Values.Add("key", key)
+	source, err := c.post(SSLKeysPath, "", params.Values)
+	if err != nil {
+		return nil, errors.Trace(keyError(err))
+	}
+	result, err := readSSLKey(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
+
+// DeleteSSLKey implements Controller.
+func (c *controller) DeleteSSLKey(id int) error {
+	if err := c.delete(APIPath(SSLKeysPath, id)); err != nil {
+		return errors.Trace(keyError(err))
+	}
+	return nil
+}
+
+// keyError translates the errors of the SSH and SSL key APIs. The server
+// gives a 400 for a key that cannot be parsed or that the user already
+// has, and a 404 for the key of another user.
+func keyError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+func readSSHKey(controllerVersion version.Number, source interface{}) (*sshKey, error) {
+	readFunc, err := getSSHKeyDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ssh key base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
+func readSSHKeys(controllerVersion version.Number, source interface{}) ([]*sshKey, error) {
+	readFunc, err := getSSHKeyDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ssh key base schema check failed")
+	}
+	sourceList := coerced.([]interface{})
+	result := make([]*sshKey, 0, len(sourceList))
+	for i, value := range sourceList {
+		k, err := readFunc(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "ssh key %d", i)
+		}
+		result = append(result, k)
+	}
+	return result, nil
+}
+
+func getSSHKeyDeserializationFunc(controllerVersion version.Number) (sshKeyDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range sshKeyDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no ssh key read func for version %s", controllerVersion)
+	}
+	return sshKeyDeserializationFuncs[deserialisationVersion], nil
+}
+
+type sshKeyDeserializationFunc func(map[string]interface{}) (*sshKey, error)
+
+var sshKeyDeserializationFuncs = map[version.Number]sshKeyDeserializationFunc{
+	twoDotOh: sshKey_2_0,
+}
+
+func sshKey_2_0(source map[string]interface{}) (*sshKey, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+		"id":           schema.ForceInt(),
+		"key":          schema.String(),
+		"keysource":    schema.OneOf(schema.Nil(""), schema.String()),
+	}
+	defaults := schema.Defaults{
+		// Servers before 2.2 cannot import keys.
+		"keysource": nil,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ssh key 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	keySource, _ := valid["keysource"].(string)
+	return &sshKey{
+		resourceURI: valid["resource_uri"].(string),
+		id:          valid["id"].(int),
+		key:         valid["key"].(string),
+		keySource:   keySource,
+	}, nil
+}
+
+func readSSLKey(controllerVersion version.Number, source interface{}) (*sslKey, error) {
+	readFunc, err := getSSLKeyDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ssl key base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
+func readSSLKeys(controllerVersion version.Number, source interface{}) ([]*sslKey, error) {
+	readFunc, err := getSSLKeyDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ssl key base schema check failed")
+	}
+	sourceList := coerced.([]interface{})
+	result := make([]*sslKey, 0, len(sourceList))
+	for i, value := range sourceList {
+		k, err := readFunc(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "ssl key %d", i)
+		}
+		result = append(result, k)
+	}
+	return result, nil
+}
+
+func getSSLKeyDeserializationFunc(controllerVersion version.Number) (sslKeyDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range sslKeyDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no ssl key read func for version %s", controllerVersion)
+	}
+	return sslKeyDeserializationFuncs[deserialisationVersion], nil
+}
+
+type sslKeyDeserializationFunc func(map[string]interface{}) (*sslKey, error)
+
+var sslKeyDeserializationFuncs = map[version.Number]sslKeyDeserializationFunc{
+	twoDotOh: sslKey_2_0,
+}
+
+func sslKey_2_0(source map[string]interface{}) (*sslKey, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+		"id":           schema.ForceInt(),
+		"key":          schema.String(),
+	}
+	checker := schema.FieldMap(fields, nil) // no defaults
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ssl key 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	return &sslKey{
+		resourceURI: valid["resource_uri"].(string),
+		id:          valid["id"].(int),
+		key:         valid["key"].(string),
+	}, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type keySuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&keySuite{})
+
+func (*keySuite) TestReadSSHKeysBadSchema(c *gc.C) {
+	_, err := readSSHKeys(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `ssh key base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*keySuite) TestReadSSHKeys(c *gc.C) {
+	keys, err := readSSHKeys(twoDotOh, parseJSON(c, sshKeysResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(keys, gc.HasLen, 2)
+	c.Check(keys[0].ID(), gc.Equals, 1)
+	c.Check(keys[0].Key(), gc.Equals, "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBxm bob@laptop")
+	c.Check(keys[0].KeySource(), gc.Equals, "")
+	c.Check(keys[1].KeySource(), gc.Equals, "gh:bob")
+}
+
+func (*keySuite) TestReadSSLKeys(c *gc.C) {
+	keys, err := readSSLKeys(twoDotOh, parseJSON(c, sslKeysResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(keys, gc.HasLen, 1)
+	c.Check(keys[0].ID(), gc.Equals, 4)
+	c.Check(keys[0].Key(), gc.Matches, "-----BEGIN CERTIFICATE-----\n(.|\n)*")
+}
+
+func (*keySuite) TestLowVersion(c *gc.C) {
+	_, err := readSSHKeys(version.MustParse("1.9.0"), parseJSON(c, sshKeysResponse))
+	c.Assert(err.Error(), gc.Equals, `no ssh key read func for version 1.9.0`)
+	_, err = readSSLKeys(version.MustParse("1.9.0"), parseJSON(c, sslKeysResponse))
+	c.Assert(err.Error(), gc.Equals, `no ssl key read func for version 1.9.0`)
+}
+
+func (s *keySuite) TestSSHKeys(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/sshkeys/", http.StatusOK, sshKeysResponse)
+	keys, err := controller.SSHKeys()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(keys, gc.HasLen, 2)
+}
+
+func (s *keySuite) TestAddSSHKey(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/sshkeys/?op=", http.StatusOK, sshKeyResponse)
+	key, err := controller.AddSSHKey("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBxm bob@laptop\n")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(key.ID(), gc.Equals, 1)
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 1)
+	c.Check(form.Get("key"), gc.Equals, "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBxm bob@laptop")
+}
+
+func (s *keySuite) TestAddSSHKeyErrors(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	_, err := controller.AddSSHKey(" ")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, "empty key not valid")
+
+	server.AddPostResponse("/api/2.0/sshkeys/?op=", http.StatusBadRequest, `{"key": ["Invalid SSH public key: unable to determine the key type."]}`)
+	_, err = controller.AddSSHKey("not a key")
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *keySuite) TestDeleteSSHKey(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddDeleteResponse("/api/2.0/sshkeys/1/", http.StatusNoContent, "")
+	err := controller.DeleteSSHKey(1)
+	c.Assert(err, jc.ErrorIsNil)
+	err = controller.DeleteSSHKey(2)
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *keySuite) TestSSLKeys(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/sslkeys/", http.StatusOK, sslKeysResponse)
+	keys, err := controller.SSLKeys()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(keys, gc.HasLen, 1)
+}
+
+func (s *keySuite) TestAddSSLKey(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/sslkeys/?op=", http.StatusOK, sslKeyResponse)
+	key, err := controller.AddSSLKey(testCertificate)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(key.ID(), gc.Equals, 4)
+	c.Check(server.LastRequest().PostForm.Get("key"), gc.Equals, testCertificate)
+
+	_, err = controller.AddSSLKey("")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *keySuite) TestDeleteSSLKey(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddDeleteResponse("/api/2.0/sslkeys/4/", http.StatusForbidden, "Can't delete a key you don't own.")
+	err := controller.DeleteSSLKey(4)
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
+const (
+	testCertificate = "-----BEGIN CERTIFICATE-----\nMIIBszCCAVmgAwIBAgIUQ2Fu\n-----END CERTIFICATE-----\n"
+
+	sshKeyResponse = `
+{
+    "id": 1,
+    "key": "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBxm bob@laptop",
+    "keysource": null,
+    "resource_uri": "/MAAS/api/2.0/account/prefs/sshkeys/1/"
+}
+`
+	sshKeysResponse = `[` + sshKeyResponse + `,
+{
+    "id": 2,
+    "key": "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC7 bob@github",
+    "keysource": "gh:bob",
+    "resource_uri": "/MAAS/api/2.0/account/prefs/sshkeys/2/"
+}
+]`
+	sslKeyResponse = `
+{
+    "id": 4,
+    "key": "-----BEGIN CERTIFICATE-----\nMIIBszCCAVmgAwIBAgIUQ2Fu\n-----END CERTIFICATE-----\n",
+    "resource_uri": "/MAAS/api/2.0/account/prefs/sslkeys/4/"
+}
+`
+	sslKeysResponse = `[` + sslKeyResponse + `]`
+)
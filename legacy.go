@@ -0,0 +1,95 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/juju/errors"
+)
+
+// The functions here let code written against the MAASObject API and code
+// written against the Controller share a connection to the server, so that
+// a code base can move from one to the other a piece at a time.
+
+// NewControllerFromMAAS creates a Controller that makes its requests with
+// the client of the MAAS object, as returned by NewMAAS. The BaseURL and
+// APIKey of the args are not used, as the client already has them; the
+// other args apply as they do to NewController.
+func NewControllerFromMAAS(maas *MAASObject, args ControllerArgs) (Controller, error) {
+	client := maas.client
+	base, apiVersion, includesVersion := SplitVersionedURL(client.APIURL.String())
+	if !includesVersion {
+		return nil, errors.NotValidf("MAAS object URL %q without API version", client.APIURL)
+	}
+	if !supportedVersion(apiVersion) {
+		return nil, NewUnsupportedVersionError("version %s", apiVersion)
+	}
+	controller, err := newControllerWithClient(&client, base, apiVersion, args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return controller, nil
+}
+
+// MAASForController returns the root MAAS object for the server of the
+// Controller, using its client. Controllers not made by this package give
+// an error satisfying errors.IsNotSupported.
+func MAASForController(c Controller) (*MAASObject, error) {
+	ctrl, ok := c.(*controller)
+	if !ok {
+		return nil, errors.NotSupportedf("MAAS object for %T", c)
+	}
+	return NewMAAS(*ctrl.client), nil
+}
+
+// MachineMAASObject reads the MAAS object for the machine, so that calls
+// can be made on it that Machine does not have. It gives an error
+// satisfying IsNoMatchError if the machine no longer exists.
+func MachineMAASObject(m Machine) (MAASObject, error) {
+	mach, ok := m.(*machine)
+	if !ok || mach.controller == nil {
+		return MAASObject{}, errors.NotSupportedf("MAAS object for %T", m)
+	}
+	// The machine does not keep the values that it was read with.
+	object := newJSONMAASObject(map[string]interface{}{resourceURI: mach.resourceURI}, *mach.controller.client)
+	result, err := object.Get()
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return MAASObject{}, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return MAASObject{}, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return MAASObject{}, NewUnexpectedError(err)
+	}
+	return result, nil
+}
+
+// MachineFromMAASObject reads the machine that the MAAS object, such as a
+// node from the nodes or machines API, represents. The machine uses the
+// Controller for its requests.
+func MachineFromMAASObject(c Controller, object MAASObject) (Machine, error) {
+	ctrl, ok := c.(*controller)
+	if !ok {
+		return nil, errors.NotSupportedf("machine for %T", c)
+	}
+	bytes, err := json.Marshal(object.GetMap())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	source, err := parseResponse(bytes)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	m, err := readMachine(ctrl.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	ctrl.adoptMachine(m)
+	return m, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type legacySuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&legacySuite{})
+
+func (s *legacySuite) TestNewControllerFromMAAS(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	client, err := NewAuthenticatedClient(server.URL+"/api/2.0/", "fake:as:key")
+	c.Assert(err, jc.ErrorIsNil)
+	controller, err := NewControllerFromMAAS(NewMAAS(*client), ControllerArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	zones, err := controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(zones, gc.HasLen, 2)
+}
+
+func (s *legacySuite) TestNewControllerFromMAASNoVersion(c *gc.C) {
+	baseURL, err := url.Parse("http://maas.example.com/MAAS/")
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = NewControllerFromMAAS(NewMAAS(Client{APIURL: baseURL}), ControllerArgs{})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *legacySuite) TestMAASForController(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	maas, err := MAASForController(controller)
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := maas.GetSubObject("machines").CallGet("", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	nodes, err := result.GetArray()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(nodes, gc.HasLen, 3)
+}
+
+func (s *legacySuite) TestMachineRoundTrip(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	server.AddGetResponse("/MAAS/api/2.0/machines/4y3ha3/", http.StatusOK, machineResponse)
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+
+	object, err := MachineMAASObject(machines[0])
+	c.Assert(err, jc.ErrorIsNil)
+	systemID, err := object.GetField("system_id")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(systemID, gc.Equals, "4y3ha3")
+
+	machine, err := MachineFromMAASObject(controller, object)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(machine.Hostname(), gc.Equals, machines[0].Hostname())
+}
+
+func (s *legacySuite) TestMachineMAASObjectGone(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = MachineMAASObject(machines[0])
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
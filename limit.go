@@ -0,0 +1,100 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+)
+
+// The estimated memory, in bytes, that decoding each kind of JSON value
+// into an interface{} takes, not counting the bytes of strings. They are
+// rounded up from what the runtime allocates on 64 bit platforms.
+const (
+	decodedValueSize     = 16
+	decodedStringSize    = 32
+	decodedContainerSize = 64
+)
+
+// readLimited reads and closes the stream, failing with a TooLargeError if
+// there is more than limit bytes. A limit of zero or less reads it all.
+func readLimited(stream io.ReadCloser, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return readAndClose(stream)
+	}
+	if stream == nil {
+		return nil, nil
+	}
+	defer stream.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(stream, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, NewTooLargeError("response", int64(len(data)), limit)
+	}
+	return data, nil
+}
+
+// limitStream returns a reader of the stream that fails with a
+// TooLargeError once more than limit bytes have been read. A limit of zero
+// or less reads it all.
+func limitStream(stream io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return stream
+	}
+	return &limitedStream{stream: stream, limit: limit}
+}
+
+type limitedStream struct {
+	stream io.Reader
+	limit  int64
+	read   int64
+}
+
+func (s *limitedStream) Read(p []byte) (int, error) {
+	if s.read > s.limit {
+		return 0, NewTooLargeError("response", s.read, s.limit)
+	}
+	n, err := s.stream.Read(p)
+	s.read += int64(n)
+	if s.read > s.limit {
+		return n, NewTooLargeError("response", s.read, s.limit)
+	}
+	return n, err
+}
+
+// checkDecodeMemory estimates the memory that decoding the JSON document
+// takes, without decoding it, and fails with a TooLargeError if that is
+// more than the budget. A budget of zero or less accepts everything.
+// Documents that are not JSON are left for the decoder to reject.
+func checkDecodeMemory(data []byte, budget int64) error {
+	if budget <= 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var used int64
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil
+		}
+		switch token := token.(type) {
+		case json.Delim:
+			if token == '{' || token == '[' {
+				used += decodedContainerSize
+			}
+		case string:
+			used += decodedStringSize + int64(len(token))
+		default:
+			used += decodedValueSize
+		}
+		if used > budget {
+			return NewTooLargeError("decoded response", used, budget)
+		}
+	}
+}
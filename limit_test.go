@@ -0,0 +1,50 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"io/ioutil"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type limitSuite struct{}
+
+var _ = gc.Suite(&limitSuite{})
+
+func (*limitSuite) TestReadLimited(c *gc.C) {
+	data, err := readLimited(ioutil.NopCloser(strings.NewReader("0123456789")), 10)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(data), gc.Equals, "0123456789")
+
+	_, err = readLimited(ioutil.NopCloser(strings.NewReader("0123456789")), 9)
+	c.Check(err, jc.Satisfies, IsTooLargeError)
+	c.Check(err.(*TooLargeError).Size, gc.Equals, int64(10))
+	c.Check(err.(*TooLargeError).Limit, gc.Equals, int64(9))
+
+	data, err = readLimited(ioutil.NopCloser(strings.NewReader("0123456789")), 0)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(data), gc.Equals, "0123456789")
+}
+
+func (*limitSuite) TestCheckDecodeMemory(c *gc.C) {
+	// An array, two strings of one byte and a number.
+	document := []byte(`["a", "b", 1]`)
+	c.Check(checkDecodeMemory(document, 64+33+33+16), jc.ErrorIsNil)
+	err := checkDecodeMemory(document, 64+33+33+15)
+	c.Check(err, jc.Satisfies, IsTooLargeError)
+	c.Check(err, gc.ErrorMatches, "decoded response of at least 146 bytes exceeds the limit of 145 bytes")
+
+	c.Check(checkDecodeMemory(document, 0), jc.ErrorIsNil)
+	c.Check(checkDecodeMemory([]byte("not json"), 10), jc.ErrorIsNil)
+}
+
+func (*limitSuite) TestCheckDecodeMemoryObjectKeys(c *gc.C) {
+	// The keys are counted as strings.
+	err := checkDecodeMemory([]byte(`{"name": null}`), 64+36+15)
+	c.Check(err, jc.Satisfies, IsTooLargeError)
+	c.Check(checkDecodeMemory([]byte(`{"name": null}`), 64+36+16), jc.ErrorIsNil)
+}
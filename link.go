@@ -69,11 +69,11 @@ func readLinkList(sourceList []interface{}, readFunc linkDeserializationFunc) ([
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, NewDeserializationError("unexpected value for link %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for link %d, %T", i, value), indexPath(i))
 		}
 		link, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "link %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "link %d", i)
 		}
 		result = append(result, link)
 	}
@@ -110,7 +110,7 @@ func link_2_0(source map[string]interface{}) (*link, error) {
 	if value, ok := valid["subnet"]; ok {
 		subnet, err = subnet_2_0(value.(map[string]interface{}))
 		if err != nil {
-			return nil, errors.Trace(err)
+			return nil, errors.Trace(atPath(err, "subnet"))
 		}
 	}
 
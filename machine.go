@@ -4,10 +4,13 @@
 package gomaasapi
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 
+	"github.com/juju/collections/set"
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
@@ -15,35 +18,49 @@ import (
 
 type machine struct {
 	controller *controller
+	// generation is when the machine was read, see stale.go.
+	generation uint64
 
 	resourceURI string
 
 	systemID  string
 	hostname  string
 	fqdn      string
+	nodeType  NodeType
 	tags      []string
 	ownerData map[string]string
 
 	operatingSystem string
 	distroSeries    string
+	hweKernel       string
 	architecture    string
 	memory          int
 	cpuCount        int
 
 	ipAddresses []string
 	powerState  string
+	powerType   string
+
+	netboot         bool
+	ephemeralDeploy bool
+	locked          bool
 
 	// NOTE: consider some form of status struct
 	statusName    string
 	statusMessage string
+	owner         string
 
 	bootInterface *interface_
 	interfaceSet  []*interface_
 	zone          *zone
 	pool          *pool
+	domain        *domain
+	// virtualMachine is nil unless the machine was composed on a pod.
+	virtualMachine *virtualMachine
 	// Don't really know the difference between these two lists:
 	physicalBlockDevices []*blockdevice
 	blockDevices         []*blockdevice
+	numaNodes            []NUMANode
 }
 
 func (m *machine) updateFrom(other *machine) {
@@ -51,17 +68,29 @@ func (m *machine) updateFrom(other *machine) {
 	m.systemID = other.systemID
 	m.hostname = other.hostname
 	m.fqdn = other.fqdn
+	m.nodeType = other.nodeType
 	m.operatingSystem = other.operatingSystem
 	m.distroSeries = other.distroSeries
+	m.hweKernel = other.hweKernel
 	m.architecture = other.architecture
 	m.memory = other.memory
 	m.cpuCount = other.cpuCount
 	m.ipAddresses = other.ipAddresses
 	m.powerState = other.powerState
+	m.powerType = other.powerType
+	m.netboot = other.netboot
+	m.ephemeralDeploy = other.ephemeralDeploy
+	m.locked = other.locked
 	m.statusName = other.statusName
 	m.statusMessage = other.statusMessage
+	m.owner = other.owner
 	m.zone = other.zone
 	m.pool = other.pool
+	m.domain = other.domain
+	m.virtualMachine = other.virtualMachine
+	if m.virtualMachine != nil {
+		m.virtualMachine.controller = m.controller
+	}
 	m.tags = other.tags
 	m.ownerData = other.ownerData
 }
@@ -81,6 +110,11 @@ func (m *machine) FQDN() string {
 	return m.fqdn
 }
 
+// NodeType implements Machine.
+func (m *machine) NodeType() NodeType {
+	return m.nodeType
+}
+
 // Tags implements Machine.
 func (m *machine) Tags() []string {
 	return m.tags
@@ -91,9 +125,18 @@ func (m *machine) Pool() Pool {
 	if m.pool == nil {
 		return nil
 	}
+	m.pool.controller = m.controller
 	return m.pool
 }
 
+// VirtualMachine implements Machine.
+func (m *machine) VirtualMachine() VirtualMachine {
+	if m.virtualMachine == nil {
+		return nil
+	}
+	return m.virtualMachine
+}
+
 // IPAddresses implements Machine.
 func (m *machine) IPAddresses() []string {
 	return m.ipAddresses
@@ -114,6 +157,234 @@ func (m *machine) PowerState() string {
 	return m.powerState
 }
 
+// PowerType implements Machine.
+func (m *machine) PowerType() string {
+	return m.powerType
+}
+
+// PowerParameters implements Machine.
+func (m *machine) PowerParameters() (map[string]string, error) {
+	source, err := m.controller.getOp(m.resourceURI, "power_parameters")
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "power parameters schema check failed")
+	}
+	result := make(map[string]string)
+	for key, value := range coerced.(map[string]interface{}) {
+		if value != nil {
+			result[key] = fmt.Sprint(value)
+		}
+	}
+	return result, nil
+}
+
+// bmcPowerTypes are the power types that talk to a BMC, whose address is
+// the power_address power parameter.
+var bmcPowerTypes = set.NewStrings(
+	"amt",
+	"hmc",
+	"ipmi",
+	"moonshot",
+	"mscm",
+	"msftocs",
+	"redfish",
+	"ucsm",
+	"wedge",
+)
+
+// BMCAddress implements Machine.
+func (m *machine) BMCAddress() (string, error) {
+	if !bmcPowerTypes.Contains(m.powerType) {
+		return "", errors.NotSupportedf("BMC address for power type %q", m.powerType)
+	}
+	params, err := m.PowerParameters()
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	address := params["power_address"]
+	if address == "" {
+		return "", errors.NotFoundf("power_address for machine %q", m.systemID)
+	}
+	return bmcHost(address)
+}
+
+// bmcHost returns the host, and port if specified, from a BMC address. Redfish
+// addresses are often specified as a URL, and others may have a trailing path.
+func bmcHost(address string) (string, error) {
+	if strings.Contains(address, "://") {
+		parsed, err := url.Parse(address)
+		if err != nil {
+			return "", errors.NotValidf("BMC address %q", address)
+		}
+		return parsed.Host, nil
+	}
+	if i := strings.Index(address, "/"); i >= 0 {
+		address = address[:i]
+	}
+	return address, nil
+}
+
+// Netboot implements Machine.
+func (m *machine) Netboot() bool {
+	return m.netboot
+}
+
+// EphemeralDeploy implements Machine.
+func (m *machine) EphemeralDeploy() bool {
+	return m.ephemeralDeploy
+}
+
+// Locked implements Machine.
+func (m *machine) Locked() bool {
+	return m.locked
+}
+
+// MetadataClientArgs implements Machine.
+func (m *machine) MetadataClientArgs() (MetadataClientArgs, error) {
+	args, err := m.controller.metadataClientArgs(m.resourceURI)
+	return args, errors.Trace(err)
+}
+
+// MetadataClient implements Machine.
+func (m *machine) MetadataClient() (*MetadataClient, error) {
+	client, err := m.controller.metadataClient(m.resourceURI)
+	return client, errors.Trace(err)
+}
+
+// UserData implements Machine.
+func (m *machine) UserData() ([]byte, error) {
+	client, err := m.MetadataClient()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	data, err := client.UserData()
+	return data, errors.Trace(err)
+}
+
+// Preseed implements Machine.
+func (m *machine) Preseed() ([]byte, error) {
+	client, err := m.MetadataClient()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	data, err := client.Preseed()
+	return data, errors.Trace(err)
+}
+
+// CurtinConfig implements Machine.
+func (m *machine) CurtinConfig() ([]byte, error) {
+	data, err := m.controller._getRaw(m.resourceURI, "get_curtin_config", nil)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	return data, nil
+}
+
+// SetNetboot implements Machine.
+func (m *machine) SetNetboot(enabled bool) error {
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return errors.Trace(err)
+	}
+	op := "netboot_off"
+	if enabled {
+		op = "netboot_on"
+	}
+	result, err := m.controller.post(m.resourceURI, op, nil)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				if strings.HasPrefix(svrErr.BodyMessage, "Unrecognised signature") {
+					return errors.NewNotSupported(err, "setting netboot")
+				}
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			case http.StatusConflict:
+				return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+
+	machine, err := readMachine(m.controller.schemaVersion(), result)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
+// Lock implements Machine.
+func (m *machine) Lock(comment string) error {
+	return errors.Trace(m.setLocked("lock", comment))
+}
+
+// Unlock implements Machine.
+func (m *machine) Unlock(comment string) error {
+	return errors.Trace(m.setLocked("unlock", comment))
+}
+
+func (m *machine) setLocked(op, comment string) error {
+	if err := m.controller.requireVersion("machine locking", 2, 5); err != nil {
+		return errors.Trace(err)
+	}
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.MaybeAdd("comment", comment)
+	result, err := m.controller.post(m.resourceURI, op, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			case http.StatusConflict:
+				// The machine is not deployed, or is already locked or
+				// unlocked.
+				return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+
+	machine, err := readMachine(m.controller.schemaVersion(), result)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
 // Zone implements Machine.
 func (m *machine) Zone() Zone {
 	if m.zone == nil {
@@ -122,6 +393,17 @@ func (m *machine) Zone() Zone {
 	return m.zone
 }
 
+// Domain implements Machine.
+func (m *machine) Domain() Domain {
+	if m.domain == nil {
+		return nil
+	}
+	// The domain is read with the machine, so it picks up the controller
+	// when it is needed for Update.
+	m.domain.controller = m.controller
+	return m.domain
+}
+
 // BootInterface implements Machine.
 func (m *machine) BootInterface() Interface {
 	if m.bootInterface == nil {
@@ -162,6 +444,51 @@ func (m *machine) DistroSeries() string {
 	return m.distroSeries
 }
 
+// HWEKernel implements Machine.
+func (m *machine) HWEKernel() string {
+	return m.hweKernel
+}
+
+// DeployedOS implements Machine.
+func (m *machine) DeployedOS() DeployedOS {
+	return DeployedOS{
+		OperatingSystem: m.operatingSystem,
+		DistroSeries:    m.distroSeries,
+		Kernel:          m.hweKernel,
+	}
+}
+
+// DeployedOS describes the operating system installed on a machine, or the
+// operating system that a machine is expected to have.
+type DeployedOS struct {
+	OperatingSystem string
+	DistroSeries    string
+	Kernel          string
+}
+
+// Mismatches compares the deployed operating system with the desired one,
+// and returns the names of the fields that differ. Fields that are empty in
+// desired are not compared.
+func (o DeployedOS) Mismatches(desired DeployedOS) []string {
+	var result []string
+	if desired.OperatingSystem != "" && desired.OperatingSystem != o.OperatingSystem {
+		result = append(result, "OperatingSystem")
+	}
+	if desired.DistroSeries != "" && desired.DistroSeries != o.DistroSeries {
+		result = append(result, "DistroSeries")
+	}
+	if desired.Kernel != "" && desired.Kernel != o.Kernel {
+		result = append(result, "Kernel")
+	}
+	return result
+}
+
+// Matches returns true if the deployed operating system has all the non-empty
+// values of desired.
+func (o DeployedOS) Matches(desired DeployedOS) bool {
+	return len(o.Mismatches(desired)) == 0
+}
+
 // Architecture implements Machine.
 func (m *machine) Architecture() string {
 	return m.architecture
@@ -177,10 +504,16 @@ func (m *machine) StatusMessage() string {
 	return m.statusMessage
 }
 
+// Owner implements Machine.
+func (m *machine) Owner() string {
+	return m.owner
+}
+
 // PhysicalBlockDevices implements Machine.
 func (m *machine) PhysicalBlockDevices() []BlockDevice {
 	result := make([]BlockDevice, len(m.physicalBlockDevices))
 	for i, v := range m.physicalBlockDevices {
+		v.setController(m.controller)
 		result[i] = v
 	}
 	return result
@@ -195,6 +528,7 @@ func (m *machine) PhysicalBlockDevice(id int) BlockDevice {
 func (m *machine) BlockDevices() []BlockDevice {
 	result := make([]BlockDevice, len(m.blockDevices))
 	for i, v := range m.blockDevices {
+		v.setController(m.controller)
 		result[i] = v
 	}
 	return result
@@ -230,40 +564,186 @@ func partitionById(id int, blockDevices []BlockDevice) Partition {
 	return nil
 }
 
+// CreateBlockDevicePartition implements Machine.
+func (m *machine) CreateBlockDevicePartition(device BlockDevice, args CreatePartitionArgs) (Partition, error) {
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if device == nil {
+		return nil, errors.NotValidf("missing BlockDevice")
+	}
+	var target *blockdevice
+	for _, b := range m.blockDevices {
+		if b.id == device.ID() {
+			target = b
+		}
+	}
+	if target == nil {
+		return nil, errors.NotValidf("block device %q not of machine %s", device.Name(), m.systemID)
+	}
+	target.setController(m.controller)
+	created, err := target.createPartition(args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// The physical block devices are read separately from the others, so
+	// the new partition is added to the physical one too.
+	for _, b := range m.physicalBlockDevices {
+		if b.id == target.id {
+			b.partitions = append(b.partitions, created)
+		}
+	}
+	return created, nil
+}
+
 // Devices implements Machine.
 func (m *machine) Devices(args DevicesArgs) ([]Device, error) {
-	// Perhaps in the future, MAAS will give us a way to query just for the
-	// devices for a particular parent.
+	args.Parent = m.SystemID()
 	devices, err := m.controller.Devices(args)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	var result []Device
-	for _, device := range devices {
-		if device.Parent() == m.SystemID() {
-			result = append(result, device)
-		}
-	}
-	return result, nil
+	return devices, nil
 }
 
 // StartArgs is an argument struct for passing parameters to the Machine.Start
 // method.
 type StartArgs struct {
 	// UserData needs to be Base64 encoded user data for cloud-init.
-	UserData     string
+	UserData string
+	// RawUserData is user data for cloud-init, such as a #cloud-config
+	// document or a script, that is Base64 encoded when it is sent. Only
+	// one of UserData and RawUserData can be set.
+	RawUserData  []byte
 	DistroSeries string
-	Kernel       string
-	Comment      string
+	// Kernel is the hwe_kernel to deploy, such as "ga-20.04" or
+	// "hwe-20.04". The options of the kernel command line are not
+	// deploy options: they come from the tags of the machine, see
+	// Tag.KernelOpts.
+	Kernel  string
+	Comment string
+	// InstallKVM installs KVM on the machine and registers it as a pod.
+	// It needs MAAS 2.5 or later.
+	InstallKVM bool
+	// EphemeralDeploy runs the operating system in memory, leaving the
+	// disks alone. It needs MAAS 3.0 or later.
+	EphemeralDeploy bool
+	// EnableHWSync keeps the hardware details of the machine up to date
+	// while it is deployed. It needs MAAS 3.2 or later.
+	EnableHWSync bool
+	// EnableKernelCrashDump reserves memory on the deployed machine for
+	// a dump of the kernel if it crashes. It needs MAAS 3.5 or later.
+	EnableKernelCrashDump bool
+	// BridgeAll creates a bridge on each configured interface of the
+	// machine, so that containers on it can be attached to the networks
+	// of the machine. It needs MAAS 2.5 or later. The other bridge
+	// options can only be used with it.
+	BridgeAll bool
+	// BridgeType is BridgeTypeStandard or BridgeTypeOVS. The server
+	// makes standard bridges if it is empty.
+	BridgeType string
+	// BridgeSTP turns on the spanning tree protocol for the bridges.
+	BridgeSTP bool
+	// BridgeFD is the forward delay of the bridges in seconds. The
+	// server default is used if it is zero.
+	BridgeFD int
+	// Params are sent as they are, for deploy options that have no field
+	// here yet. They cannot repeat the options of the other fields.
+	Params map[string]string
+}
+
+// The bridge types of StartArgs.BridgeType.
+const (
+	BridgeTypeStandard = "standard"
+	BridgeTypeOVS      = "ovs"
+)
+
+// startParams are the deploy options set by the fields of StartArgs.
+var startParams = []string{
+	"user_data", "distro_series", "hwe_kernel", "comment", "install_kvm",
+	"ephemeral_deploy", "enable_hw_sync", "enable_kernel_crash_dump",
+	"bridge_all", "bridge_type", "bridge_stp", "bridge_fd",
+}
+
+// Validate ensures that the Params do not repeat the other options, that
+// only one form of user data is set, and that the bridge options are only
+// used with BridgeAll.
+func (a *StartArgs) Validate() error {
+	if a.UserData != "" && len(a.RawUserData) > 0 {
+		return errors.NotValidf("both UserData and RawUserData")
+	}
+	for _, name := range append(startParams, "op") {
+		if _, found := a.Params[name]; found {
+			return errors.NotValidf("Params with %q", name)
+		}
+	}
+	switch a.BridgeType {
+	case "", BridgeTypeStandard, BridgeTypeOVS:
+	default:
+		return errors.NotValidf("BridgeType %q", a.BridgeType)
+	}
+	if a.BridgeFD < 0 {
+		return errors.NotValidf("negative BridgeFD")
+	}
+	if !a.BridgeAll && (a.BridgeType != "" || a.BridgeSTP || a.BridgeFD != 0) {
+		return errors.NotValidf("bridge options without BridgeAll")
+	}
+	return nil
 }
 
 // Start implements Machine.
 func (m *machine) Start(args StartArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if args.EnableHWSync {
+		if err := m.controller.requireVersion("hardware sync", 3, 2); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if args.EphemeralDeploy {
+		if err := m.controller.requireVersion("ephemeral deploy", 3, 0); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if args.EnableKernelCrashDump {
+		if err := m.controller.requireVersion("kernel crash dump", 3, 5); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if args.BridgeAll {
+		if err := m.controller.requireVersion("bridging all interfaces", 2, 5); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if args.InstallKVM {
+		if err := m.controller.requireVersion("installing KVM", 2, 5); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return errors.Trace(err)
+	}
 	params := NewURLParams()
-	params.MaybeAdd("user_data", args.UserData)
+	userData := args.UserData
+	if len(args.RawUserData) > 0 {
+		userData = base64.StdEncoding.EncodeToString(args.RawUserData)
+	}
+	params.MaybeAdd("user_data", userData)
 	params.MaybeAdd("distro_series", args.DistroSeries)
 	params.MaybeAdd("hwe_kernel", args.Kernel)
 	params.MaybeAdd("comment", args.Comment)
+	params.MaybeAddBool("install_kvm", args.InstallKVM)
+	params.MaybeAddBool("ephemeral_deploy", args.EphemeralDeploy)
+	params.MaybeAddBool("enable_hw_sync", args.EnableHWSync)
+	params.MaybeAddBool("enable_kernel_crash_dump", args.EnableKernelCrashDump)
+	params.MaybeAddBool("bridge_all", args.BridgeAll)
+	params.MaybeAdd("bridge_type", args.BridgeType)
+	params.MaybeAddBool("bridge_stp", args.BridgeSTP)
+	params.MaybeAddInt("bridge_fd", args.BridgeFD)
+	for name, value := range args.Params {
+		params.Values.Add(name, value)
+	}
 	result, err := m.controller.post(m.resourceURI, "deploy", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
@@ -279,7 +759,7 @@ func (m *machine) Start(args StartArgs) error {
 		return NewUnexpectedError(err)
 	}
 
-	machine, err := readMachine(m.controller.apiVersion, result)
+	machine, err := readMachine(m.controller.schemaVersion(), result)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -322,6 +802,9 @@ func (a *CreateMachineDeviceArgs) Validate() error {
 
 // CreateDevice implements Machine
 func (m *machine) CreateDevice(args CreateMachineDeviceArgs) (_ Device, err error) {
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return nil, errors.Trace(err)
+	}
 	if err := args.Validate(); err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -338,7 +821,7 @@ func (m *machine) CreateDevice(args CreateMachineDeviceArgs) (_ Device, err erro
 		// If there is an error return, at least try to delete the device we just created.
 		if *err != nil {
 			if innerErr := device.Delete(); innerErr != nil {
-				logger.Warningf("could not delete device %q", device.SystemID())
+				controllerLogger.Warningf("could not delete device %q", device.SystemID())
 			}
 		}
 	}(&err)
@@ -415,6 +898,9 @@ func (m *machine) OwnerData() map[string]string {
 
 // SetOwnerData implements OwnerDataHolder.
 func (m *machine) SetOwnerData(ownerData map[string]string) error {
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return errors.Trace(err)
+	}
 	params := make(url.Values)
 	for key, value := range ownerData {
 		params.Add(key, value)
@@ -423,7 +909,7 @@ func (m *machine) SetOwnerData(ownerData map[string]string) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
-	machine, err := readMachine(m.controller.apiVersion, result)
+	machine, err := readMachine(m.controller.schemaVersion(), result)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -443,10 +929,10 @@ func readMachine(controllerVersion version.Number, source interface{}) (*machine
 		return nil, WrapWithDeserializationError(err, "machine base schema check failed")
 	}
 	valid := coerced.(map[string]interface{})
-	return readFunc(valid)
+	return readFunc(valid, strictReading)
 }
 
-func readMachines(controllerVersion version.Number, source interface{}) ([]*machine, error) {
+func readMachines(controllerVersion version.Number, source interface{}, options ...ReadOption) ([]*machine, error) {
 	readFunc, err := getMachineDeserializationFunc(controllerVersion)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -455,10 +941,14 @@ func readMachines(controllerVersion version.Number, source interface{}) ([]*mach
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, WrapWithDeserializationError(err, "machine base schema check failed")
+		return nil, atPath(WrapWithDeserializationError(err, "machine base schema check failed"), "machines")
 	}
 	valid := coerced.([]interface{})
-	return readMachineList(valid, readFunc)
+	result, err := readMachineList(valid, readFunc, collectReadOptions(options))
+	if err != nil {
+		return nil, atPath(err, "machines")
+	}
+	return result, nil
 }
 
 func getMachineDeserializationFunc(controllerVersion version.Number) (machineDeserializationFunc, error) {
@@ -474,132 +964,199 @@ func getMachineDeserializationFunc(controllerVersion version.Number) (machineDes
 	return machineDeserializationFuncs[deserialisationVersion], nil
 }
 
-func readMachineList(sourceList []interface{}, readFunc machineDeserializationFunc) ([]*machine, error) {
+func readMachineList(sourceList []interface{}, readFunc machineDeserializationFunc, options readOptions) ([]*machine, error) {
 	result := make([]*machine, 0, len(sourceList))
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, NewDeserializationError("unexpected value for machine %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for machine %d, %T", i, value), indexPath(i))
 		}
-		machine, err := readFunc(source)
-		if err != nil {
-			return nil, errors.Annotatef(err, "machine %d", i)
+		machine, err := readFunc(source, options)
+		if skip, err := options.item(err, "machine", i, indexPath(i)); err != nil {
+			return nil, err
+		} else if skip {
+			continue
 		}
 		result = append(result, machine)
 	}
 	return result, nil
 }
 
-type machineDeserializationFunc func(map[string]interface{}) (*machine, error)
+type machineDeserializationFunc func(map[string]interface{}, readOptions) (*machine, error)
 
 var machineDeserializationFuncs = map[version.Number]machineDeserializationFunc{
 	twoDotOh: machine_2_0,
 }
 
-func machine_2_0(source map[string]interface{}) (*machine, error) {
+func machine_2_0(source map[string]interface{}, options readOptions) (*machine, error) {
 	fields := schema.Fields{
 		"resource_uri": schema.String(),
 
 		"system_id":  schema.String(),
 		"hostname":   schema.String(),
 		"fqdn":       schema.String(),
+		"node_type":  schema.ForceInt(),
 		"tag_names":  schema.List(schema.String()),
 		"owner_data": schema.StringMap(schema.String()),
 
 		"osystem":       schema.String(),
 		"distro_series": schema.String(),
+		"hwe_kernel":    schema.OneOf(schema.Nil(""), schema.String()),
 		"architecture":  schema.OneOf(schema.Nil(""), schema.String()),
 		"memory":        schema.ForceInt(),
 		"cpu_count":     schema.ForceInt(),
 
 		"ip_addresses":   schema.List(schema.String()),
 		"power_state":    schema.String(),
+		"power_type":     schema.String(),
+		"netboot":        schema.Bool(),
 		"status_name":    schema.String(),
 		"status_message": schema.OneOf(schema.Nil(""), schema.String()),
+		"owner":          schema.OneOf(schema.Nil(""), schema.String()),
 
 		"boot_interface": schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
 		"interface_set":  schema.List(schema.StringMap(schema.Any())),
 		"zone":           schema.StringMap(schema.Any()),
-		"pool":           schema.OneOf(schema.Nil(""), schema.Any()),
+		"pool":           schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+		"domain":         schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+
+		"ephemeral_deploy": schema.Bool(),
+		"locked":           schema.Bool(),
+
+		"pod":               schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+		"virtualmachine_id": schema.OneOf(schema.Nil(""), schema.ForceInt()),
 
 		"physicalblockdevice_set": schema.List(schema.StringMap(schema.Any())),
 		"blockdevice_set":         schema.List(schema.StringMap(schema.Any())),
+		"numanode_set":            schema.List(schema.StringMap(schema.Any())),
 	}
 	defaults := schema.Defaults{
+		"node_type":    int(NodeTypeMachine),
+		"power_type":   "",
 		"architecture": "",
+		"hwe_kernel":   "",
+		"netboot":      false,
+		"owner":        "",
+		"domain":       nil,
+
+		"ephemeral_deploy": false,
+		// Servers before 2.5 cannot lock machines.
+		"locked": false,
+
+		"pod":               nil,
+		"virtualmachine_id": nil,
+
+		// Servers before 2.7 do not report NUMA nodes.
+		"numanode_set": []interface{}{},
 	}
 
-	checker := schema.FieldMap(fields, defaults)
-	coerced, err := checker.Coerce(source, nil)
+	valid, err := options.coerce("machine", fields, defaults, source)
 	if err != nil {
 		return nil, WrapWithDeserializationError(err, "machine 2.0 schema check failed")
 	}
-	valid := coerced.(map[string]interface{})
 	// From here we know that the map returned from the schema coercion
 	// contains fields of the right type.
 
 	var bootInterface *interface_
 	if ifaceMap, ok := valid["boot_interface"].(map[string]interface{}); ok {
 		bootInterface, err = interface_2_0(ifaceMap)
-		if err != nil {
-			return nil, errors.Trace(err)
+		if err = options.omit(err, "machine", "boot_interface"); err != nil {
+			return nil, errors.Trace(atPath(err, "boot_interface"))
 		}
 	}
 
 	interfaceSet, err := readInterfaceList(valid["interface_set"].([]interface{}), interface_2_0)
-	if err != nil {
-		return nil, errors.Trace(err)
+	if err = options.omit(err, "machine", "interface_set"); err != nil {
+		return nil, errors.Trace(atPath(err, "interface_set"))
 	}
 
 	zone, err := zone_2_0(valid["zone"].(map[string]interface{}))
-	if err != nil {
-		return nil, errors.Trace(err)
+	if err = options.omit(err, "machine", "zone"); err != nil {
+		return nil, errors.Trace(atPath(err, "zone"))
 	}
 
 	var pool *pool
 	if valid["pool"] != nil {
-		if pool, err = pool_2_0(valid["pool"].(map[string]interface{})); err != nil {
-			return nil, errors.Trace(err)
+		pool, err = pool_2_0(valid["pool"].(map[string]interface{}))
+		if err = options.omit(err, "machine", "pool"); err != nil {
+			return nil, errors.Trace(atPath(err, "pool"))
+		}
+	}
+
+	var domain *domain
+	if valid["domain"] != nil {
+		domain, err = domain_2_0(valid["domain"].(map[string]interface{}))
+		if err = options.omit(err, "machine", "domain"); err != nil {
+			return nil, errors.Trace(atPath(err, "domain"))
+		}
+	}
+
+	var virtualMachine *virtualMachine
+	if valid["pod"] != nil {
+		virtualMachine, err = virtualMachine_2_0(valid["pod"].(map[string]interface{}))
+		if err = options.omit(err, "machine", "pod"); err != nil {
+			return nil, errors.Trace(atPath(err, "pod"))
+		}
+		if virtualMachine != nil {
+			virtualMachine.id, _ = valid["virtualmachine_id"].(int)
 		}
 	}
 
 	physicalBlockDevices, err := readBlockDeviceList(valid["physicalblockdevice_set"].([]interface{}), blockdevice_2_0)
-	if err != nil {
-		return nil, errors.Trace(err)
+	if err = options.omit(err, "machine", "physicalblockdevice_set"); err != nil {
+		return nil, errors.Trace(atPath(err, "physicalblockdevice_set"))
 	}
 
 	blockDevices, err := readBlockDeviceList(valid["blockdevice_set"].([]interface{}), blockdevice_2_0)
-	if err != nil {
-		return nil, errors.Trace(err)
+	if err = options.omit(err, "machine", "blockdevice_set"); err != nil {
+		return nil, errors.Trace(atPath(err, "blockdevice_set"))
+	}
+
+	numaNodes, err := readNUMANodeList(valid["numanode_set"].([]interface{}))
+	if err = options.omit(err, "machine", "numanode_set"); err != nil {
+		return nil, errors.Trace(atPath(err, "numanode_set"))
 	}
 	architecture, _ := valid["architecture"].(string)
+	hweKernel, _ := valid["hwe_kernel"].(string)
 	statusMessage, _ := valid["status_message"].(string)
+	owner, _ := valid["owner"].(string)
 	result := &machine{
 		resourceURI: valid["resource_uri"].(string),
 
 		systemID:  valid["system_id"].(string),
 		hostname:  valid["hostname"].(string),
 		fqdn:      valid["fqdn"].(string),
+		nodeType:  NodeType(valid["node_type"].(int)),
 		tags:      convertToStringSlice(valid["tag_names"]),
 		ownerData: convertToStringMap(valid["owner_data"]),
 
 		operatingSystem: valid["osystem"].(string),
 		distroSeries:    valid["distro_series"].(string),
+		hweKernel:       hweKernel,
 		architecture:    architecture,
 		memory:          valid["memory"].(int),
 		cpuCount:        valid["cpu_count"].(int),
 
 		ipAddresses:   convertToStringSlice(valid["ip_addresses"]),
 		powerState:    valid["power_state"].(string),
+		powerType:     valid["power_type"].(string),
 		statusName:    valid["status_name"].(string),
 		statusMessage: statusMessage,
+		owner:         owner,
+
+		netboot:         valid["netboot"].(bool),
+		ephemeralDeploy: valid["ephemeral_deploy"].(bool),
+		locked:          valid["locked"].(bool),
 
 		bootInterface:        bootInterface,
 		interfaceSet:         interfaceSet,
 		zone:                 zone,
 		pool:                 pool,
+		domain:               domain,
+		virtualMachine:       virtualMachine,
 		physicalBlockDevices: physicalBlockDevices,
 		blockDevices:         blockDevices,
+		numaNodes:            numaNodes,
 	}
 
 	return result, nil
@@ -62,10 +62,18 @@ func (*machineSuite) TestReadMachines(c *gc.C) {
 	c.Check(machine.Memory(), gc.Equals, 1024)
 	c.Check(machine.CPUCount(), gc.Equals, 1)
 	c.Check(machine.PowerState(), gc.Equals, "on")
+	c.Check(machine.PowerType(), gc.Equals, "virsh")
 	c.Check(machine.Zone().Name(), gc.Equals, "default")
 	c.Check(machine.Pool().Name(), gc.Equals, "default")
+	c.Check(machine.Domain().Name(), gc.Equals, "maas")
+	c.Check(machine.Domain().ID(), gc.Equals, 0)
+	c.Check(machine.Domain().Authoritative(), jc.IsTrue)
 	c.Check(machine.OperatingSystem(), gc.Equals, "ubuntu")
 	c.Check(machine.DistroSeries(), gc.Equals, "trusty")
+	c.Check(machine.HWEKernel(), gc.Equals, "hwe-t")
+	c.Check(machine.NodeType(), gc.Equals, NodeTypeMachine)
+	c.Check(machine.Netboot(), jc.IsFalse)
+	c.Check(machine.EphemeralDeploy(), jc.IsFalse)
 	c.Check(machine.Architecture(), gc.Equals, "amd64/generic")
 	c.Check(machine.StatusName(), gc.Equals, "Deployed")
 	c.Check(machine.StatusMessage(), gc.Equals, "From 'Deploying' to 'Deployed'")
@@ -107,6 +115,7 @@ func (*machineSuite) TestReadMachinesNilValues(c *gc.C) {
 	data["status_message"] = nil
 	data["boot_interface"] = nil
 	data["pool"] = nil
+	data["hwe_kernel"] = nil
 	machines, err := readMachines(twoDotOh, json)
 	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(machines, gc.HasLen, 3)
@@ -115,6 +124,62 @@ func (*machineSuite) TestReadMachinesNilValues(c *gc.C) {
 	c.Check(machine.StatusMessage(), gc.Equals, "")
 	c.Check(machine.BootInterface(), gc.IsNil)
 	c.Check(machine.Pool(), gc.IsNil)
+	c.Check(machine.HWEKernel(), gc.Equals, "")
+}
+
+func (*machineSuite) TestReadMachinesNotStrict(c *gc.C) {
+	json := parseJSON(c, machinesResponse)
+	data := json.([]interface{})[1].(map[string]interface{})
+	delete(data, "hostname")
+
+	_, err := readMachines(twoDotOh, json)
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `machine 1: machine 2.0 schema check failed: .*`)
+
+	machines, err := readMachines(twoDotOh, json, WithStrictParsing(false))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+	c.Check(machines[1].SystemID(), gc.Equals, "4y3ha4")
+	c.Check(machines[1].Hostname(), gc.Equals, "")
+}
+
+func (*machineSuite) TestReadMachinesNotStrictWrongTypes(c *gc.C) {
+	json := parseJSON(c, machinesResponse)
+	data := json.([]interface{})[0].(map[string]interface{})
+	data["memory"] = "lots"
+	data["netboot"] = "yes"
+	data["zone"] = map[string]interface{}{"name": 42}
+
+	_, err := readMachines(twoDotOh, json)
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+
+	machines, err := readMachines(twoDotOh, json, WithStrictParsing(false))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+	machine := machines[0]
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(machine.Memory(), gc.Equals, 0)
+	c.Check(machine.Netboot(), jc.IsFalse)
+	c.Check(machine.Zone(), gc.IsNil)
+	c.Check(machine.CPUCount(), gc.Equals, 1)
+}
+
+func (*machineSuite) TestDeployedOS(c *gc.C) {
+	machines, err := readMachines(twoDotOh, parseJSON(c, machinesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	deployed := machines[0].DeployedOS()
+	c.Check(deployed, gc.Equals, DeployedOS{
+		OperatingSystem: "ubuntu",
+		DistroSeries:    "trusty",
+		Kernel:          "hwe-t",
+	})
+	c.Check(deployed.Matches(DeployedOS{DistroSeries: "trusty"}), jc.IsTrue)
+	c.Check(deployed.Matches(DeployedOS{}), jc.IsTrue)
+	c.Check(deployed.Mismatches(DeployedOS{
+		OperatingSystem: "ubuntu",
+		DistroSeries:    "xenial",
+		Kernel:          "hwe-x",
+	}), jc.DeepEquals, []string{"DistroSeries", "Kernel"})
 }
 
 func (*machineSuite) TestLowVersion(c *gc.C) {
@@ -141,6 +206,252 @@ func (s *machineSuite) getServerAndMachine(c *gc.C) (*SimpleTestServer, *machine
 	return server, machine
 }
 
+func (s *machineSuite) TestVirtualMachineNotComposed(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	c.Check(machine.VirtualMachine(), gc.IsNil)
+}
+
+func (s *machineSuite) getServerAndComposedMachine(c *gc.C) (*SimpleTestServer, Machine) {
+	server, controller := createTestServerController(c, s)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"pod":               map[string]interface{}{"id": 1, "name": "big-iron", "resource_uri": "/MAAS/api/2.0/pods/1/"},
+		"virtualmachine_id": 7,
+	})
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+response+"]")
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	return server, machines[0]
+}
+
+func (s *machineSuite) TestVirtualMachine(c *gc.C) {
+	server, machine := s.getServerAndComposedMachine(c)
+	vm := machine.VirtualMachine()
+	c.Assert(vm, gc.NotNil)
+	c.Check(vm.ID(), gc.Equals, 7)
+	c.Check(vm.PodID(), gc.Equals, 1)
+	c.Check(vm.PodName(), gc.Equals, "big-iron")
+
+	server.AddGetResponse("/api/2.0/pods/1/", http.StatusOK, podResponse)
+	pod, err := vm.Pod()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(pod.Name(), gc.Equals, "big-iron")
+	c.Check(pod.Total().Cores, gc.Equals, 16)
+}
+
+func (s *machineSuite) TestVirtualMachinePodGone(c *gc.C) {
+	server, machine := s.getServerAndComposedMachine(c)
+	server.AddGetResponse("/api/2.0/pods/1/", http.StatusNotFound, "No Pod matches the given query.")
+	_, err := machine.VirtualMachine().Pod()
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *machineSuite) TestSetNetboot(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"netboot": true,
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=netboot_on", http.StatusOK, response)
+	server.AddPostResponse(machine.resourceURI+"?op=netboot_off", http.StatusOK, machineResponse)
+
+	err := machine.SetNetboot(true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.Netboot(), jc.IsTrue)
+
+	err = machine.SetNetboot(false)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.Netboot(), jc.IsFalse)
+}
+
+func (s *machineSuite) TestSetNetbootNotSupported(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=netboot_on", http.StatusBadRequest,
+		"Unrecognised signature: method=POST op=netboot_on")
+	err := machine.SetNetboot(true)
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *machineSuite) TestReleaseMakesStale(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+	err := machine.controller.ReleaseMachines(ReleaseMachinesArgs{SystemIDs: []string{machine.SystemID()}})
+	c.Assert(err, jc.ErrorIsNil)
+	server.ResetRequests()
+
+	err = machine.SetNetboot(true)
+	c.Check(err, jc.Satisfies, IsStaleObjectError)
+	c.Check(err, gc.ErrorMatches, "4y3ha3 was released and is stale")
+	err = machine.SetOwnerData(map[string]string{"a": "b"})
+	c.Check(err, jc.Satisfies, IsStaleObjectError)
+	err = machine.Start(StartArgs{})
+	c.Check(err, jc.Satisfies, IsStaleObjectError)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+
+	// The machine read again is not stale.
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	machines, err := machine.controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	server.AddPostResponse(machine.resourceURI+"?op=netboot_on", http.StatusOK, machineResponse)
+	err = machines[0].SetNetboot(true)
+	c.Check(err, jc.ErrorIsNil)
+}
+
+func (s *machineSuite) TestSetNetbootForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=netboot_on", http.StatusForbidden, "admins only")
+	err := machine.SetNetboot(true)
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(err.Error(), gc.Equals, "admins only")
+}
+
+func (s *machineSuite) TestLock(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	c.Check(machine.Locked(), jc.IsFalse)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"locked": true,
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=lock", http.StatusOK, response)
+	server.AddPostResponse(machine.resourceURI+"?op=unlock", http.StatusOK, machineResponse)
+
+	err := machine.Lock("production")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.Locked(), jc.IsTrue)
+	c.Check(server.LastRequest().PostForm.Get("comment"), gc.Equals, "production")
+
+	err = machine.Unlock("")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.Locked(), jc.IsFalse)
+	c.Check(server.LastRequest().PostForm, gc.HasLen, 0)
+}
+
+func (s *machineSuite) TestLockNotDeployed(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=lock", http.StatusConflict, "Cannot lock machine because it is not deployed.")
+	err := machine.Lock("")
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err.Error(), gc.Equals, "Cannot lock machine because it is not deployed.")
+}
+
+func (s *machineSuite) TestLockOldServer(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.serverVersion = version.MustParse("2.4.2")
+	count := server.RequestCount()
+	err := machine.Lock("")
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+	c.Check(err, gc.ErrorMatches, "machine locking needs MAAS 2.5 or later, the server is 2.4.2")
+	c.Check(server.RequestCount(), gc.Equals, count)
+}
+
+const nodeTokenResponse = `{"consumer_key": "ck", "token_key": "tk", "token_secret": "ts"}`
+
+func (s *machineSuite) TestUserData(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_token", http.StatusOK, nodeTokenResponse)
+	server.AddGetResponse("/metadata/latest/user-data", http.StatusOK, "#cloud-config\n")
+	data, err := machine.UserData()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(data), gc.Equals, "#cloud-config\n")
+
+	requests := server.LastNRequests(2)
+	c.Assert(requests, gc.HasLen, 2)
+	auth := requests[1].Header.Get("Authorization")
+	c.Check(auth, jc.Contains, `oauth_consumer_key="ck"`)
+	c.Check(auth, jc.Contains, `oauth_token="tk"`)
+	c.Check(auth, jc.Contains, `oauth_signature="%26ts"`)
+}
+
+func (s *machineSuite) TestUserDataMissing(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_token", http.StatusOK, nodeTokenResponse)
+	server.AddGetResponse("/metadata/latest/user-data", http.StatusNotFound, "Not found")
+	_, err := machine.UserData()
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err.Error(), gc.Equals, "no user data")
+}
+
+func (s *machineSuite) TestUserDataForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_token", http.StatusForbidden, "admins only")
+	_, err := machine.UserData()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(err.Error(), gc.Equals, "admins only")
+	c.Check(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *machineSuite) TestUserDataNoToken(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_token", http.StatusOK, "null")
+	_, err := machine.UserData()
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *machineSuite) TestMetadataClientArgs(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_token", http.StatusOK, nodeTokenResponse)
+	args, err := machine.MetadataClientArgs()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(args, jc.DeepEquals, MetadataClientArgs{
+		BaseURL: server.URL + "/",
+		Token:   "ck:tk:ts",
+	})
+
+	// The args give an agent the same identity as the machine.
+	client, err := NewMetadataClient(args)
+	c.Assert(err, jc.ErrorIsNil)
+	server.AddGetResponse("/metadata/latest/user-data", http.StatusOK, "#cloud-config\n")
+	data, err := client.UserData()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(data), gc.Equals, "#cloud-config\n")
+	auth := server.LastRequest().Header.Get("Authorization")
+	c.Check(auth, jc.Contains, `oauth_token="tk"`)
+}
+
+func (s *machineSuite) TestMetadataClientSharesHTTPSettings(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_token", http.StatusOK, nodeTokenResponse)
+	controller := machine.controller
+	controller.client.HTTPClient = &http.Client{}
+	controller.client.TLSServerName = "maas.internal"
+	client, err := machine.MetadataClient()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(client.client.HTTPClient, gc.Equals, controller.client.HTTPClient)
+	c.Check(client.client.TLSServerName, gc.Equals, "maas.internal")
+	c.Check(client.client.httpClients, gc.NotNil)
+	c.Check(client.client.httpClients, gc.Equals, controller.client.httpClients)
+}
+
+func (s *machineSuite) TestPreseed(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_token", http.StatusOK, nodeTokenResponse)
+	server.AddGetResponse("/metadata/latest/?op=get_preseed", http.StatusOK, "#cloud-config\n")
+	data, err := machine.Preseed()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(data), gc.Equals, "#cloud-config\n")
+}
+
+func (s *machineSuite) TestCurtinConfig(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_curtin_config", http.StatusOK, "debconf_selections: {}\n")
+	data, err := machine.CurtinConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(data), gc.Equals, "debconf_selections: {}\n")
+}
+
+func (s *machineSuite) TestCurtinConfigWrongState(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_curtin_config", http.StatusBadRequest,
+		"Failed to retrieve curtin config: Node must be deploying or deployed.")
+	_, err := machine.CurtinConfig()
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *machineSuite) TestCurtinConfigForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_curtin_config", http.StatusForbidden, "admins only")
+	_, err := machine.CurtinConfig()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
 func (s *machineSuite) TestStart(c *gc.C) {
 	server, machine := s.getServerAndMachine(c)
 	response := updateJSONMap(c, machineResponse, map[string]interface{}{
@@ -169,6 +480,151 @@ func (s *machineSuite) TestStart(c *gc.C) {
 	c.Check(form.Get("comment"), gc.Equals, "a comment")
 }
 
+func (s *machineSuite) TestStartRawUserData(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusOK, machineResponse)
+
+	err := machine.Start(StartArgs{
+		RawUserData: []byte("#cloud-config\npackages: [jq]\n"),
+		InstallKVM:  true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 2)
+	c.Check(form.Get("user_data"), gc.Equals, "I2Nsb3VkLWNvbmZpZwpwYWNrYWdlczogW2pxXQo=")
+	c.Check(form.Get("install_kvm"), gc.Equals, "true")
+}
+
+func (s *machineSuite) TestStartValidatesUserData(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	err := machine.Start(StartArgs{UserData: "dXNlcmRhdGE=", RawUserData: []byte("userdata")})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, "both UserData and RawUserData not valid")
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestStartInstallKVMTooOld(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.serverVersion = version.MustParse("2.4.2")
+	err := machine.Start(StartArgs{InstallKVM: true})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Check(err.Error(), gc.Equals, "installing KVM needs MAAS 2.5 or later, the server is 2.4.2")
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestStartNewerOptions(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.serverVersion = version.MustParse("3.5.0")
+	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusOK, machineResponse)
+
+	err := machine.Start(StartArgs{
+		EnableHWSync:          true,
+		EnableKernelCrashDump: true,
+		Params:                map[string]string{"register_vmhost": "true"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 3)
+	c.Check(form.Get("enable_hw_sync"), gc.Equals, "true")
+	c.Check(form.Get("enable_kernel_crash_dump"), gc.Equals, "true")
+	c.Check(form.Get("register_vmhost"), gc.Equals, "true")
+}
+
+func (s *machineSuite) TestStartOptionTooNew(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.serverVersion = version.MustParse("3.4.2")
+	err := machine.Start(StartArgs{EnableKernelCrashDump: true})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Check(err.Error(), gc.Equals, "kernel crash dump needs MAAS 3.5 or later, the server is 3.4.2")
+	c.Check(server.RequestCount(), gc.Equals, 0)
+
+	// The option is sent when the server version is not known.
+	machine.controller.serverVersion = version.Zero
+	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusOK, machineResponse)
+	err = machine.Start(StartArgs{EnableKernelCrashDump: true})
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *machineSuite) TestStartValidatesParams(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	err := machine.Start(StartArgs{Params: map[string]string{"distro_series": "focal"}})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, `Params with "distro_series" not valid`)
+}
+
+func (s *machineSuite) TestStartBridgeAll(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusOK, machineResponse)
+	err := machine.Start(StartArgs{
+		BridgeAll:  true,
+		BridgeType: BridgeTypeOVS,
+		BridgeSTP:  true,
+		BridgeFD:   15,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 4)
+	c.Check(form.Get("bridge_all"), gc.Equals, "true")
+	c.Check(form.Get("bridge_type"), gc.Equals, "ovs")
+	c.Check(form.Get("bridge_stp"), gc.Equals, "true")
+	c.Check(form.Get("bridge_fd"), gc.Equals, "15")
+
+	machine.controller.serverVersion = version.MustParse("2.4.2")
+	err = machine.Start(StartArgs{BridgeAll: true})
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+	c.Check(err.Error(), gc.Equals, "bridging all interfaces needs MAAS 2.5 or later, the server is 2.4.2")
+}
+
+func (s *machineSuite) TestStartValidatesBridgeOptions(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	for _, test := range []struct {
+		args    StartArgs
+		message string
+	}{{
+		args:    StartArgs{BridgeAll: true, BridgeType: "linux"},
+		message: `BridgeType "linux" not valid`,
+	}, {
+		args:    StartArgs{BridgeAll: true, BridgeFD: -1},
+		message: "negative BridgeFD not valid",
+	}, {
+		args:    StartArgs{BridgeSTP: true},
+		message: "bridge options without BridgeAll not valid",
+	}, {
+		args:    StartArgs{Params: map[string]string{"bridge_all": "true"}},
+		message: `Params with "bridge_all" not valid`,
+	}} {
+		err := machine.Start(test.args)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (s *machineSuite) TestStartEphemeral(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"status_name":      "Deploying",
+		"ephemeral_deploy": true,
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusOK, response)
+
+	err := machine.Start(StartArgs{EphemeralDeploy: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.EphemeralDeploy(), jc.IsTrue)
+	c.Check(server.LastRequest().PostForm.Get("ephemeral_deploy"), gc.Equals, "true")
+}
+
+func (s *machineSuite) TestStartEphemeralNotSupported(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.serverVersion = version.MustParse("2.9.2")
+
+	err := machine.Start(StartArgs{EphemeralDeploy: true})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Check(err, gc.ErrorMatches, "ephemeral deploy needs MAAS 3.0 or later, the server is 2.9.2")
+	// The machine is not deployed to disk instead.
+	c.Check(server.RequestCount(), gc.Equals, 0)
+	c.Check(machine.StatusName(), gc.Equals, "Deployed")
+}
+
 func (s *machineSuite) TestStartMachineNotFound(c *gc.C) {
 	server, machine := s.getServerAndMachine(c)
 	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusNotFound, "can't find machine")
@@ -209,9 +665,77 @@ func (s *machineSuite) TestStartMachineUnknown(c *gc.C) {
 	c.Assert(err.Error(), gc.Equals, "unexpected: ServerError: 405 Method Not Allowed (wat?)")
 }
 
+func (s *machineSuite) TestPowerParameters(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=power_parameters", http.StatusOK, `{
+		"power_address": "qemu+ssh://ubuntu@10.0.0.1/system",
+		"power_id": "vm1",
+		"power_pass": null,
+		"power_port": 22
+	}`)
+	params, err := machine.PowerParameters()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(params, jc.DeepEquals, map[string]string{
+		"power_address": "qemu+ssh://ubuntu@10.0.0.1/system",
+		"power_id":      "vm1",
+		"power_port":    "22",
+	})
+}
+
+func (s *machineSuite) TestPowerParametersForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=power_parameters", http.StatusForbidden, "admins only")
+	_, err := machine.PowerParameters()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *machineSuite) TestBMCAddress(c *gc.C) {
+	for i, test := range []struct {
+		powerType string
+		address   string
+		expected  string
+	}{{
+		powerType: "ipmi",
+		address:   "10.0.0.5",
+		expected:  "10.0.0.5",
+	}, {
+		powerType: "redfish",
+		address:   "https://10.0.0.6:8443/redfish/v1",
+		expected:  "10.0.0.6:8443",
+	}, {
+		powerType: "moonshot",
+		address:   "10.0.0.7/c1n1",
+		expected:  "10.0.0.7",
+	}} {
+		c.Logf("test %d", i)
+		server, machine := s.getServerAndMachine(c)
+		machine.powerType = test.powerType
+		server.AddGetResponse(machine.resourceURI+"?op=power_parameters", http.StatusOK,
+			fmt.Sprintf(`{"power_address": %q}`, test.address))
+		address, err := machine.BMCAddress()
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(address, gc.Equals, test.expected)
+	}
+}
+
+func (s *machineSuite) TestBMCAddressNotSupported(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	machine.powerType = "manual"
+	_, err := machine.BMCAddress()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *machineSuite) TestBMCAddressMissing(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.powerType = "ipmi"
+	server.AddGetResponse(machine.resourceURI+"?op=power_parameters", http.StatusOK, `{}`)
+	_, err := machine.BMCAddress()
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
 func (s *machineSuite) TestDevices(c *gc.C) {
 	server, machine := s.getServerAndMachine(c)
-	server.AddGetResponse("/api/2.0/devices/", http.StatusOK, devicesResponse)
+	server.AddGetResponse("/api/2.0/devices/?parent=4y3ha3", http.StatusOK, devicesResponse)
 	devices, err := machine.Devices(DevicesArgs{})
 	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(devices, gc.HasLen, 1)
@@ -223,12 +747,64 @@ func (s *machineSuite) TestDevicesNone(c *gc.C) {
 	response := updateJSONMap(c, deviceResponse, map[string]interface{}{
 		"parent": "other",
 	})
-	server.AddGetResponse("/api/2.0/devices/", http.StatusOK, "["+response+"]")
+	server.AddGetResponse("/api/2.0/devices/?parent=4y3ha3", http.StatusOK, "["+response+"]")
 	devices, err := machine.Devices(DevicesArgs{})
 	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(devices, gc.HasLen, 0)
 }
 
+func (s *machineSuite) TestNodeDevices(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/nodes/4y3ha3/devices/?hardware_type=5", http.StatusOK, nodeDevicesResponse)
+	devices, err := machine.NodeDevices(NodeDevicesArgs{HardwareTypes: []HardwareType{HardwareTypeGPU}})
+	c.Assert(err, jc.ErrorIsNil)
+	// The server response is filtered again locally.
+	c.Assert(devices, gc.HasLen, 1)
+	c.Check(devices[0].ProductName(), gc.Equals, "TU104GL [Tesla T4]")
+}
+
+func (s *machineSuite) TestNodeDevicesVendor(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/nodes/4y3ha3/devices/?vendor_id=8086", http.StatusOK, nodeDevicesResponse)
+	devices, err := machine.NodeDevices(NodeDevicesArgs{VendorID: "8086"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 1)
+	c.Check(devices[0].CommissioningDriver(), gc.Equals, "igb")
+}
+
+func (s *machineSuite) TestNodeDevicesNotSupported(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/nodes/4y3ha3/devices/", http.StatusNotFound, "Unknown API endpoint: /MAAS/api/2.0/nodes/4y3ha3/devices/.")
+	_, err := machine.NodeDevices(NodeDevicesArgs{})
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *machineSuite) TestNodeDevicesNotFound(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/nodes/4y3ha3/devices/", http.StatusNotFound, "No Node matches the given query.")
+	_, err := machine.NodeDevices(NodeDevicesArgs{})
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *machineSuite) TestScriptResults(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/nodes/4y3ha3/results/?include_output=true&type=release", http.StatusOK, scriptResultsResponse)
+	sets, err := machine.ScriptResults(ScriptResultsArgs{Type: ScriptResultRelease, IncludeOutput: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(sets, gc.HasLen, 1)
+	c.Check(sets[0].Results()[0].Name(), gc.Equals, "wipe-disks")
+}
+
+func (s *machineSuite) TestScriptResultsErrors(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/nodes/4y3ha3/results/", http.StatusNotFound, "Unknown API endpoint: /MAAS/api/2.0/nodes/4y3ha3/results/.")
+	server.AddGetResponse("/api/2.0/nodes/4y3ha3/results/?type=release", http.StatusBadRequest, "Unknown type")
+	_, err := machine.ScriptResults(ScriptResultsArgs{})
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+	_, err = machine.ScriptResults(ScriptResultsArgs{Type: ScriptResultRelease})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
 func (s *machineSuite) TestCreateMachineDeviceArgsValidate(c *gc.C) {
 	for i, test := range []struct {
 		args    CreateMachineDeviceArgs
@@ -0,0 +1,198 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// CreateInterface implements Machine.
+func (m *machine) CreateInterface(args CreateInterfaceArgs) (Interface, error) {
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("name", args.Name)
+	params.Values.Add("mac_address", args.MACAddress)
+	params.Values.Add("vlan", fmt.Sprint(args.VLAN.ID()))
+	params.MaybeAdd("tags", strings.Join(args.Tags, ","))
+	params.MaybeAddInt("mtu", args.MTU)
+	params.MaybeAddBool("accept_ra", args.AcceptRA)
+	params.MaybeAddBool("autoconf", args.Autoconf)
+	iface, err := m.postInterface("create_physical", params)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	m.interfaceSet = append(m.interfaceSet, iface)
+	return iface, nil
+}
+
+// CreateBridgeArgs is an argument struct for Machine.CreateBridge. Name
+// and Parent are required.
+type CreateBridgeArgs struct {
+	// Name of the bridge, such as "br0".
+	Name string
+	// Parent is the interface of the machine that is bridged.
+	Parent Interface
+	// MACAddress of the bridge. It is that of the parent by default.
+	MACAddress string
+	// VLAN is the untagged VLAN of the bridge. It is that of the parent by
+	// default.
+	VLAN VLAN
+	// Tags to attach to the bridge.
+	Tags []string
+	// MTU - Maximum transmission unit.
+	MTU int
+	// Type is BridgeTypeStandard or BridgeTypeOVS. The server makes a
+	// standard bridge if it is empty.
+	Type string
+	// STP turns on the spanning tree protocol.
+	STP bool
+	// FD is the forward delay in seconds. The server default is used if
+	// it is zero.
+	FD int
+}
+
+// Validate ensures that the name and parent are set, and that the type is
+// known.
+func (a *CreateBridgeArgs) Validate() error {
+	if a.Name == "" {
+		return errors.NotValidf("missing Name")
+	}
+	if a.Parent == nil {
+		return errors.NotValidf("missing Parent")
+	}
+	switch a.Type {
+	case "", BridgeTypeStandard, BridgeTypeOVS:
+	default:
+		return errors.NotValidf("Type %q", a.Type)
+	}
+	if a.FD < 0 {
+		return errors.NotValidf("negative FD")
+	}
+	return nil
+}
+
+// CreateBridge implements Machine.
+func (m *machine) CreateBridge(args CreateBridgeArgs) (Interface, error) {
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := m.checkParents(args.Parent); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("name", args.Name)
+	params.Values.Add("parent", fmt.Sprint(args.Parent.ID()))
+	params.MaybeAdd("mac_address", args.MACAddress)
+	if args.VLAN != nil {
+		params.Values.Add("vlan", fmt.Sprint(args.VLAN.ID()))
+	}
+	params.MaybeAdd("tags", strings.Join(args.Tags, ","))
+	params.MaybeAddInt("mtu", args.MTU)
+	params.MaybeAdd("bridge_type", args.Type)
+	params.MaybeAddBool("bridge_stp", args.STP)
+	params.MaybeAddInt("bridge_fd", args.FD)
+	bridge, err := m.postInterface("create_bridge", params)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	m.interfaceSet = append(m.interfaceSet, bridge)
+	return bridge, nil
+}
+
+// CreateVLANInterfaceArgs is an argument struct for
+// Machine.CreateVLANInterface. Parent and VLAN are required.
+type CreateVLANInterfaceArgs struct {
+	// Parent is the interface of the machine that the VLAN is tagged on.
+	Parent Interface
+	// VLAN is the tagged VLAN. The server names the interface after the
+	// parent and the VID of the VLAN, such as "eth0.100".
+	VLAN VLAN
+	// Tags to attach to the interface.
+	Tags []string
+	// MTU - Maximum transmission unit. It cannot be more than the MTU of
+	// the parent.
+	MTU int
+}
+
+// Validate ensures that the parent and VLAN are set.
+func (a *CreateVLANInterfaceArgs) Validate() error {
+	if a.Parent == nil {
+		return errors.NotValidf("missing Parent")
+	}
+	if a.VLAN == nil {
+		return errors.NotValidf("missing VLAN")
+	}
+	return nil
+}
+
+// CreateVLANInterface implements Machine.
+func (m *machine) CreateVLANInterface(args CreateVLANInterfaceArgs) (Interface, error) {
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := m.checkParents(args.Parent); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("parent", fmt.Sprint(args.Parent.ID()))
+	params.Values.Add("vlan", fmt.Sprint(args.VLAN.ID()))
+	params.MaybeAdd("tags", strings.Join(args.Tags, ","))
+	params.MaybeAddInt("mtu", args.MTU)
+	iface, err := m.postInterface("create_vlan", params)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	m.interfaceSet = append(m.interfaceSet, iface)
+	return iface, nil
+}
+
+// checkParents ensures that the interfaces belong to the machine.
+func (m *machine) checkParents(parents ...Interface) error {
+	for _, parent := range parents {
+		if m.Interface(parent.ID()) == nil {
+			return errors.NotValidf("parent %q not an interface of machine %s", parent.Name(), m.systemID)
+		}
+	}
+	return nil
+}
+
+// postInterface creates an interface of the machine with the op of the
+// interfaces endpoint.
+func (m *machine) postInterface(op string, params *URLParams) (*interface_, error) {
+	source, err := m.controller.post(APIPath(NodesPath, m.systemID, "interfaces"), op, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound, http.StatusBadRequest, http.StatusConflict:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			case http.StatusServiceUnavailable:
+				return nil, errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	iface, err := readInterface(m.controller.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	iface.controller = m.controller
+	return iface, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *machineSuite) TestMachineCreateInterface(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/api/2.0/nodes/4y3ha3/interfaces/?op=create_physical", http.StatusOK, unlinkedInterfaceResponse(100, "eth2", "physical"))
+
+	iface, err := machine.CreateInterface(CreateInterfaceArgs{
+		Name:       "eth2",
+		MACAddress: "a4:bf:01:02:03:04",
+		VLAN:       &fakeVLAN{id: 5001},
+		Tags:       []string{"sriov"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Name(), gc.Equals, "eth2")
+	c.Check(machine.Interface(100), gc.NotNil)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("name"), gc.Equals, "eth2")
+	c.Check(form.Get("mac_address"), gc.Equals, "a4:bf:01:02:03:04")
+	c.Check(form.Get("vlan"), gc.Equals, "5001")
+	c.Check(form.Get("tags"), gc.Equals, "sriov")
+}
+
+func (s *machineSuite) TestMachineCreateInterfaceValidates(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	_, err := machine.CreateInterface(CreateInterfaceArgs{Name: "eth2"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestCreateBridgeArgsValidate(c *gc.C) {
+	parent := &interface_{id: 35}
+	for i, test := range []struct {
+		args    CreateBridgeArgs
+		errText string
+	}{{
+		args:    CreateBridgeArgs{Parent: parent},
+		errText: "missing Name not valid",
+	}, {
+		args:    CreateBridgeArgs{Name: "br0"},
+		errText: "missing Parent not valid",
+	}, {
+		args:    CreateBridgeArgs{Name: "br0", Parent: parent, Type: "linux"},
+		errText: `Type "linux" not valid`,
+	}, {
+		args:    CreateBridgeArgs{Name: "br0", Parent: parent, FD: -1},
+		errText: "negative FD not valid",
+	}, {
+		args: CreateBridgeArgs{Name: "br0", Parent: parent, Type: BridgeTypeOVS, STP: true, FD: 15},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *machineSuite) TestCreateBridge(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/api/2.0/nodes/4y3ha3/interfaces/?op=create_bridge", http.StatusOK, unlinkedInterfaceResponse(100, "br0", "bridge"))
+
+	bridge, err := machine.CreateBridge(CreateBridgeArgs{
+		Name:   "br0",
+		Parent: machine.Interface(35),
+		Type:   BridgeTypeOVS,
+		STP:    true,
+		FD:     15,
+		MTU:    9000,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(bridge.Type(), gc.Equals, "bridge")
+	c.Check(machine.Interface(100), gc.NotNil)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("name"), gc.Equals, "br0")
+	c.Check(form.Get("parent"), gc.Equals, "35")
+	c.Check(form.Get("bridge_type"), gc.Equals, "ovs")
+	c.Check(form.Get("bridge_stp"), gc.Equals, "true")
+	c.Check(form.Get("bridge_fd"), gc.Equals, "15")
+	c.Check(form.Get("mtu"), gc.Equals, "9000")
+	c.Check(form.Get("vlan"), gc.Equals, "")
+}
+
+func (s *machineSuite) TestCreateBridgeParentOfOtherMachine(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	_, err := machine.CreateBridge(CreateBridgeArgs{
+		Name:   "br0",
+		Parent: &interface_{id: 40, name: "eth0"},
+	})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestCreateBridgeServerError(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/api/2.0/nodes/4y3ha3/interfaces/?op=create_bridge", http.StatusForbidden, "not allowed")
+	_, err := machine.CreateBridge(CreateBridgeArgs{Name: "br0", Parent: machine.Interface(35)})
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(machine.InterfaceSet(), gc.HasLen, 2)
+}
+
+func (s *machineSuite) TestCreateVLANInterface(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/api/2.0/nodes/4y3ha3/interfaces/?op=create_vlan", http.StatusOK, unlinkedInterfaceResponse(100, "eth0.100", "vlan"))
+
+	iface, err := machine.CreateVLANInterface(CreateVLANInterfaceArgs{
+		Parent: machine.Interface(35),
+		VLAN:   &fakeVLAN{id: 5005},
+		MTU:    1400,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Name(), gc.Equals, "eth0.100")
+	c.Check(machine.Interface(100), gc.NotNil)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("parent"), gc.Equals, "35")
+	c.Check(form.Get("vlan"), gc.Equals, "5005")
+	c.Check(form.Get("mtu"), gc.Equals, "1400")
+}
+
+func (s *machineSuite) TestCreateVLANInterfaceValidates(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	_, err := machine.CreateVLANInterface(CreateVLANInterfaceArgs{Parent: machine.Interface(35)})
+	c.Check(err, gc.ErrorMatches, "missing VLAN not valid")
+	_, err = machine.CreateVLANInterface(CreateVLANInterfaceArgs{VLAN: &fakeVLAN{id: 5005}})
+	c.Check(err, gc.ErrorMatches, "missing Parent not valid")
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestCreateVLANInterfaceServerError(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/api/2.0/nodes/4y3ha3/interfaces/?op=create_vlan", http.StatusBadRequest, "vlan already in use")
+	_, err := machine.CreateVLANInterface(CreateVLANInterfaceArgs{
+		Parent: machine.Interface(35),
+		VLAN:   &fakeVLAN{id: 5005},
+	})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "vlan already in use")
+}
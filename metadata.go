@@ -0,0 +1,298 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/utils/clock"
+)
+
+// metadataRealm is the OAuth realm used by nodes when they talk to the
+// metadata service.
+const metadataRealm = "OAuth"
+
+// DefaultMetadataVersion is the metadata version used when
+// MetadataClientArgs.Version is empty.
+const DefaultMetadataVersion = "latest"
+
+// Signal status values that can be sent with MetadataClient.Signal.
+const (
+	SignalOK      = "OK"
+	SignalFailed  = "FAILED"
+	SignalWorking = "WORKING"
+)
+
+// MetadataClient talks to the MAAS metadata service with the credentials
+// of a single node, in the way the node itself does while it is enlisting,
+// commissioning or deploying. It is intended for tooling that inspects or
+// simulates that side of the conversation.
+type MetadataClient struct {
+	client  *Client
+	version string
+}
+
+// MetadataClientArgs is an argument struct for NewMetadataClient.
+type MetadataClientArgs struct {
+	// BaseURL is the root of the MAAS server, such as
+	// "http://maas.server/MAAS/".
+	BaseURL string
+
+	// Token holds the node's credentials in the same
+	// "<consumer key>:<token key>:<token secret>" form as an API key.
+	Token string
+
+	// Version is the metadata version to ask for. If it is empty,
+	// DefaultMetadataVersion is used.
+	Version string
+
+	// Clock is used to wait before retrying a request. If it is nil, the
+	// wall clock is used.
+	Clock clock.Clock
+}
+
+// Validate checks that the base URL and token are set, and that the token
+// has three parts.
+func (a *MetadataClientArgs) Validate() error {
+	if a.BaseURL == "" {
+		return errors.NotValidf("missing BaseURL")
+	}
+	if len(strings.Split(a.Token, ":")) != 3 {
+		return errors.NotValidf("token %q, expected \"<consumer key>:<token key>:<token secret>\"", a.Token)
+	}
+	return nil
+}
+
+// NewMetadataClient returns a MetadataClient for the node whose
+// credentials are in the args.
+func NewMetadataClient(args MetadataClientArgs) (*MetadataClient, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	parts := strings.Split(args.Token, ":")
+	metadataURL, err := url.Parse(EnsureTrailingSlash(args.BaseURL) + "metadata/")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return newMetadataClient(metadataURL, &nodeToken{
+		consumerKey: parts[0],
+		tokenKey:    parts[1],
+		tokenSecret: parts[2],
+	}, args.Version, &Client{Clock: args.Clock, httpClients: &httpClientCache{}})
+}
+
+// newMetadataClient returns a MetadataClient that makes its requests with
+// the clock and HTTP settings of the base client, and shares its
+// connections.
+func newMetadataClient(metadataURL *url.URL, token *nodeToken, version string, base *Client) (*MetadataClient, error) {
+	signer, err := NewPlainTestOAuthSigner(&OAuthToken{
+		ConsumerKey: token.consumerKey,
+		TokenKey:    token.tokenKey,
+		TokenSecret: token.tokenSecret,
+	}, metadataRealm)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if version == "" {
+		version = DefaultMetadataVersion
+	}
+	return &MetadataClient{
+		client: &Client{
+			APIURL:        metadataURL,
+			Signer:        signer,
+			Clock:         base.Clock,
+			HTTPClient:    base.HTTPClient,
+			TLSConfig:     base.TLSConfig,
+			TLSServerName: base.TLSServerName,
+			httpClients:   base.httpClients,
+		},
+		version: version,
+	}, nil
+}
+
+// UserData returns the user data the node runs when it boots. The error
+// satisfies IsNoMatchError if there is no user data.
+func (mc *MetadataClient) UserData() ([]byte, error) {
+	data, err := mc.get("user-data", "")
+	if IsNoMatchError(err) {
+		return nil, errors.Wrap(err, NewNoMatchError("no user data"))
+	}
+	return data, errors.Trace(err)
+}
+
+// MetaDataKeys returns the names of the meta-data items available to the
+// node, such as "instance-id" and "local-hostname".
+func (mc *MetadataClient) MetaDataKeys() ([]string, error) {
+	data, err := mc.get("meta-data/", "")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var keys []string
+	for _, key := range strings.Split(string(data), "\n") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// MetaData returns the value of the named meta-data item.
+func (mc *MetadataClient) MetaData(key string) (string, error) {
+	data, err := mc.get("meta-data/"+key, "")
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return string(data), nil
+}
+
+// Preseed returns the preseed that MAAS renders for the node in its
+// current state.
+func (mc *MetadataClient) Preseed() ([]byte, error) {
+	data, err := mc.get("", "get_preseed")
+	return data, errors.Trace(err)
+}
+
+// SignalArgs is an argument struct for MetadataClient.Signal.
+type SignalArgs struct {
+	// Status is one of the Signal* values.
+	Status string
+
+	// Error is an optional message describing the status.
+	Error string
+
+	// Files are uploaded with the signal, keyed by file name, as the
+	// commissioning scripts do with their output.
+	Files map[string][]byte
+}
+
+// Validate checks that the status is set.
+func (a *SignalArgs) Validate() error {
+	if a.Status == "" {
+		return errors.NotValidf("missing Status")
+	}
+	return nil
+}
+
+// Signal reports the node's progress to MAAS, as the node does at the
+// end of each stage of commissioning or deployment. This changes the
+// state of the node on the server.
+func (mc *MetadataClient) Signal(args SignalArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("status", args.Status)
+	params.MaybeAdd("error", args.Error)
+	_, err := mc.client.Post(mc.versionURL(""), "signal", params.Values, args.Files)
+	return errors.Trace(mapMetadataError(err))
+}
+
+func (mc *MetadataClient) versionURL(path string) *url.URL {
+	return &url.URL{Path: mc.version + "/" + path}
+}
+
+func (mc *MetadataClient) get(path, op string) ([]byte, error) {
+	data, err := mc.client.Get(mc.versionURL(path), op, nil)
+	if err != nil {
+		return nil, mapMetadataError(err)
+	}
+	return data, nil
+}
+
+func mapMetadataError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		case http.StatusConflict:
+			return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+// nodeToken holds the OAuth credentials a node uses to authenticate with
+// the metadata service.
+type nodeToken struct {
+	consumerKey string
+	tokenKey    string
+	tokenSecret string
+}
+
+// getNodeToken reads the metadata credentials of the node at the
+// resourceURI. Only admins can do this.
+func (c *controller) getNodeToken(resourceURI string) (*nodeToken, error) {
+	source, err := c.getOp(resourceURI, "get_token")
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	if source == nil {
+		return nil, NewNoMatchError("node has no metadata token")
+	}
+
+	fields := schema.Fields{
+		"consumer_key": schema.String(),
+		"token_key":    schema.String(),
+		"token_secret": schema.String(),
+	}
+	checker := schema.FieldMap(fields, nil) // no defaults
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "node token response")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+	return &nodeToken{
+		consumerKey: valid["consumer_key"].(string),
+		tokenKey:    valid["token_key"].(string),
+		tokenSecret: valid["token_secret"].(string),
+	}, nil
+}
+
+// metadataClient returns a MetadataClient for the node at the resourceURI.
+// The metadata service sits next to the API, so
+// "http://maas.server/MAAS/api/2.0/" becomes "http://maas.server/MAAS/metadata/".
+func (c *controller) metadataClient(resourceURI string) (*MetadataClient, error) {
+	token, err := c.getNodeToken(resourceURI)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	metadataURL := c.client.APIURL.ResolveReference(&url.URL{Path: "../../metadata/"})
+	return newMetadataClient(metadataURL, token, "", c.client)
+}
+
+// metadataClientArgs returns the args for NewMetadataClient with the
+// credentials of the node at the resourceURI, and the root of the server,
+// which is two levels above the API.
+func (c *controller) metadataClientArgs(resourceURI string) (MetadataClientArgs, error) {
+	token, err := c.getNodeToken(resourceURI)
+	if err != nil {
+		return MetadataClientArgs{}, errors.Trace(err)
+	}
+	baseURL := c.client.APIURL.ResolveReference(&url.URL{Path: "../../"})
+	return MetadataClientArgs{
+		BaseURL: baseURL.String(),
+		Token:   token.consumerKey + ":" + token.tokenKey + ":" + token.tokenSecret,
+	}, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type metadataSuite struct{}
+
+var _ = gc.Suite(&metadataSuite{})
+
+func (*metadataSuite) getServerAndClient(c *gc.C) (*SimpleTestServer, *MetadataClient) {
+	server := NewSimpleServer()
+	server.Start()
+	client, err := NewMetadataClient(MetadataClientArgs{
+		BaseURL: server.URL,
+		Token:   "ck:tk:ts",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, client
+}
+
+func (*metadataSuite) TestNewMetadataClientValidates(c *gc.C) {
+	_, err := NewMetadataClient(MetadataClientArgs{Token: "ck:tk:ts"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	_, err = NewMetadataClient(MetadataClientArgs{BaseURL: "http://maas.server/MAAS/", Token: "invalid"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *metadataSuite) TestUserData(c *gc.C) {
+	server, client := s.getServerAndClient(c)
+	defer server.Close()
+	server.AddGetResponse("/metadata/latest/user-data", http.StatusOK, "#!/bin/sh\n")
+	data, err := client.UserData()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(data), gc.Equals, "#!/bin/sh\n")
+}
+
+func (s *metadataSuite) TestVersion(c *gc.C) {
+	server := NewSimpleServer()
+	server.Start()
+	defer server.Close()
+	client, err := NewMetadataClient(MetadataClientArgs{
+		BaseURL: server.URL,
+		Token:   "ck:tk:ts",
+		Version: "2012-03-01",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	server.AddGetResponse("/metadata/2012-03-01/user-data", http.StatusOK, "data")
+	data, err := client.UserData()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(data), gc.Equals, "data")
+}
+
+func (s *metadataSuite) TestMetaData(c *gc.C) {
+	server, client := s.getServerAndClient(c)
+	defer server.Close()
+	server.AddGetResponse("/metadata/latest/meta-data/", http.StatusOK, "instance-id\nlocal-hostname\n")
+	server.AddGetResponse("/metadata/latest/meta-data/local-hostname", http.StatusOK, "untasted-markita")
+
+	keys, err := client.MetaDataKeys()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(keys, jc.DeepEquals, []string{"instance-id", "local-hostname"})
+
+	value, err := client.MetaData("local-hostname")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(value, gc.Equals, "untasted-markita")
+
+	_, err = client.MetaData("missing")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *metadataSuite) TestPreseed(c *gc.C) {
+	server, client := s.getServerAndClient(c)
+	defer server.Close()
+	server.AddGetResponse("/metadata/latest/?op=get_preseed", http.StatusOK, "preseed")
+	data, err := client.Preseed()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(data), gc.Equals, "preseed")
+}
+
+func (s *metadataSuite) TestUnauthorized(c *gc.C) {
+	server, client := s.getServerAndClient(c)
+	defer server.Close()
+	server.AddGetResponse("/metadata/latest/?op=get_preseed", http.StatusUnauthorized, "Authorization Required")
+	_, err := client.Preseed()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *metadataSuite) TestSignal(c *gc.C) {
+	server, client := s.getServerAndClient(c)
+	defer server.Close()
+	server.AddPostResponse("/metadata/latest/?op=signal", http.StatusOK, "OK")
+	err := client.Signal(SignalArgs{Status: SignalFailed, Error: "it broke"})
+	c.Assert(err, jc.ErrorIsNil)
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("status"), gc.Equals, "FAILED")
+	c.Check(form.Get("error"), gc.Equals, "it broke")
+}
+
+func (s *metadataSuite) TestSignalFiles(c *gc.C) {
+	server, client := s.getServerAndClient(c)
+	defer server.Close()
+	server.AddPostResponse("/metadata/latest/?op=signal", http.StatusOK, "OK")
+	err := client.Signal(SignalArgs{
+		Status: SignalOK,
+		Files:  map[string][]byte{"00-maas-01-lshw.out": []byte("<list/>")},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	request := server.LastRequest()
+	c.Check(request.MultipartForm.Value["status"], jc.DeepEquals, []string{"OK"})
+	file, err := request.MultipartForm.File["00-maas-01-lshw.out"][0].Open()
+	c.Assert(err, jc.ErrorIsNil)
+	content, err := ioutil.ReadAll(file)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, "<list/>")
+}
+
+func (s *metadataSuite) TestSignalConflict(c *gc.C) {
+	server, client := s.getServerAndClient(c)
+	defer server.Close()
+	server.AddPostResponse("/metadata/latest/?op=signal", http.StatusConflict, "Node wasn't commissioning")
+	err := client.Signal(SignalArgs{Status: SignalOK})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err.Error(), gc.Equals, "Node wasn't commissioning")
+}
+
+func (*metadataSuite) TestSignalArgsValidate(c *gc.C) {
+	args := SignalArgs{}
+	c.Check(args.Validate(), jc.Satisfies, errors.IsNotValid)
+}
@@ -0,0 +1,324 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type controllerNode struct {
+	controller *controller
+
+	resourceURI string
+
+	systemID string
+	hostname string
+	fqdn     string
+	nodeType NodeType
+	version  string
+
+	ipAddresses  []string
+	interfaceSet []*interface_
+	services     []ControllerService
+}
+
+// ControllerService is the status of a service on a controller node, as the
+// controller reports it.
+type ControllerService struct {
+	// Name is the name of the service, such as "rackd", "regiond" or
+	// "dhcpd".
+	Name string
+	// Status is "running", "degraded", "dead", "off" or "unknown". Off is
+	// the status of a service that is not needed, such as dhcpd when no
+	// VLAN served by the controller has DHCP enabled.
+	Status string
+	// StatusInfo explains a status other than running.
+	StatusInfo string
+}
+
+// Running returns true if the status of the service is "running".
+func (s ControllerService) Running() bool {
+	return s.Status == "running"
+}
+
+// SystemID implements ControllerNode.
+func (n *controllerNode) SystemID() string {
+	return n.systemID
+}
+
+// Hostname implements ControllerNode.
+func (n *controllerNode) Hostname() string {
+	return n.hostname
+}
+
+// FQDN implements ControllerNode.
+func (n *controllerNode) FQDN() string {
+	return n.fqdn
+}
+
+// NodeType implements ControllerNode.
+func (n *controllerNode) NodeType() NodeType {
+	return n.nodeType
+}
+
+// IPAddresses implements ControllerNode.
+func (n *controllerNode) IPAddresses() []string {
+	return n.ipAddresses
+}
+
+// Version implements ControllerNode.
+func (n *controllerNode) Version() string {
+	return n.version
+}
+
+// InterfaceSet implements ControllerNode.
+func (n *controllerNode) InterfaceSet() []Interface {
+	result := make([]Interface, len(n.interfaceSet))
+	for i, v := range n.interfaceSet {
+		v.controller = n.controller
+		result[i] = v
+	}
+	return result
+}
+
+// Services implements ControllerNode.
+func (n *controllerNode) Services() []ControllerService {
+	return n.services
+}
+
+// Service implements ControllerNode.
+func (n *controllerNode) Service(name string) (ControllerService, bool) {
+	for _, service := range n.services {
+		if service.Name == name {
+			return service, true
+		}
+	}
+	return ControllerService{}, false
+}
+
+// RackControllers implements Controller.
+func (c *controller) RackControllers() ([]ControllerNode, error) {
+	return c.controllerNodes(RackControllersPath)
+}
+
+// RegionControllers implements Controller.
+func (c *controller) RegionControllers() ([]ControllerNode, error) {
+	return c.controllerNodes(RegionControllersPath)
+}
+
+func (c *controller) controllerNodes(path string) ([]ControllerNode, error) {
+	source, err := c.get(path)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok && svrErr.StatusCode == http.StatusForbidden {
+			return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	nodes, err := readControllerNodes(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []ControllerNode
+	for _, n := range nodes {
+		n.controller = c
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func readControllerNodes(controllerVersion version.Number, source interface{}) ([]*controllerNode, error) {
+	readFunc, err := getControllerNodeDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "controller node base schema check failed")
+	}
+	valid := coerced.([]interface{})
+	return readControllerNodeList(valid, readFunc)
+}
+
+// readControllerNodeList expects the values of the sourceList to be string maps.
+func readControllerNodeList(sourceList []interface{}, readFunc controllerNodeDeserializationFunc) ([]*controllerNode, error) {
+	result := make([]*controllerNode, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for controller node %d, %T", i, value)
+		}
+		node, err := readFunc(source, strictReading)
+		if err != nil {
+			return nil, errors.Annotatef(err, "controller node %d", i)
+		}
+		result = append(result, node)
+	}
+	return result, nil
+}
+
+// readNodes reads the response of the nodes endpoint, using the node_type
+// of each element to pick the deserialization func to apply.
+func readNodes(controllerVersion version.Number, source interface{}, options ...ReadOption) ([]GenericNode, error) {
+	machineFunc, err := getMachineDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	deviceFunc, err := getDeviceDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	controllerNodeFunc, err := getControllerNodeDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "node base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	opts := collectReadOptions(options)
+	result := make([]GenericNode, 0, len(valid))
+	for i, value := range valid {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for node %d, %T", i, value)
+		}
+		node, err := readNode(source, opts, machineFunc, deviceFunc, controllerNodeFunc)
+		if skip, err := opts.item(err, "node", i, indexPath(i)); err != nil {
+			return nil, err
+		} else if skip {
+			continue
+		}
+		result = append(result, node)
+	}
+	return result, nil
+}
+
+func readNode(
+	source map[string]interface{},
+	options readOptions,
+	machineFunc machineDeserializationFunc,
+	deviceFunc deviceDeserializationFunc,
+	controllerNodeFunc controllerNodeDeserializationFunc,
+) (GenericNode, error) {
+	coerced, err := schema.ForceInt().Coerce(source["node_type"], []string{"node_type"})
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "node type check failed")
+	}
+	switch nodeType := NodeType(coerced.(int)); nodeType {
+	case NodeTypeMachine:
+		machine, err := machineFunc(source, options)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return machine, nil
+	case NodeTypeDevice:
+		device, err := deviceFunc(source, options)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return device, nil
+	case NodeTypeRackController, NodeTypeRegionController, NodeTypeRegionAndRackController:
+		node, err := controllerNodeFunc(source, options)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return node, nil
+	default:
+		return nil, NewDeserializationError("unknown node type %d", int(nodeType))
+	}
+}
+
+func getControllerNodeDeserializationFunc(controllerVersion version.Number) (controllerNodeDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range controllerNodeDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no controller node read func for version %s", controllerVersion)
+	}
+	return controllerNodeDeserializationFuncs[deserialisationVersion], nil
+}
+
+type controllerNodeDeserializationFunc func(map[string]interface{}, readOptions) (*controllerNode, error)
+
+var controllerNodeDeserializationFuncs = map[version.Number]controllerNodeDeserializationFunc{
+	twoDotOh: controllerNode_2_0,
+}
+
+func controllerNode_2_0(source map[string]interface{}, options readOptions) (*controllerNode, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+
+		"system_id": schema.String(),
+		"hostname":  schema.String(),
+		"fqdn":      schema.String(),
+		"node_type": schema.ForceInt(),
+		"version":   schema.OneOf(schema.Nil(""), schema.String()),
+
+		"ip_addresses":  schema.List(schema.String()),
+		"interface_set": schema.List(schema.StringMap(schema.Any())),
+		"service_set": schema.List(schema.FieldMap(schema.Fields{
+			"name":        schema.String(),
+			"status":      schema.String(),
+			"status_info": schema.OneOf(schema.Nil(""), schema.String()),
+		}, schema.Defaults{
+			"status_info": "",
+		})),
+	}
+	// The nodes endpoint leaves out the interfaces and services.
+	defaults := schema.Defaults{
+		"version":       "",
+		"interface_set": []interface{}{},
+		"service_set":   []interface{}{},
+	}
+	valid, err := options.coerce("controller node", fields, defaults, source)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "controller node 2.0 schema check failed")
+	}
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	interfaceSet, err := readInterfaceList(valid["interface_set"].([]interface{}), interface_2_0)
+	if err = options.omit(err, "controller node", "interface_set"); err != nil {
+		return nil, errors.Trace(atPath(err, "interface_set"))
+	}
+	var services []ControllerService
+	for _, value := range valid["service_set"].([]interface{}) {
+		service := value.(map[string]interface{})
+		statusInfo, _ := service["status_info"].(string)
+		services = append(services, ControllerService{
+			Name:       service["name"].(string),
+			Status:     service["status"].(string),
+			StatusInfo: statusInfo,
+		})
+	}
+
+	version, _ := valid["version"].(string)
+	result := &controllerNode{
+		resourceURI: valid["resource_uri"].(string),
+
+		systemID: valid["system_id"].(string),
+		hostname: valid["hostname"].(string),
+		fqdn:     valid["fqdn"].(string),
+		nodeType: NodeType(valid["node_type"].(int)),
+		version:  version,
+
+		ipAddresses:  convertToStringSlice(valid["ip_addresses"]),
+		interfaceSet: interfaceSet,
+		services:     services,
+	}
+	return result, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type nodeSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&nodeSuite{})
+
+func (*nodeSuite) TestReadNodesBadSchema(c *gc.C) {
+	_, err := readNodes(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `node base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*nodeSuite) TestReadNodes(c *gc.C) {
+	nodes, err := readNodes(twoDotOh, parseJSON(c, nodesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(nodes, gc.HasLen, 3)
+
+	machine, ok := nodes[0].(Machine)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(machine.NodeType(), gc.Equals, NodeTypeMachine)
+
+	device, ok := nodes[1].(Device)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(device.SystemID(), gc.Equals, "4y3haf")
+	c.Check(device.NodeType(), gc.Equals, NodeTypeDevice)
+
+	rack, ok := nodes[2].(ControllerNode)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(rack.SystemID(), gc.Equals, "8ecwpp")
+	c.Check(rack.Hostname(), gc.Equals, "maas-rack")
+	c.Check(rack.FQDN(), gc.Equals, "maas-rack.maas")
+	c.Check(rack.NodeType(), gc.Equals, NodeTypeRegionAndRackController)
+	c.Check(rack.IPAddresses(), jc.DeepEquals, []string{"192.168.100.2"})
+	c.Check(rack.Version(), gc.Equals, "2.5.0")
+}
+
+func (*nodeSuite) TestReadNodesUnknownType(c *gc.C) {
+	response := updateJSONMap(c, controllerNodeResponse, map[string]interface{}{
+		"node_type": 9,
+	})
+	_, err := readNodes(twoDotOh, parseJSON(c, "["+response+"]"))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `node 0: unknown node type 9`)
+}
+
+func (*nodeSuite) TestReadNodesNotStrict(c *gc.C) {
+	response := updateJSONMap(c, controllerNodeResponse, map[string]interface{}{
+		"node_type": 9,
+	})
+	nodes, err := readNodes(twoDotOh, parseJSON(c, "["+response+","+controllerNodeResponse+"]"), WithStrictParsing(false))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(nodes, gc.HasLen, 1)
+	c.Check(nodes[0].SystemID(), gc.Equals, "8ecwpp")
+}
+
+func (*nodeSuite) TestReadControllerNodeNilVersion(c *gc.C) {
+	response := updateJSONMap(c, controllerNodeResponse, map[string]interface{}{
+		"version": nil,
+	})
+	nodes, err := readNodes(twoDotOh, parseJSON(c, "["+response+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(nodes, gc.HasLen, 1)
+	c.Check(nodes[0].(ControllerNode).Version(), gc.Equals, "")
+}
+
+func (*nodeSuite) TestLowVersion(c *gc.C) {
+	_, err := readNodes(version.MustParse("1.9.0"), parseJSON(c, nodesResponse))
+	c.Assert(err.Error(), gc.Equals, `no machine read func for version 1.9.0`)
+}
+
+func (*nodeSuite) TestHighVersion(c *gc.C) {
+	nodes, err := readNodes(version.MustParse("2.1.9"), parseJSON(c, nodesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(nodes, gc.HasLen, 3)
+}
+
+func (s *nodeSuite) TestNodes(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/nodes/?hostname=maas-rack", http.StatusOK, nodesResponse)
+
+	nodes, err := controller.Nodes(NodesArgs{Hostnames: []string{"maas-rack"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(nodes, gc.HasLen, 3)
+	c.Check(nodes[0].(*machine).controller, gc.NotNil)
+	c.Check(nodes[1].(*device).controller, gc.NotNil)
+}
+
+func (s *nodeSuite) TestRackControllers(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, "["+rackControllerResponse+"]")
+
+	racks, err := controller.RackControllers()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(racks, gc.HasLen, 1)
+	rack := racks[0]
+	c.Check(rack.SystemID(), gc.Equals, "8ecwpp")
+	c.Check(rack.Version(), gc.Equals, "2.5.0")
+	c.Check(rack.InterfaceSet(), gc.HasLen, 1)
+	c.Check(rack.InterfaceSet()[0].(*interface_).controller, gc.NotNil)
+	c.Check(rack.Services(), jc.DeepEquals, []ControllerService{
+		{Name: "rackd", Status: "running"},
+		{Name: "dhcpd", Status: "off", StatusInfo: "DHCP is not enabled"},
+	})
+	rackd, ok := rack.Service("rackd")
+	c.Check(ok, jc.IsTrue)
+	c.Check(rackd.Running(), jc.IsTrue)
+	_, ok = rack.Service("regiond")
+	c.Check(ok, jc.IsFalse)
+}
+
+func (s *nodeSuite) TestRegionControllers(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/regioncontrollers/", http.StatusOK, "["+controllerNodeResponse+"]")
+
+	regions, err := controller.RegionControllers()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(regions, gc.HasLen, 1)
+	c.Check(regions[0].Hostname(), gc.Equals, "maas-rack")
+	c.Check(regions[0].InterfaceSet(), gc.HasLen, 0)
+	c.Check(regions[0].Services(), gc.HasLen, 0)
+}
+
+func (s *nodeSuite) TestRegionControllersPermission(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/regioncontrollers/", http.StatusForbidden, "admins only")
+	_, err := controller.RegionControllers()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
+const controllerNodeResponse = `
+{
+    "system_id": "8ecwpp",
+    "hostname": "maas-rack",
+    "fqdn": "maas-rack.maas",
+    "node_type": 4,
+    "node_type_name": "Region and rack controller",
+    "version": "2.5.0",
+    "ip_addresses": ["192.168.100.2"],
+    "resource_uri": "/MAAS/api/2.0/regioncontrollers/8ecwpp/"
+}
+`
+
+var nodesResponse = "[" + machineResponse + "," + deviceResponse + "," + controllerNodeResponse + "]"
+
+var rackControllerResponse = `
+{
+    "system_id": "8ecwpp",
+    "hostname": "maas-rack",
+    "fqdn": "maas-rack.maas",
+    "node_type": 2,
+    "version": "2.5.0",
+    "ip_addresses": ["192.168.100.2"],
+    "interface_set": ` + interfacesResponse + `,
+    "service_set": [
+        {"name": "rackd", "status": "running", "status_info": ""},
+        {"name": "dhcpd", "status": "off", "status_info": "DHCP is not enabled"}
+    ],
+    "resource_uri": "/MAAS/api/2.0/rackcontrollers/8ecwpp/"
+}
+`
@@ -0,0 +1,267 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type nodeDevice struct {
+	resourceURI string
+
+	id           int
+	bus          DeviceBus
+	hardwareType HardwareType
+
+	vendorID    string
+	productID   string
+	vendorName  string
+	productName string
+
+	commissioningDriver string
+	pciAddress          string
+	busNumber           int
+	deviceNumber        int
+}
+
+// ID implements NodeDevice.
+func (d *nodeDevice) ID() int {
+	return d.id
+}
+
+// Bus implements NodeDevice.
+func (d *nodeDevice) Bus() DeviceBus {
+	return d.bus
+}
+
+// HardwareType implements NodeDevice.
+func (d *nodeDevice) HardwareType() HardwareType {
+	return d.hardwareType
+}
+
+// VendorID implements NodeDevice.
+func (d *nodeDevice) VendorID() string {
+	return d.vendorID
+}
+
+// ProductID implements NodeDevice.
+func (d *nodeDevice) ProductID() string {
+	return d.productID
+}
+
+// VendorName implements NodeDevice.
+func (d *nodeDevice) VendorName() string {
+	return d.vendorName
+}
+
+// ProductName implements NodeDevice.
+func (d *nodeDevice) ProductName() string {
+	return d.productName
+}
+
+// CommissioningDriver implements NodeDevice.
+func (d *nodeDevice) CommissioningDriver() string {
+	return d.commissioningDriver
+}
+
+// PCIAddress implements NodeDevice.
+func (d *nodeDevice) PCIAddress() string {
+	return d.pciAddress
+}
+
+// BusNumber implements NodeDevice.
+func (d *nodeDevice) BusNumber() int {
+	return d.busNumber
+}
+
+// DeviceNumber implements NodeDevice.
+func (d *nodeDevice) DeviceNumber() int {
+	return d.deviceNumber
+}
+
+// NodeDevicesArgs is an argument struct for selecting node devices. All
+// the set fields must match.
+type NodeDevicesArgs struct {
+	HardwareTypes []HardwareType
+	Bus           DeviceBus
+	// VendorID and ProductID are compared without regard to case.
+	VendorID  string
+	ProductID string
+}
+
+func (a *NodeDevicesArgs) params() *URLParams {
+	params := NewURLParams()
+	for _, hardwareType := range a.HardwareTypes {
+		params.Values.Add("hardware_type", fmt.Sprint(int(hardwareType)))
+	}
+	params.MaybeAddInt("bus", int(a.Bus))
+	params.MaybeAdd("vendor_id", strings.ToLower(a.VendorID))
+	params.MaybeAdd("product_id", strings.ToLower(a.ProductID))
+	return params
+}
+
+// matches applies the filter locally as well, as not all servers filter
+// on every field.
+func (a *NodeDevicesArgs) matches(d *nodeDevice) bool {
+	if len(a.HardwareTypes) > 0 {
+		found := false
+		for _, hardwareType := range a.HardwareTypes {
+			if d.hardwareType == hardwareType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if a.Bus != 0 && d.bus != a.Bus {
+		return false
+	}
+	if a.VendorID != "" && !strings.EqualFold(d.vendorID, a.VendorID) {
+		return false
+	}
+	if a.ProductID != "" && !strings.EqualFold(d.productID, a.ProductID) {
+		return false
+	}
+	return true
+}
+
+// NodeDevices implements Machine.
+func (m *machine) NodeDevices(args NodeDevicesArgs) ([]NodeDevice, error) {
+	source, err := m.controller.getQuery(APIPath(NodesPath, m.systemID, "devices"), args.params().Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok && svrErr.StatusCode == http.StatusNotFound {
+			if strings.HasPrefix(svrErr.BodyMessage, "Unknown API endpoint") {
+				return nil, errors.NewNotSupported(err, "node devices")
+			}
+			return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		}
+		if errors.IsNotValid(err) {
+			return nil, errors.Trace(err)
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	devices, err := readNodeDevices(m.controller.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []NodeDevice
+	for _, d := range devices {
+		if args.matches(d) {
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+func readNodeDevices(controllerVersion version.Number, source interface{}) ([]*nodeDevice, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "node device base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range nodeDeviceDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no node device read func for version %s", controllerVersion)
+	}
+	readFunc := nodeDeviceDeserializationFuncs[deserialisationVersion]
+	return readNodeDeviceList(valid, readFunc)
+}
+
+// readNodeDeviceList expects the values of the sourceList to be string maps.
+func readNodeDeviceList(sourceList []interface{}, readFunc nodeDeviceDeserializationFunc) ([]*nodeDevice, error) {
+	result := make([]*nodeDevice, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for node device %d, %T", i, value)
+		}
+		device, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "node device %d", i)
+		}
+		result = append(result, device)
+	}
+	return result, nil
+}
+
+type nodeDeviceDeserializationFunc func(map[string]interface{}) (*nodeDevice, error)
+
+var nodeDeviceDeserializationFuncs = map[version.Number]nodeDeviceDeserializationFunc{
+	twoDotOh: nodeDevice_2_0,
+}
+
+func nodeDevice_2_0(source map[string]interface{}) (*nodeDevice, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+
+		"id":            schema.ForceInt(),
+		"bus":           schema.ForceInt(),
+		"hardware_type": schema.ForceInt(),
+
+		"vendor_id":    schema.String(),
+		"product_id":   schema.String(),
+		"vendor_name":  schema.OneOf(schema.Nil(""), schema.String()),
+		"product_name": schema.OneOf(schema.Nil(""), schema.String()),
+
+		"commissioning_driver": schema.OneOf(schema.Nil(""), schema.String()),
+		"pci_address":          schema.OneOf(schema.Nil(""), schema.String()),
+		"bus_number":           schema.ForceInt(),
+		"device_number":        schema.ForceInt(),
+	}
+	defaults := schema.Defaults{
+		"resource_uri":         "",
+		"vendor_name":          "",
+		"product_name":         "",
+		"commissioning_driver": "",
+		"pci_address":          "",
+		"bus_number":           0,
+		"device_number":        0,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "node device 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	vendorName, _ := valid["vendor_name"].(string)
+	productName, _ := valid["product_name"].(string)
+	driver, _ := valid["commissioning_driver"].(string)
+	pciAddress, _ := valid["pci_address"].(string)
+	result := &nodeDevice{
+		resourceURI: valid["resource_uri"].(string),
+
+		id:           valid["id"].(int),
+		bus:          DeviceBus(valid["bus"].(int)),
+		hardwareType: HardwareType(valid["hardware_type"].(int)),
+
+		vendorID:    valid["vendor_id"].(string),
+		productID:   valid["product_id"].(string),
+		vendorName:  vendorName,
+		productName: productName,
+
+		commissioningDriver: driver,
+		pciAddress:          pciAddress,
+		busNumber:           valid["bus_number"].(int),
+		deviceNumber:        valid["device_number"].(int),
+	}
+	return result, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type nodeDeviceSuite struct{}
+
+var _ = gc.Suite(&nodeDeviceSuite{})
+
+func (*nodeDeviceSuite) TestReadNodeDevicesBadSchema(c *gc.C) {
+	_, err := readNodeDevices(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `node device base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*nodeDeviceSuite) TestReadNodeDevices(c *gc.C) {
+	devices, err := readNodeDevices(twoDotOh, parseJSON(c, nodeDevicesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 3)
+
+	gpu := devices[0]
+	c.Check(gpu.ID(), gc.Equals, 1)
+	c.Check(gpu.Bus(), gc.Equals, DeviceBusPCIE)
+	c.Check(gpu.HardwareType(), gc.Equals, HardwareTypeGPU)
+	c.Check(gpu.VendorID(), gc.Equals, "10de")
+	c.Check(gpu.ProductID(), gc.Equals, "1eb8")
+	c.Check(gpu.VendorName(), gc.Equals, "NVIDIA Corporation")
+	c.Check(gpu.ProductName(), gc.Equals, "TU104GL [Tesla T4]")
+	c.Check(gpu.CommissioningDriver(), gc.Equals, "nvidia")
+	c.Check(gpu.PCIAddress(), gc.Equals, "0000:3b:00.0")
+	c.Check(gpu.BusNumber(), gc.Equals, 59)
+	c.Check(gpu.DeviceNumber(), gc.Equals, 0)
+
+	usb := devices[2]
+	c.Check(usb.Bus(), gc.Equals, DeviceBusUSB)
+	c.Check(usb.HardwareType(), gc.Equals, HardwareTypeNode)
+	c.Check(usb.VendorName(), gc.Equals, "")
+	c.Check(usb.PCIAddress(), gc.Equals, "")
+}
+
+func (*nodeDeviceSuite) TestLowVersion(c *gc.C) {
+	_, err := readNodeDevices(version.MustParse("1.9.0"), parseJSON(c, nodeDevicesResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (*nodeDeviceSuite) TestHighVersion(c *gc.C) {
+	devices, err := readNodeDevices(version.MustParse("2.1.9"), parseJSON(c, nodeDevicesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 3)
+}
+
+func (*nodeDeviceSuite) TestArgsParams(c *gc.C) {
+	args := NodeDevicesArgs{
+		HardwareTypes: []HardwareType{HardwareTypeGPU, HardwareTypeNetwork},
+		Bus:           DeviceBusPCIE,
+		VendorID:      "10DE",
+	}
+	c.Check(args.params().Values.Encode(), gc.Equals, "bus=1&hardware_type=5&hardware_type=4&vendor_id=10de")
+}
+
+func (*nodeDeviceSuite) TestHardwareTypeString(c *gc.C) {
+	c.Check(HardwareTypeGPU.String(), gc.Equals, "GPU")
+	c.Check(HardwareType(42).String(), gc.Equals, "HardwareType(42)")
+	c.Check(DeviceBusUSB.String(), gc.Equals, "USB")
+}
+
+const nodeDevicesResponse = `
+[
+    {
+        "id": 1,
+        "bus": 1,
+        "hardware_type": 5,
+        "vendor_id": "10de",
+        "product_id": "1eb8",
+        "vendor_name": "NVIDIA Corporation",
+        "product_name": "TU104GL [Tesla T4]",
+        "commissioning_driver": "nvidia",
+        "bus_number": 59,
+        "device_number": 0,
+        "pci_address": "0000:3b:00.0",
+        "resource_uri": "/MAAS/api/2.0/nodes/4y3ha3/devices/1/"
+    },
+    {
+        "id": 2,
+        "bus": 1,
+        "hardware_type": 4,
+        "vendor_id": "8086",
+        "product_id": "1521",
+        "vendor_name": "Intel Corporation",
+        "product_name": "I350 Gigabit Network Connection",
+        "commissioning_driver": "igb",
+        "bus_number": 1,
+        "device_number": 0,
+        "pci_address": "0000:01:00.0",
+        "resource_uri": "/MAAS/api/2.0/nodes/4y3ha3/devices/2/"
+    },
+    {
+        "id": 3,
+        "bus": 2,
+        "hardware_type": 0,
+        "vendor_id": "046b",
+        "product_id": "ff10",
+        "vendor_name": null,
+        "product_name": null,
+        "commissioning_driver": "usbhid",
+        "bus_number": 1,
+        "device_number": 3,
+        "pci_address": null,
+        "resource_uri": "/MAAS/api/2.0/nodes/4y3ha3/devices/3/"
+    }
+]
+`
@@ -0,0 +1,120 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"sort"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// NUMANode is a NUMA node of a machine, as found by commissioning.
+type NUMANode struct {
+	Index int
+	// Memory is in MiB.
+	Memory int
+	// Cores are the indexes of the CPU cores of the node, which are the
+	// values of ComposeMachineArgs.PinnedCores.
+	Cores     []int
+	HugePages []HugePages
+}
+
+// HugePages are the huge pages of one size configured on a NUMA node.
+type HugePages struct {
+	// PageSize is in bytes.
+	PageSize uint64
+	Total    uint64
+}
+
+// SelectNUMACores picks cores to pin a virtual machine to, so that its
+// cores and memory are all on one NUMA node of the VM host. The node with
+// the fewest cores that has enough cores and memory, in MiB, is chosen, and
+// its lowest numbered cores are returned. It does not know which cores
+// other virtual machines are pinned to. If no node is big enough an error
+// satisfying errors.IsNotFound is returned.
+func SelectNUMACores(nodes []NUMANode, cores, memory int) ([]int, error) {
+	if cores <= 0 {
+		return nil, errors.NotValidf("cores %d", cores)
+	}
+	var best *NUMANode
+	for i, node := range nodes {
+		if len(node.Cores) < cores || node.Memory < memory {
+			continue
+		}
+		if best == nil || len(node.Cores) < len(best.Cores) {
+			best = &nodes[i]
+		}
+	}
+	if best == nil {
+		return nil, errors.NotFoundf("NUMA node with %d cores and %d MiB", cores, memory)
+	}
+	result := append([]int(nil), best.Cores...)
+	sort.Ints(result)
+	return result[:cores], nil
+}
+
+// NUMANodes implements Machine.
+func (m *machine) NUMANodes() []NUMANode {
+	return m.numaNodes
+}
+
+// HostSystemID implements Pod.
+func (p *pod) HostSystemID() string {
+	return p.hostSystemID
+}
+
+// NUMANodes implements Pod.
+func (p *pod) NUMANodes() ([]NUMANode, error) {
+	if p.hostSystemID == "" {
+		return nil, errors.NotFoundf("host machine of pod %q", p.name)
+	}
+	machines, err := p.controller.Machines(MachinesArgs{SystemIDs: []string{p.hostSystemID}})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(machines) != 1 {
+		return nil, errors.NotFoundf("host machine %q of pod %q", p.hostSystemID, p.name)
+	}
+	return machines[0].NUMANodes(), nil
+}
+
+func readNUMANodeList(sourceList []interface{}) ([]NUMANode, error) {
+	checker := schema.FieldMap(schema.Fields{
+		"index":  schema.ForceInt(),
+		"memory": schema.ForceInt(),
+		"cores":  schema.List(schema.ForceInt()),
+		"hugepages_set": schema.List(schema.FieldMap(schema.Fields{
+			"page_size": schema.ForceUint(),
+			"total":     schema.ForceUint(),
+		}, nil)),
+	}, schema.Defaults{
+		// Servers before 2.9 have no huge pages.
+		"hugepages_set": []interface{}{},
+	})
+	result := make([]NUMANode, 0, len(sourceList))
+	for i, value := range sourceList {
+		coerced, err := checker.Coerce(value, nil)
+		if err != nil {
+			return nil, WrapWithDeserializationError(atPath(err, indexPath(i)), "numa node schema check failed")
+		}
+		valid := coerced.(map[string]interface{})
+		node := NUMANode{
+			Index:  valid["index"].(int),
+			Memory: valid["memory"].(int),
+		}
+		for _, core := range valid["cores"].([]interface{}) {
+			node.Cores = append(node.Cores, core.(int))
+		}
+		for _, value := range valid["hugepages_set"].([]interface{}) {
+			pages := value.(map[string]interface{})
+			node.HugePages = append(node.HugePages, HugePages{
+				PageSize: pages["page_size"].(uint64),
+				Total:    pages["total"].(uint64),
+			})
+		}
+		result = append(result, node)
+	}
+	return result, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+var testNUMANodes = []NUMANode{
+	{Index: 0, Memory: 16384, Cores: []int{0, 1, 2, 3, 4, 5, 6, 7}},
+	{Index: 1, Memory: 8192, Cores: []int{11, 10, 9, 8}},
+}
+
+func (*podSuite) TestSelectNUMACores(c *gc.C) {
+	cores, err := SelectNUMACores(testNUMANodes, 2, 4096)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(cores, jc.DeepEquals, []int{8, 9})
+
+	// The smaller node does not have the memory.
+	cores, err = SelectNUMACores(testNUMANodes, 2, 12288)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(cores, jc.DeepEquals, []int{0, 1})
+
+	_, err = SelectNUMACores(testNUMANodes, 12, 1024)
+	c.Check(err, jc.Satisfies, errors.IsNotFound)
+	_, err = SelectNUMACores(testNUMANodes, 0, 1024)
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (*machineSuite) TestReadMachineNUMANodes(c *gc.C) {
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"numanode_set": []interface{}{
+			map[string]interface{}{
+				"index":  0,
+				"memory": 16384,
+				"cores":  []interface{}{0, 1, 2, 3},
+				"hugepages_set": []interface{}{
+					map[string]interface{}{"page_size": 2097152, "total": 1024},
+				},
+			},
+			map[string]interface{}{
+				"index":  1,
+				"memory": 8192,
+				"cores":  []interface{}{4, 5},
+			},
+		},
+	})
+	machines, err := readMachines(twoDotOh, parseJSON(c, "["+response+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].NUMANodes(), jc.DeepEquals, []NUMANode{
+		{Index: 0, Memory: 16384, Cores: []int{0, 1, 2, 3}, HugePages: []HugePages{{PageSize: 2097152, Total: 1024}}},
+		{Index: 1, Memory: 8192, Cores: []int{4, 5}},
+	})
+
+	machines, err = readMachines(twoDotOh, parseJSON(c, "["+machineResponse+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].NUMANodes(), gc.HasLen, 0)
+}
+
+func (s *podSuite) TestPodNUMANodes(c *gc.C) {
+	server, pod := s.getServerAndPod(c)
+	c.Check(pod.HostSystemID(), gc.Equals, "4y3ha3")
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"numanode_set": []interface{}{
+			map[string]interface{}{"index": 0, "memory": 16384, "cores": []interface{}{0, 1}},
+		},
+	})
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+response+"]")
+	nodes, err := pod.NUMANodes()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(nodes, jc.DeepEquals, []NUMANode{{Index: 0, Memory: 16384, Cores: []int{0, 1}}})
+}
+
+func (s *podSuite) TestPodNUMANodesNoHost(c *gc.C) {
+	_, pod := s.getServerAndPod(c)
+	pod.hostSystemID = ""
+	_, err := pod.NUMANodes()
+	c.Check(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *podSuite) TestComposePinned(c *gc.C) {
+	server, pod := s.getServerAndPod(c)
+	pod.controller.serverVersion = version.MustParse("2.9.0")
+	server.AddPostResponse(pod.resourceURI+"?op=compose", http.StatusOK,
+		`{"system_id": "4y3ha3", "resource_uri": "/MAAS/api/2.0/machines/4y3ha3/"}`)
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineResponse+"]")
+	_, err := pod.Compose(ComposeMachineArgs{
+		PinnedCores:     []int{8, 9},
+		HugepagesBacked: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := server.LastNRequests(2)[0].PostForm
+	c.Check(form["pinned_cores"], jc.DeepEquals, []string{"8", "9"})
+	c.Check(form.Get("hugepages_backed"), gc.Equals, "true")
+	_, found := form["cores"]
+	c.Check(found, jc.IsFalse)
+}
+
+func (s *podSuite) TestComposePinnedValidates(c *gc.C) {
+	_, pod := s.getServerAndPod(c)
+	_, err := pod.Compose(ComposeMachineArgs{Cores: 4, PinnedCores: []int{8, 9}})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, "Cores 4 with 2 PinnedCores not valid")
+
+	pod.controller.serverVersion = version.MustParse("2.8.2")
+	_, err = pod.Compose(ComposeMachineArgs{PinnedCores: []int{8, 9}})
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+}
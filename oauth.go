@@ -10,7 +10,10 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/juju/utils/clock"
 )
 
 // Not a true uuidgen, but at least creates same length random
@@ -23,8 +26,8 @@ func generateNonce() (string, error) {
 	return fmt.Sprintf("%16x", randBytes), nil
 }
 
-func generateTimestamp() string {
-	return strconv.Itoa(int(time.Now().Unix()))
+func generateTimestamp(now time.Time) string {
+	return strconv.Itoa(int(now.Unix()))
 }
 
 type OAuthSigner interface {
@@ -42,30 +45,91 @@ type OAuthToken struct {
 var _ OAuthSigner = (*plainTextOAuthSigner)(nil)
 
 type plainTextOAuthSigner struct {
+	// mu guards token, which can be replaced while requests are signed,
+	// and clock and offset.
+	mu    sync.Mutex
 	token *OAuthToken
 	realm string
+
+	// offset is added to the time of the clock, or the wall clock if it
+	// is nil, for the timestamps of the signatures.
+	clock  clock.Clock
+	offset time.Duration
 }
 
 func NewPlainTestOAuthSigner(token *OAuthToken, realm string) (OAuthSigner, error) {
-	return &plainTextOAuthSigner{token, realm}, nil
+	return &plainTextOAuthSigner{token: token, realm: realm}, nil
+}
+
+// timeOffsetter is implemented by signers whose timestamps can be shifted
+// to match the server's clock.
+type timeOffsetter interface {
+	// setTimeOffset makes the timestamps the time of the clock plus the
+	// offset.
+	setTimeOffset(clk clock.Clock, offset time.Duration)
+}
+
+func (signer *plainTextOAuthSigner) setTimeOffset(clk clock.Clock, offset time.Duration) {
+	signer.mu.Lock()
+	defer signer.mu.Unlock()
+	signer.clock = clk
+	signer.offset = offset
+}
+
+// now returns the time for the timestamp of a signature.
+func (signer *plainTextOAuthSigner) now() time.Time {
+	signer.mu.Lock()
+	defer signer.mu.Unlock()
+	if signer.clock == nil {
+		return time.Now().Add(signer.offset)
+	}
+	return signer.clock.Now().Add(signer.offset)
+}
+
+// tokenSetter is implemented by signers whose token can be replaced, so
+// that the credentials of a client can be rotated while it is in use.
+type tokenSetter interface {
+	setToken(*OAuthToken)
+	// withToken returns a new signer like this one that uses the token.
+	withToken(*OAuthToken) OAuthSigner
+}
+
+func (signer *plainTextOAuthSigner) setToken(token *OAuthToken) {
+	signer.mu.Lock()
+	defer signer.mu.Unlock()
+	signer.token = token
+}
+
+func (signer *plainTextOAuthSigner) withToken(token *OAuthToken) OAuthSigner {
+	signer.mu.Lock()
+	defer signer.mu.Unlock()
+	return &plainTextOAuthSigner{
+		token:  token,
+		realm:  signer.realm,
+		clock:  signer.clock,
+		offset: signer.offset,
+	}
 }
 
 // OAuthSignPLAINTEXT signs the provided request using the OAuth PLAINTEXT
 // method: http://oauth.net/core/1.0/#anchor22.
-func (signer plainTextOAuthSigner) OAuthSign(request *http.Request) error {
+func (signer *plainTextOAuthSigner) OAuthSign(request *http.Request) error {
+	signer.mu.Lock()
+	token := signer.token
+	signer.mu.Unlock()
 
-	signature := signer.token.ConsumerSecret + `&` + signer.token.TokenSecret
+	signature := token.ConsumerSecret + `&` + token.TokenSecret
 	nonce, err := generateNonce()
 	if err != nil {
 		return err
 	}
 	authData := map[string]string{
 		"realm":                  signer.realm,
-		"oauth_consumer_key":     signer.token.ConsumerKey,
-		"oauth_token":            signer.token.TokenKey,
+		"oauth_consumer_key":     token.ConsumerKey,
+		"oauth_token":            token.TokenKey,
 		"oauth_signature_method": "PLAINTEXT",
 		"oauth_signature":        signature,
-		"oauth_timestamp":        generateTimestamp(),
+		"oauth_timestamp":        generateTimestamp(signer.now()),
 		"oauth_nonce":            nonce,
 		"oauth_version":          "1.0",
 	}
@@ -75,6 +139,6 @@ func (signer plainTextOAuthSigner) OAuthSign(request *http.Request) error {
 		authHeader = append(authHeader, fmt.Sprintf(`%s="%s"`, key, url.QueryEscape(value)))
 	}
 	strHeader := "OAuth " + strings.Join(authHeader, ", ")
-	request.Header.Add("Authorization", strHeader)
+	request.Header.Set("Authorization", strHeader)
 	return nil
 }
@@ -4,12 +4,17 @@
 package gomaasapi
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
 )
 
 type partition struct {
+	controller *controller
+
 	resourceURI string
 
 	id      int
@@ -65,14 +70,126 @@ func (p *partition) Tags() []string {
 	return p.tags
 }
 
-func readPartitions(controllerVersion version.Number, source interface{}) ([]*partition, error) {
-	checker := schema.List(schema.StringMap(schema.Any()))
+func (p *partition) updateFrom(other *partition) {
+	p.resourceURI = other.resourceURI
+	p.id = other.id
+	p.path = other.path
+	p.uuid = other.uuid
+	p.usedFor = other.usedFor
+	p.size = other.size
+	p.tags = other.tags
+	p.filesystem = other.filesystem
+}
+
+// FormatPartitionArgs is an argument struct for Partition.Format. FSType is
+// required.
+type FormatPartitionArgs struct {
+	// FSType is the type of the filesystem, such as "ext4", "xfs" or
+	// "swap".
+	FSType string
+	// UUID of the filesystem. The server generates one if it is empty.
+	UUID string
+	// Label of the filesystem.
+	Label string
+}
+
+// Validate ensures that the filesystem type is set.
+func (a *FormatPartitionArgs) Validate() error {
+	if a.FSType == "" {
+		return errors.NotValidf("missing FSType")
+	}
+	return nil
+}
+
+// Format implements Partition.
+func (p *partition) Format(args FormatPartitionArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("fstype", args.FSType)
+	params.MaybeAdd("uuid", args.UUID)
+	params.MaybeAdd("label", args.Label)
+	return errors.Trace(p.post("format", params))
+}
+
+// MountPartitionArgs is an argument struct for Partition.Mount. MountPoint
+// is required.
+type MountPartitionArgs struct {
+	// MountPoint is the absolute path to mount the filesystem on, or
+	// "none" for a swap filesystem.
+	MountPoint string
+	// MountOptions are the options for mounting, as in fstab, such as
+	// "noatime,nodiratime".
+	MountOptions []string
+}
+
+// Validate ensures that the mount point is an absolute path or "none".
+func (a *MountPartitionArgs) Validate() error {
+	if a.MountPoint == "" {
+		return errors.NotValidf("missing MountPoint")
+	}
+	if a.MountPoint != "none" && !strings.HasPrefix(a.MountPoint, "/") {
+		return errors.NotValidf("relative MountPoint %q", a.MountPoint)
+	}
+	return nil
+}
+
+// Mount implements Partition.
+func (p *partition) Mount(args MountPartitionArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("mount_point", args.MountPoint)
+	params.MaybeAdd("mount_options", strings.Join(args.MountOptions, ","))
+	return errors.Trace(p.post("mount", params))
+}
+
+// Unmount implements Partition.
+func (p *partition) Unmount() error {
+	return errors.Trace(p.post("unmount", NewURLParams()))
+}
+
+// post sends the op for the partition, and updates the partition from the
+// response.
+func (p *partition) post(op string, params *URLParams) error {
+	source, err := p.controller.post(p.resourceURI, op, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusBadRequest, http.StatusConflict:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	response, err := readPartition(p.controller.schemaVersion(), source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	p.updateFrom(response)
+	return nil
+}
+
+func readPartition(controllerVersion version.Number, source interface{}) (*partition, error) {
+	readFunc, err := getPartitionDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
 		return nil, WrapWithDeserializationError(err, "partition base schema check failed")
 	}
-	valid := coerced.([]interface{})
+	return readFunc(coerced.(map[string]interface{}))
+}
 
+func getPartitionDeserializationFunc(controllerVersion version.Number) (partitionDeserializationFunc, error) {
 	var deserialisationVersion version.Number
 	for v := range partitionDeserializationFuncs {
 		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
@@ -82,7 +199,20 @@ func readPartitions(controllerVersion version.Number, source interface{}) ([]*pa
 	if deserialisationVersion == version.Zero {
 		return nil, NewUnsupportedVersionError("no partition read func for version %s", controllerVersion)
 	}
-	readFunc := partitionDeserializationFuncs[deserialisationVersion]
+	return partitionDeserializationFuncs[deserialisationVersion], nil
+}
+
+func readPartitions(controllerVersion version.Number, source interface{}) ([]*partition, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "partition base schema check failed")
+	}
+	valid := coerced.([]interface{})
+	readFunc, err := getPartitionDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	return readPartitionList(valid, readFunc)
 }
 
@@ -92,11 +222,11 @@ func readPartitionList(sourceList []interface{}, readFunc partitionDeserializati
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, NewDeserializationError("unexpected value for partition %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for partition %d, %T", i, value), indexPath(i))
 		}
 		partition, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "partition %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "partition %d", i)
 		}
 		result = append(result, partition)
 	}
@@ -137,7 +267,7 @@ func partition_2_0(source map[string]interface{}) (*partition, error) {
 	var filesystem *filesystem
 	if fsSource, ok := valid["filesystem"].(map[string]interface{}); ok {
 		if filesystem, err = filesystem2_0(fsSource); err != nil {
-			return nil, errors.Trace(err)
+			return nil, errors.Trace(atPath(err, "filesystem"))
 		}
 	}
 
@@ -4,6 +4,10 @@
 package gomaasapi
 
 import (
+	"fmt"
+	"net/http"
+
+	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
@@ -70,6 +74,114 @@ func (*partitionSuite) TestHighVersion(c *gc.C) {
 	c.Assert(partitions, gc.HasLen, 1)
 }
 
+// partitionResponse returns partition 1 of block device 34 of machine
+// 4y3ha3 with the filesystem, which may be "null".
+func partitionResponse(id int, filesystem string) string {
+	return fmt.Sprintf(`{
+    "id": %d, "path": "/dev/disk/by-dname/sda-part%d", "type": "partition",
+    "resource_uri": "/MAAS/api/2.0/nodes/4y3ha3/blockdevices/34/partition/%d",
+    "uuid": "6199b7c9-b66f-40f6-a238-a938a58a0adf", "used_for": "",
+    "size": 1073741824, "tags": [], "filesystem": %s
+}`, id, id, id, filesystem)
+}
+
+func (*partitionSuite) TestFormatPartitionArgsValidate(c *gc.C) {
+	args := FormatPartitionArgs{}
+	c.Check(args.Validate(), gc.ErrorMatches, "missing FSType not valid")
+	args.FSType = "xfs"
+	c.Check(args.Validate(), jc.ErrorIsNil)
+}
+
+func (*partitionSuite) TestMountPartitionArgsValidate(c *gc.C) {
+	for i, test := range []struct {
+		args    MountPartitionArgs
+		errText string
+	}{{
+		errText: "missing MountPoint not valid",
+	}, {
+		args:    MountPartitionArgs{MountPoint: "srv"},
+		errText: `relative MountPoint "srv" not valid`,
+	}, {
+		args: MountPartitionArgs{MountPoint: "/srv"},
+	}, {
+		args: MountPartitionArgs{MountPoint: "none"},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *machineSuite) TestPartitionFormat(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/blockdevices/34/partition/1/?op=format", http.StatusOK,
+		partitionResponse(1, `{"fstype": "xfs", "label": "data", "uuid": "fcd7745e-f1b5-4f5d-9575-9b0bb796b752"}`))
+	partition := machine.Partition(1)
+
+	err := partition.Format(FormatPartitionArgs{FSType: "xfs", Label: "data"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(partition.FileSystem().Type(), gc.Equals, "xfs")
+	c.Check(partition.FileSystem().MountPoint(), gc.Equals, "")
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("fstype"), gc.Equals, "xfs")
+	c.Check(form.Get("label"), gc.Equals, "data")
+	c.Check(form.Get("uuid"), gc.Equals, "")
+}
+
+func (s *machineSuite) TestPartitionFormatInUse(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/blockdevices/34/partition/1/?op=format", http.StatusConflict, "partition in use")
+	err := machine.Partition(1).Format(FormatPartitionArgs{FSType: "xfs"})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "partition in use")
+}
+
+func (s *machineSuite) TestPartitionMount(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/blockdevices/34/partition/1/?op=mount", http.StatusOK,
+		partitionResponse(1, `{"fstype": "xfs", "mount_point": "/srv", "uuid": "fcd7745e-f1b5-4f5d-9575-9b0bb796b752"}`))
+	partition := machine.Partition(1)
+
+	err := partition.Mount(MountPartitionArgs{MountPoint: "/srv", MountOptions: []string{"noatime", "nodiratime"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(partition.FileSystem().MountPoint(), gc.Equals, "/srv")
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("mount_point"), gc.Equals, "/srv")
+	c.Check(form.Get("mount_options"), gc.Equals, "noatime,nodiratime")
+}
+
+func (s *machineSuite) TestPartitionMountValidates(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	err := machine.Partition(1).Mount(MountPartitionArgs{})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestPartitionUnmount(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/blockdevices/34/partition/1/?op=unmount", http.StatusOK,
+		partitionResponse(1, `{"fstype": "ext4", "uuid": "fcd7745e-f1b5-4f5d-9575-9b0bb796b752"}`))
+	partition := machine.Partition(1)
+
+	err := partition.Unmount()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(partition.FileSystem().MountPoint(), gc.Equals, "")
+}
+
+func (s *machineSuite) TestPartitionUnmountForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/blockdevices/34/partition/1/?op=unmount", http.StatusForbidden, "no")
+	err := machine.Partition(1).Unmount()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
 var partitionsResponse = `
 [
     {
@@ -0,0 +1,65 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The roots of the MAAS 2.0 API. They are relative to the versioned API
+// URL, such as http://maas.example.com/MAAS/api/2.0/, so they can be used
+// with a Client made by NewAuthenticatedClient or resolved against the
+// APIURL of one.
+const (
+	BootResourcesPath      = "boot-resources"
+	BootSourcesPath        = "boot-sources"
+	DevicesPath            = "devices"
+	DNSResourcesPath       = "dnsresources"
+	DNSResourceRecordsPath = "dnsresourcerecords"
+	DomainsPath            = "domains"
+	FabricsPath            = "fabrics"
+	FilesPath              = "files"
+	IPAddressesPath        = "ipaddresses"
+	IPRangesPath           = "ipranges"
+	MachinesPath           = "machines"
+	NodesPath              = "nodes"
+	PodsPath               = "pods"
+	PoolsPath              = "pools"
+	RackControllersPath    = "rackcontrollers"
+	RegionControllersPath  = "regioncontrollers"
+	SpacesPath             = "spaces"
+	SSHKeysPath            = "sshkeys"
+	SSLKeysPath            = "sslkeys"
+	StaticRoutesPath       = "static-routes"
+	SubnetsPath            = "subnets"
+	TagsPath               = "tags"
+	UsersPath              = "users"
+	VersionPath            = "version"
+	ZonesPath              = "zones"
+)
+
+// APIPath joins a root of the API and the elements that follow it, such
+// as the system ID of a machine or the ID of a subnet, into a path:
+//
+//	APIPath(NodesPath, "4y3ha3", "interfaces", 48) // "nodes/4y3ha3/interfaces/48"
+//
+// The path has no trailing slash; MAAS expects one, which the controller
+// adds unless it was asked not to. Use EnsureTrailingSlash when passing
+// the path to a Client directly.
+func APIPath(root string, elements ...interface{}) string {
+	parts := []string{strings.TrimSuffix(root, "/")}
+	for _, element := range elements {
+		parts = append(parts, strings.Trim(fmt.Sprint(element), "/"))
+	}
+	return strings.Join(parts, "/")
+}
+
+// VersionedAPIPath returns the path, relative to the base URL of the MAAS
+// server, of a path of the API at the version:
+//
+//	VersionedAPIPath("2.0", MachinesPath) // "api/2.0/machines/"
+func VersionedAPIPath(apiVersion, path string) string {
+	return EnsureTrailingSlash(fmt.Sprintf("api/%s/%s", apiVersion, strings.TrimPrefix(path, "/")))
+}
@@ -0,0 +1,37 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/url"
+
+	gc "gopkg.in/check.v1"
+)
+
+func (suite *GomaasapiTestSuite) TestAPIPathRoot(c *gc.C) {
+	c.Check(APIPath(MachinesPath), gc.Equals, "machines")
+}
+
+func (suite *GomaasapiTestSuite) TestAPIPathJoinsElements(c *gc.C) {
+	c.Check(APIPath(NodesPath, "4y3ha3", "interfaces", 48), gc.Equals, "nodes/4y3ha3/interfaces/48")
+}
+
+func (suite *GomaasapiTestSuite) TestAPIPathNormalizesSlashes(c *gc.C) {
+	c.Check(APIPath("subnets/", "/3/"), gc.Equals, "subnets/3")
+}
+
+func (suite *GomaasapiTestSuite) TestVersionedAPIPath(c *gc.C) {
+	c.Check(VersionedAPIPath("2.0", MachinesPath), gc.Equals, "api/2.0/machines/")
+	c.Check(VersionedAPIPath("2.0", "/subnets/3/"), gc.Equals, "api/2.0/subnets/3/")
+	c.Check(VersionedAPIPath("2.0", ""), gc.Equals, "api/2.0/")
+}
+
+func (suite *GomaasapiTestSuite) TestAPIPathWithClient(c *gc.C) {
+	client, err := NewAnonymousClient("http://example.com/MAAS", "2.0")
+	c.Assert(err, gc.IsNil)
+	path, err := url.Parse(EnsureTrailingSlash(APIPath(SubnetsPath, 3)))
+	c.Assert(err, gc.IsNil)
+	uri := client.GetURL(path)
+	c.Check(uri.String(), gc.Equals, "http://example.com/MAAS/api/2.0/subnets/3/")
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// The scopes reported by CheckAPIKeyPermissions.
+const (
+	// ScopeRead is listing machines and other objects.
+	ScopeRead = "read"
+	// ScopeAllocate is allocating and releasing machines, the writes
+	// available to ordinary users.
+	ScopeAllocate = "allocate"
+	// ScopeAdmin is the admin only operations, such as managing boot
+	// sources and changing settings.
+	ScopeAdmin = "admin"
+)
+
+// PermissionProbe is the result of one of the requests made by
+// CheckAPIKeyPermissions.
+type PermissionProbe struct {
+	// Scope is one of the Scope* values.
+	Scope string
+	// Request describes the request, such as "GET boot-sources".
+	Request string
+	Allowed bool
+	// Err is set if the request failed for a reason other than
+	// permission, in which case Allowed is false and the scope unknown.
+	Err error
+}
+
+// APIKeyPermissions is the result of CheckAPIKeyPermissions.
+type APIKeyPermissions struct {
+	// Username is the user the API key belongs to.
+	Username string
+	Probes   []PermissionProbe
+}
+
+// Allowed returns true if the probe of the scope succeeded.
+func (p APIKeyPermissions) Allowed(scope string) bool {
+	for _, probe := range p.Probes {
+		if probe.Scope == scope {
+			return probe.Allowed
+		}
+	}
+	return false
+}
+
+// ReadOnly returns true if the key can read but not allocate machines.
+func (p APIKeyPermissions) ReadOnly() bool {
+	return p.Allowed(ScopeRead) && !p.Allowed(ScopeAllocate)
+}
+
+// Require returns an error satisfying IsPermissionError, naming the user
+// and the missing scopes, unless all the scopes are allowed.
+func (p APIKeyPermissions) Require(scopes ...string) error {
+	var missing []string
+	for _, scope := range scopes {
+		if !p.Allowed(scope) {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return NewPermissionError(fmt.Sprintf(
+		"API key for user %q lacks the %s permission needed; use the API key of another user or change the user's rights in MAAS",
+		p.Username, strings.Join(missing, " and ")))
+}
+
+// probeMachineID is a system ID that no machine has, so that the probes
+// do not touch any machine.
+const probeMachineID = "permission-probe"
+
+// CheckAPIKeyPermissions implements Controller.
+func (c *controller) CheckAPIKeyPermissions() (APIKeyPermissions, error) {
+	var result APIKeyPermissions
+	source, err := c.getOp(UsersPath, "whoami")
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok && svrErr.StatusCode == http.StatusUnauthorized {
+			return result, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+		return result, NewUnexpectedError(err)
+	}
+	// Older servers return the username alone, newer ones the user.
+	switch whoami := source.(type) {
+	case string:
+		result.Username = whoami
+	case map[string]interface{}:
+		result.Username, _ = whoami["username"].(string)
+	}
+
+	params := NewURLParams()
+	params.Values.Add("id", probeMachineID)
+	_, err = c.getQuery(MachinesPath, params.Values)
+	result.addProbe(ScopeRead, "GET machines", err)
+
+	// A dry run allocation of a machine that does not exist checks the
+	// permission without allocating anything, and fails with a conflict
+	// when it is allowed.
+	params = NewURLParams()
+	params.Values.Add("system_id", probeMachineID)
+	params.Values.Add("dry_run", "true")
+	_, err = c.post(MachinesPath, "allocate", params.Values)
+	result.addProbe(ScopeAllocate, "POST machines op=allocate dry_run", err)
+
+	_, err = c.get(BootSourcesPath)
+	result.addProbe(ScopeAdmin, "GET boot-sources", err)
+	return result, nil
+}
+
+func (p *APIKeyPermissions) addProbe(scope, request string, err error) {
+	probe := PermissionProbe{Scope: scope, Request: request}
+	if err == nil {
+		probe.Allowed = true
+	} else if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			// Not allowed.
+		case http.StatusConflict, http.StatusBadRequest:
+			// The request was refused for what it asked for, not who
+			// asked.
+			probe.Allowed = true
+		default:
+			probe.Err = NewUnexpectedError(err)
+		}
+	} else {
+		probe.Err = NewUnexpectedError(err)
+	}
+	p.Probes = append(p.Probes, probe)
+}
@@ -0,0 +1,840 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+// MaxOverCommitRatio is the largest over-commit ratio MAAS accepts for the
+// CPU or memory of a pod.
+const MaxOverCommitRatio = 10
+
+// PodResources is an amount of the resources of a pod.
+type PodResources struct {
+	Cores int
+	// Memory is in MiB.
+	Memory int
+	// LocalStorage is in bytes.
+	LocalStorage uint64
+}
+
+type pod struct {
+	controller *controller
+
+	resourceURI string
+
+	id            int
+	name          string
+	podType       string
+	architectures []string
+	capabilities  []string
+	tags          []string
+	zone          *zone
+	pool          *pool
+	// hostSystemID is the machine that the pod runs on, if MAAS
+	// deployed it.
+	hostSystemID string
+
+	total     PodResources
+	used      PodResources
+	available PodResources
+
+	cpuOverCommitRatio    float64
+	memoryOverCommitRatio float64
+	defaultStoragePool    string
+	storagePools          []*podStoragePool
+}
+
+func (p *pod) updateFrom(other *pod) {
+	p.resourceURI = other.resourceURI
+	p.name = other.name
+	p.podType = other.podType
+	p.architectures = other.architectures
+	p.capabilities = other.capabilities
+	p.tags = other.tags
+	p.zone = other.zone
+	p.pool = other.pool
+	p.hostSystemID = other.hostSystemID
+	p.total = other.total
+	p.used = other.used
+	p.available = other.available
+	p.cpuOverCommitRatio = other.cpuOverCommitRatio
+	p.memoryOverCommitRatio = other.memoryOverCommitRatio
+	p.defaultStoragePool = other.defaultStoragePool
+	p.storagePools = other.storagePools
+}
+
+// ID implements Pod.
+func (p *pod) ID() int {
+	return p.id
+}
+
+// Name implements Pod.
+func (p *pod) Name() string {
+	return p.name
+}
+
+// Type implements Pod.
+func (p *pod) Type() string {
+	return p.podType
+}
+
+// Architectures implements Pod.
+func (p *pod) Architectures() []string {
+	return p.architectures
+}
+
+// Capabilities implements Pod.
+func (p *pod) Capabilities() []string {
+	return p.capabilities
+}
+
+// Tags implements Pod.
+func (p *pod) Tags() []string {
+	return p.tags
+}
+
+// Zone implements Pod.
+func (p *pod) Zone() Zone {
+	if p.zone == nil {
+		return nil
+	}
+	return p.zone
+}
+
+// Pool implements Pod.
+func (p *pod) Pool() Pool {
+	if p.pool == nil {
+		return nil
+	}
+	return p.pool
+}
+
+// Total implements Pod.
+func (p *pod) Total() PodResources {
+	return p.total
+}
+
+// Used implements Pod.
+func (p *pod) Used() PodResources {
+	return p.used
+}
+
+// Available implements Pod.
+func (p *pod) Available() PodResources {
+	return p.available
+}
+
+// CPUOverCommitRatio implements Pod.
+func (p *pod) CPUOverCommitRatio() float64 {
+	return p.cpuOverCommitRatio
+}
+
+// MemoryOverCommitRatio implements Pod.
+func (p *pod) MemoryOverCommitRatio() float64 {
+	return p.memoryOverCommitRatio
+}
+
+// DefaultStoragePool implements Pod.
+func (p *pod) DefaultStoragePool() PodStoragePool {
+	for _, sp := range p.storagePools {
+		if sp.id == p.defaultStoragePool {
+			return sp
+		}
+	}
+	return nil
+}
+
+// StoragePools implements Pod.
+func (p *pod) StoragePools() []PodStoragePool {
+	result := make([]PodStoragePool, len(p.storagePools))
+	for i, sp := range p.storagePools {
+		result[i] = sp
+	}
+	return result
+}
+
+// UpdatePodArgs is an argument struct for calling Pod.Update. Zero values
+// are left unchanged.
+type UpdatePodArgs struct {
+	Name string
+	// Tags, if not nil, replaces all the tags of the pod.
+	Tags []string
+	Zone string
+	Pool string
+
+	// The over-commit ratios must be between zero and
+	// MaxOverCommitRatio.
+	CPUOverCommitRatio    float64
+	MemoryOverCommitRatio float64
+
+	// DefaultStoragePool is the ID or name of one of the pod's storage
+	// pools.
+	DefaultStoragePool string
+}
+
+// Validate ensures that the over-commit ratios are in range.
+func (a *UpdatePodArgs) Validate() error {
+	if a.CPUOverCommitRatio < 0 || a.CPUOverCommitRatio > MaxOverCommitRatio {
+		return errors.NotValidf("CPUOverCommitRatio %v", a.CPUOverCommitRatio)
+	}
+	if a.MemoryOverCommitRatio < 0 || a.MemoryOverCommitRatio > MaxOverCommitRatio {
+		return errors.NotValidf("MemoryOverCommitRatio %v", a.MemoryOverCommitRatio)
+	}
+	return nil
+}
+
+// Update implements Pod.
+func (p *pod) Update(args UpdatePodArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.MaybeAdd("name", args.Name)
+	if args.Tags != nil {
+		params.Values.Add("tags", strings.Join(args.Tags, ","))
+	}
+	params.MaybeAdd("zone", args.Zone)
+	params.MaybeAdd("pool", args.Pool)
+	if args.CPUOverCommitRatio != 0 {
+		params.Values.Add("cpu_over_commit_ratio", strconv.FormatFloat(args.CPUOverCommitRatio, 'f', -1, 64))
+	}
+	if args.MemoryOverCommitRatio != 0 {
+		params.Values.Add("memory_over_commit_ratio", strconv.FormatFloat(args.MemoryOverCommitRatio, 'f', -1, 64))
+	}
+	if args.DefaultStoragePool != "" {
+		storagePool := p.storagePool(args.DefaultStoragePool)
+		if storagePool == nil {
+			return errors.NotValidf("unknown storage pool %q", args.DefaultStoragePool)
+		}
+		params.Values.Add("default_storage_pool", storagePool.id)
+	}
+	if len(params.Values) == 0 {
+		return nil
+	}
+
+	source, err := p.controller.put(p.resourceURI, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+
+	response, err := readPod(p.controller.schemaVersion(), source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	p.updateFrom(response)
+	return nil
+}
+
+func (p *pod) storagePool(idOrName string) *podStoragePool {
+	for _, sp := range p.storagePools {
+		if sp.id == idOrName || sp.name == idOrName {
+			return sp
+		}
+	}
+	return nil
+}
+
+type podStoragePool struct {
+	id          string
+	name        string
+	poolType    string
+	path        string
+	total       uint64
+	used        uint64
+	available   uint64
+	defaultPool bool
+}
+
+// ID implements PodStoragePool.
+func (sp *podStoragePool) ID() string {
+	return sp.id
+}
+
+// Name implements PodStoragePool.
+func (sp *podStoragePool) Name() string {
+	return sp.name
+}
+
+// Type implements PodStoragePool.
+func (sp *podStoragePool) Type() string {
+	return sp.poolType
+}
+
+// Path implements PodStoragePool.
+func (sp *podStoragePool) Path() string {
+	return sp.path
+}
+
+// Total implements PodStoragePool.
+func (sp *podStoragePool) Total() uint64 {
+	return sp.total
+}
+
+// Used implements PodStoragePool.
+func (sp *podStoragePool) Used() uint64 {
+	return sp.used
+}
+
+// Available implements PodStoragePool.
+func (sp *podStoragePool) Available() uint64 {
+	return sp.available
+}
+
+// Default implements PodStoragePool.
+func (sp *podStoragePool) Default() bool {
+	return sp.defaultPool
+}
+
+type virtualMachine struct {
+	controller *controller
+
+	id      int
+	podID   int
+	podName string
+}
+
+// ID implements VirtualMachine.
+func (vm *virtualMachine) ID() int {
+	return vm.id
+}
+
+// PodID implements VirtualMachine.
+func (vm *virtualMachine) PodID() int {
+	return vm.podID
+}
+
+// PodName implements VirtualMachine.
+func (vm *virtualMachine) PodName() string {
+	return vm.podName
+}
+
+// Pod implements VirtualMachine.
+func (vm *virtualMachine) Pod() (Pod, error) {
+	return vm.controller.Pod(vm.podID)
+}
+
+// Pod implements Controller.
+func (c *controller) Pod(id int) (Pod, error) {
+	source, err := c.get(APIPath(PodsPath, id))
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	p, err := readPod(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	p.controller = c
+	return p, nil
+}
+
+// CreatePodArgs is an argument struct for calling Controller.CreatePod.
+type CreatePodArgs struct {
+	// Type is the type of the VM host, such as "virsh" or "lxd".
+	Type string
+	// PowerAddress is where MAAS reaches the host, such as
+	// "qemu+ssh://ubuntu@10.0.0.5/system" for virsh.
+	PowerAddress  string
+	PowerUser     string
+	PowerPassword string
+
+	Name string
+	Tags []string
+	Zone string
+	Pool string
+}
+
+// Validate ensures that the type and power address are set.
+func (a *CreatePodArgs) Validate() error {
+	if a.Type == "" {
+		return errors.NotValidf("missing Type")
+	}
+	if a.PowerAddress == "" {
+		return errors.NotValidf("missing PowerAddress")
+	}
+	return nil
+}
+
+// CreatePod implements Controller.
+func (c *controller) CreatePod(args CreatePodArgs) (Pod, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("type", args.Type)
+	params.Values.Add("power_address", args.PowerAddress)
+	params.MaybeAdd("power_user", args.PowerUser)
+	params.MaybeAdd("power_pass", args.PowerPassword)
+	params.MaybeAdd("name", args.Name)
+	if len(args.Tags) > 0 {
+		params.Values.Add("tags", strings.Join(args.Tags, ","))
+	}
+	params.MaybeAdd("zone", args.Zone)
+	params.MaybeAdd("pool", args.Pool)
+	source, err := c.post(PodsPath, "", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return nil, errors.NewNotSupported(err, "pods")
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	p, err := readPod(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	p.controller = c
+	return p, nil
+}
+
+// ComposeMachineArgs is an argument struct for calling Pod.Compose. Zero
+// values are chosen by the server.
+type ComposeMachineArgs struct {
+	Hostname     string
+	Architecture string
+	Cores        int
+	// Memory is in MiB.
+	Memory int
+	// CPUSpeed is in MHz.
+	CPUSpeed int
+	// PinnedCores are the host cores to pin the machine to, such as
+	// those picked by SelectNUMACores. Cores may be left out, as the
+	// machine gets one core for each pinned core. It needs MAAS 2.9.
+	PinnedCores []int
+	// HugepagesBacked backs the memory of the machine with the huge
+	// pages of the host. It needs MAAS 2.9.
+	HugepagesBacked bool
+
+	// Storage lists the disks of the machine, with the size of each in
+	// GB. The tags of a disk name the storage pool to create it in.
+	Storage []StorageSpec
+	// Interfaces connects the machine to the spaces.
+	Interfaces []InterfaceSpec
+
+	Domain string
+	Zone   string
+	Pool   string
+}
+
+// Validate ensures that the resources are not negative and that the
+// storage and interface specs are valid.
+func (a *ComposeMachineArgs) Validate() error {
+	if a.Cores < 0 {
+		return errors.NotValidf("Cores %d", a.Cores)
+	}
+	if a.Memory < 0 {
+		return errors.NotValidf("Memory %d", a.Memory)
+	}
+	if a.CPUSpeed < 0 {
+		return errors.NotValidf("CPUSpeed %d", a.CPUSpeed)
+	}
+	if len(a.PinnedCores) > 0 && a.Cores != 0 && a.Cores != len(a.PinnedCores) {
+		return errors.NotValidf("Cores %d with %d PinnedCores", a.Cores, len(a.PinnedCores))
+	}
+	for _, core := range a.PinnedCores {
+		if core < 0 {
+			return errors.NotValidf("PinnedCores %d", core)
+		}
+	}
+	for _, s := range a.Storage {
+		if err := s.Validate(); err != nil {
+			return errors.Annotatef(err, "Storage")
+		}
+	}
+	for _, spec := range a.Interfaces {
+		if err := spec.Validate(); err != nil {
+			return errors.Annotatef(err, "Interfaces")
+		}
+	}
+	return nil
+}
+
+func (a *ComposeMachineArgs) storage() string {
+	var values []string
+	for _, spec := range a.Storage {
+		values = append(values, spec.String())
+	}
+	return strings.Join(values, ",")
+}
+
+func (a *ComposeMachineArgs) interfaces() string {
+	var values []string
+	for _, spec := range a.Interfaces {
+		values = append(values, spec.String())
+	}
+	return strings.Join(values, ";")
+}
+
+// Compose implements Pod.
+func (p *pod) Compose(args ComposeMachineArgs) (Machine, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(args.PinnedCores) > 0 || args.HugepagesBacked {
+		if err := p.controller.requireVersion("pinning cores and huge pages", 2, 9); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	params := NewURLParams()
+	params.MaybeAdd("hostname", args.Hostname)
+	params.MaybeAdd("architecture", args.Architecture)
+	params.MaybeAddInt("cores", args.Cores)
+	params.MaybeAddInt("memory", args.Memory)
+	params.MaybeAddInt("cpu_speed", args.CPUSpeed)
+	for _, core := range args.PinnedCores {
+		params.Values.Add("pinned_cores", strconv.Itoa(core))
+	}
+	params.MaybeAddBool("hugepages_backed", args.HugepagesBacked)
+	params.MaybeAdd("storage", args.storage())
+	params.MaybeAdd("interfaces", args.interfaces())
+	params.MaybeAdd("domain", args.Domain)
+	params.MaybeAdd("zone", args.Zone)
+	params.MaybeAdd("pool", args.Pool)
+	source, err := p.controller.post(p.resourceURI, "compose", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+
+	// The response only identifies the new machine.
+	checker := schema.FieldMap(schema.Fields{"system_id": schema.String()}, nil)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "compose response schema check failed")
+	}
+	systemID := coerced.(map[string]interface{})["system_id"].(string)
+	machines, err := p.controller.Machines(MachinesArgs{SystemIDs: []string{systemID}})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(machines) != 1 {
+		return nil, NewUnexpectedError(errors.Errorf("composed machine %q not found", systemID))
+	}
+	return machines[0], nil
+}
+
+// Pods implements Controller.
+func (c *controller) Pods() ([]Pod, error) {
+	source, err := c.get(PodsPath)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, errors.NewNotSupported(err, "pods")
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	pods, err := readPods(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []Pod
+	for _, p := range pods {
+		p.controller = c
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+func readPod(controllerVersion version.Number, source interface{}) (*pod, error) {
+	readFunc, err := getPodDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "pod base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
+func readPods(controllerVersion version.Number, source interface{}) ([]*pod, error) {
+	readFunc, err := getPodDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, atPath(WrapWithDeserializationError(err, "pod base schema check failed"), "pods")
+	}
+	valid := coerced.([]interface{})
+	result, err := readPodList(valid, readFunc)
+	if err != nil {
+		return nil, atPath(err, "pods")
+	}
+	return result, nil
+}
+
+func getPodDeserializationFunc(controllerVersion version.Number) (podDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range podDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no pod read func for version %s", controllerVersion)
+	}
+	return podDeserializationFuncs[deserialisationVersion], nil
+}
+
+// readPodList expects the values of the sourceList to be string maps.
+func readPodList(sourceList []interface{}, readFunc podDeserializationFunc) ([]*pod, error) {
+	result := make([]*pod, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, atPath(NewDeserializationError("unexpected value for pod %d, %T", i, value), indexPath(i))
+		}
+		pod, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "pod %d", i)
+		}
+		result = append(result, pod)
+	}
+	return result, nil
+}
+
+type podDeserializationFunc func(map[string]interface{}) (*pod, error)
+
+var podDeserializationFuncs = map[version.Number]podDeserializationFunc{
+	twoDotOh: pod_2_0,
+}
+
+func pod_2_0(source map[string]interface{}) (*pod, error) {
+	resources := schema.FieldMap(schema.Fields{
+		"cores":         schema.ForceInt(),
+		"memory":        schema.ForceInt(),
+		"local_storage": schema.ForceUint(),
+	}, schema.Defaults{
+		"cores":         0,
+		"memory":        0,
+		"local_storage": uint64(0),
+	})
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+
+		"id":            schema.ForceInt(),
+		"name":          schema.String(),
+		"type":          schema.String(),
+		"architectures": schema.List(schema.String()),
+		"capabilities":  schema.List(schema.String()),
+		"tags":          schema.OneOf(schema.Nil(""), schema.List(schema.String())),
+		"zone":          schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+		"pool":          schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+		"host":          schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+
+		"total":     resources,
+		"used":      resources,
+		"available": resources,
+
+		"cpu_over_commit_ratio":    schema.Float(),
+		"memory_over_commit_ratio": schema.Float(),
+		"default_storage_pool":     schema.OneOf(schema.Nil(""), schema.String()),
+		"storage_pools":            schema.List(schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"resource_uri":  "",
+		"architectures": []interface{}{},
+		"capabilities":  []interface{}{},
+		"tags":          nil,
+		"zone":          nil,
+		"pool":          nil,
+		"host":          nil,
+		// Servers before 2.4 have no over-commit or storage pools.
+		"cpu_over_commit_ratio":    1.0,
+		"memory_over_commit_ratio": 1.0,
+		"default_storage_pool":     "",
+		"storage_pools":            []interface{}{},
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "pod 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	var zone *zone
+	if zoneMap, ok := valid["zone"].(map[string]interface{}); ok {
+		if zone, err = zone_2_0(zoneMap); err != nil {
+			return nil, errors.Trace(atPath(err, "zone"))
+		}
+	}
+
+	var pool *pool
+	if poolMap, ok := valid["pool"].(map[string]interface{}); ok {
+		if pool, err = pool_2_0(poolMap); err != nil {
+			return nil, errors.Trace(atPath(err, "pool"))
+		}
+	}
+
+	storagePools, err := readPodStoragePoolList(valid["storage_pools"].([]interface{}))
+	if err != nil {
+		return nil, errors.Trace(atPath(err, "storage_pools"))
+	}
+
+	var hostSystemID string
+	if hostMap, ok := valid["host"].(map[string]interface{}); ok {
+		hostSystemID, _ = hostMap["system_id"].(string)
+	}
+
+	defaultStoragePool, _ := valid["default_storage_pool"].(string)
+	result := &pod{
+		resourceURI: valid["resource_uri"].(string),
+
+		id:            valid["id"].(int),
+		name:          valid["name"].(string),
+		podType:       valid["type"].(string),
+		architectures: convertToStringSlice(valid["architectures"]),
+		capabilities:  convertToStringSlice(valid["capabilities"]),
+		tags:          convertToStringSlice(valid["tags"]),
+		zone:          zone,
+		pool:          pool,
+		hostSystemID:  hostSystemID,
+
+		total:     podResources(valid["total"]),
+		used:      podResources(valid["used"]),
+		available: podResources(valid["available"]),
+
+		cpuOverCommitRatio:    valid["cpu_over_commit_ratio"].(float64),
+		memoryOverCommitRatio: valid["memory_over_commit_ratio"].(float64),
+		defaultStoragePool:    defaultStoragePool,
+		storagePools:          storagePools,
+	}
+	return result, nil
+}
+
+// virtualMachine_2_0 reads the summary of its pod that a composed machine
+// carries. The ID of the virtual machine is read from the machine.
+func virtualMachine_2_0(source map[string]interface{}) (*virtualMachine, error) {
+	fields := schema.Fields{
+		"id":   schema.ForceInt(),
+		"name": schema.String(),
+	}
+	checker := schema.FieldMap(fields, nil)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "machine pod schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return &virtualMachine{
+		podID:   valid["id"].(int),
+		podName: valid["name"].(string),
+	}, nil
+}
+
+func podResources(value interface{}) PodResources {
+	valid := value.(map[string]interface{})
+	return PodResources{
+		Cores:        valid["cores"].(int),
+		Memory:       valid["memory"].(int),
+		LocalStorage: valid["local_storage"].(uint64),
+	}
+}
+
+func readPodStoragePoolList(sourceList []interface{}) ([]*podStoragePool, error) {
+	result := make([]*podStoragePool, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, atPath(NewDeserializationError("unexpected value for storage pool %d, %T", i, value), indexPath(i))
+		}
+		storagePool, err := podStoragePool_2_0(source)
+		if err != nil {
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "storage pool %d", i)
+		}
+		result = append(result, storagePool)
+	}
+	return result, nil
+}
+
+func podStoragePool_2_0(source map[string]interface{}) (*podStoragePool, error) {
+	fields := schema.Fields{
+		"id":        schema.String(),
+		"name":      schema.String(),
+		"type":      schema.String(),
+		"path":      schema.OneOf(schema.Nil(""), schema.String()),
+		"total":     schema.ForceUint(),
+		"used":      schema.ForceUint(),
+		"available": schema.ForceUint(),
+		"default":   schema.Bool(),
+	}
+	defaults := schema.Defaults{
+		"path":      "",
+		"used":      uint64(0),
+		"available": uint64(0),
+		"default":   false,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "storage pool 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	path, _ := valid["path"].(string)
+	result := &podStoragePool{
+		id:          valid["id"].(string),
+		name:        valid["name"].(string),
+		poolType:    valid["type"].(string),
+		path:        path,
+		total:       valid["total"].(uint64),
+		used:        valid["used"].(uint64),
+		available:   valid["available"].(uint64),
+		defaultPool: valid["default"].(bool),
+	}
+	return result, nil
+}
@@ -0,0 +1,307 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type podSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&podSuite{})
+
+func (*podSuite) TestReadPodsBadSchema(c *gc.C) {
+	_, err := readPods(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `pod base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*podSuite) TestReadPods(c *gc.C) {
+	pods, err := readPods(twoDotOh, parseJSON(c, podsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(pods, gc.HasLen, 2)
+
+	pod := pods[0]
+	c.Check(pod.ID(), gc.Equals, 1)
+	c.Check(pod.Name(), gc.Equals, "big-iron")
+	c.Check(pod.Type(), gc.Equals, "virsh")
+	c.Check(pod.Architectures(), jc.DeepEquals, []string{"amd64/generic"})
+	c.Check(pod.Capabilities(), jc.DeepEquals, []string{"composable", "dynamic_local_storage"})
+	c.Check(pod.Tags(), jc.DeepEquals, []string{"kvm"})
+	c.Check(pod.Zone().Name(), gc.Equals, "default")
+	c.Check(pod.Pool().Name(), gc.Equals, "default")
+	c.Check(pod.Total(), jc.DeepEquals, PodResources{Cores: 16, Memory: 65536, LocalStorage: 2000000000000})
+	c.Check(pod.Used(), jc.DeepEquals, PodResources{Cores: 4, Memory: 8192, LocalStorage: 500000000000})
+	c.Check(pod.Available(), jc.DeepEquals, PodResources{Cores: 44, Memory: 90112, LocalStorage: 1500000000000})
+	c.Check(pod.CPUOverCommitRatio(), gc.Equals, 3.0)
+	c.Check(pod.MemoryOverCommitRatio(), gc.Equals, 1.5)
+
+	storagePools := pod.StoragePools()
+	c.Assert(storagePools, gc.HasLen, 2)
+	c.Check(storagePools[1].ID(), gc.Equals, "a1b2")
+	c.Check(storagePools[1].Name(), gc.Equals, "fast")
+	c.Check(storagePools[1].Type(), gc.Equals, "lvm")
+	c.Check(storagePools[1].Path(), gc.Equals, "/dev/fast")
+	c.Check(storagePools[1].Total(), gc.Equals, uint64(1000000000000))
+	c.Check(storagePools[1].Used(), gc.Equals, uint64(100000000000))
+	c.Check(storagePools[1].Available(), gc.Equals, uint64(900000000000))
+	c.Check(storagePools[1].Default(), jc.IsTrue)
+	c.Check(pod.DefaultStoragePool().Name(), gc.Equals, "fast")
+}
+
+func (*podSuite) TestReadPodsOldServer(c *gc.C) {
+	pods, err := readPods(twoDotOh, parseJSON(c, podsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	pod := pods[1]
+	c.Check(pod.Tags(), gc.HasLen, 0)
+	c.Check(pod.Zone(), gc.IsNil)
+	c.Check(pod.Pool(), gc.IsNil)
+	c.Check(pod.CPUOverCommitRatio(), gc.Equals, 1.0)
+	c.Check(pod.MemoryOverCommitRatio(), gc.Equals, 1.0)
+	c.Check(pod.StoragePools(), gc.HasLen, 0)
+	c.Check(pod.DefaultStoragePool(), gc.IsNil)
+}
+
+func (*podSuite) TestLowVersion(c *gc.C) {
+	_, err := readPods(version.MustParse("1.9.0"), parseJSON(c, podsResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (s *podSuite) getServerAndPod(c *gc.C) (*SimpleTestServer, *pod) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/pods/", http.StatusOK, podsResponse)
+	pods, err := controller.Pods()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(pods, gc.HasLen, 2)
+	return server, pods[0].(*pod)
+}
+
+func (s *podSuite) TestPodsNotSupported(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	_, err := controller.Pods()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *podSuite) TestUpdate(c *gc.C) {
+	server, pod := s.getServerAndPod(c)
+	response := updateJSONMap(c, podResponse, map[string]interface{}{
+		"cpu_over_commit_ratio":    4.5,
+		"memory_over_commit_ratio": 2,
+		"default_storage_pool":     "f00d",
+	})
+	server.AddPutResponse(pod.resourceURI, http.StatusOK, response)
+	err := pod.Update(UpdatePodArgs{
+		CPUOverCommitRatio:    4.5,
+		MemoryOverCommitRatio: 2,
+		DefaultStoragePool:    "default",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(pod.CPUOverCommitRatio(), gc.Equals, 4.5)
+	c.Check(pod.MemoryOverCommitRatio(), gc.Equals, 2.0)
+	c.Check(pod.DefaultStoragePool().Name(), gc.Equals, "default")
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("cpu_over_commit_ratio"), gc.Equals, "4.5")
+	c.Check(form.Get("memory_over_commit_ratio"), gc.Equals, "2")
+	c.Check(form.Get("default_storage_pool"), gc.Equals, "f00d")
+	_, found := form["name"]
+	c.Check(found, jc.IsFalse)
+}
+
+func (s *podSuite) TestUpdateNoChangeNoRequest(c *gc.C) {
+	server, pod := s.getServerAndPod(c)
+	count := server.RequestCount()
+	err := pod.Update(UpdatePodArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *podSuite) TestUpdateValidates(c *gc.C) {
+	_, pod := s.getServerAndPod(c)
+	err := pod.Update(UpdatePodArgs{CPUOverCommitRatio: 11})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	err = pod.Update(UpdatePodArgs{MemoryOverCommitRatio: -1})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	err = pod.Update(UpdatePodArgs{DefaultStoragePool: "slow"})
+	c.Check(err, gc.ErrorMatches, `unknown storage pool "slow" not valid`)
+}
+
+func (s *podSuite) TestUpdateErrors(c *gc.C) {
+	server, pod := s.getServerAndPod(c)
+	server.AddPutResponse(pod.resourceURI, http.StatusBadRequest, "ratio too big")
+	server.AddPutResponse(pod.resourceURI, http.StatusForbidden, "bad user")
+	server.AddPutResponse(pod.resourceURI, http.StatusConflict, "wat?")
+
+	err := pod.Update(UpdatePodArgs{CPUOverCommitRatio: 2})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "ratio too big")
+	err = pod.Update(UpdatePodArgs{CPUOverCommitRatio: 2})
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	err = pod.Update(UpdatePodArgs{CPUOverCommitRatio: 2})
+	c.Check(err, jc.Satisfies, IsUnexpectedError)
+}
+
+func (s *podSuite) TestPod(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/pods/1/", http.StatusOK, podResponse)
+	server.AddGetResponse("/api/2.0/pods/9/", http.StatusNotFound, "No Pod matches the given query.")
+	pod, err := controller.Pod(1)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(pod.Name(), gc.Equals, "big-iron")
+	_, err = controller.Pod(9)
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *podSuite) TestCreatePod(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/pods/?op=", http.StatusOK, podResponse)
+	pod, err := controller.CreatePod(CreatePodArgs{
+		Type:         "virsh",
+		PowerAddress: "qemu+ssh://ubuntu@10.0.0.5/system",
+		Name:         "big-iron",
+		Tags:         []string{"kvm", "gpu"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(pod.ID(), gc.Equals, 1)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("type"), gc.Equals, "virsh")
+	c.Check(form.Get("power_address"), gc.Equals, "qemu+ssh://ubuntu@10.0.0.5/system")
+	c.Check(form.Get("name"), gc.Equals, "big-iron")
+	c.Check(form.Get("tags"), gc.Equals, "kvm,gpu")
+	_, found := form["power_pass"]
+	c.Check(found, jc.IsFalse)
+}
+
+func (s *podSuite) TestCreatePodValidates(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	_, err := controller.CreatePod(CreatePodArgs{PowerAddress: "10.0.0.5"})
+	c.Check(err, gc.ErrorMatches, "missing Type not valid")
+	_, err = controller.CreatePod(CreatePodArgs{Type: "lxd"})
+	c.Check(err, gc.ErrorMatches, "missing PowerAddress not valid")
+}
+
+func (s *podSuite) TestCreatePodUnreachable(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/pods/?op=", http.StatusBadRequest, "Failed talking to pod")
+	_, err := controller.CreatePod(CreatePodArgs{Type: "lxd", PowerAddress: "10.0.0.5"})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *podSuite) TestCompose(c *gc.C) {
+	server, pod := s.getServerAndPod(c)
+	server.AddPostResponse(pod.resourceURI+"?op=compose", http.StatusOK,
+		`{"system_id": "4y3ha3", "resource_uri": "/MAAS/api/2.0/machines/4y3ha3/"}`)
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineResponse+"]")
+	machine, err := pod.Compose(ComposeMachineArgs{
+		Hostname: "vm1",
+		Cores:    4,
+		Memory:   8192,
+		Storage: []StorageSpec{
+			{Label: "root", Size: 20, Tags: []string{"fast"}},
+			{Size: 100},
+		},
+		Interfaces: []InterfaceSpec{{Label: "eth0", Space: "dmz"}},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+
+	form := server.LastNRequests(2)[0].PostForm
+	c.Check(form.Get("hostname"), gc.Equals, "vm1")
+	c.Check(form.Get("cores"), gc.Equals, "4")
+	c.Check(form.Get("memory"), gc.Equals, "8192")
+	c.Check(form.Get("storage"), gc.Equals, "root:20(fast),100")
+	c.Check(form.Get("interfaces"), gc.Equals, "eth0:space=dmz")
+	_, found := form["cpu_speed"]
+	c.Check(found, jc.IsFalse)
+}
+
+func (s *podSuite) TestComposeValidates(c *gc.C) {
+	_, pod := s.getServerAndPod(c)
+	_, err := pod.Compose(ComposeMachineArgs{Cores: -1})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	_, err = pod.Compose(ComposeMachineArgs{Storage: []StorageSpec{{Label: "root"}}})
+	c.Check(err, gc.ErrorMatches, "Storage: Size value 0 not valid")
+}
+
+func (s *podSuite) TestComposeNoResources(c *gc.C) {
+	server, pod := s.getServerAndPod(c)
+	server.AddPostResponse(pod.resourceURI+"?op=compose", http.StatusBadRequest, "Not enough cores")
+	_, err := pod.Compose(ComposeMachineArgs{Cores: 64})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "Not enough cores")
+}
+
+const (
+	podResponse = `
+{
+    "id": 1,
+    "name": "big-iron",
+    "type": "virsh",
+    "resource_uri": "/MAAS/api/2.0/pods/1/",
+    "architectures": ["amd64/generic"],
+    "capabilities": ["composable", "dynamic_local_storage"],
+    "tags": ["kvm"],
+    "host": {"system_id": "4y3ha3", "__incomplete__": true},
+    "zone": {
+        "name": "default",
+        "description": "",
+        "id": 1,
+        "resource_uri": "/MAAS/api/2.0/zones/default/"
+    },
+    "pool": {
+        "name": "default",
+        "description": "",
+        "id": 0,
+        "resource_uri": "/MAAS/api/2.0/resourcepool/0/"
+    },
+    "total": {"cores": 16, "memory": 65536, "local_storage": 2000000000000, "local_disks": -1},
+    "used": {"cores": 4, "memory": 8192, "local_storage": 500000000000, "local_disks": -1},
+    "available": {"cores": 44, "memory": 90112, "local_storage": 1500000000000, "local_disks": -1},
+    "cpu_over_commit_ratio": 3.0,
+    "memory_over_commit_ratio": 1.5,
+    "default_storage_pool": "a1b2",
+    "storage_pools": [
+        {
+            "id": "f00d",
+            "name": "default",
+            "type": "dir",
+            "path": "/var/lib/libvirt/images",
+            "total": 1000000000000,
+            "used": 400000000000,
+            "available": 600000000000,
+            "default": false
+        }, {
+            "id": "a1b2",
+            "name": "fast",
+            "type": "lvm",
+            "path": "/dev/fast",
+            "total": 1000000000000,
+            "used": 100000000000,
+            "available": 900000000000,
+            "default": true
+        }
+    ]
+}
+`
+	podsResponse = "[" + podResponse + `, {
+    "id": 2,
+    "name": "old-iron",
+    "type": "virsh",
+    "resource_uri": "/MAAS/api/2.0/pods/2/",
+    "architectures": [],
+    "capabilities": ["fixed_local_storage"],
+    "total": {"cores": 4, "memory": 8192, "local_storage": 0},
+    "used": {"cores": 0, "memory": 0, "local_storage": 0},
+    "available": {"cores": 4, "memory": 8192, "local_storage": 0}
+}]`
+)
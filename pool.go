@@ -4,21 +4,31 @@
 package gomaasapi
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
 )
 
 type pool struct {
-	// Add the controller in when we need to do things with the pool.
-	// controller Controller
+	controller *controller
 
 	resourceURI string
 
+	id          int
 	name        string
 	description string
 }
 
+// ID implements Pool.
+func (p *pool) ID() int {
+	return p.id
+}
+
 // Name implements Pool.
 func (p *pool) Name() string {
 	return p.name
@@ -29,6 +39,187 @@ func (p *pool) Description() string {
 	return p.description
 }
 
+// Delete implements Pool.
+func (p *pool) Delete() error {
+	if err := p.controller.delete(p.resourceURI); err != nil {
+		return errors.Trace(poolError(err))
+	}
+	return nil
+}
+
+// CreatePool implements Controller.
+func (c *controller) CreatePool(name, description string) (Pool, error) {
+	if name == "" {
+		return nil, errors.NotValidf("missing name")
+	}
+	params := NewURLParams()
+	params.Values.Add("name", name)
+	params.MaybeAdd("description", description)
+	source, err := c.post(PoolsPath, "", params.Values)
+	if err != nil {
+		return nil, errors.Trace(poolError(err))
+	}
+	result, err := readPool(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result.controller = c
+	return result, nil
+}
+
+// poolError translates the errors of the resource pools API. Servers
+// before 2.5 have no pools, so the API gives a 404. A name that is taken
+// and the default pool, which cannot be deleted, give a 400.
+func poolError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			if strings.HasPrefix(svrErr.BodyMessage, "Unknown API endpoint") {
+				return errors.NewNotSupported(err, "resource pools")
+			}
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+// DefaultMoveBatchSize is the number of machines MoveMachinesToPool moves
+// between pauses when MoveMachinesToPoolArgs.BatchSize is not set.
+const DefaultMoveBatchSize = 10
+
+// MoveMachinesToPoolArgs is an argument struct for passing the machines and
+// the pool into MoveMachinesToPool.
+type MoveMachinesToPoolArgs struct {
+	SystemIDs []string
+	Pool      string
+
+	// BatchSize is the number of machines moved before pausing for
+	// BatchInterval, so that moving many machines does not flood the
+	// server. Zero means DefaultMoveBatchSize.
+	BatchSize int
+
+	// BatchInterval is the pause between batches. Zero means no pause.
+	BatchInterval time.Duration
+}
+
+// Validate ensures that there are machines and a pool.
+func (a *MoveMachinesToPoolArgs) Validate() error {
+	if len(a.SystemIDs) == 0 {
+		return errors.NotValidf("missing SystemIDs")
+	}
+	if a.Pool == "" {
+		return errors.NotValidf("missing Pool")
+	}
+	if a.BatchSize < 0 {
+		return errors.NotValidf("negative BatchSize")
+	}
+	if a.BatchInterval < 0 {
+		return errors.NotValidf("negative BatchInterval")
+	}
+	return nil
+}
+
+// MoveMachinesToPool implements Controller.
+func (c *controller) MoveMachinesToPool(args MoveMachinesToPoolArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := c.checkPoolExists(args.Pool); err != nil {
+		return errors.Trace(err)
+	}
+	batchSize := args.BatchSize
+	if batchSize == 0 {
+		batchSize = DefaultMoveBatchSize
+	}
+
+	result := NewBulkError()
+	for i, systemID := range args.SystemIDs {
+		if i > 0 && i%batchSize == 0 && args.BatchInterval > 0 {
+			<-c.clock.After(args.BatchInterval)
+		}
+		err := c.moveMachineToPool(systemID, args.Pool)
+		result.Add(systemID, err)
+		if IsPermissionError(err) {
+			// The other machines would be refused in the same way.
+			for _, remaining := range args.SystemIDs[i+1:] {
+				result.Add(remaining, err)
+			}
+			break
+		}
+	}
+	if result.HasFailures() {
+		return result
+	}
+	return nil
+}
+
+// checkPoolExists fails with an error satisfying errors.IsNotSupported if
+// the server has no resource pools, and with a NoMatchError if it has no
+// pool with the name.
+func (c *controller) checkPoolExists(name string) error {
+	source, err := c.get(PoolsPath)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok && svrErr.StatusCode == http.StatusNotFound {
+			return errors.NewNotSupported(err, "resource pools")
+		}
+		return NewUnexpectedError(err)
+	}
+	pools, err := readPools(c.schemaVersion(), source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, p := range pools {
+		if p.name == name {
+			return nil
+		}
+	}
+	return NewNoMatchError(fmt.Sprintf("no pool named %q", name))
+}
+
+func (c *controller) moveMachineToPool(systemID, pool string) error {
+	params := NewURLParams()
+	params.Values.Add("pool", pool)
+	_, err := c.put(APIPath(MachinesPath, systemID), params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	return nil
+}
+
+func readPool(controllerVersion version.Number, source interface{}) (*pool, error) {
+	var deserialisationVersion version.Number
+	for v := range poolDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, errors.Errorf("no pool read func for version %s", controllerVersion)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "pool base schema check failed")
+	}
+	readFunc := poolDeserializationFuncs[deserialisationVersion]
+	return readFunc(coerced.(map[string]interface{}))
+}
+
 func readPools(controllerVersion version.Number, source interface{}) ([]*pool, error) {
 	var deserialisationVersion version.Number
 
@@ -36,7 +227,7 @@ func readPools(controllerVersion version.Number, source interface{}) ([]*pool, e
 	coerced, err := checker.Coerce(source, nil)
 
 	if err != nil {
-		return nil, errors.Annotatef(err, "pool base schema check failed")
+		return nil, WrapWithDeserializationError(err, "pool base schema check failed")
 	}
 
 	valid := coerced.([]interface{})
@@ -62,11 +253,11 @@ func readPoolList(sourceList []interface{}, readFunc poolDeserializationFunc) ([
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, errors.Errorf("unexpected value for pool %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for pool %d, %T", i, value), indexPath(i))
 		}
 		pool, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "pool %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "pool %d", i)
 		}
 		result = append(result, pool)
 	}
@@ -81,22 +272,27 @@ var poolDeserializationFuncs = map[version.Number]poolDeserializationFunc{
 
 func pool_2_0(source map[string]interface{}) (*pool, error) {
 	fields := schema.Fields{
+		"id":           schema.ForceInt(),
 		"name":         schema.String(),
 		"description":  schema.String(),
 		"resource_uri": schema.String(),
 	}
+	defaults := schema.Defaults{
+		"id": 0,
+	}
 
-	checker := schema.FieldMap(fields, nil) // no defaults
+	checker := schema.FieldMap(fields, defaults)
 
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "pool 2.0 schema check failed")
+		return nil, WrapWithDeserializationError(err, "pool 2.0 schema check failed")
 	}
 	valid := coerced.(map[string]interface{})
 	// From here we know that the map returned from the schema coercion
 	// contains fields of the right type.
 
 	result := &pool{
+		id:          valid["id"].(int),
 		name:        valid["name"].(string),
 		description: valid["description"].(string),
 		resourceURI: valid["resource_uri"].(string),
@@ -4,12 +4,18 @@
 package gomaasapi
 
 import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
 )
 
-type poolSuite struct{}
+type poolSuite struct {
+	testing.LoggingCleanupSuite
+}
 
 var _ = gc.Suite(&poolSuite{})
 
@@ -29,6 +35,9 @@ func (*poolSuite) TestReadPools(c *gc.C) {
 
 	c.Assert(pools[1].Name(), gc.Equals, "swimming_is_fun")
 	c.Assert(pools[1].Description(), gc.Equals, "swimming is fun description")
+
+	c.Check(pools[0].ID(), gc.Equals, 0)
+	c.Check(pools[1].ID(), gc.Equals, 2)
 }
 
 // Pools were not introduced until 2.5.x
@@ -44,6 +53,54 @@ func (*poolSuite) TestHighVersion(c *gc.C) {
 	c.Assert(pools, gc.HasLen, 2)
 }
 
+func (s *poolSuite) TestCreatePool(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/pools/?op=", http.StatusOK, `{
+        "description": "racks in the basement",
+        "resource_uri": "/MAAS/api/2.0/resourcepool/3/",
+        "name": "basement",
+        "id": 3
+    }`)
+	p, err := controller.CreatePool("basement", "racks in the basement")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(p.ID(), gc.Equals, 3)
+	c.Check(p.Name(), gc.Equals, "basement")
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 2)
+	c.Check(form.Get("name"), gc.Equals, "basement")
+	c.Check(form.Get("description"), gc.Equals, "racks in the basement")
+
+	server.AddDeleteResponse("/MAAS/api/2.0/resourcepool/3/", http.StatusNoContent, "")
+	err = p.Delete()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *poolSuite) TestCreatePoolErrors(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	_, err := controller.CreatePool("", "")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+
+	server.AddPostResponse("/api/2.0/pools/?op=", http.StatusBadRequest, `{"name": ["Resource pool with this Name already exists."]}`)
+	_, err = controller.CreatePool("default", "")
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+
+	server.AddPostResponse("/api/2.0/pools/?op=", http.StatusNotFound, "Unknown API endpoint: /MAAS/api/2.0/pools/.")
+	_, err = controller.CreatePool("basement", "")
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *poolSuite) TestDeleteDefaultPool(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/pools/", http.StatusOK, poolResponse)
+	pools, err := controller.Pools()
+	c.Assert(err, jc.ErrorIsNil)
+
+	server.AddDeleteResponse("/MAAS/api/2.0/pools/default/", http.StatusBadRequest, "This pool is the default pool, it cannot be deleted.")
+	err = pools[0].Delete()
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
 var poolResponse = `
 [
     {
@@ -53,7 +110,8 @@ var poolResponse = `
     }, {
         "description": "swimming is fun description",
         "resource_uri": "/MAAS/api/2.0/pools/swimming_is_fun/",
-        "name": "swimming_is_fun"
+        "name": "swimming_is_fun",
+        "id": 2
     }
 ]
 `
@@ -0,0 +1,361 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// PowerDriver describes a power driver of the server, and the parameters
+// that it takes.
+type PowerDriver struct {
+	// Name is the power type, such as "ipmi" or "redfish".
+	Name        string
+	Description string
+	Fields      []PowerField
+}
+
+// PowerField describes a parameter of a power driver.
+type PowerField struct {
+	Name     string
+	Label    string
+	Required bool
+	// FieldType is the kind of value, such as "string", "password" or
+	// "choice".
+	FieldType string
+	Default   string
+	// Choices are the values allowed for a "choice" field.
+	Choices []string
+}
+
+// Field returns the field with the name, and false if the driver has no
+// such field.
+func (d PowerDriver) Field(name string) (PowerField, bool) {
+	for _, field := range d.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return PowerField{}, false
+}
+
+// Convert maps the power parameters of another driver to this driver. The
+// parameters that the drivers share, such as power_address, power_user and
+// power_pass, are kept and the others are dropped, so that fields left out
+// take their default on the server. A BMC address given as a URL, as
+// Redfish allows, is reduced to its host for the other drivers. The result
+// is checked against the fields of the driver, and an error satisfying
+// errors.IsNotValid is returned if a required field is missing or a value
+// is not one of the choices of its field.
+func (d PowerDriver) Convert(params map[string]string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, field := range d.Fields {
+		value := params[field.Name]
+		if value == "" {
+			if field.Required && field.Default == "" {
+				return nil, errors.NotValidf("missing %s for power type %q", field.Name, d.Name)
+			}
+			continue
+		}
+		if field.Name == "power_address" && d.Name != "redfish" && strings.Contains(value, "://") {
+			parsed, err := url.Parse(value)
+			if err != nil || parsed.Hostname() == "" {
+				return nil, errors.NotValidf("power_address %q", value)
+			}
+			value = parsed.Hostname()
+		}
+		if len(field.Choices) > 0 && !set.NewStrings(field.Choices...).Contains(value) {
+			return nil, errors.NotValidf("%s %q for power type %q", field.Name, value, d.Name)
+		}
+		result[field.Name] = value
+	}
+	return result, nil
+}
+
+// PowerDrivers implements Controller.
+func (c *controller) PowerDrivers() ([]PowerDriver, error) {
+	source, err := c.getOp(MachinesPath, "describe_power_types")
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	drivers, err := readPowerDrivers(source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return drivers, nil
+}
+
+// powerDriver returns the driver with the name from the server.
+func (c *controller) powerDriver(name string) (PowerDriver, error) {
+	drivers, err := c.PowerDrivers()
+	if err != nil {
+		return PowerDriver{}, errors.Trace(err)
+	}
+	for _, driver := range drivers {
+		if driver.Name == name {
+			return driver, nil
+		}
+	}
+	return PowerDriver{}, errors.NotValidf("power type %q", name)
+}
+
+// ConvertPowerTypeArgs is an argument struct for Machine.ConvertPowerType.
+type ConvertPowerTypeArgs struct {
+	// PowerType is the driver to convert to, such as "redfish".
+	PowerType string
+	// Parameters are set on top of the parameters kept from the current
+	// driver, for the fields that have no counterpart, such as the
+	// node_id of a Redfish system.
+	Parameters map[string]string
+	// SkipCheck saves the parameters without the server checking that it
+	// can reach the BMC with them.
+	SkipCheck bool
+}
+
+// Validate ensures that there is a power type.
+func (a *ConvertPowerTypeArgs) Validate() error {
+	if a.PowerType == "" {
+		return errors.NotValidf("missing PowerType")
+	}
+	return nil
+}
+
+// ConvertPowerType implements Machine.
+func (m *machine) ConvertPowerType(args ConvertPowerTypeArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return errors.Trace(err)
+	}
+	driver, err := m.controller.powerDriver(args.PowerType)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	current, err := m.PowerParameters()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for name, value := range args.Parameters {
+		if _, ok := driver.Field(name); !ok {
+			return errors.NotValidf("parameter %s for power type %q", name, driver.Name)
+		}
+		current[name] = value
+	}
+	converted, err := driver.Convert(current)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	params := NewURLParams()
+	params.Values.Add("power_type", driver.Name)
+	names := make([]string, 0, len(converted))
+	for name := range converted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		params.Values.Add("power_parameters_"+name, converted[name])
+	}
+	params.MaybeAddBool("power_parameters_skip_check", args.SkipCheck)
+	result, err := m.controller.put(m.resourceURI, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			case http.StatusConflict:
+				return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+
+	machine, err := readMachine(m.controller.schemaVersion(), result)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
+// The stop modes of PowerOffArgs.
+const (
+	StopModeHard = "hard"
+	StopModeSoft = "soft"
+)
+
+// PowerOnArgs is an argument struct for Machine.PowerOn.
+type PowerOnArgs struct {
+	// UserData is base64 encoded user data for the machine to run, as
+	// for StartArgs.UserData.
+	UserData string
+	Comment  string
+}
+
+// PowerOffArgs is an argument struct for Machine.PowerOff.
+type PowerOffArgs struct {
+	// StopMode is StopModeHard to cut the power, or StopModeSoft to ask
+	// the operating system to shut down. The server uses a hard stop if
+	// it is empty.
+	StopMode string
+	Comment  string
+}
+
+// Validate ensures that the stop mode is known.
+func (a *PowerOffArgs) Validate() error {
+	switch a.StopMode {
+	case "", StopModeHard, StopModeSoft:
+		return nil
+	}
+	return errors.NotValidf("StopMode %q", a.StopMode)
+}
+
+// PowerOn implements Machine.
+func (m *machine) PowerOn(args PowerOnArgs) error {
+	params := NewURLParams()
+	params.MaybeAdd("user_data", args.UserData)
+	params.MaybeAdd("comment", args.Comment)
+	return errors.Trace(m.power("power_on", params))
+}
+
+// PowerOff implements Machine.
+func (m *machine) PowerOff(args PowerOffArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.MaybeAdd("stop_mode", args.StopMode)
+	params.MaybeAdd("comment", args.Comment)
+	return errors.Trace(m.power("power_off", params))
+}
+
+// power posts the power op, and updates the machine from the response.
+func (m *machine) power(op string, params *URLParams) error {
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return errors.Trace(err)
+	}
+	result, err := m.controller.post(m.resourceURI, op, params.Values)
+	if err != nil {
+		return errors.Trace(powerError(err))
+	}
+	machine, err := readMachine(m.controller.schemaVersion(), result)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
+// QueryPowerState implements Machine.
+func (m *machine) QueryPowerState() (string, error) {
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return "", errors.Trace(err)
+	}
+	source, err := m.controller.getOp(m.resourceURI, "query_power_state")
+	if err != nil {
+		return "", errors.Trace(powerError(err))
+	}
+	checker := schema.FieldMap(schema.Fields{"state": schema.String()}, nil)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return "", WrapWithDeserializationError(err, "power state schema check failed")
+	}
+	m.powerState = coerced.(map[string]interface{})["state"].(string)
+	return m.powerState, nil
+}
+
+// powerError translates the errors of the power ops. The server answers
+// 409 if the machine is in a state that does not allow the op, and 503 if
+// the power driver could not reach the BMC.
+func powerError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		case http.StatusConflict, http.StatusServiceUnavailable:
+			return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+func readPowerDrivers(source interface{}) ([]PowerDriver, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "power driver base schema check failed")
+	}
+	fieldChecker := schema.FieldMap(schema.Fields{
+		"name":       schema.String(),
+		"label":      schema.String(),
+		"required":   schema.Bool(),
+		"field_type": schema.String(),
+		"default":    schema.Any(),
+		// Choices are pairs of value and label.
+		"choices": schema.List(schema.List(schema.Any())),
+	}, schema.Defaults{
+		"label":      "",
+		"required":   false,
+		"field_type": "string",
+		"default":    nil,
+		"choices":    []interface{}{},
+	})
+	driverChecker := schema.FieldMap(schema.Fields{
+		"name":        schema.String(),
+		"description": schema.String(),
+		"fields":      schema.List(fieldChecker),
+	}, schema.Defaults{
+		"description": "",
+		"fields":      []interface{}{},
+	})
+	var result []PowerDriver
+	for i, value := range coerced.([]interface{}) {
+		coerced, err := driverChecker.Coerce(value, nil)
+		if err != nil {
+			return nil, WrapWithDeserializationError(atPath(err, indexPath(i)), "power driver schema check failed")
+		}
+		valid := coerced.(map[string]interface{})
+		driver := PowerDriver{
+			Name:        valid["name"].(string),
+			Description: valid["description"].(string),
+		}
+		for _, f := range valid["fields"].([]interface{}) {
+			fieldMap := f.(map[string]interface{})
+			field := PowerField{
+				Name:      fieldMap["name"].(string),
+				Label:     fieldMap["label"].(string),
+				Required:  fieldMap["required"].(bool),
+				FieldType: fieldMap["field_type"].(string),
+			}
+			if value := fieldMap["default"]; value != nil {
+				field.Default = fmt.Sprint(value)
+			}
+			for _, choice := range fieldMap["choices"].([]interface{}) {
+				if pair := choice.([]interface{}); len(pair) > 0 {
+					field.Choices = append(field.Choices, fmt.Sprint(pair[0]))
+				}
+			}
+			driver.Fields = append(driver.Fields, field)
+		}
+		result = append(result, driver)
+	}
+	return result, nil
+}
@@ -0,0 +1,247 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type powerSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&powerSuite{})
+
+func (*powerSuite) TestReadPowerDriversBadSchema(c *gc.C) {
+	_, err := readPowerDrivers("wat?")
+	c.Assert(err.Error(), gc.Equals, `power driver base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*powerSuite) TestReadPowerDrivers(c *gc.C) {
+	drivers, err := readPowerDrivers(parseJSON(c, powerTypesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(drivers, gc.HasLen, 2)
+	c.Check(drivers[0].Name, gc.Equals, "ipmi")
+	c.Check(drivers[0].Description, gc.Equals, "IPMI")
+	field, ok := drivers[0].Field("power_driver")
+	c.Assert(ok, jc.IsTrue)
+	c.Check(field, jc.DeepEquals, PowerField{
+		Name:      "power_driver",
+		Label:     "Power driver",
+		FieldType: "choice",
+		Default:   "LAN_2_0",
+		Choices:   []string{"LAN", "LAN_2_0"},
+	})
+	field, ok = drivers[1].Field("power_address")
+	c.Assert(ok, jc.IsTrue)
+	c.Check(field.Required, jc.IsTrue)
+	_, ok = drivers[1].Field("power_driver")
+	c.Check(ok, jc.IsFalse)
+}
+
+func (*powerSuite) TestConvertToRedfish(c *gc.C) {
+	drivers, err := readPowerDrivers(parseJSON(c, powerTypesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	params, err := drivers[1].Convert(map[string]string{
+		"power_driver":  "LAN_2_0",
+		"power_address": "10.0.0.5",
+		"power_user":    "admin",
+		"power_pass":    "secret",
+		"mac_address":   "52:54:00:12:34:56",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(params, jc.DeepEquals, map[string]string{
+		"power_address": "10.0.0.5",
+		"power_user":    "admin",
+		"power_pass":    "secret",
+	})
+}
+
+func (*powerSuite) TestConvertToIPMI(c *gc.C) {
+	drivers, err := readPowerDrivers(parseJSON(c, powerTypesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	params, err := drivers[0].Convert(map[string]string{
+		"power_address": "https://10.0.0.5:443/redfish",
+		"power_user":    "admin",
+		"power_pass":    "secret",
+		"node_id":       "1",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(params, jc.DeepEquals, map[string]string{
+		"power_address": "10.0.0.5",
+		"power_user":    "admin",
+		"power_pass":    "secret",
+	})
+}
+
+func (*powerSuite) TestConvertValidates(c *gc.C) {
+	drivers, err := readPowerDrivers(parseJSON(c, powerTypesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = drivers[1].Convert(map[string]string{"power_user": "admin"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, `missing power_address for power type "redfish" not valid`)
+
+	_, err = drivers[0].Convert(map[string]string{"power_driver": "serial"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, `power_driver "serial" for power type "ipmi" not valid`)
+}
+
+func (s *machineSuite) TestConvertPowerType(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/machines/?op=describe_power_types", http.StatusOK, powerTypesResponse)
+	server.AddGetResponse(machine.resourceURI+"?op=power_parameters", http.StatusOK, `{
+		"power_driver": "LAN_2_0",
+		"power_address": "10.0.0.5",
+		"power_user": "admin",
+		"power_pass": "secret"
+	}`)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"power_type": "redfish",
+	})
+	server.AddPutResponse(machine.resourceURI, http.StatusOK, response)
+
+	err := machine.ConvertPowerType(ConvertPowerTypeArgs{
+		PowerType:  "redfish",
+		Parameters: map[string]string{"node_id": "1"},
+		SkipCheck:  true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.PowerType(), gc.Equals, "redfish")
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 6)
+	c.Check(form.Get("power_type"), gc.Equals, "redfish")
+	c.Check(form.Get("power_parameters_power_address"), gc.Equals, "10.0.0.5")
+	c.Check(form.Get("power_parameters_power_user"), gc.Equals, "admin")
+	c.Check(form.Get("power_parameters_power_pass"), gc.Equals, "secret")
+	c.Check(form.Get("power_parameters_node_id"), gc.Equals, "1")
+	c.Check(form.Get("power_parameters_skip_check"), gc.Equals, "true")
+}
+
+func (s *machineSuite) TestConvertPowerTypeUnknown(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/machines/?op=describe_power_types", http.StatusOK, powerTypesResponse)
+	err := machine.ConvertPowerType(ConvertPowerTypeArgs{PowerType: "wol"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, `power type "wol" not valid`)
+}
+
+func (s *machineSuite) TestConvertPowerTypeUnknownParameter(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/machines/?op=describe_power_types", http.StatusOK, powerTypesResponse)
+	server.AddGetResponse(machine.resourceURI+"?op=power_parameters", http.StatusOK, `{"power_address": "10.0.0.5"}`)
+	err := machine.ConvertPowerType(ConvertPowerTypeArgs{
+		PowerType:  "redfish",
+		Parameters: map[string]string{"k_g": "key"},
+	})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, `parameter k_g for power type "redfish" not valid`)
+}
+
+func (s *machineSuite) TestPowerOn(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"power_state": "on",
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=power_on", http.StatusOK, response)
+	err := machine.PowerOn(PowerOnArgs{Comment: "maintenance done"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.PowerState(), gc.Equals, "on")
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 1)
+	c.Check(form.Get("comment"), gc.Equals, "maintenance done")
+}
+
+func (s *machineSuite) TestPowerOff(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"power_state": "off",
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=power_off", http.StatusOK, response)
+	err := machine.PowerOff(PowerOffArgs{StopMode: StopModeSoft})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.PowerState(), gc.Equals, "off")
+	c.Check(server.LastRequest().PostForm.Get("stop_mode"), gc.Equals, "soft")
+}
+
+func (s *machineSuite) TestPowerOffValidates(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	err := machine.PowerOff(PowerOffArgs{StopMode: "gentle"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, `StopMode "gentle" not valid`)
+}
+
+func (s *machineSuite) TestPowerErrors(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=power_on", http.StatusConflict, "machine is not allocated")
+	server.AddPostResponse(machine.resourceURI+"?op=power_on", http.StatusForbidden, "not yours")
+	server.AddPostResponse(machine.resourceURI+"?op=power_off", http.StatusServiceUnavailable, "BMC timed out")
+
+	err := machine.PowerOn(PowerOnArgs{})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err.Error(), gc.Equals, "machine is not allocated")
+	err = machine.PowerOn(PowerOnArgs{})
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	err = machine.PowerOff(PowerOffArgs{})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+}
+
+func (s *machineSuite) TestQueryPowerState(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=query_power_state", http.StatusOK, `{"state": "off"}`)
+	server.AddGetResponse(machine.resourceURI+"?op=query_power_state", http.StatusServiceUnavailable, "BMC timed out")
+
+	state, err := machine.QueryPowerState()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(state, gc.Equals, "off")
+	c.Check(machine.PowerState(), gc.Equals, "off")
+
+	_, err = machine.QueryPowerState()
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+}
+
+const powerTypesResponse = `
+[
+    {
+        "driver_type": "power",
+        "name": "ipmi",
+        "description": "IPMI",
+        "chassis": false,
+        "fields": [
+            {"name": "power_driver", "label": "Power driver", "required": false, "field_type": "choice",
+             "choices": [["LAN", "LAN [IPMI 1.5]"], ["LAN_2_0", "LAN_2_0 [IPMI 2.0]"]], "default": "LAN_2_0", "scope": "bmc"},
+            {"name": "power_address", "label": "IP address", "required": false, "field_type": "string",
+             "choices": [], "default": "", "scope": "bmc"},
+            {"name": "power_user", "label": "Power user", "required": false, "field_type": "string",
+             "choices": [], "default": "", "scope": "bmc"},
+            {"name": "power_pass", "label": "Power password", "required": false, "field_type": "password",
+             "choices": [], "default": "", "scope": "bmc"},
+            {"name": "mac_address", "label": "Power MAC", "required": false, "field_type": "mac_address",
+             "choices": [], "default": "", "scope": "node"}
+        ]
+    },
+    {
+        "driver_type": "power",
+        "name": "redfish",
+        "description": "Redfish",
+        "chassis": false,
+        "fields": [
+            {"name": "power_address", "label": "Redfish address", "required": true, "field_type": "string",
+             "choices": [], "default": "", "scope": "bmc"},
+            {"name": "power_user", "label": "Redfish user", "required": false, "field_type": "string",
+             "choices": [], "default": "", "scope": "bmc"},
+            {"name": "power_pass", "label": "Redfish password", "required": false, "field_type": "password",
+             "choices": [], "default": "", "scope": "bmc"},
+            {"name": "node_id", "label": "Node ID", "required": false, "field_type": "string",
+             "choices": [], "default": "", "scope": "node"}
+        ]
+    }
+]
+`
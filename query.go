@@ -0,0 +1,203 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"strings"
+
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+)
+
+// nodeStatusNames maps the NodeStatus* values to the status names that
+// machines report.
+var nodeStatusNames = map[string]string{
+	NodeStatusDeclared:          "New",
+	NodeStatusCommissioning:     "Commissioning",
+	NodeStatusFailedTests:       "Failed commissioning",
+	NodeStatusMissing:           "Missing",
+	NodeStatusReady:             "Ready",
+	NodeStatusReserved:          "Reserved",
+	NodeStatusDeployed:          "Deployed",
+	NodeStatusRetired:           "Retired",
+	NodeStatusBroken:            "Broken",
+	NodeStatusDeploying:         "Deploying",
+	NodeStatusAllocated:         "Allocated",
+	NodeStatusFailedDeployment:  "Failed deployment",
+	NodeStatusReleasing:         "Releasing",
+	NodeStatusFailedReleasing:   "Releasing failed",
+	NodeStatusDiskErasing:       "Disk erasing",
+	NodeStatusFailedDiskErasing: "Failed disk erasing",
+}
+
+// MachineQuery builds a selection of machines from the filters that the
+// server applies, which make up its MachinesArgs, and predicates that are
+// checked on the machines returned. The methods return the query so that
+// calls can be chained, as in
+//
+//	Query().Zone("az1").Tag("gpu").Status(NodeStatusReady).MinMemoryGB(256)
+//
+// A query may be kept and run again.
+type MachineQuery struct {
+	args       MachinesArgs
+	predicates []func(Machine) bool
+}
+
+// Query returns an empty MachineQuery, which selects every machine.
+func Query() *MachineQuery {
+	return &MachineQuery{}
+}
+
+// Hostname selects machines with any of the hostnames.
+func (q *MachineQuery) Hostname(hostnames ...string) *MachineQuery {
+	q.args.Hostnames = append(q.args.Hostnames, hostnames...)
+	return q
+}
+
+// SystemID selects machines with any of the system IDs.
+func (q *MachineQuery) SystemID(systemIDs ...string) *MachineQuery {
+	q.args.SystemIDs = append(q.args.SystemIDs, systemIDs...)
+	return q
+}
+
+// MACAddress selects machines with any of the MAC addresses.
+func (q *MachineQuery) MACAddress(addresses ...string) *MachineQuery {
+	q.args.MACAddresses = append(q.args.MACAddresses, addresses...)
+	return q
+}
+
+// Domain selects machines in the domain.
+func (q *MachineQuery) Domain(domain string) *MachineQuery {
+	q.args.Domain = domain
+	return q
+}
+
+// Zone selects machines in the zone.
+func (q *MachineQuery) Zone(zone string) *MachineQuery {
+	q.args.Zone = zone
+	return q
+}
+
+// Pool selects machines in the resource pool.
+func (q *MachineQuery) Pool(pool string) *MachineQuery {
+	q.args.Pool = pool
+	return q
+}
+
+// AgentName selects machines allocated with the agent name.
+func (q *MachineQuery) AgentName(agentName string) *MachineQuery {
+	q.args.AgentName = agentName
+	return q
+}
+
+// OwnerData selects machines with the owner data value.
+func (q *MachineQuery) OwnerData(key, value string) *MachineQuery {
+	if q.args.OwnerData == nil {
+		q.args.OwnerData = make(map[string]string)
+	}
+	q.args.OwnerData[key] = value
+	return q
+}
+
+// NodeType selects machines of any of the node types.
+func (q *MachineQuery) NodeType(nodeTypes ...NodeType) *MachineQuery {
+	q.args.NodeTypes = append(q.args.NodeTypes, nodeTypes...)
+	return q
+}
+
+// InterfaceTag selects machines with an interface that has all of the tags.
+func (q *MachineQuery) InterfaceTag(tags ...string) *MachineQuery {
+	q.args.InterfaceTags = append(q.args.InterfaceTags, tags...)
+	return q
+}
+
+// Tag selects machines with all of the tags.
+func (q *MachineQuery) Tag(tags ...string) *MachineQuery {
+	required := set.NewStrings(tags...)
+	return q.Where(func(m Machine) bool {
+		return required.Difference(set.NewStrings(m.Tags()...)).IsEmpty()
+	})
+}
+
+// Status selects machines in any of the statuses, which are NodeStatus*
+// values or status names such as "Ready".
+func (q *MachineQuery) Status(statuses ...string) *MachineQuery {
+	names := set.NewStrings()
+	for _, status := range statuses {
+		if name, ok := nodeStatusNames[status]; ok {
+			status = name
+		}
+		names.Add(status)
+	}
+	return q.Where(func(m Machine) bool {
+		return names.Contains(m.StatusName())
+	})
+}
+
+// PowerState selects machines in any of the power states, such as "on".
+func (q *MachineQuery) PowerState(states ...string) *MachineQuery {
+	values := set.NewStrings(states...)
+	return q.Where(func(m Machine) bool {
+		return values.Contains(m.PowerState())
+	})
+}
+
+// Architecture selects machines with the architecture, such as "amd64",
+// whatever the subarchitecture.
+func (q *MachineQuery) Architecture(arch string) *MachineQuery {
+	return q.Where(func(m Machine) bool {
+		return m.Architecture() == arch || strings.HasPrefix(m.Architecture(), arch+"/")
+	})
+}
+
+// MinCPUCount selects machines with at least the number of CPUs.
+func (q *MachineQuery) MinCPUCount(count int) *MachineQuery {
+	return q.Where(func(m Machine) bool {
+		return m.CPUCount() >= count
+	})
+}
+
+// MinMemoryGB selects machines with at least the memory, in GiB.
+func (q *MachineQuery) MinMemoryGB(gb int) *MachineQuery {
+	return q.Where(func(m Machine) bool {
+		return m.Memory() >= gb*1024
+	})
+}
+
+// Where selects machines for which the predicate returns true.
+func (q *MachineQuery) Where(predicate func(Machine) bool) *MachineQuery {
+	q.predicates = append(q.predicates, predicate)
+	return q
+}
+
+// Args returns the part of the query that the server applies.
+func (q *MachineQuery) Args() MachinesArgs {
+	return q.args
+}
+
+// Matches returns true if the machine satisfies the predicates of the
+// query. It does not check the Args.
+func (q *MachineQuery) Matches(m Machine) bool {
+	for _, predicate := range q.predicates {
+		if !predicate(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// Run returns the machines selected by the query.
+func (q *MachineQuery) Run(c Controller) ([]Machine, error) {
+	machines, err := c.Machines(q.args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []Machine
+	for _, m := range machines {
+		if q.Matches(m) {
+			result = append(result, m)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type querySuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&querySuite{})
+
+func (*querySuite) TestArgs(c *gc.C) {
+	args := Query().
+		Zone("az1").
+		Pool("gpu-pool").
+		Hostname("a", "b").
+		OwnerData("owner", "ci").
+		NodeType(NodeTypeMachine).
+		InterfaceTag("sriov").
+		Args()
+	c.Check(args, jc.DeepEquals, MachinesArgs{
+		Zone:          "az1",
+		Pool:          "gpu-pool",
+		Hostnames:     []string{"a", "b"},
+		OwnerData:     map[string]string{"owner": "ci"},
+		NodeTypes:     []NodeType{NodeTypeMachine},
+		InterfaceTags: []string{"sriov"},
+	})
+}
+
+func (*querySuite) TestMatches(c *gc.C) {
+	big := NewTestMachine(MachineSpec{
+		Tags:         []string{"gpu", "nvme"},
+		StatusName:   "Ready",
+		PowerState:   "off",
+		Architecture: "amd64/generic",
+		Memory:       512 * 1024,
+		CPUCount:     64,
+	})
+	small := NewTestMachine(MachineSpec{
+		Tags:         []string{"gpu"},
+		StatusName:   "Deployed",
+		PowerState:   "on",
+		Architecture: "arm64/generic",
+		Memory:       16 * 1024,
+		CPUCount:     8,
+	})
+	for i, test := range []struct {
+		query *MachineQuery
+		big   bool
+		small bool
+	}{{
+		query: Query(),
+		big:   true,
+		small: true,
+	}, {
+		query: Query().Tag("gpu"),
+		big:   true,
+		small: true,
+	}, {
+		query: Query().Tag("gpu", "nvme"),
+		big:   true,
+	}, {
+		query: Query().Status(NodeStatusReady),
+		big:   true,
+	}, {
+		query: Query().Status("Deployed", NodeStatusAllocated),
+		small: true,
+	}, {
+		query: Query().PowerState("on"),
+		small: true,
+	}, {
+		query: Query().Architecture("amd64"),
+		big:   true,
+	}, {
+		query: Query().MinMemoryGB(256),
+		big:   true,
+	}, {
+		query: Query().MinCPUCount(8).Where(func(m Machine) bool { return m.PowerState() == "on" }),
+		small: true,
+	}, {
+		query: Query().Tag("gpu").Status(NodeStatusReady).MinMemoryGB(1024),
+	}} {
+		c.Logf("test %d", i)
+		c.Check(test.query.Matches(big), gc.Equals, test.big)
+		c.Check(test.query.Matches(small), gc.Equals, test.small)
+	}
+}
+
+func (s *querySuite) TestRun(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?zone=default", http.StatusOK, machinesResponse)
+	server.AddGetResponse("/api/2.0/machines/?zone=default", http.StatusOK, machinesResponse)
+	query := Query().Zone("default").Tag("virtual")
+	machines, err := query.Run(controller)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines, gc.HasLen, 3)
+
+	machines, err = query.Tag("missing").Run(controller)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines, gc.HasLen, 0)
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// resetEpochThreshold separates the two forms of the X-RateLimit-Reset
+// header: values below it are seconds from now, and values above it are
+// seconds since the epoch.
+const resetEpochThreshold = 1000000000
+
+// RateLimit is the request budget that the server, or a proxy in front of
+// it, reports in the headers of its responses. Schedulers can use it to
+// slow down before their requests are rejected with 429 responses.
+type RateLimit struct {
+	// Limit is the number of requests allowed in the window, or zero if
+	// the response did not say.
+	Limit int
+	// Remaining is the number of requests left in the window.
+	Remaining int
+	// Reset is when the budget is restored, or the zero time if the
+	// response did not say.
+	Reset time.Time
+}
+
+// ParseRateLimit reads the rate limit from the RateLimit-* headers of the
+// IETF draft, or the X-RateLimit-* headers that many proxies send. It
+// returns false if the header has no remaining count. Reset times given
+// in seconds are taken relative to now.
+func ParseRateLimit(header http.Header, now time.Time) (RateLimit, bool) {
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		remaining, err := strconv.Atoi(header.Get(prefix + "Remaining"))
+		if err != nil {
+			continue
+		}
+		result := RateLimit{Remaining: remaining}
+		if limit, err := strconv.Atoi(header.Get(prefix + "Limit")); err == nil {
+			result.Limit = limit
+		}
+		if reset, err := strconv.ParseInt(header.Get(prefix+"Reset"), 10, 64); err == nil {
+			if reset >= resetEpochThreshold {
+				result.Reset = time.Unix(reset, 0)
+			} else {
+				result.Reset = now.Add(time.Duration(reset) * time.Second)
+			}
+		}
+		return result, true
+	}
+	return RateLimit{}, false
+}
+
+// RateLimitObserver is told the rate limits reported by the server, see
+// Client.RateLimitObserver.
+type RateLimitObserver interface {
+	ObserveRateLimit(RateLimit)
+}
+
+// rateLimitTracker keeps the latest rate limit reported to a controller,
+// and passes each one on to the changed func.
+type rateLimitTracker struct {
+	changed func(RateLimit)
+
+	// mu guards latest.
+	mu     sync.Mutex
+	latest *RateLimit
+}
+
+// ObserveRateLimit implements RateLimitObserver.
+func (t *rateLimitTracker) ObserveRateLimit(limit RateLimit) {
+	t.mu.Lock()
+	t.latest = &limit
+	t.mu.Unlock()
+	if t.changed != nil {
+		t.changed(limit)
+	}
+}
+
+// RateLimit implements Controller.
+func (c *controller) RateLimit() (RateLimit, bool) {
+	c.rateLimits.mu.Lock()
+	defer c.rateLimits.mu.Unlock()
+	if c.rateLimits.latest == nil {
+		return RateLimit{}, false
+	}
+	return *c.rateLimits.latest, true
+}
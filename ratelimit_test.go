@@ -0,0 +1,141 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type rateLimitSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&rateLimitSuite{})
+
+func (*rateLimitSuite) TestParseRateLimit(c *gc.C) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, test := range []struct {
+		header   map[string]string
+		expected RateLimit
+		ok       bool
+	}{{
+		header: map[string]string{},
+	}, {
+		header: map[string]string{"RateLimit-Limit": "100"},
+	}, {
+		header: map[string]string{"RateLimit-Remaining": "lots"},
+	}, {
+		header:   map[string]string{"RateLimit-Limit": "100", "RateLimit-Remaining": "42", "RateLimit-Reset": "30"},
+		expected: RateLimit{Limit: 100, Remaining: 42, Reset: now.Add(30 * time.Second)},
+		ok:       true,
+	}, {
+		header:   map[string]string{"X-RateLimit-Remaining": "7"},
+		expected: RateLimit{Remaining: 7},
+		ok:       true,
+	}, {
+		header:   map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1551445200"},
+		expected: RateLimit{Remaining: 0, Reset: time.Unix(1551445200, 0)},
+		ok:       true,
+	}, {
+		// The draft headers win over the proxy ones.
+		header:   map[string]string{"RateLimit-Remaining": "1", "X-RateLimit-Remaining": "2"},
+		expected: RateLimit{Remaining: 1},
+		ok:       true,
+	}} {
+		c.Logf("test %d", i)
+		header := make(http.Header)
+		for name, value := range test.header {
+			header.Set(name, value)
+		}
+		limit, ok := ParseRateLimit(header, now)
+		c.Check(ok, gc.Equals, test.ok)
+		c.Check(limit, jc.DeepEquals, test.expected)
+	}
+}
+
+// newRateLimitServer returns a server for a controller that reports a
+// budget that goes down by one with each request, and refuses requests
+// once it is spent.
+func newRateLimitServer(limit int) *httptest.Server {
+	remaining := limit
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if remaining > 0 {
+			remaining--
+		}
+		writer.Header().Set("X-RateLimit-Limit", fmt.Sprint(limit))
+		writer.Header().Set("X-RateLimit-Remaining", fmt.Sprint(remaining))
+		writer.Header().Set("X-RateLimit-Reset", "60")
+		switch {
+		case remaining == 0:
+			writer.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(writer, "slow down")
+		case request.URL.Path == "/api/2.0/version/":
+			fmt.Fprint(writer, versionResponse)
+		case request.URL.Path == "/api/2.0/users/":
+			fmt.Fprint(writer, `"captain awesome"`)
+		default:
+			fmt.Fprint(writer, `[]`)
+		}
+	}))
+}
+
+func (s *rateLimitSuite) TestController(c *gc.C) {
+	server := newRateLimitServer(4)
+	defer server.Close()
+	var reported []RateLimit
+	clock := testing.NewClock(time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC))
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+		Clock:   clock,
+		RateLimitChanged: func(limit RateLimit) {
+			reported = append(reported, limit)
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	// Reading the version and checking the credentials used two requests.
+	c.Check(reported, gc.HasLen, 2)
+	limit, ok := controller.RateLimit()
+	c.Assert(ok, jc.IsTrue)
+	c.Check(limit, jc.DeepEquals, RateLimit{Limit: 4, Remaining: 2, Reset: clock.Now().Add(time.Minute)})
+
+	_, err = controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	limit, _ = controller.RateLimit()
+	c.Check(limit.Remaining, gc.Equals, 1)
+
+	// Rejected requests report the budget too.
+	_, err = controller.Zones()
+	c.Assert(err, gc.NotNil)
+	limit, _ = controller.RateLimit()
+	c.Check(limit.Remaining, gc.Equals, 0)
+	c.Check(reported, gc.HasLen, 4)
+}
+
+func (s *rateLimitSuite) TestControllerVisitDevices(c *gc.C) {
+	server := newRateLimitServer(4)
+	defer server.Close()
+	controller, err := NewController(ControllerArgs{BaseURL: server.URL, APIKey: "fake:as:key"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Streamed reads report the budget too.
+	err = controller.VisitDevices(DevicesArgs{}, func(Device) error { return nil })
+	c.Assert(err, jc.ErrorIsNil)
+	limit, ok := controller.RateLimit()
+	c.Assert(ok, jc.IsTrue)
+	c.Check(limit.Remaining, gc.Equals, 1)
+}
+
+func (s *rateLimitSuite) TestControllerWithoutHeaders(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	_, ok := controller.RateLimit()
+	c.Check(ok, jc.IsFalse)
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// ReadOption modifies how the response of a single listing call is
+// deserialized.
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	strict bool
+}
+
+// strictReading are the read options of calls that take none.
+var strictReading = readOptions{strict: true}
+
+// WithStrictParsing controls what happens when an item in a listing response
+// does not match the expected schema, for example because a field is missing
+// or has an unexpected type. When strict, which is the default, the whole call
+// fails with a DeserializationError. When not strict, the item degrades
+// gracefully so that clusters with mixed region and rack versions that emit
+// inconsistent payloads can still be read: fields that are missing or of an
+// unexpected type take their zero value, and nested values that cannot be
+// read, such as the zone, are left out. Items that cannot be read even so,
+// such as nodes of an unknown type, are logged and skipped.
+func WithStrictParsing(strict bool) ReadOption {
+	return func(o *readOptions) {
+		o.strict = strict
+	}
+}
+
+func collectReadOptions(options []ReadOption) readOptions {
+	result := strictReading
+	for _, option := range options {
+		option(&result)
+	}
+	return result
+}
+
+// zeroValues are tried in order for a field that cannot be coerced, the
+// first one its checker accepts is used.
+var zeroValues = []interface{}{nil, "", 0, false, []interface{}{}, map[string]interface{}{}}
+
+// coerce checks the source of an item against the fields, filling in the
+// defaults. When not strict, fields that are missing or cannot be coerced
+// are logged and set to a zero value their checker accepts.
+func (o readOptions) coerce(what string, fields schema.Fields, defaults schema.Defaults, source map[string]interface{}) (map[string]interface{}, error) {
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err == nil {
+		return coerced.(map[string]interface{}), nil
+	}
+	if o.strict {
+		return nil, err
+	}
+	lenient := make(map[string]interface{}, len(source))
+	for name, value := range source {
+		lenient[name] = value
+	}
+	for name, field := range fields {
+		value, found := source[name]
+		if _, hasDefault := defaults[name]; !found && hasDefault {
+			continue
+		}
+		if _, fieldErr := field.Coerce(value, []string{name}); found && fieldErr == nil {
+			continue
+		}
+		for _, zero := range zeroValues {
+			if _, zeroErr := field.Coerce(zero, nil); zeroErr == nil {
+				deserializeLogger.Warningf("%s: using %#v for %s", what, zero, name)
+				lenient[name] = zero
+				break
+			}
+		}
+	}
+	coerced, err = checker.Coerce(lenient, nil)
+	if err != nil {
+		return nil, err
+	}
+	return coerced.(map[string]interface{}), nil
+}
+
+// omit returns nil, after logging err, when not strict, so that the field of
+// an item that err failed to read is left out instead of failing the item.
+func (o readOptions) omit(err error, what, field string) error {
+	if err == nil || o.strict {
+		return err
+	}
+	deserializeLogger.Warningf("%s: leaving out %s: %v", what, field, err)
+	return nil
+}
+
+// item handles the error of reading item i of a listing. It reports whether
+// to skip the item, which is only the case when not strict, and returns the
+// annotated error otherwise.
+func (o readOptions) item(err error, what string, i int, path string) (bool, error) {
+	if err == nil {
+		return false, nil
+	}
+	if !o.strict {
+		deserializeLogger.Warningf("skipping %s %d: %v", what, i, err)
+		return true, nil
+	}
+	return false, errors.Annotatef(atPath(err, path), "%s %d", what, i)
+}
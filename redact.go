@@ -0,0 +1,110 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue replaces the values of sensitive fields in logs and
+// errors.
+const RedactedValue = "REDACTED"
+
+// IsSensitiveField returns true if the field, such as a power parameter or
+// a request parameter, holds a credential: a password, a passphrase, a
+// secret, an SNMP community or the IPMI BMC key k_g. Power parameters are
+// matched with or without their "power_parameters_" prefix.
+func IsSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	name = strings.TrimPrefix(name, "power_parameters_")
+	for _, part := range []string{"password", "passphrase", "secret"} {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return name == "pass" || name == "k_g" || strings.HasSuffix(name, "_pass") || strings.HasSuffix(name, "community")
+}
+
+// RedactParams returns a copy of the values with those of sensitive fields
+// replaced by RedactedValue.
+func RedactParams(values url.Values) url.Values {
+	if values == nil {
+		return nil
+	}
+	result := make(url.Values, len(values))
+	for name, value := range values {
+		if IsSensitiveField(name) {
+			redacted := make([]string, len(value))
+			for i := range redacted {
+				redacted[i] = RedactedValue
+			}
+			value = redacted
+		}
+		result[name] = value
+	}
+	return result
+}
+
+// RedactJSON returns the JSON document with the values of sensitive fields,
+// at any depth, replaced by RedactedValue. Use it before writing out the
+// raw JSON of a machine or its power parameters. Data that isn't JSON is
+// redacted as by RedactText.
+func RedactJSON(data []byte) []byte {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []byte(RedactText(string(data)))
+	}
+	result, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return []byte(RedactText(string(data)))
+	}
+	return result
+}
+
+func redactValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if IsSensitiveField(key) && item != nil {
+				value[key] = RedactedValue
+			} else {
+				value[key] = redactValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+var (
+	jsonFieldPattern = regexp.MustCompile(`"([\w.-]+)"(\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	formFieldPattern = regexp.MustCompile(`\b([\w.-]+)=([^&\s"]*)`)
+)
+
+// RedactText returns the text with the values of sensitive fields replaced
+// by RedactedValue, where they appear as JSON string fields or as
+// name=value pairs. It is for text that may not be whole JSON documents,
+// such as the bodies of error responses and log lines.
+func RedactText(text string) string {
+	text = jsonFieldPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := jsonFieldPattern.FindStringSubmatch(match)
+		if !IsSensitiveField(parts[1]) {
+			return match
+		}
+		return `"` + parts[1] + `"` + parts[2] + `"` + RedactedValue + `"`
+	})
+	return formFieldPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := formFieldPattern.FindStringSubmatch(match)
+		if !IsSensitiveField(parts[1]) {
+			return match
+		}
+		return parts[1] + "=" + RedactedValue
+	})
+}
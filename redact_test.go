@@ -0,0 +1,83 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type redactSuite struct{}
+
+var _ = gc.Suite(&redactSuite{})
+
+func (*redactSuite) TestIsSensitiveField(c *gc.C) {
+	for _, name := range []string{
+		"power_pass", "power_parameters_power_pass", "password", "Password",
+		"power_password", "privacy_passphrase", "token_secret", "snmp_community",
+		"k_g", "power_parameters_k_g",
+	} {
+		c.Check(IsSensitiveField(name), jc.IsTrue, gc.Commentf(name))
+	}
+	for _, name := range []string{
+		"power_user", "power_address", "hostname", "bypass", "passive", "token_key",
+		"power_k_g_type",
+	} {
+		c.Check(IsSensitiveField(name), jc.IsFalse, gc.Commentf(name))
+	}
+}
+
+func (*redactSuite) TestRedactParams(c *gc.C) {
+	values := url.Values{
+		"power_parameters_power_pass": {"hunter2"},
+		"power_parameters_power_user": {"admin"},
+	}
+	redacted := RedactParams(values)
+	c.Check(redacted, jc.DeepEquals, url.Values{
+		"power_parameters_power_pass": {RedactedValue},
+		"power_parameters_power_user": {"admin"},
+	})
+	// The values are not changed.
+	c.Check(values.Get("power_parameters_power_pass"), gc.Equals, "hunter2")
+	c.Check(RedactParams(nil), gc.IsNil)
+}
+
+func (*redactSuite) TestRedactJSON(c *gc.C) {
+	redacted := RedactJSON([]byte(`{"power_type": "ipmi", "power_parameters": {"power_user": "admin", "power_pass": "hunter2"}, "list": [{"password": "x"}]}`))
+	c.Check(string(redacted), gc.Equals,
+		`{"list":[{"password":"REDACTED"}],"power_parameters":{"power_pass":"REDACTED","power_user":"admin"},"power_type":"ipmi"}`)
+}
+
+func (*redactSuite) TestRedactBMCKey(c *gc.C) {
+	c.Check(RedactParams(url.Values{"power_parameters_k_g": {"0x1234"}}).Get("power_parameters_k_g"), gc.Equals, RedactedValue)
+	c.Check(string(RedactJSON([]byte(`{"power_parameters": {"k_g": "0x1234"}}`))), gc.Equals, `{"power_parameters":{"k_g":"REDACTED"}}`)
+	c.Check(RedactText(`{"k_g": "0x1234"}`), gc.Equals, `{"k_g": "REDACTED"}`)
+}
+
+func (*redactSuite) TestRedactJSONNotJSON(c *gc.C) {
+	c.Check(string(RedactJSON([]byte(`power_pass=hunter2`))), gc.Equals, "power_pass=REDACTED")
+}
+
+func (*redactSuite) TestRedactText(c *gc.C) {
+	for _, test := range []struct {
+		text     string
+		expected string
+	}{{
+		text:     `{"power_pass": "hun\"ter2", "power_user": "admin"}`,
+		expected: `{"power_pass": "REDACTED", "power_user": "admin"}`,
+	}, {
+		text:     `power_parameters_power_pass=hunter2&power_parameters_power_user=admin`,
+		expected: `power_parameters_power_pass=REDACTED&power_parameters_power_user=admin`,
+	}, {
+		text:     `Unable to connect to BMC with password=hunter2 for admin`,
+		expected: `Unable to connect to BMC with password=REDACTED for admin`,
+	}, {
+		text:     `No machine matches.`,
+		expected: `No machine matches.`,
+	}} {
+		c.Check(RedactText(test.text), gc.Equals, test.expected)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+)
+
+// rescueStatuses are the statuses of a machine that is in rescue mode, or
+// on its way in or out of it. Rescue mode is left from any of them.
+var rescueStatuses = set.NewStrings(
+	"Entering rescue mode",
+	"Failed to enter rescue mode",
+	"Rescue mode",
+	"Exiting rescue mode",
+	"Failed to exit rescue mode",
+)
+
+// enterRescueStatuses are the statuses other than the failed ones from
+// which a machine can be booted into rescue mode.
+var enterRescueStatuses = set.NewStrings(
+	"New",
+	"Ready",
+	"Allocated",
+	"Deployed",
+)
+
+// EnterRescueMode implements Machine.
+func (m *machine) EnterRescueMode() error {
+	status := m.StatusName()
+	if rescueStatuses.Contains(status) || !(enterRescueStatuses.Contains(status) || isFailedStatus(status)) {
+		return NewCannotCompleteError(fmt.Sprintf("machine %s is %q, cannot enter rescue mode", m.systemID, status))
+	}
+	return errors.Trace(m.rescue("rescue_mode"))
+}
+
+// ExitRescueMode implements Machine.
+func (m *machine) ExitRescueMode() error {
+	status := m.StatusName()
+	if !rescueStatuses.Contains(status) {
+		return NewCannotCompleteError(fmt.Sprintf("machine %s is %q, not in rescue mode", m.systemID, status))
+	}
+	return errors.Trace(m.rescue("exit_rescue_mode"))
+}
+
+// rescue posts the rescue op, and updates the machine from the response.
+func (m *machine) rescue(op string) error {
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return errors.Trace(err)
+	}
+	result, err := m.controller.post(m.resourceURI, op, nil)
+	if err != nil {
+		return errors.Trace(rescueError(err))
+	}
+	machine, err := readMachine(m.controller.schemaVersion(), result)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
+// rescueError translates the errors of the rescue ops. The server answers
+// 409 if the status of the machine changed since it was read, so that it
+// can no longer make the transition.
+func rescueError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		case http.StatusConflict, http.StatusServiceUnavailable:
+			return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *machineSuite) TestEnterRescueMode(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"status_name": "Entering rescue mode",
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=rescue_mode", http.StatusOK, response)
+	err := machine.EnterRescueMode()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.StatusName(), gc.Equals, "Entering rescue mode")
+
+	// Entering again is refused without asking the server.
+	count := server.RequestCount()
+	err = machine.EnterRescueMode()
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err, gc.ErrorMatches, `machine 4y3ha3 is "Entering rescue mode", cannot enter rescue mode`)
+	c.Check(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *machineSuite) TestEnterRescueModeFromFailedStatus(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.statusName = "Failed deployment"
+	server.AddPostResponse(machine.resourceURI+"?op=rescue_mode", http.StatusOK, machineResponse)
+	err := machine.EnterRescueMode()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *machineSuite) TestEnterRescueModeInvalidStatus(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	machine.statusName = "Commissioning"
+	err := machine.EnterRescueMode()
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+}
+
+func (s *machineSuite) TestEnterRescueModeConflict(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=rescue_mode", http.StatusConflict, "Node is releasing")
+	err := machine.EnterRescueMode()
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err.Error(), gc.Equals, "Node is releasing")
+}
+
+func (s *machineSuite) TestExitRescueMode(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	err := machine.ExitRescueMode()
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err, gc.ErrorMatches, `machine 4y3ha3 is "Deployed", not in rescue mode`)
+
+	machine.statusName = "Rescue mode"
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"status_name": "Exiting rescue mode",
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=exit_rescue_mode", http.StatusOK, response)
+	err = machine.ExitRescueMode()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.StatusName(), gc.Equals, "Exiting rescue mode")
+}
+
+func (s *machineSuite) TestExitRescueModeForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.statusName = "Failed to enter rescue mode"
+	server.AddPostResponse(machine.resourceURI+"?op=exit_rescue_mode", http.StatusForbidden, "Not the owner")
+	err := machine.ExitRescueMode()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+
+	"github.com/juju/errors"
+)
+
+// CommissioningResourcesScript is the commissioning script whose output is
+// the hardware of the machine in the machine-resources format, which is
+// the resources format of LXD. Servers before 2.9 do not run it.
+const CommissioningResourcesScript = "50-maas-01-commissioning"
+
+// MachineResources is the hardware of a machine as found by commissioning.
+// It has more detail than the machine itself, such as the caches and
+// flags of the CPUs and the features of the network cards. Sizes are in
+// bytes and frequencies in MHz.
+type MachineResources struct {
+	CPU     ResourcesCPU     `json:"cpu"`
+	Memory  ResourcesMemory  `json:"memory"`
+	Network ResourcesNetwork `json:"network"`
+	Storage ResourcesStorage `json:"storage"`
+}
+
+// ResourcesCPU is the CPUs of a machine.
+type ResourcesCPU struct {
+	Architecture string               `json:"architecture"`
+	Sockets      []ResourcesCPUSocket `json:"sockets"`
+	// Total is the number of threads.
+	Total uint64 `json:"total"`
+}
+
+// ResourcesCPUSocket is a CPU package.
+type ResourcesCPUSocket struct {
+	Name             string              `json:"name"`
+	Vendor           string              `json:"vendor"`
+	Socket           uint64              `json:"socket"`
+	Cache            []ResourcesCPUCache `json:"cache"`
+	Cores            []ResourcesCPUCore  `json:"cores"`
+	Frequency        uint64              `json:"frequency"`
+	FrequencyMinimum uint64              `json:"frequency_minimum"`
+	FrequencyTurbo   uint64              `json:"frequency_turbo"`
+}
+
+// ResourcesCPUCache is a cache of a CPU package.
+type ResourcesCPUCache struct {
+	Level uint64 `json:"level"`
+	// Type is "Data", "Instruction" or "Unified".
+	Type string `json:"type"`
+	Size uint64 `json:"size"`
+}
+
+// ResourcesCPUCore is a core of a CPU package.
+type ResourcesCPUCore struct {
+	Core      uint64               `json:"core"`
+	Die       uint64               `json:"die"`
+	Threads   []ResourcesCPUThread `json:"threads"`
+	Frequency uint64               `json:"frequency"`
+	// Flags are the features of the core, as in /proc/cpuinfo, such as
+	// "avx2" or "vmx".
+	Flags []string `json:"flags"`
+}
+
+// ResourcesCPUThread is a hardware thread of a core.
+type ResourcesCPUThread struct {
+	// ID is the number of the CPU to the kernel.
+	ID       int64  `json:"id"`
+	NUMANode uint64 `json:"numa_node"`
+	Thread   uint64 `json:"thread"`
+	Online   bool   `json:"online"`
+	Isolated bool   `json:"isolated"`
+}
+
+// ResourcesMemory is the memory of a machine.
+type ResourcesMemory struct {
+	Nodes          []ResourcesMemoryNode `json:"nodes"`
+	HugepagesTotal uint64                `json:"hugepages_total"`
+	HugepagesUsed  uint64                `json:"hugepages_used"`
+	HugepagesSize  uint64                `json:"hugepages_size"`
+	Used           uint64                `json:"used"`
+	Total          uint64                `json:"total"`
+}
+
+// ResourcesMemoryNode is the memory of a NUMA node.
+type ResourcesMemoryNode struct {
+	NUMANode       uint64 `json:"numa_node"`
+	HugepagesTotal uint64 `json:"hugepages_total"`
+	HugepagesUsed  uint64 `json:"hugepages_used"`
+	Used           uint64 `json:"used"`
+	Total          uint64 `json:"total"`
+}
+
+// ResourcesNetwork is the network cards of a machine.
+type ResourcesNetwork struct {
+	Cards []ResourcesNetworkCard `json:"cards"`
+	Total uint64                 `json:"total"`
+}
+
+// ResourcesNetworkCard is a network card, which may have several ports.
+type ResourcesNetworkCard struct {
+	Driver          string                 `json:"driver"`
+	DriverVersion   string                 `json:"driver_version"`
+	Ports           []ResourcesNetworkPort `json:"ports"`
+	SRIOV           *ResourcesNetworkSRIOV `json:"sriov"`
+	NUMANode        uint64                 `json:"numa_node"`
+	PCIAddress      string                 `json:"pci_address"`
+	Vendor          string                 `json:"vendor"`
+	VendorID        string                 `json:"vendor_id"`
+	Product         string                 `json:"product"`
+	ProductID       string                 `json:"product_id"`
+	FirmwareVersion string                 `json:"firmware_version"`
+}
+
+// ResourcesNetworkPort is a port of a network card.
+type ResourcesNetworkPort struct {
+	// ID is the name of the interface, such as "eth0".
+	ID              string   `json:"id"`
+	Address         string   `json:"address"`
+	Port            uint64   `json:"port"`
+	Protocol        string   `json:"protocol"`
+	SupportedModes  []string `json:"supported_modes"`
+	SupportedPorts  []string `json:"supported_ports"`
+	PortType        string   `json:"port_type"`
+	TransceiverType string   `json:"transceiver_type"`
+	AutoNegotiation bool     `json:"auto_negotiation"`
+	LinkDetected    bool     `json:"link_detected"`
+	// LinkSpeed is in Mbit/s.
+	LinkSpeed  uint64 `json:"link_speed"`
+	LinkDuplex string `json:"link_duplex"`
+}
+
+// ResourcesNetworkSRIOV is the SR-IOV virtual functions of a network
+// card that has them.
+type ResourcesNetworkSRIOV struct {
+	CurrentVFs uint64 `json:"current_vfs"`
+	MaximumVFs uint64 `json:"maximum_vfs"`
+}
+
+// ResourcesStorage is the disks of a machine.
+type ResourcesStorage struct {
+	Disks []ResourcesStorageDisk `json:"disks"`
+	Total uint64                 `json:"total"`
+}
+
+// ResourcesStorageDisk is a disk.
+type ResourcesStorageDisk struct {
+	// ID is the name of the disk, such as "sda".
+	ID              string `json:"id"`
+	Device          string `json:"device"`
+	Model           string `json:"model"`
+	Type            string `json:"type"`
+	ReadOnly        bool   `json:"read_only"`
+	Size            uint64 `json:"size"`
+	Removable       bool   `json:"removable"`
+	WWN             string `json:"wwn"`
+	NUMANode        uint64 `json:"numa_node"`
+	DevicePath      string `json:"device_path"`
+	BlockSize       uint64 `json:"block_size"`
+	FirmwareVersion string `json:"firmware_version"`
+	// RPM is zero for solid state disks.
+	RPM    uint64 `json:"rpm"`
+	Serial string `json:"serial"`
+}
+
+// ParseMachineResources reads the output of the
+// CommissioningResourcesScript. The output of the servers that wrap the
+// resources, with the networks of the machine, is read as well as that of
+// the bare machine-resources binary.
+func ParseMachineResources(output []byte) (*MachineResources, error) {
+	var wrapped struct {
+		Resources *MachineResources `json:"resources"`
+	}
+	if err := json.Unmarshal(output, &wrapped); err != nil {
+		return nil, NewDeserializationError("machine resources: %v", err)
+	}
+	if wrapped.Resources != nil {
+		return wrapped.Resources, nil
+	}
+	var result MachineResources
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, NewDeserializationError("machine resources: %v", err)
+	}
+	return &result, nil
+}
+
+// CommissioningResources implements Machine.
+func (m *machine) CommissioningResources() (*MachineResources, error) {
+	sets, err := m.ScriptResults(ScriptResultsArgs{
+		Type:          ScriptResultCommissioning,
+		IncludeOutput: true,
+		Filters:       []string{CommissioningResourcesScript},
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// The newest run of the commissioning scripts is first.
+	for _, set := range sets {
+		for _, r := range set.Results() {
+			if r.Name() != CommissioningResourcesScript || r.Status() != "Passed" {
+				continue
+			}
+			return ParseMachineResources(r.Output())
+		}
+	}
+	return nil, errors.NotFoundf("%s output for machine %s", CommissioningResourcesScript, m.systemID)
+}
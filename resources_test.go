@@ -0,0 +1,216 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type resourcesSuite struct{}
+
+var _ = gc.Suite(&resourcesSuite{})
+
+func (*resourcesSuite) TestParseMachineResources(c *gc.C) {
+	resources, err := ParseMachineResources([]byte(machineResourcesOutput))
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(resources.CPU.Architecture, gc.Equals, "x86_64")
+	c.Check(resources.CPU.Total, gc.Equals, uint64(2))
+	c.Assert(resources.CPU.Sockets, gc.HasLen, 1)
+	socket := resources.CPU.Sockets[0]
+	c.Check(socket.Name, gc.Equals, "Intel(R) Xeon(R) CPU E5-2680 v4 @ 2.40GHz")
+	c.Check(socket.Cache, jc.DeepEquals, []ResourcesCPUCache{
+		{Level: 1, Type: "Data", Size: 32768},
+		{Level: 3, Type: "Unified", Size: 36700160},
+	})
+	c.Assert(socket.Cores, gc.HasLen, 1)
+	c.Check(socket.Cores[0].Flags, jc.DeepEquals, []string{"avx2", "vmx"})
+	c.Check(socket.Cores[0].Threads, jc.DeepEquals, []ResourcesCPUThread{
+		{ID: 0, Thread: 0, Online: true},
+		{ID: 1, Thread: 1, Online: true},
+	})
+
+	c.Check(resources.Memory.Total, gc.Equals, uint64(8589934592))
+	c.Assert(resources.Memory.Nodes, gc.HasLen, 1)
+
+	c.Assert(resources.Network.Cards, gc.HasLen, 1)
+	card := resources.Network.Cards[0]
+	c.Check(card.Driver, gc.Equals, "ixgbe")
+	c.Check(card.SRIOV, jc.DeepEquals, &ResourcesNetworkSRIOV{CurrentVFs: 0, MaximumVFs: 63})
+	c.Assert(card.Ports, gc.HasLen, 1)
+	c.Check(card.Ports[0].ID, gc.Equals, "eth0")
+	c.Check(card.Ports[0].LinkSpeed, gc.Equals, uint64(10000))
+
+	c.Assert(resources.Storage.Disks, gc.HasLen, 1)
+	c.Check(resources.Storage.Disks[0].ID, gc.Equals, "sda")
+	c.Check(resources.Storage.Disks[0].RPM, gc.Equals, uint64(0))
+}
+
+func (*resourcesSuite) TestParseMachineResourcesWrapped(c *gc.C) {
+	output := fmt.Sprintf(`{"api_version": "1.0", "resources": %s, "networks": {}}`, machineResourcesOutput)
+	resources, err := ParseMachineResources([]byte(output))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(resources.CPU.Architecture, gc.Equals, "x86_64")
+	c.Check(resources.Storage.Disks, gc.HasLen, 1)
+}
+
+func (*resourcesSuite) TestParseMachineResourcesBad(c *gc.C) {
+	_, err := ParseMachineResources([]byte("Traceback (most recent call last):"))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+}
+
+func commissioningResultsResponse(status, output string) string {
+	return fmt.Sprintf(`
+[
+    {
+        "id": 3,
+        "system_id": "4y3ha3",
+        "type": 0,
+        "type_name": "Commissioning",
+        "status_name": "Passed",
+        "started": "Tue, 19 Nov. 2019 15:20:01",
+        "ended": "Tue, 19 Nov. 2019 15:22:10",
+        "resource_uri": "/MAAS/api/2.0/nodes/4y3ha3/results/3/",
+        "results": [
+            {
+                "id": 20,
+                "name": "50-maas-01-commissioning",
+                "status_name": %q,
+                "exit_status": 0,
+                "output": %q
+            }
+        ]
+    }
+]`, status, base64.StdEncoding.EncodeToString([]byte(output)))
+}
+
+const commissioningResourcesPath = "/api/2.0/nodes/4y3ha3/results/?filters=50-maas-01-commissioning&include_output=true&type=commissioning"
+
+func (s *machineSuite) TestCommissioningResources(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(commissioningResourcesPath, http.StatusOK, commissioningResultsResponse("Passed", machineResourcesOutput))
+	resources, err := machine.CommissioningResources()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(resources.Network.Cards[0].Driver, gc.Equals, "ixgbe")
+}
+
+func (s *machineSuite) TestCommissioningResourcesFailed(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(commissioningResourcesPath, http.StatusOK, commissioningResultsResponse("Failed", ""))
+	_, err := machine.CommissioningResources()
+	c.Check(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *machineSuite) TestCommissioningResourcesNeverCommissioned(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(commissioningResourcesPath, http.StatusOK, "[]")
+	_, err := machine.CommissioningResources()
+	c.Check(err, jc.Satisfies, errors.IsNotFound)
+	c.Check(err, gc.ErrorMatches, "50-maas-01-commissioning output for machine 4y3ha3 not found")
+}
+
+const machineResourcesOutput = `
+{
+    "cpu": {
+        "architecture": "x86_64",
+        "sockets": [
+            {
+                "name": "Intel(R) Xeon(R) CPU E5-2680 v4 @ 2.40GHz",
+                "vendor": "GenuineIntel",
+                "socket": 0,
+                "cache": [
+                    {"level": 1, "type": "Data", "size": 32768},
+                    {"level": 3, "type": "Unified", "size": 36700160}
+                ],
+                "cores": [
+                    {
+                        "core": 0,
+                        "die": 0,
+                        "threads": [
+                            {"id": 0, "numa_node": 0, "thread": 0, "online": true, "isolated": false},
+                            {"id": 1, "numa_node": 0, "thread": 1, "online": true, "isolated": false}
+                        ],
+                        "frequency": 2400,
+                        "flags": ["avx2", "vmx"]
+                    }
+                ],
+                "frequency": 2400,
+                "frequency_minimum": 1200,
+                "frequency_turbo": 3300
+            }
+        ],
+        "total": 2
+    },
+    "memory": {
+        "nodes": [
+            {"numa_node": 0, "hugepages_used": 0, "hugepages_total": 0, "used": 1073741824, "total": 8589934592}
+        ],
+        "hugepages_total": 0,
+        "hugepages_used": 0,
+        "hugepages_size": 2097152,
+        "used": 1073741824,
+        "total": 8589934592
+    },
+    "network": {
+        "cards": [
+            {
+                "driver": "ixgbe",
+                "driver_version": "5.1.0-k",
+                "ports": [
+                    {
+                        "id": "eth0",
+                        "address": "52:54:00:c9:6a:45",
+                        "port": 0,
+                        "protocol": "ethernet",
+                        "supported_modes": ["1000baseT/Full", "10000baseT/Full"],
+                        "supported_ports": ["fibre"],
+                        "port_type": "fibre",
+                        "transceiver_type": "external",
+                        "auto_negotiation": true,
+                        "link_detected": true,
+                        "link_speed": 10000,
+                        "link_duplex": "full"
+                    }
+                ],
+                "sriov": {"current_vfs": 0, "maximum_vfs": 63},
+                "numa_node": 0,
+                "pci_address": "0000:03:00.0",
+                "vendor": "Intel Corporation",
+                "vendor_id": "8086",
+                "product": "82599ES 10-Gigabit SFI/SFP+ Network Connection",
+                "product_id": "10fb",
+                "firmware_version": "0x800003df"
+            }
+        ],
+        "total": 1
+    },
+    "storage": {
+        "disks": [
+            {
+                "id": "sda",
+                "device": "8:0",
+                "model": "SAMSUNG MZ7LM480",
+                "type": "sata",
+                "read_only": false,
+                "size": 480103981056,
+                "removable": false,
+                "wwn": "0x5002538c40146ccb",
+                "numa_node": 0,
+                "device_path": "pci-0000:00:1f.2-ata-1",
+                "block_size": 512,
+                "firmware_version": "GXT5404Q",
+                "rpm": 0,
+                "serial": "S2UJNX0J103139"
+            }
+        ],
+        "total": 1
+    }
+}
+`
@@ -0,0 +1,147 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"crypto/x509"
+	stderrors "errors"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// RetryPolicy controls which failed requests a Client sends again, and how
+// long it waits before each retry. The zero value retries nothing but the
+// responses that carry a Retry-After header.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a request is sent at most,
+	// including the first. Zero means NumberOfRetries + 1.
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry of a response without a
+	// Retry-After header, or of a network failure. It doubles with each
+	// retry after that. If it is zero, only responses with a Retry-After
+	// header are retried, after waiting as long as the header says.
+	Backoff time.Duration
+
+	// MaxBackoff, if set, caps the wait that doubling Backoff gives.
+	MaxBackoff time.Duration
+
+	// Jitter is the fraction, between 0 and 1, by which each backoff is
+	// randomly lengthened or shortened, so that clients failing together
+	// do not retry together.
+	Jitter float64
+
+	// StatusCodes are the response codes that are retried. If it is
+	// empty, only 503 responses are.
+	StatusCodes []int
+
+	// RetryNetworkErrors, if true, retries requests that failed without
+	// a response, such as when the connection was reset or timed out.
+	// Only requests that are safe to repeat, which are those other than
+	// POST, are retried unless the connection was refused, as the server
+	// may have acted on a POST that it did not answer.
+	RetryNetworkErrors bool
+}
+
+// defaultRetryPolicy is used by clients without a RetryPolicy.
+var defaultRetryPolicy = &RetryPolicy{}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return NumberOfRetries + 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) retryStatus(code int) bool {
+	if len(p.StatusCodes) == 0 {
+		return code == http.StatusServiceUnavailable
+	}
+	for _, c := range p.StatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before sending the request again
+// after the attempt, numbered from one, failed with the error. It returns
+// false if the request should not be sent again.
+func (p *RetryPolicy) retryDelay(request *http.Request, err error, attempt int, now time.Time) (time.Duration, bool) {
+	if serverError, ok := errors.Cause(err).(ServerError); ok {
+		if !p.retryStatus(serverError.StatusCode) {
+			return 0, false
+		}
+		if delay, ok := retryAfter(serverError.Header, now); ok {
+			return delay, true
+		}
+	} else if !p.RetryNetworkErrors || !retryableNetworkError(request, err) {
+		return 0, false
+	}
+	if p.Backoff <= 0 {
+		return 0, false
+	}
+	return p.backoff(attempt), true
+}
+
+// backoff returns the wait after the attempt, numbered from one.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration(float64(delay) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return delay
+}
+
+// retryAfter reads the Retry-After header, which is either a number of
+// seconds or a time.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get(RetryAfterHeaderName)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := at.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// retryableNetworkError returns true if the request failed without a
+// response in a way that is safe to retry. Certificates that do not verify
+// will not verify the next time either.
+func retryableNetworkError(request *http.Request, err error) bool {
+	var urlError *url.Error
+	if !stderrors.As(err, &urlError) {
+		return false
+	}
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	if stderrors.As(err, &unknownAuthority) || stderrors.As(err, &hostname) || stderrors.As(err, &invalid) {
+		return false
+	}
+	if stderrors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	return request.Method != http.MethodPost
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type retrySuite struct{}
+
+var _ = gc.Suite(&retrySuite{})
+
+func serverErrorWithHeader(code int, header http.Header) error {
+	return errors.Trace(ServerError{error: errors.New("boom"), StatusCode: code, Header: header})
+}
+
+func (*retrySuite) TestDefaultPolicy(c *gc.C) {
+	request, _ := http.NewRequest("GET", "http://example.com/", nil)
+	now := time.Now()
+	policy := defaultRetryPolicy
+	c.Check(policy.maxAttempts(), gc.Equals, NumberOfRetries+1)
+
+	delay, ok := policy.retryDelay(request, serverErrorWithHeader(503, http.Header{"Retry-After": {"3"}}), 1, now)
+	c.Check(ok, jc.IsTrue)
+	c.Check(delay, gc.Equals, 3*time.Second)
+
+	_, ok = policy.retryDelay(request, serverErrorWithHeader(503, nil), 1, now)
+	c.Check(ok, jc.IsFalse)
+	_, ok = policy.retryDelay(request, serverErrorWithHeader(500, http.Header{"Retry-After": {"3"}}), 1, now)
+	c.Check(ok, jc.IsFalse)
+	_, ok = policy.retryDelay(request, &url.Error{Op: "Get", Err: syscall.ECONNRESET}, 1, now)
+	c.Check(ok, jc.IsFalse)
+}
+
+func (*retrySuite) TestBackoff(c *gc.C) {
+	policy := &RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	var delays []time.Duration
+	for attempt := 1; attempt <= 5; attempt++ {
+		delays = append(delays, policy.backoff(attempt))
+	}
+	c.Check(delays, jc.DeepEquals, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second,
+	})
+}
+
+func (*retrySuite) TestBackoffJitter(c *gc.C) {
+	policy := &RetryPolicy{Backoff: 10 * time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		delay := policy.backoff(1)
+		c.Assert(delay >= 5*time.Second && delay <= 15*time.Second, jc.IsTrue, gc.Commentf("delay %v", delay))
+	}
+}
+
+func (*retrySuite) TestRetryAfterDate(c *gc.C) {
+	now := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	header := http.Header{"Retry-After": {now.Add(time.Minute).Format(http.TimeFormat)}}
+	delay, ok := retryAfter(header, now)
+	c.Check(ok, jc.IsTrue)
+	c.Check(delay, gc.Equals, time.Minute)
+
+	_, ok = retryAfter(http.Header{"Retry-After": {"soon"}}, now)
+	c.Check(ok, jc.IsFalse)
+}
+
+func (*retrySuite) TestRetryStatusCodes(c *gc.C) {
+	request, _ := http.NewRequest("GET", "http://example.com/", nil)
+	policy := &RetryPolicy{Backoff: time.Second, StatusCodes: []int{429, 502}}
+	delay, ok := policy.retryDelay(request, serverErrorWithHeader(502, nil), 2, time.Now())
+	c.Check(ok, jc.IsTrue)
+	c.Check(delay, gc.Equals, 2*time.Second)
+	_, ok = policy.retryDelay(request, serverErrorWithHeader(503, nil), 1, time.Now())
+	c.Check(ok, jc.IsFalse)
+}
+
+func (*retrySuite) TestRetryNetworkErrors(c *gc.C) {
+	get, _ := http.NewRequest("GET", "http://example.com/", nil)
+	post, _ := http.NewRequest("POST", "http://example.com/", nil)
+	policy := &RetryPolicy{Backoff: time.Second, RetryNetworkErrors: true}
+	reset := &url.Error{Op: "Post", Err: syscall.ECONNRESET}
+	refused := &url.Error{Op: "Post", Err: syscall.ECONNREFUSED}
+
+	_, ok := policy.retryDelay(get, reset, 1, time.Now())
+	c.Check(ok, jc.IsTrue)
+	_, ok = policy.retryDelay(post, reset, 1, time.Now())
+	c.Check(ok, jc.IsFalse)
+	_, ok = policy.retryDelay(post, refused, 1, time.Now())
+	c.Check(ok, jc.IsTrue)
+	_, ok = policy.retryDelay(get, errors.New("not a network error"), 1, time.Now())
+	c.Check(ok, jc.IsFalse)
+}
@@ -0,0 +1,358 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+// ScriptResultType selects the scripts whose results ScriptResults returns.
+type ScriptResultType string
+
+// The script result types.
+const (
+	ScriptResultCommissioning ScriptResultType = "commissioning"
+	ScriptResultTesting       ScriptResultType = "testing"
+	ScriptResultInstallation  ScriptResultType = "installation"
+	ScriptResultRelease       ScriptResultType = "release"
+)
+
+type scriptSet struct {
+	resourceURI string
+
+	id         int
+	resultType string
+	status     string
+	started    time.Time
+	ended      time.Time
+	results    []*scriptResult
+}
+
+// ID implements ScriptSet.
+func (s *scriptSet) ID() int {
+	return s.id
+}
+
+// Type implements ScriptSet.
+func (s *scriptSet) Type() string {
+	return s.resultType
+}
+
+// Status implements ScriptSet.
+func (s *scriptSet) Status() string {
+	return s.status
+}
+
+// Started implements ScriptSet.
+func (s *scriptSet) Started() time.Time {
+	return s.started
+}
+
+// Ended implements ScriptSet.
+func (s *scriptSet) Ended() time.Time {
+	return s.ended
+}
+
+// Results implements ScriptSet.
+func (s *scriptSet) Results() []ScriptResult {
+	result := make([]ScriptResult, len(s.results))
+	for i, r := range s.results {
+		result[i] = r
+	}
+	return result
+}
+
+type scriptResult struct {
+	id         int
+	name       string
+	status     string
+	exitStatus int
+	started    time.Time
+	ended      time.Time
+	output     []byte
+
+	// blockDeviceID and blockDeviceName are only set for scripts run
+	// against a storage device.
+	blockDeviceID   int
+	blockDeviceName string
+}
+
+// ID implements ScriptResult.
+func (r *scriptResult) ID() int {
+	return r.id
+}
+
+// Name implements ScriptResult.
+func (r *scriptResult) Name() string {
+	return r.name
+}
+
+// Status implements ScriptResult.
+func (r *scriptResult) Status() string {
+	return r.status
+}
+
+// ExitStatus implements ScriptResult.
+func (r *scriptResult) ExitStatus() int {
+	return r.exitStatus
+}
+
+// Started implements ScriptResult.
+func (r *scriptResult) Started() time.Time {
+	return r.started
+}
+
+// Ended implements ScriptResult.
+func (r *scriptResult) Ended() time.Time {
+	return r.ended
+}
+
+// Output implements ScriptResult.
+func (r *scriptResult) Output() []byte {
+	return r.output
+}
+
+// BlockDeviceID implements ScriptResult.
+func (r *scriptResult) BlockDeviceID() int {
+	return r.blockDeviceID
+}
+
+// BlockDeviceName implements ScriptResult.
+func (r *scriptResult) BlockDeviceName() string {
+	return r.blockDeviceName
+}
+
+// ScriptResultsArgs is an argument struct for selecting script results.
+type ScriptResultsArgs struct {
+	// Type is empty for the results of every type.
+	Type ScriptResultType
+	// IncludeOutput asks for the combined output of each script, which
+	// can be large.
+	IncludeOutput bool
+	// Filters limits the results to the scripts with the names or tags.
+	Filters []string
+}
+
+// ScriptResults implements Machine.
+func (m *machine) ScriptResults(args ScriptResultsArgs) ([]ScriptSet, error) {
+	params := NewURLParams()
+	params.MaybeAdd("type", string(args.Type))
+	params.MaybeAddBool("include_output", args.IncludeOutput)
+	params.MaybeAdd("filters", strings.Join(args.Filters, ","))
+	source, err := m.controller.getQuery(APIPath(NodesPath, m.systemID, "results"), params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				if strings.HasPrefix(svrErr.BodyMessage, "Unknown API endpoint") {
+					return nil, errors.NewNotSupported(err, "script results")
+				}
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusBadRequest:
+				// Servers without release scripts reject that type.
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			}
+		}
+		if errors.IsNotValid(err) {
+			return nil, errors.Trace(err)
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	sets, err := readScriptSets(m.controller.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result := make([]ScriptSet, len(sets))
+	for i, s := range sets {
+		result[i] = s
+	}
+	return result, nil
+}
+
+func readScriptSets(controllerVersion version.Number, source interface{}) ([]*scriptSet, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "script set base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range scriptSetDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no script set read func for version %s", controllerVersion)
+	}
+	readFunc := scriptSetDeserializationFuncs[deserialisationVersion]
+	return readScriptSetList(valid, readFunc)
+}
+
+// readScriptSetList expects the values of the sourceList to be string maps.
+func readScriptSetList(sourceList []interface{}, readFunc scriptSetDeserializationFunc) ([]*scriptSet, error) {
+	result := make([]*scriptSet, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, atPath(NewDeserializationError("unexpected value for script set %d, %T", i, value), indexPath(i))
+		}
+		set, err := readFunc(source)
+		if err != nil {
+			return nil, atPath(errors.Annotatef(err, "script set %d", i), indexPath(i))
+		}
+		result = append(result, set)
+	}
+	return result, nil
+}
+
+type scriptSetDeserializationFunc func(map[string]interface{}) (*scriptSet, error)
+
+var scriptSetDeserializationFuncs = map[version.Number]scriptSetDeserializationFunc{
+	twoDotOh: scriptSet_2_0,
+}
+
+func scriptSet_2_0(source map[string]interface{}) (*scriptSet, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+
+		"id":          schema.ForceInt(),
+		"type_name":   schema.String(),
+		"status_name": schema.String(),
+		"started":     schema.OneOf(schema.Nil(""), schema.String()),
+		"ended":       schema.OneOf(schema.Nil(""), schema.String()),
+		"results":     schema.List(schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"resource_uri": "",
+		"started":      "",
+		"ended":        "",
+		"results":      []interface{}{},
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "script set 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	started, ended, err := readStartedEnded(valid)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	results := make([]*scriptResult, 0)
+	for i, value := range valid["results"].([]interface{}) {
+		result, err := scriptResult_2_0(value.(map[string]interface{}))
+		if err != nil {
+			return nil, atPath(errors.Annotatef(err, "script result %d", i), joinPath("results", indexPath(i)))
+		}
+		results = append(results, result)
+	}
+	return &scriptSet{
+		resourceURI: valid["resource_uri"].(string),
+		id:          valid["id"].(int),
+		resultType:  valid["type_name"].(string),
+		status:      valid["status_name"].(string),
+		started:     started,
+		ended:       ended,
+		results:     results,
+	}, nil
+}
+
+func scriptResult_2_0(source map[string]interface{}) (*scriptResult, error) {
+	fields := schema.Fields{
+		"id":          schema.ForceInt(),
+		"name":        schema.String(),
+		"status_name": schema.String(),
+		"exit_status": schema.OneOf(schema.Nil(""), schema.ForceInt()),
+		"started":     schema.OneOf(schema.Nil(""), schema.String()),
+		"ended":       schema.OneOf(schema.Nil(""), schema.String()),
+		"output":      schema.String(),
+		"parameters":  schema.StringMap(schema.Any()),
+	}
+	defaults := schema.Defaults{
+		"exit_status": nil,
+		"started":     "",
+		"ended":       "",
+		"output":      "",
+		"parameters":  schema.Omit,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "script result 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+
+	started, ended, err := readStartedEnded(valid)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// Scripts that have not finished have no exit status.
+	exitStatus := -1
+	if status, ok := valid["exit_status"].(int); ok {
+		exitStatus = status
+	}
+	// The output is only included when asked for, base64 encoded.
+	output, err := base64.StdEncoding.DecodeString(valid["output"].(string))
+	if err != nil {
+		return nil, atPath(NewDeserializationError("script result output: %v", err), "output")
+	}
+	result := &scriptResult{
+		id:         valid["id"].(int),
+		name:       valid["name"].(string),
+		status:     valid["status_name"].(string),
+		exitStatus: exitStatus,
+		started:    started,
+		ended:      ended,
+		output:     output,
+	}
+	if parameters, ok := valid["parameters"].(map[string]interface{}); ok {
+		result.blockDeviceID, result.blockDeviceName = readStorageParameter(parameters)
+	}
+	return result, nil
+}
+
+// readStorageParameter returns the storage device that a script was run
+// against, from the "storage" parameter that the server fills in with
+// the device. Scripts without one, or run against all devices before the
+// server has split them, give a zero ID.
+func readStorageParameter(parameters map[string]interface{}) (int, string) {
+	checker := schema.FieldMap(schema.Fields{
+		"value": schema.FieldMap(schema.Fields{
+			"id":   schema.ForceInt(),
+			"name": schema.String(),
+		}, schema.Defaults{"name": ""}),
+	}, nil)
+	coerced, err := checker.Coerce(parameters["storage"], nil)
+	if err != nil {
+		return 0, ""
+	}
+	value := coerced.(map[string]interface{})["value"].(map[string]interface{})
+	return value["id"].(int), value["name"].(string)
+}
+
+func readStartedEnded(valid map[string]interface{}) (time.Time, time.Time, error) {
+	started, _ := valid["started"].(string)
+	ended, _ := valid["ended"].(string)
+	startedTime, err := parseTimestamp(started)
+	if err != nil {
+		return time.Time{}, time.Time{}, atPath(NewDeserializationError("started: %v", err), "started")
+	}
+	endedTime, err := parseTimestamp(ended)
+	if err != nil {
+		return time.Time{}, time.Time{}, atPath(NewDeserializationError("ended: %v", err), "ended")
+	}
+	return startedTime, endedTime, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type scriptResultSuite struct{}
+
+var _ = gc.Suite(&scriptResultSuite{})
+
+func (*scriptResultSuite) TestReadScriptSetsBadSchema(c *gc.C) {
+	_, err := readScriptSets(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `script set base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*scriptResultSuite) TestReadScriptSets(c *gc.C) {
+	sets, err := readScriptSets(twoDotOh, parseJSON(c, scriptResultsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(sets, gc.HasLen, 1)
+
+	set := sets[0]
+	c.Check(set.ID(), gc.Equals, 12)
+	c.Check(set.Type(), gc.Equals, "Release")
+	c.Check(set.Status(), gc.Equals, "Passed")
+	c.Check(set.Started(), gc.Equals, time.Date(2019, 11, 19, 15, 24, 25, 0, time.UTC))
+	c.Check(set.Ended(), gc.Equals, time.Date(2019, 11, 19, 15, 31, 2, 0, time.UTC))
+
+	results := set.Results()
+	c.Assert(results, gc.HasLen, 2)
+	c.Check(results[0].ID(), gc.Equals, 40)
+	c.Check(results[0].Name(), gc.Equals, "wipe-disks")
+	c.Check(results[0].Status(), gc.Equals, "Passed")
+	c.Check(results[0].ExitStatus(), gc.Equals, 0)
+	c.Check(string(results[0].Output()), gc.Equals, "wiped /dev/sda\n")
+
+	c.Check(results[1].Status(), gc.Equals, "Pending")
+	c.Check(results[1].ExitStatus(), gc.Equals, -1)
+	c.Check(results[1].Started().IsZero(), jc.IsTrue)
+	c.Check(results[1].Output(), gc.HasLen, 0)
+}
+
+func (*scriptResultSuite) TestReadScriptSetsBadOutput(c *gc.C) {
+	source := parseJSON(c, scriptResultsResponse)
+	result := source.([]interface{})[0].(map[string]interface{})["results"].([]interface{})[1]
+	result.(map[string]interface{})["output"] = "not base64!"
+	_, err := readScriptSets(twoDotOh, source)
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Check(DeserializationErrorPath(err), gc.Equals, "[0].results[1].output")
+}
+
+func (*scriptResultSuite) TestLowVersion(c *gc.C) {
+	_, err := readScriptSets(version.MustParse("1.9.0"), parseJSON(c, scriptResultsResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+const scriptResultsResponse = `
+[
+    {
+        "id": 12,
+        "system_id": "4y3ha3",
+        "type": 3,
+        "type_name": "Release",
+        "status": 2,
+        "status_name": "Passed",
+        "started": "Tue, 19 Nov. 2019 15:24:25",
+        "ended": "Tue, 19 Nov. 2019 15:31:02",
+        "runtime": "0:06:37",
+        "resource_uri": "/MAAS/api/2.0/nodes/4y3ha3/results/12/",
+        "results": [
+            {
+                "id": 40,
+                "name": "wipe-disks",
+                "status": 2,
+                "status_name": "Passed",
+                "exit_status": 0,
+                "started": "Tue, 19 Nov. 2019 15:24:26",
+                "ended": "Tue, 19 Nov. 2019 15:31:01",
+                "output": "d2lwZWQgL2Rldi9zZGEK"
+            },
+            {
+                "id": 41,
+                "name": "collect-evidence",
+                "status": 0,
+                "status_name": "Pending",
+                "exit_status": null,
+                "started": null,
+                "ended": null,
+                "output": ""
+            }
+        ]
+    }
+]
+`
@@ -0,0 +1,473 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+	"github.com/juju/version"
+)
+
+// RegionSnapshotVersion is the version of the RegionSnapshot format that
+// TakeRegionSnapshot writes. ReadRegionSnapshot rejects snapshots of
+// newer versions.
+const RegionSnapshotVersion = 1
+
+// RegionSnapshot is the state of a MAAS region at a point in time, for
+// saving as JSON and comparing with DiffRegionSnapshots. It is only read
+// from the region; nothing restores it.
+type RegionSnapshot struct {
+	Version       int       `json:"version"`
+	Taken         time.Time `json:"taken"`
+	ServerVersion string    `json:"server_version,omitempty"`
+
+	Fabrics  []SnapshotFabric  `json:"fabrics"`
+	Spaces   []SnapshotSpace   `json:"spaces"`
+	Subnets  []SnapshotSubnet  `json:"subnets"`
+	Zones    []SnapshotZone    `json:"zones"`
+	Pools    []SnapshotPool    `json:"pools"`
+	Tags     []SnapshotTag     `json:"tags"`
+	Machines []SnapshotMachine `json:"machines"`
+}
+
+// SnapshotFabric is a fabric in a RegionSnapshot.
+type SnapshotFabric struct {
+	Name      string         `json:"name"`
+	ClassType string         `json:"class_type,omitempty"`
+	VLANs     []SnapshotVLAN `json:"vlans"`
+}
+
+// SnapshotVLAN is a VLAN of a SnapshotFabric.
+type SnapshotVLAN struct {
+	VID  int    `json:"vid"`
+	Name string `json:"name"`
+	MTU  int    `json:"mtu"`
+	DHCP bool   `json:"dhcp"`
+}
+
+// SnapshotSpace is a space in a RegionSnapshot.
+type SnapshotSpace struct {
+	Name string `json:"name"`
+	// Subnets are the CIDRs of the subnets in the space, sorted.
+	Subnets []string `json:"subnets"`
+}
+
+// SnapshotSubnet is a subnet in a RegionSnapshot.
+type SnapshotSubnet struct {
+	CIDR       string   `json:"cidr"`
+	Name       string   `json:"name"`
+	Space      string   `json:"space,omitempty"`
+	Fabric     string   `json:"fabric,omitempty"`
+	VID        int      `json:"vid"`
+	Gateway    string   `json:"gateway,omitempty"`
+	DNSServers []string `json:"dns_servers,omitempty"`
+	Managed    bool     `json:"managed"`
+}
+
+// SnapshotZone is a zone in a RegionSnapshot.
+type SnapshotZone struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// SnapshotPool is a resource pool in a RegionSnapshot.
+type SnapshotPool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// SnapshotTag is a tag in a RegionSnapshot.
+type SnapshotTag struct {
+	Name       string `json:"name"`
+	Comment    string `json:"comment,omitempty"`
+	Definition string `json:"definition,omitempty"`
+	KernelOpts string `json:"kernel_opts,omitempty"`
+}
+
+// SnapshotMachine is the metadata of a machine in a RegionSnapshot.
+type SnapshotMachine struct {
+	SystemID     string            `json:"system_id"`
+	Hostname     string            `json:"hostname"`
+	FQDN         string            `json:"fqdn,omitempty"`
+	Status       string            `json:"status"`
+	Owner        string            `json:"owner,omitempty"`
+	Architecture string            `json:"architecture,omitempty"`
+	CPUCount     int               `json:"cpu_count"`
+	Memory       int               `json:"memory"`
+	Zone         string            `json:"zone,omitempty"`
+	Pool         string            `json:"pool,omitempty"`
+	PowerType    string            `json:"power_type,omitempty"`
+	OS           string            `json:"os,omitempty"`
+	DistroSeries string            `json:"distro_series,omitempty"`
+	Tags         []string          `json:"tags,omitempty"`
+	OwnerData    map[string]string `json:"owner_data,omitempty"`
+}
+
+// poolsVersion is the first server version with resource pools.
+var poolsVersion = version.Number{Major: 2, Minor: 5}
+
+// TakeRegionSnapshot reads the fabrics, spaces, subnets, zones, resource
+// pools, tags and machines of the region. The pools are left out for
+// servers that have none.
+func TakeRegionSnapshot(controller Controller) (*RegionSnapshot, error) {
+	result := &RegionSnapshot{
+		Version: RegionSnapshotVersion,
+		Taken:   time.Now().UTC(),
+	}
+	serverVersion := controller.ServerVersion()
+	if serverVersion != version.Zero {
+		result.ServerVersion = serverVersion.String()
+	}
+
+	fabrics, err := controller.Fabrics()
+	if err != nil {
+		return nil, errors.Annotate(err, "reading fabrics")
+	}
+	for _, f := range fabrics {
+		fabric := SnapshotFabric{Name: f.Name(), ClassType: f.ClassType()}
+		for _, v := range f.VLANs() {
+			fabric.VLANs = append(fabric.VLANs, SnapshotVLAN{VID: v.VID(), Name: v.Name(), MTU: v.MTU(), DHCP: v.DHCP()})
+		}
+		sort.Slice(fabric.VLANs, func(i, j int) bool { return fabric.VLANs[i].VID < fabric.VLANs[j].VID })
+		result.Fabrics = append(result.Fabrics, fabric)
+	}
+
+	spaces, err := controller.Spaces()
+	if err != nil {
+		return nil, errors.Annotate(err, "reading spaces")
+	}
+	for _, s := range spaces {
+		space := SnapshotSpace{Name: s.Name()}
+		for _, subnet := range s.Subnets() {
+			space.Subnets = append(space.Subnets, subnet.CIDR())
+		}
+		sort.Strings(space.Subnets)
+		result.Spaces = append(result.Spaces, space)
+	}
+
+	subnets, err := controller.Subnets()
+	if err != nil {
+		return nil, errors.Annotate(err, "reading subnets")
+	}
+	for _, s := range subnets {
+		subnet := SnapshotSubnet{
+			CIDR:       s.CIDR(),
+			Name:       s.Name(),
+			Space:      s.Space(),
+			Gateway:    s.Gateway(),
+			DNSServers: s.DNSServers(),
+			Managed:    s.Managed(),
+		}
+		if vlan := s.VLAN(); vlan != nil {
+			subnet.Fabric = vlan.Fabric()
+			subnet.VID = vlan.VID()
+		}
+		result.Subnets = append(result.Subnets, subnet)
+	}
+
+	zones, err := controller.Zones()
+	if err != nil {
+		return nil, errors.Annotate(err, "reading zones")
+	}
+	for _, z := range zones {
+		result.Zones = append(result.Zones, SnapshotZone{Name: z.Name(), Description: z.Description()})
+	}
+
+	if serverVersion == version.Zero || serverVersion.Compare(poolsVersion) >= 0 {
+		pools, err := controller.Pools()
+		if err != nil {
+			return nil, errors.Annotate(err, "reading resource pools")
+		}
+		for _, p := range pools {
+			result.Pools = append(result.Pools, SnapshotPool{Name: p.Name(), Description: p.Description()})
+		}
+	}
+
+	tags, err := controller.Tags()
+	if err != nil {
+		return nil, errors.Annotate(err, "reading tags")
+	}
+	for _, t := range tags {
+		result.Tags = append(result.Tags, SnapshotTag{
+			Name:       t.Name(),
+			Comment:    t.Comment(),
+			Definition: t.Definition(),
+			KernelOpts: t.KernelOpts(),
+		})
+	}
+
+	machines, err := controller.Machines(MachinesArgs{})
+	if err != nil {
+		return nil, errors.Annotate(err, "reading machines")
+	}
+	for _, m := range machines {
+		machine := SnapshotMachine{
+			SystemID:     m.SystemID(),
+			Hostname:     m.Hostname(),
+			FQDN:         m.FQDN(),
+			Status:       m.StatusName(),
+			Owner:        m.Owner(),
+			Architecture: m.Architecture(),
+			CPUCount:     m.CPUCount(),
+			Memory:       m.Memory(),
+			PowerType:    m.PowerType(),
+			OS:           m.OperatingSystem(),
+			DistroSeries: m.DistroSeries(),
+			Tags:         append([]string(nil), m.Tags()...),
+			OwnerData:    m.OwnerData(),
+		}
+		if zone := m.Zone(); zone != nil {
+			machine.Zone = zone.Name()
+		}
+		if pool := m.Pool(); pool != nil {
+			machine.Pool = pool.Name()
+		}
+		sort.Strings(machine.Tags)
+		if len(machine.OwnerData) == 0 {
+			machine.OwnerData = nil
+		}
+		result.Machines = append(result.Machines, machine)
+	}
+	return result, nil
+}
+
+// ReadRegionSnapshot reads a snapshot saved as JSON. Snapshots of a newer
+// version than RegionSnapshotVersion give an error satisfying
+// errors.IsNotSupported.
+func ReadRegionSnapshot(data []byte) (*RegionSnapshot, error) {
+	var result RegionSnapshot
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, NewDeserializationError("region snapshot: %v", err)
+	}
+	if result.Version < 1 {
+		return nil, NewDeserializationError("region snapshot: missing version")
+	}
+	if result.Version > RegionSnapshotVersion {
+		return nil, errors.NotSupportedf("region snapshot version %d", result.Version)
+	}
+	return &result, nil
+}
+
+// Kinds of the items in a SnapshotChange.
+const (
+	SnapshotKindFabric  = "fabric"
+	SnapshotKindVLAN    = "vlan"
+	SnapshotKindSpace   = "space"
+	SnapshotKindSubnet  = "subnet"
+	SnapshotKindZone    = "zone"
+	SnapshotKindPool    = "pool"
+	SnapshotKindTag     = "tag"
+	SnapshotKindMachine = "machine"
+)
+
+// SnapshotChange is a single difference found by DiffRegionSnapshots.
+type SnapshotChange struct {
+	// Kind is one of the SnapshotKind* values.
+	Kind string
+
+	// Key identifies the item: the name of a fabric, space, zone, pool or
+	// tag, the CIDR of a subnet, the fabric and VID of a VLAN as
+	// "fabric-0.10", and the system ID of a machine.
+	Key string
+
+	// Property is what differs, such as "description" or "status". An
+	// item missing from either snapshot has the property "present".
+	Property string
+
+	// Before and After hold the values in each snapshot.
+	Before string
+	After  string
+}
+
+// String returns a readable description of the change.
+func (c SnapshotChange) String() string {
+	return fmt.Sprintf("%s %s %s: %q -> %q", c.Kind, c.Key, c.Property, c.Before, c.After)
+}
+
+// SnapshotDiff is the result of DiffRegionSnapshots.
+type SnapshotDiff struct {
+	Changes []SnapshotChange
+}
+
+// Empty returns true if no differences were found.
+func (d SnapshotDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// String returns one line per change.
+func (d SnapshotDiff) String() string {
+	lines := make([]string, len(d.Changes))
+	for i, change := range d.Changes {
+		lines[i] = change.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiffRegionSnapshots reports how after differs from before. Items are
+// matched by their keys, and the changes are ordered by kind and key. The
+// times the snapshots were taken are not compared.
+func DiffRegionSnapshots(before, after *RegionSnapshot) SnapshotDiff {
+	var diff SnapshotDiff
+	diffItems := func(kind string, beforeItems, afterItems map[string]map[string]string) {
+		keys := set.NewStrings()
+		for key := range beforeItems {
+			keys.Add(key)
+		}
+		for key := range afterItems {
+			keys.Add(key)
+		}
+		for _, key := range keys.SortedValues() {
+			b, inBefore := beforeItems[key]
+			a, inAfter := afterItems[key]
+			if !inBefore || !inAfter {
+				diff.Changes = append(diff.Changes, SnapshotChange{
+					Kind:     kind,
+					Key:      key,
+					Property: "present",
+					Before:   strconv.FormatBool(inBefore),
+					After:    strconv.FormatBool(inAfter),
+				})
+				continue
+			}
+			properties := set.NewStrings()
+			for property := range b {
+				properties.Add(property)
+			}
+			for property := range a {
+				properties.Add(property)
+			}
+			for _, property := range properties.SortedValues() {
+				if b[property] != a[property] {
+					diff.Changes = append(diff.Changes, SnapshotChange{
+						Kind:     kind,
+						Key:      key,
+						Property: property,
+						Before:   b[property],
+						After:    a[property],
+					})
+				}
+			}
+		}
+	}
+
+	diffItems(SnapshotKindFabric, before.fabrics(), after.fabrics())
+	diffItems(SnapshotKindVLAN, before.vlans(), after.vlans())
+	diffItems(SnapshotKindSpace, before.spaces(), after.spaces())
+	diffItems(SnapshotKindSubnet, before.subnets(), after.subnets())
+	diffItems(SnapshotKindZone, before.zones(), after.zones())
+	diffItems(SnapshotKindPool, before.pools(), after.pools())
+	diffItems(SnapshotKindTag, before.tags(), after.tags())
+	diffItems(SnapshotKindMachine, before.machines(), after.machines())
+	return diff
+}
+
+// The following methods flatten the items of each kind into their
+// properties by key, for DiffRegionSnapshots.
+
+func (s *RegionSnapshot) fabrics() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, f := range s.Fabrics {
+		result[f.Name] = map[string]string{"class_type": f.ClassType}
+	}
+	return result
+}
+
+func (s *RegionSnapshot) vlans() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, f := range s.Fabrics {
+		for _, v := range f.VLANs {
+			result[fmt.Sprintf("%s.%d", f.Name, v.VID)] = map[string]string{
+				"name": v.Name,
+				"mtu":  strconv.Itoa(v.MTU),
+				"dhcp": strconv.FormatBool(v.DHCP),
+			}
+		}
+	}
+	return result
+}
+
+func (s *RegionSnapshot) spaces() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, space := range s.Spaces {
+		result[space.Name] = map[string]string{"subnets": strings.Join(space.Subnets, ",")}
+	}
+	return result
+}
+
+func (s *RegionSnapshot) subnets() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, subnet := range s.Subnets {
+		result[subnet.CIDR] = map[string]string{
+			"name":        subnet.Name,
+			"space":       subnet.Space,
+			"fabric":      subnet.Fabric,
+			"vid":         strconv.Itoa(subnet.VID),
+			"gateway":     subnet.Gateway,
+			"dns_servers": strings.Join(subnet.DNSServers, ","),
+			"managed":     strconv.FormatBool(subnet.Managed),
+		}
+	}
+	return result
+}
+
+func (s *RegionSnapshot) zones() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, zone := range s.Zones {
+		result[zone.Name] = map[string]string{"description": zone.Description}
+	}
+	return result
+}
+
+func (s *RegionSnapshot) pools() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, pool := range s.Pools {
+		result[pool.Name] = map[string]string{"description": pool.Description}
+	}
+	return result
+}
+
+func (s *RegionSnapshot) tags() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, tag := range s.Tags {
+		result[tag.Name] = map[string]string{
+			"comment":     tag.Comment,
+			"definition":  tag.Definition,
+			"kernel_opts": tag.KernelOpts,
+		}
+	}
+	return result
+}
+
+func (s *RegionSnapshot) machines() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, m := range s.Machines {
+		properties := map[string]string{
+			"hostname":      m.Hostname,
+			"fqdn":          m.FQDN,
+			"status":        m.Status,
+			"owner":         m.Owner,
+			"architecture":  m.Architecture,
+			"cpu_count":     strconv.Itoa(m.CPUCount),
+			"memory":        strconv.Itoa(m.Memory),
+			"zone":          m.Zone,
+			"pool":          m.Pool,
+			"power_type":    m.PowerType,
+			"os":            m.OS,
+			"distro_series": m.DistroSeries,
+			"tags":          strings.Join(m.Tags, ","),
+		}
+		for key, value := range m.OwnerData {
+			properties["owner_data."+key] = value
+		}
+		result[m.SystemID] = properties
+	}
+	return result
+}
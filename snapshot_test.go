@@ -0,0 +1,116 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type snapshotSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&snapshotSuite{})
+
+func (s *snapshotSuite) takeSnapshot(c *gc.C) *RegionSnapshot {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/fabrics/", http.StatusOK, fabricResponse)
+	server.AddGetResponse("/api/2.0/spaces/", http.StatusOK, spacesResponse)
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+	server.AddGetResponse("/api/2.0/pools/", http.StatusOK, poolResponse)
+	server.AddGetResponse("/api/2.0/tags/", http.StatusOK, tagsResponse)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	snapshot, err := TakeRegionSnapshot(controller)
+	c.Assert(err, jc.ErrorIsNil)
+	return snapshot
+}
+
+func (s *snapshotSuite) TestTakeRegionSnapshot(c *gc.C) {
+	snapshot := s.takeSnapshot(c)
+	c.Check(snapshot.Version, gc.Equals, RegionSnapshotVersion)
+	c.Check(snapshot.Taken.IsZero(), jc.IsFalse)
+	c.Check(snapshot.Fabrics, gc.HasLen, 2)
+	c.Check(snapshot.Spaces, gc.HasLen, 1)
+	c.Check(snapshot.Subnets, gc.HasLen, 2)
+	c.Check(snapshot.Zones, gc.HasLen, 2)
+	c.Check(snapshot.Pools, gc.HasLen, 2)
+	c.Check(snapshot.Tags, gc.HasLen, 2)
+	c.Assert(snapshot.Machines, gc.HasLen, 3)
+
+	machine := snapshot.Machines[0]
+	c.Check(machine.SystemID, gc.Equals, "4y3ha3")
+	c.Check(machine.Hostname, gc.Equals, "untasted-markita")
+	c.Check(machine.Zone, gc.Equals, "default")
+	c.Check(machine.Tags, jc.DeepEquals, []string{"magic", "virtual"})
+}
+
+func (s *snapshotSuite) TestTakeRegionSnapshotError(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/fabrics/", http.StatusInternalServerError, "boom")
+	_, err := TakeRegionSnapshot(controller)
+	c.Assert(err, gc.ErrorMatches, "reading fabrics: .*boom.*")
+}
+
+func (s *snapshotSuite) TestReadRegionSnapshotRoundTrip(c *gc.C) {
+	snapshot := s.takeSnapshot(c)
+	data, err := json.Marshal(snapshot)
+	c.Assert(err, jc.ErrorIsNil)
+	read, err := ReadRegionSnapshot(data)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(DiffRegionSnapshots(snapshot, read).Empty(), jc.IsTrue)
+	c.Check(read.Taken.Equal(snapshot.Taken), jc.IsTrue)
+}
+
+func (s *snapshotSuite) TestReadRegionSnapshotBadVersion(c *gc.C) {
+	_, err := ReadRegionSnapshot([]byte(`{"fabrics": []}`))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	_, err = ReadRegionSnapshot([]byte(`{"version": 2}`))
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
+	_, err = ReadRegionSnapshot([]byte(`not json`))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (s *snapshotSuite) TestDiffRegionSnapshots(c *gc.C) {
+	before := &RegionSnapshot{
+		Version: RegionSnapshotVersion,
+		Fabrics: []SnapshotFabric{{Name: "fabric-0", VLANs: []SnapshotVLAN{{VID: 0, Name: "untagged", MTU: 1500}}}},
+		Zones:   []SnapshotZone{{Name: "default"}, {Name: "old"}},
+		Machines: []SnapshotMachine{{
+			SystemID:  "4y3ha3",
+			Status:    "Ready",
+			Tags:      []string{"virtual"},
+			OwnerData: map[string]string{"owner": "alice"},
+		}},
+	}
+	after := &RegionSnapshot{
+		Version: RegionSnapshotVersion,
+		Fabrics: []SnapshotFabric{{Name: "fabric-0", VLANs: []SnapshotVLAN{{VID: 0, Name: "untagged", MTU: 9000}}}},
+		Zones:   []SnapshotZone{{Name: "default", Description: "main"}},
+		Machines: []SnapshotMachine{{
+			SystemID:  "4y3ha3",
+			Status:    "Deployed",
+			Tags:      []string{"gpu", "virtual"},
+			OwnerData: map[string]string{"owner": "bob"},
+		}},
+	}
+	diff := DiffRegionSnapshots(before, after)
+	c.Check(diff.Changes, jc.DeepEquals, []SnapshotChange{
+		{Kind: SnapshotKindVLAN, Key: "fabric-0.0", Property: "mtu", Before: "1500", After: "9000"},
+		{Kind: SnapshotKindZone, Key: "default", Property: "description", Before: "", After: "main"},
+		{Kind: SnapshotKindZone, Key: "old", Property: "present", Before: "true", After: "false"},
+		{Kind: SnapshotKindMachine, Key: "4y3ha3", Property: "owner_data.owner", Before: "alice", After: "bob"},
+		{Kind: SnapshotKindMachine, Key: "4y3ha3", Property: "status", Before: "Ready", After: "Deployed"},
+		{Kind: SnapshotKindMachine, Key: "4y3ha3", Property: "tags", Before: "virtual", After: "gpu,virtual"},
+	})
+	c.Check(diff.Empty(), jc.IsFalse)
+	c.Check(diff.Changes[0].String(), gc.Equals, `vlan fabric-0.0 mtu: "1500" -> "9000"`)
+	c.Check(DiffRegionSnapshots(before, before).Empty(), jc.IsTrue)
+}
@@ -44,7 +44,7 @@ func readSpaces(controllerVersion version.Number, source interface{}) ([]*space,
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "space base schema check failed")
+		return nil, WrapWithDeserializationError(err, "space base schema check failed")
 	}
 	valid := coerced.([]interface{})
 
@@ -67,11 +67,11 @@ func readSpaceList(sourceList []interface{}, readFunc spaceDeserializationFunc)
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, errors.Errorf("unexpected value for space %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for space %d, %T", i, value), indexPath(i))
 		}
 		space, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "space %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "space %d", i)
 		}
 		result = append(result, space)
 	}
@@ -94,7 +94,7 @@ func space_2_0(source map[string]interface{}) (*space, error) {
 	checker := schema.FieldMap(fields, nil) // no defaults
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "space 2.0 schema check failed")
+		return nil, WrapWithDeserializationError(err, "space 2.0 schema check failed")
 	}
 	valid := coerced.(map[string]interface{})
 	// From here we know that the map returned from the schema coercion
@@ -102,7 +102,7 @@ func space_2_0(source map[string]interface{}) (*space, error) {
 
 	subnets, err := readSubnetList(valid["subnets"].([]interface{}), subnet_2_0)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return nil, errors.Trace(atPath(err, "subnets"))
 	}
 
 	result := &space{
@@ -0,0 +1,62 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+// The controller counts the machines and devices it has released or
+// deleted. Each Machine and Device records the count when it was read, so
+// one read before its system ID was released or deleted is stale, while one
+// read afterwards, such as a machine allocated again, is not.
+
+// adoptMachine prepares a machine read from the server for use.
+func (c *controller) adoptMachine(m *machine) {
+	m.controller = c
+	if m.virtualMachine != nil {
+		m.virtualMachine.controller = c
+	}
+	m.generation = c.currentGeneration()
+	c.checkMachineValues(m)
+}
+
+// adoptDevice prepares a device read from the server for use.
+func (c *controller) adoptDevice(d *device) {
+	d.controller = c
+	d.generation = c.currentGeneration()
+	c.checkDeviceValues(d)
+}
+
+type staleRecord struct {
+	generation uint64
+	reason     string
+}
+
+// currentGeneration returns the generation to record on an object as it
+// is read.
+func (c *controller) currentGeneration() uint64 {
+	c.staleMu.Lock()
+	defer c.staleMu.Unlock()
+	return c.generation
+}
+
+// markStale makes the objects for the system ID read so far stale. The
+// reason is used in the error, as in "abc123 was released".
+func (c *controller) markStale(systemID, reason string) {
+	c.staleMu.Lock()
+	defer c.staleMu.Unlock()
+	c.generation++
+	if c.stale == nil {
+		c.stale = make(map[string]staleRecord)
+	}
+	c.stale[systemID] = staleRecord{generation: c.generation, reason: reason}
+}
+
+// checkStale returns a StaleObjectError if the object for the system ID
+// read at the generation is stale.
+func (c *controller) checkStale(systemID string, generation uint64) error {
+	c.staleMu.Lock()
+	defer c.staleMu.Unlock()
+	if record, ok := c.stale[systemID]; ok && record.generation > generation {
+		return NewStaleObjectError(systemID, record.reason)
+	}
+	return nil
+}
@@ -4,6 +4,9 @@
 package gomaasapi
 
 import (
+	"net"
+	"net/http"
+
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
@@ -44,14 +47,108 @@ func (s *staticRoute) Metric() int {
 	return s.metric
 }
 
+// CreateStaticRouteArgs is an argument struct for
+// Controller.CreateStaticRoute. Source, Destination and GatewayIP must be
+// set.
+type CreateStaticRouteArgs struct {
+	// Source and Destination are the ID or CIDR of a subnet.
+	Source      string
+	Destination string
+	// GatewayIP is the address in the source subnet to route through.
+	GatewayIP string
+	// Metric is zero unless set.
+	Metric int
+}
+
+// Validate ensures that the args give both subnets and a gateway address.
+func (a *CreateStaticRouteArgs) Validate() error {
+	if a.Source == "" {
+		return errors.NotValidf("missing Source")
+	}
+	if a.Destination == "" {
+		return errors.NotValidf("missing Destination")
+	}
+	if net.ParseIP(a.GatewayIP) == nil {
+		return errors.NotValidf("GatewayIP %q", a.GatewayIP)
+	}
+	if a.Metric < 0 {
+		return errors.NotValidf("negative Metric")
+	}
+	return nil
+}
+
+// CreateStaticRoute implements Controller.
+func (c *controller) CreateStaticRoute(args CreateStaticRouteArgs) (StaticRoute, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("source", args.Source)
+	params.Values.Add("destination", args.Destination)
+	params.Values.Add("gateway_ip", args.GatewayIP)
+	params.MaybeAddInt("metric", args.Metric)
+	source, err := c.post(StaticRoutesPath, "", params.Values)
+	if err != nil {
+		return nil, errors.Trace(staticRouteError(err))
+	}
+	route, err := readStaticRoute(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return route, nil
+}
+
+// DeleteStaticRoute implements Controller.
+func (c *controller) DeleteStaticRoute(id int) error {
+	if err := c.delete(APIPath(StaticRoutesPath, id)); err != nil {
+		return errors.Trace(staticRouteError(err))
+	}
+	return nil
+}
+
+func staticRouteError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+func readStaticRoute(controllerVersion version.Number, source interface{}) (*staticRoute, error) {
+	readFunc, err := getStaticRouteDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "static-route base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
 func readStaticRoutes(controllerVersion version.Number, source interface{}) ([]*staticRoute, error) {
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "static-route base schema check failed")
+		return nil, WrapWithDeserializationError(err, "static-route base schema check failed")
 	}
 	valid := coerced.([]interface{})
 
+	readFunc, err := getStaticRouteDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return readStaticRouteList(valid, readFunc)
+}
+
+func getStaticRouteDeserializationFunc(controllerVersion version.Number) (staticRouteDeserializationFunc, error) {
 	var deserialisationVersion version.Number
 	for v := range staticRouteDeserializationFuncs {
 		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
@@ -61,8 +158,7 @@ func readStaticRoutes(controllerVersion version.Number, source interface{}) ([]*
 	if deserialisationVersion == version.Zero {
 		return nil, errors.Errorf("no static-route read func for version %s", controllerVersion)
 	}
-	readFunc := staticRouteDeserializationFuncs[deserialisationVersion]
-	return readStaticRouteList(valid, readFunc)
+	return staticRouteDeserializationFuncs[deserialisationVersion], nil
 }
 
 // readStaticRouteList expects the values of the sourceList to be string maps.
@@ -71,11 +167,11 @@ func readStaticRouteList(sourceList []interface{}, readFunc staticRouteDeseriali
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, errors.Errorf("unexpected value for static-route %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for static-route %d, %T", i, value), indexPath(i))
 		}
 		staticRoute, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "static-route %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "static-route %d", i)
 		}
 		result = append(result, staticRoute)
 	}
@@ -100,7 +196,7 @@ func staticRoute_2_0(source map[string]interface{}) (*staticRoute, error) {
 	checker := schema.FieldMap(fields, nil) // no defaults
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "static-route 2.0 schema check failed")
+		return nil, WrapWithDeserializationError(err, "static-route 2.0 schema check failed")
 	}
 	valid := coerced.(map[string]interface{})
 	// From here we know that the map returned from the schema coercion
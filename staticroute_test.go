@@ -4,12 +4,18 @@
 package gomaasapi
 
 import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
 )
 
-type staticRouteSuite struct{}
+type staticRouteSuite struct {
+	testing.LoggingCleanupSuite
+}
 
 var _ = gc.Suite(&staticRouteSuite{})
 
@@ -48,8 +54,75 @@ func (*staticRouteSuite) TestHighVersion(c *gc.C) {
 	c.Assert(staticRoutes, gc.HasLen, 1)
 }
 
-var staticRoutesResponse = `
-[
+func (s *staticRouteSuite) TestCreateStaticRoute(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/static-routes/?op=", http.StatusOK, staticRouteResponse)
+	staticRoute, err := controller.CreateStaticRoute(CreateStaticRouteArgs{
+		Source:      "192.168.0.0/24",
+		Destination: "3",
+		GatewayIP:   "192.168.0.1",
+		Metric:      10,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(staticRoute.ID(), gc.Equals, 2)
+	c.Check(staticRoute.Destination().CIDR(), gc.Equals, "192.168.0.0/16")
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 4)
+	c.Check(form.Get("source"), gc.Equals, "192.168.0.0/24")
+	c.Check(form.Get("destination"), gc.Equals, "3")
+	c.Check(form.Get("gateway_ip"), gc.Equals, "192.168.0.1")
+	c.Check(form.Get("metric"), gc.Equals, "10")
+}
+
+func (s *staticRouteSuite) TestCreateStaticRouteValidates(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	for _, test := range []struct {
+		args    CreateStaticRouteArgs
+		message string
+	}{{
+		args:    CreateStaticRouteArgs{Destination: "3", GatewayIP: "192.168.0.1"},
+		message: "missing Source not valid",
+	}, {
+		args:    CreateStaticRouteArgs{Source: "1", GatewayIP: "192.168.0.1"},
+		message: "missing Destination not valid",
+	}, {
+		args:    CreateStaticRouteArgs{Source: "1", Destination: "3", GatewayIP: "router"},
+		message: `GatewayIP "router" not valid`,
+	}, {
+		args:    CreateStaticRouteArgs{Source: "1", Destination: "3", GatewayIP: "192.168.0.1", Metric: -1},
+		message: "negative Metric not valid",
+	}} {
+		_, err := controller.CreateStaticRoute(test.args)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (s *staticRouteSuite) TestCreateStaticRouteBadGateway(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/static-routes/?op=", http.StatusBadRequest, `{"gateway_ip": ["Enter an IP address in 192.168.0.0/24."]}`)
+	_, err := controller.CreateStaticRoute(CreateStaticRouteArgs{
+		Source:      "1",
+		Destination: "3",
+		GatewayIP:   "10.0.0.1",
+	})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *staticRouteSuite) TestDeleteStaticRoute(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddDeleteResponse("/api/2.0/static-routes/2/", http.StatusNoContent, "")
+	err := controller.DeleteStaticRoute(2)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = controller.DeleteStaticRoute(4)
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+var staticRoutesResponse = "[" + staticRouteResponse + "]"
+
+var staticRouteResponse = `
     {
         "destination": {
             "active_discovery": false,
@@ -108,5 +181,4 @@ var staticRoutesResponse = `
         "metric": 0,
         "gateway_ip": "192.168.0.1"
     }
-]
 `
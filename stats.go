@@ -0,0 +1,132 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// ControllerStats summarizes the machines and subnets of a controller.
+type ControllerStats struct {
+	// Machines is the number of machines, and the other maps count them
+	// by status name, zone name and pool name. Machines without a zone
+	// or pool are counted under the empty name.
+	Machines int
+	ByStatus map[string]int
+	ByZone   map[string]int
+	ByPool   map[string]int
+
+	// Cores and Memory, in MiB, are the totals over all the machines.
+	Cores  int
+	Memory int
+
+	// Storage and StorageUsed are the totals, in bytes, of the size and
+	// used size of the physical block devices of the machines.
+	Storage     uint64
+	StorageUsed uint64
+
+	// Subnets has the address usage of each subnet.
+	Subnets []SubnetUsage
+}
+
+// SubnetUsage is the address usage of a subnet, as the server reports it.
+// The numbers of addresses are floats as those of IPv6 subnets do not fit
+// in any integer type; a /64 has 2^64 addresses.
+type SubnetUsage struct {
+	Subnet Subnet
+
+	TotalAddresses       float64
+	AvailableAddresses   float64
+	UnavailableAddresses float64
+	// LargestAvailable is the size of the largest range of consecutive
+	// available addresses.
+	LargestAvailable float64
+	// Usage is the fraction of the addresses that are unavailable.
+	Usage float64
+}
+
+// Stats implements Controller.
+func (c *controller) Stats() (ControllerStats, error) {
+	machines, err := c.Machines(MachinesArgs{})
+	if err != nil {
+		return ControllerStats{}, errors.Trace(err)
+	}
+	stats := ControllerStats{
+		Machines: len(machines),
+		ByStatus: make(map[string]int),
+		ByZone:   make(map[string]int),
+		ByPool:   make(map[string]int),
+	}
+	for _, m := range machines {
+		stats.ByStatus[m.StatusName()]++
+		var zone, pool string
+		if z := m.Zone(); z != nil {
+			zone = z.Name()
+		}
+		if p := m.Pool(); p != nil {
+			pool = p.Name()
+		}
+		stats.ByZone[zone]++
+		stats.ByPool[pool]++
+		stats.Cores += m.CPUCount()
+		stats.Memory += m.Memory()
+		for _, device := range m.PhysicalBlockDevices() {
+			stats.Storage += device.Size()
+			stats.StorageUsed += device.UsedSize()
+		}
+	}
+
+	subnets, err := c.Subnets()
+	if err != nil {
+		return ControllerStats{}, errors.Trace(err)
+	}
+	for _, subnet := range subnets {
+		usage, err := c.subnetUsage(subnet)
+		if err != nil {
+			return ControllerStats{}, errors.Trace(err)
+		}
+		stats.Subnets = append(stats.Subnets, usage)
+	}
+	return stats, nil
+}
+
+// subnetUsage reads the statistics of the subnet. The subnet list does not
+// include them, so there is a request for each subnet. The numbers are
+// decoded as floats, which the JSON of large IPv6 counts already is.
+func (c *controller) subnetUsage(subnet Subnet) (SubnetUsage, error) {
+	source, err := c.getOp(APIPath(SubnetsPath, subnet.ID()), "statistics")
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok && svrErr.StatusCode == http.StatusNotFound {
+			return SubnetUsage{}, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		}
+		return SubnetUsage{}, NewUnexpectedError(err)
+	}
+	fields := schema.Fields{
+		"num_available":     schema.Float(),
+		"largest_available": schema.Float(),
+		"num_unavailable":   schema.Float(),
+		"total_addresses":   schema.Float(),
+		"usage":             schema.Float(),
+	}
+	checker := schema.FieldMap(fields, nil) // no defaults
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return SubnetUsage{}, WrapWithDeserializationError(err, "subnet statistics schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	return SubnetUsage{
+		Subnet:               subnet,
+		TotalAddresses:       valid["total_addresses"].(float64),
+		AvailableAddresses:   valid["num_available"].(float64),
+		UnavailableAddresses: valid["num_unavailable"].(float64),
+		LargestAvailable:     valid["largest_available"].(float64),
+		Usage:                valid["usage"].(float64),
+	}, nil
+}
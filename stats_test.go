@@ -0,0 +1,98 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type statsSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&statsSuite{})
+
+func (s *statsSuite) TestStats(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	server.AddGetResponse("/api/2.0/subnets/1/?op=statistics", http.StatusOK, subnetStatisticsResponse)
+	server.AddGetResponse("/api/2.0/subnets/34/?op=statistics", http.StatusOK, subnetStatisticsResponse)
+
+	stats, err := controller.Stats()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(stats.Machines, gc.Equals, 3)
+	c.Check(stats.ByStatus, jc.DeepEquals, map[string]int{"Deployed": 1, "Ready": 2})
+	c.Check(stats.ByZone, jc.DeepEquals, map[string]int{"default": 3})
+	c.Check(stats.ByPool, jc.DeepEquals, map[string]int{"default": 3})
+	c.Check(stats.Cores, gc.Equals, 3)
+	c.Check(stats.Memory, gc.Equals, 3072)
+	c.Check(stats.Storage, gc.Equals, uint64(34359738368))
+	c.Check(stats.StorageUsed, gc.Equals, uint64(34347155456))
+
+	c.Assert(stats.Subnets, gc.HasLen, 2)
+	usage := stats.Subnets[0]
+	c.Check(usage.Subnet.ID(), gc.Equals, 1)
+	c.Check(usage.TotalAddresses, gc.Equals, 254.0)
+	c.Check(usage.AvailableAddresses, gc.Equals, 244.0)
+	c.Check(usage.UnavailableAddresses, gc.Equals, 10.0)
+	c.Check(usage.LargestAvailable, gc.Equals, 200.0)
+	c.Check(usage.Usage, gc.Equals, 0.039)
+}
+
+func (s *statsSuite) TestStatsIPv6Subnet(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "[]")
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	server.AddGetResponse("/api/2.0/subnets/1/?op=statistics", http.StatusOK, subnetStatisticsIPv6Response)
+	server.AddGetResponse("/api/2.0/subnets/34/?op=statistics", http.StatusOK, subnetStatisticsResponse)
+
+	stats, err := controller.Stats()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(stats.Subnets, gc.HasLen, 2)
+	usage := stats.Subnets[0]
+	c.Check(usage.TotalAddresses, gc.Equals, math.Pow(2, 64))
+	c.Check(usage.AvailableAddresses, gc.Equals, math.Pow(2, 64)-math.Pow(2, 12))
+	c.Check(usage.UnavailableAddresses, gc.Equals, 4096.0)
+	c.Check(usage.LargestAvailable, gc.Equals, math.Pow(2, 63))
+}
+
+func (s *statsSuite) TestStatsSubnetGone(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	server.AddGetResponse("/api/2.0/subnets/1/?op=statistics", http.StatusNotFound, "gone")
+
+	_, err := controller.Stats()
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+const subnetStatisticsResponse = `
+{
+    "num_available": 244,
+    "largest_available": 200,
+    "num_unavailable": 10,
+    "total_addresses": 254,
+    "usage": 0.039,
+    "usage_string": "3.9%",
+    "available_string": "96.1%"
+}
+`
+
+const subnetStatisticsIPv6Response = `
+{
+    "num_available": 18446744073709547520,
+    "largest_available": 9223372036854775808,
+    "num_unavailable": 4096,
+    "total_addresses": 18446744073709551616,
+    "usage": 2.220446049250313e-16,
+    "usage_string": "0.0%",
+    "available_string": "100.0%"
+}
+`
@@ -4,15 +4,17 @@
 package gomaasapi
 
 import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
 )
 
 type subnet struct {
-	// Add the controller in when we need to do things with the subnet.
-	// controller Controller
-
 	resourceURI string
 
 	id    int
@@ -22,6 +24,7 @@ type subnet struct {
 
 	gateway string
 	cidr    string
+	managed bool
 
 	dnsServers []string
 }
@@ -59,19 +62,178 @@ func (s *subnet) CIDR() string {
 	return s.cidr
 }
 
+// Managed implements Subnet.
+func (s *subnet) Managed() bool {
+	return s.managed
+}
+
 // DNSServers implements Subnet.
 func (s *subnet) DNSServers() []string {
 	return s.dnsServers
 }
 
-func readSubnets(controllerVersion version.Number, source interface{}) ([]*subnet, error) {
-	checker := schema.List(schema.StringMap(schema.Any()))
+// CreateSubnetArgs is an argument struct for calling
+// Controller.CreateSubnet. Only the CIDR is required; the server puts the
+// subnet on the untagged VLAN of the default fabric unless the VLAN, or
+// the Fabric and VID, say otherwise.
+type CreateSubnetArgs struct {
+	CIDR        string
+	Name        string
+	Description string
+
+	// VLAN is the ID of the VLAN for the subnet.
+	VLAN int
+	// Fabric is the name or ID of the fabric, and VID the VLAN on it.
+	Fabric string
+	VID    int
+
+	Space      string
+	GatewayIP  string
+	DNSServers []string
+	// Managed is nil to leave the subnet managed by MAAS.
+	Managed *bool
+}
+
+// Validate ensures that the CIDR is a network, and that the VLAN is not
+// given in two ways.
+func (a *CreateSubnetArgs) Validate() error {
+	if _, _, err := net.ParseCIDR(a.CIDR); err != nil {
+		return errors.NotValidf("CIDR %q", a.CIDR)
+	}
+	if a.VLAN != 0 && (a.Fabric != "" || a.VID != 0) {
+		return errors.NotValidf("both VLAN and Fabric or VID")
+	}
+	if a.VID < 0 || a.VID > 4094 {
+		return errors.NotValidf("VID %d", a.VID)
+	}
+	return nil
+}
+
+// CreateSubnet implements Controller.
+func (c *controller) CreateSubnet(args CreateSubnetArgs) (Subnet, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("cidr", args.CIDR)
+	params.MaybeAdd("name", args.Name)
+	params.MaybeAdd("description", args.Description)
+	params.MaybeAddInt("vlan", args.VLAN)
+	params.MaybeAdd("fabric", args.Fabric)
+	params.MaybeAddInt("vid", args.VID)
+	params.MaybeAdd("space", args.Space)
+	params.MaybeAdd("gateway_ip", args.GatewayIP)
+	params.MaybeAdd("dns_servers", strings.Join(args.DNSServers, ","))
+	if args.Managed != nil {
+		params.Values.Add("managed", strconv.FormatBool(*args.Managed))
+	}
+	source, err := c.post(SubnetsPath, "", params.Values)
+	if err != nil {
+		return nil, subnetError(err)
+	}
+	result, err := readSubnet(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
+
+// UpdateSubnetArgs is an argument struct for calling
+// Controller.UpdateSubnet. Only the fields that are set are changed.
+type UpdateSubnetArgs struct {
+	Name        string
+	Description string
+	// VLAN is the ID of the VLAN to move the subnet to.
+	VLAN      int
+	Space     string
+	GatewayIP string
+	// DNSServers, if not nil, replaces the DNS servers of the subnet. An
+	// empty slice removes them.
+	DNSServers []string
+	Managed    *bool
+}
+
+// UpdateSubnet implements Controller.
+func (c *controller) UpdateSubnet(id int, args UpdateSubnetArgs) (Subnet, error) {
+	params := NewURLParams()
+	params.MaybeAdd("name", args.Name)
+	params.MaybeAdd("description", args.Description)
+	params.MaybeAddInt("vlan", args.VLAN)
+	params.MaybeAdd("space", args.Space)
+	params.MaybeAdd("gateway_ip", args.GatewayIP)
+	if args.DNSServers != nil {
+		params.Values.Add("dns_servers", strings.Join(args.DNSServers, ","))
+	}
+	if args.Managed != nil {
+		params.Values.Add("managed", strconv.FormatBool(*args.Managed))
+	}
+	if len(params.Values) == 0 {
+		return nil, errors.NotValidf("empty update")
+	}
+	source, err := c.put(APIPath(SubnetsPath, id), params.Values)
+	if err != nil {
+		return nil, subnetError(err)
+	}
+	result, err := readSubnet(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
+
+// DeleteSubnet implements Controller.
+func (c *controller) DeleteSubnet(id int) error {
+	if err := c.delete(APIPath(SubnetsPath, id)); err != nil {
+		return subnetError(err)
+	}
+	return nil
+}
+
+// Subnets implements Controller.
+func (c *controller) Subnets() ([]Subnet, error) {
+	source, err := c.get(SubnetsPath)
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	subnets, err := readSubnets(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []Subnet
+	for _, s := range subnets {
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+func subnetError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+func readSubnet(controllerVersion version.Number, source interface{}) (*subnet, error) {
+	readFunc, err := getSubnetDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "subnet base schema check failed")
+		return nil, WrapWithDeserializationError(err, "subnet base schema check failed")
 	}
-	valid := coerced.([]interface{})
+	return readFunc(coerced.(map[string]interface{}))
+}
 
+func getSubnetDeserializationFunc(controllerVersion version.Number) (subnetDeserializationFunc, error) {
 	var deserialisationVersion version.Number
 	for v := range subnetDeserializationFuncs {
 		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
@@ -81,7 +243,21 @@ func readSubnets(controllerVersion version.Number, source interface{}) ([]*subne
 	if deserialisationVersion == version.Zero {
 		return nil, errors.Errorf("no subnet read func for version %s", controllerVersion)
 	}
-	readFunc := subnetDeserializationFuncs[deserialisationVersion]
+	return subnetDeserializationFuncs[deserialisationVersion], nil
+}
+
+func readSubnets(controllerVersion version.Number, source interface{}) ([]*subnet, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "subnet base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	readFunc, err := getSubnetDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	return readSubnetList(valid, readFunc)
 }
 
@@ -91,11 +267,11 @@ func readSubnetList(sourceList []interface{}, readFunc subnetDeserializationFunc
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, errors.Errorf("unexpected value for subnet %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for subnet %d, %T", i, value), indexPath(i))
 		}
 		subnet, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "subnet %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "subnet %d", i)
 		}
 		result = append(result, subnet)
 	}
@@ -118,11 +294,16 @@ func subnet_2_0(source map[string]interface{}) (*subnet, error) {
 		"cidr":         schema.String(),
 		"vlan":         schema.StringMap(schema.Any()),
 		"dns_servers":  schema.OneOf(schema.Nil(""), schema.List(schema.String())),
+		"managed":      schema.Bool(),
 	}
-	checker := schema.FieldMap(fields, nil) // no defaults
+	defaults := schema.Defaults{
+		// Subnets are managed unless the server says otherwise.
+		"managed": true,
+	}
+	checker := schema.FieldMap(fields, defaults)
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "subnet 2.0 schema check failed")
+		return nil, WrapWithDeserializationError(err, "subnet 2.0 schema check failed")
 	}
 	valid := coerced.(map[string]interface{})
 	// From here we know that the map returned from the schema coercion
@@ -130,7 +311,7 @@ func subnet_2_0(source map[string]interface{}) (*subnet, error) {
 
 	vlan, err := vlan_2_0(valid["vlan"].(map[string]interface{}))
 	if err != nil {
-		return nil, errors.Trace(err)
+		return nil, errors.Trace(atPath(err, "vlan"))
 	}
 
 	// Since the gateway_ip is optional, we use the two part cast assignment. If
@@ -146,6 +327,7 @@ func subnet_2_0(source map[string]interface{}) (*subnet, error) {
 		vlan:        vlan,
 		gateway:     gateway,
 		cidr:        valid["cidr"].(string),
+		managed:     valid["managed"].(bool),
 		dnsServers:  convertToStringSlice(valid["dns_servers"]),
 	}
 	return result, nil
@@ -4,12 +4,18 @@
 package gomaasapi
 
 import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
 )
 
-type subnetSuite struct{}
+type subnetSuite struct {
+	testing.LoggingCleanupSuite
+}
 
 var _ = gc.Suite(&subnetSuite{})
 
@@ -38,6 +44,8 @@ func (*subnetSuite) TestReadSubnets(c *gc.C) {
 	c.Assert(vlan, gc.NotNil)
 	c.Assert(vlan.Name(), gc.Equals, "untagged")
 	c.Assert(subnet.DNSServers(), jc.DeepEquals, []string{"8.8.8.8", "8.8.4.4"})
+	c.Assert(subnet.Managed(), jc.IsTrue)
+	c.Assert(subnets[1].Managed(), jc.IsFalse)
 }
 
 func (*subnetSuite) TestLowVersion(c *gc.C) {
@@ -51,6 +59,115 @@ func (*subnetSuite) TestHighVersion(c *gc.C) {
 	c.Assert(subnets, gc.HasLen, 2)
 }
 
+func (s *subnetSuite) TestSubnets(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	subnets, err := controller.Subnets()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnets, gc.HasLen, 2)
+	c.Check(subnets[0].CIDR(), gc.Equals, "192.168.100.0/24")
+}
+
+func (s *subnetSuite) TestCreateSubnet(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/subnets/?op=", http.StatusOK, subnetItemResponse)
+	managed := false
+	subnet, err := controller.CreateSubnet(CreateSubnetArgs{
+		CIDR:       "192.168.100.0/24",
+		Fabric:     "fabric-0",
+		VID:        10,
+		GatewayIP:  "192.168.100.1",
+		DNSServers: []string{"8.8.8.8", "8.8.4.4"},
+		Managed:    &managed,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(subnet.ID(), gc.Equals, 1)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("cidr"), gc.Equals, "192.168.100.0/24")
+	c.Check(form.Get("fabric"), gc.Equals, "fabric-0")
+	c.Check(form.Get("vid"), gc.Equals, "10")
+	c.Check(form.Get("gateway_ip"), gc.Equals, "192.168.100.1")
+	c.Check(form.Get("dns_servers"), gc.Equals, "8.8.8.8,8.8.4.4")
+	c.Check(form.Get("managed"), gc.Equals, "false")
+	_, found := form["vlan"]
+	c.Check(found, jc.IsFalse)
+}
+
+func (s *subnetSuite) TestCreateSubnetValidates(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	_, err := controller.CreateSubnet(CreateSubnetArgs{CIDR: "192.168.100.0"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	_, err = controller.CreateSubnet(CreateSubnetArgs{CIDR: "10.0.0.0/8", VLAN: 5, VID: 10})
+	c.Check(err, gc.ErrorMatches, "both VLAN and Fabric or VID not valid")
+}
+
+func (s *subnetSuite) TestCreateSubnetOverlaps(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/subnets/?op=", http.StatusBadRequest, "Subnet with this Cidr already exists.")
+	_, err := controller.CreateSubnet(CreateSubnetArgs{CIDR: "192.168.100.0/24"})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *subnetSuite) TestUpdateSubnet(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPutResponse("/api/2.0/subnets/1/", http.StatusOK, subnetItemResponse)
+	subnet, err := controller.UpdateSubnet(1, UpdateSubnetArgs{
+		Name:       "dmz",
+		DNSServers: []string{},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(subnet.ID(), gc.Equals, 1)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("name"), gc.Equals, "dmz")
+	c.Check(form["dns_servers"], jc.DeepEquals, []string{""})
+	_, found := form["managed"]
+	c.Check(found, jc.IsFalse)
+}
+
+func (s *subnetSuite) TestUpdateSubnetErrors(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	_, err := controller.UpdateSubnet(1, UpdateSubnetArgs{})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+
+	server.AddPutResponse("/api/2.0/subnets/9/", http.StatusNotFound, "No Subnet matches the given query.")
+	_, err = controller.UpdateSubnet(9, UpdateSubnetArgs{Name: "dmz"})
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *subnetSuite) TestDeleteSubnet(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddDeleteResponse("/api/2.0/subnets/1/", http.StatusNoContent, "")
+	server.AddDeleteResponse("/api/2.0/subnets/2/", http.StatusForbidden, "bad user")
+	c.Check(controller.DeleteSubnet(1), jc.ErrorIsNil)
+	c.Check(controller.DeleteSubnet(2), jc.Satisfies, IsPermissionError)
+}
+
+var subnetItemResponse = `
+{
+    "gateway_ip": "192.168.100.1",
+    "name": "192.168.100.0/24",
+    "vlan": {
+        "fabric": "fabric-0",
+        "resource_uri": "/MAAS/api/2.0/vlans/1/",
+        "name": "untagged",
+        "secondary_rack": null,
+        "primary_rack": "4y3h7n",
+        "vid": 0,
+        "dhcp_on": true,
+        "id": 1,
+        "mtu": 1500
+    },
+    "space": "space-0",
+    "id": 1,
+    "resource_uri": "/MAAS/api/2.0/subnets/1/",
+    "dns_servers": ["8.8.8.8", "8.8.4.4"],
+    "cidr": "192.168.100.0/24",
+    "managed": false
+}
+`
+
 var subnetResponse = `
 [
     {
@@ -93,7 +210,8 @@ var subnetResponse = `
         "resource_uri": "/MAAS/api/2.0/subnets/34/",
         "dns_servers": null,
         "cidr": "192.168.122.0/24",
-        "rdns_mode": 2
+        "rdns_mode": 2,
+        "managed": false
     }
 ]
 `
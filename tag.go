@@ -0,0 +1,311 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type tag struct {
+	controller *controller
+
+	resourceURI string
+
+	name       string
+	comment    string
+	definition string
+	kernelOpts string
+}
+
+// Name implements Tag.
+func (t *tag) Name() string {
+	return t.name
+}
+
+// Comment implements Tag.
+func (t *tag) Comment() string {
+	return t.comment
+}
+
+// Definition implements Tag.
+func (t *tag) Definition() string {
+	return t.definition
+}
+
+// KernelOpts implements Tag.
+func (t *tag) KernelOpts() string {
+	return t.kernelOpts
+}
+
+// Delete implements Tag.
+func (t *tag) Delete() error {
+	err := t.controller.delete(t.resourceURI)
+	if err != nil {
+		return errors.Trace(tagError(err))
+	}
+	return nil
+}
+
+// Machines implements Tag.
+func (t *tag) Machines() ([]Machine, error) {
+	source, err := t.controller.getOp(t.resourceURI, "machines")
+	if err != nil {
+		return nil, errors.Trace(tagError(err))
+	}
+	machines, err := readMachines(t.controller.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []Machine
+	for _, m := range machines {
+		t.controller.adoptMachine(m)
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// UpdateTagMachinesArgs is an argument struct for Tag.UpdateMachines.
+type UpdateTagMachinesArgs struct {
+	// Add holds the system IDs of the machines to add the tag to.
+	Add []string
+	// Remove holds the system IDs of the machines to remove the tag from.
+	Remove []string
+}
+
+// Validate ensures that there are machines to change, and that none of them
+// is both added and removed.
+func (a *UpdateTagMachinesArgs) Validate() error {
+	if len(a.Add) == 0 && len(a.Remove) == 0 {
+		return errors.NotValidf("missing Add and Remove")
+	}
+	added := set.NewStrings(a.Add...)
+	for _, systemID := range a.Remove {
+		if added.Contains(systemID) {
+			return errors.NotValidf("machine %q both added and removed", systemID)
+		}
+	}
+	return nil
+}
+
+// UpdateMachines implements Tag.
+func (t *tag) UpdateMachines(args UpdateTagMachinesArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.MaybeAddMany("add", args.Add)
+	params.MaybeAddMany("remove", args.Remove)
+	if _, err := t.controller.post(t.resourceURI, "update_nodes", params.Values); err != nil {
+		systemIDs := append(append([]string(nil), args.Add...), args.Remove...)
+		return bulkErrorForAll(systemIDs, tagError(err))
+	}
+	return nil
+}
+
+// Tags implements Controller.
+func (c *controller) Tags() ([]Tag, error) {
+	source, err := c.get(TagsPath)
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	tags, err := readTags(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []Tag
+	for _, t := range tags {
+		t.controller = c
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+// CreateTag implements Controller.
+func (c *controller) CreateTag(name, comment, definition string) (Tag, error) {
+	if name == "" {
+		return nil, errors.NotValidf("missing name")
+	}
+	params := NewURLParams()
+	params.Values.Add("name", name)
+	params.MaybeAdd("comment", comment)
+	params.MaybeAdd("definition", definition)
+	source, err := c.post(TagsPath, "", params.Values)
+	if err != nil {
+		return nil, errors.Trace(tagError(err))
+	}
+	t, err := readTag(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	t.controller = c
+	return t, nil
+}
+
+// AddTag implements Machine.
+func (m *machine) AddTag(name string) error {
+	if err := m.changeTag("add", name); err != nil {
+		return errors.Trace(err)
+	}
+	for _, t := range m.tags {
+		if t == name {
+			return nil
+		}
+	}
+	m.tags = append(m.tags, name)
+	return nil
+}
+
+// RemoveTag implements Machine.
+func (m *machine) RemoveTag(name string) error {
+	if err := m.changeTag("remove", name); err != nil {
+		return errors.Trace(err)
+	}
+	var tags []string
+	for _, t := range m.tags {
+		if t != name {
+			tags = append(tags, t)
+		}
+	}
+	m.tags = tags
+	return nil
+}
+
+// changeTag adds the machine to, or removes it from, the tag. The server
+// changes the machines of a tag rather than the tags of a machine.
+func (m *machine) changeTag(change, name string) error {
+	if name == "" {
+		return errors.NotValidf("empty tag")
+	}
+	if err := m.controller.checkStale(m.systemID, m.generation); err != nil {
+		return errors.Trace(err)
+	}
+	params := make(url.Values)
+	params.Add(change, m.systemID)
+	_, err := m.controller.post(APIPath(TagsPath, name), "update_nodes", params)
+	if err != nil {
+		return errors.Trace(tagError(err))
+	}
+	return nil
+}
+
+// tagError translates the errors of the tags API. The server refuses to
+// change the machines of a tag with a definition, as they are chosen by
+// the definition.
+func tagError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		case http.StatusConflict:
+			return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+func readTag(controllerVersion version.Number, source interface{}) (*tag, error) {
+	readFunc, err := getTagDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "tag base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
+func readTags(controllerVersion version.Number, source interface{}) ([]*tag, error) {
+	readFunc, err := getTagDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "tag base schema check failed")
+	}
+	return readTagList(coerced.([]interface{}), readFunc)
+}
+
+func getTagDeserializationFunc(controllerVersion version.Number) (tagDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range tagDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no tag read func for version %s", controllerVersion)
+	}
+	return tagDeserializationFuncs[deserialisationVersion], nil
+}
+
+// readTagList expects the values of the sourceList to be string maps.
+func readTagList(sourceList []interface{}, readFunc tagDeserializationFunc) ([]*tag, error) {
+	result := make([]*tag, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, atPath(NewDeserializationError("unexpected value for tag %d, %T", i, value), indexPath(i))
+		}
+		t, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "tag %d", i)
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+type tagDeserializationFunc func(map[string]interface{}) (*tag, error)
+
+var tagDeserializationFuncs = map[version.Number]tagDeserializationFunc{
+	twoDotOh: tag_2_0,
+}
+
+func tag_2_0(source map[string]interface{}) (*tag, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+		"name":         schema.String(),
+		"comment":      schema.OneOf(schema.Nil(""), schema.String()),
+		"definition":   schema.OneOf(schema.Nil(""), schema.String()),
+		"kernel_opts":  schema.OneOf(schema.Nil(""), schema.String()),
+	}
+	defaults := schema.Defaults{
+		"comment":     "",
+		"definition":  "",
+		"kernel_opts": "",
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "tag 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	comment, _ := valid["comment"].(string)
+	definition, _ := valid["definition"].(string)
+	kernelOpts, _ := valid["kernel_opts"].(string)
+	return &tag{
+		resourceURI: valid["resource_uri"].(string),
+		name:        valid["name"].(string),
+		comment:     comment,
+		definition:  definition,
+		kernelOpts:  kernelOpts,
+	}, nil
+}
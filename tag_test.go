@@ -0,0 +1,179 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type tagSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&tagSuite{})
+
+func (*tagSuite) TestReadTagsBadSchema(c *gc.C) {
+	_, err := readTags(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `tag base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*tagSuite) TestReadTags(c *gc.C) {
+	tags, err := readTags(twoDotOh, parseJSON(c, tagsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(tags, gc.HasLen, 2)
+
+	c.Check(tags[0].Name(), gc.Equals, "virtual")
+	c.Check(tags[0].Comment(), gc.Equals, "")
+	c.Check(tags[0].Definition(), gc.Equals, "")
+	c.Check(tags[1].Name(), gc.Equals, "gpu")
+	c.Check(tags[1].Comment(), gc.Equals, "machines with NVIDIA GPUs")
+	c.Check(tags[1].Definition(), gc.Equals, "//node[@class='display']/vendor[contains(.,'NVIDIA')]")
+	c.Check(tags[1].KernelOpts(), gc.Equals, "nomodeset")
+}
+
+func (*tagSuite) TestLowVersion(c *gc.C) {
+	_, err := readTags(version.MustParse("1.9.0"), parseJSON(c, tagsResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (s *tagSuite) getServerAndTag(c *gc.C) (*SimpleTestServer, Tag) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/tags/", http.StatusOK, tagsResponse)
+	tags, err := controller.Tags()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(tags, gc.HasLen, 2)
+	return server, tags[0]
+}
+
+func (s *tagSuite) TestCreateTag(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/tags/?op=", http.StatusOK, tagResponse)
+	tag, err := controller.CreateTag("virtual", "", "")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(tag.Name(), gc.Equals, "virtual")
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("name"), gc.Equals, "virtual")
+	_, found := form["definition"]
+	c.Check(found, jc.IsFalse)
+
+	_, err = controller.CreateTag("", "", "")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *tagSuite) TestCreateTagInUse(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/tags/?op=", http.StatusBadRequest, "Tag with this Name already exists.")
+	_, err := controller.CreateTag("virtual", "", "")
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *tagSuite) TestDelete(c *gc.C) {
+	server, tag := s.getServerAndTag(c)
+	server.AddDeleteResponse("/MAAS/api/2.0/tags/virtual/", http.StatusNoContent, "")
+	c.Assert(tag.Delete(), jc.ErrorIsNil)
+	server.AddDeleteResponse("/MAAS/api/2.0/tags/virtual/", http.StatusNotFound, "No Tag matches the given query.")
+	c.Check(tag.Delete(), jc.Satisfies, IsNoMatchError)
+}
+
+func (s *tagSuite) TestMachines(c *gc.C) {
+	server, tag := s.getServerAndTag(c)
+	server.AddGetResponse("/MAAS/api/2.0/tags/virtual/?op=machines", http.StatusOK, machinesResponse)
+	machines, err := tag.Machines()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines, gc.HasLen, 3)
+}
+
+func (s *tagSuite) TestUpdateMachines(c *gc.C) {
+	server, tag := s.getServerAndTag(c)
+	server.AddPostResponse("/MAAS/api/2.0/tags/virtual/?op=update_nodes", http.StatusOK, `{"added": 2, "removed": 1}`)
+	err := tag.UpdateMachines(UpdateTagMachinesArgs{
+		Add:    []string{"4y3ha3", "4y3ha4"},
+		Remove: []string{"4y3ha6"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	form := server.LastRequest().PostForm
+	c.Check(form["add"], jc.DeepEquals, []string{"4y3ha3", "4y3ha4"})
+	c.Check(form["remove"], jc.DeepEquals, []string{"4y3ha6"})
+}
+
+func (s *tagSuite) TestUpdateMachinesError(c *gc.C) {
+	server, tag := s.getServerAndTag(c)
+	server.AddPostResponse("/MAAS/api/2.0/tags/virtual/?op=update_nodes", http.StatusForbidden, "admins only")
+	err := tag.UpdateMachines(UpdateTagMachinesArgs{
+		Add:    []string{"4y3ha3"},
+		Remove: []string{"4y3ha6"},
+	})
+	c.Assert(err, jc.Satisfies, IsBulkError)
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	bulk, _ := AsBulkError(err)
+	c.Check(bulk.Failed(), jc.DeepEquals, []string{"4y3ha3", "4y3ha6"})
+}
+
+func (s *tagSuite) TestUpdateMachinesValidates(c *gc.C) {
+	_, tag := s.getServerAndTag(c)
+	err := tag.UpdateMachines(UpdateTagMachinesArgs{})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	err = tag.UpdateMachines(UpdateTagMachinesArgs{Add: []string{"a"}, Remove: []string{"a"}})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, `machine "a" both added and removed not valid`)
+}
+
+func (s *tagSuite) TestMachineAddRemoveTag(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	machine := machines[0]
+	c.Assert(machine.Tags(), jc.DeepEquals, []string{"virtual", "magic"})
+
+	server.AddPostResponse("/api/2.0/tags/gpu/?op=update_nodes", http.StatusOK, `{"added": 1, "removed": 0}`)
+	c.Assert(machine.AddTag("gpu"), jc.ErrorIsNil)
+	c.Check(server.LastRequest().PostForm.Get("add"), gc.Equals, "4y3ha3")
+	c.Check(machine.Tags(), jc.DeepEquals, []string{"virtual", "magic", "gpu"})
+
+	server.AddPostResponse("/api/2.0/tags/virtual/?op=update_nodes", http.StatusOK, `{"added": 0, "removed": 1}`)
+	c.Assert(machine.RemoveTag("virtual"), jc.ErrorIsNil)
+	c.Check(server.LastRequest().PostForm.Get("remove"), gc.Equals, "4y3ha3")
+	c.Check(machine.Tags(), jc.DeepEquals, []string{"magic", "gpu"})
+}
+
+func (s *tagSuite) TestMachineAddTagErrors(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	machine := machines[0]
+
+	c.Check(machine.AddTag(""), jc.Satisfies, errors.IsNotValid)
+	server.AddPostResponse("/api/2.0/tags/gpu/?op=update_nodes", http.StatusBadRequest, "Cannot manually add nodes to a tag with a definition.")
+	c.Check(machine.AddTag("gpu"), jc.Satisfies, IsBadRequestError)
+	c.Check(machine.Tags(), jc.DeepEquals, []string{"virtual", "magic"})
+}
+
+const (
+	tagResponse = `
+{
+    "name": "virtual",
+    "definition": "",
+    "comment": "",
+    "kernel_opts": null,
+    "resource_uri": "/MAAS/api/2.0/tags/virtual/"
+}
+`
+	tagsResponse = "[" + tagResponse + `, {
+    "name": "gpu",
+    "definition": "//node[@class='display']/vendor[contains(.,'NVIDIA')]",
+    "comment": "machines with NVIDIA GPUs",
+    "kernel_opts": "nomodeset",
+    "resource_uri": "/MAAS/api/2.0/tags/gpu/"
+}]`
+)
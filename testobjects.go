@@ -0,0 +1,444 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/clock"
+)
+
+// The constructors in this file create fully populated instances of the
+// interface types without needing a MAAS server or JSON fixtures. They are
+// intended for the unit tests of code that consumes this package. Objects
+// created this way are not bound to a MAAS server, so the methods that need
+// to talk to the MAAS API (Start, Delete, Update, ...) fail with an error
+// that says "test object not supported".
+
+// newTestObjectController returns the controller of an object made by the
+// constructors, whose requests all fail.
+func newTestObjectController() *controller {
+	return &controller{
+		client: &Client{
+			APIURL:     &url.URL{Scheme: "http", Host: "test-object.invalid", Path: "/MAAS/api/2.0/"},
+			Signer:     anonSigner{},
+			HTTPClient: &http.Client{Transport: testObjectTransport{}},
+		},
+		baseAPIVersion:  twoDotOh,
+		clock:           clock.WallClock,
+		controllerState: &controllerState{apiVersion: twoDotOh},
+		rateLimits:      &rateLimitTracker{},
+	}
+}
+
+// testObjectTransport fails every request with an error satisfying
+// errors.IsNotSupported.
+type testObjectTransport struct{}
+
+func (testObjectTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.NotSupportedf("test object")
+}
+
+// ZoneSpec describes a Zone created by NewTestZone.
+type ZoneSpec struct {
+	ID          int
+	Name        string
+	Description string
+}
+
+// NewTestZone returns a Zone with the values from the spec.
+func NewTestZone(spec ZoneSpec) Zone {
+	return newTestZone(spec)
+}
+
+func newTestZone(spec ZoneSpec) *zone {
+	return &zone{
+		id:          spec.ID,
+		name:        spec.Name,
+		description: spec.Description,
+	}
+}
+
+// PoolSpec describes a Pool created by NewTestPool.
+type PoolSpec struct {
+	ID          int
+	Name        string
+	Description string
+}
+
+// NewTestPool returns a Pool with the values from the spec.
+func NewTestPool(spec PoolSpec) Pool {
+	result := newTestPool(spec)
+	result.controller = newTestObjectController()
+	return result
+}
+
+func newTestPool(spec PoolSpec) *pool {
+	return &pool{
+		id:          spec.ID,
+		name:        spec.Name,
+		description: spec.Description,
+	}
+}
+
+// DomainSpec describes a Domain created by NewTestDomain.
+type DomainSpec struct {
+	ID   int
+	Name string
+}
+
+// NewTestDomain returns a Domain with the values from the spec.
+func NewTestDomain(spec DomainSpec) Domain {
+	result := newTestDomain(spec)
+	result.controller = newTestObjectController()
+	return result
+}
+
+func newTestDomain(spec DomainSpec) *domain {
+	return &domain{
+		id:   spec.ID,
+		name: spec.Name,
+	}
+}
+
+// VLANSpec describes a VLAN created by NewTestVLAN.
+type VLANSpec struct {
+	ID            int
+	Name          string
+	Fabric        string
+	VID           int
+	MTU           int
+	DHCP          bool
+	PrimaryRack   string
+	SecondaryRack string
+}
+
+// NewTestVLAN returns a VLAN with the values from the spec.
+func NewTestVLAN(spec VLANSpec) VLAN {
+	return newTestVLAN(spec)
+}
+
+func newTestVLAN(spec VLANSpec) *vlan {
+	return &vlan{
+		id:            spec.ID,
+		name:          spec.Name,
+		fabric:        spec.Fabric,
+		vid:           spec.VID,
+		mtu:           spec.MTU,
+		dhcp:          spec.DHCP,
+		primaryRack:   spec.PrimaryRack,
+		secondaryRack: spec.SecondaryRack,
+	}
+}
+
+// SubnetSpec describes a Subnet created by NewTestSubnet.
+type SubnetSpec struct {
+	ID         int
+	Name       string
+	Space      string
+	VLAN       VLANSpec
+	Gateway    string
+	CIDR       string
+	DNSServers []string
+	// Unmanaged makes Managed return false.
+	Unmanaged bool
+}
+
+// NewTestSubnet returns a Subnet with the values from the spec.
+func NewTestSubnet(spec SubnetSpec) Subnet {
+	return newTestSubnet(spec)
+}
+
+func newTestSubnet(spec SubnetSpec) *subnet {
+	return &subnet{
+		id:         spec.ID,
+		name:       spec.Name,
+		space:      spec.Space,
+		vlan:       newTestVLAN(spec.VLAN),
+		gateway:    spec.Gateway,
+		cidr:       spec.CIDR,
+		managed:    !spec.Unmanaged,
+		dnsServers: spec.DNSServers,
+	}
+}
+
+// LinkSpec describes a Link created as part of an InterfaceTestSpec.
+type LinkSpec struct {
+	ID        int
+	Mode      string
+	Subnet    *SubnetSpec
+	IPAddress string
+}
+
+func newTestLink(spec LinkSpec) *link {
+	result := &link{
+		id:        spec.ID,
+		mode:      spec.Mode,
+		ipAddress: spec.IPAddress,
+	}
+	if spec.Subnet != nil {
+		result.subnet = newTestSubnet(*spec.Subnet)
+	}
+	return result
+}
+
+// InterfaceTestSpec describes an Interface created by NewTestInterface.
+// It is not named InterfaceSpec as that name is used by the allocation
+// constraints.
+type InterfaceTestSpec struct {
+	ID             int
+	Name           string
+	Type           string
+	Enabled        bool
+	Tags           []string
+	VLAN           *VLANSpec
+	Links          []LinkSpec
+	MACAddress     string
+	EffectiveMTU   int
+	LinkSpeed      int
+	InterfaceSpeed int
+	Parents        []string
+	Children       []string
+}
+
+// NewTestInterface returns an Interface with the values from the spec.
+func NewTestInterface(spec InterfaceTestSpec) Interface {
+	result := newTestInterface(spec)
+	result.controller = newTestObjectController()
+	return result
+}
+
+func newTestInterface(spec InterfaceTestSpec) *interface_ {
+	result := &interface_{
+		id:             spec.ID,
+		name:           spec.Name,
+		type_:          spec.Type,
+		enabled:        spec.Enabled,
+		tags:           spec.Tags,
+		macAddress:     spec.MACAddress,
+		effectiveMTU:   spec.EffectiveMTU,
+		linkSpeed:      spec.LinkSpeed,
+		interfaceSpeed: spec.InterfaceSpeed,
+		parents:        spec.Parents,
+		children:       spec.Children,
+	}
+	if spec.VLAN != nil {
+		result.vlan = newTestVLAN(*spec.VLAN)
+	}
+	for _, link := range spec.Links {
+		result.links = append(result.links, newTestLink(link))
+	}
+	return result
+}
+
+// FileSystemSpec describes a FileSystem created as part of a storage spec.
+type FileSystemSpec struct {
+	Type       string
+	MountPoint string
+	Label      string
+	UUID       string
+}
+
+func newTestFileSystem(spec *FileSystemSpec) *filesystem {
+	if spec == nil {
+		return nil
+	}
+	return &filesystem{
+		fstype:     spec.Type,
+		mountPoint: spec.MountPoint,
+		label:      spec.Label,
+		uuid:       spec.UUID,
+	}
+}
+
+// PartitionSpec describes a Partition created as part of a BlockDeviceSpec.
+type PartitionSpec struct {
+	ID         int
+	Path       string
+	UUID       string
+	UsedFor    string
+	Size       uint64
+	Tags       []string
+	FileSystem *FileSystemSpec
+}
+
+func newTestPartition(spec PartitionSpec) *partition {
+	return &partition{
+		id:         spec.ID,
+		path:       spec.Path,
+		uuid:       spec.UUID,
+		usedFor:    spec.UsedFor,
+		size:       spec.Size,
+		tags:       spec.Tags,
+		filesystem: newTestFileSystem(spec.FileSystem),
+	}
+}
+
+// BlockDeviceSpec describes a BlockDevice created by NewTestBlockDevice.
+type BlockDeviceSpec struct {
+	ID         int
+	UUID       string
+	Name       string
+	Model      string
+	IDPath     string
+	Path       string
+	UsedFor    string
+	Tags       []string
+	BlockSize  uint64
+	UsedSize   uint64
+	Size       uint64
+	FileSystem *FileSystemSpec
+	Partitions []PartitionSpec
+}
+
+// NewTestBlockDevice returns a BlockDevice with the values from the spec.
+func NewTestBlockDevice(spec BlockDeviceSpec) BlockDevice {
+	result := newTestBlockDevice(spec)
+	result.setController(newTestObjectController())
+	return result
+}
+
+func newTestBlockDevice(spec BlockDeviceSpec) *blockdevice {
+	result := &blockdevice{
+		id:         spec.ID,
+		uuid:       spec.UUID,
+		name:       spec.Name,
+		model:      spec.Model,
+		idPath:     spec.IDPath,
+		path:       spec.Path,
+		usedFor:    spec.UsedFor,
+		tags:       spec.Tags,
+		blockSize:  spec.BlockSize,
+		usedSize:   spec.UsedSize,
+		size:       spec.Size,
+		filesystem: newTestFileSystem(spec.FileSystem),
+	}
+	for _, p := range spec.Partitions {
+		result.partitions = append(result.partitions, newTestPartition(p))
+	}
+	return result
+}
+
+// DeviceSpec describes a Device created by NewTestDevice.
+type DeviceSpec struct {
+	SystemID    string
+	Hostname    string
+	FQDN        string
+	Parent      string
+	Owner       string
+	IPAddresses []string
+	Interfaces  []InterfaceTestSpec
+	Zone        *ZoneSpec
+	Pool        *PoolSpec
+	Domain      *DomainSpec
+}
+
+// NewTestDevice returns a Device with the values from the spec.
+func NewTestDevice(spec DeviceSpec) Device {
+	result := &device{
+		controller:  newTestObjectController(),
+		systemID:    spec.SystemID,
+		hostname:    spec.Hostname,
+		fqdn:        spec.FQDN,
+		parent:      spec.Parent,
+		owner:       spec.Owner,
+		ipAddresses: spec.IPAddresses,
+	}
+	for _, iface := range spec.Interfaces {
+		result.interfaceSet = append(result.interfaceSet, newTestInterface(iface))
+	}
+	if spec.Zone != nil {
+		result.zone = newTestZone(*spec.Zone)
+	}
+	if spec.Pool != nil {
+		result.pool = newTestPool(*spec.Pool)
+	}
+	if spec.Domain != nil {
+		result.domain = newTestDomain(*spec.Domain)
+	}
+	return result
+}
+
+// MachineSpec describes a Machine created by NewTestMachine.
+//
+// BootInterface, when set, is the name of one of the Interfaces. All the
+// BlockDevices are considered physical.
+type MachineSpec struct {
+	SystemID  string
+	Hostname  string
+	FQDN      string
+	NodeType  NodeType
+	Tags      []string
+	OwnerData map[string]string
+
+	OperatingSystem string
+	DistroSeries    string
+	HWEKernel       string
+	Architecture    string
+	Memory          int
+	CPUCount        int
+
+	IPAddresses   []string
+	PowerState    string
+	PowerType     string
+	StatusName    string
+	StatusMessage string
+	Owner         string
+
+	BootInterface string
+	Interfaces    []InterfaceTestSpec
+	BlockDevices  []BlockDeviceSpec
+	Zone          *ZoneSpec
+	Pool          *PoolSpec
+	Domain        *DomainSpec
+}
+
+// NewTestMachine returns a Machine with the values from the spec.
+func NewTestMachine(spec MachineSpec) Machine {
+	result := &machine{
+		controller:      newTestObjectController(),
+		systemID:        spec.SystemID,
+		hostname:        spec.Hostname,
+		fqdn:            spec.FQDN,
+		nodeType:        spec.NodeType,
+		tags:            spec.Tags,
+		ownerData:       spec.OwnerData,
+		operatingSystem: spec.OperatingSystem,
+		distroSeries:    spec.DistroSeries,
+		hweKernel:       spec.HWEKernel,
+		architecture:    spec.Architecture,
+		memory:          spec.Memory,
+		cpuCount:        spec.CPUCount,
+		ipAddresses:     spec.IPAddresses,
+		powerState:      spec.PowerState,
+		powerType:       spec.PowerType,
+		statusName:      spec.StatusName,
+		statusMessage:   spec.StatusMessage,
+		owner:           spec.Owner,
+	}
+	for _, ifaceSpec := range spec.Interfaces {
+		iface := newTestInterface(ifaceSpec)
+		if spec.BootInterface != "" && iface.name == spec.BootInterface {
+			result.bootInterface = iface
+		}
+		result.interfaceSet = append(result.interfaceSet, iface)
+	}
+	for _, deviceSpec := range spec.BlockDevices {
+		blockDevice := newTestBlockDevice(deviceSpec)
+		result.physicalBlockDevices = append(result.physicalBlockDevices, blockDevice)
+		result.blockDevices = append(result.blockDevices, blockDevice)
+	}
+	if spec.Zone != nil {
+		result.zone = newTestZone(*spec.Zone)
+	}
+	if spec.Pool != nil {
+		result.pool = newTestPool(*spec.Pool)
+	}
+	if spec.Domain != nil {
+		result.domain = newTestDomain(*spec.Domain)
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type testObjectsSuite struct{}
+
+var _ = gc.Suite(&testObjectsSuite{})
+
+func (*testObjectsSuite) TestNewTestMachine(c *gc.C) {
+	subnet := SubnetSpec{ID: 3, CIDR: "10.0.0.0/24", VLAN: VLANSpec{ID: 1, VID: 0}}
+	machine := NewTestMachine(MachineSpec{
+		SystemID:      "4y3ha3",
+		Hostname:      "untasted-markita",
+		Memory:        1024,
+		CPUCount:      2,
+		StatusName:    "Deployed",
+		OwnerData:     map[string]string{"fez": "phil fish"},
+		BootInterface: "eth0",
+		Interfaces: []InterfaceTestSpec{{
+			ID:   1,
+			Name: "eth0",
+			Links: []LinkSpec{{
+				ID:        7,
+				Mode:      "static",
+				Subnet:    &subnet,
+				IPAddress: "10.0.0.4",
+			}},
+		}, {
+			ID:   2,
+			Name: "eth1",
+		}},
+		BlockDevices: []BlockDeviceSpec{{
+			ID:         34,
+			Name:       "sda",
+			Size:       8589934592,
+			Partitions: []PartitionSpec{{ID: 1, FileSystem: &FileSystemSpec{Type: "ext4", MountPoint: "/"}}},
+		}},
+		Zone: &ZoneSpec{Name: "default"},
+	})
+
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(machine.Hostname(), gc.Equals, "untasted-markita")
+	c.Check(machine.Memory(), gc.Equals, 1024)
+	c.Check(machine.CPUCount(), gc.Equals, 2)
+	c.Check(machine.StatusName(), gc.Equals, "Deployed")
+	c.Check(machine.OwnerData(), jc.DeepEquals, map[string]string{"fez": "phil fish"})
+	c.Check(machine.Zone().Name(), gc.Equals, "default")
+	c.Check(machine.Pool(), gc.IsNil)
+	c.Check(machine.Domain(), gc.IsNil)
+
+	c.Assert(machine.BootInterface(), gc.NotNil)
+	c.Check(machine.BootInterface().ID(), gc.Equals, 1)
+	c.Check(machine.InterfaceSet(), gc.HasLen, 2)
+	c.Check(machine.Interface(2).Name(), gc.Equals, "eth1")
+	link := machine.Interface(1).Links()[0]
+	c.Check(link.IPAddress(), gc.Equals, "10.0.0.4")
+	c.Check(link.Subnet().CIDR(), gc.Equals, "10.0.0.0/24")
+	c.Check(link.Subnet().VLAN().ID(), gc.Equals, 1)
+
+	c.Check(machine.PhysicalBlockDevices(), gc.HasLen, 1)
+	c.Check(machine.BlockDevice(34).Name(), gc.Equals, "sda")
+	c.Check(machine.Partition(1).FileSystem().MountPoint(), gc.Equals, "/")
+}
+
+func (*testObjectsSuite) TestNewTestDevice(c *gc.C) {
+	device := NewTestDevice(DeviceSpec{
+		SystemID:   "4y3haf",
+		Parent:     "4y3ha3",
+		Interfaces: []InterfaceTestSpec{{ID: 48, Name: "eth0", VLAN: &VLANSpec{ID: 1}}},
+		Pool:       &PoolSpec{ID: 1, Name: "default"},
+		Domain:     &DomainSpec{ID: 0, Name: "maas"},
+	})
+	c.Check(device.SystemID(), gc.Equals, "4y3haf")
+	c.Check(device.Parent(), gc.Equals, "4y3ha3")
+	c.Check(device.InterfaceSet(), gc.HasLen, 1)
+	c.Check(device.InterfaceSet()[0].VLAN().ID(), gc.Equals, 1)
+	c.Check(device.Zone(), gc.IsNil)
+	c.Check(device.Pool().Name(), gc.Equals, "default")
+	c.Check(device.Pool().ID(), gc.Equals, 1)
+	c.Check(device.Domain().Name(), gc.Equals, "maas")
+}
+
+func (*testObjectsSuite) TestNewTestSubnet(c *gc.C) {
+	subnet := NewTestSubnet(SubnetSpec{
+		ID:         1,
+		CIDR:       "192.168.100.0/24",
+		Gateway:    "192.168.100.1",
+		DNSServers: []string{"8.8.8.8"},
+		VLAN:       VLANSpec{ID: 5, VID: 100},
+	})
+	c.Check(subnet.CIDR(), gc.Equals, "192.168.100.0/24")
+	c.Check(subnet.Gateway(), gc.Equals, "192.168.100.1")
+	c.Check(subnet.DNSServers(), jc.DeepEquals, []string{"8.8.8.8"})
+	c.Check(subnet.VLAN().VID(), gc.Equals, 100)
+}
+
+func (*testObjectsSuite) TestServerMethodsFail(c *gc.C) {
+	machine := NewTestMachine(MachineSpec{
+		SystemID:     "4y3ha3",
+		PowerType:    "ipmi",
+		Interfaces:   []InterfaceTestSpec{{ID: 1, Name: "eth0"}},
+		BlockDevices: []BlockDeviceSpec{{ID: 34, Name: "sda"}},
+	})
+	_, err := machine.PowerParameters()
+	c.Check(err, gc.ErrorMatches, ".*test object not supported.*")
+	err = machine.Start(StartArgs{})
+	c.Check(err, gc.ErrorMatches, ".*test object not supported.*")
+	err = machine.Interface(1).Update(UpdateInterfaceArgs{Name: "eth1"})
+	c.Check(err, gc.ErrorMatches, ".*test object not supported.*")
+	_, err = machine.BlockDevice(34).CreatePartition(CreatePartitionArgs{})
+	c.Check(err, gc.ErrorMatches, ".*test object not supported.*")
+
+	device := NewTestDevice(DeviceSpec{SystemID: "4y3haf"})
+	err = device.Delete()
+	c.Check(err, gc.ErrorMatches, ".*test object not supported.*")
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+
+	"github.com/juju/collections/set"
+)
+
+// The kinds of UnknownValue.
+const (
+	UnknownStatusName    = "status_name"
+	UnknownPowerState    = "power_state"
+	UnknownNodeType      = "node_type"
+	UnknownInterfaceType = "interface type"
+)
+
+// UnknownValue describes a value read from the server that this package
+// does not know, as reported to ControllerArgs.UnknownValue.
+type UnknownValue struct {
+	// Kind is one of the Unknown* values.
+	Kind  string
+	Value string
+	// SystemID is the node the value was read from.
+	SystemID string
+}
+
+// String returns a readable description of the value.
+func (v UnknownValue) String() string {
+	return fmt.Sprintf("unknown %s %q on %s", v.Kind, v.Value, v.SystemID)
+}
+
+var (
+	knownStatusNames = set.NewStrings(
+		"New", "Commissioning", "Failed commissioning", "Missing", "Ready",
+		"Reserved", "Allocated", "Deploying", "Deployed", "Retired", "Broken",
+		"Failed deployment", "Releasing", "Releasing failed", "Disk erasing",
+		"Failed disk erasing", "Rescue mode", "Entering rescue mode",
+		"Failed to enter rescue mode", "Exiting rescue mode",
+		"Failed to exit rescue mode", "Testing", "Failed testing",
+	)
+	knownPowerStates    = set.NewStrings("on", "off", "unknown", "error")
+	knownInterfaceTypes = set.NewStrings("physical", "bond", "bridge", "vlan", "alias", "unknown")
+)
+
+func knownNodeType(t NodeType) bool {
+	return t >= NodeTypeMachine && t <= NodeTypeRegionAndRackController
+}
+
+func (c *controller) reportUnknown(kind, value, systemID string) {
+	if c.unknownValue == nil {
+		return
+	}
+	c.unknownValue(UnknownValue{Kind: kind, Value: value, SystemID: systemID})
+}
+
+func (c *controller) checkMachineValues(m *machine) {
+	if c.unknownValue == nil {
+		return
+	}
+	if m.statusName != "" && !knownStatusNames.Contains(m.statusName) {
+		c.reportUnknown(UnknownStatusName, m.statusName, m.systemID)
+	}
+	if m.powerState != "" && !knownPowerStates.Contains(m.powerState) {
+		c.reportUnknown(UnknownPowerState, m.powerState, m.systemID)
+	}
+	if !knownNodeType(m.nodeType) {
+		c.reportUnknown(UnknownNodeType, fmt.Sprint(int(m.nodeType)), m.systemID)
+	}
+	c.checkInterfaceValues(m.interfaceSet, m.systemID)
+}
+
+func (c *controller) checkDeviceValues(d *device) {
+	if c.unknownValue == nil {
+		return
+	}
+	if !knownNodeType(d.nodeType) {
+		c.reportUnknown(UnknownNodeType, fmt.Sprint(int(d.nodeType)), d.systemID)
+	}
+	c.checkInterfaceValues(d.interfaceSet, d.systemID)
+}
+
+func (c *controller) checkInterfaceValues(interfaces []*interface_, systemID string) {
+	for _, iface := range interfaces {
+		if !knownInterfaceTypes.Contains(iface.type_) {
+			c.reportUnknown(UnknownInterfaceType, iface.type_, systemID)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type user struct {
+	controller *controller
+
+	resourceURI string
+
+	username string
+	email    string
+	admin    bool
+	local    bool
+}
+
+// Username implements User.
+func (u *user) Username() string {
+	return u.username
+}
+
+// Email implements User.
+func (u *user) Email() string {
+	return u.email
+}
+
+// Admin implements User.
+func (u *user) Admin() bool {
+	return u.admin
+}
+
+// Local implements User.
+func (u *user) Local() bool {
+	return u.local
+}
+
+// Delete implements User.
+func (u *user) Delete() error {
+	if err := u.controller.delete(u.resourceURI); err != nil {
+		return errors.Trace(userError(err))
+	}
+	return nil
+}
+
+// Users implements Controller.
+func (c *controller) Users() ([]User, error) {
+	source, err := c.get(UsersPath)
+	if err != nil {
+		return nil, errors.Trace(userError(err))
+	}
+	users, err := readUsers(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []User
+	for _, u := range users {
+		u.controller = c
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+// CreateUserArgs is an argument struct for Controller.CreateUser. All the
+// fields but Admin are required.
+type CreateUserArgs struct {
+	Username string
+	Email    string
+	Password string
+	// Admin makes the user a MAAS administrator.
+	Admin bool
+}
+
+// Validate ensures that the username, email and password are set.
+func (a *CreateUserArgs) Validate() error {
+	if a.Username == "" {
+		return errors.NotValidf("missing Username")
+	}
+	if a.Email == "" {
+		return errors.NotValidf("missing Email")
+	}
+	if a.Password == "" {
+		return errors.NotValidf("missing Password")
+	}
+	return nil
+}
+
+// CreateUser implements Controller.
+func (c *controller) CreateUser(args CreateUserArgs) (User, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("username", args.Username)
+	params.Values.Add("email", args.Email)
+	params.Values.Add("password", args.Password)
+	// The server requires is_superuser, even when it is false.
+	params.Values.Add("is_superuser", strconv.FormatBool(args.Admin))
+	source, err := c.post(UsersPath, "", params.Values)
+	if err != nil {
+		return nil, errors.Trace(userError(err))
+	}
+	result, err := readUser(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result.controller = c
+	return result, nil
+}
+
+// WhoAmI implements Controller.
+func (c *controller) WhoAmI() (User, error) {
+	source, err := c.getOp(UsersPath, "whoami")
+	if err != nil {
+		return nil, errors.Trace(userError(err))
+	}
+	var result *user
+	if username, ok := source.(string); ok {
+		// Servers before 2.2 return the username alone.
+		result = &user{username: username, local: true}
+	} else {
+		result, err = readUser(c.schemaVersion(), source)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if result.resourceURI == "" {
+		result.resourceURI = APIPath(UsersPath, result.username)
+	}
+	result.controller = c
+	return result, nil
+}
+
+// userError translates the errors of the users API. The server gives a
+// 400 for a username that is taken or a user that still owns resources,
+// and a 403 when the user of the API key is not an administrator.
+func userError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		case http.StatusConflict:
+			return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+func readUser(controllerVersion version.Number, source interface{}) (*user, error) {
+	readFunc, err := getUserDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "user base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
+func readUsers(controllerVersion version.Number, source interface{}) ([]*user, error) {
+	readFunc, err := getUserDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "user base schema check failed")
+	}
+	sourceList := coerced.([]interface{})
+	result := make([]*user, 0, len(sourceList))
+	for i, value := range sourceList {
+		u, err := readFunc(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "user %d", i)
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+func getUserDeserializationFunc(controllerVersion version.Number) (userDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range userDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no user read func for version %s", controllerVersion)
+	}
+	return userDeserializationFuncs[deserialisationVersion], nil
+}
+
+type userDeserializationFunc func(map[string]interface{}) (*user, error)
+
+var userDeserializationFuncs = map[version.Number]userDeserializationFunc{
+	twoDotOh: user_2_0,
+}
+
+func user_2_0(source map[string]interface{}) (*user, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+		"username":     schema.String(),
+		"email":        schema.OneOf(schema.Nil(""), schema.String()),
+		"is_superuser": schema.Bool(),
+		"is_local":     schema.Bool(),
+	}
+	defaults := schema.Defaults{
+		"resource_uri": "",
+		"email":        "",
+		// Servers before 2.2 have no external authentication.
+		"is_local": true,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "user 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	email, _ := valid["email"].(string)
+	return &user{
+		resourceURI: valid["resource_uri"].(string),
+		username:    valid["username"].(string),
+		email:       email,
+		admin:       valid["is_superuser"].(bool),
+		local:       valid["is_local"].(bool),
+	}, nil
+}
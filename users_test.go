@@ -0,0 +1,171 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type userSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&userSuite{})
+
+func (*userSuite) TestReadUsersBadSchema(c *gc.C) {
+	_, err := readUsers(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `user base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*userSuite) TestReadUsers(c *gc.C) {
+	users, err := readUsers(twoDotOh, parseJSON(c, usersResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(users, gc.HasLen, 2)
+
+	u := users[0]
+	c.Check(u.Username(), gc.Equals, "admin")
+	c.Check(u.Email(), gc.Equals, "admin@example.com")
+	c.Check(u.Admin(), jc.IsTrue)
+	c.Check(u.Local(), jc.IsTrue)
+
+	u = users[1]
+	c.Check(u.Username(), gc.Equals, "bob")
+	c.Check(u.Email(), gc.Equals, "")
+	c.Check(u.Admin(), jc.IsFalse)
+	c.Check(u.Local(), jc.IsFalse)
+}
+
+func (*userSuite) TestLowVersion(c *gc.C) {
+	_, err := readUsers(version.MustParse("1.9.0"), parseJSON(c, usersResponse))
+	c.Assert(err.Error(), gc.Equals, `no user read func for version 1.9.0`)
+}
+
+func (s *userSuite) TestUsers(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/users/", http.StatusOK, usersResponse)
+	users, err := controller.Users()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(users, gc.HasLen, 2)
+
+	server.AddDeleteResponse("/MAAS/api/2.0/users/bob/", http.StatusNoContent, "")
+	err = users[1].Delete()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *userSuite) TestUsersForbidden(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/users/", http.StatusForbidden, "admins only")
+	_, err := controller.Users()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *userSuite) TestDeleteUserWithResources(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/users/", http.StatusOK, usersResponse)
+	users, err := controller.Users()
+	c.Assert(err, jc.ErrorIsNil)
+
+	server.AddDeleteResponse("/MAAS/api/2.0/users/bob/", http.StatusBadRequest, "User bob cannot be deleted: 2 node(s) are still allocated.")
+	err = users[1].Delete()
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *userSuite) TestCreateUser(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/users/?op=", http.StatusOK, userResponse)
+	u, err := controller.CreateUser(CreateUserArgs{
+		Username: "admin",
+		Email:    "admin@example.com",
+		Password: "sekrit",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(u.Username(), gc.Equals, "admin")
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 4)
+	c.Check(form.Get("username"), gc.Equals, "admin")
+	c.Check(form.Get("email"), gc.Equals, "admin@example.com")
+	c.Check(form.Get("password"), gc.Equals, "sekrit")
+	c.Check(form.Get("is_superuser"), gc.Equals, "false")
+}
+
+func (s *userSuite) TestCreateUserValidates(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	for _, test := range []struct {
+		args    CreateUserArgs
+		message string
+	}{{
+		args:    CreateUserArgs{Email: "bob@example.com", Password: "sekrit"},
+		message: "missing Username not valid",
+	}, {
+		args:    CreateUserArgs{Username: "bob", Password: "sekrit"},
+		message: "missing Email not valid",
+	}, {
+		args:    CreateUserArgs{Username: "bob", Email: "bob@example.com"},
+		message: "missing Password not valid",
+	}} {
+		_, err := controller.CreateUser(test.args)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (s *userSuite) TestCreateUserTaken(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/users/?op=", http.StatusBadRequest, `{"username": ["User with this Username already exists."]}`)
+	_, err := controller.CreateUser(CreateUserArgs{Username: "admin", Email: "admin@example.com", Password: "sekrit", Admin: true})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(server.LastRequest().PostForm.Get("is_superuser"), gc.Equals, "true")
+}
+
+func (s *userSuite) TestWhoAmI(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, userResponse)
+	u, err := controller.WhoAmI()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(u.Username(), gc.Equals, "admin")
+	c.Check(u.Email(), gc.Equals, "admin@example.com")
+	c.Check(u.Admin(), jc.IsTrue)
+}
+
+func (s *userSuite) TestWhoAmIUsernameOnly(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"bob"`)
+	u, err := controller.WhoAmI()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(u.Username(), gc.Equals, "bob")
+	c.Check(u.Admin(), jc.IsFalse)
+
+	server.AddDeleteResponse("/api/2.0/users/bob/", http.StatusNoContent, "")
+	err = u.Delete()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+const (
+	userResponse = `
+{
+    "resource_uri": "/MAAS/api/2.0/users/admin/",
+    "username": "admin",
+    "email": "admin@example.com",
+    "is_superuser": true,
+    "is_local": true
+}
+`
+	usersResponse = `[` + userResponse + `,
+{
+    "resource_uri": "/MAAS/api/2.0/users/bob/",
+    "username": "bob",
+    "email": null,
+    "is_superuser": false,
+    "is_local": false
+}
+]`
+)
@@ -71,7 +71,7 @@ func readVLANs(controllerVersion version.Number, source interface{}) ([]*vlan, e
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "vlan base schema check failed")
+		return nil, WrapWithDeserializationError(err, "vlan base schema check failed")
 	}
 	valid := coerced.([]interface{})
 
@@ -88,16 +88,24 @@ func readVLANs(controllerVersion version.Number, source interface{}) ([]*vlan, e
 	return readVLANList(valid, readFunc)
 }
 
+func readVLAN(controllerVersion version.Number, source interface{}) (*vlan, error) {
+	vlans, err := readVLANs(controllerVersion, []interface{}{source})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return vlans[0], nil
+}
+
 func readVLANList(sourceList []interface{}, readFunc vlanDeserializationFunc) ([]*vlan, error) {
 	result := make([]*vlan, 0, len(sourceList))
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, errors.Errorf("unexpected value for vlan %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for vlan %d, %T", i, value), indexPath(i))
 		}
 		vlan, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "vlan %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "vlan %d", i)
 		}
 		result = append(result, vlan)
 	}
@@ -126,7 +134,7 @@ func vlan_2_0(source map[string]interface{}) (*vlan, error) {
 	checker := schema.FieldMap(fields, nil)
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "vlan 2.0 schema check failed")
+		return nil, WrapWithDeserializationError(err, "vlan 2.0 schema check failed")
 	}
 	valid := coerced.(map[string]interface{})
 	// From here we know that the map returned from the schema coercion
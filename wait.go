@@ -0,0 +1,162 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+)
+
+const (
+	// DefaultPollInterval is the first wait of WaitForStatus when the
+	// args do not give one.
+	DefaultPollInterval = 5 * time.Second
+
+	// DefaultMaxPollInterval is the longest wait of WaitForStatus when
+	// the args do not give one.
+	DefaultMaxPollInterval = time.Minute
+)
+
+// WaitForStatusArgs is an argument struct for Machine.WaitForStatus.
+type WaitForStatusArgs struct {
+	// Statuses are the status names to wait for, such as "Deployed".
+	Statuses []string
+
+	// PollInterval is the wait before the machine is first read again,
+	// and MaxPollInterval is the longest wait. The wait doubles after
+	// each read until it reaches MaxPollInterval.
+	PollInterval    time.Duration
+	MaxPollInterval time.Duration
+}
+
+// Validate checks that there are statuses to wait for.
+func (a *WaitForStatusArgs) Validate() error {
+	if len(a.Statuses) == 0 {
+		return errors.NotValidf("missing Statuses")
+	}
+	if a.PollInterval < 0 {
+		return errors.NotValidf("negative PollInterval")
+	}
+	if a.MaxPollInterval < 0 {
+		return errors.NotValidf("negative MaxPollInterval")
+	}
+	return nil
+}
+
+// MachineFailedError is returned by WaitForStatus when the machine reaches
+// a failed status, such as "Failed deployment", that it was not waiting
+// for.
+type MachineFailedError struct {
+	errors.Err
+	SystemID      string
+	StatusName    string
+	StatusMessage string
+}
+
+// NewMachineFailedError constructs a new MachineFailedError and sets the location.
+func NewMachineFailedError(systemID, statusName, statusMessage string) error {
+	err := &MachineFailedError{
+		Err:           errors.NewErr("machine %s: %s: %s", systemID, statusName, statusMessage),
+		SystemID:      systemID,
+		StatusName:    statusName,
+		StatusMessage: statusMessage,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// IsMachineFailedError returns true if err is a MachineFailedError.
+func IsMachineFailedError(err error) bool {
+	_, ok := errors.Cause(err).(*MachineFailedError)
+	return ok
+}
+
+// failedStatuses are the names of the statuses that a machine is left in
+// when an action fails, which need a user to act before it changes again.
+// Not all of them start with "Failed".
+var failedStatuses = set.NewStrings(
+	"Broken",
+	"Failed commissioning",
+	"Failed deployment",
+	"Failed disk erasing",
+	"Failed testing",
+	"Failed to enter rescue mode",
+	"Failed to exit rescue mode",
+	"Releasing failed",
+)
+
+func isFailedStatus(statusName string) bool {
+	return failedStatuses.Contains(statusName)
+}
+
+// WaitForStatus implements Machine.
+func (m *machine) WaitForStatus(ctx context.Context, args WaitForStatusArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	interval := args.PollInterval
+	if interval == 0 {
+		interval = DefaultPollInterval
+	}
+	maxInterval := args.MaxPollInterval
+	if maxInterval == 0 {
+		maxInterval = DefaultMaxPollInterval
+	}
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	controller := m.controller.WithContext(ctx).(*controller)
+	for {
+		for _, status := range args.Statuses {
+			if m.statusName == status {
+				return nil
+			}
+		}
+		if isFailedStatus(m.statusName) {
+			return NewMachineFailedError(m.systemID, m.statusName, m.statusMessage)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-m.controller.clock.After(interval):
+		}
+		if err := m.refresh(controller); err != nil {
+			return errors.Trace(err)
+		}
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+// refresh reads the machine again with the controller, which may be bound
+// to a context.
+func (m *machine) refresh(c *controller) error {
+	source, err := c.get(m.resourceURI)
+	if err != nil {
+		if IsContextError(err) {
+			return errors.Trace(err)
+		}
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	other, err := readMachine(c.schemaVersion(), source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(other)
+	return nil
+}
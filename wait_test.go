@@ -0,0 +1,119 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *machineSuite) getWaitingMachine(c *gc.C, statuses ...string) (*SimpleTestServer, *machine, *testing.Clock) {
+	server, machine := s.getServerAndMachine(c)
+	machine.statusName = "Deploying"
+	for _, status := range statuses {
+		response := updateJSONMap(c, machineResponse, map[string]interface{}{
+			"status_name":    status,
+			"status_message": "for testing",
+		})
+		server.AddGetResponse(machine.resourceURI, http.StatusOK, response)
+	}
+	clock := testing.NewClock(time.Time{})
+	machine.controller.clock = clock
+	return server, machine, clock
+}
+
+func (s *machineSuite) TestWaitForStatus(c *gc.C) {
+	server, machine, clock := s.getWaitingMachine(c, "Deploying", "Deployed")
+	done := make(chan error, 1)
+	go func() {
+		done <- machine.WaitForStatus(context.Background(), WaitForStatusArgs{
+			Statuses:     []string{"Deployed"},
+			PollInterval: time.Second,
+		})
+	}()
+	// The wait doubles after each read.
+	c.Assert(clock.WaitAdvance(time.Second, 5*time.Second, 1), jc.ErrorIsNil)
+	c.Assert(clock.WaitAdvance(2*time.Second, 5*time.Second, 1), jc.ErrorIsNil)
+
+	select {
+	case err := <-done:
+		c.Assert(err, jc.ErrorIsNil)
+	case <-time.After(5 * time.Second):
+		c.Fatalf("wait did not finish after advancing the clock")
+	}
+	c.Check(machine.StatusName(), gc.Equals, "Deployed")
+	c.Check(server.RequestCount(), gc.Equals, 2)
+}
+
+func (s *machineSuite) TestWaitForStatusAlreadyThere(c *gc.C) {
+	server, machine, _ := s.getWaitingMachine(c)
+	err := machine.WaitForStatus(context.Background(), WaitForStatusArgs{
+		Statuses: []string{"Deploying"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestWaitForStatusFailed(c *gc.C) {
+	_, machine, clock := s.getWaitingMachine(c, "Failed deployment")
+	done := make(chan error, 1)
+	go func() {
+		done <- machine.WaitForStatus(context.Background(), WaitForStatusArgs{
+			Statuses: []string{"Deployed"},
+		})
+	}()
+	c.Assert(clock.WaitAdvance(DefaultPollInterval, 5*time.Second, 1), jc.ErrorIsNil)
+
+	select {
+	case err := <-done:
+		c.Assert(err, jc.Satisfies, IsMachineFailedError)
+		failed := errors.Cause(err).(*MachineFailedError)
+		c.Check(failed.SystemID, gc.Equals, machine.SystemID())
+		c.Check(failed.StatusName, gc.Equals, "Failed deployment")
+		c.Check(failed.StatusMessage, gc.Equals, "for testing")
+	case <-time.After(5 * time.Second):
+		c.Fatalf("wait did not finish after advancing the clock")
+	}
+}
+
+func (s *machineSuite) TestIsFailedStatus(c *gc.C) {
+	for _, status := range []string{"Broken", "Failed deployment", "Failed testing", "Releasing failed", "Failed to exit rescue mode"} {
+		c.Check(isFailedStatus(status), jc.IsTrue, gc.Commentf(status))
+	}
+	for _, status := range []string{"Deployed", "Releasing", "Failed", "Deploying"} {
+		c.Check(isFailedStatus(status), jc.IsFalse, gc.Commentf(status))
+	}
+}
+
+func (s *machineSuite) TestWaitForStatusCancelled(c *gc.C) {
+	_, machine, clock := s.getWaitingMachine(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- machine.WaitForStatus(ctx, WaitForStatusArgs{
+			Statuses: []string{"Deployed"},
+		})
+	}()
+	c.Assert(clock.WaitAdvance(0, 5*time.Second, 1), jc.ErrorIsNil)
+	cancel()
+
+	select {
+	case err := <-done:
+		c.Assert(err, jc.Satisfies, IsContextError)
+	case <-time.After(5 * time.Second):
+		c.Fatalf("wait did not finish after cancelling")
+	}
+}
+
+func (s *machineSuite) TestWaitForStatusValidates(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	err := machine.WaitForStatus(context.Background(), WaitForStatusArgs{})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
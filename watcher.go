@@ -0,0 +1,292 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/clock"
+)
+
+// DefaultWatchInterval is how often a Watcher lists its resources when
+// WatcherArgs.Interval is not set.
+const DefaultWatchInterval = 30 * time.Second
+
+// WatchEventType says how a watched resource changed.
+type WatchEventType string
+
+const (
+	ResourceAdded   WatchEventType = "added"
+	ResourceRemoved WatchEventType = "removed"
+	ResourceChanged WatchEventType = "changed"
+)
+
+// WatchEvent is a single change seen by a Watcher.
+type WatchEvent struct {
+	Type WatchEventType
+
+	// Key identifies the resource, as returned by WatcherArgs.Key.
+	Key string
+
+	// Old is the resource as previously listed, and is nil for added
+	// resources. New is the resource as listed now, and is nil for removed
+	// resources.
+	Old interface{}
+	New interface{}
+}
+
+// WatcherArgs is an argument struct for passing information into
+// NewWatcher. The FabricsWatcherArgs, SpacesWatcherArgs, SubnetsWatcherArgs
+// and ZonesWatcherArgs functions return args for the common resources.
+type WatcherArgs struct {
+	// List returns all the resources being watched.
+	List func() ([]interface{}, error)
+
+	// Key returns a value that identifies a resource across listings,
+	// usually its ID.
+	Key func(interface{}) string
+
+	// Interval is the time between listings. Zero means that
+	// DefaultWatchInterval is used.
+	Interval time.Duration
+
+	// Clock is used to wait between listings. If it is nil, the wall
+	// clock is used.
+	Clock clock.Clock
+}
+
+// Validate ensures that List and Key are set.
+func (a *WatcherArgs) Validate() error {
+	if a.List == nil {
+		return errors.NotValidf("missing List")
+	}
+	if a.Key == nil {
+		return errors.NotValidf("missing Key")
+	}
+	if a.Interval < 0 {
+		return errors.NotValidf("negative Interval")
+	}
+	return nil
+}
+
+// Watcher periodically lists a resource type and reports the differences
+// between each listing and the one before, for the resources that MAAS
+// offers no event stream for. Resources are compared with reflect.DeepEqual.
+type Watcher struct {
+	args     WatcherArgs
+	changes  chan []WatchEvent
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWatcher starts a Watcher. The first listing reports every resource as
+// added. Listings that fail are logged and retried after the interval, and
+// the next successful listing is compared with the last successful one.
+func NewWatcher(args WatcherArgs) (*Watcher, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if args.Interval == 0 {
+		args.Interval = DefaultWatchInterval
+	}
+	if args.Clock == nil {
+		args.Clock = clock.WallClock
+	}
+	w := &Watcher{
+		args:    args,
+		changes: make(chan []WatchEvent),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.loop()
+	return w, nil
+}
+
+// Changes returns the channel that receives the events from each listing
+// that differs from the one before, sorted by key. The channel is closed
+// when the Watcher is stopped. No listings are made while a batch of
+// events waits to be received.
+func (w *Watcher) Changes() <-chan []WatchEvent {
+	return w.changes
+}
+
+// Stop stops the Watcher and waits for it to finish.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+func (w *Watcher) loop() {
+	defer close(w.done)
+	defer close(w.changes)
+	var known map[string]interface{}
+	for {
+		current, err := w.snapshot()
+		if err != nil {
+			controllerLogger.Warningf("watcher listing failed: %v", err)
+		} else {
+			events := diffSnapshots(known, current)
+			known = current
+			if len(events) > 0 {
+				select {
+				case w.changes <- events:
+				case <-w.stop:
+					return
+				}
+			}
+		}
+		select {
+		case <-w.args.Clock.After(w.args.Interval):
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *Watcher) snapshot() (map[string]interface{}, error) {
+	resources, err := w.args.List()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result := make(map[string]interface{}, len(resources))
+	for _, resource := range resources {
+		result[w.args.Key(resource)] = resource
+	}
+	return result, nil
+}
+
+func diffSnapshots(before, after map[string]interface{}) []WatchEvent {
+	keys := make([]string, 0, len(after))
+	for key := range after {
+		keys = append(keys, key)
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var events []WatchEvent
+	for _, key := range keys {
+		old, wasKnown := before[key]
+		current, isKnown := after[key]
+		switch {
+		case !wasKnown:
+			events = append(events, WatchEvent{Type: ResourceAdded, Key: key, New: current})
+		case !isKnown:
+			events = append(events, WatchEvent{Type: ResourceRemoved, Key: key, Old: old})
+		case !reflect.DeepEqual(old, current):
+			events = append(events, WatchEvent{Type: ResourceChanged, Key: key, Old: old, New: current})
+		}
+	}
+	return events
+}
+
+// FabricsWatcherArgs returns args for watching the fabrics, keyed by ID.
+// The events hold Fabric values.
+func FabricsWatcherArgs(c Controller) WatcherArgs {
+	return WatcherArgs{
+		List: func() ([]interface{}, error) {
+			fabrics, err := c.Fabrics()
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			result := make([]interface{}, len(fabrics))
+			for i, fabric := range fabrics {
+				result[i] = fabric
+			}
+			return result, nil
+		},
+		Key: func(value interface{}) string {
+			return fmt.Sprint(value.(Fabric).ID())
+		},
+		Clock: controllerClock(c),
+	}
+}
+
+// SpacesWatcherArgs returns args for watching the spaces, keyed by ID.
+// A change to any subnet of a space is reported as a change to the space.
+// The events hold Space values.
+func SpacesWatcherArgs(c Controller) WatcherArgs {
+	return WatcherArgs{
+		List: func() ([]interface{}, error) {
+			spaces, err := c.Spaces()
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			result := make([]interface{}, len(spaces))
+			for i, space := range spaces {
+				result[i] = space
+			}
+			return result, nil
+		},
+		Key: func(value interface{}) string {
+			return fmt.Sprint(value.(Space).ID())
+		},
+		Clock: controllerClock(c),
+	}
+}
+
+// SubnetsWatcherArgs returns args for watching the subnets, keyed by ID.
+// The subnets are those listed in the spaces. The events hold Subnet
+// values.
+func SubnetsWatcherArgs(c Controller) WatcherArgs {
+	return WatcherArgs{
+		List: func() ([]interface{}, error) {
+			spaces, err := c.Spaces()
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			var result []interface{}
+			for _, space := range spaces {
+				for _, subnet := range space.Subnets() {
+					result = append(result, subnet)
+				}
+			}
+			return result, nil
+		},
+		Key: func(value interface{}) string {
+			return fmt.Sprint(value.(Subnet).ID())
+		},
+		Clock: controllerClock(c),
+	}
+}
+
+// ZonesWatcherArgs returns args for watching the zones, keyed by name as
+// not all servers report zone IDs. The events hold Zone values.
+func ZonesWatcherArgs(c Controller) WatcherArgs {
+	return WatcherArgs{
+		List: func() ([]interface{}, error) {
+			zones, err := c.Zones()
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			result := make([]interface{}, len(zones))
+			for i, zone := range zones {
+				result[i] = zone
+			}
+			return result, nil
+		},
+		Key: func(value interface{}) string {
+			return value.(Zone).Name()
+		},
+		Clock: controllerClock(c),
+	}
+}
+
+// controllerClock returns the clock given in the ControllerArgs, so that
+// watchers share it, or nil for other Controller implementations.
+func controllerClock(c Controller) clock.Clock {
+	if ctrl, ok := c.(*controller); ok {
+		return ctrl.clock
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type watcherSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&watcherSuite{})
+
+type watched struct {
+	key   string
+	value int
+}
+
+// listings returns a List func that returns each listing in turn.
+func listings(values ...[]interface{}) func() ([]interface{}, error) {
+	return func() ([]interface{}, error) {
+		if len(values) == 0 {
+			return nil, errors.New("no more listings")
+		}
+		result := values[0]
+		values = values[1:]
+		if result == nil {
+			return nil, errors.New("listing failed")
+		}
+		return result, nil
+	}
+}
+
+func nextChanges(c *gc.C, w *Watcher) []WatchEvent {
+	select {
+	case events, ok := <-w.Changes():
+		c.Assert(ok, jc.IsTrue)
+		return events
+	case <-time.After(5 * time.Second):
+		c.Fatalf("no changes received")
+	}
+	return nil
+}
+
+func (s *watcherSuite) TestChanges(c *gc.C) {
+	clock := testing.NewClock(time.Time{})
+	a, b, b2, d := watched{"a", 1}, watched{"b", 2}, watched{"b", 3}, watched{"d", 4}
+	w, err := NewWatcher(WatcherArgs{
+		List: listings(
+			[]interface{}{a, b},
+			[]interface{}{a, b},
+			nil,
+			[]interface{}{b2, d},
+		),
+		Key:      func(v interface{}) string { return v.(watched).key },
+		Interval: time.Minute,
+		Clock:    clock,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	defer w.Stop()
+
+	c.Check(nextChanges(c, w), jc.DeepEquals, []WatchEvent{
+		{Type: ResourceAdded, Key: "a", New: a},
+		{Type: ResourceAdded, Key: "b", New: b},
+	})
+	// The second listing is unchanged and the third fails, so neither
+	// gives any events.
+	for i := 0; i < 3; i++ {
+		c.Assert(clock.WaitAdvance(time.Minute, 5*time.Second, 1), jc.ErrorIsNil)
+	}
+	c.Check(nextChanges(c, w), jc.DeepEquals, []WatchEvent{
+		{Type: ResourceRemoved, Key: "a", Old: a},
+		{Type: ResourceChanged, Key: "b", Old: b, New: b2},
+		{Type: ResourceAdded, Key: "d", New: d},
+	})
+}
+
+func (s *watcherSuite) TestStopClosesChanges(c *gc.C) {
+	w, err := NewWatcher(WatcherArgs{
+		List:  listings([]interface{}{}),
+		Key:   func(v interface{}) string { return "" },
+		Clock: testing.NewClock(time.Time{}),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	w.Stop()
+	_, ok := <-w.Changes()
+	c.Check(ok, jc.IsFalse)
+	// Stopping again is fine.
+	w.Stop()
+}
+
+func (s *watcherSuite) TestArgsValidate(c *gc.C) {
+	_, err := NewWatcher(WatcherArgs{Key: func(interface{}) string { return "" }})
+	c.Check(err, gc.ErrorMatches, "missing List not valid")
+	_, err = NewWatcher(WatcherArgs{List: listings()})
+	c.Check(err, gc.ErrorMatches, "missing Key not valid")
+}
+
+func (s *watcherSuite) TestZones(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+	w, err := NewWatcher(ZonesWatcherArgs(controller))
+	c.Assert(err, jc.ErrorIsNil)
+	defer w.Stop()
+
+	events := nextChanges(c, w)
+	c.Assert(events, gc.HasLen, 2)
+	c.Check(events[0].Key, gc.Equals, "default")
+	c.Check(events[1].New.(Zone).Description(), gc.Equals, "special description")
+}
@@ -15,10 +15,16 @@ type zone struct {
 
 	resourceURI string
 
+	id          int
 	name        string
 	description string
 }
 
+// ID implements Zone.
+func (z *zone) ID() int {
+	return z.id
+}
+
 // Name implements Zone.
 func (z *zone) Name() string {
 	return z.name
@@ -33,7 +39,7 @@ func readZones(controllerVersion version.Number, source interface{}) ([]*zone, e
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "zone base schema check failed")
+		return nil, WrapWithDeserializationError(err, "zone base schema check failed")
 	}
 	valid := coerced.([]interface{})
 
@@ -56,11 +62,11 @@ func readZoneList(sourceList []interface{}, readFunc zoneDeserializationFunc) ([
 	for i, value := range sourceList {
 		source, ok := value.(map[string]interface{})
 		if !ok {
-			return nil, errors.Errorf("unexpected value for zone %d, %T", i, value)
+			return nil, atPath(NewDeserializationError("unexpected value for zone %d, %T", i, value), indexPath(i))
 		}
 		zone, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "zone %d", i)
+			return nil, errors.Annotatef(atPath(err, indexPath(i)), "zone %d", i)
 		}
 		result = append(result, zone)
 	}
@@ -75,20 +81,26 @@ var zoneDeserializationFuncs = map[version.Number]zoneDeserializationFunc{
 
 func zone_2_0(source map[string]interface{}) (*zone, error) {
 	fields := schema.Fields{
+		"id":           schema.ForceInt(),
 		"name":         schema.String(),
 		"description":  schema.String(),
 		"resource_uri": schema.String(),
 	}
-	checker := schema.FieldMap(fields, nil) // no defaults
+	// Older servers don't include the id.
+	defaults := schema.Defaults{
+		"id": 0,
+	}
+	checker := schema.FieldMap(fields, defaults)
 	coerced, err := checker.Coerce(source, nil)
 	if err != nil {
-		return nil, errors.Annotatef(err, "zone 2.0 schema check failed")
+		return nil, WrapWithDeserializationError(err, "zone 2.0 schema check failed")
 	}
 	valid := coerced.(map[string]interface{})
 	// From here we know that the map returned from the schema coercion
 	// contains fields of the right type.
 
 	result := &zone{
+		id:          valid["id"].(int),
 		name:        valid["name"].(string),
 		description: valid["description"].(string),
 		resourceURI: valid["resource_uri"].(string),